@@ -4,6 +4,9 @@ import LogsPage from '../components/Logs/Index.vue'
 import GeneralPage from '../components/General/Index.vue'
 import McpPage from '../components/Mcp/index.vue'
 import SkillPage from '../components/Skill/Index.vue'
+import PromptPage from '../components/Prompt/Index.vue'
+import AgentPage from '../components/Agent/Index.vue'
+import HookPage from '../components/Hook/Index.vue'
 
 const routes = [
   { path: '/', component: MainPage },
@@ -11,6 +14,9 @@ const routes = [
   { path: '/settings', component: GeneralPage },
   { path: '/mcp', component: McpPage },
   { path: '/skill', component: SkillPage },
+  { path: '/prompt', component: PromptPage },
+  { path: '/agent', component: AgentPage },
+  { path: '/hook', component: HookPage },
 ]
 
 export default createRouter({
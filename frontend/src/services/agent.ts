@@ -0,0 +1,73 @@
+import { Call } from '@wailsio/runtime'
+
+export type AgentLocation = 'user' | 'project'
+
+export type AgentTarget = {
+  location: AgentLocation
+  project_dir: string
+}
+
+export type Agent = {
+  name: string
+  file_name: string
+  description: string
+  model: string
+  tools: string[]
+  color?: string
+  prompt: string
+  enabled: boolean
+  location: AgentLocation
+  project_dir?: string
+  path: string
+  codex_synced: boolean
+  updated_at: string
+}
+
+export type AgentTemplate = {
+  id: string
+  name: string
+  description: string
+  model: string
+  tools: string[]
+  prompt: string
+}
+
+export type CreateAgentPayload = {
+  name: string
+  description: string
+  template: string
+  model: string
+  tools: string[]
+  location: AgentLocation
+  project_dir: string
+}
+
+export const fetchAgents = async (target: AgentTarget): Promise<Agent[]> => {
+  const response = await Call.ByName('codeswitch/services.AgentService.ListAgents', target)
+  return (response as Agent[]) ?? []
+}
+
+export const fetchAgentTemplates = async (): Promise<AgentTemplate[]> => {
+  const response = await Call.ByName('codeswitch/services.AgentService.ListAgentTemplates')
+  return (response as AgentTemplate[]) ?? []
+}
+
+export const createAgent = async (payload: CreateAgentPayload): Promise<Agent> => {
+  return (await Call.ByName('codeswitch/services.AgentService.CreateAgent', payload)) as Agent
+}
+
+export const saveAgent = async (agent: Agent): Promise<Agent> => {
+  return (await Call.ByName('codeswitch/services.AgentService.SaveAgent', agent)) as Agent
+}
+
+export const deleteAgent = async (target: AgentTarget, fileName: string): Promise<void> => {
+  await Call.ByName('codeswitch/services.AgentService.DeleteAgent', target, fileName)
+}
+
+export const setAgentEnabled = async (target: AgentTarget, fileName: string, enabled: boolean): Promise<Agent> => {
+  return (await Call.ByName('codeswitch/services.AgentService.SetAgentEnabled', target, fileName, enabled)) as Agent
+}
+
+export const setAgentCodexSync = async (target: AgentTarget, fileName: string, enabled: boolean): Promise<Agent> => {
+  return (await Call.ByName('codeswitch/services.AgentService.SetAgentCodexSync', target, fileName, enabled)) as Agent
+}
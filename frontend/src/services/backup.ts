@@ -0,0 +1,45 @@
+import { Call } from '@wailsio/runtime'
+import type { PromptDiff } from './prompt'
+
+export type BackupPolicy = {
+  keep: number
+}
+
+export type BackupFile = {
+  path: string
+  exists: boolean
+  size: number
+  hash?: string
+}
+
+export type BackupSnapshot = {
+  id: string
+  operation: string
+  created_at: string
+  files: BackupFile[]
+}
+
+export const fetchBackups = async (path = ''): Promise<BackupSnapshot[]> => {
+  const snapshots = await Call.ByName('codeswitch/services.BackupService.ListBackups', path)
+  return (snapshots as BackupSnapshot[] | null) ?? []
+}
+
+export const restoreBackup = async (id: string, path = ''): Promise<void> => {
+  await Call.ByName('codeswitch/services.BackupService.RestoreBackup', id, path)
+}
+
+export const diffBackup = async (id: string, path: string): Promise<PromptDiff> => {
+  return (await Call.ByName('codeswitch/services.BackupService.DiffBackup', id, path)) as PromptDiff
+}
+
+export const deleteBackup = async (id: string): Promise<void> => {
+  await Call.ByName('codeswitch/services.BackupService.DeleteBackup', id)
+}
+
+export const fetchBackupPolicy = async (): Promise<BackupPolicy> => {
+  return (await Call.ByName('codeswitch/services.BackupService.GetBackupPolicy')) as BackupPolicy
+}
+
+export const saveBackupPolicy = async (policy: BackupPolicy): Promise<BackupPolicy> => {
+  return (await Call.ByName('codeswitch/services.BackupService.SaveBackupPolicy', policy)) as BackupPolicy
+}
@@ -1,11 +1,12 @@
 import { Call } from '@wailsio/runtime'
 import type { ClaudeProxyStatus } from '../../bindings/codeswitch/services/models'
 
-type Platform = 'claude' | 'codex'
+type Platform = 'claude' | 'codex' | 'gemini'
 
 const serviceNames: Record<Platform, string> = {
   claude: 'codeswitch/services.ClaudeSettingsService',
   codex: 'codeswitch/services.CodexSettingsService',
+  gemini: 'codeswitch/services.GeminiSettingsService',
 }
 
 const callByPlatform = async <T = unknown>(platform: Platform, method: string, payload?: any[]): Promise<T> => {
@@ -25,3 +26,114 @@ export const enableProxy = async (platform: Platform): Promise<void> => {
 export const disableProxy = async (platform: Platform): Promise<void> => {
   await callByPlatform(platform, 'DisableProxy')
 }
+
+export type CodexProfileStatus = {
+  enabled: boolean
+  profile: string
+  base_url: string
+  default: boolean
+  commands: string[]
+}
+
+export const fetchCodexProfileStatus = async (): Promise<CodexProfileStatus> => {
+  return callByPlatform<CodexProfileStatus>('codex', 'ProfileStatus')
+}
+
+export const enableCodexProfile = async (): Promise<void> => {
+  await callByPlatform('codex', 'EnableProfile')
+}
+
+export const disableCodexProfile = async (): Promise<void> => {
+  await callByPlatform('codex', 'DisableProfile')
+}
+
+export const setCodexProfileDefault = async (enabled: boolean): Promise<void> => {
+  await callByPlatform('codex', 'SetProfileDefault', [enabled])
+}
+
+export type ClaudeSettingsScope = 'user' | 'project' | 'local'
+
+export type ClaudeScopeStatus = {
+  scope: ClaudeSettingsScope
+  path: string
+  exists: boolean
+  mode: '' | 'proxy' | 'direct'
+  provider: string
+  base_url: string
+  proxy: boolean
+  effective: boolean
+}
+
+export type ClaudeProjectSettings = {
+  project: string
+  scopes: ClaudeScopeStatus[]
+  effective_scope: ClaudeSettingsScope | ''
+  effective_base_url: string
+  via_proxy: boolean
+}
+
+export const fetchClaudeProjectSettings = async (project: string): Promise<ClaudeProjectSettings> => {
+  return callByPlatform<ClaudeProjectSettings>('claude', 'ProjectSettingsStatus', [project])
+}
+
+export const enableClaudeProjectProxy = async (project: string, scope: ClaudeSettingsScope): Promise<void> => {
+  await callByPlatform('claude', 'EnableProjectProxy', [project, scope])
+}
+
+export const applyClaudeProjectProvider = async (project: string, scope: ClaudeSettingsScope, provider: unknown): Promise<void> => {
+  await callByPlatform('claude', 'ApplyProjectProvider', [project, scope, provider])
+}
+
+export const clearClaudeProjectScope = async (project: string, scope: ClaudeSettingsScope): Promise<void> => {
+  await callByPlatform('claude', 'ClearProjectScope', [project, scope])
+}
+
+export type ClaudeHookEvent =
+  | 'PreToolUse'
+  | 'PostToolUse'
+  | 'UserPromptSubmit'
+  | 'Notification'
+  | 'Stop'
+  | 'SubagentStop'
+  | 'PreCompact'
+  | 'SessionStart'
+  | 'SessionEnd'
+
+export type ClaudeHook = {
+  id: string
+  event: ClaudeHookEvent
+  matcher: string
+  type: string
+  command: string
+  timeout?: number
+}
+
+export type ClaudeHookRecipe = {
+  id: string
+  name: string
+  description: string
+  requires: string[] | null
+  hook: ClaudeHook
+}
+
+export const fetchClaudeHooks = async (): Promise<ClaudeHook[]> => {
+  const hooks = await callByPlatform<ClaudeHook[] | null>('claude', 'ListClaudeHooks')
+  return hooks ?? []
+}
+
+export const fetchClaudeHookRecipes = async (): Promise<ClaudeHookRecipe[]> => {
+  const recipes = await callByPlatform<ClaudeHookRecipe[] | null>('claude', 'ListClaudeHookRecipes')
+  return recipes ?? []
+}
+
+export const saveClaudeHook = async (hook: ClaudeHook): Promise<ClaudeHook> => {
+  return callByPlatform<ClaudeHook>('claude', 'SaveClaudeHook', [hook])
+}
+
+export const deleteClaudeHook = async (id: string): Promise<void> => {
+  await callByPlatform('claude', 'DeleteClaudeHook', [id])
+}
+
+export const applyClaudeHookRecipe = async (id: string): Promise<ClaudeHook> => {
+  return callByPlatform<ClaudeHook>('claude', 'ApplyClaudeHookRecipe', [id])
+}
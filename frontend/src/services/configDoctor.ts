@@ -0,0 +1,27 @@
+import { Call } from '@wailsio/runtime'
+
+export type ConfigDiagnosis = {
+  id: string
+  platform: 'claude' | 'codex' | 'gemini'
+  path: string
+  field: string
+  message: string
+  detail: string
+  fix?: string
+  fixable: boolean
+}
+
+export const diagnoseConfigs = async (): Promise<ConfigDiagnosis[]> => {
+  const diagnoses = await Call.ByName('codeswitch/services.ConfigDoctorService.DiagnoseConfigs')
+  return (diagnoses as ConfigDiagnosis[] | null) ?? []
+}
+
+export const fixConfigIssue = async (id: string): Promise<ConfigDiagnosis[]> => {
+  const diagnoses = await Call.ByName('codeswitch/services.ConfigDoctorService.FixConfigIssue', id)
+  return (diagnoses as ConfigDiagnosis[] | null) ?? []
+}
+
+export const fixAllConfigIssues = async (): Promise<ConfigDiagnosis[]> => {
+  const diagnoses = await Call.ByName('codeswitch/services.ConfigDoctorService.FixAllConfigIssues')
+  return (diagnoses as ConfigDiagnosis[] | null) ?? []
+}
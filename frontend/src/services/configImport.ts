@@ -1,4 +1,5 @@
 import { Call } from '@wailsio/runtime'
+import type { McpServer } from './mcp'
 
 export type ConfigImportStatus = {
   config_exists: boolean
@@ -45,3 +46,20 @@ export const importFromCustomFile = async (path: string): Promise<ConfigImportRe
   const response = await Call.ByName('codeswitch/services.ImportService.ImportFromFile', path)
   return response as ConfigImportResult
 }
+
+export type McpImportCandidate = {
+  server: McpServer
+  sources: string[]
+  exists: boolean
+  conflict: boolean
+}
+
+export const scanMcpImports = async (): Promise<McpImportCandidate[]> => {
+  const response = await Call.ByName('codeswitch/services.ImportService.ScanMCPImports')
+  return (response as McpImportCandidate[]) ?? []
+}
+
+export const importMcpFromConfigs = async (names: string[]): Promise<number> => {
+  const response = await Call.ByName('codeswitch/services.ImportService.ImportMCPServersFromConfigs', names)
+  return (response as number) ?? 0
+}
@@ -104,3 +104,40 @@ export const fetchHeatmapStats = async (days: number): Promise<HeatmapStat[]> =>
   const range = Number.isFinite(days) && days > 0 ? Math.floor(days) : 30
   return Call.ByName('codeswitch/services.LogService.HeatmapStats', range)
 }
+
+export type SkillUsageStat = {
+  skill: string
+  installed: boolean
+  invocations: number
+  input_tokens: number
+  output_tokens: number
+  cache_create_tokens: number
+  cache_read_tokens: number
+  total_cost: number
+  last_used?: string
+}
+
+export const fetchSkillUsageStats = async (days: number): Promise<SkillUsageStat[]> => {
+  const range = Number.isFinite(days) && days > 0 ? Math.floor(days) : 30
+  const response = await Call.ByName('codeswitch/services.LogService.GetSkillUsageStats', range)
+  return (response as SkillUsageStat[]) ?? []
+}
+
+export type PromptUsageStat = {
+  prompt_id: string
+  name: string
+  deployed: boolean
+  invocations: number
+  input_tokens: number
+  output_tokens: number
+  cache_create_tokens: number
+  cache_read_tokens: number
+  total_cost: number
+  last_used?: string
+}
+
+export const fetchPromptUsageStats = async (days: number): Promise<PromptUsageStat[]> => {
+  const range = Number.isFinite(days) && days > 0 ? Math.floor(days) : 30
+  const response = await Call.ByName('codeswitch/services.LogService.GetPromptUsageStats', range)
+  return (response as PromptUsageStat[]) ?? []
+}
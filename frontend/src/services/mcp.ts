@@ -1,6 +1,7 @@
-import { Call } from '@wailsio/runtime'
+import { Call, Events } from '@wailsio/runtime'
 
-export type McpPlatform = 'claude-code' | 'codex'
+// 内置平台之外还可以是自定义同步目标的 ID
+export type McpPlatform = 'claude-code' | 'codex' | 'gemini' | (string & {})
 export type McpServerType = 'stdio' | 'http'
 
 export type McpServer = {
@@ -13,11 +14,32 @@ export type McpServer = {
   website?: string
   tips?: string
   enable_platform: McpPlatform[]
+  managed?: boolean
+  scope?: McpScope
+  projects?: string[]
+  secret_env?: string[]
+  auth_token?: string
   enabled_in_claude: boolean
   enabled_in_codex: boolean
+  enabled_in_gemini: boolean
+  enabled_in?: Record<string, boolean>
   missing_placeholders: string[]
 }
 
+export type McpScope = 'global' | 'project'
+
+export type McpProject = {
+  path: string
+  name: string
+  exists: boolean
+  servers: string[]
+}
+
+export const fetchMcpProjects = async (): Promise<McpProject[]> => {
+  const response = await Call.ByName('codeswitch/services.MCPService.ListMCPProjects')
+  return (response as McpProject[]) ?? []
+}
+
 export const fetchMcpServers = async (): Promise<McpServer[]> => {
   const response = await Call.ByName('codeswitch/services.MCPService.ListServers')
   return (response as McpServer[]) ?? []
@@ -26,3 +48,267 @@ export const fetchMcpServers = async (): Promise<McpServer[]> => {
 export const saveMcpServers = async (servers: McpServer[]): Promise<void> => {
   await Call.ByName('codeswitch/services.MCPService.SaveServers', servers)
 }
+
+export type McpCatalogSecret = {
+  key: string
+  label: string
+  description?: string
+  required: boolean
+  secret: boolean
+}
+
+export type McpCatalogEntry = {
+  id: string
+  name: string
+  description: string
+  category?: string
+  website?: string
+  type: McpServerType
+  command?: string
+  args?: string[]
+  env?: Record<string, string>
+  url?: string
+  tips?: string
+  secrets?: McpCatalogSecret[]
+  installed: boolean
+}
+
+export const fetchMcpCatalog = async (): Promise<McpCatalogEntry[]> => {
+  const response = await Call.ByName('codeswitch/services.MCPService.ListMCPCatalog')
+  return (response as McpCatalogEntry[]) ?? []
+}
+
+export const refreshMcpCatalog = async (sourceUrl = ''): Promise<number> => {
+  const response = await Call.ByName('codeswitch/services.MCPService.RefreshMCPCatalog', sourceUrl)
+  return (response as number) ?? 0
+}
+
+export const installMcpFromCatalog = async (
+  id: string,
+  values: Record<string, string>,
+  platforms: McpPlatform[],
+): Promise<McpServer> => {
+  return (await Call.ByName('codeswitch/services.MCPService.InstallMCPFromCatalog', id, values, platforms)) as McpServer
+}
+
+export type McpSyncTarget = {
+  id: string
+  name: string
+  config_path: string
+  format: 'json' | 'toml'
+  servers_key: string
+  style: 'claude' | 'codex' | 'gemini'
+  built_in: boolean
+}
+
+export const fetchMcpSyncTargets = async (): Promise<McpSyncTarget[]> => {
+  const response = await Call.ByName('codeswitch/services.MCPService.ListMCPSyncTargets')
+  return (response as McpSyncTarget[]) ?? []
+}
+
+export const saveMcpSyncTarget = async (target: Partial<McpSyncTarget>): Promise<McpSyncTarget> => {
+  return (await Call.ByName('codeswitch/services.MCPService.SaveMCPSyncTarget', target)) as McpSyncTarget
+}
+
+export const deleteMcpSyncTarget = async (id: string): Promise<void> => {
+  await Call.ByName('codeswitch/services.MCPService.DeleteMCPSyncTarget', id)
+}
+
+export const syncMcpServers = async (): Promise<void> => {
+  await Call.ByName('codeswitch/services.MCPService.SyncMCPServers')
+}
+
+export type McpServerHealth = {
+  name: string
+  status: 'ok' | 'error' | 'skipped'
+  server_name?: string
+  server_version?: string
+  protocol_version?: string
+  tool_count: number
+  tools?: string[]
+  error?: string
+  latency_ms: number
+  checked_at: string
+}
+
+export const checkMcpServers = async (): Promise<McpServerHealth[]> => {
+  const response = await Call.ByName('codeswitch/services.MCPService.CheckMCPServers')
+  return (response as McpServerHealth[]) ?? []
+}
+
+export const checkMcpServer = async (name: string): Promise<McpServerHealth> => {
+  return (await Call.ByName('codeswitch/services.MCPService.CheckMCPServer', name)) as McpServerHealth
+}
+
+export type McpProcessState = 'stopped' | 'running' | 'backoff' | 'failed'
+
+export type McpProcessStatus = {
+  name: string
+  state: McpProcessState
+  pid?: number
+  started_at?: string
+  restarts: number
+  next_retry?: string
+  last_error?: string
+}
+
+export type McpLogEntry = {
+  id: number
+  server: string
+  stream: 'stderr' | 'event'
+  line: string
+  created_at: string
+}
+
+export const fetchMcpProcesses = async (): Promise<McpProcessStatus[]> => {
+  const response = await Call.ByName('codeswitch/services.MCPService.ListMCPProcesses')
+  return (response as McpProcessStatus[]) ?? []
+}
+
+export const startMcpProcess = async (name: string): Promise<McpProcessStatus> => {
+  return (await Call.ByName('codeswitch/services.MCPService.StartMCPProcess', name)) as McpProcessStatus
+}
+
+export const stopMcpProcess = async (name: string): Promise<McpProcessStatus> => {
+  return (await Call.ByName('codeswitch/services.MCPService.StopMCPProcess', name)) as McpProcessStatus
+}
+
+export const restartMcpProcess = async (name: string): Promise<McpProcessStatus> => {
+  return (await Call.ByName('codeswitch/services.MCPService.RestartMCPProcess', name)) as McpProcessStatus
+}
+
+export const fetchMcpLogs = async (server: string, limit = 200): Promise<McpLogEntry[]> => {
+  const response = await Call.ByName('codeswitch/services.LogService.ListMCPLogs', server, limit)
+  return (response as McpLogEntry[]) ?? []
+}
+
+export type McpTrafficEntry = {
+  id: number
+  server: string
+  direction: 'request' | 'notification' | 'server'
+  method: string
+  tool?: string
+  params?: string
+  result?: string
+  error?: string
+  duration_ms: number
+  created_at: string
+}
+
+export const fetchMcpTraffic = async (server: string, errorsOnly = false, limit = 200): Promise<McpTrafficEntry[]> => {
+  const response = await Call.ByName('codeswitch/services.LogService.ListMCPTraffic', server, errorsOnly, limit)
+  return (response as McpTrafficEntry[]) ?? []
+}
+
+export const clearMcpTraffic = async (server: string): Promise<void> => {
+  await Call.ByName('codeswitch/services.LogService.ClearMCPTraffic', server)
+}
+
+export const onMcpTraffic = (handler: (entry: McpTrafficEntry) => void): (() => void) =>
+  Events.On('mcp:traffic', (event: { data: McpTrafficEntry }) => handler(event.data))
+
+export const onMcpProcess = (handler: (status: McpProcessStatus) => void): (() => void) =>
+  Events.On('mcp:process', (event: { data: McpProcessStatus }) => handler(event.data))
+
+export type McpVersionInfo = {
+  server: string
+  registry: 'npm' | 'pypi'
+  package: string
+  current?: string
+  pinned: boolean
+  latest?: string
+  update_available: boolean
+  error?: string
+  checked_at: string
+}
+
+export const fetchMcpServerVersions = async (): Promise<McpVersionInfo[]> => {
+  const response = await Call.ByName('codeswitch/services.MCPService.ListMCPServerVersions')
+  return (response as McpVersionInfo[]) ?? []
+}
+
+export const checkMcpServerVersions = async (): Promise<McpVersionInfo[]> => {
+  const response = await Call.ByName('codeswitch/services.MCPService.CheckMCPServerVersions')
+  return (response as McpVersionInfo[]) ?? []
+}
+
+export const updateMcpServerVersions = async (names: string[]): Promise<McpVersionInfo[]> => {
+  const response = await Call.ByName('codeswitch/services.MCPService.UpdateMCPServerVersions', names)
+  return (response as McpVersionInfo[]) ?? []
+}
+
+export const onMcpVersions = (handler: (updates: McpVersionInfo[]) => void): (() => void) =>
+  Events.On('mcp:versions', (event: { data: McpVersionInfo[] }) => handler(event.data))
+
+export type McpBundleExport = {
+  path: string
+  count: number
+}
+
+export type McpBundleCandidate = {
+  server: McpServer
+  secrets?: string[]
+  auth_token: boolean
+  exists: boolean
+}
+
+export const exportMcpBundle = async (names: string[] = [], outputDir = ''): Promise<McpBundleExport> => {
+  return (await Call.ByName('codeswitch/services.MCPService.ExportMCPBundle', names, outputDir)) as McpBundleExport
+}
+
+export const previewMcpBundle = async (path: string): Promise<McpBundleCandidate[]> => {
+  const response = await Call.ByName('codeswitch/services.MCPService.PreviewMCPBundle', path)
+  return (response as McpBundleCandidate[]) ?? []
+}
+
+export const importMcpBundle = async (
+  path: string,
+  names: string[],
+  secrets: Record<string, Record<string, string>>,
+): Promise<McpServer[]> => {
+  const response = await Call.ByName('codeswitch/services.MCPService.ImportMCPBundle', path, names, secrets)
+  return (response as McpServer[]) ?? []
+}
+
+export type McpGatewayEndpoint = {
+  name: string
+  url: string
+  sse_url: string
+}
+
+export type McpGatewayInfo = {
+  enabled: boolean
+  token: string
+  base_url: string
+  endpoints: McpGatewayEndpoint[]
+}
+
+export const fetchMcpGateway = async (): Promise<McpGatewayInfo> => {
+  return (await Call.ByName('codeswitch/services.MCPService.GetMCPGateway')) as McpGatewayInfo
+}
+
+export const setMcpGatewayEnabled = async (enabled: boolean): Promise<McpGatewayInfo> => {
+  return (await Call.ByName('codeswitch/services.MCPService.SetMCPGatewayEnabled', enabled)) as McpGatewayInfo
+}
+
+export const regenerateMcpGatewayToken = async (): Promise<McpGatewayInfo> => {
+  return (await Call.ByName('codeswitch/services.MCPService.RegenerateMCPGatewayToken')) as McpGatewayInfo
+}
+
+export type McpPolicyAction = 'allow' | 'deny'
+
+export type McpToolPolicy = {
+  server: string
+  tool: string
+  action: McpPolicyAction
+}
+
+export const fetchMcpToolPolicies = async (): Promise<McpToolPolicy[]> => {
+  const response = await Call.ByName('codeswitch/services.MCPService.ListMCPToolPolicies')
+  return (response as McpToolPolicy[]) ?? []
+}
+
+export const saveMcpToolPolicies = async (policies: McpToolPolicy[]): Promise<McpToolPolicy[]> => {
+  const response = await Call.ByName('codeswitch/services.MCPService.SaveMCPToolPolicies', policies)
+  return (response as McpToolPolicy[]) ?? []
+}
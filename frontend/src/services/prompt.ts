@@ -0,0 +1,152 @@
+import { Call, Events } from '@wailsio/runtime'
+import type { SkillRepoProvider } from './skill'
+
+export type PromptVariable = {
+  name: string
+  default?: string
+  description?: string
+}
+
+export type PromptUpstream = {
+  name: string
+  description: string
+  content: string
+  hash: string
+}
+
+export type Prompt = {
+  id: string
+  name: string
+  description?: string
+  content: string
+  variables: PromptVariable[]
+  deploy: string[]
+  deployed?: Record<string, string>
+  source?: string
+  source_hash?: string
+  upstream?: PromptUpstream | null
+  created_at?: string
+  updated_at?: string
+}
+
+export type PromptRender = {
+  content: string
+  missing: string[]
+}
+
+export type PromptRevision = {
+  id: number
+  prompt_id: string
+  name: string
+  description: string
+  content: string
+  variables: PromptVariable[]
+  created_at: string
+}
+
+export type PromptDiffLine = {
+  type: 'equal' | 'add' | 'remove'
+  text: string
+}
+
+export type PromptDiff = {
+  lines: PromptDiffLine[]
+  added: number
+  removed: number
+}
+
+export const fetchPrompts = async (): Promise<Prompt[]> => {
+  const response = await Call.ByName('codeswitch/services.PromptService.ListPrompts')
+  return (response as Prompt[]) ?? []
+}
+
+export const savePrompt = async (prompt: Prompt): Promise<Prompt> => {
+  return (await Call.ByName('codeswitch/services.PromptService.SavePrompt', prompt)) as Prompt
+}
+
+export const setPromptDeploy = async (id: string, targets: string[]): Promise<Prompt> => {
+  return (await Call.ByName('codeswitch/services.PromptService.SetPromptDeploy', id, targets)) as Prompt
+}
+
+export const deletePrompt = async (id: string): Promise<void> => {
+  await Call.ByName('codeswitch/services.PromptService.DeletePrompt', id)
+}
+
+export const renderPrompt = async (id: string, values: Record<string, string>): Promise<PromptRender> => {
+  return (await Call.ByName('codeswitch/services.PromptService.RenderPrompt', id, values)) as PromptRender
+}
+
+export const renderPromptTemplate = async (
+  content: string,
+  variables: PromptVariable[],
+  values: Record<string, string>,
+): Promise<PromptRender> => {
+  return (await Call.ByName('codeswitch/services.PromptService.RenderPromptTemplate', content, variables, values)) as PromptRender
+}
+
+export const parsePromptVariables = async (content: string): Promise<PromptVariable[]> => {
+  const response = await Call.ByName('codeswitch/services.PromptService.ParsePromptVariables', content)
+  return (response as PromptVariable[]) ?? []
+}
+
+export const fetchPromptRevisions = async (id: string, limit = 0): Promise<PromptRevision[]> => {
+  const response = await Call.ByName('codeswitch/services.PromptService.ListPromptRevisions', id, limit)
+  return (response as PromptRevision[]) ?? []
+}
+
+export const diffPromptRevisions = async (id: string, fromRevision: number, toRevision = 0): Promise<PromptDiff> => {
+  return (await Call.ByName('codeswitch/services.PromptService.DiffPromptRevisions', id, fromRevision, toRevision)) as PromptDiff
+}
+
+export const revertPrompt = async (id: string, revisionId: number): Promise<Prompt> => {
+  return (await Call.ByName('codeswitch/services.PromptService.RevertPrompt', id, revisionId)) as Prompt
+}
+
+export type PromptRepoConfig = {
+  owner: string
+  name: string
+  branch: string
+  enabled: boolean
+  provider?: SkillRepoProvider
+  base_url?: string
+  token?: string
+  proxy?: string
+  directory?: string
+  interval_hours: number
+}
+
+export type PromptSyncResult = {
+  branch: string
+  added: string[]
+  updated: string[]
+  removed: string[]
+  detached: string[]
+  conflicts: string[]
+  skipped: string[]
+  error?: string
+  synced_at: string
+}
+
+export type PromptRepoState = {
+  repo: PromptRepoConfig
+  last_sync: PromptSyncResult | null
+}
+
+export const fetchPromptRepo = async (): Promise<PromptRepoState> => {
+  return (await Call.ByName('codeswitch/services.PromptService.GetPromptRepo')) as PromptRepoState
+}
+
+export const savePromptRepo = async (repo: PromptRepoConfig): Promise<PromptRepoConfig> => {
+  return (await Call.ByName('codeswitch/services.PromptService.SetPromptRepo', repo)) as PromptRepoConfig
+}
+
+export const syncPromptRepo = async (): Promise<PromptSyncResult> => {
+  return (await Call.ByName('codeswitch/services.PromptService.SyncPromptRepo')) as PromptSyncResult
+}
+
+export const resolvePromptConflict = async (id: string, useRemote: boolean): Promise<Prompt> => {
+  return (await Call.ByName('codeswitch/services.PromptService.ResolvePromptConflict', id, useRemote)) as Prompt
+}
+
+export const onPromptSync = (handler: (result: PromptSyncResult) => void): (() => void) =>
+  Events.On('prompt:sync', (event: { data: PromptSyncResult }) => handler(event.data))
@@ -0,0 +1,26 @@
+import { Call } from '@wailsio/runtime'
+
+export type ProxyEnvFormat = 'sh' | 'fish' | 'powershell' | 'launchd'
+export type ProxyEnvPlatform = 'claude' | 'codex' | 'gemini'
+
+export type ProxyEnvVar = {
+  platform: ProxyEnvPlatform
+  key: string
+  value: string
+}
+
+export type ProxyEnvSnippet = {
+  format: ProxyEnvFormat
+  content: string
+  target: string
+  hint: string
+  vars: ProxyEnvVar[]
+}
+
+export const fetchProxyEnvSnippet = async (format: ProxyEnvFormat, platforms: ProxyEnvPlatform[] = []): Promise<ProxyEnvSnippet> => {
+  return (await Call.ByName('codeswitch/services.ProxyEnvService.ProxyEnvSnippet', format, platforms)) as ProxyEnvSnippet
+}
+
+export const launchProxyTerminal = async (dir = '', platforms: ProxyEnvPlatform[] = []): Promise<void> => {
+  await Call.ByName('codeswitch/services.ProxyEnvService.LaunchProxyTerminal', dir, platforms)
+}
@@ -1,10 +1,12 @@
-import { Call } from '@wailsio/runtime'
+import { Call, Events } from '@wailsio/runtime'
 
 export type SkillSummary = {
   key: string
   name: string
   description: string
+  tags?: string[]
   directory: string
+  path?: string
   readme_url: string
   installed: boolean
   repo_owner?: string
@@ -12,18 +14,49 @@ export type SkillSummary = {
   repo_branch?: string
 }
 
+export type SkillRepoProvider = 'github' | 'gitlab' | 'gitea'
+
 export type SkillRepoConfig = {
   owner: string
   name: string
   branch: string
   enabled: boolean
+  provider?: SkillRepoProvider
+  base_url?: string
+  token?: string
+  proxy?: string
 }
 
 export type InstallSkillPayload = {
   directory: string
+  path?: string
   repo_owner?: string
   repo_name?: string
   repo_branch?: string
+  force?: boolean
+  install_dependencies?: boolean
+}
+
+export type SkillLintIssue = {
+  level: 'error' | 'warning'
+  code: string
+  message: string
+  file?: string
+  line?: number
+}
+
+export type SkillDependency = {
+  kind: 'skill' | 'binary' | 'mcp'
+  name: string
+  satisfied: boolean
+  installable: boolean
+  hint?: string
+}
+
+export type SkillInstallResult = {
+  installed: boolean
+  issues: SkillLintIssue[]
+  dependencies?: SkillDependency[]
 }
 
 export const fetchSkills = async (): Promise<SkillSummary[]> => {
@@ -31,8 +64,173 @@ export const fetchSkills = async (): Promise<SkillSummary[]> => {
   return (response as SkillSummary[]) ?? []
 }
 
-export const installSkill = async (payload: InstallSkillPayload): Promise<void> => {
-  await Call.ByName('codeswitch/services.SkillService.InstallSkill', payload)
+export const searchSkills = async (query: string): Promise<SkillSummary[]> => {
+  const response = await Call.ByName('codeswitch/services.SkillService.SearchSkills', query)
+  return (response as SkillSummary[]) ?? []
+}
+
+export const refreshSkillIndex = async (): Promise<SkillSummary[]> => {
+  const response = await Call.ByName('codeswitch/services.SkillService.RefreshSkillIndex')
+  return (response as SkillSummary[]) ?? []
+}
+
+export type SkillTemplate = {
+  id: string
+  name: string
+  description: string
+}
+
+export type CreateSkillPayload = {
+  name: string
+  description: string
+  tags?: string[]
+  template?: string
+  platform?: SkillPlatform
+  location?: 'user' | 'project' | 'custom'
+  project_dir?: string
+}
+
+export type CreateSkillResult = {
+  skill: SkillSummary
+  dir: string
+  files: string[]
+}
+
+export const fetchSkillTemplates = async (): Promise<SkillTemplate[]> => {
+  const response = await Call.ByName('codeswitch/services.SkillService.ListSkillTemplates')
+  return (response as SkillTemplate[]) ?? []
+}
+
+export const createSkill = async (payload: CreateSkillPayload): Promise<CreateSkillResult> => {
+  const response = await Call.ByName('codeswitch/services.SkillService.CreateSkill', payload)
+  return response as CreateSkillResult
+}
+
+export type SkillBundle = {
+  path: string
+  directory: string
+  sha256: string
+  size: number
+}
+
+export const exportSkill = async (directory: string, outputDir = ''): Promise<SkillBundle> => {
+  const response = await Call.ByName('codeswitch/services.SkillService.ExportSkill', directory, outputDir)
+  return response as SkillBundle
+}
+
+export const exportSkillLink = async (directory: string): Promise<string> => {
+  const response = await Call.ByName('codeswitch/services.SkillService.ExportSkillLink', directory)
+  return (response as string) ?? ''
+}
+
+export const importSkillFromFile = async (path: string, sha256 = ''): Promise<SkillSummary> => {
+  const response = await Call.ByName('codeswitch/services.SkillService.ImportSkillFromFile', path, sha256)
+  return response as SkillSummary
+}
+
+export const importSkillFromURL = async (url: string, sha256 = ''): Promise<SkillSummary> => {
+  const response = await Call.ByName('codeswitch/services.SkillService.ImportSkillFromURL', url, sha256)
+  return response as SkillSummary
+}
+
+export type SkillSyncStatus = {
+  directory: string
+  claude: boolean
+  codex: boolean
+  in_sync: boolean
+  conflict: boolean
+  synced_at: string
+}
+
+export const syncSkill = async (directory: string, from: '' | 'claude' | 'codex' = ''): Promise<SkillSyncStatus> => {
+  const response = await Call.ByName('codeswitch/services.SkillService.SyncSkill', directory, from)
+  return response as SkillSyncStatus
+}
+
+export const unsyncSkill = async (directory: string): Promise<void> => {
+  await Call.ByName('codeswitch/services.SkillService.UnsyncSkill', directory)
+}
+
+export const fetchSkillSync = async (): Promise<SkillSyncStatus[]> => {
+  const response = await Call.ByName('codeswitch/services.SkillService.ListSkillSync')
+  return (response as SkillSyncStatus[]) ?? []
+}
+
+export type SkillPlatform = 'claude' | 'codex' | 'gemini'
+
+export type SkillProject = {
+  path: string
+  name: string
+  source: 'manual' | 'discovered'
+  exists: boolean
+  last_used?: string
+}
+
+export const fetchSkillProjects = async (): Promise<SkillProject[]> => {
+  const response = await Call.ByName('codeswitch/services.SkillService.ListSkillProjects')
+  return (response as SkillProject[]) ?? []
+}
+
+export const addSkillProject = async (path: string): Promise<SkillProject[]> => {
+  const response = await Call.ByName('codeswitch/services.SkillService.AddSkillProject', path)
+  return (response as SkillProject[]) ?? []
+}
+
+export const removeSkillProject = async (path: string): Promise<SkillProject[]> => {
+  const response = await Call.ByName('codeswitch/services.SkillService.RemoveSkillProject', path)
+  return (response as SkillProject[]) ?? []
+}
+
+export const fetchProjectSkills = async (projectPath: string, platform: SkillPlatform): Promise<SkillSummary[]> => {
+  const response = await Call.ByName('codeswitch/services.SkillService.ListProjectSkills', projectPath, platform)
+  return (response as SkillSummary[]) ?? []
+}
+
+export const fetchInstalledSkills = async (target: SkillTarget): Promise<SkillSummary[]> => {
+  const response = await Call.ByName('codeswitch/services.SkillService.ListInstalledSkills', target)
+  return (response as SkillSummary[]) ?? []
+}
+
+export const installProjectSkill = async (
+  projectPath: string,
+  platform: SkillPlatform,
+  payload: InstallSkillPayload
+): Promise<SkillInstallResult> => {
+  const response = await Call.ByName('codeswitch/services.SkillService.InstallProjectSkill', projectPath, platform, payload)
+  return (response as SkillInstallResult) ?? { installed: false, issues: [] }
+}
+
+export const copySkillToProject = async (directory: string, projectPath: string, platform: SkillPlatform): Promise<void> => {
+  await Call.ByName('codeswitch/services.SkillService.CopySkillToProject', directory, projectPath, platform)
+}
+
+export const uninstallProjectSkill = async (projectPath: string, platform: SkillPlatform, directory: string): Promise<void> => {
+  await Call.ByName('codeswitch/services.SkillService.UninstallProjectSkill', projectPath, platform, directory)
+}
+
+export const installSkill = async (payload: InstallSkillPayload): Promise<SkillInstallResult> => {
+  const response = await Call.ByName('codeswitch/services.SkillService.InstallSkill', payload)
+  return (response as SkillInstallResult) ?? { installed: false, issues: [] }
+}
+
+export const checkSkillDependencies = async (directory: string): Promise<SkillDependency[]> => {
+  const response = await Call.ByName('codeswitch/services.SkillService.CheckSkillDependencies', directory)
+  return (response as SkillDependency[]) ?? []
+}
+
+export const installSkillDependencies = async (directory: string): Promise<SkillDependency[]> => {
+  const response = await Call.ByName('codeswitch/services.SkillService.InstallSkillDependencies', directory)
+  return (response as SkillDependency[]) ?? []
+}
+
+export const lintSkill = async (payload: InstallSkillPayload): Promise<SkillLintIssue[]> => {
+  const response = await Call.ByName('codeswitch/services.SkillService.LintSkill', payload)
+  return (response as SkillLintIssue[]) ?? []
+}
+
+export const lintLocalSkill = async (dir: string): Promise<SkillLintIssue[]> => {
+  const response = await Call.ByName('codeswitch/services.SkillService.LintLocalSkill', dir)
+  return (response as SkillLintIssue[]) ?? []
 }
 
 export const uninstallSkill = async (directory: string): Promise<void> => {
@@ -49,7 +247,11 @@ export const addSkillRepo = async (repo: Partial<SkillRepoConfig>): Promise<Skil
     owner: repo.owner ?? '',
     name: repo.name ?? '',
     branch: repo.branch ?? 'main',
-    enabled: repo.enabled ?? true
+    enabled: repo.enabled ?? true,
+    provider: repo.provider ?? 'github',
+    base_url: repo.base_url ?? '',
+    token: repo.token ?? '',
+    proxy: repo.proxy ?? ''
   }
   const response = await Call.ByName('codeswitch/services.SkillService.AddRepo', payload)
   return (response as SkillRepoConfig[]) ?? []
@@ -59,3 +261,103 @@ export const removeSkillRepo = async (owner: string, name: string): Promise<Skil
   const response = await Call.ByName('codeswitch/services.SkillService.RemoveRepo', owner, name)
   return (response as SkillRepoConfig[]) ?? []
 }
+
+export type SkillTarget = {
+  platform?: SkillPlatform
+  location?: 'user' | 'project' | 'custom'
+  project_dir?: string
+}
+
+export type SkillProfile = {
+  name: string
+  description?: string
+  skills: InstallSkillPayload[]
+  updated_at?: string
+}
+
+export type SkillBulkStatus = 'running' | 'installed' | 'removed' | 'blocked' | 'failed'
+
+export type SkillBulkItem = {
+  directory: string
+  status: SkillBulkStatus
+  error?: string
+  issues?: SkillLintIssue[]
+}
+
+export type SkillBulkResult = {
+  operation: 'install' | 'uninstall'
+  profile?: string
+  succeeded: number
+  failed: number
+  items: SkillBulkItem[]
+}
+
+export type SkillBulkProgress = {
+  operation: 'install' | 'uninstall'
+  profile?: string
+  directory: string
+  index: number
+  total: number
+  status: SkillBulkStatus
+  error?: string
+}
+
+export const fetchSkillProfiles = async (): Promise<SkillProfile[]> => {
+  const response = await Call.ByName('codeswitch/services.SkillService.ListSkillProfiles')
+  return (response as SkillProfile[]) ?? []
+}
+
+export const saveSkillProfile = async (profile: SkillProfile): Promise<SkillProfile[]> => {
+  const response = await Call.ByName('codeswitch/services.SkillService.SaveSkillProfile', profile)
+  return (response as SkillProfile[]) ?? []
+}
+
+export const deleteSkillProfile = async (name: string): Promise<SkillProfile[]> => {
+  const response = await Call.ByName('codeswitch/services.SkillService.DeleteSkillProfile', name)
+  return (response as SkillProfile[]) ?? []
+}
+
+export const applySkillProfile = async (name: string, target: SkillTarget, force = false): Promise<SkillBulkResult> => {
+  const response = await Call.ByName('codeswitch/services.SkillService.ApplySkillProfile', name, target, force)
+  return response as SkillBulkResult
+}
+
+export const unapplySkillProfile = async (name: string, target: SkillTarget): Promise<SkillBulkResult> => {
+  const response = await Call.ByName('codeswitch/services.SkillService.UnapplySkillProfile', name, target)
+  return response as SkillBulkResult
+}
+
+export const bulkInstallSkills = async (skills: InstallSkillPayload[], target: SkillTarget): Promise<SkillBulkResult> => {
+  const response = await Call.ByName('codeswitch/services.SkillService.BulkInstallSkills', skills, target)
+  return response as SkillBulkResult
+}
+
+export const bulkUninstallSkills = async (directories: string[], target: SkillTarget): Promise<SkillBulkResult> => {
+  const response = await Call.ByName('codeswitch/services.SkillService.BulkUninstallSkills', directories, target)
+  return response as SkillBulkResult
+}
+
+export const onSkillBulkProgress = (handler: (progress: SkillBulkProgress) => void): (() => void) =>
+  Events.On('skill:bulk-progress', (event: { data: SkillBulkProgress }) => handler(event.data))
+
+export type SkillBackup = {
+  id: string
+  directory: string
+  source: string
+  reason: 'install' | 'uninstall' | 'sync' | 'restore'
+  created_at: string
+}
+
+export const fetchSkillBackups = async (directory = ''): Promise<SkillBackup[]> => {
+  const response = await Call.ByName('codeswitch/services.SkillService.ListSkillBackups', directory)
+  return (response as SkillBackup[]) ?? []
+}
+
+export const restoreSkillBackup = async (id: string): Promise<SkillBackup> => {
+  const response = await Call.ByName('codeswitch/services.SkillService.RestoreSkillBackup', id)
+  return response as SkillBackup
+}
+
+export const deleteSkillBackup = async (id: string): Promise<void> => {
+  await Call.ByName('codeswitch/services.SkillService.DeleteSkillBackup', id)
+}
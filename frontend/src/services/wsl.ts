@@ -0,0 +1,29 @@
+import { Call } from '@wailsio/runtime'
+import type { ClaudeProxyStatus } from '../../bindings/codeswitch/services/models'
+
+export type WSLPlatform = 'claude' | 'codex'
+
+export type WSLDistro = {
+  name: string
+  default: boolean
+  state: string
+  version: number
+  home: string
+  networking: 'nat' | 'mirrored' | ''
+  base_url: string
+  claude: ClaudeProxyStatus
+  codex: ClaudeProxyStatus
+  error?: string
+}
+
+export const listWSLDistros = async (): Promise<WSLDistro[]> => {
+  return (await Call.ByName('codeswitch/services.WSLService.ListWSLDistros')) as WSLDistro[]
+}
+
+export const enableWSLProxy = async (distro: string, platforms: WSLPlatform[] = []): Promise<void> => {
+  await Call.ByName('codeswitch/services.WSLService.EnableWSLProxy', distro, platforms)
+}
+
+export const disableWSLProxy = async (distro: string, platforms: WSLPlatform[] = []): Promise<void> => {
+  await Call.ByName('codeswitch/services.WSLService.DisableWSLProxy', distro, platforms)
+}
@@ -1,12 +1,19 @@
 package main
 
 import (
+	"bytes"
 	"codeswitch/services"
 	"embed"
 	_ "embed"
 	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
 	"log"
 	"runtime"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/wailsapp/wails/v3/pkg/application"
@@ -63,28 +70,109 @@ func (a *AppService) OpenSecondWindow() {
 func main() {
 	appservice := &AppService{}
 
+	// 切换数据目录后，需在任何服务打开数据文件之前完成迁移
+	if err := services.ApplyPendingDataDirMigration(); err != nil {
+		log.Printf("data dir migration error: %v", err)
+	}
+	dataDirService := services.NewDataDirService()
+	// 校验数据库与配置文件，损坏时自动从快照恢复，避免启动失败
+	services.RunStartupSelfCheck()
+
 	suiService, errt := services.NewSuiStore()
 	if errt != nil {
 		// 处理错误，比如日志或退出
 	}
 	providerService := services.NewProviderService()
-	providerRelay := services.NewProviderRelayService(providerService, ":18100")
+	autoStartService := services.NewAutoStartService()
+	appSettings := services.NewAppSettingsService(autoStartService)
+	blacklistService := services.NewBlacklistService(appSettings)
+	providerRelay := services.NewProviderRelayService(providerService, blacklistService, ":18100")
 	claudeSettings := services.NewClaudeSettingsService(providerRelay.Addr())
 	codexSettings := services.NewCodexSettingsService(providerRelay.Addr())
+	geminiSettings := services.NewGeminiSettingsService(providerRelay.Addr())
+	proxyEnvService := services.NewProxyEnvService(providerRelay.Addr())
+	wslService := services.NewWSLService(providerRelay.Addr())
+	customCliService := services.NewCustomCliService(providerRelay.Addr())
+	providerRelay.SetCustomCliService(customCliService)
 	logService := services.NewLogService()
-	autoStartService := services.NewAutoStartService()
-	appSettings := services.NewAppSettingsService(autoStartService)
 	mcpService := services.NewMCPService()
+	providerRelay.SetMCPService(mcpService)
 	skillService := services.NewSkillService()
+	promptService := services.NewPromptService()
+	agentService := services.NewAgentService()
 	importService := services.NewImportService(providerService, mcpService)
+	speedTestService := services.NewSpeedTestService(providerService)
+	demoService := services.NewDemoService(appSettings)
+	balanceService := services.NewBalanceService(providerService)
+	subscriptionService := services.NewSubscriptionService()
+	connectivityService := services.NewConnectivityTestService(providerService, providerRelay.Addr())
+	healthCheckService := services.NewHealthCheckService(providerService, connectivityService, blacklistService, appSettings, logService)
+	degradedService := services.NewDegradedService(appSettings)
+	statusPageService := services.NewStatusPageService(providerService)
+	bodyLogService := services.NewBodyLogService(appSettings)
+	pricingService := services.NewPricingService(logService)
+	currencyService := services.NewCurrencyService(appSettings)
+	budgetService := services.NewBudgetService(appSettings, logService)
+	tracingService := services.NewTracingService(appSettings)
+	usageWebhookService := services.NewUsageWebhookService(appSettings, logService)
+	logMaintenanceService := services.NewLogMaintenanceService(appSettings)
+	notificationService := services.NewNotificationService(appSettings, blacklistService, logService)
+	updateService := services.NewUpdateService(appSettings, AppVersion)
+	profileService := services.NewProfileService(providerService, claudeSettings, codexSettings)
+	selfCheckService := services.NewSelfCheckService(claudeSettings, codexSettings)
+	configDoctorService := services.NewConfigDoctorService(claudeSettings, codexSettings, geminiSettings)
+	configWatchService := services.NewConfigWatchService(appSettings, claudeSettings, codexSettings, customCliService)
+	backupService := services.NewBackupService(appSettings)
 	dockService := dock.New()
 	versionService := NewVersionService()
 
+	if err := backupService.Start(); err != nil {
+		log.Printf("backup service start error: %v", err)
+	}
 	go func() {
 		if err := providerRelay.Start(); err != nil {
 			log.Printf("provider relay start error: %v", err)
 		}
 	}()
+	if err := speedTestService.Start(); err != nil {
+		log.Printf("speed test service start error: %v", err)
+	}
+	if err := subscriptionService.Start(); err != nil {
+		log.Printf("subscription service start error: %v", err)
+	}
+	if err := healthCheckService.Start(); err != nil {
+		log.Printf("health check service start error: %v", err)
+	}
+	if err := degradedService.Start(); err != nil {
+		log.Printf("degraded service start error: %v", err)
+	}
+	if err := bodyLogService.Start(); err != nil {
+		log.Printf("body log service start error: %v", err)
+	}
+	if err := currencyService.Start(); err != nil {
+		log.Printf("currency service start error: %v", err)
+	}
+	if err := logService.Start(); err != nil {
+		log.Printf("log service start error: %v", err)
+	}
+	if err := tracingService.Start(); err != nil {
+		log.Printf("tracing service start error: %v", err)
+	}
+	if err := usageWebhookService.Start(); err != nil {
+		log.Printf("usage webhook service start error: %v", err)
+	}
+	if err := logMaintenanceService.Start(); err != nil {
+		log.Printf("log maintenance service start error: %v", err)
+	}
+	if err := notificationService.Start(); err != nil {
+		log.Printf("notification service start error: %v", err)
+	}
+	if err := updateService.Start(); err != nil {
+		log.Printf("update service start error: %v", err)
+	}
+	if err := skillService.Start(); err != nil {
+		log.Printf("skill sync start error: %v", err)
+	}
 
 	//fmt.Println(clipboardService)
 	// Create a new Wails application by providing the necessary options.
@@ -101,11 +189,41 @@ func main() {
 			application.NewService(providerService),
 			application.NewService(claudeSettings),
 			application.NewService(codexSettings),
+			application.NewService(geminiSettings),
+			application.NewService(proxyEnvService),
+			application.NewService(wslService),
+			application.NewService(customCliService),
 			application.NewService(logService),
 			application.NewService(appSettings),
 			application.NewService(mcpService),
 			application.NewService(skillService),
+			application.NewService(promptService),
+			application.NewService(agentService),
 			application.NewService(importService),
+			application.NewService(speedTestService),
+			application.NewService(demoService),
+			application.NewService(balanceService),
+			application.NewService(subscriptionService),
+			application.NewService(connectivityService),
+			application.NewService(blacklistService),
+			application.NewService(healthCheckService),
+			application.NewService(degradedService),
+			application.NewService(statusPageService),
+			application.NewService(bodyLogService),
+			application.NewService(pricingService),
+			application.NewService(currencyService),
+			application.NewService(budgetService),
+			application.NewService(tracingService),
+			application.NewService(usageWebhookService),
+			application.NewService(logMaintenanceService),
+			application.NewService(notificationService),
+			application.NewService(updateService),
+			application.NewService(dataDirService),
+			application.NewService(profileService),
+			application.NewService(selfCheckService),
+			application.NewService(configDoctorService),
+			application.NewService(configWatchService),
+			application.NewService(backupService),
 			application.NewService(dockService),
 			application.NewService(versionService),
 		},
@@ -119,8 +237,92 @@ func main() {
 
 	app.OnShutdown(func() {
 		_ = providerRelay.Stop()
+		_ = speedTestService.Stop()
+		_ = balanceService.Stop()
+		_ = subscriptionService.Stop()
+		_ = healthCheckService.Stop()
+		_ = degradedService.Stop()
+		_ = statusPageService.Stop()
+		_ = bodyLogService.Stop()
+		_ = currencyService.Stop()
+		_ = budgetService.Stop()
+		_ = tracingService.Stop()
+		_ = usageWebhookService.Stop()
+		_ = logMaintenanceService.Stop()
+		_ = notificationService.Stop()
+		_ = updateService.Stop()
+		_ = skillService.Stop()
+		_ = configWatchService.Stop()
+		_ = backupService.Stop()
+		_ = mcpService.Stop()
+		_ = promptService.Stop()
+		_ = logService.Stop()
 	})
 
+	balanceService.SetAlertHandler(func(balance services.ProviderBalance) {
+		app.Event.Emit("provider:balance-low", balance)
+	})
+	if err := balanceService.Start(); err != nil {
+		log.Printf("balance service start error: %v", err)
+	}
+	logService.SetStreamHandler(func(batch services.LogStreamBatch) {
+		app.Event.Emit("logs:batch", batch)
+	})
+	configWatchService.SetDriftHandler(func(drift services.ConfigDrift) {
+		app.Event.Emit("config:drift", drift)
+	})
+	if err := configWatchService.Start(); err != nil {
+		log.Printf("config watch service start error: %v", err)
+	}
+	blacklistService.SetRecoveryHandler(func(recovery services.BlacklistRecovery) {
+		app.Event.Emit("provider:recovered", recovery)
+		notificationService.NotifyRecovery(recovery)
+	})
+	updateService.SetStateHandler(func(state services.UpdateState) {
+		app.Event.Emit("update:state", state)
+		if state.Status == services.UpdateStatusAvailable {
+			notificationService.NotifyUpdate(state)
+		}
+	})
+	budgetService.SetAlertHandler(func(alert services.BudgetAlert) {
+		app.Event.Emit("budget:alert", alert)
+		notificationService.NotifyBudgetAlert(alert)
+	})
+	skillService.SetBulkProgressHandler(func(progress services.SkillBulkProgress) {
+		app.Event.Emit("skill:bulk-progress", progress)
+	})
+	mcpService.SetMCPProcessHandler(func(status services.MCPProcessStatus) {
+		app.Event.Emit("mcp:process", status)
+	})
+	mcpService.SetMCPTrafficHandler(func(entry services.MCPTrafficEntry) {
+		app.Event.Emit("mcp:traffic", entry)
+	})
+	mcpService.SetMCPVersionHandler(func(updates []services.MCPVersionInfo) {
+		app.Event.Emit("mcp:versions", updates)
+	})
+	if err := mcpService.Start(); err != nil {
+		log.Printf("mcp service start error: %v", err)
+	}
+	promptService.SetPromptSyncHandler(func(result services.PromptSyncResult) {
+		app.Event.Emit("prompt:sync", result)
+	})
+	if err := promptService.Start(); err != nil {
+		log.Printf("prompt sync start error: %v", err)
+	}
+	profileService.SetSwitchHandler(func(profile services.Profile) {
+		budgetService.ReloadBudget()
+		app.Event.Emit("profile:switched", profile)
+	})
+	if err := budgetService.Start(); err != nil {
+		log.Printf("budget service start error: %v", err)
+	}
+	statusPageService.SetIncidentHandler(func(incident services.StatusIncident) {
+		app.Event.Emit("provider:incident", incident)
+	})
+	if err := statusPageService.Start(); err != nil {
+		log.Printf("status page service start error: %v", err)
+	}
+
 	// Create a new window with the necessary options.
 	// 'Title' is the title of the window.
 	// 'Mac' options tailor the window when running on macOS.
@@ -190,10 +392,13 @@ func main() {
 	systray := app.SystemTray.New()
 	// systray.SetLabel("Code Switch")
 	systray.SetTooltip("Code Switch")
-	if lightIcon := loadTrayIcon("assets/icon.png"); len(lightIcon) > 0 {
+	lightIcon := loadTrayIcon("assets/icon.png")
+	darkIcon := loadTrayIcon("assets/icon-dark.png")
+	alertIcon := alertTrayIcon(lightIcon)
+	if len(lightIcon) > 0 {
 		systray.SetIcon(lightIcon)
 	}
-	if darkIcon := loadTrayIcon("assets/icon-dark.png"); len(darkIcon) > 0 {
+	if len(darkIcon) > 0 {
 		systray.SetDarkModeIcon(darkIcon)
 	}
 
@@ -218,6 +423,53 @@ func main() {
 
 	appservice.SetApp(app)
 
+	// 托盘标签展示今日费用，按所选币种换算；预计超出预算时在提示中告警，平台全部 provider 不可用时切换为告警图标
+	var trayMu sync.Mutex
+	trayAlerting := false
+	refreshTray := func() {
+		trayMu.Lock()
+		defer trayMu.Unlock()
+		outages := providerRelay.ListOutages()
+		label := logService.TrayUsageLabel()
+		tooltip := "Code Switch"
+		if len(outages) > 0 {
+			platforms := make([]string, 0, len(outages))
+			for _, outage := range outages {
+				platforms = append(platforms, outage.Platform)
+			}
+			label = "⚠ " + label
+			tooltip += "\n全部 provider 不可用：" + strings.Join(platforms, "、")
+		}
+		if warning := logService.BudgetWarning(); warning != "" {
+			tooltip += "\n" + warning
+		}
+		systray.SetLabel(label)
+		systray.SetTooltip(tooltip)
+		if alerting := len(outages) > 0; alerting != trayAlerting && len(alertIcon) > 0 {
+			trayAlerting = alerting
+			if alerting {
+				systray.SetIcon(alertIcon)
+				systray.SetDarkModeIcon(alertIcon)
+			} else {
+				systray.SetIcon(lightIcon)
+				systray.SetDarkModeIcon(darkIcon)
+			}
+		}
+	}
+	providerRelay.SetOutageHandler(func(outage services.PlatformOutage) {
+		app.Event.Emit("platform:outage", outage)
+		notificationService.NotifyOutage(outage)
+		refreshTray()
+	})
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			refreshTray()
+			<-ticker.C
+		}
+	}()
+
 	// Create a goroutine that emits an event containing the current time every second.
 	// The frontend can listen to this event and update the UI accordingly.
 	go func() {
@@ -246,6 +498,37 @@ func loadTrayIcon(path string) []byte {
 	return data
 }
 
+// alertTrayIcon 在托盘图标右下角叠加红点，作为告警状态的图标
+func alertTrayIcon(base []byte) []byte {
+	if len(base) == 0 {
+		return nil
+	}
+	src, err := png.Decode(bytes.NewReader(base))
+	if err != nil {
+		log.Printf("failed to decode tray icon: %v", err)
+		return nil
+	}
+	bounds := src.Bounds()
+	img := image.NewRGBA(bounds)
+	draw.Draw(img, bounds, src, bounds.Min, draw.Src)
+	radius := bounds.Dx() / 4
+	cx, cy := bounds.Max.X-radius-1, bounds.Max.Y-radius-1
+	red := color.RGBA{R: 0xE5, G: 0x39, B: 0x35, A: 0xFF}
+	for y := cy - radius; y <= cy+radius; y++ {
+		for x := cx - radius; x <= cx+radius; x++ {
+			if dx, dy := x-cx, y-cy; dx*dx+dy*dy <= radius*radius {
+				img.Set(x, y, red)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		log.Printf("failed to encode alert tray icon: %v", err)
+		return nil
+	}
+	return buf.Bytes()
+}
+
 func handleDockVisibility(service *dock.DockService, show bool) {
 	if runtime.GOOS != "darwin" || service == nil {
 		return
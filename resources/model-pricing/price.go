@@ -83,8 +83,13 @@ func DefaultService() (*Service, error) {
 
 // NewService 从嵌入的 JSON 创建服务实例。
 func NewService() (*Service, error) {
+	return NewServiceFromJSON(pricingFile)
+}
+
+// NewServiceFromJSON 从 LiteLLM 格式的价格 JSON 创建服务实例。
+func NewServiceFromJSON(data []byte) (*Service, error) {
 	raw := make(map[string]PricingEntry)
-	if err := json.Unmarshal(pricingFile, &raw); err != nil {
+	if err := json.Unmarshal(data, &raw); err != nil {
 		return nil, fmt.Errorf("parse pricing file: %w", err)
 	}
 	pricing := make(map[string]*PricingEntry, len(raw))
@@ -142,6 +147,15 @@ func (s *Service) CalculateCost(model string, usage UsageSnapshot) CostBreakdown
 	return breakdown
 }
 
+// Entries 返回全部模型价格（按模型名索引）。
+func (s *Service) Entries() map[string]PricingEntry {
+	entries := make(map[string]PricingEntry, len(s.pricingMap))
+	for key, entry := range s.pricingMap {
+		entries[key] = *entry
+	}
+	return entries
+}
+
 func (s *Service) getPricing(model string) (*PricingEntry, bool) {
 	if model == "" {
 		return nil, false
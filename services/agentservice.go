@@ -0,0 +1,615 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	agentFileExt = ".md"
+	// agentDisabledExt 停用的 subagent 改为该后缀，Claude Code 只加载 .md 文件
+	agentDisabledExt = ".md.disabled"
+	// agentCodexPrefix Codex 没有 subagent，同步为带该前缀的自定义 prompt，通过 /agent-<name> 调用
+	agentCodexPrefix = "agent-"
+)
+
+// agentModels front matter 中 model 可选的值，空表示使用默认模型
+var agentModels = []string{"inherit", "sonnet", "opus", "haiku"}
+
+// Agent Claude Code 的一个 subagent（agents 目录下的 Markdown 文件），Prompt 为正文即 subagent 的系统提示词。
+// Tools 为空表示继承主会话的全部工具
+type Agent struct {
+	Name        string    `json:"name"`
+	FileName    string    `json:"file_name"`
+	Description string    `json:"description"`
+	Model       string    `json:"model"`
+	Tools       []string  `json:"tools"`
+	Color       string    `json:"color,omitempty"`
+	Prompt      string    `json:"prompt"`
+	Enabled     bool      `json:"enabled"`
+	Location    string    `json:"location"`
+	ProjectDir  string    `json:"project_dir,omitempty"`
+	Path        string    `json:"path"`
+	CodexSynced bool      `json:"codex_synced"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// AgentTarget subagent 所在位置：user 为 ~/.claude/agents，project 为 ProjectDir/.claude/agents
+type AgentTarget struct {
+	Location   string `json:"location"`
+	ProjectDir string `json:"project_dir"`
+}
+
+// AgentTemplate 新建 subagent 时可选的内置模板
+type AgentTemplate struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Model       string   `json:"model"`
+	Tools       []string `json:"tools"`
+	Prompt      string   `json:"prompt"`
+}
+
+// CreateAgentRequest 新建 subagent 的参数，Template 为空时只生成基本结构
+type CreateAgentRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Template    string   `json:"template"`
+	Model       string   `json:"model"`
+	Tools       []string `json:"tools"`
+	Location    string   `json:"location"`
+	ProjectDir  string   `json:"project_dir"`
+}
+
+// agentBlankPrompt 不使用模板时生成的正文骨架
+const agentBlankPrompt = `You are a specialist in <area>.
+
+When invoked:
+1. <first step>
+2. <second step>
+3. <how to report the result>`
+
+var agentTemplates = []AgentTemplate{
+	{
+		ID:          "code-reviewer",
+		Name:        "代码审查",
+		Description: "Expert code reviewer. Use proactively right after writing or modifying code.",
+		Model:       "inherit",
+		Tools:       []string{"Read", "Grep", "Glob", "Bash"},
+		Prompt: `You are a senior code reviewer ensuring high standards of code quality and security.
+
+When invoked:
+1. Run git diff to see recent changes
+2. Focus on modified files
+3. Begin the review immediately
+
+Review checklist:
+- Code is simple and readable
+- Functions and variables are well-named
+- No duplicated code
+- Proper error handling
+- No exposed secrets or API keys
+- Input validation is implemented
+- Good test coverage
+
+Provide feedback organized by priority: critical issues (must fix), warnings (should fix) and suggestions (consider improving). Include specific examples of how to fix each issue.`,
+	},
+	{
+		ID:          "debugger",
+		Name:        "调试",
+		Description: "Debugging specialist for errors, test failures and unexpected behavior. Use proactively when encountering any issues.",
+		Model:       "inherit",
+		Tools:       []string{"Read", "Edit", "Bash", "Grep", "Glob"},
+		Prompt: `You are an expert debugger specializing in root cause analysis.
+
+When invoked:
+1. Capture the error message and stack trace
+2. Identify reproduction steps
+3. Isolate the failure location
+4. Implement a minimal fix
+5. Verify that the solution works
+
+For each issue, provide the root cause explanation, the evidence supporting the diagnosis, the specific code fix and a testing approach. Focus on fixing the underlying issue, not the symptoms.`,
+	},
+	{
+		ID:          "test-runner",
+		Name:        "测试",
+		Description: "Test automation expert. Use proactively to run tests and fix failures after code changes.",
+		Model:       "haiku",
+		Tools:       []string{"Read", "Edit", "Bash", "Grep", "Glob"},
+		Prompt: `You are a test automation expert.
+
+When you see code changes, proactively run the appropriate tests. If tests fail, analyze the failures and fix them while preserving the original test intent. Report which tests ran, what failed and what you changed.`,
+	},
+	{
+		ID:          "doc-writer",
+		Name:        "文档",
+		Description: "Technical writer for READMEs, API docs and code comments. Use when documentation needs to be created or updated.",
+		Model:       "sonnet",
+		Tools:       []string{"Read", "Write", "Edit", "Grep", "Glob"},
+		Prompt: `You are a technical writer who keeps documentation accurate and concise.
+
+When invoked:
+1. Read the relevant code to understand current behavior
+2. Update or create documentation that matches the code
+3. Prefer short examples over long explanations
+4. Keep the existing tone and structure of the project's docs`,
+	},
+}
+
+// AgentService 管理用户级与项目级的 Claude Code subagent
+type AgentService struct {
+	mu sync.Mutex
+}
+
+func NewAgentService() *AgentService {
+	return &AgentService{}
+}
+
+// ListAgentTemplates 返回内置模板
+func (as *AgentService) ListAgentTemplates() []AgentTemplate {
+	return agentTemplates
+}
+
+// ListAgents 列出目录下的 subagent，包括已停用的，按名称排序
+func (as *AgentService) ListAgents(target AgentTarget) ([]Agent, error) {
+	dir, err := agentDir(target)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Agent{}, nil
+		}
+		return nil, err
+	}
+	agents := make([]Agent, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		fileName, ok := agentFileName(entry.Name())
+		if !ok {
+			continue
+		}
+		agent, err := readAgent(filepath.Join(dir, entry.Name()), fileName, target)
+		if err != nil {
+			fmt.Printf("[WARN] 读取 subagent %s 失败: %v\n", entry.Name(), err)
+			continue
+		}
+		agents = append(agents, agent)
+	}
+	sort.Slice(agents, func(i, j int) bool { return agents[i].Name < agents[j].Name })
+	return agents, nil
+}
+
+// CreateAgent 新建 subagent，可选择内置模板；同名文件已存在时返回错误
+func (as *AgentService) CreateAgent(req CreateAgentRequest) (Agent, error) {
+	agent := Agent{
+		Name:        strings.TrimSpace(req.Name),
+		Description: strings.TrimSpace(req.Description),
+		Model:       req.Model,
+		Tools:       req.Tools,
+		Enabled:     true,
+		Location:    req.Location,
+		ProjectDir:  req.ProjectDir,
+	}
+	if req.Template != "" {
+		template, ok := findAgentTemplate(req.Template)
+		if !ok {
+			return Agent{}, fmt.Errorf("未知模板: %s", req.Template)
+		}
+		if agent.Description == "" {
+			agent.Description = template.Description
+		}
+		if agent.Model == "" {
+			agent.Model = template.Model
+		}
+		if len(agent.Tools) == 0 {
+			agent.Tools = template.Tools
+		}
+		agent.Prompt = template.Prompt
+	}
+	if agent.Prompt == "" {
+		agent.Prompt = agentBlankPrompt
+	}
+
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	dir, err := agentDir(agent.target())
+	if err != nil {
+		return Agent{}, err
+	}
+	if err := validateAgent(agent); err != nil {
+		return Agent{}, err
+	}
+	if agentExists(dir, agent.Name) {
+		return Agent{}, fmt.Errorf("subagent 已存在: %s", agent.Name)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return Agent{}, err
+	}
+	path := filepath.Join(dir, agent.Name+agentFileExt)
+	if err := writeAgentFile(path, nil, agent); err != nil {
+		return Agent{}, err
+	}
+	return readAgent(path, agent.Name, agent.target())
+}
+
+// SaveAgent 修改 subagent 的 front matter 与正文，未识别的 front matter 字段原样保留；
+// 名称变化时同时重命名文件，已同步到 Codex 的副本一并更新
+func (as *AgentService) SaveAgent(agent Agent) (Agent, error) {
+	agent.Name = strings.TrimSpace(agent.Name)
+	agent.Description = strings.TrimSpace(agent.Description)
+	if err := validateAgent(agent); err != nil {
+		return Agent{}, err
+	}
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	dir, err := agentDir(agent.target())
+	if err != nil {
+		return Agent{}, err
+	}
+	current, err := locateAgent(dir, agent.FileName)
+	if err != nil {
+		return Agent{}, err
+	}
+	previous, err := readAgent(current, agent.FileName, agent.target())
+	if err != nil {
+		return Agent{}, err
+	}
+	data, err := os.ReadFile(current)
+	if err != nil {
+		return Agent{}, err
+	}
+	front, _ := splitAgentFile(string(data))
+
+	path := current
+	if agent.Name != agent.FileName {
+		if agentExists(dir, agent.Name) {
+			return Agent{}, fmt.Errorf("subagent 已存在: %s", agent.Name)
+		}
+		path = filepath.Join(dir, agent.Name+strings.TrimPrefix(filepath.Base(current), agent.FileName))
+	}
+	if err := writeAgentFile(path, front, agent); err != nil {
+		return Agent{}, err
+	}
+	if path != current {
+		if err := os.Remove(current); err != nil {
+			return Agent{}, err
+		}
+	}
+	saved, err := readAgent(path, agent.Name, agent.target())
+	if err != nil {
+		return Agent{}, err
+	}
+	if previous.CodexSynced {
+		if previous.Name != saved.Name {
+			_ = os.Remove(agentCodexPath(previous.Name))
+		}
+		if err := writeAgentCodexPrompt(saved); err != nil {
+			return saved, err
+		}
+		saved.CodexSynced = true
+	}
+	return saved, nil
+}
+
+// DeleteAgent 删除 subagent 及其 Codex 副本
+func (as *AgentService) DeleteAgent(target AgentTarget, fileName string) error {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	dir, err := agentDir(target)
+	if err != nil {
+		return err
+	}
+	path, err := locateAgent(dir, fileName)
+	if err != nil {
+		return err
+	}
+	agent, err := readAgent(path, fileName, target)
+	if err == nil && agent.CodexSynced {
+		_ = os.Remove(agentCodexPath(agent.Name))
+	}
+	return os.Remove(path)
+}
+
+// SetAgentEnabled 启用或停用 subagent，停用时文件改名为 .md.disabled，Codex 副本同时移除
+func (as *AgentService) SetAgentEnabled(target AgentTarget, fileName string, enabled bool) (Agent, error) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	dir, err := agentDir(target)
+	if err != nil {
+		return Agent{}, err
+	}
+	current, err := locateAgent(dir, fileName)
+	if err != nil {
+		return Agent{}, err
+	}
+	ext := agentDisabledExt
+	if enabled {
+		ext = agentFileExt
+	}
+	path := filepath.Join(dir, fileName+ext)
+	if path != current {
+		if err := os.Rename(current, path); err != nil {
+			return Agent{}, err
+		}
+	}
+	agent, err := readAgent(path, fileName, target)
+	if err != nil {
+		return Agent{}, err
+	}
+	if !enabled && agent.CodexSynced {
+		if err := os.Remove(agentCodexPath(agent.Name)); err != nil && !os.IsNotExist(err) {
+			return agent, err
+		}
+		agent.CodexSynced = false
+	}
+	return agent, nil
+}
+
+// SetAgentCodexSync 把 subagent 同步为 Codex 的自定义 prompt（~/.codex/prompts/agent-<name>.md）或取消同步。
+// Codex 没有独立上下文的 subagent，同步后可通过 /agent-<name> 以相同的指令执行任务
+func (as *AgentService) SetAgentCodexSync(target AgentTarget, fileName string, enabled bool) (Agent, error) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	dir, err := agentDir(target)
+	if err != nil {
+		return Agent{}, err
+	}
+	path, err := locateAgent(dir, fileName)
+	if err != nil {
+		return Agent{}, err
+	}
+	agent, err := readAgent(path, fileName, target)
+	if err != nil {
+		return Agent{}, err
+	}
+	if !enabled {
+		if err := os.Remove(agentCodexPath(agent.Name)); err != nil && !os.IsNotExist(err) {
+			return agent, err
+		}
+		agent.CodexSynced = false
+		return agent, nil
+	}
+	if !agent.Enabled {
+		return agent, errors.New("请先启用该 subagent")
+	}
+	if err := writeAgentCodexPrompt(agent); err != nil {
+		return agent, err
+	}
+	agent.CodexSynced = true
+	return agent, nil
+}
+
+func (agent Agent) target() AgentTarget {
+	return AgentTarget{Location: agent.Location, ProjectDir: agent.ProjectDir}
+}
+
+func agentDir(target AgentTarget) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(target.Location)) {
+	case "", "user":
+		return filepath.Join(userHomeDir(), claudeSettingsDir, "agents"), nil
+	case "project":
+		projectDir := strings.TrimSpace(target.ProjectDir)
+		if projectDir == "" || !filepath.IsAbs(projectDir) {
+			return "", errors.New("请选择项目目录")
+		}
+		if info, err := os.Stat(projectDir); err != nil || !info.IsDir() {
+			return "", fmt.Errorf("项目目录不存在: %s", projectDir)
+		}
+		return filepath.Join(projectDir, claudeSettingsDir, "agents"), nil
+	default:
+		return "", fmt.Errorf("不支持的位置: %s", target.Location)
+	}
+}
+
+func agentFileName(name string) (string, bool) {
+	switch {
+	case strings.HasSuffix(name, agentDisabledExt):
+		return strings.TrimSuffix(name, agentDisabledExt), true
+	case strings.HasSuffix(name, agentFileExt):
+		return strings.TrimSuffix(name, agentFileExt), true
+	default:
+		return "", false
+	}
+}
+
+func locateAgent(dir, fileName string) (string, error) {
+	if fileName == "" || fileName != filepath.Base(fileName) {
+		return "", fmt.Errorf("subagent 名称无效: %q", fileName)
+	}
+	for _, ext := range []string{agentFileExt, agentDisabledExt} {
+		path := filepath.Join(dir, fileName+ext)
+		if fileExists(path) {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("subagent 不存在: %s", fileName)
+}
+
+func agentExists(dir, name string) bool {
+	_, err := locateAgent(dir, name)
+	return err == nil
+}
+
+func findAgentTemplate(id string) (AgentTemplate, bool) {
+	for _, template := range agentTemplates {
+		if template.ID == id {
+			return template, true
+		}
+	}
+	return AgentTemplate{}, false
+}
+
+// validateAgent name 与 description 是 Claude Code 加载 subagent 的必填字段
+func validateAgent(agent Agent) error {
+	if !skillNamePattern.MatchString(agent.Name) || len(agent.Name) > skillNameMaxLen {
+		return fmt.Errorf("名称只能包含小写字母、数字与中划线: %q", agent.Name)
+	}
+	if agent.Description == "" {
+		return errors.New("描述不能为空，Claude Code 据此决定何时调用该 subagent")
+	}
+	if agent.Model != "" && !containsPlatform(agentModels, agent.Model) {
+		return fmt.Errorf("不支持的模型: %s", agent.Model)
+	}
+	return nil
+}
+
+func readAgent(path, fileName string, target AgentTarget) (Agent, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Agent{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return Agent{}, err
+	}
+	front, body := splitAgentFile(string(data))
+	var meta struct {
+		Name        string    `yaml:"name"`
+		Description string    `yaml:"description"`
+		Model       string    `yaml:"model"`
+		Tools       yaml.Node `yaml:"tools"`
+		Color       string    `yaml:"color"`
+	}
+	if front != nil {
+		if err := front.Decode(&meta); err != nil {
+			return Agent{}, fmt.Errorf("front matter 格式错误: %w", err)
+		}
+	}
+	agent := Agent{
+		Name:        strings.TrimSpace(meta.Name),
+		FileName:    fileName,
+		Description: strings.TrimSpace(meta.Description),
+		Model:       strings.TrimSpace(meta.Model),
+		Tools:       parseAgentTools(meta.Tools),
+		Color:       strings.TrimSpace(meta.Color),
+		Prompt:      body,
+		Enabled:     strings.HasSuffix(path, agentFileExt),
+		Location:    target.Location,
+		ProjectDir:  target.ProjectDir,
+		Path:        path,
+		UpdatedAt:   info.ModTime(),
+	}
+	if agent.Location == "" {
+		agent.Location = "user"
+	}
+	if agent.Name == "" {
+		agent.Name = fileName
+	}
+	agent.CodexSynced = fileExists(agentCodexPath(agent.Name))
+	return agent, nil
+}
+
+// parseAgentTools tools 既可以写成逗号分隔的字符串，也可以写成列表
+func parseAgentTools(node yaml.Node) []string {
+	tools := []string{}
+	var values []string
+	switch node.Kind {
+	case yaml.ScalarNode:
+		values = strings.Split(node.Value, ",")
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			values = append(values, item.Value)
+		}
+	}
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			tools = append(tools, value)
+		}
+	}
+	return tools
+}
+
+// splitAgentFile 拆分 front matter 与正文，没有 front matter 时返回 nil
+func splitAgentFile(content string) (*yaml.Node, string) {
+	content = strings.TrimLeft(content, "\ufeff")
+	if !strings.HasPrefix(content, "---") {
+		return nil, strings.TrimSpace(content)
+	}
+	parts := strings.SplitN(content, "---", 3)
+	if len(parts) < 3 {
+		return nil, strings.TrimSpace(content)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(parts[1]), &doc); err != nil || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, strings.TrimSpace(parts[2])
+	}
+	return doc.Content[0], strings.TrimSpace(parts[2])
+}
+
+// writeAgentFile 在原有 front matter 上更新已知字段，字段为空时删除
+func writeAgentFile(path string, front *yaml.Node, agent Agent) error {
+	if front == nil {
+		front = &yaml.Node{Kind: yaml.MappingNode}
+	}
+	setFrontMatterValue(front, "name", agent.Name)
+	setFrontMatterValue(front, "description", agent.Description)
+	tools := make([]string, 0, len(agent.Tools))
+	for _, tool := range agent.Tools {
+		if tool = strings.TrimSpace(tool); tool != "" {
+			tools = append(tools, tool)
+		}
+	}
+	setFrontMatterValue(front, "tools", strings.Join(tools, ", "))
+	setFrontMatterValue(front, "model", agent.Model)
+	setFrontMatterValue(front, "color", strings.TrimSpace(agent.Color))
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(front); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	content := "---\n" + buf.String() + "---\n\n" + strings.TrimSpace(agent.Prompt) + "\n"
+	return os.WriteFile(path, []byte(content), 0o644)
+}
+
+func setFrontMatterValue(node *yaml.Node, key, value string) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != key {
+			continue
+		}
+		if value == "" {
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			return
+		}
+		node.Content[i+1] = &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+		return
+	}
+	if value != "" {
+		node.Content = append(node.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: key},
+			&yaml.Node{Kind: yaml.ScalarNode, Value: value},
+		)
+	}
+}
+
+func agentCodexPath(name string) string {
+	return filepath.Join(userHomeDir(), codexDirName, "prompts", agentCodexPrefix+name+".md")
+}
+
+func writeAgentCodexPrompt(agent Agent) error {
+	path := agentCodexPath(agent.Name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	content := fmt.Sprintf("---\ndescription: %s\nargument-hint: %s\n---\n\n%s\n\n$ARGUMENTS\n",
+		yamlScalar(agent.Description), yamlScalar("[task]"), strings.TrimSpace(agent.Prompt))
+	return os.WriteFile(path, []byte(content), 0o644)
+}
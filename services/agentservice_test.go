@@ -0,0 +1,86 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestAgentLifecycle(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	project := t.TempDir()
+	target := AgentTarget{Location: "project", ProjectDir: project}
+	as := NewAgentService()
+
+	agent, err := as.CreateAgent(CreateAgentRequest{Name: "reviewer", Template: "code-reviewer", Location: "project", ProjectDir: project})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if agent.Path != filepath.Join(project, ".claude", "agents", "reviewer.md") || !agent.Enabled || agent.Model != "inherit" {
+		t.Fatalf("agent = %+v", agent)
+	}
+	if _, err := as.CreateAgent(CreateAgentRequest{Name: "reviewer", Description: "dup", Location: "project", ProjectDir: project}); err == nil {
+		t.Fatal("expected duplicate error")
+	}
+
+	// 未识别的 front matter 字段保留，空字段删除
+	data, _ := os.ReadFile(agent.Path)
+	if err := os.WriteFile(agent.Path, []byte(strings.Replace(string(data), "---\n", "---\nowner: team-a\n", 1)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	agent.Name = "code-reviewer"
+	agent.Model = ""
+	agent.Tools = []string{"Read", " Grep "}
+	agent.Prompt = "Review carefully."
+	saved, err := as.SaveAgent(agent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(saved.Path)
+	want := "---\nowner: team-a\nname: code-reviewer\ndescription: " + agentTemplates[0].Description + "\ntools: Read, Grep\n---\n\nReview carefully.\n"
+	if string(data) != want || fileExists(agent.Path) {
+		t.Fatalf("saved file = %q", data)
+	}
+
+	synced, err := as.SetAgentCodexSync(target, "code-reviewer", true)
+	if err != nil || !synced.CodexSynced {
+		t.Fatalf("codex sync: %+v, %v", synced, err)
+	}
+	disabled, err := as.SetAgentEnabled(target, "code-reviewer", false)
+	if err != nil || disabled.Enabled || disabled.CodexSynced || !strings.HasSuffix(disabled.Path, agentDisabledExt) {
+		t.Fatalf("disable: %+v, %v", disabled, err)
+	}
+
+	agents, err := as.ListAgents(target)
+	if err != nil || len(agents) != 1 || agents[0].Enabled || !reflect.DeepEqual(agents[0].Tools, []string{"Read", "Grep"}) {
+		t.Fatalf("agents = %+v, %v", agents, err)
+	}
+	if err := as.DeleteAgent(target, "code-reviewer"); err != nil {
+		t.Fatal(err)
+	}
+	if agents, _ := as.ListAgents(target); len(agents) != 0 {
+		t.Fatalf("agents after delete = %+v", agents)
+	}
+}
+
+func TestParseAgentToolsList(t *testing.T) {
+	front, body := splitAgentFile("---\nname: a\ntools:\n  - Read\n  - Bash\n---\nbody\n")
+	if front == nil || body != "body" {
+		t.Fatalf("front = %v, body = %q", front, body)
+	}
+	var meta struct {
+		Tools yaml.Node `yaml:"tools"`
+	}
+	if err := front.Decode(&meta); err != nil {
+		t.Fatal(err)
+	}
+	if got := parseAgentTools(meta.Tools); !reflect.DeepEqual(got, []string{"Read", "Bash"}) {
+		t.Fatalf("tools = %v", got)
+	}
+}
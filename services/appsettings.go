@@ -2,6 +2,7 @@ package services
 
 import (
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
 	"sync"
@@ -16,6 +17,27 @@ type AppSettings struct {
 	ShowHeatmap   bool `json:"show_heatmap"`
 	ShowHomeTitle bool `json:"show_home_title"`
 	AutoStart     bool `json:"auto_start"`
+	DemoMode      bool `json:"demo_mode"`
+
+	Blacklist   BlacklistPolicy   `json:"blacklist"`
+	HealthCheck HealthCheckPolicy `json:"health_check"`
+	Degraded    DegradedPolicy    `json:"degraded"`
+	BodyLogging BodyLoggingPolicy `json:"body_logging"`
+	Tracing     TracingPolicy     `json:"tracing"`
+	Currency    CurrencySettings  `json:"currency"`
+	Budget      SpendingBudget    `json:"budget"`
+	// 非默认 profile 的预算，key 为 profile ID
+	ProfileBudgets map[string]SpendingBudget `json:"profile_budgets,omitempty"`
+
+	UsageWebhook UsageWebhookPolicy `json:"usage_webhook"`
+	LogRetention LogRetentionPolicy `json:"log_retention"`
+	ConfigWatch  ConfigWatchPolicy  `json:"config_watch"`
+	Backup       BackupPolicy       `json:"backup"`
+
+	Notifications NotificationSettings `json:"notifications"`
+	Email         EmailSettings        `json:"email"`
+
+	Update UpdateSettings `json:"update"`
 }
 
 type AppSettingsService struct {
@@ -25,13 +47,8 @@ type AppSettingsService struct {
 }
 
 func NewAppSettingsService(autoStartService *AutoStartService) *AppSettingsService {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		home = "."
-	}
-	path := filepath.Join(home, appSettingsDir, appSettingsFile)
 	return &AppSettingsService{
-		path:             path,
+		path:             appSettingsPath(),
 		autoStartService: autoStartService,
 	}
 }
@@ -49,6 +66,18 @@ func (as *AppSettingsService) defaultSettings() AppSettings {
 		ShowHeatmap:   true,
 		ShowHomeTitle: true,
 		AutoStart:     autoStartEnabled,
+		Blacklist:     defaultBlacklistPolicy(),
+		HealthCheck:   defaultHealthCheckPolicy(),
+		Degraded:      defaultDegradedPolicy(),
+		BodyLogging:   defaultBodyLoggingPolicy(),
+		Tracing:       defaultTracingPolicy(),
+		Currency:      defaultCurrencySettings(),
+		UsageWebhook:  defaultUsageWebhookPolicy(),
+		LogRetention:  defaultLogRetentionPolicy(),
+		ConfigWatch:   defaultConfigWatchPolicy(),
+		Backup:        defaultBackupPolicy(),
+		Email:         defaultEmailSettings(),
+		Update:        defaultUpdateSettings(),
 	}
 }
 
@@ -83,6 +112,42 @@ func (as *AppSettingsService) SaveAppSettings(settings AppSettings) (AppSettings
 	return settings, nil
 }
 
+// GetBlacklistPolicy 返回拉黑升级曲线配置
+func (as *AppSettingsService) GetBlacklistPolicy() (BlacklistPolicy, error) {
+	settings, err := as.GetAppSettings()
+	if err != nil {
+		return defaultBlacklistPolicy(), err
+	}
+	return normalizeBlacklistPolicy(settings.Blacklist), nil
+}
+
+// SaveBlacklistPolicy 保存拉黑升级曲线配置
+func (as *AppSettingsService) SaveBlacklistPolicy(policy BlacklistPolicy) (BlacklistPolicy, error) {
+	if len(policy.Durations) == 0 {
+		return policy, errors.New("至少需要配置一级拉黑时长")
+	}
+	policy = normalizeBlacklistPolicy(policy)
+	_, err := as.update(func(settings *AppSettings) {
+		settings.Blacklist = policy
+	})
+	return policy, err
+}
+
+// update 在锁内读取、修改并保存设置，供其他服务修改各自关心的字段
+func (as *AppSettingsService) update(mutate func(settings *AppSettings)) (AppSettings, error) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	settings, err := as.loadLocked()
+	if err != nil {
+		return settings, err
+	}
+	mutate(&settings)
+	if err := as.saveLocked(settings); err != nil {
+		return settings, err
+	}
+	return settings, nil
+}
+
 func (as *AppSettingsService) loadLocked() (AppSettings, error) {
 	settings := as.defaultSettings()
 	data, err := os.ReadFile(as.path)
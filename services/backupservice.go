@@ -0,0 +1,439 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// 与启动自检的 backups 目录分开，自检按目录名清理旧快照
+	backupDirName      = "config-history"
+	backupManifestName = "manifest.json"
+	defaultBackupKeep  = 20
+	maxBackupKeep      = 200
+)
+
+// 快照只用于查看与手动恢复历史版本。开启代理时写在 CLI 目录中的 cc-studio.back.* 不经过这里：
+// 它们是关闭代理时要还原的唯一基准，不能被保留数清理掉，未注册 BackupService（WSL 目标、测试）时也要可用，
+// 旧版本升级上来的用户同样依赖这些文件关闭代理
+
+// BackupPolicy 每个受管文件保留的历史版本数
+type BackupPolicy struct {
+	Keep int `json:"keep"`
+}
+
+// BackupFile 快照中的一个文件。Exists 为 false 表示操作前文件不存在，恢复时会删除该文件
+type BackupFile struct {
+	Path   string      `json:"path"`
+	Exists bool        `json:"exists"`
+	Size   int64       `json:"size"`
+	Hash   string      `json:"hash,omitempty"`
+	Mode   os.FileMode `json:"mode,omitempty"`
+	Blob   string      `json:"blob,omitempty"`
+}
+
+// BackupSnapshot 一次操作前留存的快照，同一操作涉及的多个文件放在同一个快照中，可整体恢复
+type BackupSnapshot struct {
+	ID        string       `json:"id"`
+	Operation string       `json:"operation"`
+	CreatedAt time.Time    `json:"created_at"`
+	Files     []BackupFile `json:"files"`
+}
+
+// BackupService 集中保存各服务修改配置文件前的快照，按文件保留最近的若干版本
+type BackupService struct {
+	appSettings *AppSettingsService
+	// root 为空时使用数据目录下的 config-history，数据目录迁移后随之变化
+	root string
+	mu   sync.Mutex
+}
+
+// activeBackups 由 BackupService.Start 注册，未注册时（如测试中）snapshotConfigFiles 不做任何事
+var activeBackups struct {
+	sync.RWMutex
+	service *BackupService
+}
+
+func NewBackupService(appSettings *AppSettingsService) *BackupService {
+	return &BackupService{appSettings: appSettings}
+}
+
+func defaultBackupPolicy() BackupPolicy {
+	return BackupPolicy{Keep: defaultBackupKeep}
+}
+
+func normalizeBackupPolicy(policy BackupPolicy) BackupPolicy {
+	if policy.Keep <= 0 {
+		policy.Keep = defaultBackupKeep
+	}
+	if policy.Keep > maxBackupKeep {
+		policy.Keep = maxBackupKeep
+	}
+	return policy
+}
+
+// Start 注册为全局快照服务，并按当前保留数清理一次
+func (bs *BackupService) Start() error {
+	activeBackups.Lock()
+	activeBackups.service = bs
+	activeBackups.Unlock()
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	return bs.pruneLocked(bs.policy().Keep)
+}
+
+func (bs *BackupService) Stop() error {
+	activeBackups.Lock()
+	if activeBackups.service == bs {
+		activeBackups.service = nil
+	}
+	activeBackups.Unlock()
+	return nil
+}
+
+// GetBackupPolicy 返回备份保留设置
+func (bs *BackupService) GetBackupPolicy() BackupPolicy {
+	return bs.policy()
+}
+
+// SaveBackupPolicy 保存备份保留设置，超出新保留数的旧版本立即删除
+func (bs *BackupService) SaveBackupPolicy(policy BackupPolicy) (BackupPolicy, error) {
+	policy = normalizeBackupPolicy(policy)
+	if bs.appSettings != nil {
+		if _, err := bs.appSettings.update(func(settings *AppSettings) {
+			settings.Backup = policy
+		}); err != nil {
+			return policy, err
+		}
+	}
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	return policy, bs.pruneLocked(policy.Keep)
+}
+
+// ListBackups 按时间倒序返回快照，path 非空时只返回包含该文件的快照
+func (bs *BackupService) ListBackups(path string) ([]BackupSnapshot, error) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	snapshots, err := bs.loadSnapshotsLocked()
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return snapshots, nil
+	}
+	path = filepath.Clean(path)
+	filtered := make([]BackupSnapshot, 0)
+	for _, snapshot := range snapshots {
+		for _, file := range snapshot.Files {
+			if file.Path == path {
+				filtered = append(filtered, snapshot)
+				break
+			}
+		}
+	}
+	return filtered, nil
+}
+
+// Snapshot 保存 paths 的当前内容。所有文件都与各自最近一次快照相同时不重复保存，返回 nil
+func (bs *BackupService) Snapshot(operation string, paths ...string) (*BackupSnapshot, error) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	return bs.snapshotLocked(operation, paths)
+}
+
+// RestoreBackup 把快照中的文件恢复到快照时的状态，path 为空时恢复快照中的全部文件。
+// 恢复前会先为当前内容保存快照，恢复本身可以撤销
+func (bs *BackupService) RestoreBackup(id string, path string) error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	snapshot, err := bs.loadSnapshotLocked(id)
+	if err != nil {
+		return err
+	}
+	files := make([]BackupFile, 0, len(snapshot.Files))
+	for _, file := range snapshot.Files {
+		if path == "" || file.Path == filepath.Clean(path) {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("快照 %s 中没有 %s", id, path)
+	}
+	// 先读出快照内容，保存恢复前快照时的清理可能删除这个较旧的版本
+	paths := make([]string, 0, len(files))
+	contents := make([][]byte, len(files))
+	for i, file := range files {
+		paths = append(paths, file.Path)
+		if !file.Exists {
+			continue
+		}
+		if contents[i], err = os.ReadFile(filepath.Join(bs.dir(), snapshot.ID, file.Blob)); err != nil {
+			return err
+		}
+	}
+	if _, err := bs.snapshotLocked("restore:"+id, paths); err != nil {
+		return err
+	}
+	for i, file := range files {
+		if !file.Exists {
+			if err := os.Remove(file.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			continue
+		}
+		mode := file.Mode
+		if mode == 0 {
+			mode = 0o600
+		}
+		if err := os.MkdirAll(filepath.Dir(file.Path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(file.Path, contents[i], mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DiffBackup 比较快照中的文件与当前文件，删除行为快照中的内容，新增行为当前内容
+func (bs *BackupService) DiffBackup(id string, path string) (PromptDiff, error) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	snapshot, err := bs.loadSnapshotLocked(id)
+	if err != nil {
+		return PromptDiff{}, err
+	}
+	path = filepath.Clean(path)
+	for _, file := range snapshot.Files {
+		if file.Path != path {
+			continue
+		}
+		var before []byte
+		if file.Exists {
+			if before, err = os.ReadFile(filepath.Join(bs.dir(), snapshot.ID, file.Blob)); err != nil {
+				return PromptDiff{}, err
+			}
+		}
+		after, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return PromptDiff{}, err
+		}
+		return diffPromptLines(string(before), string(after)), nil
+	}
+	return PromptDiff{}, fmt.Errorf("快照 %s 中没有 %s", id, path)
+}
+
+// DeleteBackup 删除整个快照
+func (bs *BackupService) DeleteBackup(id string) error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if _, err := bs.loadSnapshotLocked(id); err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(bs.dir(), id))
+}
+
+func (bs *BackupService) snapshotLocked(operation string, paths []string) (*BackupSnapshot, error) {
+	snapshots, err := bs.loadSnapshotsLocked()
+	if err != nil {
+		return nil, err
+	}
+	latest := make(map[string]BackupFile)
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		for _, file := range snapshots[i].Files {
+			latest[file.Path] = file
+		}
+	}
+
+	snapshot := BackupSnapshot{Operation: operation, CreatedAt: time.Now(), Files: make([]BackupFile, 0, len(paths))}
+	contents := make(map[string][]byte, len(paths))
+	changed := false
+	seen := make(map[string]struct{}, len(paths))
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		if _, ok := seen[path]; ok {
+			continue
+		}
+		seen[path] = struct{}{}
+		file := BackupFile{Path: path}
+		info, err := os.Stat(path)
+		if err == nil && !info.IsDir() {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			sum := sha256.Sum256(data)
+			file.Exists, file.Size, file.Mode = true, int64(len(data)), info.Mode().Perm()
+			file.Hash = hex.EncodeToString(sum[:])
+			file.Blob = strconv.Itoa(len(snapshot.Files)) + ".bak"
+			contents[file.Blob] = data
+		} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		previous, ok := latest[path]
+		if !ok || previous.Exists != file.Exists || previous.Hash != file.Hash {
+			changed = true
+		}
+		snapshot.Files = append(snapshot.Files, file)
+	}
+	if !changed {
+		return nil, nil
+	}
+
+	snapshot.ID = snapshot.CreatedAt.Format("20060102-150405.000000")
+	dir := filepath.Join(bs.dir(), snapshot.ID)
+	for suffix := 1; fileExists(dir); suffix++ {
+		snapshot.ID = fmt.Sprintf("%s-%d", snapshot.CreatedAt.Format("20060102-150405.000000"), suffix)
+		dir = filepath.Join(bs.dir(), snapshot.ID)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	for blob, data := range contents {
+		if err := os.WriteFile(filepath.Join(dir, blob), data, 0o600); err != nil {
+			return nil, err
+		}
+	}
+	if err := writeBackupManifest(dir, snapshot); err != nil {
+		return nil, err
+	}
+	if err := bs.pruneLocked(bs.policy().Keep); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// pruneLocked 每个文件只保留最近 keep 个版本，文件全部被清理的快照整体删除
+func (bs *BackupService) pruneLocked(keep int) error {
+	snapshots, err := bs.loadSnapshotsLocked()
+	if err != nil {
+		return err
+	}
+	counts := make(map[string]int)
+	for _, snapshot := range snapshots {
+		kept := make([]BackupFile, 0, len(snapshot.Files))
+		for _, file := range snapshot.Files {
+			counts[file.Path]++
+			if counts[file.Path] <= keep {
+				kept = append(kept, file)
+			}
+		}
+		if len(kept) == len(snapshot.Files) {
+			continue
+		}
+		dir := filepath.Join(bs.dir(), snapshot.ID)
+		if len(kept) == 0 {
+			if err := os.RemoveAll(dir); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, file := range snapshot.Files {
+			if counts[file.Path] > keep && file.Blob != "" {
+				_ = os.Remove(filepath.Join(dir, file.Blob))
+			}
+		}
+		snapshot.Files = kept
+		if err := writeBackupManifest(dir, snapshot); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadSnapshotsLocked 按时间倒序返回全部快照，无法解析的目录跳过
+func (bs *BackupService) loadSnapshotsLocked() ([]BackupSnapshot, error) {
+	entries, err := os.ReadDir(bs.dir())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []BackupSnapshot{}, nil
+		}
+		return nil, err
+	}
+	snapshots := make([]BackupSnapshot, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		snapshot, err := bs.loadSnapshotLocked(entry.Name())
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		if !snapshots[i].CreatedAt.Equal(snapshots[j].CreatedAt) {
+			return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt)
+		}
+		return snapshots[i].ID > snapshots[j].ID
+	})
+	return snapshots, nil
+}
+
+func (bs *BackupService) loadSnapshotLocked(id string) (BackupSnapshot, error) {
+	var snapshot BackupSnapshot
+	if id == "" || filepath.Base(id) != id {
+		return snapshot, fmt.Errorf("快照 ID 无效: %s", id)
+	}
+	data, err := os.ReadFile(filepath.Join(bs.dir(), id, backupManifestName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return snapshot, fmt.Errorf("快照 %s 不存在", id)
+		}
+		return snapshot, err
+	}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return snapshot, err
+	}
+	snapshot.ID = id
+	return snapshot, nil
+}
+
+func (bs *BackupService) policy() BackupPolicy {
+	if bs.appSettings == nil {
+		return defaultBackupPolicy()
+	}
+	settings, err := bs.appSettings.GetAppSettings()
+	if err != nil {
+		return defaultBackupPolicy()
+	}
+	return normalizeBackupPolicy(settings.Backup)
+}
+
+func (bs *BackupService) dir() string {
+	if bs.root != "" {
+		return bs.root
+	}
+	return filepath.Join(dataDir(), backupDirName)
+}
+
+func writeBackupManifest(dir string, snapshot BackupSnapshot) error {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, backupManifestName), data, 0o600)
+}
+
+// snapshotConfigFiles 修改配置文件前调用，为 paths 保存一个快照；失败只记录警告，不影响原操作
+func snapshotConfigFiles(operation string, paths ...string) {
+	activeBackups.RLock()
+	service := activeBackups.service
+	activeBackups.RUnlock()
+	if service == nil || len(paths) == 0 {
+		return
+	}
+	if _, err := service.Snapshot(operation, paths...); err != nil {
+		fmt.Printf("[WARN] 保存配置快照失败 (%s): %v\n", operation, err)
+	}
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupSnapshotRestoreAndRetention(t *testing.T) {
+	work := t.TempDir()
+	bs := &BackupService{root: filepath.Join(t.TempDir(), "backups")}
+	config := filepath.Join(work, "config.toml")
+	auth := filepath.Join(work, "auth.json")
+	writeSkillFiles(t, work, map[string]string{"config.toml": "model = \"o3\"\n"})
+
+	first, err := bs.Snapshot("codex:enable-proxy", config, auth)
+	if err != nil || first == nil {
+		t.Fatalf("snapshot: %v %v", first, err)
+	}
+	if again, _ := bs.Snapshot("codex:enable-proxy", config, auth); again != nil {
+		t.Fatal("unchanged files should not create a new snapshot")
+	}
+
+	writeSkillFiles(t, work, map[string]string{"config.toml": "model = \"gpt-5\"\n", "auth.json": "{}"})
+	diff, err := bs.DiffBackup(first.ID, config)
+	if err != nil || diff.Added != 1 || diff.Removed != 1 {
+		t.Fatalf("unexpected diff %+v %v", diff, err)
+	}
+	if err := bs.RestoreBackup(first.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(config); string(data) != "model = \"o3\"\n" {
+		t.Fatalf("config not restored: %s", data)
+	}
+	if fileExists(auth) {
+		t.Fatal("auth.json did not exist in the snapshot and should be removed")
+	}
+	snapshots, _ := bs.ListBackups(config)
+	if len(snapshots) != 2 || snapshots[0].Operation != "restore:"+first.ID {
+		t.Fatalf("expected a pre-restore snapshot, got %+v", snapshots)
+	}
+
+	for _, content := range []string{"a", "b", "c"} {
+		writeSkillFiles(t, work, map[string]string{"config.toml": content})
+		if _, err := bs.Snapshot("edit", config); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bs.mu.Lock()
+	err = bs.pruneLocked(2)
+	bs.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshots, _ := bs.ListBackups(config); len(snapshots) != 2 {
+		t.Fatalf("expected 2 versions after pruning, got %d", len(snapshots))
+	}
+	// 最早两个快照中的 config.toml 版本被清理，auth.json 的版本仍在保留数内
+	all, _ := bs.ListBackups("")
+	if len(all) != 4 || len(all[3].Files) != 1 || all[3].Files[0].Path != auth {
+		t.Fatalf("unexpected snapshots after pruning: %+v", all)
+	}
+}
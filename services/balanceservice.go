@@ -0,0 +1,291 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+const (
+	balancePollInterval = 15 * time.Minute
+	balanceQueryTimeout = 15 * time.Second
+)
+
+// BalanceQuery 描述 provider 的余额查询接口
+type BalanceQuery struct {
+	URL     string            `json:"url"`
+	Method  string            `json:"method,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// 余额字段路径（gjson 语法），如 "data.total_available"、"balance_infos.0.total_balance"
+	JSONPath string `json:"jsonPath"`
+	// 换算系数，部分平台以额度单位返回（如 500000 = 1 美元），结果 = 原值 / Divisor
+	Divisor float64 `json:"divisor,omitempty"`
+	Unit    string  `json:"unit,omitempty"`
+	// 低于该值视为余额不足
+	LowThreshold float64 `json:"lowThreshold,omitempty"`
+	// 余额不足时在路由中自动降到最后
+	AutoDeprioritize bool `json:"autoDeprioritize,omitempty"`
+}
+
+type ProviderBalance struct {
+	Platform   string    `json:"platform"`
+	ProviderID int       `json:"provider_id"`
+	Provider   string    `json:"provider"`
+	Remaining  float64   `json:"remaining"`
+	Unit       string    `json:"unit,omitempty"`
+	Low        bool      `json:"low"`
+	Error      string    `json:"error,omitempty"`
+	CheckedAt  time.Time `json:"checked_at"`
+}
+
+// lowBalanceProviders 由 BalanceService 维护、relay 读取：余额不足且开启自动降级的 provider
+var lowBalanceProviders = struct {
+	sync.RWMutex
+	names map[string]map[string]struct{}
+}{names: make(map[string]map[string]struct{})}
+
+type BalanceService struct {
+	providerService *ProviderService
+	httpClient      *http.Client
+	mu              sync.Mutex
+	balances        map[string]ProviderBalance
+	alertHandler    func(ProviderBalance)
+	stopCh          chan struct{}
+}
+
+func NewBalanceService(providerService *ProviderService) *BalanceService {
+	return &BalanceService{
+		providerService: providerService,
+		httpClient:      &http.Client{Timeout: balanceQueryTimeout},
+		balances:        make(map[string]ProviderBalance),
+	}
+}
+
+// SetAlertHandler 设置余额不足时的回调（由 main 转为前端事件/系统通知）
+func (bs *BalanceService) SetAlertHandler(handler func(ProviderBalance)) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	bs.alertHandler = handler
+}
+
+// Start 启动定时余额查询
+func (bs *BalanceService) Start() error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if bs.stopCh != nil {
+		return nil
+	}
+	stopCh := make(chan struct{})
+	bs.stopCh = stopCh
+	go func() {
+		bs.refreshAll()
+		ticker := time.NewTicker(balancePollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				bs.refreshAll()
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (bs *BalanceService) Stop() error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if bs.stopCh != nil {
+		close(bs.stopCh)
+		bs.stopCh = nil
+	}
+	return nil
+}
+
+// ListBalances 返回最近一次查询到的余额
+func (bs *BalanceService) ListBalances(kind string) []ProviderBalance {
+	platform := normalizePresetKind(kind)
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	result := make([]ProviderBalance, 0, len(bs.balances))
+	for _, balance := range bs.balances {
+		if platform != "" && balance.Platform != platform {
+			continue
+		}
+		result = append(result, balance)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Platform != result[j].Platform {
+			return result[i].Platform < result[j].Platform
+		}
+		return result[i].ProviderID < result[j].ProviderID
+	})
+	return result
+}
+
+// RefreshBalances 立即查询指定平台所有配置了余额接口的 provider
+func (bs *BalanceService) RefreshBalances(kind string) ([]ProviderBalance, error) {
+	platform := normalizePresetKind(kind)
+	if platform == "" {
+		return nil, fmt.Errorf("unknown provider type: %s", kind)
+	}
+	providers, err := bs.providerService.LoadProviders(platform)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]ProviderBalance, 0)
+	lowNames := make(map[string]struct{})
+	for _, provider := range providers {
+		if provider.Balance == nil || strings.TrimSpace(provider.Balance.URL) == "" {
+			continue
+		}
+		balance := bs.query(platform, provider)
+		bs.store(balance)
+		if balance.Low && provider.Balance.AutoDeprioritize {
+			lowNames[strings.ToLower(provider.Name)] = struct{}{}
+		}
+		results = append(results, balance)
+	}
+	lowBalanceProviders.Lock()
+	lowBalanceProviders.names[platform] = lowNames
+	lowBalanceProviders.Unlock()
+	return results, nil
+}
+
+// QueryBalance 查询单个 provider 的余额（用于配置时测试）
+func (bs *BalanceService) QueryBalance(kind string, providerID int) (ProviderBalance, error) {
+	platform := normalizePresetKind(kind)
+	providers, err := bs.providerService.LoadProviders(platform)
+	if err != nil {
+		return ProviderBalance{}, err
+	}
+	for _, provider := range providers {
+		if provider.ID != providerID {
+			continue
+		}
+		if provider.Balance == nil || strings.TrimSpace(provider.Balance.URL) == "" {
+			return ProviderBalance{}, errors.New("该 provider 未配置余额查询")
+		}
+		balance := bs.query(platform, provider)
+		bs.store(balance)
+		return balance, nil
+	}
+	return ProviderBalance{}, fmt.Errorf("未找到 provider id %d", providerID)
+}
+
+func (bs *BalanceService) refreshAll() {
+	for _, kind := range []string{"claude", "codex"} {
+		if _, err := bs.RefreshBalances(kind); err != nil {
+			fmt.Printf("[WARN] 余额查询失败 [%s]: %v\n", kind, err)
+		}
+	}
+}
+
+// store 保存查询结果，余额从正常变为不足时触发一次告警
+func (bs *BalanceService) store(balance ProviderBalance) {
+	key := fmt.Sprintf("%s:%d", balance.Platform, balance.ProviderID)
+	bs.mu.Lock()
+	previous, existed := bs.balances[key]
+	bs.balances[key] = balance
+	handler := bs.alertHandler
+	bs.mu.Unlock()
+	if handler != nil && balance.Low && (!existed || !previous.Low) {
+		handler(balance)
+	}
+}
+
+func (bs *BalanceService) query(platform string, provider Provider) ProviderBalance {
+	cfg := provider.Balance
+	balance := ProviderBalance{
+		Platform:   platform,
+		ProviderID: provider.ID,
+		Provider:   provider.Name,
+		Unit:       cfg.Unit,
+		CheckedAt:  time.Now(),
+	}
+	remaining, err := bs.fetchBalance(provider)
+	if err != nil {
+		balance.Error = err.Error()
+		return balance
+	}
+	balance.Remaining = remaining
+	balance.Low = cfg.LowThreshold > 0 && remaining < cfg.LowThreshold
+	return balance
+}
+
+func (bs *BalanceService) fetchBalance(provider Provider) (float64, error) {
+	cfg := provider.Balance
+	method := strings.ToUpper(strings.TrimSpace(cfg.Method))
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequest(method, cfg.URL, nil)
+	if err != nil {
+		return 0, err
+	}
+	headers := map[string]string{"Accept": "application/json"}
+	applyAuthHeaders(headers, provider)
+	for key, value := range cfg.Headers {
+		headers[key] = strings.ReplaceAll(value, "{apiKey}", provider.APIKey)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := bs.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("余额接口返回 %s", resp.Status)
+	}
+	return parseBalanceValue(body, cfg.JSONPath, cfg.Divisor)
+}
+
+func parseBalanceValue(body []byte, path string, divisor float64) (float64, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return 0, errors.New("未配置余额字段路径")
+	}
+	result := gjson.GetBytes(body, path)
+	if !result.Exists() {
+		return 0, fmt.Errorf("响应中未找到字段 %s", path)
+	}
+	value := result.Float()
+	if divisor > 0 {
+		value = value / divisor
+	}
+	return value, nil
+}
+
+// deprioritizeLowBalance 把余额不足的 provider 移到队尾，其余保持原顺序
+func deprioritizeLowBalance(kind string, providers []Provider) []Provider {
+	lowBalanceProviders.RLock()
+	low := lowBalanceProviders.names[normalizePresetKind(kind)]
+	lowBalanceProviders.RUnlock()
+	if len(low) == 0 {
+		return providers
+	}
+	ordered := make([]Provider, 0, len(providers))
+	tail := make([]Provider, 0)
+	for _, provider := range providers {
+		if _, ok := low[strings.ToLower(provider.Name)]; ok {
+			tail = append(tail, provider)
+			continue
+		}
+		ordered = append(ordered, provider)
+	}
+	return append(ordered, tail...)
+}
@@ -0,0 +1,440 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BlacklistPolicy 拉黑升级曲线：连续失败达到阈值后按等级拉黑，等级越高时长越长
+type BlacklistPolicy struct {
+	Enabled bool `json:"enabled"`
+	// 连续失败多少次触发拉黑
+	FailureThreshold int `json:"failure_threshold"`
+	// 每一级的拉黑时长（秒），依次对应 L1、L2...，超过长度时沿用最后一级
+	Durations []int `json:"durations"`
+	// 最高等级
+	MaxLevel int `json:"max_level"`
+	// 距上次失败每经过该分钟数，等级下降一级；0 表示不衰减
+	DecayMinutes int `json:"decay_minutes"`
+	// 请求成功后是否直接清零等级
+	ResetOnSuccess bool `json:"reset_on_success"`
+}
+
+type BlacklistEntry struct {
+	Platform     string    `json:"platform"`
+	Provider     string    `json:"provider"`
+	Level        int       `json:"level"`
+	Until        time.Time `json:"until"`
+	RemainingSec int64     `json:"remaining_sec"`
+	Failures     int       `json:"failures"`
+	Reason       string    `json:"reason"`
+	Manual       bool      `json:"manual"`
+}
+
+type providerHealthState struct {
+	failures    int
+	level       int
+	until       time.Time
+	lastFailure time.Time
+	reason      string
+	manual      bool
+}
+
+// BlacklistRecovery 拉黑到期或健康检查通过后 provider 恢复可用
+type BlacklistRecovery struct {
+	Platform string `json:"platform"`
+	Provider string `json:"provider"`
+	Level    int    `json:"level"`
+	// expired：拉黑到期；health_check：健康检查通过提前恢复
+	Source      string    `json:"source"`
+	Reason      string    `json:"reason"`
+	RecoveredAt time.Time `json:"recovered_at"`
+}
+
+const (
+	RecoverySourceExpired     = "expired"
+	RecoverySourceHealthCheck = "health_check"
+)
+
+type BlacklistService struct {
+	appSettings     *AppSettingsService
+	mu              sync.Mutex
+	states          map[string]*providerHealthState
+	recoveryHandler func(BlacklistRecovery)
+}
+
+func NewBlacklistService(appSettings *AppSettingsService) *BlacklistService {
+	return &BlacklistService{
+		appSettings: appSettings,
+		states:      make(map[string]*providerHealthState),
+	}
+}
+
+func defaultBlacklistPolicy() BlacklistPolicy {
+	return BlacklistPolicy{
+		Enabled:          true,
+		FailureThreshold: 3,
+		Durations:        []int{300, 900, 1800, 3600, 7200},
+		MaxLevel:         5,
+		DecayMinutes:     60,
+		ResetOnSuccess:   true,
+	}
+}
+
+// normalizeBlacklistPolicy 校正非法配置，保证升级曲线可用
+func normalizeBlacklistPolicy(policy BlacklistPolicy) BlacklistPolicy {
+	defaults := defaultBlacklistPolicy()
+	if policy.FailureThreshold <= 0 {
+		policy.FailureThreshold = defaults.FailureThreshold
+	}
+	durations := make([]int, 0, len(policy.Durations))
+	for _, d := range policy.Durations {
+		if d > 0 {
+			durations = append(durations, d)
+		}
+	}
+	if len(durations) == 0 {
+		durations = defaults.Durations
+	}
+	policy.Durations = durations
+	if policy.MaxLevel <= 0 {
+		policy.MaxLevel = len(policy.Durations)
+	}
+	if policy.DecayMinutes < 0 {
+		policy.DecayMinutes = 0
+	}
+	return policy
+}
+
+// levelDuration 返回某一等级对应的拉黑时长
+func (p BlacklistPolicy) levelDuration(level int) time.Duration {
+	if level <= 0 {
+		return 0
+	}
+	index := level - 1
+	if index >= len(p.Durations) {
+		index = len(p.Durations) - 1
+	}
+	return time.Duration(p.Durations[index]) * time.Second
+}
+
+// decayLevel 按距上次失败的时间衰减等级
+func (p BlacklistPolicy) decayLevel(level int, lastFailure, now time.Time) int {
+	if p.DecayMinutes <= 0 || level <= 0 || lastFailure.IsZero() {
+		return level
+	}
+	steps := int(now.Sub(lastFailure) / (time.Duration(p.DecayMinutes) * time.Minute))
+	if steps >= level {
+		return 0
+	}
+	return level - steps
+}
+
+// RecordFailure 记录一次失败，达到阈值时升级并拉黑，返回是否因此被拉黑。
+// 每个请求都会调用：配置在加锁前读取，事件在解锁后写入，避免并发请求排队等待磁盘 I/O
+func (bs *BlacklistService) RecordFailure(platform string, provider string, requestID string) bool {
+	policy := bs.policy()
+	if !policy.Enabled {
+		return false
+	}
+	now := time.Now()
+	bs.mu.Lock()
+	state := bs.stateLocked(platform, provider)
+	state.level = policy.decayLevel(state.level, state.lastFailure, now)
+	state.lastFailure = now
+	state.failures++
+	if state.failures < policy.FailureThreshold {
+		bs.mu.Unlock()
+		return false
+	}
+	state.failures = 0
+	if state.level < policy.MaxLevel {
+		state.level++
+	}
+	state.until = now.Add(policy.levelDuration(state.level))
+	state.reason = fmt.Sprintf("连续失败 %d 次", policy.FailureThreshold)
+	state.manual = false
+	level, until, reason := state.level, state.until, state.reason
+	bs.mu.Unlock()
+
+	recordProviderEvent(ProviderEvent{
+		Platform:  platform,
+		EventType: ProviderEventBlacklist,
+		Provider:  provider,
+		Reason:    fmt.Sprintf("%s，L%d %s", reason, level, policy.levelDuration(level)),
+		RequestID: requestID,
+	})
+	fmt.Printf("[WARN] Provider %s 连续失败，拉黑 L%d 至 %s\n", provider, level, until.Format("15:04:05"))
+	return true
+}
+
+// RecordSuccess 记录一次成功：清空连续失败计数，按配置决定是否清零等级
+func (bs *BlacklistService) RecordSuccess(platform string, provider string, requestID string) {
+	// 绝大多数请求发往没有失败记录的 provider，这种情况不读取配置
+	bs.mu.Lock()
+	_, ok := bs.states[blacklistKey(platform, provider)]
+	bs.mu.Unlock()
+	if !ok {
+		return
+	}
+	policy := bs.policy()
+
+	bs.mu.Lock()
+	state, ok := bs.states[blacklistKey(platform, provider)]
+	if !ok {
+		bs.mu.Unlock()
+		return
+	}
+	state.failures = 0
+	// 手动拉黑不会因为兜底请求成功而提前解除
+	if (state.manual && time.Now().Before(state.until)) || !policy.ResetOnSuccess {
+		bs.mu.Unlock()
+		return
+	}
+	level := state.level
+	state.level = 0
+	state.until = time.Time{}
+	bs.mu.Unlock()
+
+	if level > 0 {
+		recordProviderEvent(ProviderEvent{
+			Platform:  platform,
+			EventType: ProviderEventRecover,
+			Provider:  provider,
+			Reason:    fmt.Sprintf("请求成功，等级 L%d 清零", level),
+			RequestID: requestID,
+		})
+	}
+}
+
+// IsBlacklisted 判断 provider 当前是否处于拉黑期
+func (bs *BlacklistService) IsBlacklisted(platform string, provider string) bool {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	state, ok := bs.states[blacklistKey(platform, provider)]
+	return ok && time.Now().Before(state.until)
+}
+
+// BlacklistProvider 手动拉黑 provider 指定时长，reason 会展示在界面与切换通知中
+func (bs *BlacklistService) BlacklistProvider(platform string, provider string, durationSec int, reason string) (BlacklistEntry, error) {
+	if normalizeRelayScope(platform) == "" {
+		return BlacklistEntry{}, fmt.Errorf("unknown provider type: %s", platform)
+	}
+	provider = strings.TrimSpace(provider)
+	if provider == "" {
+		return BlacklistEntry{}, errors.New("provider 不能为空")
+	}
+	if durationSec <= 0 {
+		return BlacklistEntry{}, errors.New("拉黑时长必须大于 0")
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		reason = "手动拉黑"
+	}
+	now := time.Now()
+	bs.mu.Lock()
+	state := bs.stateLocked(platform, provider)
+	state.until = now.Add(time.Duration(durationSec) * time.Second)
+	state.reason = reason
+	state.manual = true
+	state.failures = 0
+	entry := entryFromState(blacklistKey(platform, provider), state, now)
+	bs.mu.Unlock()
+	recordProviderEvent(ProviderEvent{
+		Platform:  platform,
+		EventType: ProviderEventBlacklist,
+		Provider:  provider,
+		Reason:    fmt.Sprintf("手动拉黑 %s：%s", time.Duration(durationSec)*time.Second, reason),
+	})
+	return entry, nil
+}
+
+// UnblacklistProvider 提前解除拉黑（手动或自动），并清零升级等级
+func (bs *BlacklistService) UnblacklistProvider(platform string, provider string) error {
+	bs.mu.Lock()
+	key := blacklistKey(platform, provider)
+	_, ok := bs.states[key]
+	delete(bs.states, key)
+	bs.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("provider %s 未被拉黑", provider)
+	}
+	recordProviderEvent(ProviderEvent{
+		Platform:  platform,
+		EventType: ProviderEventUnblacklist,
+		Provider:  provider,
+		Reason:    "手动解除拉黑",
+	})
+	return nil
+}
+
+// SetRecoveryHandler 设置 provider 恢复可用时的回调（由 main 转为前端事件/通知）
+func (bs *BlacklistService) SetRecoveryHandler(handler func(BlacklistRecovery)) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	bs.recoveryHandler = handler
+}
+
+// RecoverFromHealthCheck 健康检查通过时提前解除自动拉黑；手动拉黑不受影响
+func (bs *BlacklistService) RecoverFromHealthCheck(platform string, provider string) bool {
+	now := time.Now()
+	bs.mu.Lock()
+	state, ok := bs.states[blacklistKey(platform, provider)]
+	if !ok || state.manual || !now.Before(state.until) {
+		bs.mu.Unlock()
+		return false
+	}
+	state.until = time.Time{}
+	state.failures = 0
+	recovery := BlacklistRecovery{
+		Platform:    normalizeRelayScope(platform),
+		Provider:    provider,
+		Level:       state.level,
+		Source:      RecoverySourceHealthCheck,
+		Reason:      fmt.Sprintf("健康检查通过，提前解除 L%d 拉黑", state.level),
+		RecoveredAt: now,
+	}
+	handler := bs.recoveryHandler
+	bs.mu.Unlock()
+	recordProviderEvent(ProviderEvent{
+		Platform:  platform,
+		EventType: ProviderEventRecover,
+		Provider:  provider,
+		Reason:    recovery.Reason,
+	})
+	if handler != nil {
+		handler(recovery)
+	}
+	return true
+}
+
+// AutoRecoverExpired 把拉黑已到期的 provider 标记为恢复并逐个回调；升级等级保留，按衰减规则下降
+func (bs *BlacklistService) AutoRecoverExpired() []BlacklistRecovery {
+	now := time.Now()
+	bs.mu.Lock()
+	recoveries := make([]BlacklistRecovery, 0)
+	for key, state := range bs.states {
+		if state.until.IsZero() || now.Before(state.until) {
+			continue
+		}
+		entry := entryFromState(key, state, now)
+		recovery := BlacklistRecovery{
+			Platform:    entry.Platform,
+			Provider:    entry.Provider,
+			Level:       state.level,
+			Source:      RecoverySourceExpired,
+			Reason:      fmt.Sprintf("L%d 拉黑到期，自动恢复", state.level),
+			RecoveredAt: now,
+		}
+		if state.manual {
+			recovery.Reason = "手动拉黑到期，自动恢复"
+		}
+		state.until = time.Time{}
+		state.manual = false
+		recoveries = append(recoveries, recovery)
+	}
+	handler := bs.recoveryHandler
+	bs.mu.Unlock()
+	for _, recovery := range recoveries {
+		recordProviderEvent(ProviderEvent{
+			Platform:  recovery.Platform,
+			EventType: ProviderEventRecover,
+			Provider:  recovery.Provider,
+			Reason:    recovery.Reason,
+		})
+	}
+	sort.Slice(recoveries, func(i, j int) bool {
+		if recoveries[i].Platform != recoveries[j].Platform {
+			return recoveries[i].Platform < recoveries[j].Platform
+		}
+		return recoveries[i].Provider < recoveries[j].Provider
+	})
+	if handler != nil {
+		for _, recovery := range recoveries {
+			handler(recovery)
+		}
+	}
+	return recoveries
+}
+
+// blacklistEntry 返回 provider 当前的拉黑信息
+func (bs *BlacklistService) blacklistEntry(platform string, provider string) (BlacklistEntry, bool) {
+	now := time.Now()
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	key := blacklistKey(platform, provider)
+	state, ok := bs.states[key]
+	if !ok || !now.Before(state.until) {
+		return BlacklistEntry{}, false
+	}
+	return entryFromState(key, state, now), true
+}
+
+// ListBlacklist 返回当前处于拉黑期的 provider
+func (bs *BlacklistService) ListBlacklist(platform string) []BlacklistEntry {
+	platform = normalizeRelayScope(platform)
+	now := time.Now()
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	entries := make([]BlacklistEntry, 0)
+	for key, state := range bs.states {
+		if !now.Before(state.until) {
+			continue
+		}
+		entry := entryFromState(key, state, now)
+		if platform != "" && entry.Platform != platform {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Until.Before(entries[j].Until) })
+	return entries
+}
+
+// GetPolicy 返回当前生效的拉黑升级曲线
+func (bs *BlacklistService) GetPolicy() BlacklistPolicy {
+	return bs.policy()
+}
+
+func (bs *BlacklistService) policy() BlacklistPolicy {
+	if bs.appSettings == nil {
+		return defaultBlacklistPolicy()
+	}
+	settings, err := bs.appSettings.GetAppSettings()
+	if err != nil {
+		return defaultBlacklistPolicy()
+	}
+	return normalizeBlacklistPolicy(settings.Blacklist)
+}
+
+func (bs *BlacklistService) stateLocked(platform string, provider string) *providerHealthState {
+	key := blacklistKey(platform, provider)
+	state, ok := bs.states[key]
+	if !ok {
+		state = &providerHealthState{}
+		bs.states[key] = state
+	}
+	return state
+}
+
+func entryFromState(key string, state *providerHealthState, now time.Time) BlacklistEntry {
+	kind, name, _ := strings.Cut(key, ":")
+	return BlacklistEntry{
+		Platform:     kind,
+		Provider:     name,
+		Level:        state.level,
+		Until:        state.until,
+		RemainingSec: int64(state.until.Sub(now).Seconds()),
+		Failures:     state.failures,
+		Reason:       state.reason,
+		Manual:       state.manual,
+	}
+}
+
+func blacklistKey(platform string, provider string) string {
+	return normalizeRelayScope(platform) + ":" + provider
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBlacklistPolicyLadder(t *testing.T) {
+	policy := normalizeBlacklistPolicy(BlacklistPolicy{
+		Enabled:      true,
+		Durations:    []int{60, 0, 600},
+		DecayMinutes: 30,
+	})
+	if policy.FailureThreshold != 3 {
+		t.Fatalf("阈值默认值：实际 %d，期望 3", policy.FailureThreshold)
+	}
+	if policy.MaxLevel != 2 {
+		t.Fatalf("最高等级应等于有效时长数：实际 %d", policy.MaxLevel)
+	}
+	if got := policy.levelDuration(5); got != 10*time.Minute {
+		t.Fatalf("超出长度应沿用最后一级：实际 %v", got)
+	}
+
+	now := time.Now()
+	if got := policy.decayLevel(2, now.Add(-45*time.Minute), now); got != 1 {
+		t.Fatalf("45 分钟应衰减一级：实际 %d", got)
+	}
+	if got := policy.decayLevel(2, now.Add(-2*time.Hour), now); got != 0 {
+		t.Fatalf("2 小时应衰减到 0：实际 %d", got)
+	}
+}
+
+func TestBlacklistServiceEscalation(t *testing.T) {
+	bs := NewBlacklistService(nil)
+	for i := 0; i < 2; i++ {
+		if bs.RecordFailure("claude", "A", "") {
+			t.Fatalf("第 %d 次失败不应拉黑", i+1)
+		}
+	}
+	if !bs.RecordFailure("claude", "A", "") || !bs.IsBlacklisted("claude", "A") {
+		t.Fatal("连续 3 次失败应拉黑")
+	}
+	if bs.IsBlacklisted("codex", "A") {
+		t.Fatal("不同平台的同名 provider 不应受影响")
+	}
+	bs.RecordSuccess("claude", "A", "")
+	if bs.IsBlacklisted("claude", "A") {
+		t.Fatal("成功后应解除拉黑")
+	}
+}
+
+func TestBlacklistAutoRecoverExpired(t *testing.T) {
+	bs := NewBlacklistService(nil)
+	var notified []BlacklistRecovery
+	bs.SetRecoveryHandler(func(recovery BlacklistRecovery) {
+		notified = append(notified, recovery)
+	})
+	if _, err := bs.BlacklistProvider("claude", "A", 60, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bs.BlacklistProvider("claude", "B", 60, ""); err != nil {
+		t.Fatal(err)
+	}
+	bs.mu.Lock()
+	bs.states[blacklistKey("claude", "A")].until = time.Now().Add(-time.Second)
+	bs.mu.Unlock()
+
+	recoveries := bs.AutoRecoverExpired()
+	if len(recoveries) != 1 || recoveries[0].Provider != "A" || recoveries[0].Source != RecoverySourceExpired {
+		t.Fatalf("只应恢复已到期的 A：%+v", recoveries)
+	}
+	if len(notified) != 1 {
+		t.Fatalf("恢复回调次数：实际 %d，期望 1", len(notified))
+	}
+	if again := bs.AutoRecoverExpired(); len(again) != 0 {
+		t.Fatalf("同一次到期不应重复恢复：%+v", again)
+	}
+	if !bs.IsBlacklisted("claude", "B") {
+		t.Fatal("未到期的 B 应仍处于拉黑期")
+	}
+}
+
+func TestBlacklistCustomScopesIndependent(t *testing.T) {
+	bs := NewBlacklistService(nil)
+	if _, err := bs.BlacklistProvider("custom/aider", "A", 60, ""); err != nil {
+		t.Fatal(err)
+	}
+	if !bs.IsBlacklisted("custom/aider", "A") {
+		t.Fatal("custom/aider 的 A 应被拉黑")
+	}
+	for _, scope := range []string{"custom/opencode", "gemini", "claude"} {
+		if bs.IsBlacklisted(scope, "A") {
+			t.Fatalf("%s 的同名 provider 不应受影响", scope)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		bs.RecordFailure("custom/opencode", "B", "")
+	}
+	if !bs.IsBlacklisted("custom/opencode", "B") || bs.IsBlacklisted("custom/aider", "B") {
+		t.Fatal("自动拉黑应只作用于失败所在的自定义工具")
+	}
+	entries := bs.ListBlacklist("custom/aider")
+	if len(entries) != 1 || entries[0].Platform != "custom/aider" || entries[0].Provider != "A" {
+		t.Fatalf("按自定义工具筛选拉黑列表：%+v", entries)
+	}
+	if _, err := bs.BlacklistProvider("unknown", "A", 60, ""); err == nil {
+		t.Fatal("未知平台应拒绝手动拉黑")
+	}
+}
@@ -0,0 +1,331 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/daodao97/xgo/xdb"
+)
+
+const (
+	requestBodyTable      = "request_body"
+	bodyLogPruneInterval  = time.Hour
+	bodyLogRedactedMarker = "[REDACTED]"
+)
+
+// BodyLoggingPolicy 完整请求/响应体记录（默认关闭），仅保存在本地数据库
+type BodyLoggingPolicy struct {
+	Claude bool `json:"claude"`
+	Codex  bool `json:"codex"`
+	// 单个请求体/响应体最多保存的大小（KB），超出部分截断
+	MaxBodyKB int `json:"max_body_kb"`
+	// 保留天数，过期后只删除请求体，request_log 中的元数据保留
+	RetentionDays int `json:"retention_days"`
+}
+
+// RequestBody 某次请求保存的完整请求体与响应体
+type RequestBody struct {
+	LogID        int64  `json:"log_id"`
+	RequestID    string `json:"request_id,omitempty"`
+	Platform     string `json:"platform"`
+	Provider     string `json:"provider"`
+	RequestBody  string `json:"request_body"`
+	ResponseBody string `json:"response_body"`
+	RequestSize  int    `json:"request_size"`
+	ResponseSize int    `json:"response_size"`
+	Truncated    bool   `json:"truncated"`
+	CreatedAt    string `json:"created_at"`
+}
+
+// bodyLoggingPolicy 由 BodyLogService 维护、relay 读取
+var bodyLoggingPolicy atomic.Pointer[BodyLoggingPolicy]
+
+var (
+	secretTokenPattern = regexp.MustCompile(`\b(sk-[A-Za-z0-9_\-]{16,}|eyJ[A-Za-z0-9_\-]{20,}\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+)`)
+	secretFieldPattern = regexp.MustCompile(`(?i)("(?:api[_-]?key|authorization|x-api-key|access_token|refresh_token|id_token)"\s*:\s*)"[^"]*"`)
+)
+
+type BodyLogService struct {
+	appSettings *AppSettingsService
+	mu          sync.Mutex
+	stopCh      chan struct{}
+}
+
+func NewBodyLogService(appSettings *AppSettingsService) *BodyLogService {
+	bls := &BodyLogService{appSettings: appSettings}
+	policy := defaultBodyLoggingPolicy()
+	if appSettings != nil {
+		if settings, err := appSettings.GetAppSettings(); err == nil {
+			policy = normalizeBodyLoggingPolicy(settings.BodyLogging)
+		}
+	}
+	bodyLoggingPolicy.Store(&policy)
+	return bls
+}
+
+func defaultBodyLoggingPolicy() BodyLoggingPolicy {
+	return BodyLoggingPolicy{
+		MaxBodyKB:     256,
+		RetentionDays: 7,
+	}
+}
+
+func normalizeBodyLoggingPolicy(policy BodyLoggingPolicy) BodyLoggingPolicy {
+	defaults := defaultBodyLoggingPolicy()
+	if policy.MaxBodyKB <= 0 {
+		policy.MaxBodyKB = defaults.MaxBodyKB
+	}
+	if policy.RetentionDays <= 0 {
+		policy.RetentionDays = defaults.RetentionDays
+	}
+	return policy
+}
+
+// GetPolicy 返回当前的请求体记录配置
+func (bls *BodyLogService) GetPolicy() BodyLoggingPolicy {
+	if policy := bodyLoggingPolicy.Load(); policy != nil {
+		return *policy
+	}
+	return defaultBodyLoggingPolicy()
+}
+
+// SavePolicy 保存请求体记录配置并立即生效
+func (bls *BodyLogService) SavePolicy(policy BodyLoggingPolicy) (BodyLoggingPolicy, error) {
+	policy = normalizeBodyLoggingPolicy(policy)
+	if bls.appSettings != nil {
+		if _, err := bls.appSettings.update(func(settings *AppSettings) {
+			settings.BodyLogging = policy
+		}); err != nil {
+			return policy, err
+		}
+	}
+	bodyLoggingPolicy.Store(&policy)
+	return policy, nil
+}
+
+// Start 启动定时清理过期请求体
+func (bls *BodyLogService) Start() error {
+	bls.mu.Lock()
+	defer bls.mu.Unlock()
+	if bls.stopCh != nil {
+		return nil
+	}
+	stopCh := make(chan struct{})
+	bls.stopCh = stopCh
+	go func() {
+		bls.prune()
+		ticker := time.NewTicker(bodyLogPruneInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				bls.prune()
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (bls *BodyLogService) Stop() error {
+	bls.mu.Lock()
+	defer bls.mu.Unlock()
+	if bls.stopCh != nil {
+		close(bls.stopCh)
+		bls.stopCh = nil
+	}
+	return nil
+}
+
+// PruneBodies 立即清理过期请求体
+func (bls *BodyLogService) PruneBodies() error {
+	return bls.prune()
+}
+
+func (bls *BodyLogService) prune() error {
+	cutoff := time.Now().AddDate(0, 0, -bls.GetPolicy().RetentionDays)
+	_, err := xdb.New(requestBodyTable).Delete(xdb.WhereLt("created_at", cutoff.UTC().Format(timeLayout)))
+	if err != nil && !errors.Is(err, xdb.ErrNotFound) && !isNoSuchTableErr(err) {
+		fmt.Printf("清理 request_body 失败: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+// GetRequestBody 返回某条日志保存的请求体与响应体
+func (ls *LogService) GetRequestBody(logID int64) (RequestBody, error) {
+	record, err := xdb.New(requestBodyTable).First(xdb.WhereEq("log_id", logID))
+	if err != nil {
+		if errors.Is(err, xdb.ErrNotFound) || isNoSuchTableErr(err) {
+			return RequestBody{}, fmt.Errorf("日志 %d 没有保存请求体", logID)
+		}
+		return RequestBody{}, err
+	}
+	request, err := gunzipBody(record.GetString("request_body"))
+	if err != nil {
+		return RequestBody{}, err
+	}
+	response, err := gunzipBody(record.GetString("response_body"))
+	if err != nil {
+		return RequestBody{}, err
+	}
+	return RequestBody{
+		LogID:        logID,
+		RequestID:    record.GetString("request_id"),
+		Platform:     record.GetString("platform"),
+		Provider:     record.GetString("provider"),
+		RequestBody:  string(request),
+		ResponseBody: string(response),
+		RequestSize:  record.GetInt("request_size"),
+		ResponseSize: record.GetInt("response_size"),
+		Truncated:    record.GetBool("truncated"),
+		CreatedAt:    record.GetString("created_at"),
+	}, nil
+}
+
+// bodyCapture 在转发过程中收集请求体与响应体
+type bodyCapture struct {
+	limit     int
+	request   []byte
+	response  bytes.Buffer
+	reqSize   int
+	respSize  int
+	truncated bool
+}
+
+// newBodyCapture 未开启对应平台的记录时返回 nil
+func newBodyCapture(kind string, request []byte) *bodyCapture {
+	policy := bodyLoggingPolicy.Load()
+	if policy == nil {
+		return nil
+	}
+	switch normalizePresetKind(kind) {
+	case "claude":
+		if !policy.Claude {
+			return nil
+		}
+	case "codex":
+		if !policy.Codex {
+			return nil
+		}
+	default:
+		return nil
+	}
+	capture := &bodyCapture{limit: policy.MaxBodyKB * 1024, reqSize: len(request)}
+	capture.request = request
+	if len(request) > capture.limit {
+		capture.request = request[:capture.limit]
+		capture.truncated = true
+	}
+	return capture
+}
+
+func (bc *bodyCapture) appendResponse(data []byte) {
+	bc.respSize += len(data)
+	remaining := bc.limit - bc.response.Len()
+	if remaining <= 0 {
+		bc.truncated = true
+		return
+	}
+	if len(data) > remaining {
+		data = data[:remaining]
+		bc.truncated = true
+	}
+	bc.response.Write(data)
+}
+
+// save 脱敏、压缩后写入 request_body
+func (bc *bodyCapture) save(logID int64, entry *ReqeustLog, secrets ...string) {
+	request, err := gzipBody(redactSecrets(bc.request, secrets...))
+	if err != nil {
+		fmt.Printf("压缩请求体失败: %v\n", err)
+		return
+	}
+	response, err := gzipBody(redactSecrets(bc.response.Bytes(), secrets...))
+	if err != nil {
+		fmt.Printf("压缩响应体失败: %v\n", err)
+		return
+	}
+	if _, err := xdb.New(requestBodyTable).Insert(xdb.Record{
+		"log_id":        logID,
+		"request_id":    entry.RequestID,
+		"platform":      entry.Platform,
+		"provider":      entry.Provider,
+		"request_body":  request,
+		"response_body": response,
+		"request_size":  bc.reqSize,
+		"response_size": bc.respSize,
+		"truncated":     boolToInt(bc.truncated),
+	}); err != nil {
+		fmt.Printf("写入 request_body 失败: %v\n", err)
+	}
+}
+
+// redactSecrets 移除请求体中的密钥：已知的 Key、常见密钥格式与敏感字段
+func redactSecrets(body []byte, secrets ...string) []byte {
+	for _, secret := range secrets {
+		if len(secret) < 8 {
+			continue
+		}
+		body = bytes.ReplaceAll(body, []byte(secret), []byte(bodyLogRedactedMarker))
+	}
+	body = secretFieldPattern.ReplaceAll(body, []byte(`${1}"`+bodyLogRedactedMarker+`"`))
+	return secretTokenPattern.ReplaceAll(body, []byte(bodyLogRedactedMarker))
+}
+
+func gzipBody(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(body); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func gunzipBody(data string) ([]byte, error) {
+	if data == "" {
+		return nil, nil
+	}
+	reader, err := gzip.NewReader(bytes.NewReader([]byte(data)))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+func ensureRequestBodyTable() error {
+	db, err := xdb.DB("default")
+	if err != nil {
+		return err
+	}
+	const createTableSQL = `CREATE TABLE IF NOT EXISTS request_body (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		log_id INTEGER,
+		request_id TEXT,
+		platform TEXT,
+		provider TEXT,
+		request_body BLOB,
+		response_body BLOB,
+		request_size INTEGER DEFAULT 0,
+		response_size INTEGER DEFAULT 0,
+		truncated INTEGER DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return err
+	}
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_request_body_log ON request_body (log_id)`)
+	return err
+}
@@ -0,0 +1,29 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactSecrets(t *testing.T) {
+	body := []byte(`{"api_key":"abc","metadata":{"user_id":"u1"},"text":"key is my-provider-secret-123 and sk-ant-REDACTED"}`)
+	got := string(redactSecrets(body, "my-provider-secret-123", "short"))
+
+	for _, leaked := range []string{`"abc"`, "my-provider-secret-123", "sk-ant-api03"} {
+		if strings.Contains(got, leaked) {
+			t.Fatalf("redactSecrets leaked %q: %s", leaked, got)
+		}
+	}
+	if !strings.Contains(got, `"user_id":"u1"`) {
+		t.Fatalf("redactSecrets removed non-secret field: %s", got)
+	}
+}
+
+func TestBodyCaptureTruncates(t *testing.T) {
+	capture := &bodyCapture{limit: 4}
+	capture.appendResponse([]byte("abc"))
+	capture.appendResponse([]byte("def"))
+	if capture.response.String() != "abcd" || !capture.truncated || capture.respSize != 6 {
+		t.Fatalf("unexpected capture state: %q truncated=%v size=%d", capture.response.String(), capture.truncated, capture.respSize)
+	}
+}
@@ -0,0 +1,448 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/daodao97/xgo/xdb"
+)
+
+const budgetCheckInterval = time.Minute
+
+var (
+	budgetPeriods           = []string{"day", "week", "month"}
+	defaultBudgetThresholds = []int{50, 80, 100}
+)
+
+// SpendingBudget 费用预算，金额以 Currency 计，0 表示该周期不限制
+type SpendingBudget struct {
+	DailyLimit   float64 `json:"daily_limit"`
+	WeeklyLimit  float64 `json:"weekly_limit"`
+	MonthlyLimit float64 `json:"monthly_limit"`
+	Currency     string  `json:"currency"`
+	// 告警阈值（预算的百分比），每个周期内每个阈值只告警一次
+	Thresholds []int `json:"thresholds"`
+	// 任一周期超出预算后拒绝 relay 请求，直到进入下一个周期或调高预算
+	HardStop bool `json:"hard_stop"`
+	// 按平台（claude / codex / 自定义 CLI）单独设置的预算，与总预算同时生效
+	Buckets []BudgetBucket `json:"buckets"`
+}
+
+// BudgetBucket 某个平台独立的预算，币种、告警阈值与硬上限沿用总预算
+type BudgetBucket struct {
+	Platform     string  `json:"platform"`
+	DailyLimit   float64 `json:"daily_limit"`
+	WeeklyLimit  float64 `json:"weekly_limit"`
+	MonthlyLimit float64 `json:"monthly_limit"`
+}
+
+// BudgetStatus 某个周期的预算使用情况，金额以预算币种计
+type BudgetStatus struct {
+	// 平台预算对应的平台，总预算为空
+	Platform string  `json:"platform,omitempty"`
+	Period   string  `json:"period"`
+	Limit    float64 `json:"limit"`
+	Spent    float64 `json:"spent"`
+	Percent  float64 `json:"percent"`
+	Currency string  `json:"currency"`
+	Exceeded bool    `json:"exceeded"`
+}
+
+// BudgetAlert 预算使用达到告警阈值
+type BudgetAlert struct {
+	BudgetStatus
+	Threshold int  `json:"threshold"`
+	Blocked   bool `json:"blocked"`
+}
+
+// spendingBudget 由 BudgetService 维护，LogService 预测费用时读取
+var spendingBudget atomic.Pointer[SpendingBudget]
+
+// budgetBlockReasons 超出预算且开启硬上限时的拒绝原因，key 为平台（总预算为空），由 BudgetService 维护、relay 读取
+var budgetBlockReasons = struct {
+	sync.RWMutex
+	reasons map[string]string
+}{reasons: make(map[string]string)}
+
+type BudgetService struct {
+	appSettings  *AppSettingsService
+	logService   *LogService
+	mu           sync.Mutex
+	stopCh       chan struct{}
+	alertHandler func(BudgetAlert)
+	// 各周期已告警的最高阈值，key 为 profile:平台:周期:周期起始日期
+	alerted map[string]int
+}
+
+func NewBudgetService(appSettings *AppSettingsService, logService *LogService) *BudgetService {
+	bs := &BudgetService{
+		appSettings: appSettings,
+		logService:  logService,
+		alerted:     make(map[string]int),
+	}
+	bs.loadBudget()
+	return bs
+}
+
+func (bs *BudgetService) loadBudget() {
+	budget := normalizeSpendingBudget(SpendingBudget{})
+	if bs.appSettings != nil {
+		if settings, err := bs.appSettings.GetAppSettings(); err == nil {
+			budget = normalizeSpendingBudget(profileBudget(settings, currentProfileID()))
+		}
+	}
+	spendingBudget.Store(&budget)
+}
+
+// ReloadBudget 切换 profile 后改用新 profile 的预算，并按新 profile 的用量重新检查
+func (bs *BudgetService) ReloadBudget() {
+	bs.loadBudget()
+	bs.check()
+}
+
+// profileBudget 默认 profile 沿用 settings.Budget，其他 profile 的预算各自独立
+func profileBudget(settings AppSettings, id string) SpendingBudget {
+	if id == defaultProfileID {
+		return settings.Budget
+	}
+	return settings.ProfileBudgets[id]
+}
+
+func normalizeSpendingBudget(budget SpendingBudget) SpendingBudget {
+	budget.Currency = strings.ToUpper(strings.TrimSpace(budget.Currency))
+	if budget.Currency == "" {
+		budget.Currency = currentCurrency().Currency
+	}
+	seen := make(map[int]struct{}, len(budget.Thresholds))
+	thresholds := make([]int, 0, len(budget.Thresholds))
+	for _, threshold := range budget.Thresholds {
+		if threshold <= 0 || threshold > 1000 {
+			continue
+		}
+		if _, ok := seen[threshold]; ok {
+			continue
+		}
+		seen[threshold] = struct{}{}
+		thresholds = append(thresholds, threshold)
+	}
+	if len(thresholds) == 0 {
+		thresholds = append(thresholds, defaultBudgetThresholds...)
+	}
+	sort.Ints(thresholds)
+	budget.Thresholds = thresholds
+
+	buckets := make([]BudgetBucket, 0, len(budget.Buckets))
+	seenPlatforms := make(map[string]struct{}, len(budget.Buckets))
+	for _, bucket := range budget.Buckets {
+		bucket.Platform = strings.ToLower(strings.TrimSpace(bucket.Platform))
+		if bucket.Platform == "" {
+			continue
+		}
+		if _, ok := seenPlatforms[bucket.Platform]; ok {
+			continue
+		}
+		seenPlatforms[bucket.Platform] = struct{}{}
+		buckets = append(buckets, bucket)
+	}
+	budget.Buckets = buckets
+	return budget
+}
+
+// SetAlertHandler 设置预算达到告警阈值时的回调（由 main 转为前端事件/系统通知）
+func (bs *BudgetService) SetAlertHandler(handler func(BudgetAlert)) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	bs.alertHandler = handler
+}
+
+// GetBudget 返回费用预算
+func (bs *BudgetService) GetBudget() SpendingBudget {
+	return currentBudget()
+}
+
+// SaveBudget 保存费用预算并立即重新检查
+func (bs *BudgetService) SaveBudget(budget SpendingBudget) (SpendingBudget, error) {
+	if budget.DailyLimit < 0 || budget.WeeklyLimit < 0 || budget.MonthlyLimit < 0 {
+		return budget, errors.New("预算不能为负数")
+	}
+	for _, bucket := range budget.Buckets {
+		if bucket.DailyLimit < 0 || bucket.WeeklyLimit < 0 || bucket.MonthlyLimit < 0 {
+			return budget, fmt.Errorf("%s 的预算不能为负数", bucket.Platform)
+		}
+	}
+	budget = normalizeSpendingBudget(budget)
+	if _, ok := currentCurrency().Rates[budget.Currency]; !ok {
+		return budget, fmt.Errorf("缺少 %s 的汇率", budget.Currency)
+	}
+	if bs.appSettings != nil {
+		id := currentProfileID()
+		if _, err := bs.appSettings.update(func(settings *AppSettings) {
+			if id == defaultProfileID {
+				settings.Budget = budget
+				return
+			}
+			if settings.ProfileBudgets == nil {
+				settings.ProfileBudgets = make(map[string]SpendingBudget)
+			}
+			settings.ProfileBudgets[id] = budget
+		}); err != nil {
+			return budget, err
+		}
+	}
+	spendingBudget.Store(&budget)
+	bs.check()
+	return budget, nil
+}
+
+// GetBudgetStatus 返回总预算与各平台预算的使用情况，未设置预算的周期不返回
+func (bs *BudgetService) GetBudgetStatus() ([]BudgetStatus, error) {
+	spent, err := bs.logService.budgetSpend(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return budgetStatuses(currentBudget(), spent), nil
+}
+
+// Start 启动定时预算检查
+func (bs *BudgetService) Start() error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if bs.stopCh != nil {
+		return nil
+	}
+	stopCh := make(chan struct{})
+	bs.stopCh = stopCh
+	go func() {
+		bs.check()
+		ticker := time.NewTicker(budgetCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				bs.check()
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (bs *BudgetService) Stop() error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if bs.stopCh != nil {
+		close(bs.stopCh)
+		bs.stopCh = nil
+	}
+	return nil
+}
+
+// check 统计各周期费用，更新硬上限状态，并对新达到的阈值告警
+func (bs *BudgetService) check() {
+	now := time.Now()
+	spent, err := bs.logService.budgetSpend(now)
+	if err != nil {
+		fmt.Printf("[WARN] 统计预算使用失败: %v\n", err)
+		return
+	}
+	budget := currentBudget()
+	statuses := budgetStatuses(budget, spent)
+
+	reasons := make(map[string]string)
+	if budget.HardStop {
+		for _, status := range statuses {
+			if !status.Exceeded {
+				continue
+			}
+			if _, ok := reasons[status.Platform]; ok {
+				continue
+			}
+			scope := ""
+			if status.Platform != "" {
+				scope = " " + status.Platform + " "
+			}
+			reasons[status.Platform] = fmt.Sprintf("已超出%s%s预算（%s / %s），Code Switch 已暂停转发请求",
+				budgetPeriodLabel(status.Period), scope, formatCost(status.Spent, status.Currency), formatCost(status.Limit, status.Currency))
+		}
+	}
+	budgetBlockReasons.Lock()
+	budgetBlockReasons.reasons = reasons
+	budgetBlockReasons.Unlock()
+
+	alerts := make([]BudgetAlert, 0)
+	profile := currentProfileID()
+	bs.mu.Lock()
+	for _, status := range statuses {
+		threshold := crossedThreshold(budget.Thresholds, status.Percent)
+		key := profile + ":" + status.Platform + ":" + status.Period + ":" + budgetPeriodStart(status.Period, now).Format("2006-01-02")
+		if threshold == 0 || threshold <= bs.alerted[key] {
+			continue
+		}
+		bs.alerted[key] = threshold
+		_, blocked := reasons[status.Platform]
+		alerts = append(alerts, BudgetAlert{
+			BudgetStatus: status,
+			Threshold:    threshold,
+			Blocked:      blocked,
+		})
+	}
+	handler := bs.alertHandler
+	bs.mu.Unlock()
+	if handler == nil {
+		return
+	}
+	for _, alert := range alerts {
+		handler(alert)
+	}
+}
+
+// budgetSpend 统计当前 profile 当前日/周/月的费用（美元，含探测费用），key 为平台，空字符串为所有平台合计
+func (ls *LogService) budgetSpend(now time.Time) (map[string]map[string]float64, error) {
+	since := now
+	for _, period := range budgetPeriods {
+		if start := budgetPeriodStart(period, now); start.Before(since) {
+			since = start
+		}
+	}
+	// 预算针对真实费用：演示模式下也统计当前 profile 的日志表；探测请求单独记录但同样产生费用
+	records := make([]xdb.Record, 0)
+	for _, table := range []string{activeRequestLogTable(), probeLogTable} {
+		rows, err := xdb.New(table).Selects(
+			xdb.WhereGte("created_at", since.UTC().Format(timeLayout)),
+			xdb.Field(
+				"platform",
+				"provider",
+				"model",
+				"input_tokens",
+				"output_tokens",
+				"cache_create_tokens",
+				"cache_read_tokens",
+				"created_at",
+			),
+		)
+		if err != nil && !errors.Is(err, xdb.ErrNotFound) && !isNoSuchTableErr(err) {
+			return nil, err
+		}
+		records = append(records, rows...)
+	}
+	spent := map[string]map[string]float64{"": {}}
+	for _, record := range records {
+		createdAt, ok := parseCreatedAt(record)
+		if !ok {
+			continue
+		}
+		cost := ls.recordCost(record)
+		platform := strings.ToLower(record.GetString("platform"))
+		if spent[platform] == nil {
+			spent[platform] = make(map[string]float64, len(budgetPeriods))
+		}
+		for _, period := range budgetPeriods {
+			if !createdAt.Before(budgetPeriodStart(period, now)) {
+				spent[""][period] += cost
+				if platform != "" {
+					spent[platform][period] += cost
+				}
+			}
+		}
+	}
+	return spent, nil
+}
+
+// budgetStatuses 计算总预算与各平台预算中设置了金额的周期的使用情况，spent 为美元
+func budgetStatuses(budget SpendingBudget, spent map[string]map[string]float64) []BudgetStatus {
+	buckets := append([]BudgetBucket{{
+		DailyLimit:   budget.DailyLimit,
+		WeeklyLimit:  budget.WeeklyLimit,
+		MonthlyLimit: budget.MonthlyLimit,
+	}}, budget.Buckets...)
+	statuses := make([]BudgetStatus, 0, len(buckets)*len(budgetPeriods))
+	for _, bucket := range buckets {
+		statuses = append(statuses, bucketStatuses(bucket, budget.Currency, spent[bucket.Platform])...)
+	}
+	return statuses
+}
+
+func bucketStatuses(bucket BudgetBucket, currency string, spent map[string]float64) []BudgetStatus {
+	limits := map[string]float64{
+		"day":   bucket.DailyLimit,
+		"week":  bucket.WeeklyLimit,
+		"month": bucket.MonthlyLimit,
+	}
+	statuses := make([]BudgetStatus, 0, len(budgetPeriods))
+	for _, period := range budgetPeriods {
+		limit := limits[period]
+		if limit <= 0 {
+			continue
+		}
+		status := BudgetStatus{Platform: bucket.Platform, Period: period, Limit: limit, Currency: currency}
+		amount, ok := usdTo(spent[period], currency)
+		if !ok {
+			// 缺少汇率时按美元比较
+			status.Limit = toUSD(limit, currency)
+			status.Currency = "USD"
+		}
+		status.Spent = amount
+		status.Percent = status.Spent / status.Limit * 100
+		status.Exceeded = status.Spent >= status.Limit
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// crossedThreshold 返回已达到的最高阈值，未达到任何阈值时返回 0
+func crossedThreshold(thresholds []int, percent float64) int {
+	crossed := 0
+	for _, threshold := range thresholds {
+		if percent >= float64(threshold) && threshold > crossed {
+			crossed = threshold
+		}
+	}
+	return crossed
+}
+
+func budgetPeriodStart(period string, now time.Time) time.Time {
+	day := startOfDay(now)
+	switch period {
+	case "week":
+		// 以周一为一周的开始
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case "month":
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	default:
+		return day
+	}
+}
+
+func budgetPeriodLabel(period string) string {
+	switch period {
+	case "week":
+		return "本周"
+	case "month":
+		return "本月"
+	default:
+		return "今日"
+	}
+}
+
+func currentBudget() SpendingBudget {
+	if budget := spendingBudget.Load(); budget != nil {
+		return *budget
+	}
+	return normalizeSpendingBudget(SpendingBudget{})
+}
+
+// budgetBlocked 总预算或该平台预算超出且开启硬上限时返回拒绝原因
+func budgetBlocked(platform string) string {
+	budgetBlockReasons.RLock()
+	defer budgetBlockReasons.RUnlock()
+	if reason := budgetBlockReasons.reasons[""]; reason != "" {
+		return reason
+	}
+	return budgetBlockReasons.reasons[strings.ToLower(platform)]
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daodao97/xgo/xdb"
+)
+
+func TestNormalizeSpendingBudgetThresholds(t *testing.T) {
+	budget := normalizeSpendingBudget(SpendingBudget{Currency: "usd", Thresholds: []int{90, 0, 50, 90, 120}})
+	if budget.Currency != "USD" {
+		t.Fatalf("expected USD, got %s", budget.Currency)
+	}
+	want := []int{50, 90, 120}
+	if len(budget.Thresholds) != len(want) {
+		t.Fatalf("unexpected thresholds: %v", budget.Thresholds)
+	}
+	for i := range want {
+		if budget.Thresholds[i] != want[i] {
+			t.Fatalf("unexpected thresholds: %v", budget.Thresholds)
+		}
+	}
+	if defaults := normalizeSpendingBudget(SpendingBudget{}).Thresholds; len(defaults) != 3 {
+		t.Fatalf("expected default thresholds, got %v", defaults)
+	}
+}
+
+func TestBudgetStatusesAndThresholds(t *testing.T) {
+	budget := SpendingBudget{DailyLimit: 10, MonthlyLimit: 100, Currency: "USD", Thresholds: []int{50, 80, 100}}
+	statuses := budgetStatuses(budget, map[string]map[string]float64{"": {"day": 8.5, "week": 20, "month": 120}})
+	if len(statuses) != 2 {
+		t.Fatalf("expected day and month statuses, got %+v", statuses)
+	}
+	if statuses[0].Period != "day" || statuses[0].Exceeded || crossedThreshold(budget.Thresholds, statuses[0].Percent) != 80 {
+		t.Fatalf("unexpected day status: %+v", statuses[0])
+	}
+	if statuses[1].Period != "month" || !statuses[1].Exceeded || crossedThreshold(budget.Thresholds, statuses[1].Percent) != 100 {
+		t.Fatalf("unexpected month status: %+v", statuses[1])
+	}
+	if crossedThreshold(budget.Thresholds, 10) != 0 {
+		t.Fatal("expected no threshold crossed")
+	}
+}
+
+func TestBudgetStatusesPerPlatformBucket(t *testing.T) {
+	budget := normalizeSpendingBudget(SpendingBudget{
+		MonthlyLimit: 100,
+		Currency:     "USD",
+		Buckets: []BudgetBucket{
+			{Platform: " Codex ", DailyLimit: 5},
+			{Platform: "codex", DailyLimit: 50},
+			{Platform: "claude"},
+		},
+	})
+	if len(budget.Buckets) != 2 || budget.Buckets[0].Platform != "codex" {
+		t.Fatalf("unexpected buckets: %+v", budget.Buckets)
+	}
+	spent := map[string]map[string]float64{
+		"":      {"day": 6, "month": 30},
+		"codex": {"day": 6, "month": 6},
+	}
+	statuses := budgetStatuses(budget, spent)
+	if len(statuses) != 2 {
+		t.Fatalf("expected total month and codex day statuses, got %+v", statuses)
+	}
+	if statuses[0].Platform != "" || statuses[0].Exceeded {
+		t.Fatalf("unexpected total status: %+v", statuses[0])
+	}
+	if statuses[1].Platform != "codex" || statuses[1].Period != "day" || !statuses[1].Exceeded {
+		t.Fatalf("unexpected codex status: %+v", statuses[1])
+	}
+}
+
+func TestBudgetPeriodStartWeekBeginsMonday(t *testing.T) {
+	sunday := time.Date(2026, 10, 18, 15, 0, 0, 0, time.Local)
+	if start := budgetPeriodStart("week", sunday); start.Day() != 12 || start.Weekday() != time.Monday {
+		t.Fatalf("unexpected week start: %v", start)
+	}
+	monday := time.Date(2026, 10, 12, 9, 0, 0, 0, time.Local)
+	if start := budgetPeriodStart("week", monday); start.Day() != 12 {
+		t.Fatalf("unexpected week start: %v", start)
+	}
+}
+
+func TestBudgetSpendFollowsActiveProfile(t *testing.T) {
+	useTestDB(t)
+	db, err := xdb.DB("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ensureLogTableSchema(db, profileRequestLogTable("work")); err != nil {
+		t.Fatal(err)
+	}
+	previous := currentProfileID()
+	t.Cleanup(func() {
+		setCurrentProfileID(previous)
+		demoMode.Store(false)
+	})
+
+	now := time.Now()
+	record := xdb.Record{
+		"platform":      "claude",
+		"provider":      "relay",
+		"model":         "claude-sonnet-4-20250514",
+		"input_tokens":  100000,
+		"output_tokens": 20000,
+		"created_at":    now.UTC().Format(timeLayout),
+	}
+	if _, err := xdb.New(profileRequestLogTable("work")).Insert(record); err != nil {
+		t.Fatal(err)
+	}
+	ls := NewLogService()
+	cost := ls.recordCost(record)
+	if cost <= 0 {
+		t.Fatalf("测试模型应有定价，实际费用 %v", cost)
+	}
+
+	setCurrentProfileID(defaultProfileID)
+	spent, err := ls.budgetSpend(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spent[""]["day"] != 0 {
+		t.Fatalf("default profile 不应统计 work 的费用：%v", spent)
+	}
+
+	setCurrentProfileID("work")
+	demoMode.Store(true)
+	spent, err = ls.budgetSpend(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spent[""]["day"] != cost || spent["claude"]["month"] != cost {
+		t.Fatalf("work profile 的费用：%v，期望 %v", spent, cost)
+	}
+}
+
+func TestBudgetSpendIncludesProbeCost(t *testing.T) {
+	useTestDB(t)
+	now := time.Now()
+	record := xdb.Record{
+		"platform":      "claude",
+		"provider":      "relay",
+		"model":         "claude-sonnet-4-20250514",
+		"input_tokens":  100000,
+		"output_tokens": 20000,
+		"created_at":    now.UTC().Format(timeLayout),
+	}
+	if _, err := xdb.New(probeLogTable).Insert(record); err != nil {
+		t.Fatal(err)
+	}
+	ls := NewLogService()
+	cost := ls.recordCost(record)
+	if cost <= 0 {
+		t.Fatalf("测试模型应有定价，实际费用 %v", cost)
+	}
+	spent, err := ls.budgetSpend(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spent[""]["day"] != cost || spent["claude"]["day"] != cost {
+		t.Fatalf("探测费用应计入预算：%v，期望 %v", spent, cost)
+	}
+	if stats, err := ls.ProbeStats("claude", 1); err != nil || len(stats) != 1 {
+		t.Fatalf("探测用量仍应单独统计：%+v %v", stats, err)
+	}
+}
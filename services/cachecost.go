@@ -0,0 +1,138 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+
+	modelpricing "codeswitch/resources/model-pricing"
+
+	"github.com/daodao97/xgo/xdb"
+)
+
+// logSchemaVersion 日志数据迁移版本，记录在 PRAGMA user_version 中
+const logSchemaVersion = 1
+
+// CacheSavingsStat 单个 provider 的提示词缓存命中与节省费用，费用为当前展示币种
+type CacheSavingsStat struct {
+	Provider          string `json:"provider"`
+	Requests          int64  `json:"requests"`
+	InputTokens       int64  `json:"input_tokens"`
+	CacheCreateTokens int64  `json:"cache_create_tokens"`
+	CacheReadTokens   int64  `json:"cache_read_tokens"`
+	// 缓存命中率：缓存读取 token 占全部输入 token 的百分比
+	HitRate float64 `json:"hit_rate"`
+	// 实际费用，以及全部输入都不走缓存时的费用
+	ActualCost   float64 `json:"actual_cost"`
+	UncachedCost float64 `json:"uncached_cost"`
+	// 缓存节省的费用，缓存写入多于读取时可能为负
+	Savings float64 `json:"savings"`
+}
+
+// CacheSavingsByProvider 统计最近 days 天各 provider 的缓存命中率与节省费用，按节省费用降序
+func (ls *LogService) CacheSavingsByProvider(platform string, days int) ([]CacheSavingsStat, error) {
+	if days <= 0 {
+		days = 7
+	}
+	since := startOfDay(time.Now()).AddDate(0, 0, -(days - 1))
+	options := []xdb.Option{
+		xdb.WhereGte("created_at", since.UTC().Format(timeLayout)),
+		xdb.Field(
+			"provider",
+			"model",
+			"input_tokens",
+			"output_tokens",
+			"cache_create_tokens",
+			"cache_read_tokens",
+		),
+	}
+	if platform != "" {
+		options = append(options, xdb.WhereEq("platform", platform))
+	}
+	records, err := xdb.New(requestLogTable()).Selects(options...)
+	if err != nil {
+		if errors.Is(err, xdb.ErrNotFound) || isNoSuchTableErr(err) {
+			return []CacheSavingsStat{}, nil
+		}
+		return nil, err
+	}
+	providers := make(map[string]*CacheSavingsStat)
+	for _, record := range records {
+		name := record.GetString("provider")
+		stat := providers[name]
+		if stat == nil {
+			stat = &CacheSavingsStat{Provider: name}
+			providers[name] = stat
+		}
+		usage := modelpricing.UsageSnapshot{
+			InputTokens:       record.GetInt("input_tokens"),
+			OutputTokens:      record.GetInt("output_tokens"),
+			CacheCreateTokens: record.GetInt("cache_create_tokens"),
+			CacheReadTokens:   record.GetInt("cache_read_tokens"),
+		}
+		actual, uncached := ls.cacheCosts(record.GetString("provider"), record.GetString("model"), usage)
+		stat.Requests++
+		stat.InputTokens += int64(usage.InputTokens)
+		stat.CacheCreateTokens += int64(usage.CacheCreateTokens)
+		stat.CacheReadTokens += int64(usage.CacheReadTokens)
+		stat.ActualCost += actual
+		stat.UncachedCost += uncached
+	}
+	result := make([]CacheSavingsStat, 0, len(providers))
+	for _, stat := range providers {
+		if totalInput := stat.InputTokens + stat.CacheCreateTokens + stat.CacheReadTokens; totalInput > 0 {
+			stat.HitRate = float64(stat.CacheReadTokens) / float64(totalInput) * 100
+		}
+		stat.ActualCost, _ = displayCost(stat.ActualCost)
+		stat.UncachedCost, _ = displayCost(stat.UncachedCost)
+		stat.Savings = stat.UncachedCost - stat.ActualCost
+		result = append(result, *stat)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Savings != result[j].Savings {
+			return result[i].Savings > result[j].Savings
+		}
+		return result[i].Provider < result[j].Provider
+	})
+	return result, nil
+}
+
+// cacheSavings 缓存节省的费用（美元）
+func (ls *LogService) cacheSavings(provider string, model string, usage modelpricing.UsageSnapshot) float64 {
+	actual, uncached := ls.cacheCosts(provider, model, usage)
+	return uncached - actual
+}
+
+// cacheCosts 返回实际费用，以及缓存读写的 token 都按普通输入计费时的费用（美元）
+func (ls *LogService) cacheCosts(provider string, model string, usage modelpricing.UsageSnapshot) (float64, float64) {
+	actual := ls.calculateCost(provider, model, usage).TotalCost
+	if usage.CacheCreateTokens == 0 && usage.CacheReadTokens == 0 {
+		return actual, actual
+	}
+	uncached := ls.calculateCost(provider, model, modelpricing.UsageSnapshot{
+		InputTokens:  usage.InputTokens + usage.CacheCreateTokens + usage.CacheReadTokens,
+		OutputTokens: usage.OutputTokens,
+	}).TotalCost
+	return actual, uncached
+}
+
+// migrateLogData 按 PRAGMA user_version 执行一次性的日志数据迁移
+func migrateLogData(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	if version >= logSchemaVersion {
+		return nil
+	}
+	// v1：Codex 返回的 input_tokens 包含缓存命中的 token，旧数据按原值入库导致缓存部分被重复计费，
+	// 统一改为只记录未命中缓存的输入 token，与 Claude 的口径一致
+	if _, err := db.Exec(`UPDATE request_log
+		SET input_tokens = input_tokens - cache_read_tokens
+		WHERE platform = 'codex' AND cache_read_tokens > 0 AND input_tokens >= cache_read_tokens`); err != nil {
+		return err
+	}
+	_, err := db.Exec("PRAGMA user_version = 1")
+	return err
+}
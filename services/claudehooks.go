@@ -0,0 +1,395 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// claudeHookEvents Claude Code 支持的 hook 事件
+var claudeHookEvents = []string{
+	"PreToolUse",
+	"PostToolUse",
+	"UserPromptSubmit",
+	"Notification",
+	"Stop",
+	"SubagentStop",
+	"PreCompact",
+	"SessionStart",
+	"SessionEnd",
+}
+
+// claudeHookMatcherEvents 使用 matcher 的事件，其余事件的 matcher 会被忽略
+var claudeHookMatcherEvents = []string{"PreToolUse", "PostToolUse", "PreCompact", "SessionStart"}
+
+// ClaudeHook settings.json 中的一条 command hook。ID 由事件、matcher 与命令计算，内容变化后 ID 也会变化。
+// Matcher 为空表示匹配全部工具，Timeout 为 0 时使用 Claude Code 的默认超时
+type ClaudeHook struct {
+	ID      string `json:"id"`
+	Event   string `json:"event"`
+	Matcher string `json:"matcher"`
+	Type    string `json:"type"`
+	Command string `json:"command"`
+	Timeout int    `json:"timeout,omitempty"`
+}
+
+// ClaudeHookRecipe 内置的常用 hook
+type ClaudeHookRecipe struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Requires    []string   `json:"requires"`
+	Hook        ClaudeHook `json:"hook"`
+}
+
+// claudeHookRecipes 命令从 stdin 读取 Claude Code 传入的 JSON；PreToolUse 中 exit 2 会阻止工具调用并把 stderr 反馈给 Claude
+func claudeHookRecipes() []ClaudeHookRecipe {
+	return []ClaudeHookRecipe{
+		{
+			ID:          "format-on-edit",
+			Name:        "Format on edit",
+			Description: "Claude 修改文件后按扩展名运行 gofmt 或 prettier",
+			Requires:    []string{"jq"},
+			Hook: ClaudeHook{
+				Event:   "PostToolUse",
+				Matcher: "Edit|MultiEdit|Write",
+				Command: `f=$(jq -r '.tool_input.file_path // empty'); case "$f" in *.go) gofmt -w "$f" ;; *.js|*.jsx|*.ts|*.tsx|*.vue|*.css|*.scss|*.json|*.md) npx --no-install prettier --write "$f" ;; esac >/dev/null 2>&1; exit 0`,
+				Timeout: 30,
+			},
+		},
+		{
+			ID:          "block-dangerous-commands",
+			Name:        "Block dangerous commands",
+			Description: "阻止 rm -rf /、强制推送、git reset --hard 等危险的 Bash 命令",
+			Requires:    []string{"jq"},
+			Hook: ClaudeHook{
+				Event:   "PreToolUse",
+				Matcher: "Bash",
+				Command: `cmd=$(jq -r '.tool_input.command // empty'); if printf '%s' "$cmd" | grep -Eq 'rm -[a-zA-Z]*[rf][a-zA-Z]* +(/|~|\$HOME)( |$)|git push .*(-f|--force)( |$)|git reset --hard|mkfs|dd if=.* of=/dev/|chmod -R 777 /|:\(\)\{'; then echo "Blocked by hook: dangerous command" >&2; exit 2; fi`,
+				Timeout: 10,
+			},
+		},
+		{
+			ID:          "protect-sensitive-files",
+			Name:        "Protect sensitive files",
+			Description: "阻止修改 .env、密钥文件与 .git 目录",
+			Requires:    []string{"jq"},
+			Hook: ClaudeHook{
+				Event:   "PreToolUse",
+				Matcher: "Edit|MultiEdit|Write",
+				Command: `f=$(jq -r '.tool_input.file_path // empty'); case "$f" in *.env|*.env.*|*.pem|*.key|*/.git/*) echo "Blocked by hook: $f is protected" >&2; exit 2 ;; esac`,
+				Timeout: 10,
+			},
+		},
+		{
+			ID:          "log-bash-commands",
+			Name:        "Log Bash commands",
+			Description: "把 Claude 执行的 Bash 命令追加到 ~/.claude/bash-command-log.txt",
+			Requires:    []string{"jq"},
+			Hook: ClaudeHook{
+				Event:   "PostToolUse",
+				Matcher: "Bash",
+				Command: `jq -r '"\(.tool_input.command) - \(.tool_input.description // "")"' >> ~/.claude/bash-command-log.txt`,
+				Timeout: 10,
+			},
+		},
+		{
+			ID:          "notify-when-waiting",
+			Name:        "Desktop notification",
+			Description: "Claude 等待输入或请求权限时发送系统通知",
+			Hook: ClaudeHook{
+				Event:   "Notification",
+				Command: claudeNotifyCommand(),
+				Timeout: 10,
+			},
+		},
+	}
+}
+
+func claudeNotifyCommand() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return `osascript -e 'display notification "Claude Code is waiting for you" with title "Claude Code"'`
+	case "windows":
+		return `powershell -NoProfile -Command "[reflection.assembly]::loadwithpartialname('System.Windows.Forms') | Out-Null; $n = New-Object System.Windows.Forms.NotifyIcon; $n.Icon = [System.Drawing.SystemIcons]::Information; $n.Visible = $true; $n.ShowBalloonTip(5000, 'Claude Code', 'Claude Code is waiting for you', 'Info')"`
+	default:
+		return `notify-send "Claude Code" "Claude Code is waiting for you"`
+	}
+}
+
+// ListClaudeHookRecipes 返回内置的 hook 模板
+func (css *ClaudeSettingsService) ListClaudeHookRecipes() []ClaudeHookRecipe {
+	recipes := claudeHookRecipes()
+	for i := range recipes {
+		recipes[i].Hook.Type = "command"
+		recipes[i].Hook.ID = claudeHookID(recipes[i].Hook)
+	}
+	return recipes
+}
+
+// ListClaudeHooks 按事件顺序列出 settings.json 中的 hook
+func (css *ClaudeSettingsService) ListClaudeHooks() ([]ClaudeHook, error) {
+	raw, err := css.readRawSettings()
+	if err != nil {
+		return nil, err
+	}
+	hooks := flattenClaudeHooks(raw)
+	slices.SortStableFunc(hooks, func(a, b ClaudeHook) int {
+		return claudeHookEventIndex(a.Event) - claudeHookEventIndex(b.Event)
+	})
+	return hooks, nil
+}
+
+// SaveClaudeHook 新增或修改一条 hook，ID 为空时新增
+func (css *ClaudeSettingsService) SaveClaudeHook(hook ClaudeHook) (ClaudeHook, error) {
+	hook, err := normalizeClaudeHook(hook)
+	if err != nil {
+		return ClaudeHook{}, err
+	}
+	previousID := hook.ID
+	hook.ID = claudeHookID(hook)
+	err = css.updateClaudeHooks(func(raw map[string]any) error {
+		var entry map[string]any
+		if previousID != "" {
+			removed, ok := removeClaudeHook(raw, previousID)
+			if !ok {
+				return fmt.Errorf("未找到 hook %s", previousID)
+			}
+			entry = removed
+		}
+		if previousID != hook.ID && findClaudeHook(raw, hook.ID) {
+			return errors.New("相同的 hook 已存在")
+		}
+		insertClaudeHook(raw, hook, entry)
+		return nil
+	})
+	if err != nil {
+		return ClaudeHook{}, err
+	}
+	return hook, nil
+}
+
+// DeleteClaudeHook 删除一条 hook
+func (css *ClaudeSettingsService) DeleteClaudeHook(id string) error {
+	return css.updateClaudeHooks(func(raw map[string]any) error {
+		if _, ok := removeClaudeHook(raw, id); !ok {
+			return fmt.Errorf("未找到 hook %s", id)
+		}
+		return nil
+	})
+}
+
+// ApplyClaudeHookRecipe 把内置模板添加到 settings.json，已添加过时直接返回
+func (css *ClaudeSettingsService) ApplyClaudeHookRecipe(id string) (ClaudeHook, error) {
+	for _, recipe := range css.ListClaudeHookRecipes() {
+		if recipe.ID != id {
+			continue
+		}
+		hooks, err := css.ListClaudeHooks()
+		if err != nil {
+			return ClaudeHook{}, err
+		}
+		for _, hook := range hooks {
+			if hook.ID == recipe.Hook.ID {
+				return hook, nil
+			}
+		}
+		recipe.Hook.ID = ""
+		return css.SaveClaudeHook(recipe.Hook)
+	}
+	return ClaudeHook{}, fmt.Errorf("未知的 hook 模板 %s", id)
+}
+
+// updateClaudeHooks 只改动 hooks 字段，env 等其他配置原样保留。开启代理时 settings.json 会被替换，
+// 关闭时从备份恢复，所以备份存在时同样修改备份，关闭代理后 hook 不会丢失
+func (css *ClaudeSettingsService) updateClaudeHooks(apply func(raw map[string]any) error) error {
+	css.snapshot("claude:hooks")
+	raw, err := css.readRawSettings()
+	if err != nil {
+		return err
+	}
+	if err := apply(raw); err != nil {
+		return err
+	}
+	settingsPath, backupPath, err := css.paths()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(settingsPath), 0o755); err != nil {
+		return err
+	}
+	if err := css.writeRawSettings(raw); err != nil {
+		return err
+	}
+	backup, err := readClaudeSettingsFile(backupPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		fmt.Printf("[WARN] 读取 Claude 配置备份失败，hook 未同步到备份: %v\n", err)
+		return nil
+	}
+	if hooks, ok := raw["hooks"]; ok {
+		backup["hooks"] = hooks
+	} else {
+		delete(backup, "hooks")
+	}
+	return writeClaudeSettingsFile(backupPath, backup)
+}
+
+func normalizeClaudeHook(hook ClaudeHook) (ClaudeHook, error) {
+	hook.Event = strings.TrimSpace(hook.Event)
+	hook.Matcher = strings.TrimSpace(hook.Matcher)
+	hook.Command = strings.TrimSpace(hook.Command)
+	if !slices.Contains(claudeHookEvents, hook.Event) {
+		return hook, fmt.Errorf("不支持的 hook 事件 %q", hook.Event)
+	}
+	if hook.Command == "" {
+		return hook, errors.New("hook 命令不能为空")
+	}
+	if hook.Timeout < 0 {
+		return hook, errors.New("超时时间不能为负数")
+	}
+	if !slices.Contains(claudeHookMatcherEvents, hook.Event) {
+		hook.Matcher = ""
+	}
+	hook.Type = "command"
+	return hook, nil
+}
+
+func claudeHookID(hook ClaudeHook) string {
+	sum := sha256.Sum256([]byte(hook.Event + "\x00" + hook.Matcher + "\x00" + hook.Command))
+	return hex.EncodeToString(sum[:6])
+}
+
+func claudeHookEventIndex(event string) int {
+	if index := slices.Index(claudeHookEvents, event); index >= 0 {
+		return index
+	}
+	return len(claudeHookEvents)
+}
+
+// flattenClaudeHooks 把 hooks.<事件>[].hooks[] 展开为列表。prompt 等非 command 类型同样列出，
+// 只是命令为空，修改时其余字段会保留
+func flattenClaudeHooks(raw map[string]any) []ClaudeHook {
+	events, _ := raw["hooks"].(map[string]any)
+	var hooks []ClaudeHook
+	for event, value := range events {
+		groups, _ := value.([]any)
+		for _, item := range groups {
+			group, _ := item.(map[string]any)
+			matcher, _ := group["matcher"].(string)
+			entries, _ := group["hooks"].([]any)
+			for _, e := range entries {
+				entry, ok := e.(map[string]any)
+				if !ok {
+					continue
+				}
+				hook := ClaudeHook{Event: event, Matcher: matcher}
+				hook.Type, _ = entry["type"].(string)
+				hook.Command, _ = entry["command"].(string)
+				if timeout, ok := entry["timeout"].(float64); ok {
+					hook.Timeout = int(timeout)
+				}
+				hook.ID = claudeHookID(hook)
+				hooks = append(hooks, hook)
+			}
+		}
+	}
+	if hooks == nil {
+		hooks = []ClaudeHook{}
+	}
+	return hooks
+}
+
+func findClaudeHook(raw map[string]any, id string) bool {
+	for _, hook := range flattenClaudeHooks(raw) {
+		if hook.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// removeClaudeHook 删除匹配的 hook 并返回其原始条目，分组或事件为空时一并清理
+func removeClaudeHook(raw map[string]any, id string) (map[string]any, bool) {
+	events, _ := raw["hooks"].(map[string]any)
+	for event, value := range events {
+		groups, _ := value.([]any)
+		for gi, item := range groups {
+			group, _ := item.(map[string]any)
+			matcher, _ := group["matcher"].(string)
+			entries, _ := group["hooks"].([]any)
+			for ei, e := range entries {
+				entry, ok := e.(map[string]any)
+				if !ok {
+					continue
+				}
+				hook := ClaudeHook{Event: event, Matcher: matcher}
+				hook.Command, _ = entry["command"].(string)
+				if claudeHookID(hook) != id {
+					continue
+				}
+				entries = slices.Delete(entries, ei, ei+1)
+				if len(entries) > 0 {
+					group["hooks"] = entries
+				} else {
+					groups = slices.Delete(groups, gi, gi+1)
+				}
+				if len(groups) > 0 {
+					events[event] = groups
+				} else {
+					delete(events, event)
+				}
+				if len(events) == 0 {
+					delete(raw, "hooks")
+				}
+				return entry, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// insertClaudeHook 追加到相同 matcher 的分组，没有时新建分组；entry 为修改前的原始条目
+func insertClaudeHook(raw map[string]any, hook ClaudeHook, entry map[string]any) {
+	if entry == nil {
+		entry = make(map[string]any)
+	}
+	entry["type"] = hook.Type
+	entry["command"] = hook.Command
+	if hook.Timeout > 0 {
+		entry["timeout"] = hook.Timeout
+	} else {
+		delete(entry, "timeout")
+	}
+
+	events, _ := raw["hooks"].(map[string]any)
+	if events == nil {
+		events = make(map[string]any)
+		raw["hooks"] = events
+	}
+	groups, _ := events[hook.Event].([]any)
+	for _, item := range groups {
+		group, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		if matcher, _ := group["matcher"].(string); matcher == hook.Matcher {
+			entries, _ := group["hooks"].([]any)
+			group["hooks"] = append(entries, entry)
+			return
+		}
+	}
+	group := map[string]any{"hooks": []any{entry}}
+	if hook.Matcher != "" {
+		group["matcher"] = hook.Matcher
+	}
+	events[hook.Event] = append(groups, group)
+}
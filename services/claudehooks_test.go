@@ -0,0 +1,73 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClaudeHooksPreserveSettings(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := filepath.Join(home, claudeSettingsDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	settingsPath := filepath.Join(dir, claudeSettingsFileName)
+	existing := `{"model":"opus","hooks":{"Stop":[{"hooks":[{"type":"prompt","prompt":"check"}]}]}}`
+	if err := os.WriteFile(settingsPath, []byte(existing), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	css := NewClaudeSettingsService(":18100")
+	if err := css.EnableProxy(); err != nil {
+		t.Fatal(err)
+	}
+	hook, err := css.ApplyClaudeHookRecipe("block-dangerous-commands")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.Timeout = 5
+	if hook, err = css.SaveClaudeHook(hook); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := css.SaveClaudeHook(ClaudeHook{Event: "Stop", Command: "echo done"}); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := css.readRawSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env, _ := raw["env"].(map[string]any); env["ANTHROPIC_AUTH_TOKEN"] != claudeAuthTokenValue {
+		t.Fatalf("proxy env lost: %v", raw["env"])
+	}
+	hooks, err := css.ListClaudeHooks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hooks) != 3 || hooks[0].Event != "PreToolUse" || hooks[0].Timeout != 5 {
+		t.Fatalf("unexpected hooks: %+v", hooks)
+	}
+
+	if err := css.DeleteClaudeHook(hook.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := css.DisableProxy(); err != nil {
+		t.Fatal(err)
+	}
+	raw, err = css.readRawSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw["model"] != "opus" || raw["env"] != nil {
+		t.Fatalf("settings not restored: %v", raw)
+	}
+	hooks, err = css.ListClaudeHooks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hooks) != 2 || hooks[0].Type != "prompt" || hooks[1].Command != "echo done" {
+		t.Fatalf("hooks not kept after disabling proxy: %+v", hooks)
+	}
+}
@@ -0,0 +1,280 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Claude Code 的设置按 local > project > user 的顺序生效，env 中的同名变量由优先级高的一层决定
+const (
+	ClaudeScopeUser    = "user"
+	ClaudeScopeProject = "project"
+	ClaudeScopeLocal   = "local"
+
+	claudeLocalSettingsFileName = "settings.local.json"
+	claudeProjectStatesFile     = "claude-project-scopes.json"
+
+	claudeScopeModeProxy  = "proxy"
+	claudeScopeModeDirect = "direct"
+)
+
+// ClaudeScopeStatus 一层 settings 文件的状态。Mode 为 code-switch 写入的方式（proxy / direct），
+// BaseURL 为该层 env 中的 ANTHROPIC_BASE_URL，Effective 表示该层的地址最终生效
+type ClaudeScopeStatus struct {
+	Scope     string `json:"scope"`
+	Path      string `json:"path"`
+	Exists    bool   `json:"exists"`
+	Mode      string `json:"mode"`
+	Provider  string `json:"provider"`
+	BaseURL   string `json:"base_url"`
+	Proxy     bool   `json:"proxy"`
+	Effective bool   `json:"effective"`
+}
+
+// ClaudeProjectSettings 项目下各层设置的状态，Scopes 按优先级从高到低排列
+type ClaudeProjectSettings struct {
+	Project          string              `json:"project"`
+	Scopes           []ClaudeScopeStatus `json:"scopes"`
+	EffectiveScope   string              `json:"effective_scope"`
+	EffectiveBaseURL string              `json:"effective_base_url"`
+	ViaProxy         bool                `json:"via_proxy"`
+}
+
+// claudeScopeState 项目级文件中 code-switch 写入的键与原值，按 settings 文件路径记录在数据目录，
+// 不在项目中留下额外文件
+type claudeScopeState struct {
+	Mode string `json:"mode"`
+	directApplyState
+}
+
+// ProjectSettingsStatus 返回项目中 local、project 与用户级设置的状态及最终生效的地址
+func (css *ClaudeSettingsService) ProjectSettingsStatus(project string) (ClaudeProjectSettings, error) {
+	project, err := normalizeClaudeProject(project)
+	if err != nil {
+		return ClaudeProjectSettings{}, err
+	}
+	states, err := loadClaudeProjectStates()
+	if err != nil {
+		return ClaudeProjectSettings{}, err
+	}
+	userPath, _, err := css.paths()
+	if err != nil {
+		return ClaudeProjectSettings{}, err
+	}
+	result := ClaudeProjectSettings{Project: project, Scopes: make([]ClaudeScopeStatus, 0, 3)}
+	for _, scope := range []string{ClaudeScopeLocal, ClaudeScopeProject, ClaudeScopeUser} {
+		status := ClaudeScopeStatus{Scope: scope, Path: userPath}
+		if scope != ClaudeScopeUser {
+			status.Path = claudeScopePath(project, scope)
+			if state, ok := states[status.Path]; ok {
+				status.Mode, status.Provider = state.Mode, state.Provider
+			}
+		} else if proxy, _ := css.ProxyStatus(); proxy.Enabled {
+			status.Mode = claudeScopeModeProxy
+		} else if direct, _ := css.DirectApplyStatus(); direct.Applied {
+			status.Mode, status.Provider = claudeScopeModeDirect, direct.Provider
+		}
+		raw, err := readClaudeSettingsFile(status.Path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return result, fmt.Errorf("读取 %s 失败: %w", status.Path, err)
+		}
+		status.Exists = err == nil
+		env, _ := raw["env"].(map[string]any)
+		status.BaseURL, _ = env["ANTHROPIC_BASE_URL"].(string)
+		token, _ := env["ANTHROPIC_AUTH_TOKEN"].(string)
+		status.Proxy = strings.EqualFold(status.BaseURL, css.baseURL()) && strings.EqualFold(token, claudeAuthTokenValue)
+		if status.BaseURL != "" && result.EffectiveScope == "" {
+			status.Effective = true
+			result.EffectiveScope, result.EffectiveBaseURL, result.ViaProxy = scope, status.BaseURL, status.Proxy
+		}
+		result.Scopes = append(result.Scopes, status)
+	}
+	return result, nil
+}
+
+// EnableProjectProxy 在项目的 settings.json 或 settings.local.json 中把 Claude Code 指向本地中转，
+// 只合并 env 中的两个变量，文件中的其他设置保持不变
+func (css *ClaudeSettingsService) EnableProjectProxy(project string, scope string) error {
+	return css.applyProjectScope(project, scope, claudeScopeModeProxy, "", map[string]string{
+		"ANTHROPIC_BASE_URL":   css.baseURL(),
+		"ANTHROPIC_AUTH_TOKEN": claudeAuthTokenValue,
+	})
+}
+
+// ApplyProjectProvider 项目级直连。settings.json 通常会提交到仓库，API Key 建议写入 settings.local.json
+func (css *ClaudeSettingsService) ApplyProjectProvider(project string, scope string, provider Provider) error {
+	if strings.TrimSpace(provider.APIURL) == "" || strings.TrimSpace(provider.APIKey) == "" {
+		return errors.New("provider 缺少 API 地址或 API Key")
+	}
+	return css.applyProjectScope(project, scope, claudeScopeModeDirect, provider.Name, claudeDirectEnv(provider))
+}
+
+// ClearProjectScope 移除 code-switch 在项目级文件中写入的变量，被覆盖的原值恢复；
+// 恢复后文件已无其他内容时一并删除
+func (css *ClaudeSettingsService) ClearProjectScope(project string, scope string) error {
+	project, err := normalizeClaudeProject(project)
+	if err != nil {
+		return err
+	}
+	path, err := claudeProjectScopePath(project, scope)
+	if err != nil {
+		return err
+	}
+	states, err := loadClaudeProjectStates()
+	if err != nil {
+		return err
+	}
+	snapshotConfigFiles("claude:clear-"+scope, path)
+	if err := restoreClaudeScope(path, states); err != nil {
+		return err
+	}
+	return saveClaudeProjectStates(states)
+}
+
+func (css *ClaudeSettingsService) applyProjectScope(project, scope, mode, provider string, values map[string]string) error {
+	project, err := normalizeClaudeProject(project)
+	if err != nil {
+		return err
+	}
+	path, err := claudeProjectScopePath(project, scope)
+	if err != nil {
+		return err
+	}
+	states, err := loadClaudeProjectStates()
+	if err != nil {
+		return err
+	}
+	snapshotConfigFiles("claude:"+mode+"-"+scope, path)
+	// 先恢复上次写入的值，避免把代理或上一个 provider 的值当作原值记录
+	if err := restoreClaudeScope(path, states); err != nil {
+		return err
+	}
+	raw, err := readClaudeSettingsFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		raw, err = make(map[string]any), nil
+	}
+	if err != nil {
+		return err
+	}
+	env, _ := raw["env"].(map[string]any)
+	if env == nil {
+		env = make(map[string]any)
+	}
+	state := claudeScopeState{Mode: mode, directApplyState: mergeDirectValues(env, values, provider)}
+	raw["env"] = env
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if err := writeClaudeSettingsFile(path, raw); err != nil {
+		return err
+	}
+	states[path] = state
+	return saveClaudeProjectStates(states)
+}
+
+// restoreClaudeScope 按记录恢复文件并删除记录，调用方负责保存 states
+func restoreClaudeScope(path string, states map[string]claudeScopeState) error {
+	state, ok := states[path]
+	if !ok {
+		return nil
+	}
+	raw, err := readClaudeSettingsFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		delete(states, path)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if env, ok := raw["env"].(map[string]any); ok {
+		stripDirectValues(env, state.directApplyState)
+		if len(env) == 0 {
+			delete(raw, "env")
+		}
+	}
+	delete(states, path)
+	if len(raw) == 0 {
+		return os.Remove(path)
+	}
+	return writeClaudeSettingsFile(path, raw)
+}
+
+// claudeDirectEnv 直连时写入 env 的变量：地址、Key 以及 provider 的自定义变量
+func claudeDirectEnv(provider Provider) map[string]string {
+	tokenKey := "ANTHROPIC_AUTH_TOKEN"
+	if strings.EqualFold(provider.AuthStyle, authStyleAPIKey) {
+		tokenKey = "ANTHROPIC_API_KEY"
+	}
+	values := providerExtraEnv(provider, "ANTHROPIC_BASE_URL", "ANTHROPIC_AUTH_TOKEN", "ANTHROPIC_API_KEY")
+	values["ANTHROPIC_BASE_URL"] = provider.APIURL
+	values[tokenKey] = provider.APIKey
+	return values
+}
+
+func normalizeClaudeProject(project string) (string, error) {
+	path := strings.TrimSpace(project)
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		path = filepath.Join(userHomeDir(), strings.TrimPrefix(path, "~"))
+	}
+	if path == "" || !filepath.IsAbs(path) {
+		return "", fmt.Errorf("项目路径需要是绝对路径: %s", project)
+	}
+	path = filepath.Clean(path)
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("项目目录不存在: %s", path)
+	}
+	if path == filepath.Clean(userHomeDir()) {
+		return "", errors.New("主目录下的 .claude/settings.json 即用户级设置，请直接开启代理")
+	}
+	return path, nil
+}
+
+func claudeProjectScopePath(project string, scope string) (string, error) {
+	switch scope {
+	case ClaudeScopeProject, ClaudeScopeLocal:
+		return claudeScopePath(project, scope), nil
+	default:
+		return "", fmt.Errorf("不支持的设置范围: %s", scope)
+	}
+}
+
+func claudeScopePath(project string, scope string) string {
+	if scope == ClaudeScopeLocal {
+		return filepath.Join(project, claudeSettingsDir, claudeLocalSettingsFileName)
+	}
+	return filepath.Join(project, claudeSettingsDir, claudeSettingsFileName)
+}
+
+func loadClaudeProjectStates() (map[string]claudeScopeState, error) {
+	states := make(map[string]claudeScopeState)
+	data, err := os.ReadFile(filepath.Join(dataDir(), claudeProjectStatesFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return states, nil
+		}
+		return nil, err
+	}
+	if len(data) == 0 {
+		return states, nil
+	}
+	if err := json.Unmarshal(data, &states); err != nil {
+		return nil, err
+	}
+	return states, nil
+}
+
+func saveClaudeProjectStates(states map[string]claudeScopeState) error {
+	dir, err := ensureDataDir()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, claudeProjectStatesFile), data, 0o600)
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClaudeProjectSettingsPrecedence(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	project := filepath.Join(home, "work", "app")
+	writeSkillFiles(t, home, map[string]string{
+		".claude/settings.json":                `{"env":{"ANTHROPIC_BASE_URL":"https://user.example.com"}}`,
+		"work/app/.claude/settings.json":       `{"permissions":{"allow":["Bash(go test:*)"]},"env":{"ANTHROPIC_BASE_URL":"https://team.example.com"}}`,
+		"work/app/.claude/settings.local.json": `{"env":{"ANTHROPIC_BASE_URL":"http://127.0.0.1:18100","ANTHROPIC_AUTH_TOKEN":"code-switch"}}`,
+	})
+
+	css := NewClaudeSettingsService(":18100")
+	status, err := css.ProjectSettingsStatus(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Scopes) != 3 || status.Scopes[0].Scope != ClaudeScopeLocal || status.Scopes[2].Scope != ClaudeScopeUser {
+		t.Fatalf("scopes = %+v", status.Scopes)
+	}
+	if status.EffectiveScope != ClaudeScopeLocal || !status.ViaProxy || !status.Scopes[0].Effective || status.Scopes[1].Effective {
+		t.Fatalf("status = %+v", status)
+	}
+
+	if err := os.Remove(filepath.Join(project, claudeSettingsDir, claudeLocalSettingsFileName)); err != nil {
+		t.Fatal(err)
+	}
+	status, err = css.ProjectSettingsStatus(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.EffectiveScope != ClaudeScopeProject || status.EffectiveBaseURL != "https://team.example.com" || status.ViaProxy || status.Scopes[0].Exists {
+		t.Fatalf("status = %+v", status)
+	}
+
+	if _, err := css.ProjectSettingsStatus(home); err == nil {
+		t.Fatal("home directory accepted as project")
+	}
+}
+
+func TestRestoreClaudeScope(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "settings.json")
+	writeSkillFiles(t, dir, map[string]string{"settings.json": `{"model":"opus","env":{"ANTHROPIC_BASE_URL":"https://team.example.com"}}`})
+
+	raw, err := readClaudeSettingsFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := raw["env"].(map[string]any)
+	state := claudeScopeState{Mode: claudeScopeModeProxy, directApplyState: mergeDirectValues(env, map[string]string{
+		"ANTHROPIC_BASE_URL":   "http://127.0.0.1:18100",
+		"ANTHROPIC_AUTH_TOKEN": claudeAuthTokenValue,
+	}, "")}
+	if err := writeClaudeSettingsFile(path, raw); err != nil {
+		t.Fatal(err)
+	}
+	states := map[string]claudeScopeState{path: state}
+	if err := restoreClaudeScope(path, states); err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 0 {
+		t.Fatalf("state not removed: %+v", states)
+	}
+	raw, err = readClaudeSettingsFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env = raw["env"].(map[string]any)
+	if raw["model"] != "opus" || env["ANTHROPIC_BASE_URL"] != "https://team.example.com" || len(env) != 1 {
+		t.Fatalf("settings not restored: %+v", raw)
+	}
+}
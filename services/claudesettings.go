@@ -1,6 +1,7 @@
 package services
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"os"
@@ -11,8 +12,9 @@ import (
 const (
 	claudeSettingsDir      = ".claude"
 	claudeSettingsFileName = "settings.json"
-	claudeBackupFileName   = "cc-studio.back.settings.json"
-	claudeAuthTokenValue   = "code-switch"
+	// claudeBackupFileName 开启代理前的 settings.json，DisableProxy 据此还原，与 BackupService 的历史快照相互独立
+	claudeBackupFileName = "cc-studio.back.settings.json"
+	claudeAuthTokenValue = "code-switch"
 )
 
 type ClaudeProxyStatus struct {
@@ -22,6 +24,8 @@ type ClaudeProxyStatus struct {
 
 type ClaudeSettingsService struct {
 	relayAddr string
+	// home 非空时读写该目录下的配置（如 WSL 发行版的主目录），不参与配置监控
+	home string
 }
 
 func NewClaudeSettingsService(relayAddr string) *ClaudeSettingsService {
@@ -53,6 +57,7 @@ func (css *ClaudeSettingsService) ProxyStatus() (ClaudeProxyStatus, error) {
 }
 
 func (css *ClaudeSettingsService) EnableProxy() error {
+	css.snapshot("claude:enable-proxy")
 	settingsPath, backupPath, err := css.paths()
 	if err != nil {
 		return err
@@ -69,20 +74,56 @@ func (css *ClaudeSettingsService) EnableProxy() error {
 			return err
 		}
 	}
-	settings := claudeSettingsFile{
-		Env: map[string]string{
+	settings := map[string]any{
+		"env": map[string]string{
 			"ANTHROPIC_AUTH_TOKEN": claudeAuthTokenValue,
 			"ANTHROPIC_BASE_URL":   css.baseURL(),
 		},
 	}
-	payload, err := json.MarshalIndent(settings, "", "  ")
+	// hooks 与代理无关，开启代理后继续生效
+	if previous, err := readClaudeSettingsFile(backupPath); err == nil {
+		if hooks, ok := previous["hooks"]; ok {
+			settings["hooks"] = hooks
+		}
+	}
+	if err := writeClaudeSettingsFile(settingsPath, settings); err != nil {
+		return err
+	}
+	css.setIntent(true)
+	return nil
+}
+
+// ReapplyProxy settings.json 被其他程序改写后重新写入代理变量，保留改写后的其他内容，不覆盖开启代理时的备份
+func (css *ClaudeSettingsService) ReapplyProxy() error {
+	css.snapshot("claude:reapply-proxy")
+	settingsPath, _, err := css.paths()
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(settingsPath, payload, 0o600)
+	if err := os.MkdirAll(filepath.Dir(settingsPath), 0o755); err != nil {
+		return err
+	}
+	raw, err := css.readRawSettings()
+	if err != nil {
+		return err
+	}
+	env, _ := raw["env"].(map[string]any)
+	if env == nil {
+		env = make(map[string]any)
+	}
+	env["ANTHROPIC_AUTH_TOKEN"] = claudeAuthTokenValue
+	env["ANTHROPIC_BASE_URL"] = css.baseURL()
+	raw["env"] = env
+	if err := css.writeRawSettings(raw); err != nil {
+		return err
+	}
+	css.setIntent(true)
+	return nil
 }
 
 func (css *ClaudeSettingsService) DisableProxy() error {
+	css.snapshot("claude:disable-proxy")
+	css.setIntent(false)
 	settingsPath, backupPath, err := css.paths()
 	if err != nil {
 		return err
@@ -101,7 +142,7 @@ func (css *ClaudeSettingsService) DisableProxy() error {
 }
 
 func (css *ClaudeSettingsService) paths() (settingsPath string, backupPath string, err error) {
-	home, err := os.UserHomeDir()
+	home, err := css.homeDir()
 	if err != nil {
 		return "", "", err
 	}
@@ -109,24 +150,152 @@ func (css *ClaudeSettingsService) paths() (settingsPath string, backupPath strin
 	return filepath.Join(dir, claudeSettingsFileName), filepath.Join(dir, claudeBackupFileName), nil
 }
 
-func (css *ClaudeSettingsService) baseURL() string {
-	addr := strings.TrimSpace(css.relayAddr)
-	if addr == "" {
-		addr = ":18100"
-	}
-	if strings.HasPrefix(addr, "http://") || strings.HasPrefix(addr, "https://") {
-		return addr
+// snapshot 修改 settings.json 前保存快照
+func (css *ClaudeSettingsService) snapshot(operation string) {
+	if settingsPath, _, err := css.paths(); err == nil {
+		snapshotConfigFiles(operation, settingsPath)
 	}
-	host := addr
-	if strings.HasPrefix(host, ":") {
-		host = "127.0.0.1" + host
+}
+
+func (css *ClaudeSettingsService) homeDir() (string, error) {
+	if css.home != "" {
+		return css.home, nil
 	}
-	if !strings.Contains(host, "://") {
-		host = "http://" + host
+	return os.UserHomeDir()
+}
+
+func (css *ClaudeSettingsService) setIntent(enabled bool) {
+	if css.home == "" {
+		setProxyIntent(configWatchTargetClaude, enabled)
 	}
-	return host
+}
+
+func (css *ClaudeSettingsService) baseURL() string {
+	return relayBaseURL(css.relayAddr)
 }
 
 type claudeSettingsFile struct {
 	Env map[string]string `json:"env"`
 }
+
+// ApplySingleProvider 直连模式：不经过本地中转，直接把 provider 的地址、Key 与自定义环境变量写入 settings.json
+func (css *ClaudeSettingsService) ApplySingleProvider(provider Provider) error {
+	if strings.TrimSpace(provider.APIURL) == "" || strings.TrimSpace(provider.APIKey) == "" {
+		return errors.New("provider 缺少 API 地址或 API Key")
+	}
+	css.snapshot("claude:apply-provider")
+	if err := css.RemoveSingleProvider(); err != nil {
+		return err
+	}
+	settingsPath, _, err := css.paths()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(settingsPath), 0o755); err != nil {
+		return err
+	}
+	raw, err := css.readRawSettings()
+	if err != nil {
+		return err
+	}
+	env, _ := raw["env"].(map[string]any)
+	if env == nil {
+		env = make(map[string]any)
+	}
+
+	state := mergeDirectValues(env, claudeDirectEnv(provider), provider.Name)
+	raw["env"] = env
+	if err := css.writeRawSettings(raw); err != nil {
+		return err
+	}
+	return saveDirectApplyState(css.directStatePath(), state)
+}
+
+// RemoveSingleProvider 移除直连写入的环境变量，被覆盖的原值会恢复
+func (css *ClaudeSettingsService) RemoveSingleProvider() error {
+	css.snapshot("claude:remove-provider")
+	statePath := css.directStatePath()
+	state, err := loadDirectApplyState(statePath)
+	if err != nil || state == nil {
+		return err
+	}
+	raw, err := css.readRawSettings()
+	if err != nil {
+		return err
+	}
+	if env, ok := raw["env"].(map[string]any); ok {
+		stripDirectValues(env, *state)
+		if len(env) == 0 {
+			delete(raw, "env")
+		}
+	}
+	if err := css.writeRawSettings(raw); err != nil {
+		return err
+	}
+	if err := os.Remove(statePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// DirectApplyStatus 返回当前直连应用的 provider
+func (css *ClaudeSettingsService) DirectApplyStatus() (DirectApplyStatus, error) {
+	state, err := loadDirectApplyState(css.directStatePath())
+	if err != nil || state == nil {
+		return DirectApplyStatus{}, err
+	}
+	return DirectApplyStatus{Applied: true, Provider: state.Provider}, nil
+}
+
+func (css *ClaudeSettingsService) directStatePath() string {
+	settingsPath, _, err := css.paths()
+	if err != nil {
+		return directApplyStateFileName
+	}
+	return filepath.Join(filepath.Dir(settingsPath), directApplyStateFileName)
+}
+
+// readRawSettings 以通用结构读取 settings.json，保留用户的其他配置项
+func (css *ClaudeSettingsService) readRawSettings() (map[string]any, error) {
+	settingsPath, _, err := css.paths()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := readClaudeSettingsFile(settingsPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return make(map[string]any), nil
+	}
+	return raw, err
+}
+
+func (css *ClaudeSettingsService) writeRawSettings(raw map[string]any) error {
+	settingsPath, _, err := css.paths()
+	if err != nil {
+		return err
+	}
+	return writeClaudeSettingsFile(settingsPath, raw)
+}
+
+func readClaudeSettingsFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return make(map[string]any), nil
+	}
+	return parseJSONC(data)
+}
+
+// writeClaudeSettingsFile 只改动有变化的字段，用户手写的格式与键顺序保持不变
+func writeClaudeSettingsFile(path string, raw map[string]any) error {
+	original, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	payload, err := encodeJSONPreserving(original, raw)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, payload, 0o600)
+}
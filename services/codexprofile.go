@@ -0,0 +1,121 @@
+package services
+
+import (
+	"runtime"
+	"strings"
+)
+
+// Codex 的 profile 模式：代理写入独立的 [profiles.code-switch]，顶层的 model_provider 与 model 保持用户原值，
+// 只有通过 --profile 启动的会话经过本地中转
+const codexProfileKey = "code-switch"
+
+// CodexProfileStatus profile 模式的状态，Commands 为使用该 profile 的启动方式
+type CodexProfileStatus struct {
+	Enabled  bool     `json:"enabled"`
+	Profile  string   `json:"profile"`
+	BaseURL  string   `json:"base_url"`
+	Default  bool     `json:"default"`
+	Commands []string `json:"commands"`
+}
+
+// ProfileStatus 返回 profile 是否已写入，以及指向的中转地址是否与当前一致
+func (css *CodexSettingsService) ProfileStatus() (CodexProfileStatus, error) {
+	status := CodexProfileStatus{Profile: codexProfileKey, BaseURL: css.baseURL(), Commands: codexProfileCommands()}
+	raw, err := css.readRawConfig()
+	if err != nil {
+		return status, err
+	}
+	profiles, _ := normalizeConfigValue(raw["profiles"]).(map[string]any)
+	profile, _ := normalizeConfigValue(profiles[codexProfileKey]).(map[string]any)
+	providers, _ := normalizeConfigValue(raw["model_providers"]).(map[string]any)
+	provider, _ := normalizeConfigValue(providers[codexProviderKey]).(map[string]any)
+	baseURL, _ := provider["base_url"].(string)
+	status.Enabled = profile["model_provider"] == codexProviderKey && strings.EqualFold(baseURL, css.baseURL())
+	status.Default = raw["profile"] == codexProfileKey
+	return status, nil
+}
+
+// EnableProfile 写入 code-switch provider 与同名 profile，不修改顶层配置，也不改动 auth.json；
+// 中转不校验 Key，provider 关闭 requires_openai_auth 后无需登录
+func (css *CodexSettingsService) EnableProfile() error {
+	css.snapshot("codex:enable-profile")
+	raw, err := css.readRawConfig()
+	if err != nil {
+		return err
+	}
+	modelProviders := ensureTomlTable(raw, "model_providers")
+	provider := ensureProviderTable(modelProviders, codexProviderKey)
+	provider["name"] = codexProviderKey
+	provider["base_url"] = css.baseURL()
+	provider["wire_api"] = codexWireAPI
+	provider["requires_openai_auth"] = false
+
+	profiles := ensureTomlTable(raw, "profiles")
+	profile := ensureProviderTable(profiles, codexProfileKey)
+	profile["model_provider"] = codexProviderKey
+	if model, _ := profile["model"].(string); strings.TrimSpace(model) == "" {
+		profile["model"] = codexDefaultModel
+	}
+	return css.writeRawConfig(raw)
+}
+
+// DisableProfile 删除 profile；全局代理仍在使用 code-switch provider 时保留 provider。
+// 顶层 profile 指向它时一并移除，避免 Codex 因 profile 不存在而无法启动
+func (css *CodexSettingsService) DisableProfile() error {
+	css.snapshot("codex:disable-profile")
+	raw, err := css.readRawConfig()
+	if err != nil {
+		return err
+	}
+	profiles := ensureTomlTable(raw, "profiles")
+	delete(profiles, codexProfileKey)
+	if len(profiles) == 0 {
+		delete(raw, "profiles")
+	}
+	if raw["profile"] == codexProfileKey {
+		delete(raw, "profile")
+	}
+	if raw["model_provider"] != codexProviderKey {
+		modelProviders := ensureTomlTable(raw, "model_providers")
+		delete(modelProviders, codexProviderKey)
+		if len(modelProviders) == 0 {
+			delete(raw, "model_providers")
+		}
+	}
+	return css.writeRawConfig(raw)
+}
+
+// SetProfileDefault 把 code-switch 设为顶层默认 profile，不带 --profile 启动时也经过中转；
+// 关闭后恢复为 Codex 自身的默认配置
+func (css *CodexSettingsService) SetProfileDefault(enabled bool) error {
+	css.snapshot("codex:profile-default")
+	raw, err := css.readRawConfig()
+	if err != nil {
+		return err
+	}
+	if enabled {
+		if err := css.EnableProfile(); err != nil {
+			return err
+		}
+		if raw, err = css.readRawConfig(); err != nil {
+			return err
+		}
+		raw["profile"] = codexProfileKey
+	} else if raw["profile"] == codexProfileKey {
+		delete(raw, "profile")
+	} else {
+		return nil
+	}
+	return css.writeRawConfig(raw)
+}
+
+func codexProfileCommands() []string {
+	commands := []string{
+		"codex --profile " + codexProfileKey,
+		"codex exec --profile " + codexProfileKey + ` "<prompt>"`,
+	}
+	if runtime.GOOS == "windows" {
+		return append(commands, "function codex-cs { codex --profile "+codexProfileKey+" @args }")
+	}
+	return append(commands, "alias codex-cs='codex --profile "+codexProfileKey+"'")
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"os"
@@ -11,10 +12,11 @@ import (
 )
 
 const (
-	codexSettingsDir      = ".codex"
-	codexConfigFileName   = "config.toml"
+	codexSettingsDir    = ".codex"
+	codexConfigFileName = "config.toml"
+	codexAuthFileName   = "auth.json"
+	// 开启代理前的 config.toml 与 auth.json，关闭代理时原样还原；历史版本另由 BackupService 保存
 	codexBackupConfigName = "cc-studio.back.config.toml"
-	codexAuthFileName     = "auth.json"
 	codexBackupAuthName   = "cc-studio.back.auth.json"
 	codexPreferredAuth    = "apikey"
 	codexDefaultModel     = "gpt-5-codex"
@@ -22,10 +24,14 @@ const (
 	codexEnvKey           = "OPENAI_API_KEY"
 	codexWireAPI          = "responses"
 	codexTokenValue       = "code-switch"
+
+	codexDirectProviderKey = "code-switch-direct"
 )
 
 type CodexSettingsService struct {
 	relayAddr string
+	// home 非空时读写该目录下的配置（如 WSL 发行版的主目录），不参与配置监控
+	home string
 }
 
 func NewCodexSettingsService(relayAddr string) *CodexSettingsService {
@@ -53,6 +59,7 @@ func (css *CodexSettingsService) ProxyStatus() (ClaudeProxyStatus, error) {
 }
 
 func (css *CodexSettingsService) EnableProxy() error {
+	css.snapshot("codex:enable-proxy")
 	settingsPath, backupPath, err := css.paths()
 	if err != nil {
 		return err
@@ -61,8 +68,10 @@ func (css *CodexSettingsService) EnableProxy() error {
 		return err
 	}
 	var raw map[string]any
+	var content []byte
 	if _, err := os.Stat(settingsPath); err == nil {
-		content, readErr := os.ReadFile(settingsPath)
+		var readErr error
+		content, readErr = os.ReadFile(settingsPath)
 		if readErr != nil {
 			return readErr
 		}
@@ -72,12 +81,55 @@ func (css *CodexSettingsService) EnableProxy() error {
 		if err := toml.Unmarshal(content, &raw); err != nil {
 			return err
 		}
-	} else {
-		raw = make(map[string]any)
 	}
 	if raw == nil {
 		raw = make(map[string]any)
 	}
+	css.setProxyConfig(raw)
+
+	data, err := encodeCodexConfig(content, raw)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(settingsPath, data, 0o600); err != nil {
+		return err
+	}
+	if err := css.writeAuthFile(); err != nil {
+		return err
+	}
+	css.setIntent(true)
+	return nil
+}
+
+// ReapplyProxy 配置被其他程序改写后重新写入代理配置，保留改写后的其他内容，不覆盖开启代理时的备份
+func (css *CodexSettingsService) ReapplyProxy() error {
+	css.snapshot("codex:reapply-proxy")
+	raw, err := css.readRawConfig()
+	if err != nil {
+		return err
+	}
+	css.setProxyConfig(raw)
+	if err := css.writeRawConfig(raw); err != nil {
+		return err
+	}
+	authPath, _, err := css.authPaths()
+	if err != nil {
+		return err
+	}
+	var auth map[string]any
+	if data, err := os.ReadFile(authPath); err == nil {
+		_ = json.Unmarshal(data, &auth)
+	}
+	if auth[codexEnvKey] != codexTokenValue {
+		if err := css.writeAuthFile(); err != nil {
+			return err
+		}
+	}
+	css.setIntent(true)
+	return nil
+}
+
+func (css *CodexSettingsService) setProxyConfig(raw map[string]any) {
 	raw["preferred_auth_method"] = codexPreferredAuth
 	raw["model"] = codexDefaultModel
 	raw["model_provider"] = codexProviderKey
@@ -89,19 +141,11 @@ func (css *CodexSettingsService) EnableProxy() error {
 	provider["wire_api"] = codexWireAPI
 	provider["requires_openai_auth"] = false
 	modelProviders[codexProviderKey] = provider
-
-	data, err := toml.Marshal(raw)
-	if err != nil {
-		return err
-	}
-	cleaned := stripModelProvidersHeader(data)
-	if err := os.WriteFile(settingsPath, cleaned, 0o600); err != nil {
-		return err
-	}
-	return css.writeAuthFile()
 }
 
 func (css *CodexSettingsService) DisableProxy() error {
+	css.snapshot("codex:disable-proxy")
+	css.setIntent(false)
 	settingsPath, backupPath, err := css.paths()
 	if err != nil {
 		return err
@@ -137,7 +181,7 @@ func (css *CodexSettingsService) readConfig() (*codexConfig, error) {
 }
 
 func (css *CodexSettingsService) paths() (settingsPath string, backupPath string, err error) {
-	home, err := os.UserHomeDir()
+	home, err := css.homeDir()
 	if err != nil {
 		return "", "", err
 	}
@@ -146,7 +190,7 @@ func (css *CodexSettingsService) paths() (settingsPath string, backupPath string
 }
 
 func (css *CodexSettingsService) authPaths() (string, string, error) {
-	home, err := os.UserHomeDir()
+	home, err := css.homeDir()
 	if err != nil {
 		return "", "", err
 	}
@@ -154,22 +198,34 @@ func (css *CodexSettingsService) authPaths() (string, string, error) {
 	return filepath.Join(dir, codexAuthFileName), filepath.Join(dir, codexBackupAuthName), nil
 }
 
-func (css *CodexSettingsService) baseURL() string {
-	addr := strings.TrimSpace(css.relayAddr)
-	if addr == "" {
-		addr = ":18100"
+// snapshot 修改前为 config.toml 与 auth.json 保存同一个快照
+func (css *CodexSettingsService) snapshot(operation string) {
+	settingsPath, _, err := css.paths()
+	if err != nil {
+		return
 	}
-	if strings.HasPrefix(addr, "http://") || strings.HasPrefix(addr, "https://") {
-		return addr
+	authPath, _, err := css.authPaths()
+	if err != nil {
+		return
 	}
-	host := addr
-	if strings.HasPrefix(host, ":") {
-		host = "127.0.0.1" + host
+	snapshotConfigFiles(operation, settingsPath, authPath)
+}
+
+func (css *CodexSettingsService) homeDir() (string, error) {
+	if css.home != "" {
+		return css.home, nil
 	}
-	if !strings.Contains(host, "://") {
-		host = "http://" + host
+	return os.UserHomeDir()
+}
+
+func (css *CodexSettingsService) setIntent(enabled bool) {
+	if css.home == "" {
+		setProxyIntent(configWatchTargetCodex, enabled)
 	}
-	return host
+}
+
+func (css *CodexSettingsService) baseURL() string {
+	return relayBaseURL(css.relayAddr)
 }
 
 type codexConfig struct {
@@ -231,6 +287,11 @@ func stripModelProvidersHeader(data []byte) []byte {
 }
 
 func (css *CodexSettingsService) writeAuthFile() error {
+	return css.writeAuthKey(codexTokenValue)
+}
+
+// writeAuthKey 备份现有 auth.json 后写入指定的 OPENAI_API_KEY
+func (css *CodexSettingsService) writeAuthKey(apiKey string) error {
 	authPath, backupPath, err := css.authPaths()
 	if err != nil {
 		return err
@@ -248,7 +309,7 @@ func (css *CodexSettingsService) writeAuthFile() error {
 		}
 	}
 	payload := map[string]string{
-		codexEnvKey: codexTokenValue,
+		codexEnvKey: apiKey,
 	}
 	data, err := json.MarshalIndent(payload, "", "  ")
 	if err != nil {
@@ -272,3 +333,145 @@ func (css *CodexSettingsService) restoreAuthFile() error {
 	}
 	return nil
 }
+
+// ApplySingleProvider 直连模式：在 config.toml 中新增独立的 model_provider 指向 provider 地址，
+// provider.Env 中的条目作为 config.toml 顶层配置写入（如 model、model_reasoning_effort）
+func (css *CodexSettingsService) ApplySingleProvider(provider Provider) error {
+	if strings.TrimSpace(provider.APIURL) == "" || strings.TrimSpace(provider.APIKey) == "" {
+		return errors.New("provider 缺少 API 地址或 API Key")
+	}
+	css.snapshot("codex:apply-provider")
+	if err := css.RemoveSingleProvider(); err != nil {
+		return err
+	}
+	settingsPath, _, err := css.paths()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(settingsPath), 0o755); err != nil {
+		return err
+	}
+	raw, err := css.readRawConfig()
+	if err != nil {
+		return err
+	}
+
+	values := providerExtraEnv(provider, "model_provider", "model_providers")
+	values["model_provider"] = codexDirectProviderKey
+	state := mergeDirectValues(raw, values, provider.Name)
+
+	modelProviders := ensureTomlTable(raw, "model_providers")
+	entry := ensureProviderTable(modelProviders, codexDirectProviderKey)
+	entry["name"] = provider.Name
+	entry["base_url"] = provider.APIURL
+	entry["wire_api"] = codexWireAPI
+	entry["requires_openai_auth"] = true
+	modelProviders[codexDirectProviderKey] = entry
+
+	if err := css.writeRawConfig(raw); err != nil {
+		return err
+	}
+	if err := css.writeAuthKey(provider.APIKey); err != nil {
+		return err
+	}
+	return saveDirectApplyState(css.directStatePath(), state)
+}
+
+// RemoveSingleProvider 移除直连写入的配置与 auth.json，被覆盖的原值会恢复
+func (css *CodexSettingsService) RemoveSingleProvider() error {
+	css.snapshot("codex:remove-provider")
+	statePath := css.directStatePath()
+	state, err := loadDirectApplyState(statePath)
+	if err != nil || state == nil {
+		return err
+	}
+	raw, err := css.readRawConfig()
+	if err != nil {
+		return err
+	}
+	stripDirectValues(raw, *state)
+	modelProviders := ensureTomlTable(raw, "model_providers")
+	delete(modelProviders, codexDirectProviderKey)
+	if len(modelProviders) == 0 {
+		delete(raw, "model_providers")
+	}
+	if err := css.writeRawConfig(raw); err != nil {
+		return err
+	}
+	if err := css.restoreAuthFile(); err != nil {
+		return err
+	}
+	if err := os.Remove(statePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// DirectApplyStatus 返回当前直连应用的 provider
+func (css *CodexSettingsService) DirectApplyStatus() (DirectApplyStatus, error) {
+	state, err := loadDirectApplyState(css.directStatePath())
+	if err != nil || state == nil {
+		return DirectApplyStatus{}, err
+	}
+	return DirectApplyStatus{Applied: true, Provider: state.Provider}, nil
+}
+
+func (css *CodexSettingsService) directStatePath() string {
+	settingsPath, _, err := css.paths()
+	if err != nil {
+		return directApplyStateFileName
+	}
+	return filepath.Join(filepath.Dir(settingsPath), directApplyStateFileName)
+}
+
+func (css *CodexSettingsService) readRawConfig() (map[string]any, error) {
+	settingsPath, _, err := css.paths()
+	if err != nil {
+		return nil, err
+	}
+	raw := make(map[string]any)
+	data, err := os.ReadFile(settingsPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return raw, nil
+		}
+		return nil, err
+	}
+	if err := toml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		raw = make(map[string]any)
+	}
+	return raw, nil
+}
+
+func (css *CodexSettingsService) writeRawConfig(raw map[string]any) error {
+	settingsPath, _, err := css.paths()
+	if err != nil {
+		return err
+	}
+	original, err := os.ReadFile(settingsPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	data, err := encodeCodexConfig(original, raw)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(settingsPath, data, 0o600)
+}
+
+// encodeCodexConfig 在原有 config.toml 上定点修改，保留用户的注释与键顺序
+func encodeCodexConfig(original []byte, raw map[string]any) ([]byte, error) {
+	if len(bytes.TrimSpace(original)) > 0 {
+		if data, err := patchTOMLDocument(original, raw); err == nil {
+			return data, nil
+		}
+	}
+	data, err := toml.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	return stripModelProvidersHeader(data), nil
+}
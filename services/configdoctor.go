@@ -0,0 +1,414 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// codexBuiltinProviders Codex 内置的 model_provider，不需要在 model_providers 中定义
+var codexBuiltinProviders = map[string]struct{}{"openai": {}, "oss": {}, "ollama": {}, "lmstudio": {}}
+
+// ConfigDiagnosis 配置诊断发现的一处冲突。Detail 说明冲突会导致什么，Fix 描述一键修复会做的改动，
+// Fixable 为 false 时只给出说明，需要用户自行处理
+type ConfigDiagnosis struct {
+	ID       string `json:"id"`
+	Platform string `json:"platform"`
+	Path     string `json:"path"`
+	Field    string `json:"field"`
+	Message  string `json:"message"`
+	Detail   string `json:"detail"`
+	Fix      string `json:"fix,omitempty"`
+	Fixable  bool   `json:"fixable"`
+
+	fix func() error
+}
+
+// ConfigDoctorService 检查各 CLI 配置中已知会导致请求失败的字段组合，并提供一键修复
+type ConfigDoctorService struct {
+	claude *ClaudeSettingsService
+	codex  *CodexSettingsService
+	gemini *GeminiSettingsService
+}
+
+func NewConfigDoctorService(claude *ClaudeSettingsService, codex *CodexSettingsService, gemini *GeminiSettingsService) *ConfigDoctorService {
+	return &ConfigDoctorService{claude: claude, codex: codex, gemini: gemini}
+}
+
+// DiagnoseConfigs 返回全部冲突，按平台与 ID 排序；无法解析的文件由启动自检负责，这里跳过
+func (cds *ConfigDoctorService) DiagnoseConfigs() ([]ConfigDiagnosis, error) {
+	diagnoses := make([]ConfigDiagnosis, 0)
+	if cds.claude != nil {
+		diagnoses = append(diagnoses, cds.diagnoseClaude()...)
+	}
+	if cds.codex != nil {
+		diagnoses = append(diagnoses, cds.diagnoseCodex()...)
+	}
+	if cds.gemini != nil {
+		diagnoses = append(diagnoses, cds.diagnoseGemini()...)
+	}
+	sort.SliceStable(diagnoses, func(i, j int) bool { return diagnoses[i].ID < diagnoses[j].ID })
+	return diagnoses, nil
+}
+
+// FixConfigIssue 修复指定冲突，修复前为涉及的文件保存快照，返回修复后重新诊断的结果
+func (cds *ConfigDoctorService) FixConfigIssue(id string) ([]ConfigDiagnosis, error) {
+	diagnoses, err := cds.DiagnoseConfigs()
+	if err != nil {
+		return nil, err
+	}
+	for _, diagnosis := range diagnoses {
+		if diagnosis.ID != id {
+			continue
+		}
+		if !diagnosis.Fixable {
+			return diagnoses, fmt.Errorf("%s 需要手动处理", diagnosis.Message)
+		}
+		snapshotConfigFiles("doctor:"+id, diagnosis.Path)
+		if err := diagnosis.fix(); err != nil {
+			return diagnoses, err
+		}
+		return cds.DiagnoseConfigs()
+	}
+	return diagnoses, fmt.Errorf("未找到问题 %s", id)
+}
+
+// FixAllConfigIssues 依次修复所有可修复的冲突，各项修复都重新读取文件，单项失败不影响其余各项
+func (cds *ConfigDoctorService) FixAllConfigIssues() ([]ConfigDiagnosis, error) {
+	diagnoses, err := cds.DiagnoseConfigs()
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, diagnosis := range diagnoses {
+		if !diagnosis.Fixable {
+			continue
+		}
+		snapshotConfigFiles("doctor:"+diagnosis.ID, diagnosis.Path)
+		if err := diagnosis.fix(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", diagnosis.Message, err))
+		}
+	}
+	remaining, err := cds.DiagnoseConfigs()
+	if err != nil {
+		return nil, err
+	}
+	return remaining, errors.Join(errs...)
+}
+
+func (cds *ConfigDoctorService) diagnoseClaude() []ConfigDiagnosis {
+	css := cds.claude
+	settingsPath, _, err := css.paths()
+	if err != nil {
+		return nil
+	}
+	raw, err := css.readRawSettings()
+	if err != nil {
+		return nil
+	}
+	env, _ := raw["env"].(map[string]any)
+	baseURL, _ := env["ANTHROPIC_BASE_URL"].(string)
+	token, _ := env["ANTHROPIC_AUTH_TOKEN"].(string)
+	apiKey, _ := env["ANTHROPIC_API_KEY"].(string)
+	relay := css.baseURL()
+	updateEnv := func(apply func(env map[string]any)) func() error {
+		return func() error {
+			raw, err := css.readRawSettings()
+			if err != nil {
+				return err
+			}
+			env, _ := raw["env"].(map[string]any)
+			if env == nil {
+				env = make(map[string]any)
+			}
+			apply(env)
+			raw["env"] = env
+			return css.writeRawSettings(raw)
+		}
+	}
+
+	var diagnoses []ConfigDiagnosis
+	if diagnosis, ok := staleRelayDiagnosis("claude", settingsPath, "env.ANTHROPIC_BASE_URL", baseURL, relay,
+		token == claudeAuthTokenValue, updateEnv(func(env map[string]any) {
+			env["ANTHROPIC_BASE_URL"] = relay
+			env["ANTHROPIC_AUTH_TOKEN"] = claudeAuthTokenValue
+		})); ok {
+		diagnoses = append(diagnoses, diagnosis)
+	}
+	if token != "" && apiKey != "" {
+		diagnosis := ConfigDiagnosis{
+			ID:       "claude:auth-conflict",
+			Platform: "claude",
+			Path:     settingsPath,
+			Field:    "env.ANTHROPIC_API_KEY",
+			Message:  "同时设置了 ANTHROPIC_AUTH_TOKEN 与 ANTHROPIC_API_KEY",
+			Detail:   "Claude Code 会提示鉴权冲突，并可能使用与当前 provider 不匹配的 Key 请求，导致 401",
+		}
+		// 只移除不是 code-switch 写入的那一个
+		remove := ""
+		if token == claudeAuthTokenValue {
+			remove = "ANTHROPIC_API_KEY"
+		} else if state, err := loadDirectApplyState(css.directStatePath()); err == nil && state != nil {
+			for _, key := range state.Keys {
+				switch key {
+				case "ANTHROPIC_AUTH_TOKEN":
+					remove = "ANTHROPIC_API_KEY"
+				case "ANTHROPIC_API_KEY":
+					remove = "ANTHROPIC_AUTH_TOKEN"
+				}
+			}
+		}
+		if remove != "" {
+			diagnosis.Field = "env." + remove
+			diagnosis.Fix = "删除 " + remove
+			diagnosis.Fixable = true
+			diagnosis.fix = updateEnv(func(env map[string]any) { delete(env, remove) })
+		}
+		diagnoses = append(diagnoses, diagnosis)
+	}
+	return diagnoses
+}
+
+func (cds *ConfigDoctorService) diagnoseCodex() []ConfigDiagnosis {
+	css := cds.codex
+	settingsPath, _, err := css.paths()
+	if err != nil {
+		return nil
+	}
+	raw, err := css.readRawConfig()
+	if err != nil {
+		return nil
+	}
+	relay := css.baseURL()
+	updateConfig := func(apply func(raw map[string]any)) func() error {
+		return func() error {
+			raw, err := css.readRawConfig()
+			if err != nil {
+				return err
+			}
+			apply(raw)
+			return css.writeRawConfig(raw)
+		}
+	}
+	providers, _ := normalizeConfigValue(raw["model_providers"]).(map[string]any)
+
+	var diagnoses []ConfigDiagnosis
+	for _, key := range []string{codexProviderKey, codexDirectProviderKey} {
+		provider, ok := normalizeConfigValue(providers[key]).(map[string]any)
+		if !ok {
+			continue
+		}
+		if envKey, _ := provider["env_key"].(string); envKey != "" {
+			key := key
+			diagnoses = append(diagnoses, ConfigDiagnosis{
+				ID:       "codex:env-key:" + key,
+				Platform: "codex",
+				Path:     settingsPath,
+				Field:    "model_providers." + key + ".env_key",
+				Message:  fmt.Sprintf("%s 残留 env_key = %q", key, envKey),
+				Detail:   fmt.Sprintf("Codex 启动时要求环境变量 %s 存在，未设置时报 Missing environment variable: %s；code-switch 的 provider 不需要该变量", envKey, envKey),
+				Fix:      "删除 env_key",
+				Fixable:  true,
+				fix: updateConfig(func(raw map[string]any) {
+					delete(ensureTomlTable(raw, "model_providers")[key], "env_key")
+				}),
+			})
+		}
+		if key == codexProviderKey {
+			baseURL, _ := provider["base_url"].(string)
+			if diagnosis, ok := staleRelayDiagnosis("codex", settingsPath, "model_providers."+key+".base_url", baseURL, relay, true,
+				updateConfig(func(raw map[string]any) {
+					ensureTomlTable(raw, "model_providers")[codexProviderKey]["base_url"] = relay
+				})); ok {
+				diagnoses = append(diagnoses, diagnosis)
+			}
+		}
+	}
+
+	if name, _ := raw["model_provider"].(string); name != "" {
+		_, builtin := codexBuiltinProviders[name]
+		if _, defined := providers[name]; !builtin && !defined {
+			diagnoses = append(diagnoses, ConfigDiagnosis{
+				ID:       "codex:stale-model-provider",
+				Platform: "codex",
+				Path:     settingsPath,
+				Field:    "model_provider",
+				Message:  fmt.Sprintf("model_provider = %q 未在 model_providers 中定义", name),
+				Detail:   "通常是关闭代理或直连时配置只恢复了一半，Codex 会因找不到 provider 而无法启动",
+				Fix:      "删除 model_provider，恢复使用 Codex 默认的 openai",
+				Fixable:  true,
+				fix:      updateConfig(func(raw map[string]any) { delete(raw, "model_provider") }),
+			})
+		}
+	}
+	if name, _ := raw["profile"].(string); name != "" {
+		profiles, _ := normalizeConfigValue(raw["profiles"]).(map[string]any)
+		if _, ok := profiles[name]; !ok {
+			diagnoses = append(diagnoses, ConfigDiagnosis{
+				ID:       "codex:stale-profile",
+				Platform: "codex",
+				Path:     settingsPath,
+				Field:    "profile",
+				Message:  fmt.Sprintf("默认 profile %q 不存在", name),
+				Detail:   "Codex 启动时找不到默认 profile 会直接报错退出",
+				Fix:      "删除顶层的 profile",
+				Fixable:  true,
+				fix:      updateConfig(func(raw map[string]any) { delete(raw, "profile") }),
+			})
+		}
+	}
+
+	authPath, authBackup, err := css.authPaths()
+	if err != nil {
+		return diagnoses
+	}
+	var auth map[string]any
+	if data, err := os.ReadFile(authPath); err == nil {
+		_ = json.Unmarshal(data, &auth)
+	}
+	if auth[codexEnvKey] == codexTokenValue && raw["model_provider"] != codexProviderKey {
+		diagnoses = append(diagnoses, ConfigDiagnosis{
+			ID:       "codex:placeholder-key",
+			Platform: "codex",
+			Path:     authPath,
+			Field:    codexEnvKey,
+			Message:  "auth.json 中仍是代理使用的占位 Key",
+			Detail:   "代理已关闭，Codex 会带着占位 Key 直接请求 OpenAI，结果是 401",
+			Fix:      "还原开启代理前的 auth.json，没有备份时删除占位 Key",
+			Fixable:  true,
+			fix: func() error {
+				if fileExists(authBackup) {
+					return css.restoreAuthFile()
+				}
+				delete(auth, codexEnvKey)
+				if len(auth) == 0 {
+					return os.Remove(authPath)
+				}
+				data, err := json.MarshalIndent(auth, "", "  ")
+				if err != nil {
+					return err
+				}
+				return os.WriteFile(authPath, data, 0o600)
+			},
+		})
+	}
+	return diagnoses
+}
+
+func (cds *ConfigDoctorService) diagnoseGemini() []ConfigDiagnosis {
+	gss := cds.gemini
+	settingsPath, _, err := gss.paths()
+	if err != nil {
+		return nil
+	}
+	envPath, _, err := gss.envPaths()
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(envPath)
+	if err != nil {
+		return nil
+	}
+	env := parseEnvLines(data)
+	relay := gss.baseURL()
+	proxied := env[geminiAPIKeyEnv] == geminiTokenValue
+	direct, _ := gss.DirectApplyStatus()
+
+	var diagnoses []ConfigDiagnosis
+	if diagnosis, ok := staleRelayDiagnosis("gemini", envPath, geminiBaseURLEnv, env[geminiBaseURLEnv], relay, proxied,
+		func() error {
+			return gss.updateEnv(func(env map[string]any) {
+				env[geminiBaseURLEnv] = relay
+				env[geminiAPIKeyEnv] = geminiTokenValue
+			})
+		}); ok {
+		diagnoses = append(diagnoses, diagnosis)
+	}
+	if env["GOOGLE_API_KEY"] != "" && env[geminiAPIKeyEnv] != "" {
+		diagnosis := ConfigDiagnosis{
+			ID:       "gemini:google-api-key",
+			Platform: "gemini",
+			Path:     envPath,
+			Field:    "GOOGLE_API_KEY",
+			Message:  "同时设置了 GOOGLE_API_KEY 与 GEMINI_API_KEY",
+			Detail:   "Gemini CLI 优先使用 GOOGLE_API_KEY，code-switch 写入的 GEMINI_API_KEY 不会生效",
+		}
+		if proxied || direct.Applied {
+			diagnosis.Fix = "删除 GOOGLE_API_KEY"
+			diagnosis.Fixable = true
+			diagnosis.fix = func() error {
+				return gss.updateEnv(func(env map[string]any) { delete(env, "GOOGLE_API_KEY") })
+			}
+		}
+		diagnoses = append(diagnoses, diagnosis)
+	}
+	if proxied || direct.Applied {
+		raw, err := readClaudeSettingsFile(settingsPath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return diagnoses
+		}
+		security, _ := raw["security"].(map[string]any)
+		auth, _ := security["auth"].(map[string]any)
+		if selected, _ := auth["selectedType"].(string); selected != "" && selected != geminiAuthTypeAPIKey {
+			diagnoses = append(diagnoses, ConfigDiagnosis{
+				ID:       "gemini:auth-type",
+				Platform: "gemini",
+				Path:     settingsPath,
+				Field:    "security.auth.selectedType",
+				Message:  fmt.Sprintf("认证方式为 %s", selected),
+				Detail:   ".env 中的地址与 Key 只在 gemini-api-key 认证方式下生效，当前请求不会经过 code-switch",
+				Fix:      "切换为 gemini-api-key",
+				Fixable:  true,
+				fix: func() error {
+					_, err := gss.setAuthType(geminiAuthTypeAPIKey)
+					return err
+				},
+			})
+		}
+	}
+	return diagnoses
+}
+
+// staleRelayDiagnosis 地址指向本机但不是当前中转（端口变更或其他切换工具留下的），
+// 或使用 code-switch 占位 Key 却指向其他地址时报告，修复为当前中转地址
+func staleRelayDiagnosis(platform, path, field, baseURL, relay string, placeholder bool, fix func() error) (ConfigDiagnosis, bool) {
+	if baseURL == "" || strings.EqualFold(strings.TrimRight(baseURL, "/"), strings.TrimRight(relay, "/")) {
+		return ConfigDiagnosis{}, false
+	}
+	diagnosis := ConfigDiagnosis{
+		ID:       platform + ":foreign-base-url",
+		Platform: platform,
+		Path:     path,
+		Field:    field,
+		Fix:      "改为当前中转地址 " + relay,
+		Fixable:  true,
+		fix:      fix,
+	}
+	switch {
+	case placeholder:
+		diagnosis.Message = fmt.Sprintf("使用 code-switch 占位 Key，但地址是 %s", baseURL)
+		diagnosis.Detail = "占位 Key 只能被本地中转识别，发往其他地址的请求都会鉴权失败"
+	case isLoopbackURL(baseURL):
+		diagnosis.Message = fmt.Sprintf("地址 %s 指向本机的其他端口", baseURL)
+		diagnosis.Detail = "可能是中转端口变更前写入的，或其他切换工具的本地代理；该端口没有服务时请求会连接失败"
+	default:
+		return ConfigDiagnosis{}, false
+	}
+	return diagnosis, true
+}
+
+func isLoopbackURL(raw string) bool {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(parsed.Hostname()) {
+	case "127.0.0.1", "localhost", "::1", "0.0.0.0":
+		return true
+	}
+	return false
+}
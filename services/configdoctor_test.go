@@ -0,0 +1,50 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigDoctorFixesConflicts(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeSkillFiles(t, home, map[string]string{
+		".codex/config.toml":    "# mine\nmodel_provider = \"gone\"\n\n[model_providers.code-switch]\nname = \"code-switch\"\nbase_url = \"http://127.0.0.1:9999\"\nenv_key = \"OPENAI_API_KEY\"\n",
+		".codex/auth.json":      `{"OPENAI_API_KEY": "code-switch"}`,
+		".claude/settings.json": `{"env": {"ANTHROPIC_BASE_URL": "http://127.0.0.1:18100", "ANTHROPIC_AUTH_TOKEN": "code-switch", "ANTHROPIC_API_KEY": "sk-old"}}`,
+	})
+	cds := NewConfigDoctorService(NewClaudeSettingsService(":18100"), NewCodexSettingsService(":18100"), NewGeminiSettingsService(":18100"))
+	diagnoses, err := cds.DiagnoseConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, 0, len(diagnoses))
+	for _, diagnosis := range diagnoses {
+		ids = append(ids, diagnosis.ID)
+	}
+	want := "claude:auth-conflict,codex:env-key:code-switch,codex:foreign-base-url,codex:placeholder-key,codex:stale-model-provider"
+	if got := strings.Join(ids, ","); got != want {
+		t.Fatalf("diagnoses = %s, want %s", got, want)
+	}
+
+	remaining, err := cds.FixAllConfigIssues()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remaining) != 0 {
+		t.Fatalf("unexpected remaining diagnoses %+v", remaining)
+	}
+	data, _ := os.ReadFile(filepath.Join(home, ".codex", "config.toml"))
+	if !strings.Contains(string(data), "# mine") || strings.Contains(string(data), "env_key") || !strings.Contains(string(data), "127.0.0.1:18100") {
+		t.Fatalf("unexpected config.toml:\n%s", data)
+	}
+	if fileExists(filepath.Join(home, ".codex", "auth.json")) {
+		t.Fatal("placeholder-only auth.json should be removed")
+	}
+	raw, _ := readClaudeSettingsFile(filepath.Join(home, ".claude", "settings.json"))
+	if _, ok := raw["env"].(map[string]any)["ANTHROPIC_API_KEY"]; ok {
+		t.Fatal("ANTHROPIC_API_KEY should be removed")
+	}
+}
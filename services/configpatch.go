@@ -0,0 +1,176 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+var errConfigPatchMismatch = errors.New("定点修改结果与预期不一致")
+
+// configChange 两份配置之间的一处差异：键被删除，或取值需要改为新配置中的值
+type configChange struct {
+	path   []string
+	delete bool
+}
+
+// diffConfigPayload 逐层比较两份配置，两侧都是 object 时继续向下比较，其余情况整体替换
+func diffConfigPayload(current, next map[string]any, parent []string) []configChange {
+	keys := make([]string, 0, len(current)+len(next))
+	for key := range current {
+		keys = append(keys, key)
+	}
+	for key := range next {
+		if _, ok := current[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	changes := make([]configChange, 0)
+	for _, key := range keys {
+		path := append(append([]string(nil), parent...), key)
+		old, hadOld := current[key]
+		value, hasNew := next[key]
+		switch {
+		case !hasNew:
+			changes = append(changes, configChange{path: path, delete: true})
+		case !hadOld:
+			changes = append(changes, configChange{path: path})
+		default:
+			old, value = normalizeConfigValue(old), normalizeConfigValue(value)
+			oldMap, oldIsMap := old.(map[string]any)
+			newMap, newIsMap := value.(map[string]any)
+			if oldIsMap && newIsMap {
+				changes = append(changes, diffConfigPayload(oldMap, newMap, path)...)
+			} else if !reflect.DeepEqual(old, value) {
+				changes = append(changes, configChange{path: path})
+			}
+		}
+	}
+	return changes
+}
+
+// normalizeConfigValue 把代码中构造的 map[string]string、[]string、int 等转换为解析结果使用的类型，
+// 取值相同时不产生差异
+func normalizeConfigValue(value any) any {
+	switch value.(type) {
+	case nil, string, bool, int64, float64, map[string]any, []any:
+		return value
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint())
+	case reflect.Float32:
+		return rv.Float()
+	case reflect.Slice, reflect.Array:
+		items := make([]any, rv.Len())
+		for i := range items {
+			items[i] = rv.Index(i).Interface()
+		}
+		return items
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return value
+		}
+		result := make(map[string]any, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			result[iter.Key().String()] = iter.Value().Interface()
+		}
+		return result
+	}
+	return value
+}
+
+func configValueAt(payload map[string]any, path []string) (any, bool) {
+	current := payload
+	for _, segment := range path[:len(path)-1] {
+		next, ok := normalizeConfigValue(current[segment]).(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		current = next
+	}
+	value, ok := current[path[len(path)-1]]
+	return value, ok
+}
+
+func configPathHasPrefix(path []string, prefix []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if path[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func configPathEqual(a, b []string) bool {
+	return len(a) == len(b) && configPathHasPrefix(a, b)
+}
+
+// encodeTOMLPreserving 在原文上按差异做定点修改，注释、键顺序与格式保持不变；
+// 原文为空或无法定点修改时退回整体序列化
+func encodeTOMLPreserving(original []byte, payload map[string]any) ([]byte, error) {
+	if len(bytes.TrimSpace(original)) > 0 {
+		if data, err := patchTOMLDocument(original, payload); err == nil {
+			return data, nil
+		}
+	}
+	return toml.Marshal(payload)
+}
+
+// encodeJSONPreserving 同 encodeTOMLPreserving，支持带注释与尾逗号的 JSONC
+func encodeJSONPreserving(original []byte, payload map[string]any) ([]byte, error) {
+	if len(bytes.TrimSpace(original)) > 0 {
+		if data, err := patchJSONDocument(original, payload); err == nil {
+			return data, nil
+		}
+	}
+	return json.MarshalIndent(payload, "", "  ")
+}
+
+func spliceConfigText(data []byte, start, end int, text string) []byte {
+	result := make([]byte, 0, len(data)-(end-start)+len(text))
+	result = append(result, data[:start]...)
+	result = append(result, text...)
+	return append(result, data[end:]...)
+}
+
+func lineStartOf(data []byte, pos int) int {
+	return bytes.LastIndexByte(data[:pos], '\n') + 1
+}
+
+// lineEndOf 返回 pos 所在行换行符的位置，最后一行返回文档末尾
+func lineEndOf(data []byte, pos int) int {
+	if idx := bytes.IndexByte(data[pos:], '\n'); idx >= 0 {
+		return pos + idx
+	}
+	return len(data)
+}
+
+// lineIndentOf 返回 pos 所在行的缩进
+func lineIndentOf(data []byte, pos int) string {
+	start := lineStartOf(data, pos)
+	end := start
+	for end < len(data) && (data[end] == ' ' || data[end] == '\t') {
+		end++
+	}
+	return string(data[start:end])
+}
+
+func skipInlineSpace(data []byte, pos int) int {
+	for pos < len(data) && (data[pos] == ' ' || data[pos] == '\t' || data[pos] == '\r') {
+		pos++
+	}
+	return pos
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+func TestPatchTOMLKeepsComments(t *testing.T) {
+	original := `# Codex config
+model = "gpt-5" # main model
+approval_policy = "on-request"
+
+[model_providers.proxy]
+# my proxy
+base_url = "https://example.com/v1"
+wire_api = "responses"
+
+# filesystem server
+[mcp_servers.fs]
+command = "npx"
+args = ["-y", "@mcp/fs"]
+
+[mcp_servers.git]
+command = "uvx"
+env = { GIT_DIR = "/tmp" }
+`
+	payload := make(map[string]any)
+	if err := toml.Unmarshal([]byte(original), &payload); err != nil {
+		t.Fatal(err)
+	}
+	payload["model"] = "gpt-5-codex"
+	payload["model_provider"] = "code-switch"
+	providers := payload["model_providers"].(map[string]any)
+	providers["code-switch"] = map[string]any{"name": "code-switch", "base_url": "http://127.0.0.1:18100", "requires_openai_auth": false}
+	servers := payload["mcp_servers"].(map[string]any)
+	delete(servers, "fs")
+	servers["git"].(map[string]any)["env"] = map[string]string{"GIT_DIR": "/repo"}
+	servers["git"].(map[string]any)["args"] = []string{"mcp-git"}
+
+	data, err := encodeTOMLPreserving([]byte(original), payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(data)
+	for _, want := range []string{"# Codex config\nmodel = \"gpt-5-codex\" # main model\n", "# my proxy\n", "[model_providers.code-switch]\n", `env = { GIT_DIR = "/repo" }`} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("missing %q in:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "fs") || strings.Contains(text, "filesystem") || !strings.Contains(text, "[mcp_servers.git]") {
+		t.Fatalf("mcp_servers.fs not removed cleanly:\n%s", text)
+	}
+	if strings.Index(text, "[model_providers.code-switch]") > strings.Index(text, "[mcp_servers.git]") {
+		t.Fatalf("new provider table not placed with its siblings:\n%s", text)
+	}
+	parsed := make(map[string]any)
+	if err := toml.Unmarshal(data, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if parsed["model_provider"] != "code-switch" || len(parsed["mcp_servers"].(map[string]any)) != 1 {
+		t.Fatalf("parsed = %+v", parsed)
+	}
+}
+
+func TestPatchJSONCKeepsComments(t *testing.T) {
+	original := `{
+    // editor settings
+    "editor.fontSize": 14,
+    "roo-cline.autoImportSettingsPath": "/old", // roo
+    "nested": {
+        "a": 1,
+    },
+    "empty": {}
+}
+`
+	payload, err := parseJSONC([]byte(original))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload["roo-cline.autoImportSettingsPath"] = "/new?a=1&b=2"
+	payload["nested"].(map[string]any)["b"] = 2
+	payload["empty"].(map[string]any)["x"] = true
+	payload["added"] = map[string]any{"k": "v"}
+	delete(payload, "editor.fontSize")
+
+	data, err := encodeJSONPreserving([]byte(original), payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(data)
+	for _, want := range []string{"// editor settings", `"roo-cline.autoImportSettingsPath": "/new?a=1&b=2", // roo`, "        \"b\": 2,\n", "\"empty\": {\n        \"x\": true\n    }", "    \"added\": {\n        \"k\": \"v\"\n    }\n}"} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("missing %q in:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "fontSize") {
+		t.Fatalf("deleted key still present:\n%s", text)
+	}
+	parsed, err := parseJSONC(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := parseJSONC([]byte(`{"roo-cline.autoImportSettingsPath":"/new?a=1&b=2","nested":{"a":1,"b":2},"empty":{"x":true},"added":{"k":"v"}}`))
+	if !reflect.DeepEqual(parsed, expected) {
+		t.Fatalf("parsed = %+v", parsed)
+	}
+}
+
+func TestCodexProxyKeepsConfigComments(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeSkillFiles(t, home, map[string]string{
+		".codex/config.toml": "# personal settings\nmodel = \"o3\" # keep me\n\n[mcp_servers.fs]\ncommand = \"npx\"\n",
+	})
+	css := NewCodexSettingsService(":18100")
+	if err := css.EnableProxy(); err != nil {
+		t.Fatal(err)
+	}
+	defer css.DisableProxy()
+	settingsPath, _, _ := css.paths()
+	data, err := os.ReadFile(settingsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"# personal settings\n", "# keep me", "[mcp_servers.fs]", "[model_providers.code-switch]"} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("missing %q in:\n%s", want, data)
+		}
+	}
+	if status, _ := css.ProxyStatus(); !status.Enabled {
+		t.Fatalf("proxy not enabled:\n%s", data)
+	}
+}
+
+func TestCodexProfileKeepsGlobalConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeSkillFiles(t, home, map[string]string{
+		".codex/config.toml": "# mine\nmodel = \"o3\"\nmodel_provider = \"openai\"\n",
+	})
+	css := NewCodexSettingsService(":18100")
+	if err := css.EnableProfile(); err != nil {
+		t.Fatal(err)
+	}
+	settingsPath, _, _ := css.paths()
+	data, _ := os.ReadFile(settingsPath)
+	for _, want := range []string{"# mine\n", "model = \"o3\"", "model_provider = \"openai\"", "[profiles.code-switch]", "[model_providers.code-switch]"} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("missing %q in:\n%s", want, data)
+		}
+	}
+	if status, _ := css.ProfileStatus(); !status.Enabled || status.Default {
+		t.Fatalf("unexpected profile status %+v", status)
+	}
+	if status, _ := css.ProxyStatus(); status.Enabled {
+		t.Fatal("profile mode must not enable the global proxy")
+	}
+	if err := css.SetProfileDefault(true); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := css.ProfileStatus(); !status.Default {
+		t.Fatal("profile should be the default")
+	}
+	if err := css.DisableProfile(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(settingsPath)
+	if want := "# mine\nmodel = \"o3\"\nmodel_provider = \"openai\""; strings.TrimSpace(string(data)) != want {
+		t.Fatalf("config not restored:\n%s", data)
+	}
+}
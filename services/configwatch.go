@@ -0,0 +1,355 @@
+package services
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	configWatchTargetClaude = "claude"
+	configWatchTargetCodex  = "codex"
+
+	// 编辑器与 CLI 保存时往往连续触发多次写入，合并后再检查
+	configWatchDebounce = 500 * time.Millisecond
+	// 定期重新检查并刷新监听目录，覆盖新开启代理的工具与之前不存在的目录
+	configWatchRescanInterval = 30 * time.Second
+)
+
+// ConfigWatchPolicy 监听受管配置文件，代理字段被改写时提示，AutoReapply 开启时自动重新写入
+type ConfigWatchPolicy struct {
+	Enabled     bool `json:"enabled"`
+	AutoReapply bool `json:"auto_reapply"`
+}
+
+// ConfigDrift 代理已开启但配置文件中的代理字段被其他程序改写。
+// Target 为 claude、codex 或 custom/<id>
+type ConfigDrift struct {
+	Target     string    `json:"target"`
+	Name       string    `json:"name"`
+	Files      []string  `json:"files"`
+	DetectedAt time.Time `json:"detected_at"`
+	Reapplied  bool      `json:"reapplied"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// proxyIntents 记录 Claude / Codex 代理的开关意图，由 EnableProxy / DisableProxy 维护；
+// 自定义工具以状态文件为准
+var proxyIntents = struct {
+	sync.Mutex
+	enabled map[string]bool
+}{enabled: make(map[string]bool)}
+
+func setProxyIntent(target string, enabled bool) {
+	proxyIntents.Lock()
+	defer proxyIntents.Unlock()
+	proxyIntents.enabled[target] = enabled
+}
+
+func proxyIntent(target string) (bool, bool) {
+	proxyIntents.Lock()
+	defer proxyIntents.Unlock()
+	enabled, ok := proxyIntents.enabled[target]
+	return enabled, ok
+}
+
+type ConfigWatchService struct {
+	appSettings *AppSettingsService
+	claude      *ClaudeSettingsService
+	codex       *CodexSettingsService
+	custom      *CustomCliService
+
+	mu      sync.Mutex
+	drifts  map[string]ConfigDrift
+	handler func(ConfigDrift)
+	stopCh  chan struct{}
+	checkMu sync.Mutex
+}
+
+func NewConfigWatchService(appSettings *AppSettingsService, claude *ClaudeSettingsService, codex *CodexSettingsService, custom *CustomCliService) *ConfigWatchService {
+	return &ConfigWatchService{
+		appSettings: appSettings,
+		claude:      claude,
+		codex:       codex,
+		custom:      custom,
+		drifts:      make(map[string]ConfigDrift),
+	}
+}
+
+func defaultConfigWatchPolicy() ConfigWatchPolicy {
+	return ConfigWatchPolicy{Enabled: true}
+}
+
+// SetDriftHandler 发现新的改写或自动重新写入后回调
+func (cws *ConfigWatchService) SetDriftHandler(handler func(ConfigDrift)) {
+	cws.mu.Lock()
+	defer cws.mu.Unlock()
+	cws.handler = handler
+}
+
+// Start 以启动时已开启的代理作为期望状态，随后监听受管文件所在目录
+func (cws *ConfigWatchService) Start() error {
+	cws.mu.Lock()
+	defer cws.mu.Unlock()
+	if cws.stopCh != nil {
+		return nil
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	cws.seedIntents()
+	stopCh := make(chan struct{})
+	cws.stopCh = stopCh
+	go cws.run(watcher, stopCh)
+	return nil
+}
+
+func (cws *ConfigWatchService) Stop() error {
+	cws.mu.Lock()
+	defer cws.mu.Unlock()
+	if cws.stopCh != nil {
+		close(cws.stopCh)
+		cws.stopCh = nil
+	}
+	return nil
+}
+
+// GetConfigWatchPolicy 返回配置监听设置
+func (cws *ConfigWatchService) GetConfigWatchPolicy() ConfigWatchPolicy {
+	return cws.policy()
+}
+
+// SaveConfigWatchPolicy 保存配置监听设置
+func (cws *ConfigWatchService) SaveConfigWatchPolicy(policy ConfigWatchPolicy) (ConfigWatchPolicy, error) {
+	if cws.appSettings == nil {
+		return policy, nil
+	}
+	_, err := cws.appSettings.update(func(settings *AppSettings) {
+		settings.ConfigWatch = policy
+	})
+	return policy, err
+}
+
+// ListConfigDrifts 返回尚未处理的改写，按发现时间排序
+func (cws *ConfigWatchService) ListConfigDrifts() []ConfigDrift {
+	cws.mu.Lock()
+	defer cws.mu.Unlock()
+	drifts := make([]ConfigDrift, 0, len(cws.drifts))
+	for _, drift := range cws.drifts {
+		drifts = append(drifts, drift)
+	}
+	sort.Slice(drifts, func(i, j int) bool {
+		return drifts[i].DetectedAt.Before(drifts[j].DetectedAt)
+	})
+	return drifts
+}
+
+// CheckConfigDrifts 立即检查一次，返回当前的改写
+func (cws *ConfigWatchService) CheckConfigDrifts() []ConfigDrift {
+	cws.check()
+	return cws.ListConfigDrifts()
+}
+
+// ReapplyConfig 重新写入指定目标的代理配置
+func (cws *ConfigWatchService) ReapplyConfig(target string) error {
+	var err error
+	switch {
+	case target == configWatchTargetClaude && cws.claude != nil:
+		err = cws.claude.ReapplyProxy()
+	case target == configWatchTargetCodex && cws.codex != nil:
+		err = cws.codex.ReapplyProxy()
+	case strings.HasPrefix(target, customCliScope("")) && cws.custom != nil:
+		err = cws.custom.EnableProxy(strings.TrimPrefix(target, customCliScope("")))
+	default:
+		return fmt.Errorf("未知的配置目标 %s", target)
+	}
+	if err != nil {
+		return err
+	}
+	cws.mu.Lock()
+	delete(cws.drifts, target)
+	cws.mu.Unlock()
+	return nil
+}
+
+func (cws *ConfigWatchService) policy() ConfigWatchPolicy {
+	if cws.appSettings == nil {
+		return defaultConfigWatchPolicy()
+	}
+	settings, err := cws.appSettings.GetAppSettings()
+	if err != nil {
+		return defaultConfigWatchPolicy()
+	}
+	return settings.ConfigWatch
+}
+
+func (cws *ConfigWatchService) seedIntents() {
+	if cws.claude != nil {
+		if _, ok := proxyIntent(configWatchTargetClaude); !ok {
+			if status, err := cws.claude.ProxyStatus(); err == nil {
+				setProxyIntent(configWatchTargetClaude, status.Enabled)
+			}
+		}
+	}
+	if cws.codex != nil {
+		if _, ok := proxyIntent(configWatchTargetCodex); !ok {
+			if status, err := cws.codex.ProxyStatus(); err == nil {
+				setProxyIntent(configWatchTargetCodex, status.Enabled)
+			}
+		}
+	}
+}
+
+func (cws *ConfigWatchService) run(watcher *fsnotify.Watcher, stopCh chan struct{}) {
+	defer watcher.Close()
+	watched := make(map[string]struct{})
+	managed := cws.syncWatches(watcher, watched)
+	cws.check()
+
+	debounce := time.NewTimer(configWatchDebounce)
+	debounce.Stop()
+	ticker := time.NewTicker(configWatchRescanInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if _, hit := managed[filepath.Clean(event.Name)]; hit {
+				debounce.Reset(configWatchDebounce)
+			}
+		case <-watcher.Errors:
+		case <-debounce.C:
+			managed = cws.syncWatches(watcher, watched)
+			cws.check()
+		case <-ticker.C:
+			managed = cws.syncWatches(watcher, watched)
+			cws.check()
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// managedFiles 返回期望处于代理状态的配置文件
+func (cws *ConfigWatchService) managedFiles() []string {
+	files := make([]string, 0)
+	if enabled, _ := proxyIntent(configWatchTargetClaude); enabled && cws.claude != nil {
+		if path, _, err := cws.claude.paths(); err == nil {
+			files = append(files, path)
+		}
+	}
+	if enabled, _ := proxyIntent(configWatchTargetCodex); enabled && cws.codex != nil {
+		if path, _, err := cws.codex.paths(); err == nil {
+			files = append(files, path)
+		}
+		if path, _, err := cws.codex.authPaths(); err == nil {
+			files = append(files, path)
+		}
+	}
+	if cws.custom != nil {
+		files = append(files, cws.custom.proxyConfigFiles()...)
+	}
+	return files
+}
+
+// syncWatches 监听受管文件所在的目录而不是文件本身，原子替换（写临时文件再 rename）后仍能收到事件
+func (cws *ConfigWatchService) syncWatches(watcher *fsnotify.Watcher, watched map[string]struct{}) map[string]struct{} {
+	managed := make(map[string]struct{})
+	dirs := make(map[string]struct{})
+	for _, file := range cws.managedFiles() {
+		file = filepath.Clean(file)
+		managed[file] = struct{}{}
+		dirs[filepath.Dir(file)] = struct{}{}
+	}
+	for dir := range watched {
+		if _, ok := dirs[dir]; !ok {
+			_ = watcher.Remove(dir)
+			delete(watched, dir)
+		}
+	}
+	for dir := range dirs {
+		if _, ok := watched[dir]; ok {
+			continue
+		}
+		if err := watcher.Add(dir); err == nil {
+			watched[dir] = struct{}{}
+		}
+	}
+	return managed
+}
+
+// check 对比期望状态与文件实际内容，新发现的改写按设置自动重新写入并回调
+func (cws *ConfigWatchService) check() {
+	cws.checkMu.Lock()
+	defer cws.checkMu.Unlock()
+	policy := cws.policy()
+	current := make(map[string]ConfigDrift)
+	if policy.Enabled {
+		for _, drift := range cws.detect() {
+			current[drift.Target] = drift
+		}
+	}
+
+	cws.mu.Lock()
+	fresh := make([]ConfigDrift, 0)
+	for target, drift := range current {
+		if existing, ok := cws.drifts[target]; ok {
+			current[target] = existing
+			continue
+		}
+		fresh = append(fresh, drift)
+	}
+	cws.drifts = current
+	handler := cws.handler
+	cws.mu.Unlock()
+
+	for _, drift := range fresh {
+		if policy.AutoReapply {
+			if err := cws.ReapplyConfig(drift.Target); err != nil {
+				drift.Error = err.Error()
+			} else {
+				drift.Reapplied = true
+			}
+		}
+		if handler != nil {
+			handler(drift)
+		}
+	}
+}
+
+func (cws *ConfigWatchService) detect() []ConfigDrift {
+	now := time.Now()
+	drifts := make([]ConfigDrift, 0)
+	if enabled, _ := proxyIntent(configWatchTargetClaude); enabled && cws.claude != nil {
+		if status, err := cws.claude.ProxyStatus(); err == nil && !status.Enabled {
+			path, _, _ := cws.claude.paths()
+			drifts = append(drifts, ConfigDrift{Target: configWatchTargetClaude, Name: "Claude Code", Files: []string{path}, DetectedAt: now})
+		}
+	}
+	if enabled, _ := proxyIntent(configWatchTargetCodex); enabled && cws.codex != nil {
+		// config.toml 无法解析时 ProxyStatus 返回错误，同样视为被改写
+		if status, err := cws.codex.ProxyStatus(); err != nil || !status.Enabled {
+			path, _, _ := cws.codex.paths()
+			drifts = append(drifts, ConfigDrift{Target: configWatchTargetCodex, Name: "Codex", Files: []string{path}, DetectedAt: now})
+		}
+	}
+	if cws.custom != nil {
+		tools, _ := cws.custom.driftedTools()
+		for _, tool := range tools {
+			files := make([]string, 0, len(tool.ConfigFiles))
+			for _, file := range tool.ConfigFiles {
+				files = append(files, file.Path)
+			}
+			drifts = append(drifts, ConfigDrift{Target: customCliScope(tool.ID), Name: tool.Name, Files: files, DetectedAt: now})
+		}
+	}
+	return drifts
+}
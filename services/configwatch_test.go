@@ -0,0 +1,73 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigWatchDetectsClaudeDrift(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := filepath.Join(home, claudeSettingsDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	settingsPath := filepath.Join(dir, claudeSettingsFileName)
+	backupPath := filepath.Join(dir, claudeBackupFileName)
+	if err := os.WriteFile(settingsPath, []byte(`{"model":"opus"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	css := NewClaudeSettingsService(":18100")
+	if err := css.EnableProxy(); err != nil {
+		t.Fatal(err)
+	}
+	defer css.DisableProxy()
+	cws := NewConfigWatchService(nil, css, nil, nil)
+	var notified []ConfigDrift
+	cws.SetDriftHandler(func(drift ConfigDrift) {
+		notified = append(notified, drift)
+	})
+	if drifts := cws.CheckConfigDrifts(); len(drifts) != 0 {
+		t.Fatalf("unexpected drifts: %+v", drifts)
+	}
+
+	overwritten := `{"model":"sonnet","env":{"ANTHROPIC_BASE_URL":"https://api.anthropic.com"}}`
+	if err := os.WriteFile(settingsPath, []byte(overwritten), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	drifts := cws.CheckConfigDrifts()
+	if len(drifts) != 1 || drifts[0].Target != configWatchTargetClaude || drifts[0].Files[0] != settingsPath {
+		t.Fatalf("drifts = %+v", drifts)
+	}
+	cws.CheckConfigDrifts()
+	if len(notified) != 1 {
+		t.Fatalf("handler called %d times", len(notified))
+	}
+
+	if err := cws.ReapplyConfig(configWatchTargetClaude); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := css.ProxyStatus(); !status.Enabled {
+		t.Fatal("proxy not reapplied")
+	}
+	raw, err := readClaudeSettingsFile(settingsPath)
+	if err != nil || raw["model"] != "sonnet" {
+		t.Fatalf("external changes lost: %v %v", raw, err)
+	}
+	backup, _ := os.ReadFile(backupPath)
+	if string(backup) != `{"model":"opus"}` {
+		t.Fatalf("backup overwritten: %s", backup)
+	}
+	if drifts := cws.ListConfigDrifts(); len(drifts) != 0 {
+		t.Fatalf("drift not cleared: %+v", drifts)
+	}
+
+	if err := css.DisableProxy(); err != nil {
+		t.Fatal(err)
+	}
+	if drifts := cws.CheckConfigDrifts(); len(drifts) != 0 {
+		t.Fatalf("disabled proxy reported as drift: %+v", drifts)
+	}
+}
@@ -0,0 +1,554 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+const (
+	capabilityMatrixFile = "provider-capabilities.json"
+	connectivityTimeout  = 30 * time.Second
+	claudeProbeModel     = "claude-haiku-4-5-20251001"
+	anthropicAPIVersion  = "2023-06-01"
+
+	CapabilityStreaming     = "streaming"
+	CapabilityToolUse       = "tool_use"
+	CapabilityVision        = "vision"
+	CapabilityPromptCaching = "prompt_caching"
+	CapabilityCountTokens   = "count_tokens"
+	CapabilityThinking      = "thinking"
+)
+
+// relayProbeTokenHeader 经由 relay 的探测请求携带本次运行的密钥，relay 只对持有密钥的请求启用 relayTargetHeader
+const relayProbeTokenHeader = "X-Code-Switch-Probe-Token"
+
+// relayProbeToken 每次启动随机生成、只保存在内存中，外部客户端无法伪造探测请求绕过故障切换与用量统计
+var relayProbeToken = newRelayProbeToken()
+
+// 1x1 透明 PNG，用于探测图片输入
+const probeImageBase64 = "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNkYPhfDwAChwGA60e6kgAAAABJRU5ErkJggg=="
+
+type ConnectivityResult struct {
+	Provider  string  `json:"provider"`
+	Success   bool    `json:"success"`
+	HttpCode  int     `json:"http_code"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// ProviderCapabilities 探测得到的能力矩阵，未出现在 Capabilities 中的能力视为未知
+type ProviderCapabilities struct {
+	Platform     string            `json:"platform"`
+	Provider     string            `json:"provider"`
+	Capabilities map[string]bool   `json:"capabilities"`
+	Errors       map[string]string `json:"errors,omitempty"`
+	DetectedAt   time.Time         `json:"detected_at"`
+}
+
+type capabilityProbe struct {
+	name     string
+	endpoint string
+	body     map[string]any
+}
+
+// capabilityMatrix 内存缓存，relay 每次请求都会读取
+var capabilityMatrix = struct {
+	sync.RWMutex
+	loaded bool
+	data   map[string]map[string]ProviderCapabilities
+}{}
+
+type ConnectivityTestService struct {
+	providerService *ProviderService
+	relayAddr       string
+	httpClient      *http.Client
+}
+
+func NewConnectivityTestService(providerService *ProviderService, relayAddr string) *ConnectivityTestService {
+	return &ConnectivityTestService{
+		providerService: providerService,
+		relayAddr:       relayAddr,
+		httpClient:      &http.Client{Timeout: connectivityTimeout},
+	}
+}
+
+// TestConnectivity 发送一条最小请求，验证地址、Key 与模型映射是否可用
+func (cts *ConnectivityTestService) TestConnectivity(kind string, providerID int) (ConnectivityResult, error) {
+	platform, provider, err := cts.findProvider(kind, providerID)
+	if err != nil {
+		return ConnectivityResult{}, err
+	}
+	probe := basicProbe(platform, provider)
+	result := ConnectivityResult{Provider: provider.Name}
+	start := time.Now()
+	status, err := cts.send(platform, provider, probe)
+	result.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	result.HttpCode = status
+	if err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+	result.Success = true
+	return result, nil
+}
+
+// TestThroughRelay 经由本地 relay 向指定 provider 发送探测请求（客户端 → relay → provider），
+// 同时验证 relay 的模型映射、鉴权注入与请求改写链路
+func (cts *ConnectivityTestService) TestThroughRelay(kind string, providerID int) (ConnectivityResult, error) {
+	platform, provider, err := cts.findProvider(kind, providerID)
+	if err != nil {
+		return ConnectivityResult{}, err
+	}
+	probe := relayProbe(platform)
+	payload, err := json.Marshal(probe.body)
+	if err != nil {
+		return ConnectivityResult{}, err
+	}
+	req, err := http.NewRequest(http.MethodPost, joinURL(relayBaseURL(cts.relayAddr), probe.endpoint), bytes.NewReader(payload))
+	if err != nil {
+		return ConnectivityResult{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(relayTargetHeader, provider.Name)
+	req.Header.Set(relayProbeTokenHeader, relayProbeToken)
+	if platform == "claude" {
+		req.Header.Set("Anthropic-Version", anthropicAPIVersion)
+	}
+
+	result := ConnectivityResult{Provider: provider.Name}
+	start := time.Now()
+	resp, err := cts.httpClient.Do(req)
+	result.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		result.Error = fmt.Sprintf("relay 不可用: %v", err)
+		return result, nil
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	result.HttpCode = resp.StatusCode
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		message := gjson.GetBytes(body, "error").String()
+		if message == "" {
+			message = strings.TrimSpace(string(body))
+		}
+		result.Error = fmt.Sprintf("HTTP %d: %s", resp.StatusCode, truncateProbeMessage(message))
+		return result, nil
+	}
+	result.Success = true
+	return result, nil
+}
+
+func newRelayProbeToken() string {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
+
+// validRelayProbeToken 判断请求携带的探测密钥是否由本次运行的 ConnectivityTestService 签发
+func validRelayProbeToken(provided string) bool {
+	return provided != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(relayProbeToken)) == 1
+}
+
+// relayProbe 经由 relay 的探测使用客户端视角的模型名，由 relay 负责映射
+func relayProbe(platform string) capabilityProbe {
+	return basicProbe(platform, Provider{})
+}
+
+// DetectCapabilities 逐项探测 provider 支持的能力并写入能力矩阵
+func (cts *ConnectivityTestService) DetectCapabilities(kind string, providerID int) (ProviderCapabilities, error) {
+	platform, provider, err := cts.findProvider(kind, providerID)
+	if err != nil {
+		return ProviderCapabilities{}, err
+	}
+	if _, err := cts.send(platform, provider, basicProbe(platform, provider)); err != nil {
+		return ProviderCapabilities{}, fmt.Errorf("基础请求失败，无法探测能力: %w", err)
+	}
+
+	caps := ProviderCapabilities{
+		Platform:     platform,
+		Provider:     provider.Name,
+		Capabilities: make(map[string]bool),
+		Errors:       make(map[string]string),
+		DetectedAt:   time.Now(),
+	}
+	for _, probe := range capabilityProbes(platform, provider) {
+		status, err := cts.send(platform, provider, probe)
+		switch {
+		case err == nil:
+			caps.Capabilities[probe.name] = true
+		case status >= 400 && status < 500:
+			// 4xx 说明上游明确拒绝该特性；5xx 或网络错误无法判断，保持未知
+			caps.Capabilities[probe.name] = false
+			caps.Errors[probe.name] = err.Error()
+		default:
+			caps.Errors[probe.name] = err.Error()
+		}
+	}
+	if err := saveProviderCapabilities(caps); err != nil {
+		return caps, err
+	}
+	return caps, nil
+}
+
+// GetCapabilities 返回某个平台已探测的能力矩阵
+func (cts *ConnectivityTestService) GetCapabilities(kind string) ([]ProviderCapabilities, error) {
+	platform := normalizePresetKind(kind)
+	if err := ensureCapabilityMatrixLoaded(); err != nil {
+		return nil, err
+	}
+	capabilityMatrix.RLock()
+	defer capabilityMatrix.RUnlock()
+	result := make([]ProviderCapabilities, 0, len(capabilityMatrix.data[platform]))
+	for _, caps := range capabilityMatrix.data[platform] {
+		result = append(result, caps)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return strings.ToLower(result[i].Provider) < strings.ToLower(result[j].Provider)
+	})
+	return result, nil
+}
+
+func (cts *ConnectivityTestService) findProvider(kind string, providerID int) (string, Provider, error) {
+	platform := normalizePresetKind(kind)
+	if platform == "" {
+		return "", Provider{}, fmt.Errorf("unknown provider type: %s", kind)
+	}
+	providers, err := cts.providerService.LoadProviders(platform)
+	if err != nil {
+		return "", Provider{}, err
+	}
+	for _, provider := range providers {
+		if provider.ID == providerID {
+			return platform, provider, nil
+		}
+	}
+	return "", Provider{}, fmt.Errorf("未找到 provider id %d", providerID)
+}
+
+func (cts *ConnectivityTestService) send(platform string, provider Provider, probe capabilityProbe) (int, error) {
+	payload, err := json.Marshal(probe.body)
+	if err != nil {
+		return 0, err
+	}
+	headers := map[string]string{
+		"Content-Type": "application/json",
+		"Accept":       "application/json",
+	}
+	if platform == "claude" {
+		headers["Anthropic-Version"] = anthropicAPIVersion
+	}
+	provider, err = applySubscriptionCredential(provider, headers)
+	if err != nil {
+		return 0, err
+	}
+	applyAuthHeaders(headers, provider)
+
+	req, err := http.NewRequest(http.MethodPost, joinURL(provider.APIURL, probe.endpoint), bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	usage := &ReqeustLog{
+		Platform: platform,
+		Provider: provider.Name,
+		Model:    fmt.Sprint(probe.body["model"]),
+		IsStream: probe.body["stream"] == true,
+	}
+	start := time.Now()
+	defer func() {
+		usage.DurationSec = time.Since(start).Seconds()
+		recordProbeUsage(usage)
+	}()
+	resp, err := cts.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	usage.HttpCode = resp.StatusCode
+	parseProbeUsage(platform, body, usage)
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		message := gjson.GetBytes(body, "error.message").String()
+		if message == "" {
+			message = strings.TrimSpace(string(body))
+		}
+		return resp.StatusCode, fmt.Errorf("HTTP %d: %s", resp.StatusCode, truncateProbeMessage(message))
+	}
+	return resp.StatusCode, nil
+}
+
+func basicProbe(platform string, provider Provider) capabilityProbe {
+	if platform == "codex" {
+		return capabilityProbe{name: "basic", endpoint: "/responses", body: map[string]any{
+			"model":             provider.GetEffectiveModel(codexDefaultModel),
+			"input":             "ping",
+			"max_output_tokens": 16,
+		}}
+	}
+	return capabilityProbe{name: "basic", endpoint: "/v1/messages", body: map[string]any{
+		"model":      provider.GetEffectiveModel(claudeProbeModel),
+		"max_tokens": 1,
+		"messages":   []any{map[string]any{"role": "user", "content": "ping"}},
+	}}
+}
+
+// capabilityProbes 每个探测只在基础请求上增加一个特性，请求失败即可归因到该特性
+func capabilityProbes(platform string, provider Provider) []capabilityProbe {
+	base := basicProbe(platform, provider)
+	with := func(name string, extra map[string]any) capabilityProbe {
+		body := make(map[string]any, len(base.body)+len(extra))
+		for key, value := range base.body {
+			body[key] = value
+		}
+		for key, value := range extra {
+			body[key] = value
+		}
+		return capabilityProbe{name: name, endpoint: base.endpoint, body: body}
+	}
+
+	if platform == "codex" {
+		return []capabilityProbe{
+			with(CapabilityStreaming, map[string]any{"stream": true}),
+			with(CapabilityToolUse, map[string]any{"tools": []any{map[string]any{
+				"type":       "function",
+				"name":       "get_time",
+				"parameters": map[string]any{"type": "object", "properties": map[string]any{}},
+			}}}),
+			with(CapabilityVision, map[string]any{"input": []any{map[string]any{
+				"role": "user",
+				"content": []any{
+					map[string]any{"type": "input_text", "text": "ping"},
+					map[string]any{"type": "input_image", "image_url": "data:image/png;base64," + probeImageBase64},
+				},
+			}}}),
+			with(CapabilityThinking, map[string]any{"reasoning": map[string]any{"effort": "low"}}),
+		}
+	}
+
+	return []capabilityProbe{
+		with(CapabilityStreaming, map[string]any{"stream": true}),
+		with(CapabilityToolUse, map[string]any{"tools": []any{map[string]any{
+			"name":         "get_time",
+			"description":  "Get current time",
+			"input_schema": map[string]any{"type": "object", "properties": map[string]any{}},
+		}}}),
+		with(CapabilityVision, map[string]any{"messages": []any{map[string]any{
+			"role": "user",
+			"content": []any{
+				map[string]any{"type": "image", "source": map[string]any{
+					"type": "base64", "media_type": "image/png", "data": probeImageBase64,
+				}},
+				map[string]any{"type": "text", "text": "ping"},
+			},
+		}}}),
+		with(CapabilityPromptCaching, map[string]any{"system": []any{map[string]any{
+			"type":          "text",
+			"text":          "You are a connectivity probe.",
+			"cache_control": map[string]any{"type": "ephemeral"},
+		}}}),
+		with(CapabilityThinking, map[string]any{
+			"max_tokens": 1025,
+			"thinking":   map[string]any{"type": "enabled", "budget_tokens": 1024},
+		}),
+		{name: CapabilityCountTokens, endpoint: "/v1/messages/count_tokens", body: map[string]any{
+			"model":    base.body["model"],
+			"messages": base.body["messages"],
+		}},
+	}
+}
+
+func truncateProbeMessage(message string) string {
+	const limit = 200
+	if len([]rune(message)) <= limit {
+		return message
+	}
+	return string([]rune(message)[:limit]) + "..."
+}
+
+// requiredCapabilities 根据请求体判断本次请求依赖的能力
+func requiredCapabilities(kind string, body []byte) []string {
+	required := make([]string, 0, 4)
+	if gjson.GetBytes(body, "stream").Bool() {
+		required = append(required, CapabilityStreaming)
+	}
+	if tools := gjson.GetBytes(body, "tools"); tools.IsArray() && len(tools.Array()) > 0 {
+		required = append(required, CapabilityToolUse)
+	}
+	if kind == "codex" {
+		if gjson.GetBytes(body, "reasoning").Exists() {
+			required = append(required, CapabilityThinking)
+		}
+		if strings.Contains(string(body), `"input_image"`) {
+			required = append(required, CapabilityVision)
+		}
+		return required
+	}
+	if thinking := gjson.GetBytes(body, "thinking.type"); thinking.String() == "enabled" {
+		required = append(required, CapabilityThinking)
+	}
+	if hasImageContent(body) {
+		required = append(required, CapabilityVision)
+	}
+	return required
+}
+
+func hasImageContent(body []byte) bool {
+	found := false
+	gjson.GetBytes(body, "messages").ForEach(func(_, message gjson.Result) bool {
+		message.Get("content").ForEach(func(_, block gjson.Result) bool {
+			found = block.Get("type").String() == "image"
+			return !found
+		})
+		return !found
+	})
+	return found
+}
+
+// unsupportedCapability 返回 provider 明确不支持的第一个能力；未探测过的能力不拦截
+func unsupportedCapability(kind string, provider Provider, required []string) string {
+	if len(required) == 0 {
+		return ""
+	}
+	caps, ok := lookupProviderCapabilities(kind, provider.Name)
+	if !ok {
+		return ""
+	}
+	for _, name := range required {
+		if supported, probed := caps.Capabilities[name]; probed && !supported {
+			return name
+		}
+	}
+	return ""
+}
+
+// adaptBodyForProvider 按能力矩阵调整请求体：不支持 prompt caching 的上游去掉 cache_control
+func adaptBodyForProvider(kind string, provider Provider, body []byte) []byte {
+	if kind != "claude" || !strings.Contains(string(body), `"cache_control"`) {
+		return body
+	}
+	caps, ok := lookupProviderCapabilities(kind, provider.Name)
+	if !ok {
+		return body
+	}
+	if supported, probed := caps.Capabilities[CapabilityPromptCaching]; !probed || supported {
+		return body
+	}
+	var payload any
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return body
+	}
+	stripJSONKey(payload, "cache_control")
+	stripped, err := json.Marshal(payload)
+	if err != nil {
+		return body
+	}
+	return stripped
+}
+
+func stripJSONKey(value any, key string) {
+	switch typed := value.(type) {
+	case map[string]any:
+		delete(typed, key)
+		for _, child := range typed {
+			stripJSONKey(child, key)
+		}
+	case []any:
+		for _, child := range typed {
+			stripJSONKey(child, key)
+		}
+	}
+}
+
+func lookupProviderCapabilities(kind string, name string) (ProviderCapabilities, bool) {
+	if err := ensureCapabilityMatrixLoaded(); err != nil {
+		return ProviderCapabilities{}, false
+	}
+	capabilityMatrix.RLock()
+	defer capabilityMatrix.RUnlock()
+	caps, ok := capabilityMatrix.data[normalizePresetKind(kind)][strings.ToLower(name)]
+	return caps, ok
+}
+
+func capabilityMatrixPath() (string, error) {
+	dir, err := ensureDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, capabilityMatrixFile), nil
+}
+
+func ensureCapabilityMatrixLoaded() error {
+	capabilityMatrix.RLock()
+	loaded := capabilityMatrix.loaded
+	capabilityMatrix.RUnlock()
+	if loaded {
+		return nil
+	}
+	capabilityMatrix.Lock()
+	defer capabilityMatrix.Unlock()
+	if capabilityMatrix.loaded {
+		return nil
+	}
+	data := make(map[string]map[string]ProviderCapabilities)
+	path, err := capabilityMatrixPath()
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if len(content) > 0 {
+		if err := json.Unmarshal(content, &data); err != nil {
+			return err
+		}
+	}
+	capabilityMatrix.data = data
+	capabilityMatrix.loaded = true
+	return nil
+}
+
+func saveProviderCapabilities(caps ProviderCapabilities) error {
+	if err := ensureCapabilityMatrixLoaded(); err != nil {
+		return err
+	}
+	capabilityMatrix.Lock()
+	defer capabilityMatrix.Unlock()
+	if capabilityMatrix.data[caps.Platform] == nil {
+		capabilityMatrix.data[caps.Platform] = make(map[string]ProviderCapabilities)
+	}
+	capabilityMatrix.data[caps.Platform][strings.ToLower(caps.Provider)] = caps
+
+	path, err := capabilityMatrixPath()
+	if err != nil {
+		return err
+	}
+	payload, err := json.MarshalIndent(capabilityMatrix.data, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, payload, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
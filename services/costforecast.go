@@ -0,0 +1,168 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/daodao97/xgo/xdb"
+)
+
+// forecastWindowDays 计算日均消耗所用的历史天数
+const forecastWindowDays = 7
+
+// DailySpend 某天的费用
+type DailySpend struct {
+	Day  string  `json:"day"`
+	Cost float64 `json:"cost"`
+}
+
+// SpendForecast 本月费用预测，金额均为当前展示币种
+type SpendForecast struct {
+	Currency string `json:"currency"`
+	// 本月已消耗
+	MonthToDate float64 `json:"month_to_date"`
+	// 最近 7 天的日均消耗
+	DailyAverage float64 `json:"daily_average"`
+	// 按日均消耗推算的月底总费用
+	ProjectedMonthEnd float64 `json:"projected_month_end"`
+	// 月度预算，0 表示未设置
+	BudgetLimit     float64 `json:"budget_limit"`
+	BudgetRemaining float64 `json:"budget_remaining"`
+	// 预计预算耗尽的日期（本地时间 2006-01-02），月底前不会耗尽时为空
+	ExhaustDate    string       `json:"exhaust_date,omitempty"`
+	OnPaceToExceed bool         `json:"on_pace_to_exceed"`
+	Exceeded       bool         `json:"exceeded"`
+	Daily          []DailySpend `json:"daily"`
+}
+
+// GetSpendForecast 根据历史日均消耗预测本月费用与预算耗尽日期
+func (ls *LogService) GetSpendForecast() (SpendForecast, error) {
+	now := time.Now()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	windowStart := startOfDay(now).AddDate(0, 0, -forecastWindowDays)
+	queryStart := monthStart
+	if windowStart.Before(queryStart) {
+		queryStart = windowStart
+	}
+	records, err := xdb.New(requestLogTable()).Selects(
+		xdb.WhereGte("created_at", queryStart.UTC().Format(timeLayout)),
+		xdb.Field(
+			"provider",
+			"model",
+			"input_tokens",
+			"output_tokens",
+			"cache_create_tokens",
+			"cache_read_tokens",
+			"created_at",
+		),
+	)
+	if err != nil && !errors.Is(err, xdb.ErrNotFound) && !isNoSuchTableErr(err) {
+		return SpendForecast{}, err
+	}
+
+	daily := map[string]float64{}
+	monthToDate, windowCost := 0.0, 0.0
+	var firstSeen time.Time
+	for _, record := range records {
+		createdAt, ok := parseCreatedAt(record)
+		if !ok {
+			continue
+		}
+		cost := ls.recordCost(record)
+		if !createdAt.Before(monthStart) {
+			monthToDate += cost
+			daily[createdAt.Format("2006-01-02")] += cost
+		}
+		if !createdAt.Before(windowStart) {
+			windowCost += cost
+			if firstSeen.IsZero() || createdAt.Before(firstSeen) {
+				firstSeen = createdAt
+			}
+		}
+	}
+	// 历史不足 7 天时按实际有记录的时长计算日均，至少按 1 天
+	windowDays := float64(forecastWindowDays)
+	if !firstSeen.IsZero() {
+		windowDays = math.Min(windowDays, now.Sub(startOfDay(firstSeen)).Hours()/24)
+	}
+	windowDays = math.Max(windowDays, 1)
+
+	budget := currentBudget()
+	forecast := forecastSpend(now, monthToDate, windowCost/windowDays, toUSD(budget.MonthlyLimit, budget.Currency))
+	for day := monthStart; !day.After(now); day = day.AddDate(0, 0, 1) {
+		key := day.Format("2006-01-02")
+		forecast.Daily = append(forecast.Daily, DailySpend{Day: key, Cost: daily[key]})
+	}
+	forecast.applyCurrency()
+	return forecast, nil
+}
+
+// BudgetWarning 预计超出预算时返回提示文案，否则返回空字符串
+func (ls *LogService) BudgetWarning() string {
+	forecast, err := ls.GetSpendForecast()
+	if err != nil || forecast.BudgetLimit <= 0 {
+		return ""
+	}
+	if forecast.Exceeded {
+		return fmt.Sprintf("本月费用 %s 已超出预算 %s",
+			formatCost(forecast.MonthToDate, forecast.Currency), formatCost(forecast.BudgetLimit, forecast.Currency))
+	}
+	if forecast.OnPaceToExceed {
+		exhaust, err := time.ParseInLocation("2006-01-02", forecast.ExhaustDate, time.Local)
+		if err != nil {
+			return ""
+		}
+		return fmt.Sprintf("按当前消耗速度，预算将在 %d 日用完", exhaust.Day())
+	}
+	return ""
+}
+
+// forecastSpend 按日均消耗推算月底费用与预算耗尽日期，金额均为美元
+func forecastSpend(now time.Time, monthToDate, dailyAverage, budgetUSD float64) SpendForecast {
+	monthEnd := time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, now.Location())
+	remainingDays := monthEnd.Sub(now).Hours() / 24
+	forecast := SpendForecast{
+		MonthToDate:       monthToDate,
+		DailyAverage:      dailyAverage,
+		ProjectedMonthEnd: monthToDate + dailyAverage*remainingDays,
+		BudgetLimit:       budgetUSD,
+	}
+	if budgetUSD <= 0 {
+		return forecast
+	}
+	forecast.BudgetRemaining = math.Max(budgetUSD-monthToDate, 0)
+	if monthToDate >= budgetUSD {
+		forecast.Exceeded = true
+		forecast.OnPaceToExceed = true
+		forecast.ExhaustDate = now.Format("2006-01-02")
+		return forecast
+	}
+	if dailyAverage <= 0 {
+		return forecast
+	}
+	exhaustAt := now.Add(time.Duration(forecast.BudgetRemaining / dailyAverage * float64(24*time.Hour)))
+	if exhaustAt.Before(monthEnd) {
+		forecast.OnPaceToExceed = true
+		forecast.ExhaustDate = exhaustAt.Format("2006-01-02")
+	}
+	return forecast
+}
+
+// applyCurrency 把美元金额换算为当前展示币种
+func (forecast *SpendForecast) applyCurrency() {
+	convert := func(amount float64) float64 {
+		converted, _ := displayCost(amount)
+		return converted
+	}
+	_, forecast.Currency = displayCost(0)
+	forecast.MonthToDate = convert(forecast.MonthToDate)
+	forecast.DailyAverage = convert(forecast.DailyAverage)
+	forecast.ProjectedMonthEnd = convert(forecast.ProjectedMonthEnd)
+	forecast.BudgetLimit = convert(forecast.BudgetLimit)
+	forecast.BudgetRemaining = convert(forecast.BudgetRemaining)
+	for i := range forecast.Daily {
+		forecast.Daily[i].Cost = convert(forecast.Daily[i].Cost)
+	}
+}
@@ -0,0 +1,30 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestForecastSpend(t *testing.T) {
+	now := time.Date(2026, 10, 10, 0, 0, 0, 0, time.UTC)
+
+	forecast := forecastSpend(now, 100, 10, 0)
+	if forecast.ProjectedMonthEnd != 320 || forecast.OnPaceToExceed {
+		t.Fatalf("unexpected forecast without budget: %+v", forecast)
+	}
+
+	forecast = forecastSpend(now, 100, 10, 220)
+	if !forecast.OnPaceToExceed || forecast.ExhaustDate != "2026-10-22" || forecast.BudgetRemaining != 120 {
+		t.Fatalf("expected budget exhausted on 22nd: %+v", forecast)
+	}
+
+	forecast = forecastSpend(now, 100, 10, 500)
+	if forecast.OnPaceToExceed || forecast.ExhaustDate != "" {
+		t.Fatalf("expected budget to last the month: %+v", forecast)
+	}
+
+	forecast = forecastSpend(now, 600, 10, 500)
+	if !forecast.Exceeded || forecast.BudgetRemaining != 0 {
+		t.Fatalf("expected budget exceeded: %+v", forecast)
+	}
+}
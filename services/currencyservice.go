@@ -0,0 +1,250 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+const (
+	exchangeRateURL      = "https://open.er-api.com/v6/latest/USD"
+	exchangeRateInterval = 24 * time.Hour
+	exchangeRateTimeout  = 15 * time.Second
+)
+
+// CurrencySettings 费用展示币种：上游价格均为美元，按汇率换算后展示
+type CurrencySettings struct {
+	Currency string `json:"currency"`
+	// 1 美元可兑换的各币种数量，如 {"CNY": 7.2}
+	Rates map[string]float64 `json:"rates"`
+	// 每天自动更新汇率
+	AutoFetch      bool      `json:"auto_fetch"`
+	RatesUpdatedAt time.Time `json:"rates_updated_at,omitempty"`
+}
+
+// currencyRates 由 CurrencyService 维护，LogService 与价格表换算时读取
+var currencyRates atomic.Pointer[CurrencySettings]
+
+// currencySymbols 常用币种的展示符号，未列出的使用币种代码
+var currencySymbols = map[string]string{
+	"USD": "$",
+	"CNY": "¥",
+	"EUR": "€",
+	"GBP": "£",
+	"JPY": "JP¥",
+	"HKD": "HK$",
+}
+
+type CurrencyService struct {
+	appSettings *AppSettingsService
+	httpClient  *http.Client
+	mu          sync.Mutex
+	stopCh      chan struct{}
+}
+
+func NewCurrencyService(appSettings *AppSettingsService) *CurrencyService {
+	cs := &CurrencyService{
+		appSettings: appSettings,
+		httpClient:  &http.Client{Timeout: exchangeRateTimeout},
+	}
+	settings := defaultCurrencySettings()
+	if appSettings != nil {
+		if app, err := appSettings.GetAppSettings(); err == nil {
+			settings = normalizeCurrencySettings(app.Currency)
+		}
+	}
+	currencyRates.Store(&settings)
+	return cs
+}
+
+func defaultCurrencySettings() CurrencySettings {
+	return CurrencySettings{
+		Currency:  "USD",
+		Rates:     map[string]float64{"USD": 1},
+		AutoFetch: false,
+	}
+}
+
+func normalizeCurrencySettings(settings CurrencySettings) CurrencySettings {
+	settings.Currency = strings.ToUpper(strings.TrimSpace(settings.Currency))
+	if settings.Currency == "" {
+		settings.Currency = "USD"
+	}
+	rates := make(map[string]float64, len(settings.Rates)+1)
+	for code, rate := range settings.Rates {
+		if rate > 0 {
+			rates[strings.ToUpper(code)] = rate
+		}
+	}
+	rates["USD"] = 1
+	settings.Rates = rates
+	return settings
+}
+
+// Start 开启自动更新时，每天拉取一次汇率
+func (cs *CurrencyService) Start() error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.stopCh != nil {
+		return nil
+	}
+	stopCh := make(chan struct{})
+	cs.stopCh = stopCh
+	go func() {
+		cs.autoRefresh()
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				cs.autoRefresh()
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (cs *CurrencyService) Stop() error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.stopCh != nil {
+		close(cs.stopCh)
+		cs.stopCh = nil
+	}
+	return nil
+}
+
+// GetCurrencySettings 返回当前币种与汇率
+func (cs *CurrencyService) GetCurrencySettings() CurrencySettings {
+	return currentCurrency()
+}
+
+// SaveCurrencySettings 保存展示币种与手动汇率；非美元币种必须有可用汇率
+func (cs *CurrencyService) SaveCurrencySettings(settings CurrencySettings) (CurrencySettings, error) {
+	settings = normalizeCurrencySettings(settings)
+	if _, ok := settings.Rates[settings.Currency]; !ok {
+		return settings, fmt.Errorf("缺少 %s 的汇率，请先填写或更新汇率", settings.Currency)
+	}
+	if err := cs.persist(settings); err != nil {
+		return settings, err
+	}
+	return settings, nil
+}
+
+// RefreshRates 立即从网络更新汇率，保留手动填写但接口未提供的币种
+func (cs *CurrencyService) RefreshRates() (CurrencySettings, error) {
+	resp, err := cs.httpClient.Get(exchangeRateURL)
+	if err != nil {
+		return currentCurrency(), err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return currentCurrency(), fmt.Errorf("获取汇率失败: HTTP %d", resp.StatusCode)
+	}
+	payload, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if err != nil {
+		return currentCurrency(), err
+	}
+	rates := gjson.GetBytes(payload, "rates")
+	if !rates.IsObject() {
+		return currentCurrency(), errors.New("汇率接口返回格式无法识别")
+	}
+	settings := currentCurrency()
+	merged := make(map[string]float64, len(settings.Rates))
+	for code, rate := range settings.Rates {
+		merged[code] = rate
+	}
+	rates.ForEach(func(code, rate gjson.Result) bool {
+		merged[strings.ToUpper(code.String())] = rate.Float()
+		return true
+	})
+	settings.Rates = merged
+	settings.RatesUpdatedAt = time.Now()
+	settings = normalizeCurrencySettings(settings)
+	if err := cs.persist(settings); err != nil {
+		return settings, err
+	}
+	return settings, nil
+}
+
+func (cs *CurrencyService) autoRefresh() {
+	settings := currentCurrency()
+	if !settings.AutoFetch || time.Since(settings.RatesUpdatedAt) < exchangeRateInterval {
+		return
+	}
+	if _, err := cs.RefreshRates(); err != nil {
+		fmt.Printf("[WARN] 更新汇率失败: %v\n", err)
+	}
+}
+
+func (cs *CurrencyService) persist(settings CurrencySettings) error {
+	if cs.appSettings != nil {
+		if _, err := cs.appSettings.update(func(app *AppSettings) {
+			app.Currency = settings
+		}); err != nil {
+			return err
+		}
+	}
+	currencyRates.Store(&settings)
+	return nil
+}
+
+func currentCurrency() CurrencySettings {
+	if settings := currencyRates.Load(); settings != nil {
+		return *settings
+	}
+	return defaultCurrencySettings()
+}
+
+// usdTo 把美元金额换算为指定币种，缺少汇率时返回原值与 false
+func usdTo(amount float64, currency string) (float64, bool) {
+	currency = strings.ToUpper(currency)
+	if currency == "" || currency == "USD" {
+		return amount, true
+	}
+	rate, ok := currentCurrency().Rates[currency]
+	if !ok || rate <= 0 {
+		return amount, false
+	}
+	return amount * rate, true
+}
+
+// toUSD 把指定币种金额换算为美元，缺少汇率时返回原值
+func toUSD(amount float64, currency string) float64 {
+	currency = strings.ToUpper(currency)
+	if currency == "" || currency == "USD" {
+		return amount
+	}
+	rate, ok := currentCurrency().Rates[currency]
+	if !ok || rate <= 0 {
+		return amount
+	}
+	return amount / rate
+}
+
+// displayCost 把美元金额换算为当前展示币种，缺少汇率时回落到美元
+func displayCost(amount float64) (float64, string) {
+	currency := currentCurrency().Currency
+	if converted, ok := usdTo(amount, currency); ok {
+		return converted, currency
+	}
+	return amount, "USD"
+}
+
+// formatCost 按币种符号格式化金额，如 "¥12.34"
+func formatCost(amount float64, currency string) string {
+	symbol, ok := currencySymbols[currency]
+	if !ok {
+		return fmt.Sprintf("%.2f %s", amount, currency)
+	}
+	return fmt.Sprintf("%s%.2f", symbol, amount)
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const customCliVersionTimeout = 5 * time.Second
+
+var customCliVersionPattern = regexp.MustCompile(`\d+\.\d+(?:\.\d+)?(?:[-+][0-9A-Za-z.-]+)?`)
+
+// DetectedCustomCli 本机检测到的工具。Source 为 binary 或 extension，
+// ConfigFiles 为预设中已经存在的配置文件，Registered 表示已添加为自定义工具
+type DetectedCustomCli struct {
+	PresetID    string   `json:"preset_id"`
+	Name        string   `json:"name"`
+	Source      string   `json:"source"`
+	Path        string   `json:"path"`
+	Version     string   `json:"version"`
+	ConfigFiles []string `json:"config_files"`
+	Registered  bool     `json:"registered"`
+}
+
+// DetectCustomCliTools 在 PATH、常见安装目录与编辑器扩展目录中查找预设对应的工具，并读取版本号
+func (cs *CustomCliService) DetectCustomCliTools() ([]DetectedCustomCli, error) {
+	tools, err := loadCustomCliTools()
+	if err != nil {
+		return nil, err
+	}
+	registered := make(map[string]struct{}, len(tools))
+	for _, tool := range tools {
+		registered[tool.ID] = struct{}{}
+		if tool.Preset != "" {
+			registered[tool.Preset] = struct{}{}
+		}
+	}
+
+	presets := cs.ListCustomCliPresets()
+	results := make([]*DetectedCustomCli, len(presets))
+	var wg sync.WaitGroup
+	for i, preset := range presets {
+		wg.Add(1)
+		go func(i int, preset CustomCliPreset) {
+			defer wg.Done()
+			detected, ok := detectCustomCliPreset(preset)
+			if !ok {
+				return
+			}
+			_, detected.Registered = registered[preset.ID]
+			results[i] = &detected
+		}(i, preset)
+	}
+	wg.Wait()
+
+	found := make([]DetectedCustomCli, 0)
+	for _, result := range results {
+		if result != nil {
+			found = append(found, *result)
+		}
+	}
+	return found, nil
+}
+
+// RegisterDetectedCustomClis 按检测结果批量添加工具，已添加的预设直接返回现有定义
+func (cs *CustomCliService) RegisterDetectedCustomClis(presetIDs []string) ([]CustomCliTool, error) {
+	tools := make([]CustomCliTool, 0, len(presetIDs))
+	for _, id := range presetIDs {
+		tool, err := cs.AddCustomCliPreset(id)
+		if err != nil {
+			return tools, err
+		}
+		tools = append(tools, tool)
+	}
+	return tools, nil
+}
+
+func detectCustomCliPreset(preset CustomCliPreset) (DetectedCustomCli, bool) {
+	detected := DetectedCustomCli{PresetID: preset.ID, Name: preset.Name, ConfigFiles: []string{}}
+	for _, file := range preset.Tool.ConfigFiles {
+		if _, err := os.Stat(file.Path); err == nil {
+			detected.ConfigFiles = append(detected.ConfigFiles, file.Path)
+		}
+	}
+	for _, name := range preset.Binaries {
+		path := findCustomCliBinary(name)
+		if path == "" {
+			continue
+		}
+		detected.Source = "binary"
+		detected.Path = path
+		detected.Version = customCliBinaryVersion(path)
+		return detected, true
+	}
+	for _, id := range preset.Extensions {
+		path, version := findEditorExtension(id)
+		if path == "" {
+			continue
+		}
+		detected.Source = "extension"
+		detected.Path = path
+		detected.Version = version
+		return detected, true
+	}
+	return detected, false
+}
+
+// findCustomCliBinary 先查 PATH，再查常见的安装目录；从 Finder 或开始菜单启动时 PATH 往往不包含这些目录
+func findCustomCliBinary(name string) string {
+	if path, err := exec.LookPath(name); err == nil {
+		return path
+	}
+	names := []string{name}
+	if runtime.GOOS == "windows" {
+		names = []string{name + ".exe", name + ".cmd", name + ".ps1"}
+	}
+	for _, dir := range customCliBinaryDirs() {
+		for _, candidate := range names {
+			path := filepath.Join(dir, candidate)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+func customCliBinaryDirs() []string {
+	home := userHomeDir()
+	dirs := []string{
+		filepath.Join(home, ".local", "bin"),
+		filepath.Join(home, ".npm-global", "bin"),
+		filepath.Join(home, ".bun", "bin"),
+		filepath.Join(home, ".opencode", "bin"),
+		filepath.Join(home, ".cargo", "bin"),
+		filepath.Join(home, "go", "bin"),
+	}
+	switch runtime.GOOS {
+	case "windows":
+		if appData := os.Getenv("APPDATA"); appData != "" {
+			dirs = append(dirs, filepath.Join(appData, "npm"))
+		}
+	case "darwin":
+		dirs = append(dirs, "/opt/homebrew/bin", "/usr/local/bin")
+	default:
+		dirs = append(dirs, "/usr/local/bin")
+	}
+	return dirs
+}
+
+// customCliBinaryVersion 运行 --version 并取输出中的第一个版本号，超时或失败时返回空
+func customCliBinaryVersion(path string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), customCliVersionTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, path, "--version").CombinedOutput()
+	if err != nil && len(output) == 0 {
+		return ""
+	}
+	return customCliVersionPattern.FindString(string(output))
+}
+
+// findEditorExtension 在 VS Code、Cursor 与 Windsurf 的扩展目录中查找最新版本的扩展
+func findEditorExtension(id string) (string, string) {
+	home := userHomeDir()
+	prefix := strings.ToLower(id) + "-"
+	var matches []string
+	for _, editor := range []string{".vscode", ".vscode-insiders", ".cursor", ".windsurf"} {
+		entries, err := os.ReadDir(filepath.Join(home, editor, "extensions"))
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() && strings.HasPrefix(strings.ToLower(entry.Name()), prefix) {
+				matches = append(matches, filepath.Join(home, editor, "extensions", entry.Name()))
+			}
+		}
+	}
+	if len(matches) == 0 {
+		return "", ""
+	}
+	version := func(path string) string {
+		return customCliVersionPattern.FindString(strings.TrimPrefix(strings.ToLower(filepath.Base(path)), prefix))
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return compareVersions(version(matches[i]), version(matches[j])) > 0
+	})
+	return matches[0], version(matches[0])
+}
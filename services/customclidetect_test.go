@@ -0,0 +1,45 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestDetectCustomCliPresets(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as the fake binary")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("PATH", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+	writeSkillFiles(t, home, map[string]string{
+		".local/bin/crush":         "#!/bin/sh\necho 'crush version v0.7.1'\n",
+		".config/crush/crush.json": "{}",
+		".vscode/extensions/rooveterinaryinc.roo-cline-3.9.2/a":  "",
+		".vscode/extensions/rooveterinaryinc.roo-cline-3.10.0/a": "",
+	})
+	if err := os.Chmod(filepath.Join(home, ".local", "bin", "crush"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	found := map[string]DetectedCustomCli{}
+	for _, preset := range NewCustomCliService(":18100").ListCustomCliPresets() {
+		if detected, ok := detectCustomCliPreset(preset); ok {
+			found[preset.ID] = detected
+		}
+	}
+	if len(found) != 2 {
+		t.Fatalf("detected = %+v", found)
+	}
+	crush := found["crush"]
+	if crush.Source != "binary" || crush.Version != "0.7.1" || len(crush.ConfigFiles) != 1 {
+		t.Fatalf("crush = %+v", crush)
+	}
+	roo := found["roo-code"]
+	if roo.Source != "extension" || roo.Version != "3.10.0" {
+		t.Fatalf("roo = %+v", roo)
+	}
+}
@@ -0,0 +1,276 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var customCliEnvKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// CustomCliConfigNode 配置树的一个节点。Path 为从根开始的完整键，写法与 ProxyFields 相同；
+// object 通过 Children 展开，数组与标量的值放在 Value 中。Locked 表示该节点由代理管理，不可修改
+type CustomCliConfigNode struct {
+	Key      string                `json:"key"`
+	Path     string                `json:"path"`
+	Type     string                `json:"type"`
+	Value    any                   `json:"value,omitempty"`
+	Locked   bool                  `json:"locked"`
+	Children []CustomCliConfigNode `json:"children,omitempty"`
+}
+
+// CustomCliConfigDocument 工具的一个配置文件解析后的树，文件无法解析时 Error 非空且 Nodes 为空
+type CustomCliConfigDocument struct {
+	Path   string                `json:"path"`
+	Format string                `json:"format"`
+	Exists bool                  `json:"exists"`
+	Nodes  []CustomCliConfigNode `json:"nodes"`
+	Locked []string              `json:"locked"`
+	Error  string                `json:"error,omitempty"`
+}
+
+// CustomCliConfigPatch 对单个键的修改，Delete 为 true 时删除该键
+type CustomCliConfigPatch struct {
+	Path   string `json:"path"`
+	Value  any    `json:"value"`
+	Delete bool   `json:"delete"`
+}
+
+// GetCustomCliConfig 返回工具各配置文件的树形结构，前端不需要关心文件格式
+func (cs *CustomCliService) GetCustomCliConfig(id string) ([]CustomCliConfigDocument, error) {
+	tool, err := findCustomCliTool(id)
+	if err != nil {
+		return nil, err
+	}
+	locked, err := cs.GetLockedFields(tool.ID)
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]CustomCliConfigDocument, 0, len(tool.ConfigFiles))
+	for _, file := range tool.ConfigFiles {
+		docs = append(docs, buildCustomCliConfigDocument(file, locked[file.Path]))
+	}
+	return docs, nil
+}
+
+// UpdateCustomCliConfig 把一组修改合并进指定配置文件。任一修改未通过校验时整体放弃，文件保持不变
+func (cs *CustomCliService) UpdateCustomCliConfig(id string, path string, patches []CustomCliConfigPatch) (CustomCliConfigDocument, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	tool, err := findCustomCliTool(id)
+	if err != nil {
+		return CustomCliConfigDocument{}, err
+	}
+	var file CustomCliConfigFile
+	found := false
+	for _, candidate := range tool.ConfigFiles {
+		if candidate.Path == path {
+			file, found = candidate, true
+			break
+		}
+	}
+	if !found {
+		return CustomCliConfigDocument{}, fmt.Errorf("%s 不是 %s 的配置文件", path, tool.Name)
+	}
+	locked, err := cs.GetLockedFields(tool.ID)
+	if err != nil {
+		return CustomCliConfigDocument{}, err
+	}
+	payload, mode, err := readCustomCliFile(file)
+	if err != nil {
+		return CustomCliConfigDocument{}, err
+	}
+	for _, patch := range patches {
+		if err := applyCustomCliPatch(payload, file.Format, locked[file.Path], patch); err != nil {
+			return CustomCliConfigDocument{}, err
+		}
+	}
+	if len(patches) > 0 {
+		snapshotConfigFiles("custom/"+tool.ID+":edit", file.Path)
+		if err := writeCustomCliFile(file, payload, mode); err != nil {
+			return CustomCliConfigDocument{}, err
+		}
+	}
+	return buildCustomCliConfigDocument(file, locked[file.Path]), nil
+}
+
+func buildCustomCliConfigDocument(file CustomCliConfigFile, locked []string) CustomCliConfigDocument {
+	doc := CustomCliConfigDocument{Path: file.Path, Format: file.Format, Nodes: []CustomCliConfigNode{}, Locked: locked}
+	if doc.Locked == nil {
+		doc.Locked = []string{}
+	}
+	doc.Exists = fileExists(file.Path)
+	payload, _, err := readCustomCliFile(file)
+	if err != nil {
+		doc.Error = err.Error()
+		return doc
+	}
+	doc.Nodes = customCliConfigNodes(payload, file.Format, nil, locked)
+	return doc
+}
+
+func customCliConfigNodes(payload map[string]any, format string, parents []string, locked []string) []CustomCliConfigNode {
+	keys := make([]string, 0, len(payload))
+	for key := range payload {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	nodes := make([]CustomCliConfigNode, 0, len(keys))
+	for _, key := range keys {
+		segments := append(append([]string(nil), parents...), key)
+		node := CustomCliConfigNode{Key: key, Path: joinCustomCliKey(format, segments)}
+		node.Locked = customCliKeyLocked(format, segments, locked)
+		switch value := payload[key].(type) {
+		case map[string]any:
+			node.Type = "object"
+			node.Children = customCliConfigNodes(value, format, segments, locked)
+		case []any:
+			node.Type, node.Value = "array", value
+		case string:
+			node.Type, node.Value = "string", value
+		case bool:
+			node.Type, node.Value = "boolean", value
+		case int, int64, uint64, float64:
+			node.Type, node.Value = "number", value
+		case nil:
+			node.Type = "null"
+		case time.Time, fmt.Stringer:
+			// TOML 的日期时间以文本展示
+			node.Type, node.Value = "datetime", fmt.Sprint(value)
+		default:
+			node.Type, node.Value = "string", fmt.Sprint(value)
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// applyCustomCliPatch 校验并应用一项修改：不能改动被锁定的字段及其上下级，
+// 也不能穿过已有的非 object 值创建子键
+func applyCustomCliPatch(payload map[string]any, format string, locked []string, patch CustomCliConfigPatch) error {
+	if strings.TrimSpace(patch.Path) == "" {
+		return fmt.Errorf("键不能为空")
+	}
+	segments := splitCustomCliKey(format, patch.Path)
+	for _, segment := range segments {
+		if segment == "" {
+			return fmt.Errorf("键 %s 无效", patch.Path)
+		}
+	}
+	for _, key := range locked {
+		lockedSegments := splitCustomCliKey(format, key)
+		if customCliKeyPrefix(lockedSegments, segments) || customCliKeyPrefix(segments, lockedSegments) {
+			return fmt.Errorf("%s 由代理管理，关闭代理后才能修改", key)
+		}
+	}
+	current := payload
+	for i, segment := range segments[:len(segments)-1] {
+		next, exists := current[segment]
+		if !exists {
+			break
+		}
+		child, ok := next.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s 不是 object，无法设置子键", joinCustomCliKey(format, segments[:i+1]))
+		}
+		current = child
+	}
+	if patch.Delete {
+		customCliDelete(payload, format, patch.Path)
+		return nil
+	}
+	value, err := normalizeCustomCliValue(format, patch.Path, patch.Value)
+	if err != nil {
+		return err
+	}
+	if format == customCliFormatTOML {
+		if old, ok := customCliGet(payload, format, patch.Path); ok {
+			if _, isFloat := old.(float64); isFloat {
+				if number, isInt := value.(int64); isInt {
+					value = float64(number)
+				}
+			}
+		}
+	}
+	customCliSet(payload, format, patch.Path, value)
+	return nil
+}
+
+// normalizeCustomCliValue 按文件格式约束取值：env 只接受标量并转为文本，TOML 不支持 null，整数按整数写入
+func normalizeCustomCliValue(format string, path string, value any) (any, error) {
+	switch format {
+	case customCliFormatEnv:
+		if !customCliEnvKeyPattern.MatchString(path) {
+			return nil, fmt.Errorf("环境变量名 %s 无效", path)
+		}
+		switch value.(type) {
+		case map[string]any, []any, nil:
+			return nil, fmt.Errorf("%s 只能设置为文本、数字或布尔值", path)
+		}
+		if number, ok := value.(float64); ok && number == math.Trunc(number) {
+			return fmt.Sprint(int64(number)), nil
+		}
+		return fmt.Sprint(value), nil
+	case customCliFormatTOML:
+		if value == nil {
+			return nil, fmt.Errorf("TOML 不支持空值，请删除 %s", path)
+		}
+	}
+	return normalizeCustomCliNumbers(value), nil
+}
+
+// normalizeCustomCliNumbers 前端传来的数字都是 float64，整数值转回 int64，避免写成 1.0 或 1e+06
+func normalizeCustomCliNumbers(value any) any {
+	switch v := value.(type) {
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v)
+		}
+	case map[string]any:
+		for key, item := range v {
+			v[key] = normalizeCustomCliNumbers(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = normalizeCustomCliNumbers(item)
+		}
+	}
+	return value
+}
+
+// joinCustomCliKey splitCustomCliKey 的逆操作，键名中的 . 转义为 \.
+func joinCustomCliKey(format string, segments []string) string {
+	if format == customCliFormatEnv {
+		return strings.Join(segments, "")
+	}
+	escaped := make([]string, len(segments))
+	for i, segment := range segments {
+		escaped[i] = strings.ReplaceAll(segment, ".", `\.`)
+	}
+	return strings.Join(escaped, ".")
+}
+
+// customCliKeyLocked 节点本身或任一上级被锁定时视为锁定
+func customCliKeyLocked(format string, segments []string, locked []string) bool {
+	for _, key := range locked {
+		if customCliKeyPrefix(splitCustomCliKey(format, key), segments) {
+			return true
+		}
+	}
+	return false
+}
+
+func customCliKeyPrefix(prefix []string, segments []string) bool {
+	if len(prefix) > len(segments) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != segments[i] {
+			return false
+		}
+	}
+	return true
+}
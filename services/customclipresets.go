@@ -0,0 +1,250 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// CustomCliPreset 常见 CLI 的现成定义，配置路径按当前系统解析。
+// Binaries 与 Extensions（VS Code 扩展 ID）用于检测工具是否已安装
+type CustomCliPreset struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Homepage    string        `json:"homepage"`
+	Binaries    []string      `json:"binaries,omitempty"`
+	Extensions  []string      `json:"extensions,omitempty"`
+	Tool        CustomCliTool `json:"tool"`
+}
+
+// customCliPresets 各工具的 base URL 约定不同：Anthropic SDK 自行拼接 /v1/messages，
+// 基于 @ai-sdk 的工具需要带上 /v1；OpenAI 兼容工具直接请求 <base>/chat/completions，由 Codex provider 的地址补全 /v1
+func customCliPresets() []CustomCliPreset {
+	home := userHomeDir()
+	vscodeSettings := filepath.Join(vscodeUserDir(), "settings.json")
+	rooImportPath := filepath.Join(home, ".roo", "code-switch-settings.json")
+	return []CustomCliPreset{
+		{
+			ID:          "aider",
+			Name:        "Aider",
+			Description: "通过 ~/.env 中的 ANTHROPIC_API_BASE 接入，Aider 启动时会读取主目录下的 .env",
+			Homepage:    "https://aider.chat",
+			Binaries:    []string{"aider"},
+			Tool: CustomCliTool{
+				Protocol: customCliProtocolAnthropic,
+				ConfigFiles: []CustomCliConfigFile{{
+					Path:   filepath.Join(home, ".env"),
+					Format: customCliFormatEnv,
+					ProxyFields: map[string]any{
+						"ANTHROPIC_API_BASE": "{{base_url}}",
+						"ANTHROPIC_API_KEY":  "{{api_key}}",
+					},
+				}},
+			},
+		},
+		{
+			ID:          "cline",
+			Name:        "Cline",
+			Description: "Cline 的 API 配置保存在 VS Code 的加密存储中，无法写入文件",
+			Homepage:    "https://cline.bot",
+			Binaries:    []string{"cline"},
+			Extensions:  []string{"saoudrizwan.claude-dev"},
+			Tool: CustomCliTool{
+				Protocol: customCliProtocolAnthropic,
+				Notes:    "在 Cline 设置中选择 Anthropic，勾选 Use custom base URL，填入下方的地址与 API Key",
+			},
+		},
+		{
+			ID:          "roo-code",
+			Name:        "Roo Code",
+			Description: "生成 Roo Code 设置文件，并在 VS Code 中设置 roo-cline.autoImportSettingsPath，重启 VS Code 后自动导入",
+			Homepage:    "https://roocode.com",
+			Extensions:  []string{"rooveterinaryinc.roo-cline"},
+			Tool: CustomCliTool{
+				Protocol: customCliProtocolAnthropic,
+				ConfigFiles: []CustomCliConfigFile{
+					{
+						Path:   rooImportPath,
+						Format: customCliFormatJSON,
+						ProxyFields: map[string]any{
+							"providerProfiles.currentApiConfigName":                         "code-switch",
+							"providerProfiles.apiConfigs.code-switch.id":                    "code-switch",
+							"providerProfiles.apiConfigs.code-switch.apiProvider":           "anthropic",
+							"providerProfiles.apiConfigs.code-switch.anthropicBaseUrl":      "{{base_url}}",
+							"providerProfiles.apiConfigs.code-switch.anthropicUseAuthToken": true,
+							"providerProfiles.apiConfigs.code-switch.apiKey":                "{{api_key}}",
+						},
+					},
+					{
+						Path:   vscodeSettings,
+						Format: customCliFormatJSON,
+						ProxyFields: map[string]any{
+							`roo-cline\.autoImportSettingsPath`: rooImportPath,
+						},
+					},
+				},
+			},
+		},
+		{
+			ID:          "opencode",
+			Name:        "OpenCode",
+			Description: "覆盖 opencode.json 中 anthropic provider 的 baseURL 与 apiKey",
+			Homepage:    "https://opencode.ai",
+			Binaries:    []string{"opencode"},
+			Tool: CustomCliTool{
+				Protocol: customCliProtocolAnthropic,
+				ConfigFiles: []CustomCliConfigFile{{
+					Path:   filepath.Join(xdgConfigHome(), "opencode", "opencode.json"),
+					Format: customCliFormatJSON,
+					ProxyFields: map[string]any{
+						"provider.anthropic.options.baseURL": "{{base_url}}/v1",
+						"provider.anthropic.options.apiKey":  "{{api_key}}",
+					},
+				}},
+			},
+		},
+		{
+			ID:          "crush",
+			Name:        "Crush",
+			Description: "覆盖 crush.json 中 anthropic provider 的 base_url 与 api_key",
+			Homepage:    "https://github.com/charmbracelet/crush",
+			Binaries:    []string{"crush"},
+			Tool: CustomCliTool{
+				Protocol: customCliProtocolAnthropic,
+				ConfigFiles: []CustomCliConfigFile{{
+					Path:   crushConfigPath(),
+					Format: customCliFormatJSON,
+					ProxyFields: map[string]any{
+						"providers.anthropic.base_url": "{{base_url}}",
+						"providers.anthropic.api_key":  "{{api_key}}",
+					},
+				}},
+			},
+		},
+		{
+			ID:          "goose",
+			Name:        "Goose",
+			Description: "在 Goose 的 config.yaml 中切换到 anthropic provider，并把 ANTHROPIC_HOST 指向 relay",
+			Homepage:    "https://block.github.io/goose",
+			Binaries:    []string{"goose"},
+			Tool: CustomCliTool{
+				Protocol: customCliProtocolAnthropic,
+				ConfigFiles: []CustomCliConfigFile{{
+					Path:   gooseConfigPath(),
+					Format: customCliFormatYAML,
+					ProxyFields: map[string]any{
+						"GOOSE_PROVIDER": "anthropic",
+						"ANTHROPIC_HOST": "{{base_url}}",
+					},
+				}},
+			},
+		},
+		{
+			ID:          "qwen-code",
+			Name:        "Qwen Code",
+			Description: "写入 ~/.qwen/.env 中的 OpenAI 兼容配置，请求转发到 Codex 的 provider",
+			Homepage:    "https://github.com/QwenLM/qwen-code",
+			Binaries:    []string{"qwen"},
+			Tool: CustomCliTool{
+				Protocol: customCliProtocolOpenAI,
+				ConfigFiles: []CustomCliConfigFile{{
+					Path:   filepath.Join(home, ".qwen", ".env"),
+					Format: customCliFormatEnv,
+					ProxyFields: map[string]any{
+						"OPENAI_BASE_URL": "{{base_url}}",
+						"OPENAI_API_KEY":  "{{api_key}}",
+					},
+				}},
+			},
+		},
+		{
+			ID:          "iflow",
+			Name:        "iFlow CLI",
+			Description: "把 ~/.iflow/settings.json 切换为 OpenAI 兼容认证，请求转发到 Codex 的 provider",
+			Homepage:    "https://platform.iflow.cn/cli",
+			Binaries:    []string{"iflow"},
+			Tool: CustomCliTool{
+				Protocol: customCliProtocolOpenAI,
+				ConfigFiles: []CustomCliConfigFile{{
+					Path:   filepath.Join(home, ".iflow", "settings.json"),
+					Format: customCliFormatJSON,
+					ProxyFields: map[string]any{
+						"selectedAuthType": "openai-compatible",
+						"baseUrl":          "{{base_url}}",
+						"apiKey":           "{{api_key}}",
+					},
+				}},
+			},
+		},
+	}
+}
+
+// ListCustomCliPresets 返回内置的工具预设
+func (cs *CustomCliService) ListCustomCliPresets() []CustomCliPreset {
+	presets := customCliPresets()
+	for i := range presets {
+		presets[i].Tool.ID = presets[i].ID
+		presets[i].Tool.Name = presets[i].Name
+		presets[i].Tool.Preset = presets[i].ID
+	}
+	return presets
+}
+
+// AddCustomCliPreset 按预设添加工具，已添加过时返回现有定义
+func (cs *CustomCliService) AddCustomCliPreset(id string) (CustomCliTool, error) {
+	for _, preset := range cs.ListCustomCliPresets() {
+		if preset.ID != id {
+			continue
+		}
+		if tool, err := findCustomCliTool(preset.ID); err == nil {
+			return tool, nil
+		}
+		return cs.SaveCustomCliTool(preset.Tool)
+	}
+	return CustomCliTool{}, fmt.Errorf("未知的工具预设 %s", id)
+}
+
+// xdgConfigHome Windows 上同样使用 ~/.config，与 OpenCode 等工具的行为一致
+func xdgConfigHome() string {
+	if dir := strings.TrimSpace(os.Getenv("XDG_CONFIG_HOME")); dir != "" && runtime.GOOS != "windows" {
+		return dir
+	}
+	return filepath.Join(userHomeDir(), ".config")
+}
+
+func crushConfigPath() string {
+	if runtime.GOOS == "windows" {
+		if dir := strings.TrimSpace(os.Getenv("LOCALAPPDATA")); dir != "" {
+			return filepath.Join(dir, "crush", "crush.json")
+		}
+		return filepath.Join(userHomeDir(), "AppData", "Local", "crush", "crush.json")
+	}
+	return filepath.Join(xdgConfigHome(), "crush", "crush.json")
+}
+
+func gooseConfigPath() string {
+	if runtime.GOOS == "windows" {
+		if dir := strings.TrimSpace(os.Getenv("APPDATA")); dir != "" {
+			return filepath.Join(dir, "Block", "goose", "config", "config.yaml")
+		}
+		return filepath.Join(userHomeDir(), "AppData", "Roaming", "Block", "goose", "config", "config.yaml")
+	}
+	return filepath.Join(xdgConfigHome(), "goose", "config.yaml")
+}
+
+func vscodeUserDir() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return filepath.Join(userHomeDir(), "Library", "Application Support", "Code", "User")
+	case "windows":
+		if dir := strings.TrimSpace(os.Getenv("APPDATA")); dir != "" {
+			return filepath.Join(dir, "Code", "User")
+		}
+		return filepath.Join(userHomeDir(), "AppData", "Roaming", "Code", "User")
+	default:
+		return filepath.Join(xdgConfigHome(), "Code", "User")
+	}
+}
@@ -0,0 +1,792 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+const (
+	customCliToolsFile = "custom-cli.json"
+	customCliStateFile = "custom-cli-state.json"
+	// customCliRoutePrefix 自定义工具经由 /custom/<id> 访问 relay，relay 据此区分工具
+	customCliRoutePrefix = "/custom/"
+	customCliTokenValue  = "code-switch"
+
+	customCliFormatJSON = "json"
+	customCliFormatTOML = "toml"
+	customCliFormatEnv  = "env"
+
+	// 工具使用的 API 协议：anthropic 走 Claude 的 provider，openai 走 Codex 的 provider
+	customCliProtocolAnthropic = "anthropic"
+	customCliProtocolOpenAI    = "openai"
+)
+
+// CustomCliConfigFile 工具的一个配置文件。ProxyFields 为开启代理时写入的字段：
+// json/toml/yaml 的键用 . 分隔嵌套（键名本身含 . 时写作 \.），env 为变量名；
+// 值中的 {{base_url}} 与 {{api_key}} 会替换为 relay 地址和令牌
+type CustomCliConfigFile struct {
+	Path        string         `json:"path"`
+	Format      string         `json:"format"`
+	ProxyFields map[string]any `json:"proxy_fields"`
+}
+
+// CustomCliTool 由 code-switch 接管的其他 AI CLI。ConfigFiles 为空时无法自动写入，
+// 需按 Notes 手动把 relay 地址填到工具里。Providers 为该工具专用的 provider 名称（取自协议对应的平台），
+// 按故障切换顺序排列，为空时使用平台的全部 provider
+type CustomCliTool struct {
+	ID          string                `json:"id"`
+	Name        string                `json:"name"`
+	Preset      string                `json:"preset,omitempty"`
+	Protocol    string                `json:"protocol"`
+	ConfigFiles []CustomCliConfigFile `json:"config_files"`
+	Providers   []string              `json:"providers,omitempty"`
+	Notes       string                `json:"notes,omitempty"`
+}
+
+// CustomCliProxyStatus 工具的代理状态，BaseURL 为该工具专属的 relay 地址，
+// Scope 为请求日志与拉黑列表中该工具使用的平台名
+type CustomCliProxyStatus struct {
+	Enabled bool   `json:"enabled"`
+	BaseURL string `json:"base_url"`
+	APIKey  string `json:"api_key"`
+	Scope   string `json:"scope"`
+}
+
+// customCliProxyState 开启代理时每个文件被覆盖的原值，按文件路径索引
+type customCliProxyState map[string]directApplyState
+
+type CustomCliService struct {
+	relayAddr string
+	mu        sync.Mutex
+}
+
+func NewCustomCliService(relayAddr string) *CustomCliService {
+	return &CustomCliService{relayAddr: relayAddr}
+}
+
+// ListCustomCliTools 返回已添加的自定义工具
+func (cs *CustomCliService) ListCustomCliTools() ([]CustomCliTool, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return loadCustomCliTools()
+}
+
+// SaveCustomCliTool 新增或更新自定义工具，ID 为空时根据名称生成。代理开启中的工具需要先关闭再修改配置文件
+func (cs *CustomCliService) SaveCustomCliTool(tool CustomCliTool) (CustomCliTool, error) {
+	tool, err := normalizeCustomCliTool(tool)
+	if err != nil {
+		return CustomCliTool{}, err
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	tools, err := loadCustomCliTools()
+	if err != nil {
+		return CustomCliTool{}, err
+	}
+	states, err := loadCustomCliStates()
+	if err != nil {
+		return CustomCliTool{}, err
+	}
+	replaced := false
+	for i := range tools {
+		if tools[i].ID != tool.ID {
+			continue
+		}
+		if _, enabled := states[tool.ID]; enabled && !sameCustomCliFiles(tools[i].ConfigFiles, tool.ConfigFiles) {
+			return CustomCliTool{}, fmt.Errorf("%s 的代理已开启，请先关闭再修改配置文件", tool.Name)
+		}
+		tools[i] = tool
+		replaced = true
+		break
+	}
+	if !replaced {
+		tools = append(tools, tool)
+	}
+	if err := saveCustomCliTools(tools); err != nil {
+		return CustomCliTool{}, err
+	}
+	return tool, nil
+}
+
+// DeleteCustomCliTool 删除自定义工具，代理开启时先恢复其配置文件
+func (cs *CustomCliService) DeleteCustomCliTool(id string) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	tools, err := loadCustomCliTools()
+	if err != nil {
+		return err
+	}
+	index := -1
+	for i := range tools {
+		if tools[i].ID == id {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("自定义工具不存在: %s", id)
+	}
+	if err := cs.disableProxyLocked(tools[index]); err != nil {
+		return err
+	}
+	return saveCustomCliTools(append(tools[:index], tools[index+1:]...))
+}
+
+// EnableProxy 把工具的代理字段写入其配置文件，被覆盖的原值记录下来，关闭时恢复
+func (cs *CustomCliService) EnableProxy(id string) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	tool, err := findCustomCliTool(id)
+	if err != nil {
+		return err
+	}
+	if len(tool.ConfigFiles) == 0 {
+		return fmt.Errorf("%s 没有可写入的配置文件，请按说明手动配置", tool.Name)
+	}
+	states, err := loadCustomCliStates()
+	if err != nil {
+		return err
+	}
+	snapshotConfigFiles("custom/"+tool.ID+":enable-proxy", customCliFilePaths(tool)...)
+	// 重复开启时先恢复，避免把上次写入的代理值当作原值记录
+	if previous, ok := states[tool.ID]; ok {
+		if err := restoreCustomCliProxy(tool, previous); err != nil {
+			return err
+		}
+	}
+	state, err := applyCustomCliProxy(tool, cs.toolBaseURL(tool.ID), customCliTokenValue)
+	if err != nil {
+		return err
+	}
+	states[tool.ID] = state
+	return saveCustomCliStates(states)
+}
+
+// DisableProxy 删除写入的代理字段，被覆盖的原值恢复原状
+func (cs *CustomCliService) DisableProxy(id string) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	tool, err := findCustomCliTool(id)
+	if err != nil {
+		return err
+	}
+	return cs.disableProxyLocked(tool)
+}
+
+// ProxyStatus 所有代理字段都与期望值一致时视为已开启
+func (cs *CustomCliService) ProxyStatus(id string) (CustomCliProxyStatus, error) {
+	status := CustomCliProxyStatus{BaseURL: cs.toolBaseURL(id), APIKey: customCliTokenValue, Scope: customCliScope(id)}
+	tool, err := findCustomCliTool(id)
+	if err != nil {
+		return status, err
+	}
+	status.Enabled, err = cs.proxyApplied(tool)
+	return status, err
+}
+
+func (cs *CustomCliService) proxyApplied(tool CustomCliTool) (bool, error) {
+	if len(tool.ConfigFiles) == 0 {
+		return false, nil
+	}
+	for _, file := range tool.ConfigFiles {
+		payload, _, err := readCustomCliFile(file)
+		if err != nil {
+			return false, err
+		}
+		for key, value := range renderCustomCliFields(file.ProxyFields, cs.toolBaseURL(tool.ID), customCliTokenValue) {
+			current, ok := customCliGet(payload, file.Format, key)
+			if !ok || fmt.Sprint(current) != fmt.Sprint(value) {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+// driftedTools 返回已开启代理、但代理字段被其他程序改写的工具；文件无法解析同样视为被改写
+func (cs *CustomCliService) driftedTools() ([]CustomCliTool, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	tools, err := loadCustomCliTools()
+	if err != nil {
+		return nil, err
+	}
+	states, err := loadCustomCliStates()
+	if err != nil {
+		return nil, err
+	}
+	drifted := make([]CustomCliTool, 0)
+	for _, tool := range tools {
+		if _, ok := states[tool.ID]; !ok {
+			continue
+		}
+		if applied, err := cs.proxyApplied(tool); err != nil || !applied {
+			drifted = append(drifted, tool)
+		}
+	}
+	return drifted, nil
+}
+
+// proxyConfigFiles 返回已开启代理的工具的配置文件
+func (cs *CustomCliService) proxyConfigFiles() []string {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	tools, err := loadCustomCliTools()
+	if err != nil {
+		return nil
+	}
+	states, err := loadCustomCliStates()
+	if err != nil {
+		return nil
+	}
+	paths := make([]string, 0)
+	for _, tool := range tools {
+		if _, ok := states[tool.ID]; !ok {
+			continue
+		}
+		paths = append(paths, customCliFilePaths(tool)...)
+	}
+	return paths
+}
+
+// GetLockedFields 返回代理开启时由 code-switch 管理的字段，按文件路径分组；编辑配置时这些字段不应被修改
+func (cs *CustomCliService) GetLockedFields(id string) (map[string][]string, error) {
+	tool, err := findCustomCliTool(id)
+	if err != nil {
+		return nil, err
+	}
+	states, err := loadCustomCliStates()
+	if err != nil {
+		return nil, err
+	}
+	locked := make(map[string][]string)
+	state, ok := states[tool.ID]
+	if !ok {
+		return locked, nil
+	}
+	for path, fileState := range state {
+		locked[path] = append([]string(nil), fileState.Keys...)
+	}
+	return locked, nil
+}
+
+func (cs *CustomCliService) disableProxyLocked(tool CustomCliTool) error {
+	states, err := loadCustomCliStates()
+	if err != nil {
+		return err
+	}
+	state, ok := states[tool.ID]
+	if !ok {
+		return nil
+	}
+	snapshotConfigFiles("custom/"+tool.ID+":disable-proxy", customCliFilePaths(tool)...)
+	if err := restoreCustomCliProxy(tool, state); err != nil {
+		return err
+	}
+	delete(states, tool.ID)
+	return saveCustomCliStates(states)
+}
+
+func customCliFilePaths(tool CustomCliTool) []string {
+	paths := make([]string, 0, len(tool.ConfigFiles))
+	for _, file := range tool.ConfigFiles {
+		paths = append(paths, file.Path)
+	}
+	return paths
+}
+
+func (cs *CustomCliService) toolBaseURL(id string) string {
+	return relayBaseURL(cs.relayAddr) + customCliRoutePrefix + id
+}
+
+// applyCustomCliProxy 依次写入每个文件，失败时回滚已写入的文件
+func applyCustomCliProxy(tool CustomCliTool, baseURL string, apiKey string) (customCliProxyState, error) {
+	state := make(customCliProxyState, len(tool.ConfigFiles))
+	for _, file := range tool.ConfigFiles {
+		payload, mode, err := readCustomCliFile(file)
+		if err != nil {
+			_ = restoreCustomCliProxy(tool, state)
+			return nil, err
+		}
+		fileState := directApplyState{Provider: tool.Name, Previous: make(map[string]any)}
+		for key, value := range renderCustomCliFields(file.ProxyFields, baseURL, apiKey) {
+			if old, ok := customCliGet(payload, file.Format, key); ok {
+				fileState.Previous[key] = old
+			}
+			customCliSet(payload, file.Format, key, value)
+			fileState.Keys = append(fileState.Keys, key)
+		}
+		sort.Strings(fileState.Keys)
+		if err := writeCustomCliFile(file, payload, mode); err != nil {
+			_ = restoreCustomCliProxy(tool, state)
+			return nil, err
+		}
+		state[file.Path] = fileState
+	}
+	return state, nil
+}
+
+func restoreCustomCliProxy(tool CustomCliTool, state customCliProxyState) error {
+	var errs []error
+	for _, file := range tool.ConfigFiles {
+		fileState, ok := state[file.Path]
+		if !ok {
+			continue
+		}
+		payload, mode, err := readCustomCliFile(file)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, key := range fileState.Keys {
+			if old, ok := fileState.Previous[key]; ok {
+				customCliSet(payload, file.Format, key, old)
+				continue
+			}
+			customCliDelete(payload, file.Format, key)
+		}
+		if err := writeCustomCliFile(file, payload, mode); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// renderCustomCliFields 只替换字符串值中的占位符，布尔与数字原样写入
+func renderCustomCliFields(fields map[string]any, baseURL string, apiKey string) map[string]any {
+	replacer := strings.NewReplacer("{{base_url}}", baseURL, "{{api_key}}", apiKey)
+	rendered := make(map[string]any, len(fields))
+	for key, value := range fields {
+		if text, ok := value.(string); ok {
+			value = replacer.Replace(text)
+		}
+		rendered[key] = value
+	}
+	return rendered
+}
+
+// readCustomCliFile 读取配置文件；文件无法解析时返回错误而不是覆盖，避免丢失用户的其他配置
+func readCustomCliFile(file CustomCliConfigFile) (map[string]any, os.FileMode, error) {
+	payload := make(map[string]any)
+	mode := os.FileMode(0o600)
+	data, err := os.ReadFile(file.Path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return payload, mode, nil
+		}
+		return nil, 0, err
+	}
+	if info, err := os.Stat(file.Path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return payload, mode, nil
+	}
+	switch file.Format {
+	case customCliFormatTOML:
+		err = toml.Unmarshal(data, &payload)
+	case customCliFormatEnv:
+		for key, value := range parseEnvLines(data) {
+			payload[key] = value
+		}
+	case customCliFormatYAML:
+		payload, err = parseCustomCliYAML(data)
+	default:
+		payload, err = parseJSONC(data)
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("解析 %s 失败: %w", file.Path, err)
+	}
+	if payload == nil {
+		payload = make(map[string]any)
+	}
+	return payload, mode, nil
+}
+
+func writeCustomCliFile(file CustomCliConfigFile, payload map[string]any, mode os.FileMode) error {
+	original, err := os.ReadFile(file.Path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	var data []byte
+	switch file.Format {
+	case customCliFormatTOML:
+		data, err = encodeTOMLPreserving(original, payload)
+	case customCliFormatEnv:
+		data, err = patchEnvLines(original, payload), nil
+	case customCliFormatYAML:
+		data, err = patchCustomCliYAML(original, payload)
+	default:
+		data, err = encodeJSONPreserving(original, payload)
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file.Path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(file.Path, data, mode)
+}
+
+// parseEnvLines 解析 KEY=VALUE 行，忽略注释与 export 前缀，去掉值两侧的引号
+func parseEnvLines(data []byte) map[string]string {
+	values := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, ok := splitEnvLine(line)
+		if ok {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+func splitEnvLine(line string) (string, string, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", "", false
+	}
+	line = strings.TrimPrefix(line, "export ")
+	key, value, ok := strings.Cut(line, "=")
+	if !ok {
+		return "", "", false
+	}
+	key = strings.TrimSpace(key)
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		value = value[1 : len(value)-1]
+	}
+	return key, value, key != ""
+}
+
+// patchEnvLines 只改动值有变化或被删除的行，注释与顺序保持不变，新增的变量追加在末尾
+func patchEnvLines(original []byte, payload map[string]any) []byte {
+	var lines []string
+	if len(original) > 0 {
+		lines = strings.Split(strings.TrimRight(string(original), "\n"), "\n")
+	}
+	seen := make(map[string]struct{}, len(payload))
+	result := make([]string, 0, len(lines)+len(payload))
+	for _, line := range lines {
+		key, value, ok := splitEnvLine(line)
+		if !ok {
+			result = append(result, line)
+			continue
+		}
+		next, keep := payload[key]
+		if !keep {
+			continue
+		}
+		seen[key] = struct{}{}
+		if fmt.Sprint(next) == value {
+			result = append(result, line)
+			continue
+		}
+		result = append(result, formatEnvLine(key, fmt.Sprint(next)))
+	}
+	added := make([]string, 0)
+	for key := range payload {
+		if _, ok := seen[key]; !ok {
+			added = append(added, key)
+		}
+	}
+	sort.Strings(added)
+	for _, key := range added {
+		result = append(result, formatEnvLine(key, fmt.Sprint(payload[key])))
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(result, "\n") + "\n")
+}
+
+func formatEnvLine(key string, value string) string {
+	if strings.ContainsAny(value, " #\"'") {
+		return key + "=" + fmt.Sprintf("%q", value)
+	}
+	return key + "=" + value
+}
+
+// splitCustomCliKey 按未转义的 . 拆分键，env 格式的变量名不拆分
+func splitCustomCliKey(format string, key string) []string {
+	if format == customCliFormatEnv {
+		return []string{key}
+	}
+	var parts []string
+	var current strings.Builder
+	for i := 0; i < len(key); i++ {
+		switch {
+		case key[i] == '\\' && i+1 < len(key) && key[i+1] == '.':
+			current.WriteByte('.')
+			i++
+		case key[i] == '.':
+			parts = append(parts, current.String())
+			current.Reset()
+		default:
+			current.WriteByte(key[i])
+		}
+	}
+	return append(parts, current.String())
+}
+
+func customCliGet(payload map[string]any, format string, key string) (any, bool) {
+	parts := splitCustomCliKey(format, key)
+	current := payload
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := current[part].(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		current = next
+	}
+	value, ok := current[parts[len(parts)-1]]
+	return value, ok
+}
+
+func customCliSet(payload map[string]any, format string, key string, value any) {
+	parts := splitCustomCliKey(format, key)
+	current := payload
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := current[part].(map[string]any)
+		if !ok {
+			next = make(map[string]any)
+			current[part] = next
+		}
+		current = next
+	}
+	current[parts[len(parts)-1]] = value
+}
+
+// customCliDelete 删除字段，并清理因此变空的上级表
+func customCliDelete(payload map[string]any, format string, key string) {
+	parts := splitCustomCliKey(format, key)
+	parents := []map[string]any{payload}
+	current := payload
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := current[part].(map[string]any)
+		if !ok {
+			return
+		}
+		parents = append(parents, next)
+		current = next
+	}
+	delete(current, parts[len(parts)-1])
+	for i := len(parents) - 1; i > 0; i-- {
+		if len(parents[i]) > 0 {
+			break
+		}
+		delete(parents[i-1], parts[i-1])
+	}
+}
+
+func normalizeCustomCliTool(tool CustomCliTool) (CustomCliTool, error) {
+	tool.Name = strings.TrimSpace(tool.Name)
+	if tool.Name == "" {
+		return tool, errors.New("名称不能为空")
+	}
+	tool.ID = strings.ToLower(strings.TrimSpace(tool.ID))
+	if tool.ID == "" {
+		tool.ID = strings.Trim(mcpTargetSlugPattern.ReplaceAllString(strings.ToLower(tool.Name), "-"), "-")
+	}
+	if !mcpTargetIDPattern.MatchString(tool.ID) {
+		return tool, fmt.Errorf("ID 无效: %q", tool.ID)
+	}
+	tool.Protocol = strings.ToLower(strings.TrimSpace(tool.Protocol))
+	switch tool.Protocol {
+	case customCliProtocolAnthropic, customCliProtocolOpenAI:
+	case "":
+		tool.Protocol = customCliProtocolAnthropic
+	default:
+		return tool, fmt.Errorf("不支持的协议: %s", tool.Protocol)
+	}
+	seen := make(map[string]struct{}, len(tool.ConfigFiles))
+	for i := range tool.ConfigFiles {
+		file := &tool.ConfigFiles[i]
+		path := strings.TrimSpace(file.Path)
+		if path == "~" || strings.HasPrefix(path, "~/") {
+			path = filepath.Join(userHomeDir(), strings.TrimPrefix(path, "~"))
+		}
+		if path == "" || !filepath.IsAbs(path) {
+			return tool, errors.New("配置文件路径需要是绝对路径")
+		}
+		file.Path = filepath.Clean(path)
+		if _, dup := seen[file.Path]; dup {
+			return tool, fmt.Errorf("配置文件重复: %s", file.Path)
+		}
+		seen[file.Path] = struct{}{}
+		file.Format = strings.ToLower(strings.TrimSpace(file.Format))
+		if file.Format == "" {
+			file.Format = customCliFormatFromPath(file.Path)
+		}
+		if !isCustomCliFormat(file.Format) {
+			return tool, fmt.Errorf("不支持的格式: %s", file.Format)
+		}
+		fields := make(map[string]any, len(file.ProxyFields))
+		for key, value := range file.ProxyFields {
+			if key = strings.TrimSpace(key); key != "" {
+				fields[key] = value
+			}
+		}
+		if len(fields) == 0 {
+			return tool, fmt.Errorf("%s 没有需要写入的代理字段", file.Path)
+		}
+		file.ProxyFields = fields
+	}
+	providers := make([]string, 0, len(tool.Providers))
+	seenProviders := make(map[string]struct{}, len(tool.Providers))
+	for _, name := range tool.Providers {
+		name = strings.TrimSpace(name)
+		if name == "" || containsNormalized(seenProviders, name) {
+			continue
+		}
+		seenProviders[strings.ToLower(name)] = struct{}{}
+		providers = append(providers, name)
+	}
+	tool.Providers = providers
+	tool.Notes = strings.TrimSpace(tool.Notes)
+	return tool, nil
+}
+
+func isCustomCliFormat(format string) bool {
+	switch format {
+	case customCliFormatJSON, customCliFormatTOML, customCliFormatEnv, customCliFormatYAML:
+		return true
+	}
+	return false
+}
+
+func customCliFormatFromPath(path string) string {
+	base := strings.ToLower(filepath.Base(path))
+	switch {
+	case strings.HasSuffix(base, ".toml"):
+		return customCliFormatTOML
+	case strings.HasSuffix(base, ".yaml") || strings.HasSuffix(base, ".yml"):
+		return customCliFormatYAML
+	case base == ".env" || strings.HasSuffix(base, ".env"):
+		return customCliFormatEnv
+	default:
+		return customCliFormatJSON
+	}
+}
+
+func sameCustomCliFiles(a, b []CustomCliConfigFile) bool {
+	left, _ := json.Marshal(a)
+	right, _ := json.Marshal(b)
+	return string(left) == string(right)
+}
+
+// customCliProviderKind 工具的请求交给哪个平台的 provider 处理
+func customCliProviderKind(tool CustomCliTool) string {
+	if tool.Protocol == customCliProtocolOpenAI {
+		return "codex"
+	}
+	return "claude"
+}
+
+// customCliScope 工具的拉黑、故障切换事件与请求日志使用的平台名，可直接用于日志与拉黑列表的平台筛选
+func customCliScope(id string) string {
+	return "custom/" + id
+}
+
+// normalizeRelayScope 规范化拉黑与事件使用的平台名：claude、codex、gemini 或 custom/<id>，无法识别时返回空
+func normalizeRelayScope(platform string) string {
+	platform = strings.ToLower(strings.TrimSpace(platform))
+	if id, ok := strings.CutPrefix(platform, "custom/"); ok {
+		if id = strings.TrimSpace(id); id == "" {
+			return ""
+		}
+		return customCliScope(id)
+	}
+	return normalizePresetKind(platform)
+}
+
+func findCustomCliTool(id string) (CustomCliTool, error) {
+	id = strings.ToLower(strings.TrimSpace(id))
+	tools, err := loadCustomCliTools()
+	if err != nil {
+		return CustomCliTool{}, err
+	}
+	for _, tool := range tools {
+		if tool.ID == id {
+			return tool, nil
+		}
+	}
+	return CustomCliTool{}, fmt.Errorf("自定义工具不存在: %s", id)
+}
+
+func loadCustomCliTools() ([]CustomCliTool, error) {
+	dir, err := ensureDataDir()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, customCliToolsFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []CustomCliTool{}, nil
+		}
+		return nil, err
+	}
+	tools := []CustomCliTool{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &tools); err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(tools, func(i, j int) bool {
+		return strings.ToLower(tools[i].Name) < strings.ToLower(tools[j].Name)
+	})
+	return tools, nil
+}
+
+func saveCustomCliTools(tools []CustomCliTool) error {
+	return writeCustomCliDataFile(customCliToolsFile, tools)
+}
+
+func loadCustomCliStates() (map[string]customCliProxyState, error) {
+	dir, err := ensureDataDir()
+	if err != nil {
+		return nil, err
+	}
+	states := make(map[string]customCliProxyState)
+	data, err := os.ReadFile(filepath.Join(dir, customCliStateFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return states, nil
+		}
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &states); err != nil {
+			return nil, err
+		}
+	}
+	return states, nil
+}
+
+func saveCustomCliStates(states map[string]customCliProxyState) error {
+	return writeCustomCliDataFile(customCliStateFile, states)
+}
+
+func writeCustomCliDataFile(name string, value any) error {
+	dir, err := ensureDataDir()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, name)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
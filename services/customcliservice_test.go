@@ -0,0 +1,208 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCustomCliProxyRestoresFiles(t *testing.T) {
+	dir := t.TempDir()
+	settingsPath := filepath.Join(dir, "settings.json")
+	envPath := filepath.Join(dir, ".env")
+	writeSkillFiles(t, dir, map[string]string{
+		"settings.json": `{"theme":"dark","apiKey":"sk-mine","roo-cline.autoImportSettingsPath":"/old"}`,
+		".env":          "# keys\nOPENAI_API_KEY=sk-mine\nOTHER=1\n",
+	})
+	tool := CustomCliTool{
+		Name: "Test",
+		ConfigFiles: []CustomCliConfigFile{
+			{Path: settingsPath, Format: customCliFormatJSON, ProxyFields: map[string]any{
+				"apiKey":                            "{{api_key}}",
+				"provider.options.baseURL":          "{{base_url}}/v1",
+				`roo-cline\.autoImportSettingsPath`: "/new",
+				"enabled":                           true,
+			}},
+			{Path: envPath, Format: customCliFormatEnv, ProxyFields: map[string]any{
+				"OPENAI_API_KEY":  "{{api_key}}",
+				"OPENAI_BASE_URL": "{{base_url}}",
+			}},
+		},
+	}
+
+	state, err := applyCustomCliProxy(tool, "http://127.0.0.1:18100/custom/test", "code-switch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payload map[string]any
+	data, _ := os.ReadFile(settingsPath)
+	if err := json.Unmarshal(data, &payload); err != nil {
+		t.Fatal(err)
+	}
+	provider, _ := payload["provider"].(map[string]any)
+	options, _ := provider["options"].(map[string]any)
+	if payload["apiKey"] != "code-switch" || options["baseURL"] != "http://127.0.0.1:18100/custom/test/v1" ||
+		payload["roo-cline.autoImportSettingsPath"] != "/new" || payload["enabled"] != true {
+		t.Fatalf("proxy fields not written: %s", data)
+	}
+	env, _ := os.ReadFile(envPath)
+	if string(env) != "# keys\nOPENAI_API_KEY=code-switch\nOTHER=1\nOPENAI_BASE_URL=http://127.0.0.1:18100/custom/test\n" {
+		t.Fatalf("env = %q", env)
+	}
+
+	if err := restoreCustomCliProxy(tool, state); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(settingsPath)
+	payload = nil
+	if err := json.Unmarshal(data, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if len(payload) != 3 || payload["apiKey"] != "sk-mine" || payload["roo-cline.autoImportSettingsPath"] != "/old" {
+		t.Fatalf("settings not restored: %s", data)
+	}
+	env, _ = os.ReadFile(envPath)
+	if string(env) != "# keys\nOPENAI_API_KEY=sk-mine\nOTHER=1\n" {
+		t.Fatalf("env not restored: %q", env)
+	}
+}
+
+func TestCustomCliPresetsAreValid(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cs := NewCustomCliService(":18100")
+	for _, preset := range cs.ListCustomCliPresets() {
+		tool, err := normalizeCustomCliTool(preset.Tool)
+		if err != nil {
+			t.Fatalf("preset %s: %v", preset.ID, err)
+		}
+		if tool.ID != preset.ID {
+			t.Fatalf("preset %s normalized to id %s", preset.ID, tool.ID)
+		}
+		for _, file := range tool.ConfigFiles {
+			for key, value := range file.ProxyFields {
+				if text, ok := value.(string); ok && strings.Contains(text, "{{") &&
+					!strings.Contains(text, "{{base_url}}") && !strings.Contains(text, "{{api_key}}") {
+					t.Fatalf("preset %s field %s has unknown placeholder %q", preset.ID, key, text)
+				}
+			}
+		}
+	}
+}
+
+func TestCustomCliYAMLKeepsComments(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeSkillFiles(t, dir, map[string]string{
+		"config.yaml": "# goose settings\nGOOSE_PROVIDER: openai # default provider\nGOOSE_MODEL: gpt-4o\nextensions:\n  developer:\n    enabled: true # keep\n",
+	})
+	tool := CustomCliTool{Name: "Goose", ConfigFiles: []CustomCliConfigFile{{
+		Path:        path,
+		Format:      customCliFormatYAML,
+		ProxyFields: map[string]any{"GOOSE_PROVIDER": "anthropic", "ANTHROPIC_HOST": "{{base_url}}"},
+	}}}
+
+	state, err := applyCustomCliProxy(tool, "http://127.0.0.1:18100/custom/goose", "code-switch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	for _, want := range []string{"# goose settings", "GOOSE_PROVIDER: anthropic # default provider", "enabled: true # keep", "ANTHROPIC_HOST: http://127.0.0.1:18100/custom/goose"} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("missing %q in:\n%s", want, data)
+		}
+	}
+	if err := restoreCustomCliProxy(tool, state); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(path)
+	if strings.Contains(string(data), "ANTHROPIC_HOST") || !strings.Contains(string(data), "GOOSE_PROVIDER: openai # default provider") {
+		t.Fatalf("yaml not restored:\n%s", data)
+	}
+}
+
+func TestCustomCliProviderPool(t *testing.T) {
+	tool, err := normalizeCustomCliTool(CustomCliTool{Name: "Aider", Providers: []string{" Kimi ", "", "deepseek", "kimi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(tool.Providers, ",") != "Kimi,deepseek" {
+		t.Fatalf("providers = %q", tool.Providers)
+	}
+
+	providers := []Provider{{Name: "Anthropic"}, {Name: "DeepSeek"}, {Name: "kimi"}}
+	picked := pickPoolProviders(providers, tool.Providers)
+	if len(picked) != 2 || picked[0].Name != "kimi" || picked[1].Name != "DeepSeek" {
+		t.Fatalf("picked = %+v", picked)
+	}
+}
+
+func TestCustomCliConfigEditor(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	writeSkillFiles(t, dir, map[string]string{
+		"config.toml": "theme = \"dark\"\ntemperature = 0.5\n\n[provider.anthropic]\nbase_url = \"http://127.0.0.1:18100/custom/x\"\ntimeout = 30\n",
+	})
+	file := CustomCliConfigFile{Path: path, Format: customCliFormatTOML}
+	locked := []string{"provider.anthropic.base_url"}
+
+	doc := buildCustomCliConfigDocument(file, locked)
+	if doc.Error != "" || !doc.Exists || len(doc.Nodes) != 3 {
+		t.Fatalf("doc = %+v", doc)
+	}
+	provider := doc.Nodes[0]
+	if provider.Path != "provider" || provider.Type != "object" || provider.Locked {
+		t.Fatalf("provider node = %+v", provider)
+	}
+	anthropic := provider.Children[0]
+	if !anthropic.Children[0].Locked || anthropic.Children[0].Path != "provider.anthropic.base_url" || anthropic.Children[1].Locked {
+		t.Fatalf("anthropic node = %+v", anthropic)
+	}
+
+	payload, _, err := readCustomCliFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, patch := range []CustomCliConfigPatch{
+		{Path: "provider.anthropic.base_url", Value: "https://example.com"},
+		{Path: "provider", Delete: true},
+		{Path: "theme.name", Value: "x"},
+		{Path: "model", Value: nil},
+	} {
+		if err := applyCustomCliPatch(payload, file.Format, locked, patch); err == nil {
+			t.Fatalf("patch %+v accepted", patch)
+		}
+	}
+	for _, patch := range []CustomCliConfigPatch{
+		{Path: "provider.anthropic.timeout", Value: float64(60)},
+		{Path: "temperature", Value: float64(1)},
+		{Path: "theme", Delete: true},
+		{Path: `tools.web\.search.enabled`, Value: true},
+	} {
+		if err := applyCustomCliPatch(payload, file.Format, locked, patch); err != nil {
+			t.Fatalf("patch %+v: %v", patch, err)
+		}
+	}
+	if value, _ := customCliGet(payload, file.Format, "provider.anthropic.timeout"); value != int64(60) {
+		t.Fatalf("timeout = %#v", value)
+	}
+	if value, _ := customCliGet(payload, file.Format, "temperature"); value != float64(1) {
+		t.Fatalf("temperature = %#v", value)
+	}
+	if _, ok := payload["theme"]; ok {
+		t.Fatal("theme not deleted")
+	}
+	tools, _ := payload["tools"].(map[string]any)
+	if search, _ := tools["web.search"].(map[string]any); search["enabled"] != true {
+		t.Fatalf("tools = %+v", tools)
+	}
+
+	if err := applyCustomCliPatch(map[string]any{}, customCliFormatEnv, nil, CustomCliConfigPatch{Path: "BAD KEY", Value: "1"}); err == nil {
+		t.Fatal("invalid env name accepted")
+	}
+	env := map[string]any{}
+	if err := applyCustomCliPatch(env, customCliFormatEnv, nil, CustomCliConfigPatch{Path: "MAX_TOKENS", Value: float64(4096)}); err != nil || env["MAX_TOKENS"] != "4096" {
+		t.Fatalf("env = %+v, err = %v", env, err)
+	}
+}
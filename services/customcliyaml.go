@@ -0,0 +1,107 @@
+package services
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// customCliFormatYAML 部分 CLI（Goose、continue.dev 等）使用 YAML 配置
+const customCliFormatYAML = "yaml"
+
+func parseCustomCliYAML(data []byte) (map[string]any, error) {
+	payload := make(map[string]any)
+	if err := yaml.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// patchCustomCliYAML 在原文档上按 payload 增删改键，没有变化的节点原样保留，注释与顺序不受影响
+func patchCustomCliYAML(original []byte, payload map[string]any) ([]byte, error) {
+	var doc yaml.Node
+	if len(bytes.TrimSpace(original)) > 0 {
+		if err := yaml.Unmarshal(original, &doc); err != nil {
+			return nil, err
+		}
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	if err := syncYAMLMapping(doc.Content[0], payload); err != nil {
+		return nil, err
+	}
+	if len(doc.Content[0].Content) == 0 {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func syncYAMLMapping(node *yaml.Node, values map[string]any) error {
+	seen := make(map[string]struct{}, len(values))
+	content := make([]*yaml.Node, 0, len(node.Content))
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		next, ok := values[key.Value]
+		if !ok {
+			continue
+		}
+		seen[key.Value] = struct{}{}
+		if nested, isMap := next.(map[string]any); isMap && value.Kind == yaml.MappingNode {
+			if err := syncYAMLMapping(value, nested); err != nil {
+				return err
+			}
+		} else if !yamlNodeEquals(value, next) {
+			replacement, err := yamlValueNode(next)
+			if err != nil {
+				return err
+			}
+			replacement.HeadComment, replacement.LineComment = value.HeadComment, value.LineComment
+			value = replacement
+		}
+		content = append(content, key, value)
+	}
+	added := make([]string, 0)
+	for key := range values {
+		if _, ok := seen[key]; !ok {
+			added = append(added, key)
+		}
+	}
+	sort.Strings(added)
+	for _, key := range added {
+		value, err := yamlValueNode(values[key])
+		if err != nil {
+			return err
+		}
+		content = append(content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+	}
+	node.Content = content
+	return nil
+}
+
+func yamlNodeEquals(node *yaml.Node, value any) bool {
+	var current any
+	if err := node.Decode(&current); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(current, value)
+}
+
+func yamlValueNode(value any) (*yaml.Node, error) {
+	var node yaml.Node
+	if err := node.Encode(value); err != nil {
+		return nil, err
+	}
+	return &node, nil
+}
@@ -0,0 +1,368 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultDataDirName = ".code-switch"
+	// dataDirEnv 环境变量指定的数据目录优先级最高
+	dataDirEnv = "CODE_SWITCH_DATA_DIR"
+	// portableMarkerFile 可执行文件旁存在该文件时进入便携模式，数据放在同目录的 data 下
+	portableMarkerFile = "portable"
+	portableDataDir    = "data"
+	// dataDirPointerFile 记录自定义数据目录与待迁移的旧目录，固定放在用户目录下
+	dataDirPointerFile = ".code-switch-location.json"
+)
+
+// 数据目录模式
+const (
+	DataDirModeDefault  = "default"
+	DataDirModeCustom   = "custom"
+	DataDirModePortable = "portable"
+	DataDirModeEnv      = "env"
+)
+
+// DataDirInfo 当前数据目录及可选的便携目录
+type DataDirInfo struct {
+	Mode        string `json:"mode"`
+	Dir         string `json:"dir"`
+	SettingsDir string `json:"settings_dir"`
+	DefaultDir  string `json:"default_dir"`
+	PortableDir string `json:"portable_dir,omitempty"`
+	// 已设置新目录，数据将在下次启动时迁移
+	PendingDir     string `json:"pending_dir,omitempty"`
+	RestartPending bool   `json:"restart_pending"`
+}
+
+type dataDirPointer struct {
+	Dir string `json:"dir,omitempty"`
+	// 下次启动时从这两个位置把数据迁移到新目录
+	PendingFrom         string `json:"pending_from,omitempty"`
+	PendingSettingsFrom string `json:"pending_settings_from,omitempty"`
+}
+
+// resolvedDataDir 进程内只解析一次，运行期间所有服务使用同一个目录，切换目录需要重启
+var resolvedDataDir struct {
+	once sync.Once
+	mode string
+	dir  string
+}
+
+// dataDir 返回数据目录：环境变量 > 便携模式 > 自定义目录 > ~/.code-switch
+func dataDir() string {
+	resolvedDataDir.once.Do(func() {
+		resolvedDataDir.mode, resolvedDataDir.dir = resolveDataDir()
+	})
+	return resolvedDataDir.dir
+}
+
+func dataDirMode() string {
+	dataDir()
+	return resolvedDataDir.mode
+}
+
+// ensureDataDir 返回数据目录并确保其存在
+func ensureDataDir() (string, error) {
+	dir := dataDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// appSettingsPath 默认模式下沿用 ~/.codex-switch/app.json，迁移后与其他数据放在一起
+func appSettingsPath() string {
+	if dataDirMode() == DataDirModeDefault {
+		return filepath.Join(userHomeDir(), appSettingsDir, appSettingsFile)
+	}
+	return filepath.Join(dataDir(), appSettingsFile)
+}
+
+func resolveDataDir() (string, string) {
+	if dir := strings.TrimSpace(os.Getenv(dataDirEnv)); dir != "" {
+		return DataDirModeEnv, dir
+	}
+	if portable := portableDataPath(); portable != "" {
+		if _, err := os.Stat(filepath.Join(filepath.Dir(portable), portableMarkerFile)); err == nil {
+			return DataDirModePortable, portable
+		}
+	}
+	if pointer, err := loadDataDirPointer(); err == nil && pointer.Dir != "" {
+		return DataDirModeCustom, pointer.Dir
+	}
+	return DataDirModeDefault, defaultDataDir()
+}
+
+func userHomeDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "."
+	}
+	return home
+}
+
+func defaultDataDir() string {
+	return filepath.Join(userHomeDir(), defaultDataDirName)
+}
+
+// portableDataPath 可执行文件旁的 data 目录；macOS 上放在 .app 包所在目录
+func portableDataPath() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	dir := filepath.Dir(exe)
+	if runtime.GOOS == "darwin" {
+		if i := strings.Index(dir, ".app/Contents/MacOS"); i >= 0 {
+			dir = filepath.Dir(dir[:i+len(".app")])
+		}
+	}
+	return filepath.Join(dir, portableDataDir)
+}
+
+func dataDirPointerPath() string {
+	return filepath.Join(userHomeDir(), dataDirPointerFile)
+}
+
+func loadDataDirPointer() (dataDirPointer, error) {
+	var pointer dataDirPointer
+	data, err := os.ReadFile(dataDirPointerPath())
+	if err != nil {
+		return pointer, err
+	}
+	err = json.Unmarshal(data, &pointer)
+	return pointer, err
+}
+
+func saveDataDirPointer(pointer dataDirPointer) error {
+	if pointer == (dataDirPointer{}) {
+		if err := os.Remove(dataDirPointerPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(pointer, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dataDirPointerPath(), data, 0o644)
+}
+
+type DataDirService struct {
+	mu sync.Mutex
+}
+
+func NewDataDirService() *DataDirService {
+	return &DataDirService{}
+}
+
+// GetDataDirInfo 返回当前数据目录信息
+func (ds *DataDirService) GetDataDirInfo() DataDirInfo {
+	info := DataDirInfo{
+		Mode:        dataDirMode(),
+		Dir:         dataDir(),
+		SettingsDir: filepath.Dir(appSettingsPath()),
+		DefaultDir:  defaultDataDir(),
+		PortableDir: portableDataPath(),
+	}
+	if pending := pendingDataDir(); pending != "" && pending != info.Dir {
+		info.PendingDir = pending
+		info.RestartPending = true
+	}
+	return info
+}
+
+// SetDataDir 切换数据目录（default、custom 或 portable），数据在下次启动、任何服务打开文件之前迁移
+func (ds *DataDirService) SetDataDir(mode, dir string) (DataDirInfo, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if dataDirMode() == DataDirModeEnv {
+		return ds.GetDataDirInfo(), fmt.Errorf("数据目录由环境变量 %s 指定，无法在应用内修改", dataDirEnv)
+	}
+	pointer, _ := loadDataDirPointer()
+	var target string
+	switch mode {
+	case DataDirModeDefault:
+		target = defaultDataDir()
+		pointer.Dir = ""
+	case DataDirModeCustom:
+		dir = strings.TrimSpace(dir)
+		if dir == "" || !filepath.IsAbs(dir) {
+			return ds.GetDataDirInfo(), errors.New("请选择一个绝对路径作为数据目录")
+		}
+		target = filepath.Clean(dir)
+		pointer.Dir = target
+	case DataDirModePortable:
+		target = portableDataPath()
+		if target == "" {
+			return ds.GetDataDirInfo(), errors.New("无法确定可执行文件所在目录")
+		}
+	default:
+		return ds.GetDataDirInfo(), fmt.Errorf("未知的数据目录模式: %s", mode)
+	}
+
+	current := dataDir()
+	if err := validateDataDirTarget(current, target); err != nil {
+		return ds.GetDataDirInfo(), err
+	}
+	// 便携模式由可执行文件旁的标记文件决定，离开便携模式时删除标记
+	marker := filepath.Join(filepath.Dir(portableDataPath()), portableMarkerFile)
+	if mode == DataDirModePortable {
+		if err := os.WriteFile(marker, []byte("code-switch portable mode\n"), 0o644); err != nil {
+			return ds.GetDataDirInfo(), fmt.Errorf("可执行文件所在目录不可写，无法启用便携模式: %w", err)
+		}
+	} else if err := os.Remove(marker); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return ds.GetDataDirInfo(), fmt.Errorf("删除便携模式标记失败: %w", err)
+	}
+
+	if target != current {
+		pointer.PendingFrom = current
+		pointer.PendingSettingsFrom = appSettingsPath()
+	} else {
+		pointer.PendingFrom = ""
+		pointer.PendingSettingsFrom = ""
+	}
+	if err := saveDataDirPointer(pointer); err != nil {
+		return ds.GetDataDirInfo(), err
+	}
+	return ds.GetDataDirInfo(), nil
+}
+
+// pendingDataDir 下次启动将使用的数据目录
+func pendingDataDir() string {
+	_, dir := resolveDataDir()
+	return dir
+}
+
+func validateDataDirTarget(current, target string) error {
+	if target == current {
+		return nil
+	}
+	if isSubPath(current, target) || isSubPath(target, current) {
+		return errors.New("新数据目录不能位于当前数据目录内，也不能包含当前数据目录")
+	}
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		return fmt.Errorf("无法创建数据目录: %w", err)
+	}
+	probe, err := os.CreateTemp(target, ".write-test-*")
+	if err != nil {
+		return fmt.Errorf("数据目录不可写: %w", err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
+
+func isSubPath(parent, child string) bool {
+	rel, err := filepath.Rel(parent, child)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// ApplyPendingDataDirMigration 在任何服务打开数据文件之前把旧目录中的数据移动到新目录，
+// 目标中已存在的同名文件不会被覆盖
+func ApplyPendingDataDirMigration() error {
+	pointer, err := loadDataDirPointer()
+	if err != nil || pointer.PendingFrom == "" {
+		return nil
+	}
+	target := dataDir()
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		return fmt.Errorf("创建数据目录失败: %w", err)
+	}
+	var failed []string
+	if pointer.PendingFrom != target {
+		entries, err := os.ReadDir(pointer.PendingFrom)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("读取旧数据目录失败: %w", err)
+		}
+		for _, entry := range entries {
+			if err := movePath(filepath.Join(pointer.PendingFrom, entry.Name()), filepath.Join(target, entry.Name())); err != nil {
+				failed = append(failed, fmt.Sprintf("%s: %v", entry.Name(), err))
+			}
+		}
+		_ = os.Remove(pointer.PendingFrom)
+	}
+	if settings := appSettingsPath(); pointer.PendingSettingsFrom != "" && pointer.PendingSettingsFrom != settings {
+		if err := movePath(pointer.PendingSettingsFrom, settings); err != nil && !errors.Is(err, os.ErrNotExist) {
+			failed = append(failed, fmt.Sprintf("%s: %v", appSettingsFile, err))
+		}
+		_ = os.Remove(filepath.Dir(pointer.PendingSettingsFrom))
+	}
+
+	fmt.Printf("[INFO] 数据已从 %s 迁移到 %s\n", pointer.PendingFrom, target)
+	pointer.PendingFrom = ""
+	pointer.PendingSettingsFrom = ""
+	if err := saveDataDirPointer(pointer); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("部分数据迁移失败: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// movePath 优先重命名，跨磁盘时复制后删除源文件
+func movePath(src, dst string) error {
+	if _, err := os.Lstat(dst); err == nil {
+		return errors.New("目标已存在")
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	if err := copyPath(src, dst); err != nil {
+		_ = os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+func copyPath(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		if err := os.MkdirAll(dst, info.Mode().Perm()); err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := copyPath(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name())); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
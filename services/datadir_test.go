@@ -0,0 +1,53 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsSubPath(t *testing.T) {
+	cases := []struct {
+		parent, child string
+		want          bool
+	}{
+		{"/data/code-switch", "/data/code-switch/sub", true},
+		{"/data/code-switch", "/data/code-switch", false},
+		{"/data/code-switch", "/data/code-switch-2", false},
+		{"/data/code-switch", "/data", false},
+	}
+	for _, tc := range cases {
+		if got := isSubPath(tc.parent, tc.child); got != tc.want {
+			t.Errorf("isSubPath(%q, %q) = %v, want %v", tc.parent, tc.child, got, tc.want)
+		}
+	}
+}
+
+func TestMovePathKeepsExistingTarget(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "old", "skills")
+	if err := os.MkdirAll(src, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "skill.json"), []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(root, "new", "skills")
+	if err := movePath(src, dst); err != nil {
+		t.Fatalf("movePath: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "skill.json")); err != nil {
+		t.Fatalf("moved file missing: %v", err)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Fatalf("source should be removed, stat err = %v", err)
+	}
+
+	other := filepath.Join(root, "other")
+	if err := os.MkdirAll(other, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := movePath(other, dst); err == nil {
+		t.Fatal("movePath should not overwrite an existing target")
+	}
+}
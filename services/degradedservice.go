@@ -0,0 +1,265 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daodao97/xgo/xdb"
+)
+
+const degradedEvalInterval = time.Minute
+
+// DegradedPolicy 降级判定：窗口内延迟或错误率超过阈值但仍可用的 provider 降低优先级，仅作为兜底
+type DegradedPolicy struct {
+	Enabled bool `json:"enabled"`
+	// 统计窗口（分钟）
+	WindowMinutes int `json:"window_minutes"`
+	// 窗口内请求数不足时不做判定
+	MinRequests int `json:"min_requests"`
+	// 成功请求 p95 耗时超过该值（秒）视为降级，0 表示不按延迟判定
+	LatencyP95Sec float64 `json:"latency_p95_sec"`
+	// 错误率超过该百分比视为降级，0 表示不按错误率判定
+	ErrorRatePercent float64 `json:"error_rate_percent"`
+}
+
+type DegradedProvider struct {
+	Platform  string    `json:"platform"`
+	Provider  string    `json:"provider"`
+	Requests  int       `json:"requests"`
+	ErrorRate float64   `json:"error_rate"`
+	P95Sec    float64   `json:"p95_sec"`
+	Reason    string    `json:"reason"`
+	Since     time.Time `json:"since"`
+}
+
+// degradedProviders 由 DegradedService 维护、relay 读取
+var degradedProviders = struct {
+	sync.RWMutex
+	names map[string]map[string]struct{}
+}{names: make(map[string]map[string]struct{})}
+
+type DegradedService struct {
+	appSettings *AppSettingsService
+	mu          sync.Mutex
+	degraded    map[string]DegradedProvider
+	stopCh      chan struct{}
+}
+
+func NewDegradedService(appSettings *AppSettingsService) *DegradedService {
+	return &DegradedService{
+		appSettings: appSettings,
+		degraded:    make(map[string]DegradedProvider),
+	}
+}
+
+func defaultDegradedPolicy() DegradedPolicy {
+	return DegradedPolicy{
+		Enabled:          true,
+		WindowMinutes:    15,
+		MinRequests:      5,
+		LatencyP95Sec:    60,
+		ErrorRatePercent: 20,
+	}
+}
+
+func normalizeDegradedPolicy(policy DegradedPolicy) DegradedPolicy {
+	defaults := defaultDegradedPolicy()
+	if policy.WindowMinutes <= 0 {
+		policy.WindowMinutes = defaults.WindowMinutes
+	}
+	if policy.MinRequests <= 0 {
+		policy.MinRequests = defaults.MinRequests
+	}
+	if policy.LatencyP95Sec < 0 {
+		policy.LatencyP95Sec = 0
+	}
+	if policy.ErrorRatePercent < 0 {
+		policy.ErrorRatePercent = 0
+	}
+	return policy
+}
+
+// Start 启动定时降级判定
+func (ds *DegradedService) Start() error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	if ds.stopCh != nil {
+		return nil
+	}
+	stopCh := make(chan struct{})
+	ds.stopCh = stopCh
+	go func() {
+		ds.evaluate(time.Now())
+		ticker := time.NewTicker(degradedEvalInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ds.evaluate(time.Now())
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (ds *DegradedService) Stop() error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	if ds.stopCh != nil {
+		close(ds.stopCh)
+		ds.stopCh = nil
+	}
+	return nil
+}
+
+// ListDegraded 返回当前处于降级状态的 provider
+func (ds *DegradedService) ListDegraded(platform string) []DegradedProvider {
+	platform = normalizePresetKind(platform)
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	result := make([]DegradedProvider, 0, len(ds.degraded))
+	for _, item := range ds.degraded {
+		if platform != "" && item.Platform != platform {
+			continue
+		}
+		result = append(result, item)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Platform != result[j].Platform {
+			return result[i].Platform < result[j].Platform
+		}
+		return result[i].Provider < result[j].Provider
+	})
+	return result
+}
+
+// GetPolicy 返回当前生效的降级判定配置
+func (ds *DegradedService) GetPolicy() DegradedPolicy {
+	return ds.policy()
+}
+
+func (ds *DegradedService) policy() DegradedPolicy {
+	if ds.appSettings == nil {
+		return defaultDegradedPolicy()
+	}
+	settings, err := ds.appSettings.GetAppSettings()
+	if err != nil {
+		return defaultDegradedPolicy()
+	}
+	return normalizeDegradedPolicy(settings.Degraded)
+}
+
+// evaluate 按窗口内的真实请求重新判定各 provider 的降级状态
+func (ds *DegradedService) evaluate(now time.Time) {
+	policy := ds.policy()
+	current := make(map[string]DegradedProvider)
+	if policy.Enabled {
+		records, err := xdb.New(activeRequestLogTable()).Selects(
+			xdb.WhereGte("created_at", now.Add(-time.Duration(policy.WindowMinutes)*time.Minute).UTC().Format(timeLayout)),
+			xdb.Field("platform", "provider", "http_code", "duration_sec"),
+		)
+		if err != nil && !errors.Is(err, xdb.ErrNotFound) && !isNoSuchTableErr(err) {
+			fmt.Printf("降级判定读取 request_log 失败: %v\n", err)
+			return
+		}
+		samples := make(map[string]*degradedSamples)
+		for _, record := range records {
+			key := blacklistKey(record.GetString("platform"), record.GetString("provider"))
+			sample, ok := samples[key]
+			if !ok {
+				sample = &degradedSamples{}
+				samples[key] = sample
+			}
+			code := record.GetInt("http_code")
+			if code >= 200 && code < 300 {
+				sample.durations = append(sample.durations, record.GetFloat64("duration_sec"))
+			} else {
+				sample.failures++
+			}
+		}
+		for key, sample := range samples {
+			if item, ok := judgeDegraded(policy, sample); ok {
+				item.Platform, item.Provider, _ = strings.Cut(key, ":")
+				current[key] = item
+			}
+		}
+	}
+
+	names := make(map[string]map[string]struct{})
+	ds.mu.Lock()
+	for key, item := range current {
+		if previous, ok := ds.degraded[key]; ok {
+			item.Since = previous.Since
+		} else {
+			item.Since = now
+		}
+		current[key] = item
+		if names[item.Platform] == nil {
+			names[item.Platform] = make(map[string]struct{})
+		}
+		names[item.Platform][strings.ToLower(item.Provider)] = struct{}{}
+	}
+	ds.degraded = current
+	ds.mu.Unlock()
+
+	degradedProviders.Lock()
+	degradedProviders.names = names
+	degradedProviders.Unlock()
+}
+
+type degradedSamples struct {
+	durations []float64
+	failures  int
+}
+
+// judgeDegraded 根据阈值判断是否降级；全部失败的情况交给拉黑机制处理
+func judgeDegraded(policy DegradedPolicy, sample *degradedSamples) (DegradedProvider, bool) {
+	total := len(sample.durations) + sample.failures
+	if total < policy.MinRequests || len(sample.durations) == 0 {
+		return DegradedProvider{}, false
+	}
+	sort.Float64s(sample.durations)
+	item := DegradedProvider{
+		Requests:  total,
+		ErrorRate: float64(sample.failures) * 100 / float64(total),
+		P95Sec:    percentile(sample.durations, 95),
+	}
+	reasons := make([]string, 0, 2)
+	if policy.LatencyP95Sec > 0 && item.P95Sec > policy.LatencyP95Sec {
+		reasons = append(reasons, fmt.Sprintf("p95 耗时 %.1fs 超过 %.1fs", item.P95Sec, policy.LatencyP95Sec))
+	}
+	if policy.ErrorRatePercent > 0 && item.ErrorRate > policy.ErrorRatePercent {
+		reasons = append(reasons, fmt.Sprintf("错误率 %.0f%% 超过 %.0f%%", item.ErrorRate, policy.ErrorRatePercent))
+	}
+	if len(reasons) == 0 {
+		return DegradedProvider{}, false
+	}
+	item.Reason = strings.Join(reasons, "，")
+	return item, true
+}
+
+// deprioritizeDegraded 把降级的 provider 移到队尾，仍保留为兜底
+func deprioritizeDegraded(kind string, providers []Provider) []Provider {
+	degradedProviders.RLock()
+	degraded := degradedProviders.names[normalizePresetKind(kind)]
+	degradedProviders.RUnlock()
+	if len(degraded) == 0 {
+		return providers
+	}
+	ordered := make([]Provider, 0, len(providers))
+	tail := make([]Provider, 0)
+	for _, provider := range providers {
+		if _, ok := degraded[strings.ToLower(provider.Name)]; ok {
+			tail = append(tail, provider)
+			continue
+		}
+		ordered = append(ordered, provider)
+	}
+	return append(ordered, tail...)
+}
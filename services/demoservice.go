@@ -0,0 +1,319 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/daodao97/xgo/xdb"
+)
+
+const (
+	demoRequestLogTable = "demo_request_log"
+	demoSpeedTestTable  = "demo_speed_test_result"
+	demoProviderPrefix  = "[Demo] "
+	demoSeedDays        = 30
+)
+
+// demoMode 为 true 时，界面展示演示 provider，日志、统计与可用性历史读取演示数据表，真实数据不受影响
+var demoMode atomic.Bool
+
+func requestLogTable() string {
+	if demoMode.Load() {
+		return demoRequestLogTable
+	}
+	return activeRequestLogTable()
+}
+
+// speedTestTable 测速结果表：演示模式下测速、可用性历史与路由建议都使用演示表，不与真实样本混在一起
+func speedTestTable() string {
+	if demoMode.Load() {
+		return demoSpeedTestTable
+	}
+	return speedTestResultTable
+}
+
+func isDemoMode() bool {
+	return demoMode.Load()
+}
+
+type DemoStatus struct {
+	Enabled bool   `json:"enabled"`
+	Label   string `json:"label"`
+	Rows    int64  `json:"rows"`
+}
+
+type DemoService struct {
+	appSettings *AppSettingsService
+}
+
+func NewDemoService(appSettings *AppSettingsService) *DemoService {
+	ds := &DemoService{appSettings: appSettings}
+	if appSettings != nil {
+		if settings, err := appSettings.GetAppSettings(); err == nil && settings.DemoMode {
+			demoMode.Store(true)
+		}
+	}
+	return ds
+}
+
+func (ds *DemoService) Status() (DemoStatus, error) {
+	status := DemoStatus{Enabled: isDemoMode()}
+	if status.Enabled {
+		status.Label = "演示数据"
+	}
+	count, err := xdb.New(demoRequestLogTable).Count()
+	if err != nil && !isNoSuchTableErr(err) {
+		return status, err
+	}
+	status.Rows = count
+	return status, nil
+}
+
+// EnableDemo 生成演示数据（已存在则复用）并切换日志读取到演示表
+func (ds *DemoService) EnableDemo() (DemoStatus, error) {
+	db, err := xdb.DB("default")
+	if err != nil {
+		return DemoStatus{}, err
+	}
+	if err := ensureLogTableSchema(db, demoRequestLogTable); err != nil {
+		return DemoStatus{}, err
+	}
+	if err := ensureSpeedTestTableWithDB(db, demoSpeedTestTable); err != nil {
+		return DemoStatus{}, err
+	}
+	count, err := xdb.New(demoRequestLogTable).Count()
+	if err != nil {
+		return DemoStatus{}, err
+	}
+	if count == 0 {
+		if err := seedDemoRequestLogs(demoSeedDays); err != nil {
+			return DemoStatus{}, err
+		}
+	}
+	if count, err = xdb.New(demoSpeedTestTable).Count(); err != nil {
+		return DemoStatus{}, err
+	}
+	if count == 0 {
+		if err := seedDemoHealthChecks(db, demoSeedDays); err != nil {
+			return DemoStatus{}, err
+		}
+	}
+	if err := ds.persist(true); err != nil {
+		return DemoStatus{}, err
+	}
+	demoMode.Store(true)
+	return ds.Status()
+}
+
+func (ds *DemoService) DisableDemo() (DemoStatus, error) {
+	if err := ds.persist(false); err != nil {
+		return DemoStatus{}, err
+	}
+	demoMode.Store(false)
+	return ds.Status()
+}
+
+// RegenerateDemo 清空并重新生成演示数据
+func (ds *DemoService) RegenerateDemo() (DemoStatus, error) {
+	for _, table := range []string{demoRequestLogTable, demoSpeedTestTable} {
+		if _, err := xdb.New(table).Delete(xdb.WhereGt("id", 0)); err != nil && !isNoSuchTableErr(err) {
+			return DemoStatus{}, err
+		}
+	}
+	return ds.EnableDemo()
+}
+
+// DemoProviders 返回演示用的 provider 列表，名称带有 [Demo] 前缀
+func (ds *DemoService) DemoProviders(kind string) []Provider {
+	return demoProviders(kind)
+}
+
+func demoProviders(kind string) []Provider {
+	kind = normalizePresetKind(kind)
+	accent, tint := defaultVisual(kind)
+	names := demoProviderNames[kind]
+	providers := make([]Provider, 0, len(names))
+	for i, name := range names {
+		providers = append(providers, Provider{
+			ID:      9000 + i,
+			Name:    demoProviderPrefix + name,
+			APIURL:  fmt.Sprintf("https://%s.example.com", name),
+			Site:    "https://example.com",
+			Icon:    "aicoding",
+			Tint:    tint,
+			Accent:  accent,
+			Enabled: true,
+			Level:   i + 1,
+		})
+	}
+	return providers
+}
+
+func (ds *DemoService) persist(enabled bool) error {
+	if ds.appSettings == nil {
+		return nil
+	}
+	_, err := ds.appSettings.update(func(settings *AppSettings) {
+		settings.DemoMode = enabled
+	})
+	return err
+}
+
+var (
+	demoProviderNames = map[string][]string{
+		"claude": {"alpha", "bravo", "charlie"},
+		"codex":  {"delta", "echo"},
+	}
+	demoModels = map[string][]string{
+		"claude": {"claude-sonnet-4-5-20250929", "claude-opus-4-1-20250805", "claude-haiku-4-5-20251001"},
+		"codex":  {"gpt-5-codex", "gpt-5"},
+	}
+	// demoSuccessRates 各演示 provider 健康检查的成功率，charlie 用于展示不稳定的 provider
+	demoSuccessRates = map[string]float64{"alpha": 0.99, "bravo": 0.97, "charlie": 0.82, "delta": 0.98, "echo": 0.93}
+	demoHttpCodes    = []int{200, 200, 200, 200, 200, 200, 429, 500}
+	demoHourWeight   = []float64{
+		0.1, 0.05, 0.05, 0.05, 0.05, 0.1, 0.2, 0.4, 0.8, 1.2, 1.3, 1.2,
+		0.9, 1.1, 1.3, 1.3, 1.2, 1.0, 0.7, 0.6, 0.7, 0.6, 0.4, 0.2,
+	}
+)
+
+// seedDemoRequestLogs 按工作日/时段的使用规律生成最近 N 天的模拟请求
+func seedDemoRequestLogs(days int) error {
+	model := xdb.New(demoRequestLogTable)
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	today := startOfDay(time.Now())
+	for dayOffset := 0; dayOffset < days; dayOffset++ {
+		day := today.AddDate(0, 0, -dayOffset)
+		dayFactor := 1.0
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			dayFactor = 0.4
+		}
+		for hour, weight := range demoHourWeight {
+			if dayOffset == 0 && hour > time.Now().Hour() {
+				break
+			}
+			count := int(weight * dayFactor * (2 + rng.Float64()*4))
+			for i := 0; i < count; i++ {
+				platform := "claude"
+				if rng.Intn(100) < 30 {
+					platform = "codex"
+				}
+				names := demoProviderNames[platform]
+				models := demoModels[platform]
+				input := 500 + rng.Intn(8000)
+				output := 100 + rng.Intn(3000)
+				isStream := 0
+				if rng.Intn(100) < 70 {
+					isStream = 1
+				}
+				timestamp := day.Add(time.Duration(hour)*time.Hour + time.Duration(rng.Intn(3600))*time.Second)
+				if _, err := model.Insert(xdb.Record{
+					"platform":            platform,
+					"model":               models[rng.Intn(len(models))],
+					"provider":            demoProviderPrefix + names[rng.Intn(len(names))],
+					"http_code":           demoHttpCodes[rng.Intn(len(demoHttpCodes))],
+					"input_tokens":        input,
+					"output_tokens":       output,
+					"cache_create_tokens": int(float64(input) * float64(rng.Intn(20)) / 100),
+					"cache_read_tokens":   int(float64(input) * float64(rng.Intn(60)) / 100),
+					"reasoning_tokens":    rng.Intn(400),
+					"is_stream":           isStream,
+					"duration_sec":        0.5 + rng.Float64()*20,
+					"created_at":          timestamp.UTC().Format(timeLayout),
+				}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// seedDemoHealthChecks 为演示 provider 生成最近 N 天每小时一次的健康检查结果，在一个事务内写入
+func seedDemoHealthChecks(db *sql.DB, days int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(`INSERT INTO ` + demoSpeedTestTable + ` (platform, provider, latency_ms, http_code, success, created_at) VALUES (?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	now := time.Now()
+	start := startOfDay(now).AddDate(0, 0, -(days - 1))
+	for platform, names := range demoProviderNames {
+		for i, name := range names {
+			baseLatency := 180 + float64(i)*90
+			for at := start; at.Before(now); at = at.Add(time.Hour) {
+				success, code := 1, 200
+				latency := baseLatency * (0.7 + rng.Float64()*0.8)
+				if rng.Float64() > demoSuccessRates[name] {
+					success, code = 0, []int{429, 500, 503}[rng.Intn(3)]
+					latency = 0
+				}
+				if _, err := stmt.Exec(platform, demoProviderPrefix+name, latency, code, success, at.UTC().Format(timeLayout)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// demoProviderHealth 用演示健康检查的最近结果生成 provider 健康状态
+func demoProviderHealth(platform string) []ProviderHealth {
+	platform = normalizePresetKind(platform)
+	result := make([]ProviderHealth, 0)
+	for kind, names := range demoProviderNames {
+		if platform != "" && kind != platform {
+			continue
+		}
+		for _, name := range names {
+			records, err := xdb.New(demoSpeedTestTable).Selects(
+				xdb.WhereEq("platform", kind),
+				xdb.WhereEq("provider", demoProviderPrefix+name),
+				xdb.OrderByDesc("id"),
+				xdb.Limit(10),
+			)
+			if err != nil || len(records) == 0 {
+				continue
+			}
+			latest := records[0]
+			health := ProviderHealth{
+				Platform:  kind,
+				Provider:  demoProviderPrefix + name,
+				Healthy:   latest.GetBool("success"),
+				LatencyMs: latest.GetFloat64("latency_ms"),
+				HttpCode:  latest.GetInt("http_code"),
+			}
+			if lastCheck, ok := parseCreatedAt(latest); ok {
+				health.LastCheck = lastCheck
+				health.NextCheck = lastCheck.Add(time.Hour)
+			}
+			for _, record := range records {
+				if record.GetBool("success") {
+					break
+				}
+				health.ConsecutiveFailures++
+			}
+			if !health.Healthy {
+				health.Error = fmt.Sprintf("HTTP %d", health.HttpCode)
+			}
+			result = append(result, health)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Platform != result[j].Platform {
+			return result[i].Platform < result[j].Platform
+		}
+		return result[i].Provider < result[j].Provider
+	})
+	return result
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDemoModeProvidersAndHealth(t *testing.T) {
+	useTestDB(t)
+	ds := NewDemoService(nil)
+	if _, err := ds.EnableDemo(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ds.DisableDemo() })
+
+	ps := NewProviderService()
+	providers, err := ps.ListProviders("claude")
+	if err != nil || len(providers) != 3 || !strings.HasPrefix(providers[0].Name, demoProviderPrefix) {
+		t.Fatalf("演示模式应展示演示 provider：%+v %v", providers, err)
+	}
+	if err := ps.SaveProviders("claude", providers); err == nil {
+		t.Fatal("演示模式下不应覆盖真实 provider 配置")
+	}
+
+	sts := NewSpeedTestService(ps)
+	points, err := sts.AvailabilityHistory("claude", providers[0].Name, 7)
+	if err != nil || len(points) != 7 || points[6].Samples == 0 {
+		t.Fatalf("演示 provider 应有可用性历史：%+v %v", points, err)
+	}
+	health := NewHealthCheckService(ps, nil, nil, nil, nil).ListHealth("codex")
+	if len(health) != 2 || health[0].Provider != demoProviderPrefix+"delta" || health[0].LastCheck.IsZero() {
+		t.Fatalf("演示健康状态：%+v", health)
+	}
+
+	if _, err := ds.DisableDemo(); err != nil {
+		t.Fatal(err)
+	}
+	if points, err := sts.AvailabilityHistory("claude", providers[0].Name, 7); err != nil || points[6].Samples != 0 {
+		t.Fatalf("关闭演示后不应读取演示数据：%+v %v", points, err)
+	}
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+)
+
+const directApplyStateFileName = "cc-studio.direct.json"
+
+// directApplyState 记录直连应用时写入的键及其原值，移除时据此恢复
+type directApplyState struct {
+	Provider string         `json:"provider"`
+	Keys     []string       `json:"keys"`
+	Previous map[string]any `json:"previous,omitempty"`
+}
+
+// DirectApplyStatus 当前直连应用的 provider
+type DirectApplyStatus struct {
+	Applied  bool   `json:"applied"`
+	Provider string `json:"provider"`
+}
+
+// mergeDirectValues 把 values 合并进 target，并记录被覆盖键的原值
+func mergeDirectValues(target map[string]any, values map[string]string, providerName string) directApplyState {
+	state := directApplyState{Provider: providerName, Previous: make(map[string]any)}
+	for key, value := range values {
+		if old, ok := target[key]; ok {
+			state.Previous[key] = old
+		}
+		target[key] = value
+		state.Keys = append(state.Keys, key)
+	}
+	sort.Strings(state.Keys)
+	return state
+}
+
+// stripDirectValues 删除直连写入的键，原先存在的值恢复原状
+func stripDirectValues(target map[string]any, state directApplyState) {
+	for _, key := range state.Keys {
+		if old, ok := state.Previous[key]; ok {
+			target[key] = old
+			continue
+		}
+		delete(target, key)
+	}
+}
+
+// providerExtraEnv 返回 provider 的自定义环境变量，reserved 中的键由调用方管理，不允许覆盖
+func providerExtraEnv(provider Provider, reserved ...string) map[string]string {
+	values := make(map[string]string, len(provider.Env))
+	for key, value := range provider.Env {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		values[key] = value
+	}
+	for _, key := range reserved {
+		delete(values, key)
+	}
+	return values
+}
+
+func loadDirectApplyState(path string) (*directApplyState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var state directApplyState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func saveDirectApplyState(path string, state directApplyState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
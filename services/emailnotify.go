@@ -0,0 +1,338 @@
+package services
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const emailDialTimeout = 15 * time.Second
+
+// EmailSettings SMTP 邮件通知配置
+type EmailSettings struct {
+	Enabled  bool   `json:"enabled"`
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// 发件人，为空时使用 Username
+	From string   `json:"from"`
+	To   []string `json:"to"`
+	// 每日用量摘要，在本地时间 DigestHour 点发送前一天的用量
+	DailyDigest bool `json:"daily_digest"`
+	DigestHour  int  `json:"digest_hour"`
+	// provider 持续不可用（拉黑）超过该分钟数时发邮件，0 表示不发送
+	ProviderDownMinutes int `json:"provider_down_minutes"`
+	// 预算超限时发邮件
+	BudgetExceeded bool `json:"budget_exceeded"`
+	// 最近一次发送摘要的日期
+	LastDigestDate string `json:"last_digest_date,omitempty"`
+}
+
+func defaultEmailSettings() EmailSettings {
+	return EmailSettings{
+		Port:                587,
+		To:                  []string{},
+		DigestHour:          9,
+		ProviderDownMinutes: 10,
+		BudgetExceeded:      true,
+	}
+}
+
+func normalizeEmailSettings(settings EmailSettings) EmailSettings {
+	defaults := defaultEmailSettings()
+	settings.Host = strings.TrimSpace(settings.Host)
+	settings.Username = strings.TrimSpace(settings.Username)
+	settings.From = strings.TrimSpace(settings.From)
+	if settings.Port <= 0 {
+		settings.Port = defaults.Port
+	}
+	if settings.DigestHour < 0 || settings.DigestHour > 23 {
+		settings.DigestHour = defaults.DigestHour
+	}
+	if settings.ProviderDownMinutes < 0 {
+		settings.ProviderDownMinutes = 0
+	}
+	recipients := make([]string, 0, len(settings.To))
+	for _, address := range settings.To {
+		if address = strings.TrimSpace(address); address != "" {
+			recipients = append(recipients, address)
+		}
+	}
+	settings.To = recipients
+	return settings
+}
+
+func validateEmailSettings(settings EmailSettings) error {
+	if settings.Host == "" {
+		return errors.New("请填写 SMTP 服务器地址")
+	}
+	if emailSender(settings) == "" {
+		return errors.New("请填写发件人地址")
+	}
+	if len(settings.To) == 0 {
+		return errors.New("请至少填写一个收件人")
+	}
+	return nil
+}
+
+// GetEmailSettings 返回邮件通知配置
+func (ns *NotificationService) GetEmailSettings() (EmailSettings, error) {
+	settings, err := ns.appSettings.GetAppSettings()
+	if err != nil {
+		return EmailSettings{}, err
+	}
+	return normalizeEmailSettings(settings.Email), nil
+}
+
+// SaveEmailSettings 保存邮件通知配置
+func (ns *NotificationService) SaveEmailSettings(email EmailSettings) (EmailSettings, error) {
+	email = normalizeEmailSettings(email)
+	if email.Enabled {
+		if err := validateEmailSettings(email); err != nil {
+			return email, err
+		}
+	}
+	settings, err := ns.appSettings.update(func(settings *AppSettings) {
+		email.LastDigestDate = settings.Email.LastDigestDate
+		settings.Email = email
+	})
+	if err != nil {
+		return email, err
+	}
+	return normalizeEmailSettings(settings.Email), nil
+}
+
+// SendTestEmail 使用传入的配置发送一封测试邮件（配置无需先保存）
+func (ns *NotificationService) SendTestEmail(email EmailSettings) error {
+	email = normalizeEmailSettings(email)
+	if err := validateEmailSettings(email); err != nil {
+		return err
+	}
+	return sendEmail(email, "测试邮件", "邮件通知配置成功。")
+}
+
+// sendEmailNotification 邮件通知已开启时发送，失败只打印日志
+func (ns *NotificationService) sendEmailNotification(subject, body string) {
+	email, err := ns.GetEmailSettings()
+	if err != nil || !email.Enabled || validateEmailSettings(email) != nil {
+		return
+	}
+	if err := sendEmail(email, subject, body); err != nil {
+		fmt.Printf("[WARN] 邮件通知发送失败: %v\n", err)
+	}
+}
+
+// checkProvidersDown 记录各 provider 持续拉黑的起始时间，超过配置的分钟数时发一次邮件
+func (ns *NotificationService) checkProvidersDown(now time.Time) {
+	if ns.blacklistService == nil {
+		return
+	}
+	email, err := ns.GetEmailSettings()
+	if err != nil || !email.Enabled || email.ProviderDownMinutes <= 0 {
+		return
+	}
+	threshold := time.Duration(email.ProviderDownMinutes) * time.Minute
+	entries := ns.blacklistService.ListBlacklist("")
+
+	ns.mu.Lock()
+	current := make(map[string]struct{}, len(entries))
+	var overdue []BlacklistEntry
+	for _, entry := range entries {
+		key := blacklistKey(entry.Platform, entry.Provider)
+		current[key] = struct{}{}
+		since, ok := ns.downSince[key]
+		if !ok {
+			ns.downSince[key] = now
+			continue
+		}
+		if _, sent := ns.downNotified[key]; !sent && now.Sub(since) >= threshold {
+			ns.downNotified[key] = struct{}{}
+			overdue = append(overdue, entry)
+		}
+	}
+	for key := range ns.downSince {
+		if _, ok := current[key]; !ok {
+			delete(ns.downSince, key)
+			delete(ns.downNotified, key)
+		}
+	}
+	ns.mu.Unlock()
+
+	for _, entry := range overdue {
+		notification := Notification{
+			Event:     NotificationBlacklist,
+			Title:     fmt.Sprintf("[%s] %s 已不可用超过 %d 分钟", entry.Platform, entry.Provider, email.ProviderDownMinutes),
+			Message:   fmt.Sprintf("Provider：%s\n平台：%s\n原因：%s\n预计恢复：%s\n", entry.Provider, entry.Platform, entry.Reason, entry.Until.Format("2006-01-02 15:04:05")),
+			Severity:  SeverityCritical,
+			Platform:  entry.Platform,
+			Provider:  entry.Provider,
+			CreatedAt: now,
+		}
+		if ns.allowed(notification) {
+			ns.sendEmailNotification(notification.Title, notification.Message)
+		}
+	}
+}
+
+// sendDailyDigestIfDue 到达发送时间且当天未发送时，发送前一天的用量摘要
+func (ns *NotificationService) sendDailyDigestIfDue(now time.Time) {
+	if ns.logService == nil {
+		return
+	}
+	email, err := ns.GetEmailSettings()
+	if err != nil || !email.Enabled || !email.DailyDigest || validateEmailSettings(email) != nil {
+		return
+	}
+	today := now.Format("2006-01-02")
+	if now.Hour() < email.DigestHour || email.LastDigestDate == today {
+		return
+	}
+	end := startOfDay(now)
+	start := end.AddDate(0, 0, -1)
+	report, err := ns.logService.usageReport(start, end)
+	if err != nil {
+		fmt.Printf("[WARN] 生成用量摘要失败: %v\n", err)
+		return
+	}
+	subject := fmt.Sprintf("%s 用量摘要", start.Format("2006-01-02"))
+	if err := sendEmail(email, subject, formatUsageDigest(report)); err != nil {
+		fmt.Printf("[WARN] 用量摘要发送失败: %v\n", err)
+		return
+	}
+	if _, err := ns.appSettings.update(func(settings *AppSettings) {
+		settings.Email.LastDigestDate = today
+	}); err != nil {
+		fmt.Printf("[WARN] 保存用量摘要发送日期失败: %v\n", err)
+	}
+}
+
+// formatUsageDigest 按 provider 汇总请求数、token 与费用
+func formatUsageDigest(report UsageReport) string {
+	type providerUsage struct {
+		name     string
+		requests int64
+		errors   int64
+		tokens   int64
+		cost     float64
+	}
+	providers := make(map[string]*providerUsage)
+	for _, stat := range report.Usage {
+		name := stat.Platform + " / " + stat.Provider
+		usage := providers[name]
+		if usage == nil {
+			usage = &providerUsage{name: name}
+			providers[name] = usage
+		}
+		usage.requests += stat.Requests
+		usage.errors += stat.Errors
+		usage.tokens += stat.InputTokens + stat.OutputTokens + stat.CacheCreateTokens + stat.CacheReadTokens
+		usage.cost += stat.TotalCost
+	}
+	list := make([]*providerUsage, 0, len(providers))
+	for _, usage := range providers {
+		list = append(list, usage)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].cost != list[j].cost {
+			return list[i].cost > list[j].cost
+		}
+		return list[i].name < list[j].name
+	})
+
+	var buf bytes.Buffer
+	total, currency := displayCost(report.TotalCost)
+	fmt.Fprintf(&buf, "请求数：%d\n总费用：%s\n\n", report.Requests, formatCost(total, currency))
+	for _, usage := range list {
+		cost, currency := displayCost(usage.cost)
+		fmt.Fprintf(&buf, "%s：%d 次请求（失败 %d），%d tokens，%s\n",
+			usage.name, usage.requests, usage.errors, usage.tokens, formatCost(cost, currency))
+	}
+	if len(list) == 0 {
+		buf.WriteString("当天没有请求。\n")
+	}
+	return buf.String()
+}
+
+func emailSender(settings EmailSettings) string {
+	if settings.From != "" {
+		return settings.From
+	}
+	return settings.Username
+}
+
+// sendEmail 465 端口使用隐式 TLS，其他端口在服务器支持时自动 STARTTLS
+func sendEmail(settings EmailSettings, subject, body string) error {
+	from := emailSender(settings)
+	message := buildEmailMessage(from, settings.To, "【Code Switch】"+subject, body, time.Now())
+	addr := net.JoinHostPort(settings.Host, strconv.Itoa(settings.Port))
+
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: emailDialTimeout}
+	if settings.Port == 465 {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: settings.Host})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	_ = conn.SetDeadline(time.Now().Add(emailDialTimeout * 2))
+	client, err := smtp.NewClient(conn, settings.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok && settings.Port != 465 {
+		if err := client.StartTLS(&tls.Config{ServerName: settings.Host}); err != nil {
+			return err
+		}
+	}
+	if settings.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", settings.Username, settings.Password, settings.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, to := range settings.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(message); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func buildEmailMessage(from string, to []string, subject, body string, now time.Time) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	buf.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	return buf.Bytes()
+}
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/daodao97/xgo/xdb"
+)
+
+// 错误分类
+const (
+	ErrorCategoryAuth       = "auth"
+	ErrorCategoryQuota      = "quota"
+	ErrorCategoryOverloaded = "overloaded"
+	ErrorCategoryTimeout    = "timeout"
+	ErrorCategoryMalformed  = "malformed_response"
+	ErrorCategoryClient     = "client"
+	ErrorCategoryOther      = "other"
+)
+
+const topErrorReasonLimit = 10
+
+var (
+	errorReasonNumberPattern = regexp.MustCompile(`[\w\-]*\d[\w\-]*`)
+	errorReasonSpacePattern  = regexp.MustCompile(`\s+`)
+)
+
+// ErrorReason 归一化后的错误原因及出现次数
+type ErrorReason struct {
+	Category string `json:"category"`
+	HttpCode int    `json:"http_code"`
+	Message  string `json:"message"`
+	Count    int64  `json:"count"`
+}
+
+// ErrorDay 某天各分类的错误次数
+type ErrorDay struct {
+	Day        string           `json:"day"`
+	Requests   int64            `json:"requests"`
+	Categories map[string]int64 `json:"categories"`
+}
+
+// ProviderErrorBreakdown 单个 provider 的错误统计
+type ProviderErrorBreakdown struct {
+	Provider   string           `json:"provider"`
+	Requests   int64            `json:"requests"`
+	Errors     int64            `json:"errors"`
+	ErrorRate  float64          `json:"error_rate"`
+	Categories map[string]int64 `json:"categories"`
+	TopReasons []ErrorReason    `json:"top_reasons"`
+	Daily      []ErrorDay       `json:"daily"`
+}
+
+// ErrorBreakdown 按 provider 汇总最近若干天的上游错误，按错误次数降序
+func (ls *LogService) ErrorBreakdown(platform string, days int) ([]ProviderErrorBreakdown, error) {
+	if days <= 0 {
+		days = 7
+	}
+	since := startOfDay(time.Now()).AddDate(0, 0, -(days - 1))
+	options := []xdb.Option{
+		xdb.WhereGte("created_at", since.UTC().Format(timeLayout)),
+		xdb.Field("provider", "http_code", "error_message", "created_at"),
+	}
+	if platform != "" {
+		options = append(options, xdb.WhereEq("platform", platform))
+	}
+	records, err := xdb.New(requestLogTable()).Selects(options...)
+	if err != nil {
+		if errors.Is(err, xdb.ErrNotFound) || isNoSuchTableErr(err) {
+			return []ProviderErrorBreakdown{}, nil
+		}
+		return nil, err
+	}
+
+	type accumulator struct {
+		breakdown *ProviderErrorBreakdown
+		reasons   map[string]*ErrorReason
+		days      map[string]*ErrorDay
+	}
+	providers := make(map[string]*accumulator)
+	for _, record := range records {
+		name := record.GetString("provider")
+		acc := providers[name]
+		if acc == nil {
+			acc = &accumulator{
+				breakdown: &ProviderErrorBreakdown{Provider: name, Categories: map[string]int64{}},
+				reasons:   map[string]*ErrorReason{},
+				days:      map[string]*ErrorDay{},
+			}
+			providers[name] = acc
+		}
+		day := ""
+		if createdAt, ok := parseCreatedAt(record); ok {
+			day = createdAt.Format("2006-01-02")
+		}
+		daily := acc.days[day]
+		if daily == nil {
+			daily = &ErrorDay{Day: day, Categories: map[string]int64{}}
+			acc.days[day] = daily
+		}
+		acc.breakdown.Requests++
+		daily.Requests++
+
+		code := record.GetInt("http_code")
+		message := record.GetString("error_message")
+		if code >= http.StatusOK && code < http.StatusMultipleChoices && message == "" {
+			continue
+		}
+		category := classifyUpstreamError(code, message)
+		acc.breakdown.Errors++
+		acc.breakdown.Categories[category]++
+		daily.Categories[category]++
+
+		reasonText := normalizeErrorReason(message)
+		key := category + "\x00" + reasonText
+		reason := acc.reasons[key]
+		if reason == nil {
+			reason = &ErrorReason{Category: category, HttpCode: code, Message: reasonText}
+			acc.reasons[key] = reason
+		}
+		reason.Count++
+	}
+
+	result := make([]ProviderErrorBreakdown, 0, len(providers))
+	for _, acc := range providers {
+		breakdown := acc.breakdown
+		if breakdown.Requests > 0 {
+			breakdown.ErrorRate = float64(breakdown.Errors) / float64(breakdown.Requests) * 100
+		}
+		breakdown.TopReasons = make([]ErrorReason, 0, len(acc.reasons))
+		for _, reason := range acc.reasons {
+			breakdown.TopReasons = append(breakdown.TopReasons, *reason)
+		}
+		sort.Slice(breakdown.TopReasons, func(i, j int) bool {
+			if breakdown.TopReasons[i].Count != breakdown.TopReasons[j].Count {
+				return breakdown.TopReasons[i].Count > breakdown.TopReasons[j].Count
+			}
+			return breakdown.TopReasons[i].Message < breakdown.TopReasons[j].Message
+		})
+		if len(breakdown.TopReasons) > topErrorReasonLimit {
+			breakdown.TopReasons = breakdown.TopReasons[:topErrorReasonLimit]
+		}
+		breakdown.Daily = make([]ErrorDay, 0, len(acc.days))
+		for _, daily := range acc.days {
+			breakdown.Daily = append(breakdown.Daily, *daily)
+		}
+		sort.Slice(breakdown.Daily, func(i, j int) bool {
+			return breakdown.Daily[i].Day < breakdown.Daily[j].Day
+		})
+		result = append(result, *breakdown)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Errors != result[j].Errors {
+			return result[i].Errors > result[j].Errors
+		}
+		return result[i].Provider < result[j].Provider
+	})
+	return result, nil
+}
+
+// classifyUpstreamError 按状态码与错误信息把失败归类
+func classifyUpstreamError(code int, message string) string {
+	lower := strings.ToLower(message)
+	containsAny := func(keywords ...string) bool {
+		for _, keyword := range keywords {
+			if strings.Contains(lower, keyword) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case code == http.StatusUnauthorized || code == http.StatusForbidden ||
+		containsAny("invalid api key", "invalid x-api-key", "unauthorized", "authentication", "permission denied"):
+		return ErrorCategoryAuth
+	case code == http.StatusPaymentRequired ||
+		containsAny("quota", "insufficient", "balance", "credit", "billing", "余额", "额度"):
+		return ErrorCategoryQuota
+	case code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable || code == 529 ||
+		containsAny("overloaded", "rate limit", "rate_limit", "too many requests", "capacity"):
+		return ErrorCategoryOverloaded
+	case code == http.StatusRequestTimeout || code == http.StatusGatewayTimeout ||
+		containsAny("timeout", "timed out", "deadline exceeded"):
+		return ErrorCategoryTimeout
+	case containsAny("unexpected eof", "invalid character", "malformed", "cannot unmarshal", "empty response", "connection reset"):
+		return ErrorCategoryMalformed
+	case code >= http.StatusBadRequest && code < http.StatusInternalServerError:
+		return ErrorCategoryClient
+	default:
+		return ErrorCategoryOther
+	}
+}
+
+// normalizeErrorReason 去掉错误信息中的请求 id、数字等易变部分，便于合并同类错误
+func normalizeErrorReason(message string) string {
+	message = strings.TrimSpace(message)
+	if message == "" {
+		return "(无错误信息)"
+	}
+	message = errorReasonNumberPattern.ReplaceAllString(message, "N")
+	message = errorReasonSpacePattern.ReplaceAllString(message, " ")
+	if runes := []rune(message); len(runes) > 120 {
+		message = string(runes[:120]) + "…"
+	}
+	return message
+}
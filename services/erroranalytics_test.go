@@ -0,0 +1,33 @@
+package services
+
+import "testing"
+
+func TestClassifyUpstreamError(t *testing.T) {
+	cases := []struct {
+		code    int
+		message string
+		want    string
+	}{
+		{401, "upstream status 401: invalid api key", ErrorCategoryAuth},
+		{400, "upstream status 400: Your credit balance is too low", ErrorCategoryQuota},
+		{529, "upstream status 529: Overloaded", ErrorCategoryOverloaded},
+		{429, "upstream status 429: rate limit exceeded", ErrorCategoryOverloaded},
+		{0, "context deadline exceeded (Client.Timeout exceeded while awaiting headers)", ErrorCategoryTimeout},
+		{200, "unexpected EOF", ErrorCategoryMalformed},
+		{400, "upstream status 400: messages: field required", ErrorCategoryClient},
+		{500, "upstream status 500: internal error", ErrorCategoryOther},
+	}
+	for _, tc := range cases {
+		if got := classifyUpstreamError(tc.code, tc.message); got != tc.want {
+			t.Errorf("classify(%d, %q) = %s, want %s", tc.code, tc.message, got, tc.want)
+		}
+	}
+}
+
+func TestNormalizeErrorReasonMergesVariableParts(t *testing.T) {
+	a := normalizeErrorReason("upstream status 529: Overloaded (request_id req_011CTbd4c1f9e2a7)")
+	b := normalizeErrorReason("upstream status 529:  Overloaded (request_id req_022DYzz9ab83ffe1)")
+	if a != b {
+		t.Fatalf("expected reasons to merge:\n%s\n%s", a, b)
+	}
+}
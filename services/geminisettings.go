@@ -0,0 +1,311 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	geminiEnvFileName        = ".env"
+	geminiBackupSettingsName = "cc-studio.back.settings.json"
+	geminiBackupEnvName      = "cc-studio.back.env"
+	geminiBaseURLEnv         = "GOOGLE_GEMINI_BASE_URL"
+	geminiAPIKeyEnv          = "GEMINI_API_KEY"
+	geminiAuthTypeAPIKey     = "gemini-api-key"
+	geminiTokenValue         = "code-switch"
+)
+
+// GeminiSettingsService 管理 Gemini CLI 的 ~/.gemini/settings.json 与 ~/.gemini/.env：
+// 地址与 Key 写入 .env，settings.json 只切换认证方式，其余设置（MCP、主题等）保持不变
+type GeminiSettingsService struct {
+	relayAddr string
+}
+
+// geminiDirectState 直连写入 .env 的键，以及写入前 settings.json 中的认证方式
+type geminiDirectState struct {
+	directApplyState
+	AuthType *string `json:"auth_type,omitempty"`
+}
+
+func NewGeminiSettingsService(relayAddr string) *GeminiSettingsService {
+	return &GeminiSettingsService{relayAddr: relayAddr}
+}
+
+func (gss *GeminiSettingsService) ProxyStatus() (ClaudeProxyStatus, error) {
+	status := ClaudeProxyStatus{Enabled: false, BaseURL: gss.baseURL()}
+	envPath, _, err := gss.envPaths()
+	if err != nil {
+		return status, err
+	}
+	data, err := os.ReadFile(envPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return status, nil
+		}
+		return status, err
+	}
+	values := parseEnvLines(data)
+	status.Enabled = strings.EqualFold(values[geminiBaseURLEnv], gss.baseURL()) &&
+		values[geminiAPIKeyEnv] == geminiTokenValue
+	return status, nil
+}
+
+// EnableProxy 备份 settings.json 与 .env 后把 Gemini CLI 指向本地中转
+func (gss *GeminiSettingsService) EnableProxy() error {
+	gss.snapshot("gemini:enable-proxy")
+	settingsPath, settingsBackup, err := gss.paths()
+	if err != nil {
+		return err
+	}
+	envPath, envBackup, err := gss.envPaths()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(settingsPath), 0o755); err != nil {
+		return err
+	}
+	for _, pair := range [][2]string{{settingsPath, settingsBackup}, {envPath, envBackup}} {
+		content, err := os.ReadFile(pair[0])
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(pair[1], content, 0o600); err != nil {
+			return err
+		}
+	}
+	if _, err := gss.setAuthType(geminiAuthTypeAPIKey); err != nil {
+		return err
+	}
+	return gss.updateEnv(func(env map[string]any) {
+		env[geminiBaseURLEnv] = gss.baseURL()
+		env[geminiAPIKeyEnv] = geminiTokenValue
+	})
+}
+
+// DisableProxy 用开启代理时的备份还原两个文件，开启前不存在的文件直接删除
+func (gss *GeminiSettingsService) DisableProxy() error {
+	gss.snapshot("gemini:disable-proxy")
+	settingsPath, settingsBackup, err := gss.paths()
+	if err != nil {
+		return err
+	}
+	envPath, envBackup, err := gss.envPaths()
+	if err != nil {
+		return err
+	}
+	for _, pair := range [][2]string{{settingsPath, settingsBackup}, {envPath, envBackup}} {
+		if err := os.Remove(pair[0]); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if _, err := os.Stat(pair[1]); err == nil {
+			if err := os.Rename(pair[1], pair[0]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ApplySingleProvider 直连模式：.env 中写入 provider 的地址、Key 与自定义变量（如 GEMINI_MODEL）
+func (gss *GeminiSettingsService) ApplySingleProvider(provider Provider) error {
+	if strings.TrimSpace(provider.APIURL) == "" || strings.TrimSpace(provider.APIKey) == "" {
+		return errors.New("provider 缺少 API 地址或 API Key")
+	}
+	gss.snapshot("gemini:apply-provider")
+	if err := gss.RemoveSingleProvider(); err != nil {
+		return err
+	}
+	settingsPath, _, err := gss.paths()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(settingsPath), 0o755); err != nil {
+		return err
+	}
+	values := providerExtraEnv(provider, geminiBaseURLEnv, geminiAPIKeyEnv)
+	values[geminiBaseURLEnv] = provider.APIURL
+	values[geminiAPIKeyEnv] = provider.APIKey
+
+	var state geminiDirectState
+	if state.AuthType, err = gss.setAuthType(geminiAuthTypeAPIKey); err != nil {
+		return err
+	}
+	if err := gss.updateEnv(func(env map[string]any) {
+		state.directApplyState = mergeDirectValues(env, values, provider.Name)
+	}); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(gss.directStatePath(), data, 0o600)
+}
+
+// RemoveSingleProvider 移除直连写入的变量，被覆盖的原值与原来的认证方式恢复
+func (gss *GeminiSettingsService) RemoveSingleProvider() error {
+	gss.snapshot("gemini:remove-provider")
+	statePath := gss.directStatePath()
+	data, err := os.ReadFile(statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var state geminiDirectState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	if err := gss.updateEnv(func(env map[string]any) {
+		stripDirectValues(env, state.directApplyState)
+	}); err != nil {
+		return err
+	}
+	previous := ""
+	if state.AuthType != nil {
+		previous = *state.AuthType
+	}
+	if _, err := gss.setAuthType(previous); err != nil {
+		return err
+	}
+	if err := os.Remove(statePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// DirectApplyStatus 返回当前直连应用的 provider
+func (gss *GeminiSettingsService) DirectApplyStatus() (DirectApplyStatus, error) {
+	state, err := loadDirectApplyState(gss.directStatePath())
+	if err != nil || state == nil {
+		return DirectApplyStatus{}, err
+	}
+	return DirectApplyStatus{Applied: true, Provider: state.Provider}, nil
+}
+
+// setAuthType 写入 security.auth.selectedType，旧版本的 selectedAuthType 存在时同步修改；
+// authType 为空时删除该设置。返回修改前的值，原先不存在时为 nil
+func (gss *GeminiSettingsService) setAuthType(authType string) (*string, error) {
+	settingsPath, _, err := gss.paths()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := readClaudeSettingsFile(settingsPath)
+	if errors.Is(err, os.ErrNotExist) {
+		raw, err = make(map[string]any), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	security, _ := raw["security"].(map[string]any)
+	auth, _ := security["auth"].(map[string]any)
+	var previous *string
+	if value, ok := auth["selectedType"].(string); ok {
+		previous = &value
+	}
+	if (previous == nil && authType == "") || (previous != nil && *previous == authType) {
+		return previous, nil
+	}
+	if authType == "" {
+		delete(auth, "selectedType")
+		if len(auth) == 0 {
+			delete(security, "auth")
+		}
+		if len(security) == 0 {
+			delete(raw, "security")
+		}
+	} else {
+		if security == nil {
+			security = make(map[string]any)
+			raw["security"] = security
+		}
+		if auth == nil {
+			auth = make(map[string]any)
+			security["auth"] = auth
+		}
+		auth["selectedType"] = authType
+	}
+	if _, ok := raw["selectedAuthType"]; ok {
+		if authType == "" {
+			delete(raw, "selectedAuthType")
+		} else {
+			raw["selectedAuthType"] = authType
+		}
+	}
+	return previous, writeClaudeSettingsFile(settingsPath, raw)
+}
+
+// updateEnv 修改 .env，注释与其他变量保持不变；修改后没有任何变量时删除文件
+func (gss *GeminiSettingsService) updateEnv(apply func(env map[string]any)) error {
+	envPath, _, err := gss.envPaths()
+	if err != nil {
+		return err
+	}
+	original, err := os.ReadFile(envPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	env := make(map[string]any)
+	for key, value := range parseEnvLines(original) {
+		env[key] = value
+	}
+	apply(env)
+	data := patchEnvLines(original, env)
+	if len(env) == 0 && len(data) == 0 {
+		if err := os.Remove(envPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	return os.WriteFile(envPath, data, 0o600)
+}
+
+func (gss *GeminiSettingsService) paths() (settingsPath string, backupPath string, err error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", "", err
+	}
+	dir := filepath.Join(home, geminiDirName)
+	return filepath.Join(dir, geminiSettingsFileName), filepath.Join(dir, geminiBackupSettingsName), nil
+}
+
+func (gss *GeminiSettingsService) envPaths() (string, string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", "", err
+	}
+	dir := filepath.Join(home, geminiDirName)
+	return filepath.Join(dir, geminiEnvFileName), filepath.Join(dir, geminiBackupEnvName), nil
+}
+
+func (gss *GeminiSettingsService) directStatePath() string {
+	settingsPath, _, err := gss.paths()
+	if err != nil {
+		return directApplyStateFileName
+	}
+	return filepath.Join(filepath.Dir(settingsPath), directApplyStateFileName)
+}
+
+// snapshot 修改前为 settings.json 与 .env 保存同一个快照
+func (gss *GeminiSettingsService) snapshot(operation string) {
+	settingsPath, _, err := gss.paths()
+	if err != nil {
+		return
+	}
+	envPath, _, err := gss.envPaths()
+	if err != nil {
+		return
+	}
+	snapshotConfigFiles(operation, settingsPath, envPath)
+}
+
+func (gss *GeminiSettingsService) baseURL() string {
+	return relayBaseURL(gss.relayAddr)
+}
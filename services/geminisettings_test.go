@@ -0,0 +1,63 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGeminiProxyAndDirectApply(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	settings := "{\n  // theme\n  \"theme\": \"Dracula\",\n  \"security\": {\"auth\": {\"selectedType\": \"oauth-personal\"}}\n}\n"
+	env := "# keys\nOTHER=1\n"
+	writeSkillFiles(t, home, map[string]string{
+		".gemini/settings.json": settings,
+		".gemini/.env":          env,
+	})
+	gss := NewGeminiSettingsService(":18100")
+	if err := gss.EnableProxy(); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := gss.ProxyStatus(); !status.Enabled {
+		t.Fatal("proxy should be enabled")
+	}
+	data, _ := os.ReadFile(filepath.Join(home, ".gemini", "settings.json"))
+	if !strings.Contains(string(data), "// theme") || !strings.Contains(string(data), geminiAuthTypeAPIKey) {
+		t.Fatalf("unexpected settings:\n%s", data)
+	}
+	if err := gss.DisableProxy(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(home, ".gemini", ".env")); string(data) != env {
+		t.Fatalf(".env not restored:\n%s", data)
+	}
+
+	provider := Provider{Name: "aistudio", APIURL: "https://example.com", APIKey: "sk-1", Env: map[string]string{"GEMINI_MODEL": "gemini-2.5-pro"}}
+	if err := gss.ApplySingleProvider(provider); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(filepath.Join(home, ".gemini", ".env"))
+	for _, want := range []string{"# keys", "OTHER=1", "GEMINI_API_KEY=sk-1", "GEMINI_MODEL=gemini-2.5-pro"} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("missing %q in:\n%s", want, data)
+		}
+	}
+	if status, _ := gss.DirectApplyStatus(); status.Provider != "aistudio" {
+		t.Fatalf("unexpected direct status %+v", status)
+	}
+	if err := gss.RemoveSingleProvider(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(home, ".gemini", ".env")); string(data) != env {
+		t.Fatalf(".env not restored:\n%s", data)
+	}
+	raw, err := readClaudeSettingsFile(filepath.Join(home, ".gemini", "settings.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if auth := raw["security"].(map[string]any)["auth"].(map[string]any); auth["selectedType"] != "oauth-personal" {
+		t.Fatalf("auth type not restored: %v", auth)
+	}
+}
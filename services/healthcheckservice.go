@@ -0,0 +1,348 @@
+package services
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	healthCheckTick = 5 * time.Second
+	// 探测花费达到每日预算的该比例后降低检查频率
+	probeBudgetSlowdownRatio = 0.8
+	probeBudgetSlowdownScale = 4
+	probeCostCacheTTL        = time.Minute
+)
+
+// HealthCheckPolicy 健康检查频率：健康的 provider 低频检查，异常或被拉黑的高频检查以尽快发现恢复
+type HealthCheckPolicy struct {
+	Enabled              bool `json:"enabled"`
+	HealthyIntervalSec   int  `json:"healthy_interval_sec"`
+	UnhealthyIntervalSec int  `json:"unhealthy_interval_sec"`
+	// 在间隔基础上随机浮动的百分比，避免所有 provider 同时被检查
+	JitterPercent int `json:"jitter_percent"`
+	// 经由本地 relay 做端到端探测，同时覆盖 relay 的转换与注入链路
+	ThroughRelay bool `json:"through_relay"`
+	// 每日探测预算（美元），0 表示不限制；接近预算时降频，用尽后暂停自动检查
+	DailyBudgetUSD float64 `json:"daily_budget_usd"`
+}
+
+// ProbeBudgetStatus 当日探测预算使用情况
+type ProbeBudgetStatus struct {
+	BudgetUSD float64 `json:"budget_usd"`
+	SpentUSD  float64 `json:"spent_usd"`
+	Throttled bool    `json:"throttled"`
+	Paused    bool    `json:"paused"`
+}
+
+type ProviderHealth struct {
+	Platform            string    `json:"platform"`
+	Provider            string    `json:"provider"`
+	Healthy             bool      `json:"healthy"`
+	LatencyMs           float64   `json:"latency_ms"`
+	HttpCode            int       `json:"http_code"`
+	Error               string    `json:"error,omitempty"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastCheck           time.Time `json:"last_check"`
+	NextCheck           time.Time `json:"next_check"`
+}
+
+type HealthCheckService struct {
+	providerService     *ProviderService
+	connectivityService *ConnectivityTestService
+	blacklistService    *BlacklistService
+	appSettings         *AppSettingsService
+	logService          *LogService
+	mu                  sync.Mutex
+	health              map[string]*ProviderHealth
+	running             map[string]bool
+	rng                 *rand.Rand
+	stopCh              chan struct{}
+	probeCost           float64
+	probeCostAt         time.Time
+}
+
+func NewHealthCheckService(providerService *ProviderService, connectivityService *ConnectivityTestService, blacklistService *BlacklistService, appSettings *AppSettingsService, logService *LogService) *HealthCheckService {
+	return &HealthCheckService{
+		providerService:     providerService,
+		connectivityService: connectivityService,
+		blacklistService:    blacklistService,
+		appSettings:         appSettings,
+		logService:          logService,
+		health:              make(map[string]*ProviderHealth),
+		running:             make(map[string]bool),
+		rng:                 rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func defaultHealthCheckPolicy() HealthCheckPolicy {
+	return HealthCheckPolicy{
+		Enabled:              true,
+		HealthyIntervalSec:   600,
+		UnhealthyIntervalSec: 30,
+		JitterPercent:        20,
+	}
+}
+
+func normalizeHealthCheckPolicy(policy HealthCheckPolicy) HealthCheckPolicy {
+	defaults := defaultHealthCheckPolicy()
+	if policy.HealthyIntervalSec <= 0 {
+		policy.HealthyIntervalSec = defaults.HealthyIntervalSec
+	}
+	if policy.UnhealthyIntervalSec <= 0 {
+		policy.UnhealthyIntervalSec = defaults.UnhealthyIntervalSec
+	}
+	if policy.JitterPercent < 0 {
+		policy.JitterPercent = 0
+	}
+	if policy.JitterPercent > 50 {
+		policy.JitterPercent = 50
+	}
+	if policy.DailyBudgetUSD < 0 {
+		policy.DailyBudgetUSD = 0
+	}
+	return policy
+}
+
+// Start 启动调度循环，每个 provider 按自己的下次检查时间被调度
+func (hcs *HealthCheckService) Start() error {
+	hcs.mu.Lock()
+	defer hcs.mu.Unlock()
+	if hcs.stopCh != nil {
+		return nil
+	}
+	stopCh := make(chan struct{})
+	hcs.stopCh = stopCh
+	go func() {
+		ticker := time.NewTicker(healthCheckTick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				// 拉黑到期恢复不依赖健康检查开关
+				if hcs.blacklistService != nil {
+					hcs.blacklistService.AutoRecoverExpired()
+				}
+				hcs.runDue(time.Now())
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (hcs *HealthCheckService) Stop() error {
+	hcs.mu.Lock()
+	defer hcs.mu.Unlock()
+	if hcs.stopCh != nil {
+		close(hcs.stopCh)
+		hcs.stopCh = nil
+	}
+	return nil
+}
+
+// ListHealth 返回各 provider 最近一次健康检查结果，演示模式下返回演示 provider 的结果
+func (hcs *HealthCheckService) ListHealth(platform string) []ProviderHealth {
+	if isDemoMode() {
+		return demoProviderHealth(platform)
+	}
+	platform = normalizePresetKind(platform)
+	hcs.mu.Lock()
+	defer hcs.mu.Unlock()
+	result := make([]ProviderHealth, 0, len(hcs.health))
+	for _, item := range hcs.health {
+		if platform != "" && item.Platform != platform {
+			continue
+		}
+		result = append(result, *item)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Platform != result[j].Platform {
+			return result[i].Platform < result[j].Platform
+		}
+		return result[i].Provider < result[j].Provider
+	})
+	return result
+}
+
+// CheckNow 立即检查指定 provider
+func (hcs *HealthCheckService) CheckNow(platform string, providerID int) (ProviderHealth, error) {
+	providers, err := hcs.providerService.LoadProviders(platform)
+	if err != nil {
+		return ProviderHealth{}, err
+	}
+	for _, provider := range providers {
+		if provider.ID == providerID {
+			return hcs.check(normalizePresetKind(platform), provider), nil
+		}
+	}
+	return ProviderHealth{}, fmt.Errorf("未找到 provider id %d", providerID)
+}
+
+// GetProbeBudget 返回当日探测预算使用情况
+func (hcs *HealthCheckService) GetProbeBudget() ProbeBudgetStatus {
+	return hcs.budgetStatus(hcs.policy(), time.Now())
+}
+
+func (hcs *HealthCheckService) budgetStatus(policy HealthCheckPolicy, now time.Time) ProbeBudgetStatus {
+	status := ProbeBudgetStatus{BudgetUSD: policy.DailyBudgetUSD, SpentUSD: hcs.probeCostToday(now)}
+	if status.BudgetUSD <= 0 {
+		return status
+	}
+	status.Paused = status.SpentUSD >= status.BudgetUSD
+	status.Throttled = status.Paused || status.SpentUSD >= status.BudgetUSD*probeBudgetSlowdownRatio
+	return status
+}
+
+// probeCostToday 读取今日探测花费，结果缓存一分钟，避免每个调度周期都扫描日志表
+func (hcs *HealthCheckService) probeCostToday(now time.Time) float64 {
+	if hcs.logService == nil {
+		return 0
+	}
+	hcs.mu.Lock()
+	if !hcs.probeCostAt.IsZero() && now.Sub(hcs.probeCostAt) < probeCostCacheTTL && startOfDay(hcs.probeCostAt).Equal(startOfDay(now)) {
+		cost := hcs.probeCost
+		hcs.mu.Unlock()
+		return cost
+	}
+	hcs.mu.Unlock()
+	cost, err := hcs.logService.ProbeCostToday()
+	if err != nil {
+		return 0
+	}
+	hcs.mu.Lock()
+	hcs.probeCost = cost
+	hcs.probeCostAt = now
+	hcs.mu.Unlock()
+	return cost
+}
+
+func (hcs *HealthCheckService) policy() HealthCheckPolicy {
+	if hcs.appSettings == nil {
+		return defaultHealthCheckPolicy()
+	}
+	settings, err := hcs.appSettings.GetAppSettings()
+	if err != nil {
+		return defaultHealthCheckPolicy()
+	}
+	return normalizeHealthCheckPolicy(settings.HealthCheck)
+}
+
+// runDue 检查所有到期的 provider；新发现的 provider 在一个随机的短延迟后首次检查
+func (hcs *HealthCheckService) runDue(now time.Time) {
+	policy := hcs.policy()
+	if !policy.Enabled {
+		return
+	}
+	// 预算用尽后暂停自动检查，手动 CheckNow 不受影响
+	if hcs.budgetStatus(policy, now).Paused {
+		return
+	}
+	for _, platform := range []string{"claude", "codex"} {
+		providers, err := hcs.providerService.LoadProviders(platform)
+		if err != nil {
+			continue
+		}
+		for _, provider := range providers {
+			if (!provider.Enabled && !provider.Observer) || provider.APIURL == "" {
+				continue
+			}
+			key := blacklistKey(platform, provider.Name)
+			unhealthyInterval := time.Duration(policy.UnhealthyIntervalSec) * time.Second
+			blacklisted := hcs.blacklistService != nil && hcs.blacklistService.IsBlacklisted(platform, provider.Name)
+			hcs.mu.Lock()
+			item, ok := hcs.health[key]
+			if !ok {
+				item = &ProviderHealth{
+					Platform:  platform,
+					Provider:  provider.Name,
+					Healthy:   true,
+					NextCheck: now.Add(hcs.jitterLocked(unhealthyInterval, 100)),
+				}
+				hcs.health[key] = item
+			}
+			// 被 relay 拉黑的 provider 立即切换到高频检查，尽快发现恢复
+			if blacklisted && item.NextCheck.Sub(now) > unhealthyInterval {
+				item.NextCheck = now.Add(hcs.jitterLocked(unhealthyInterval, policy.JitterPercent))
+			}
+			due := !now.Before(item.NextCheck) && !hcs.running[key]
+			if due {
+				hcs.running[key] = true
+			}
+			hcs.mu.Unlock()
+			if due {
+				go func(platform string, provider Provider, key string) {
+					defer func() {
+						hcs.mu.Lock()
+						delete(hcs.running, key)
+						hcs.mu.Unlock()
+					}()
+					hcs.check(platform, provider)
+				}(platform, provider, key)
+			}
+		}
+	}
+}
+
+// check 执行一次探测，并根据结果与拉黑状态安排下一次检查
+func (hcs *HealthCheckService) check(platform string, provider Provider) ProviderHealth {
+	policy := hcs.policy()
+	probe := hcs.connectivityService.TestConnectivity
+	if policy.ThroughRelay {
+		probe = hcs.connectivityService.TestThroughRelay
+	}
+	result, err := probe(platform, provider.ID)
+	if err != nil {
+		result = ConnectivityResult{Provider: provider.Name, Error: err.Error()}
+	}
+	now := time.Now()
+	budget := hcs.budgetStatus(policy, now)
+	blacklisted := hcs.blacklistService != nil && hcs.blacklistService.IsBlacklisted(platform, provider.Name)
+	if blacklisted && result.Success && hcs.blacklistService.RecoverFromHealthCheck(platform, provider.Name) {
+		blacklisted = false
+	}
+
+	hcs.mu.Lock()
+	defer hcs.mu.Unlock()
+	key := blacklistKey(platform, provider.Name)
+	item, ok := hcs.health[key]
+	if !ok {
+		item = &ProviderHealth{Platform: platform, Provider: provider.Name}
+		hcs.health[key] = item
+	}
+	item.Healthy = result.Success
+	item.LatencyMs = result.LatencyMs
+	item.HttpCode = result.HttpCode
+	item.Error = result.Error
+	item.LastCheck = now
+	if result.Success {
+		item.ConsecutiveFailures = 0
+	} else {
+		item.ConsecutiveFailures++
+	}
+
+	interval := time.Duration(policy.HealthyIntervalSec) * time.Second
+	if !item.Healthy || blacklisted {
+		interval = time.Duration(policy.UnhealthyIntervalSec) * time.Second
+	}
+	if budget.Throttled {
+		interval *= probeBudgetSlowdownScale
+	}
+	item.NextCheck = now.Add(hcs.jitterLocked(interval, policy.JitterPercent))
+	return *item
+}
+
+// jitterLocked 在 interval 基础上随机浮动 ±percent%
+func (hcs *HealthCheckService) jitterLocked(interval time.Duration, percent int) time.Duration {
+	if percent <= 0 {
+		return interval
+	}
+	spread := int64(interval) * int64(percent) / 100
+	if spread <= 0 {
+		return interval
+	}
+	return interval - time.Duration(spread) + time.Duration(hcs.rng.Int63n(2*spread+1))
+}
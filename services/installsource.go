@@ -0,0 +1,140 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// 安装来源
+const (
+	InstallSourceDirect   = "direct"
+	InstallSourceHomebrew = "homebrew"
+	InstallSourceScoop    = "scoop"
+	InstallSourceWinget   = "winget"
+	InstallSourceAUR      = "aur"
+)
+
+// InstallSource 应用的安装方式；由包管理器安装时不自更新，改为提示（或执行）对应的升级命令，
+// 避免覆盖包管理器管理的文件
+type InstallSource struct {
+	Source         string   `json:"source"`
+	Managed        bool     `json:"managed"`
+	Package        string   `json:"package,omitempty"`
+	UpgradeCommand []string `json:"upgrade_command,omitempty"`
+}
+
+// Command 升级命令的文本形式，供前端展示
+func (s InstallSource) Command() string {
+	return strings.Join(s.UpgradeCommand, " ")
+}
+
+// GetInstallSource 返回检测到的安装方式，首次调用时检测
+func (us *UpdateService) GetInstallSource() InstallSource {
+	us.installOnce.Do(func() {
+		us.install = detectInstallSource()
+		if us.install.Managed {
+			fmt.Printf("[INFO] 检测到通过 %s 安装，更新将交由包管理器完成\n", us.install.Source)
+		}
+	})
+	return us.install
+}
+
+// RunPackageUpgrade 执行包管理器的升级命令，返回命令输出
+func (us *UpdateService) RunPackageUpgrade() (string, error) {
+	install := us.GetInstallSource()
+	if !install.Managed || len(install.UpgradeCommand) == 0 {
+		return "", errors.New("当前不是通过包管理器安装，请使用应用内更新")
+	}
+	if _, err := exec.LookPath(install.UpgradeCommand[0]); err != nil {
+		return "", fmt.Errorf("找不到 %s，请在终端中手动执行: %s", install.UpgradeCommand[0], install.Command())
+	}
+	output, err := exec.Command(install.UpgradeCommand[0], install.UpgradeCommand[1:]...).CombinedOutput()
+	if err != nil {
+		return string(output), fmt.Errorf("执行 %s 失败: %w", install.Command(), err)
+	}
+	return string(output), nil
+}
+
+func detectInstallSource() InstallSource {
+	exe, err := os.Executable()
+	if err != nil {
+		return InstallSource{Source: InstallSourceDirect}
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	source := installSourceFromPath(runtime.GOOS, exe)
+	switch {
+	case source.Managed:
+		return source
+	case runtime.GOOS == "darwin" && homebrewCaskInstalled():
+		// cask 会把 .app 拷贝到 /Applications，只能通过 Caskroom 判断
+		return managedInstallSource(InstallSourceHomebrew, homebrewCask)
+	case runtime.GOOS == "linux":
+		if pkg := pacmanOwner(exe); pkg != "" {
+			return managedInstallSource(InstallSourceAUR, pkg)
+		}
+	}
+	return source
+}
+
+const (
+	homebrewCask  = "code-switch"
+	scoopApp      = "code-switch"
+	wingetPackage = "daodao97.CodeSwitch"
+)
+
+// installSourceFromPath 按可执行文件路径判断安装来源
+func installSourceFromPath(goos, exe string) InstallSource {
+	path := strings.ToLower(strings.ReplaceAll(exe, `\`, "/"))
+	switch {
+	case strings.Contains(path, "/caskroom/") || strings.Contains(path, "/cellar/"):
+		return managedInstallSource(InstallSourceHomebrew, homebrewCask)
+	case goos == "windows" && strings.Contains(path, "/scoop/apps/"):
+		return managedInstallSource(InstallSourceScoop, scoopApp)
+	case goos == "windows" && strings.Contains(path, "/microsoft/winget/packages/"):
+		return managedInstallSource(InstallSourceWinget, wingetPackage)
+	}
+	return InstallSource{Source: InstallSourceDirect}
+}
+
+func managedInstallSource(source, pkg string) InstallSource {
+	install := InstallSource{Source: source, Managed: true, Package: pkg}
+	switch source {
+	case InstallSourceHomebrew:
+		install.UpgradeCommand = []string{"brew", "upgrade", "--cask", pkg}
+	case InstallSourceScoop:
+		install.UpgradeCommand = []string{"scoop", "update", pkg}
+	case InstallSourceWinget:
+		install.UpgradeCommand = []string{"winget", "upgrade", "--id", pkg, "--silent"}
+	case InstallSourceAUR:
+		install.UpgradeCommand = []string{"yay", "-S", "--noconfirm", pkg}
+	}
+	return install
+}
+
+func homebrewCaskInstalled() bool {
+	for _, prefix := range []string{"/opt/homebrew", "/usr/local"} {
+		if _, err := os.Stat(filepath.Join(prefix, "Caskroom", homebrewCask)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// pacmanOwner 返回拥有该文件的 pacman 包名（AUR 包安装后同样归 pacman 管理）
+func pacmanOwner(exe string) string {
+	if _, err := exec.LookPath("pacman"); err != nil {
+		return ""
+	}
+	output, err := exec.Command("pacman", "-Qqo", exe).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
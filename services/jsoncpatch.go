@@ -0,0 +1,364 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// jsoncValue JSONC 文档中的一个取值及其在原文中的范围，object 额外记录成员
+type jsoncValue struct {
+	start   int
+	end     int
+	object  bool
+	members []jsoncMember
+}
+
+// jsoncMember start 为键的起始位置
+type jsoncMember struct {
+	key   string
+	start int
+	value *jsoncValue
+}
+
+func (v *jsoncValue) member(key string) (int, *jsoncMember) {
+	for i := len(v.members) - 1; i >= 0; i-- {
+		if v.members[i].key == key {
+			return i, &v.members[i]
+		}
+	}
+	return -1, nil
+}
+
+// parseJSONC 解析允许 // 与 /* */ 注释、尾逗号的 JSON（VS Code 系编辑器的配置格式）
+func parseJSONC(data []byte) (map[string]any, error) {
+	payload := make(map[string]any)
+	if err := json.Unmarshal(stripJSONC(data), &payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// stripJSONC 去掉注释与尾逗号，得到标准 JSON
+func stripJSONC(data []byte) []byte {
+	var buf bytes.Buffer
+	for i := 0; i < len(data); {
+		switch {
+		case data[i] == '"':
+			end := scanJSONString(data, i)
+			buf.Write(data[i:end])
+			i = end
+		case bytes.HasPrefix(data[i:], []byte("//")), bytes.HasPrefix(data[i:], []byte("/*")):
+			i = skipJSONCSpace(data, i)
+		case data[i] == ',':
+			next := skipJSONCSpace(data, i+1)
+			if next < len(data) && (data[next] == '}' || data[next] == ']') {
+				i++
+				continue
+			}
+			buf.WriteByte(',')
+			i++
+		default:
+			buf.WriteByte(data[i])
+			i++
+		}
+	}
+	return buf.Bytes()
+}
+
+// skipJSONCSpace 跳过空白与注释
+func skipJSONCSpace(data []byte, pos int) int {
+	for pos < len(data) {
+		switch {
+		case data[pos] == ' ' || data[pos] == '\t' || data[pos] == '\r' || data[pos] == '\n':
+			pos++
+		case bytes.HasPrefix(data[pos:], []byte("//")):
+			pos = tomlLineEnd(data, pos)
+		case bytes.HasPrefix(data[pos:], []byte("/*")):
+			end := bytes.Index(data[pos+2:], []byte("*/"))
+			if end < 0 {
+				return len(data)
+			}
+			pos += end + 4
+		default:
+			return pos
+		}
+	}
+	return pos
+}
+
+// scanJSONString 返回字符串结束引号之后的位置，未闭合时返回文档末尾
+func scanJSONString(data []byte, pos int) int {
+	for i := pos + 1; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(data)
+}
+
+func parseJSONCTree(data []byte) (*jsoncValue, error) {
+	pos := skipJSONCSpace(data, 0)
+	root, pos, err := parseJSONCValue(data, pos)
+	if err != nil {
+		return nil, err
+	}
+	if pos = skipJSONCSpace(data, pos); pos != len(data) {
+		return nil, fmt.Errorf("第 %d 字节后存在多余内容", pos)
+	}
+	return root, nil
+}
+
+func parseJSONCValue(data []byte, pos int) (*jsoncValue, int, error) {
+	if pos >= len(data) {
+		return nil, pos, errors.New("缺少取值")
+	}
+	value := &jsoncValue{start: pos}
+	switch data[pos] {
+	case '{', '[':
+		value.object = data[pos] == '{'
+		closing := byte('}')
+		if !value.object {
+			closing = ']'
+		}
+		pos = skipJSONCSpace(data, pos+1)
+		for pos < len(data) && data[pos] != closing {
+			var member jsoncMember
+			if value.object {
+				if data[pos] != '"' {
+					return nil, pos, fmt.Errorf("第 %d 字节处缺少键", pos)
+				}
+				end := scanJSONString(data, pos)
+				if err := json.Unmarshal(data[pos:end], &member.key); err != nil {
+					return nil, pos, err
+				}
+				member.start = pos
+				pos = skipJSONCSpace(data, end)
+				if pos >= len(data) || data[pos] != ':' {
+					return nil, pos, fmt.Errorf("第 %d 字节处缺少 :", pos)
+				}
+				pos = skipJSONCSpace(data, pos+1)
+			}
+			item, next, err := parseJSONCValue(data, pos)
+			if err != nil {
+				return nil, pos, err
+			}
+			if value.object {
+				member.value = item
+				value.members = append(value.members, member)
+			}
+			pos = skipJSONCSpace(data, next)
+			if pos < len(data) && data[pos] == ',' {
+				pos = skipJSONCSpace(data, pos+1)
+			} else if pos < len(data) && data[pos] != closing {
+				return nil, pos, fmt.Errorf("第 %d 字节处缺少 ,", pos)
+			}
+		}
+		if pos >= len(data) {
+			return nil, pos, errors.New("括号未闭合")
+		}
+		value.end = pos + 1
+	case '"':
+		value.end = scanJSONString(data, pos)
+	default:
+		end := pos
+		for end < len(data) && !strings.ContainsRune(",}] \t\r\n/", rune(data[end])) {
+			end++
+		}
+		if end == pos {
+			return nil, pos, fmt.Errorf("第 %d 字节处的取值无效", pos)
+		}
+		value.end = end
+	}
+	return value, value.end, nil
+}
+
+// patchJSONDocument 把 original 按 payload 定点修改，结果重新解析后必须与 payload 一致
+func patchJSONDocument(original []byte, payload map[string]any) ([]byte, error) {
+	current, err := parseJSONC(original)
+	if err != nil {
+		return nil, err
+	}
+	data := append([]byte(nil), original...)
+	for _, change := range diffConfigPayload(current, payload, nil) {
+		if data, err = applyJSONChange(data, payload, change); err != nil {
+			return nil, err
+		}
+	}
+
+	patched, err := parseJSONC(data)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	expected := make(map[string]any)
+	if err := json.Unmarshal(encoded, &expected); err != nil {
+		return nil, err
+	}
+	if !reflect.DeepEqual(patched, expected) {
+		return nil, errConfigPatchMismatch
+	}
+	return data, nil
+}
+
+func applyJSONChange(data []byte, payload map[string]any, change configChange) ([]byte, error) {
+	root, err := parseJSONCTree(data)
+	if err != nil {
+		return nil, err
+	}
+	if !root.object {
+		return nil, errors.New("顶层不是 object")
+	}
+	node := root
+	for i, segment := range change.path {
+		index, member := node.member(segment)
+		if member == nil {
+			if change.delete {
+				return data, nil
+			}
+			value, _ := configValueAt(payload, change.path[:i+1])
+			return insertJSONMember(data, node, segment, value)
+		}
+		last := i == len(change.path)-1
+		if last && change.delete {
+			return deleteJSONMember(data, node, index), nil
+		}
+		if last || !member.value.object {
+			// 原值不是 object 时整体替换为新配置中对应的值
+			value, ok := configValueAt(payload, change.path[:i+1])
+			if !ok {
+				return deleteJSONMember(data, node, index), nil
+			}
+			return replaceJSONValue(data, member.value, value)
+		}
+		node = member.value
+	}
+	return data, nil
+}
+
+func replaceJSONValue(data []byte, target *jsoncValue, value any) ([]byte, error) {
+	text, err := encodeJSONCValue(value, lineIndentOf(data, target.start), jsonIndentUnit(data))
+	if err != nil {
+		return nil, err
+	}
+	return spliceConfigText(data, target.start, target.end, text), nil
+}
+
+// insertJSONMember 新成员追加在最后一个成员之后，沿用该成员的缩进与换行风格
+func insertJSONMember(data []byte, object *jsoncValue, key string, value any) ([]byte, error) {
+	unit := jsonIndentUnit(data)
+	keyText, err := encodeJSONCValue(key, "", "")
+	if err != nil {
+		return nil, err
+	}
+	if len(object.members) == 0 {
+		outer := lineIndentOf(data, object.start)
+		inner := outer + unit
+		valueText, err := encodeJSONCValue(value, inner, unit)
+		if err != nil {
+			return nil, err
+		}
+		text := "\n" + inner + keyText + ": " + valueText + "\n" + outer
+		closing := object.end - 1
+		if len(bytes.TrimSpace(data[object.start+1:closing])) == 0 {
+			return spliceConfigText(data, object.start+1, closing, text), nil
+		}
+		return spliceConfigText(data, closing, closing, text), nil
+	}
+
+	last := object.members[len(object.members)-1]
+	indent := lineIndentOf(data, last.start)
+	separator := " "
+	if bytes.ContainsRune(data[object.start:last.start], '\n') {
+		separator = "\n" + indent
+	}
+	valueText, err := encodeJSONCValue(value, indent, unit)
+	if err != nil {
+		return nil, err
+	}
+	member := separator + keyText + ": " + valueText
+
+	// 已有尾逗号时新成员同样以逗号结尾
+	if next := skipJSONCSpace(data, last.value.end); next < len(data) && data[next] == ',' {
+		pos := next + 1
+		if after := skipInlineSpace(data, pos); bytes.HasPrefix(data[after:], []byte("//")) {
+			pos = lineEndOf(data, after)
+		}
+		return spliceConfigText(data, pos, pos, member+","), nil
+	}
+	// 最后一个成员后的行尾注释仍留在该成员之后
+	pos := last.value.end
+	insertAt := pos
+	if after := skipInlineSpace(data, pos); bytes.HasPrefix(data[after:], []byte("//")) && separator != " " {
+		insertAt = lineEndOf(data, after)
+	}
+	result := spliceConfigText(data, insertAt, insertAt, member)
+	return spliceConfigText(result, pos, pos, ","), nil
+}
+
+// deleteJSONMember 删除成员及其逗号；成员独占一行时连同整行与行尾注释一起删除
+func deleteJSONMember(data []byte, object *jsoncValue, index int) []byte {
+	member := object.members[index]
+	start := member.start
+	lineStart := lineStartOf(data, start)
+	ownLine := len(bytes.TrimSpace(data[lineStart:start])) == 0
+	if ownLine {
+		start = lineStart
+	}
+	end := member.value.end
+	comma := skipInlineSpace(data, end)
+	hasComma := comma < len(data) && data[comma] == ','
+	if hasComma {
+		end = comma + 1
+	}
+	if ownLine {
+		rest := skipInlineSpace(data, end)
+		if bytes.HasPrefix(data[rest:], []byte("//")) {
+			rest = lineEndOf(data, rest)
+		}
+		if rest >= len(data) || data[rest] == '\n' {
+			end = min(rest+1, len(data))
+		}
+	}
+	if hasComma || index == 0 {
+		return spliceConfigText(data, start, end, "")
+	}
+	// 最后一个成员没有逗号：删除前一个成员之后的逗号
+	data = spliceConfigText(data, start, end, "")
+	previous := object.members[index-1]
+	if pos := skipJSONCSpace(data, previous.value.end); pos < len(data) && data[pos] == ',' {
+		data = spliceConfigText(data, pos, pos+1, "")
+	}
+	return data
+}
+
+// jsonIndentUnit 取文档第一处缩进作为缩进单位，没有缩进时使用两个空格
+func jsonIndentUnit(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed != "" && len(trimmed) < len(line) {
+			return line[:len(line)-len(trimmed)]
+		}
+	}
+	return "  "
+}
+
+func encodeJSONCValue(value any, prefix string, indent string) (string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent(prefix, indent)
+	if err := encoder.Encode(value); err != nil {
+		return "", err
+	}
+	return strings.TrimRight(buf.String(), "\n"), nil
+}
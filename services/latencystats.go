@@ -0,0 +1,130 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/daodao97/xgo/xdb"
+)
+
+// LatencyStat 一组请求的耗时分位数（秒），TTFT 仅统计记录了首包时间的请求
+type LatencyStat struct {
+	Key      string  `json:"key"`
+	Requests int64   `json:"requests"`
+	P50      float64 `json:"p50"`
+	P95      float64 `json:"p95"`
+	P99      float64 `json:"p99"`
+	TTFTP50  float64 `json:"ttft_p50"`
+	TTFTP95  float64 `json:"ttft_p95"`
+	TTFTP99  float64 `json:"ttft_p99"`
+}
+
+type LatencyReport struct {
+	Since      string        `json:"since"`
+	ByProvider []LatencyStat `json:"by_provider"`
+	ByModel    []LatencyStat `json:"by_model"`
+}
+
+type latencySamples struct {
+	durations []float64
+	ttfts     []float64
+}
+
+// LatencyStatsSince 统计最近 hours 小时内成功请求的耗时与 TTFT 分位数，分别按 provider 与模型分组
+func (ls *LogService) LatencyStatsSince(platform string, hours int) (LatencyReport, error) {
+	if hours <= 0 {
+		hours = 24
+	}
+	since := time.Now().Add(-time.Duration(hours) * time.Hour)
+	report := LatencyReport{
+		Since:      since.Format(timeLayout),
+		ByProvider: []LatencyStat{},
+		ByModel:    []LatencyStat{},
+	}
+	options := []xdb.Option{
+		xdb.WhereGte("created_at", since.UTC().Format(timeLayout)),
+		xdb.WhereGte("http_code", 200),
+		xdb.WhereLt("http_code", 300),
+		xdb.Field("provider", "model", "duration_sec", "first_token_sec"),
+	}
+	if platform != "" {
+		options = append(options, xdb.WhereEq("platform", platform))
+	}
+	records, err := xdb.New(requestLogTable()).Selects(options...)
+	if err != nil {
+		if errors.Is(err, xdb.ErrNotFound) || isNoSuchTableErr(err) {
+			return report, nil
+		}
+		return report, err
+	}
+
+	byProvider := make(map[string]*latencySamples)
+	byModel := make(map[string]*latencySamples)
+	for _, record := range records {
+		duration := record.GetFloat64("duration_sec")
+		if duration <= 0 {
+			continue
+		}
+		ttft := record.GetFloat64("first_token_sec")
+		for _, bucket := range []struct {
+			groups map[string]*latencySamples
+			key    string
+		}{
+			{byProvider, record.GetString("provider")},
+			{byModel, record.GetString("model")},
+		} {
+			samples, ok := bucket.groups[bucket.key]
+			if !ok {
+				samples = &latencySamples{}
+				bucket.groups[bucket.key] = samples
+			}
+			samples.durations = append(samples.durations, duration)
+			if ttft > 0 {
+				samples.ttfts = append(samples.ttfts, ttft)
+			}
+		}
+	}
+	report.ByProvider = summarizeLatency(byProvider)
+	report.ByModel = summarizeLatency(byModel)
+	return report, nil
+}
+
+func summarizeLatency(groups map[string]*latencySamples) []LatencyStat {
+	stats := make([]LatencyStat, 0, len(groups))
+	for key, samples := range groups {
+		sort.Float64s(samples.durations)
+		sort.Float64s(samples.ttfts)
+		stats = append(stats, LatencyStat{
+			Key:      key,
+			Requests: int64(len(samples.durations)),
+			P50:      percentile(samples.durations, 50),
+			P95:      percentile(samples.durations, 95),
+			P99:      percentile(samples.durations, 99),
+			TTFTP50:  percentile(samples.ttfts, 50),
+			TTFTP95:  percentile(samples.ttfts, 95),
+			TTFTP99:  percentile(samples.ttfts, 99),
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return strings.ToLower(stats[i].Key) < strings.ToLower(stats[j].Key)
+	})
+	return stats
+}
+
+// percentile 使用 nearest-rank 法计算已排序样本的分位数
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
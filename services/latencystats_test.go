@@ -0,0 +1,28 @@
+package services
+
+import "testing"
+
+func TestPercentile(t *testing.T) {
+	samples := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+
+	tests := []struct {
+		name     string
+		sorted   []float64
+		p        float64
+		expected float64
+	}{
+		{name: "空样本", sorted: nil, p: 50, expected: 0},
+		{name: "单个样本", sorted: []float64{0.8}, p: 99, expected: 0.8},
+		{name: "p50", sorted: samples, p: 50, expected: 5},
+		{name: "p95", sorted: samples, p: 95, expected: 10},
+		{name: "p0 取最小值", sorted: samples, p: 0, expected: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := percentile(tt.sorted, tt.p); got != tt.expected {
+				t.Fatalf("percentile(%v) = %v, want %v", tt.p, got, tt.expected)
+			}
+		})
+	}
+}
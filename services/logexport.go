@@ -0,0 +1,363 @@
+package services
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const exportPageSize = 500
+
+// ExportRequest 导出条件：Query 中的 Cursor/Limit 会被忽略，导出全部匹配的日志
+type ExportRequest struct {
+	Query LogQuery `json:"query"`
+	// csv / json
+	Format string `json:"format"`
+	// 导出目录，默认为数据目录下的 exports
+	Dir string `json:"dir"`
+}
+
+// ExportJob 后台导出任务，日志与汇总统计分别写入两个文件
+type ExportJob struct {
+	ID         string    `json:"id"`
+	Format     string    `json:"format"`
+	Status     string    `json:"status"` // running / done / failed
+	LogsFile   string    `json:"logs_file"`
+	StatsFile  string    `json:"stats_file"`
+	Rows       int       `json:"rows"`
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+}
+
+// ExportStat 按日期、平台、provider、模型汇总的用量
+type ExportStat struct {
+	Day               string  `json:"day"`
+	Platform          string  `json:"platform"`
+	Provider          string  `json:"provider"`
+	Model             string  `json:"model"`
+	Requests          int64   `json:"requests"`
+	Errors            int64   `json:"errors"`
+	InputTokens       int64   `json:"input_tokens"`
+	OutputTokens      int64   `json:"output_tokens"`
+	CacheCreateTokens int64   `json:"cache_create_tokens"`
+	CacheReadTokens   int64   `json:"cache_read_tokens"`
+	ReasoningTokens   int64   `json:"reasoning_tokens"`
+	TotalCost         float64 `json:"total_cost"`
+}
+
+var exportLogHeader = []string{
+	"id", "created_at", "platform", "provider", "model", "requested_model", "session_id", "http_code",
+	"input_tokens", "output_tokens", "cache_create_tokens", "cache_read_tokens", "reasoning_tokens",
+	"is_stream", "duration_sec", "first_token_sec", "total_cost", "error_message",
+}
+
+var exportStatHeader = []string{
+	"day", "platform", "provider", "model", "requests", "errors",
+	"input_tokens", "output_tokens", "cache_create_tokens", "cache_read_tokens", "reasoning_tokens", "total_cost",
+}
+
+var logExports = struct {
+	sync.Mutex
+	jobs map[string]*ExportJob
+}{jobs: make(map[string]*ExportJob)}
+
+// Export 在后台按页读取日志并流式写入文件，返回任务信息，可通过 GetExportJob 查询进度
+func (ls *LogService) Export(req ExportRequest) (ExportJob, error) {
+	format := strings.ToLower(strings.TrimSpace(req.Format))
+	switch format {
+	case "csv", "json":
+	case "parquet":
+		return ExportJob{}, errors.New("暂不支持 Parquet 格式，请选择 CSV 或 JSON")
+	default:
+		return ExportJob{}, fmt.Errorf("不支持的导出格式: %s", req.Format)
+	}
+	// 提前校验过滤条件，避免任务启动后才失败
+	if _, err := logQueryOptions(req.Query); err != nil {
+		return ExportJob{}, err
+	}
+	dir, err := exportDir(req.Dir)
+	if err != nil {
+		return ExportJob{}, err
+	}
+	now := time.Now()
+	base := "code-switch-logs-" + now.Format("20060102-150405")
+	job := &ExportJob{
+		ID:        fmt.Sprintf("export-%d", now.UnixNano()),
+		Format:    format,
+		Status:    "running",
+		LogsFile:  filepath.Join(dir, base+"."+format),
+		StatsFile: filepath.Join(dir, base+"-stats."+format),
+		StartedAt: now,
+	}
+	logExports.Lock()
+	logExports.jobs[job.ID] = job
+	snapshot := *job
+	logExports.Unlock()
+
+	go ls.runExport(job.ID, req.Query, format, snapshot.LogsFile, snapshot.StatsFile)
+	return snapshot, nil
+}
+
+// GetExportJob 返回导出任务的当前状态
+func (ls *LogService) GetExportJob(id string) (ExportJob, error) {
+	logExports.Lock()
+	defer logExports.Unlock()
+	job, ok := logExports.jobs[id]
+	if !ok {
+		return ExportJob{}, fmt.Errorf("导出任务 %s 不存在", id)
+	}
+	return *job, nil
+}
+
+// ListExportJobs 返回本次运行期间的导出任务，最新的在前
+func (ls *LogService) ListExportJobs() []ExportJob {
+	logExports.Lock()
+	defer logExports.Unlock()
+	jobs := make([]ExportJob, 0, len(logExports.jobs))
+	for _, job := range logExports.jobs {
+		jobs = append(jobs, *job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].StartedAt.After(jobs[j].StartedAt)
+	})
+	return jobs
+}
+
+func (ls *LogService) runExport(id string, query LogQuery, format, logsFile, statsFile string) {
+	rows, stats, err := ls.exportLogs(query, format, logsFile, func(rows int) {
+		logExports.Lock()
+		logExports.jobs[id].Rows = rows
+		logExports.Unlock()
+	})
+	if err == nil {
+		err = writeExportStats(stats, format, statsFile)
+	}
+	logExports.Lock()
+	defer logExports.Unlock()
+	job := logExports.jobs[id]
+	job.Rows = rows
+	job.FinishedAt = time.Now()
+	if err != nil {
+		job.Status = "failed"
+		job.Error = err.Error()
+		return
+	}
+	job.Status = "done"
+}
+
+// exportLogs 逐页写入日志，同时累计汇总统计
+func (ls *LogService) exportLogs(query LogQuery, format, path string, progress func(int)) (int, []ExportStat, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.Close()
+	buffered := bufio.NewWriter(file)
+	writer := newExportWriter(format, buffered, exportLogHeader)
+
+	aggregates := make(map[string]*ExportStat)
+	rows := 0
+	query.Cursor = 0
+	query.Limit = exportPageSize
+	for {
+		page, err := ls.SearchRequestLogs(query)
+		if err != nil {
+			return rows, nil, err
+		}
+		for _, entry := range page.Items {
+			if err := writer.write(entry, exportLogRow(entry)); err != nil {
+				return rows, nil, err
+			}
+			accumulateExportStat(aggregates, entry)
+			rows++
+		}
+		progress(rows)
+		if !page.HasMore {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	if err := writer.close(); err != nil {
+		return rows, nil, err
+	}
+	if err := buffered.Flush(); err != nil {
+		return rows, nil, err
+	}
+
+	stats := make([]ExportStat, 0, len(aggregates))
+	for _, stat := range aggregates {
+		stats = append(stats, *stat)
+	}
+	sortExportStats(stats)
+	return rows, stats, nil
+}
+
+// sortExportStats 按日期、平台、provider、模型排序
+func sortExportStats(stats []ExportStat) {
+	sort.Slice(stats, func(i, j int) bool {
+		a, b := stats[i], stats[j]
+		if a.Day != b.Day {
+			return a.Day < b.Day
+		}
+		if a.Platform != b.Platform {
+			return a.Platform < b.Platform
+		}
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		return a.Model < b.Model
+	})
+}
+
+func writeExportStats(stats []ExportStat, format, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	buffered := bufio.NewWriter(file)
+	writer := newExportWriter(format, buffered, exportStatHeader)
+	for _, stat := range stats {
+		if err := writer.write(stat, exportStatRow(stat)); err != nil {
+			return err
+		}
+	}
+	if err := writer.close(); err != nil {
+		return err
+	}
+	return buffered.Flush()
+}
+
+func accumulateExportStat(aggregates map[string]*ExportStat, entry ReqeustLog) {
+	day := entry.CreatedAt
+	if t, err := time.Parse(timeLayout, entry.CreatedAt); err == nil {
+		day = t.In(time.Local).Format("2006-01-02")
+	}
+	key := strings.Join([]string{day, entry.Platform, entry.Provider, entry.Model}, "\x00")
+	stat := aggregates[key]
+	if stat == nil {
+		stat = &ExportStat{Day: day, Platform: entry.Platform, Provider: entry.Provider, Model: entry.Model}
+		aggregates[key] = stat
+	}
+	stat.Requests++
+	if entry.HttpCode < 200 || entry.HttpCode >= 300 {
+		stat.Errors++
+	}
+	stat.InputTokens += int64(entry.InputTokens)
+	stat.OutputTokens += int64(entry.OutputTokens)
+	stat.CacheCreateTokens += int64(entry.CacheCreateTokens)
+	stat.CacheReadTokens += int64(entry.CacheReadTokens)
+	stat.ReasoningTokens += int64(entry.ReasoningTokens)
+	stat.TotalCost += entry.TotalCost
+}
+
+func exportLogRow(entry ReqeustLog) []string {
+	return []string{
+		strconv.FormatInt(entry.ID, 10),
+		entry.CreatedAt,
+		entry.Platform,
+		entry.Provider,
+		entry.Model,
+		entry.RequestedModel,
+		entry.SessionID,
+		strconv.Itoa(entry.HttpCode),
+		strconv.Itoa(entry.InputTokens),
+		strconv.Itoa(entry.OutputTokens),
+		strconv.Itoa(entry.CacheCreateTokens),
+		strconv.Itoa(entry.CacheReadTokens),
+		strconv.Itoa(entry.ReasoningTokens),
+		strconv.FormatBool(entry.IsStream),
+		strconv.FormatFloat(entry.DurationSec, 'f', 3, 64),
+		strconv.FormatFloat(entry.FirstTokenSec, 'f', 3, 64),
+		strconv.FormatFloat(entry.TotalCost, 'f', 6, 64),
+		entry.ErrorMessage,
+	}
+}
+
+func exportStatRow(stat ExportStat) []string {
+	return []string{
+		stat.Day,
+		stat.Platform,
+		stat.Provider,
+		stat.Model,
+		strconv.FormatInt(stat.Requests, 10),
+		strconv.FormatInt(stat.Errors, 10),
+		strconv.FormatInt(stat.InputTokens, 10),
+		strconv.FormatInt(stat.OutputTokens, 10),
+		strconv.FormatInt(stat.CacheCreateTokens, 10),
+		strconv.FormatInt(stat.CacheReadTokens, 10),
+		strconv.FormatInt(stat.ReasoningTokens, 10),
+		strconv.FormatFloat(stat.TotalCost, 'f', 6, 64),
+	}
+}
+
+// exportWriter 把记录流式写为 CSV 行或 JSON 数组元素
+type exportWriter struct {
+	csv   *csv.Writer
+	out   io.Writer
+	count int
+}
+
+func newExportWriter(format string, out io.Writer, header []string) *exportWriter {
+	if format == "csv" {
+		writer := csv.NewWriter(out)
+		_ = writer.Write(header)
+		return &exportWriter{csv: writer}
+	}
+	return &exportWriter{out: out}
+}
+
+func (ew *exportWriter) write(value any, row []string) error {
+	if ew.csv != nil {
+		return ew.csv.Write(row)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	prefix := ",\n  "
+	if ew.count == 0 {
+		prefix = "[\n  "
+	}
+	ew.count++
+	if _, err := io.WriteString(ew.out, prefix); err != nil {
+		return err
+	}
+	_, err = ew.out.Write(data)
+	return err
+}
+
+func (ew *exportWriter) close() error {
+	if ew.csv != nil {
+		ew.csv.Flush()
+		return ew.csv.Error()
+	}
+	suffix := "\n]\n"
+	if ew.count == 0 {
+		suffix = "[]\n"
+	}
+	_, err := io.WriteString(ew.out, suffix)
+	return err
+}
+
+func exportDir(dir string) (string, error) {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		dir = filepath.Join(dataDir(), "exports")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestExportWriterJSONArray(t *testing.T) {
+	var buf bytes.Buffer
+	writer := newExportWriter("json", &buf, exportStatHeader)
+	if err := writer.close(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(buf.String()) != "[]" {
+		t.Fatalf("expected empty array, got %q", buf.String())
+	}
+
+	buf.Reset()
+	writer = newExportWriter("json", &buf, exportStatHeader)
+	for _, stat := range []ExportStat{{Day: "2026-10-01", Requests: 2}, {Day: "2026-10-02", Requests: 3}} {
+		if err := writer.write(stat, exportStatRow(stat)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.close(); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []ExportStat
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid json %q: %v", buf.String(), err)
+	}
+	if len(decoded) != 2 || decoded[1].Requests != 3 {
+		t.Fatalf("unexpected stats: %+v", decoded)
+	}
+}
+
+func TestExportWriterCSV(t *testing.T) {
+	var buf bytes.Buffer
+	writer := newExportWriter("csv", &buf, exportLogHeader)
+	entry := ReqeustLog{ID: 7, Platform: "claude", HttpCode: 500, ErrorMessage: "bad, \"quoted\""}
+	if err := writer.write(entry, exportLogRow(entry)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.close(); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "id,created_at") || !strings.HasSuffix(lines[1], `"bad, ""quoted"""`) {
+		t.Fatalf("unexpected csv: %q", buf.String())
+	}
+}
+
+func TestAccumulateExportStat(t *testing.T) {
+	aggregates := map[string]*ExportStat{}
+	accumulateExportStat(aggregates, ReqeustLog{Platform: "codex", Provider: "a", Model: "m", HttpCode: 200, InputTokens: 10, TotalCost: 1})
+	accumulateExportStat(aggregates, ReqeustLog{Platform: "codex", Provider: "a", Model: "m", HttpCode: 502, InputTokens: 5, TotalCost: 0.5})
+	if len(aggregates) != 1 {
+		t.Fatalf("expected one aggregate, got %d", len(aggregates))
+	}
+	for _, stat := range aggregates {
+		if stat.Requests != 2 || stat.Errors != 1 || stat.InputTokens != 15 || stat.TotalCost != 1.5 {
+			t.Fatalf("unexpected aggregate: %+v", stat)
+		}
+	}
+}
@@ -0,0 +1,402 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daodao97/xgo/xdb"
+)
+
+const (
+	logMaintenanceInterval  = time.Hour
+	minLogArchiveAfterDays  = 30
+	logArchiveMonthLayout   = "200601"
+	logArchiveCreatedLayout = "2006-01"
+)
+
+// LogRetentionPolicy 请求日志的归档与清理策略，对每个 profile 的日志表分别生效。
+// 超过 ArchiveAfterDays 的日志按月移入 <日志表>_YYYYMM 归档表（如 request_log_202401），统计查询只扫描近期数据；
+// RetentionDays > 0 时，更早的日志（含归档表）会被删除
+type LogRetentionPolicy struct {
+	ArchiveAfterDays int `json:"archive_after_days"`
+	// 0 表示永久保留
+	RetentionDays int `json:"retention_days"`
+	// VACUUM 的间隔（小时），ANALYZE 在每次归档后执行
+	VacuumIntervalHours int       `json:"vacuum_interval_hours"`
+	LastVacuumAt        time.Time `json:"last_vacuum_at,omitempty"`
+}
+
+// LogArchive 一个按月归档的日志表，Source 为被归档的日志表
+type LogArchive struct {
+	Table  string `json:"table"`
+	Source string `json:"source"`
+	Month  string `json:"month"`
+	Rows   int64  `json:"rows"`
+}
+
+// LogMaintenanceResult 一次维护的结果
+type LogMaintenanceResult struct {
+	Archived      int64    `json:"archived"`
+	Pruned        int64    `json:"pruned"`
+	DroppedTables []string `json:"dropped_tables"`
+	Vacuumed      bool     `json:"vacuumed"`
+}
+
+type LogMaintenanceService struct {
+	appSettings *AppSettingsService
+	mu          sync.Mutex
+	runMu       sync.Mutex
+	stopCh      chan struct{}
+}
+
+func NewLogMaintenanceService(appSettings *AppSettingsService) *LogMaintenanceService {
+	return &LogMaintenanceService{appSettings: appSettings}
+}
+
+func defaultLogRetentionPolicy() LogRetentionPolicy {
+	return LogRetentionPolicy{
+		ArchiveAfterDays:    90,
+		VacuumIntervalHours: 24 * 7,
+	}
+}
+
+func normalizeLogRetentionPolicy(policy LogRetentionPolicy) LogRetentionPolicy {
+	defaults := defaultLogRetentionPolicy()
+	if policy.ArchiveAfterDays <= 0 {
+		policy.ArchiveAfterDays = defaults.ArchiveAfterDays
+	}
+	if policy.ArchiveAfterDays < minLogArchiveAfterDays {
+		policy.ArchiveAfterDays = minLogArchiveAfterDays
+	}
+	if policy.RetentionDays < 0 {
+		policy.RetentionDays = 0
+	}
+	if policy.VacuumIntervalHours <= 0 {
+		policy.VacuumIntervalHours = defaults.VacuumIntervalHours
+	}
+	return policy
+}
+
+// GetPolicy 返回日志归档与清理策略
+func (lms *LogMaintenanceService) GetPolicy() (LogRetentionPolicy, error) {
+	settings, err := lms.appSettings.GetAppSettings()
+	if err != nil {
+		return LogRetentionPolicy{}, err
+	}
+	return normalizeLogRetentionPolicy(settings.LogRetention), nil
+}
+
+// SavePolicy 保存日志归档与清理策略，下次维护时生效
+func (lms *LogMaintenanceService) SavePolicy(policy LogRetentionPolicy) (LogRetentionPolicy, error) {
+	policy = normalizeLogRetentionPolicy(policy)
+	settings, err := lms.appSettings.update(func(settings *AppSettings) {
+		policy.LastVacuumAt = settings.LogRetention.LastVacuumAt
+		settings.LogRetention = policy
+	})
+	if err != nil {
+		return policy, err
+	}
+	return normalizeLogRetentionPolicy(settings.LogRetention), nil
+}
+
+// ListArchives 列出所有 profile 已有的归档表，按日志表与月份升序
+func (lms *LogMaintenanceService) ListArchives() ([]LogArchive, error) {
+	db, err := xdb.DB("default")
+	if err != nil {
+		return nil, err
+	}
+	archives := make([]LogArchive, 0)
+	for _, source := range maintainedLogTables() {
+		tables, err := logArchiveTables(db, source)
+		if err != nil {
+			return nil, err
+		}
+		for _, table := range tables {
+			archive := LogArchive{Table: table, Source: source, Month: logArchiveMonth(source, table)}
+			if err := db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&archive.Rows); err != nil {
+				return nil, err
+			}
+			archives = append(archives, archive)
+		}
+	}
+	return archives, nil
+}
+
+// RunMaintenance 立即执行归档、清理与 VACUUM
+func (lms *LogMaintenanceService) RunMaintenance() (LogMaintenanceResult, error) {
+	return lms.run(true)
+}
+
+// Start 启动定时维护
+func (lms *LogMaintenanceService) Start() error {
+	lms.mu.Lock()
+	defer lms.mu.Unlock()
+	if lms.stopCh != nil {
+		return nil
+	}
+	stopCh := make(chan struct{})
+	lms.stopCh = stopCh
+	go func() {
+		ticker := time.NewTicker(logMaintenanceInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := lms.run(false); err != nil {
+					fmt.Printf("[WARN] 日志维护失败: %v\n", err)
+				}
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (lms *LogMaintenanceService) Stop() error {
+	lms.mu.Lock()
+	defer lms.mu.Unlock()
+	if lms.stopCh != nil {
+		close(lms.stopCh)
+		lms.stopCh = nil
+	}
+	return nil
+}
+
+// run 归档与清理每次都执行；VACUUM 到达间隔或 forceVacuum 时执行
+func (lms *LogMaintenanceService) run(forceVacuum bool) (LogMaintenanceResult, error) {
+	lms.runMu.Lock()
+	defer lms.runMu.Unlock()
+
+	result := LogMaintenanceResult{DroppedTables: []string{}}
+	policy, err := lms.GetPolicy()
+	if err != nil {
+		return result, err
+	}
+	db, err := xdb.DB("default")
+	if err != nil {
+		return result, err
+	}
+	now := time.Now().UTC()
+
+	for _, source := range maintainedLogTables() {
+		if policy.RetentionDays > 0 {
+			pruned, dropped, err := pruneRequestLogs(db, source, now.AddDate(0, 0, -policy.RetentionDays))
+			result.Pruned += pruned
+			result.DroppedTables = append(result.DroppedTables, dropped...)
+			if err != nil {
+				return result, err
+			}
+		}
+		archived, err := archiveRequestLogs(db, source, now.AddDate(0, 0, -policy.ArchiveAfterDays))
+		result.Archived += archived
+		if err != nil {
+			return result, err
+		}
+	}
+	if result.Archived > 0 || result.Pruned > 0 || len(result.DroppedTables) > 0 {
+		if _, err := db.Exec("ANALYZE"); err != nil {
+			return result, err
+		}
+	}
+
+	due := now.Sub(policy.LastVacuumAt) >= time.Duration(policy.VacuumIntervalHours)*time.Hour
+	if !forceVacuum && !due {
+		return result, nil
+	}
+	if _, err := db.Exec("VACUUM"); err != nil {
+		return result, err
+	}
+	if _, err := db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return result, err
+	}
+	result.Vacuumed = true
+	_, err = lms.appSettings.update(func(settings *AppSettings) {
+		settings.LogRetention.LastVacuumAt = now
+	})
+	return result, err
+}
+
+// maintainedLogTables 需要维护的日志表：默认的 request_log 与各 profile 的日志表
+func maintainedLogTables() []string {
+	tables := []string{profileRequestLogTable(defaultProfileID)}
+	state, err := loadProfileState()
+	if err != nil {
+		return tables
+	}
+	for _, profile := range state.Profiles {
+		if profile.ID != defaultProfileID {
+			tables = append(tables, profileRequestLogTable(profile.ID))
+		}
+	}
+	return tables
+}
+
+// archiveRequestLogs 把 source 中 cutoff 之前的日志按月（UTC）移入归档表，每个月在一个事务内完成
+func archiveRequestLogs(db *sql.DB, source string, cutoff time.Time) (int64, error) {
+	cutoffText := cutoff.UTC().Format(timeLayout)
+	rows, err := db.Query(`SELECT DISTINCT substr(created_at, 1, 7) FROM `+source+` WHERE created_at < ?`, cutoffText)
+	if err != nil {
+		if isNoSuchTableErr(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	var months []string
+	for rows.Next() {
+		var month string
+		if err := rows.Scan(&month); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		months = append(months, month)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var archived int64
+	for _, month := range months {
+		start, err := time.Parse(logArchiveCreatedLayout, month)
+		if err != nil {
+			continue
+		}
+		table := source + "_" + start.Format(logArchiveMonthLayout)
+		if err := ensureLogTableSchema(db, table); err != nil {
+			return archived, err
+		}
+		columns, err := logTableColumns(db, source)
+		if err != nil {
+			return archived, err
+		}
+		end := start.AddDate(0, 1, 0).Format(timeLayout)
+		if end > cutoffText {
+			end = cutoffText
+		}
+		count, err := moveRequestLogs(db, source, table, columns, start.Format(timeLayout), end)
+		if err != nil {
+			return archived, err
+		}
+		archived += count
+	}
+	return archived, nil
+}
+
+// moveRequestLogs 把 [start, end) 的日志复制到归档表后从 source 删除
+func moveRequestLogs(db *sql.DB, source, table string, columns []string, start, end string) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	columnList := strings.Join(columns, ", ")
+	insert := fmt.Sprintf(`INSERT OR IGNORE INTO %s (%s) SELECT %s FROM %s WHERE created_at >= ? AND created_at < ?`,
+		table, columnList, columnList, source)
+	if _, err := tx.Exec(insert, start, end); err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec(`DELETE FROM `+source+` WHERE created_at >= ? AND created_at < ?`, start, end)
+	if err != nil {
+		return 0, err
+	}
+	count, _ := res.RowsAffected()
+	return count, tx.Commit()
+}
+
+// pruneRequestLogs 删除 source 及其归档表中 cutoff 之前的日志：整月过期的归档表直接删除，其余按行删除
+func pruneRequestLogs(db *sql.DB, source string, cutoff time.Time) (int64, []string, error) {
+	cutoffText := cutoff.UTC().Format(timeLayout)
+	dropped := []string{}
+	var pruned int64
+	res, err := db.Exec(`DELETE FROM `+source+` WHERE created_at < ?`, cutoffText)
+	if err != nil && !isNoSuchTableErr(err) {
+		return 0, dropped, err
+	}
+	if err == nil {
+		pruned, _ = res.RowsAffected()
+	}
+
+	tables, err := logArchiveTables(db, source)
+	if err != nil {
+		return pruned, dropped, err
+	}
+	for _, table := range tables {
+		start, err := time.Parse(logArchiveMonthLayout, logArchiveMonth(source, table))
+		if err != nil {
+			continue
+		}
+		if !start.AddDate(0, 1, 0).After(cutoff) {
+			var rows int64
+			if err := db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&rows); err != nil {
+				return pruned, dropped, err
+			}
+			if _, err := db.Exec("DROP TABLE " + table); err != nil {
+				return pruned, dropped, err
+			}
+			pruned += rows
+			dropped = append(dropped, table)
+			continue
+		}
+		if start.After(cutoff) {
+			continue
+		}
+		res, err := db.Exec("DELETE FROM "+table+" WHERE created_at < ?", cutoffText)
+		if err != nil {
+			return pruned, dropped, err
+		}
+		count, _ := res.RowsAffected()
+		pruned += count
+	}
+	return pruned, dropped, nil
+}
+
+// logArchiveTables 返回 source 的 <source>_YYYYMM 形式的归档表，按月份升序
+func logArchiveTables(db *sql.DB, source string) ([]string, error) {
+	pattern := strings.ReplaceAll(source, "_", `\_`) + `\_%`
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name LIKE ? ESCAPE '\'`, pattern)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tables := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if _, err := time.Parse(logArchiveMonthLayout, logArchiveMonth(source, name)); err == nil {
+			tables = append(tables, name)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Strings(tables)
+	return tables, nil
+}
+
+func logArchiveMonth(source, table string) string {
+	return strings.TrimPrefix(table, source+"_")
+}
+
+func logTableColumns(db *sql.DB, table string) ([]string, error) {
+	rows, err := db.Query(fmt.Sprintf("SELECT name FROM pragma_table_info('%s')", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var columns []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns = append(columns, name)
+	}
+	return columns, rows.Err()
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/daodao97/xgo/xdb"
+)
+
+func insertTestLogs(t *testing.T, table string, times ...string) {
+	t.Helper()
+	for _, createdAt := range times {
+		if _, err := xdb.New(table).Insert(xdb.Record{"platform": "claude", "provider": "relay", "created_at": createdAt}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func countTestLogs(t *testing.T, db *sql.DB, table string) int {
+	t.Helper()
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestArchiveRequestLogsMonthBoundary(t *testing.T) {
+	useTestDB(t)
+	db, err := xdb.DB("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	insertTestLogs(t, "request_log",
+		"2024-03-31 23:59:59",
+		"2024-04-01 00:00:00",
+		"2024-04-14 23:59:59",
+		"2024-04-15 00:00:00",
+	)
+	archived, err := archiveRequestLogs(db, "request_log", time.Date(2024, 4, 15, 0, 0, 0, 0, time.UTC))
+	if err != nil || archived != 3 {
+		t.Fatalf("归档 %d 行：%v", archived, err)
+	}
+	if got := countTestLogs(t, db, "request_log_202403"); got != 1 {
+		t.Fatalf("3 月最后一秒应归入 202403，实际 %d 行", got)
+	}
+	if got := countTestLogs(t, db, "request_log_202404"); got != 2 {
+		t.Fatalf("4 月 1 日零点起应归入 202404，实际 %d 行", got)
+	}
+	if got := countTestLogs(t, db, "request_log"); got != 1 {
+		t.Fatalf("截止时间当刻及之后的日志应保留，实际剩余 %d 行", got)
+	}
+}
+
+func TestPruneRequestLogsRetentionCutoff(t *testing.T) {
+	useTestDB(t)
+	db, err := xdb.DB("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []string{"request_log_202403", "request_log_202404", "request_log_202405"} {
+		if err := ensureLogTableSchema(db, table); err != nil {
+			t.Fatal(err)
+		}
+	}
+	insertTestLogs(t, "request_log_202403", "2024-03-02 10:00:00", "2024-03-30 10:00:00")
+	insertTestLogs(t, "request_log_202404", "2024-04-10 10:00:00", "2024-04-20 10:00:00")
+	insertTestLogs(t, "request_log_202405", "2024-05-01 10:00:00")
+	insertTestLogs(t, "request_log", "2024-04-14 10:00:00", "2024-04-16 10:00:00")
+
+	pruned, dropped, err := pruneRequestLogs(db, "request_log", time.Date(2024, 4, 15, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned != 4 {
+		t.Fatalf("应删除 4 行，实际 %d", pruned)
+	}
+	if len(dropped) != 1 || dropped[0] != "request_log_202403" {
+		t.Fatalf("只有整月过期的归档表应被删除：%v", dropped)
+	}
+	if got := countTestLogs(t, db, "request_log_202404"); got != 1 {
+		t.Fatalf("202404 应只保留截止时间之后的 1 行，实际 %d", got)
+	}
+	if got := countTestLogs(t, db, "request_log_202405"); got != 1 {
+		t.Fatalf("202405 不应受影响，实际 %d", got)
+	}
+	if got := countTestLogs(t, db, "request_log"); got != 1 {
+		t.Fatalf("request_log 应只保留截止时间之后的 1 行，实际 %d", got)
+	}
+}
+
+func TestArchiveFailureKeepsSourceRows(t *testing.T) {
+	useTestDB(t)
+	db, err := xdb.DB("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	insertTestLogs(t, "request_log", "2024-03-10 10:00:00", "2024-03-11 10:00:00")
+	if err := ensureLogTableSchema(db, "request_log_202403"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TRIGGER fail_archive BEFORE INSERT ON request_log_202403 BEGIN SELECT RAISE(ABORT, 'archive failed'); END`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := archiveRequestLogs(db, "request_log", time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)); err == nil {
+		t.Fatal("写入归档表失败时应返回错误")
+	}
+	if got := countTestLogs(t, db, "request_log"); got != 2 {
+		t.Fatalf("归档失败时不应删除源数据，实际剩余 %d 行", got)
+	}
+	if got := countTestLogs(t, db, "request_log_202403"); got != 0 {
+		t.Fatalf("归档失败时归档表不应有数据，实际 %d 行", got)
+	}
+}
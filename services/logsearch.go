@@ -0,0 +1,188 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/daodao97/xgo/xdb"
+	"github.com/tidwall/gjson"
+)
+
+const relaySessionIDKey = "relay_session_id"
+
+// LogQuery 日志检索条件，空值表示不过滤；时间格式为 "2006-01-02 15:04:05"（本地时间）
+type LogQuery struct {
+	Platform string `json:"platform"`
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	// success / error，或具体的 HTTP 状态码
+	Status    string `json:"status"`
+	ErrorText string `json:"error_text"`
+	Since     string `json:"since"`
+	Until     string `json:"until"`
+	SessionID string `json:"session_id"`
+	// 上一页最后一条日志的 id，0 表示第一页
+	Cursor int64 `json:"cursor"`
+	Limit  int   `json:"limit"`
+}
+
+type LogPage struct {
+	Items      []ReqeustLog `json:"items"`
+	NextCursor int64        `json:"next_cursor"`
+	HasMore    bool         `json:"has_more"`
+}
+
+// SearchRequestLogs 按条件检索日志，基于 id 的游标分页，翻页开销不随偏移量增长
+func (ls *LogService) SearchRequestLogs(query LogQuery) (LogPage, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	if limit > 500 {
+		limit = 500
+	}
+	page := LogPage{Items: []ReqeustLog{}}
+	options, err := logQueryOptions(query)
+	if err != nil {
+		return page, err
+	}
+	// 多取一条用于判断是否还有下一页
+	options = append(options, xdb.OrderByDesc("id"), xdb.Limit(limit+1))
+	records, err := xdb.New(requestLogTable()).Selects(options...)
+	if err != nil {
+		if errors.Is(err, xdb.ErrNotFound) || isNoSuchTableErr(err) {
+			return page, nil
+		}
+		return page, err
+	}
+	if len(records) > limit {
+		page.HasMore = true
+		records = records[:limit]
+	}
+	for _, record := range records {
+		logEntry := requestLogFromRecord(record)
+		ls.decorateCost(&logEntry)
+		page.Items = append(page.Items, logEntry)
+	}
+	if page.HasMore && len(page.Items) > 0 {
+		page.NextCursor = page.Items[len(page.Items)-1].ID
+	}
+	return page, nil
+}
+
+func logQueryOptions(query LogQuery) ([]xdb.Option, error) {
+	options := make([]xdb.Option, 0)
+	if query.Cursor > 0 {
+		options = append(options, xdb.WhereLt("id", query.Cursor))
+	}
+	if query.Platform != "" {
+		options = append(options, xdb.WhereEq("platform", query.Platform))
+	}
+	if query.Provider != "" {
+		options = append(options, xdb.WhereEq("provider", query.Provider))
+	}
+	if model := strings.TrimSpace(query.Model); model != "" {
+		options = append(options, xdb.WhereGroup(
+			xdb.WhereEq("model", model),
+			xdb.WhereOrEq("requested_model", model),
+		))
+	}
+	if query.SessionID != "" {
+		options = append(options, xdb.WhereEq("session_id", query.SessionID))
+	}
+	if text := strings.TrimSpace(query.ErrorText); text != "" {
+		options = append(options, xdb.WhereLike("error_message", "%"+text+"%"))
+	}
+	switch status := strings.TrimSpace(query.Status); status {
+	case "":
+	case "success":
+		options = append(options, xdb.WhereGte("http_code", http.StatusOK), xdb.WhereLt("http_code", http.StatusMultipleChoices))
+	case "error":
+		options = append(options, xdb.WhereGroup(
+			xdb.WhereLt("http_code", http.StatusOK),
+			xdb.WhereOrGe("http_code", http.StatusMultipleChoices),
+		))
+	default:
+		code, err := strconv.Atoi(status)
+		if err != nil || code <= 0 {
+			return nil, errors.New("无效的状态过滤条件: " + status)
+		}
+		options = append(options, xdb.WhereEq("http_code", code))
+	}
+	if query.Since != "" {
+		since, err := parseLocalTime(query.Since)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, xdb.WhereGte("created_at", since.UTC().Format(timeLayout)))
+	}
+	if query.Until != "" {
+		until, err := parseLocalTime(query.Until)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, xdb.WhereLt("created_at", until.UTC().Format(timeLayout)))
+	}
+	return options, nil
+}
+
+func requestLogFromRecord(record xdb.Record) ReqeustLog {
+	return ReqeustLog{
+		ID:                record.GetInt64("id"),
+		RequestID:         record.GetString("request_id"),
+		SessionID:         record.GetString("session_id"),
+		Platform:          record.GetString("platform"),
+		Model:             record.GetString("model"),
+		RequestedModel:    record.GetString("requested_model"),
+		Provider:          record.GetString("provider"),
+		HttpCode:          record.GetInt("http_code"),
+		InputTokens:       record.GetInt("input_tokens"),
+		OutputTokens:      record.GetInt("output_tokens"),
+		CacheCreateTokens: record.GetInt("cache_create_tokens"),
+		CacheReadTokens:   record.GetInt("cache_read_tokens"),
+		ReasoningTokens:   record.GetInt("reasoning_tokens"),
+		CreatedAt:         record.GetString("created_at"),
+		IsStream:          record.GetBool("is_stream"),
+		DurationSec:       record.GetFloat64("duration_sec"),
+		FirstTokenSec:     record.GetFloat64("first_token_sec"),
+		ErrorMessage:      record.GetString("error_message"),
+	}
+}
+
+func parseLocalTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{timeLayout, "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法解析时间: %s", value)
+}
+
+// relaySessionID 从客户端请求中提取会话标识：Claude Code 的 metadata.user_id 带有 session 段，Codex 通过请求头传递，
+// 都没有时退化为系统提示词哈希
+func relaySessionID(kind string, headers map[string]string, body []byte) string {
+	if kind == "codex" {
+		for _, key := range []string{"session_id", "conversation_id"} {
+			if value := strings.TrimSpace(headers[http.CanonicalHeaderKey(key)]); value != "" {
+				return value
+			}
+		}
+		if key := gjson.GetBytes(body, "prompt_cache_key").String(); key != "" {
+			return key
+		}
+		return systemPromptSessionID(gjson.GetBytes(body, "instructions").String())
+	}
+	userID := gjson.GetBytes(body, "metadata.user_id").String()
+	if _, session, ok := strings.Cut(userID, "_session_"); ok && session != "" {
+		return session
+	}
+	if userID != "" {
+		return userID
+	}
+	return systemPromptSessionID(gjson.GetBytes(body, "system").Raw)
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/daodao97/xgo/xdb"
+)
+
+func TestSearchRequestLogsKeysetSameCreatedAt(t *testing.T) {
+	useTestDB(t)
+	const total = 23
+	for i := 0; i < total; i++ {
+		platform := "claude"
+		if i%4 == 3 {
+			platform = "codex"
+		}
+		// 所有日志共用同一个 created_at，翻页只能依赖 id
+		if _, err := xdb.New("request_log").Insert(xdb.Record{
+			"platform":   platform,
+			"provider":   "relay",
+			"http_code":  200,
+			"created_at": "2024-05-01 12:00:00",
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ls := &LogService{}
+	seen := make(map[int64]bool)
+	var ids []int64
+	query := LogQuery{Platform: "claude", Limit: 4}
+	for pages := 0; ; pages++ {
+		if pages > total {
+			t.Fatal("分页没有结束")
+		}
+		page, err := ls.SearchRequestLogs(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range page.Items {
+			if item.Platform != "claude" {
+				t.Fatalf("过滤条件失效：%+v", item)
+			}
+			if seen[item.ID] {
+				t.Fatalf("日志 %d 在多页中重复出现", item.ID)
+			}
+			seen[item.ID] = true
+			ids = append(ids, item.ID)
+		}
+		if !page.HasMore {
+			if page.NextCursor != 0 {
+				t.Fatalf("最后一页不应返回游标：%d", page.NextCursor)
+			}
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+
+	var want []int64
+	for id := int64(total); id >= 1; id-- {
+		if (id-1)%4 != 3 {
+			want = append(want, id)
+		}
+	}
+	if len(ids) != len(want) {
+		t.Fatalf("应返回 %d 条，实际 %d 条：%v", len(want), len(ids), ids)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("第 %d 条应为 id %d，实际 %d（存在遗漏或乱序）：%v", i, want[i], ids[i], ids)
+		}
+	}
+}
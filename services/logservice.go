@@ -5,6 +5,8 @@ import (
 	"log"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	modelpricing "codeswitch/resources/model-pricing"
@@ -15,15 +17,28 @@ import (
 const timeLayout = "2006-01-02 15:04:05"
 
 type LogService struct {
-	pricing *modelpricing.Service
+	pricing atomic.Pointer[modelpricing.Service]
+
+	streamMu      sync.Mutex
+	streamHandler func(LogStreamBatch)
+	stopCh        chan struct{}
 }
 
 func NewLogService() *LogService {
-	svc, err := modelpricing.DefaultService()
+	svc, err := loadLocalPricing()
 	if err != nil {
 		log.Printf("pricing service init failed: %v", err)
 	}
-	return &LogService{pricing: svc}
+	ls := &LogService{}
+	ls.setPricing(svc)
+	return ls
+}
+
+// setPricing 替换内置价格（导入最新价格后调用）
+func (ls *LogService) setPricing(svc *modelpricing.Service) {
+	if svc != nil {
+		ls.pricing.Store(svc)
+	}
 }
 
 func (ls *LogService) ListRequestLogs(platform string, provider string, limit int) ([]ReqeustLog, error) {
@@ -33,7 +48,7 @@ func (ls *LogService) ListRequestLogs(platform string, provider string, limit in
 	if limit > 1000 {
 		limit = 1000
 	}
-	model := xdb.New("request_log")
+	model := xdb.New(requestLogTable())
 	options := []xdb.Option{
 		xdb.OrderByDesc("id"),
 		xdb.Limit(limit),
@@ -50,21 +65,7 @@ func (ls *LogService) ListRequestLogs(platform string, provider string, limit in
 	}
 	logs := make([]ReqeustLog, 0, len(records))
 	for _, record := range records {
-		logEntry := ReqeustLog{
-			ID:                record.GetInt64("id"),
-			Platform:          record.GetString("platform"),
-			Model:             record.GetString("model"),
-			Provider:          record.GetString("provider"),
-			HttpCode:          record.GetInt("http_code"),
-			InputTokens:       record.GetInt("input_tokens"),
-			OutputTokens:      record.GetInt("output_tokens"),
-			CacheCreateTokens: record.GetInt("cache_create_tokens"),
-			CacheReadTokens:   record.GetInt("cache_read_tokens"),
-			ReasoningTokens:   record.GetInt("reasoning_tokens"),
-			CreatedAt:         record.GetString("created_at"),
-			IsStream:          record.GetBool("is_stream"),
-			DurationSec:       record.GetFloat64("duration_sec"),
-		}
+		logEntry := requestLogFromRecord(record)
 		ls.decorateCost(&logEntry)
 		logs = append(logs, logEntry)
 	}
@@ -72,7 +73,7 @@ func (ls *LogService) ListRequestLogs(platform string, provider string, limit in
 }
 
 func (ls *LogService) ListProviders(platform string) ([]string, error) {
-	model := xdb.New("request_log")
+	model := xdb.New(requestLogTable())
 	options := []xdb.Option{
 		xdb.Field("DISTINCT provider as provider"),
 		xdb.WhereNotEq("provider", ""),
@@ -107,10 +108,11 @@ func (ls *LogService) HeatmapStats(days int) ([]HeatmapStat, error) {
 	if totalHours > 1 {
 		rangeStart = rangeStart.Add(-time.Duration(totalHours-1) * time.Hour)
 	}
-	model := xdb.New("request_log")
+	model := xdb.New(requestLogTable())
 	options := []xdb.Option{
 		xdb.WhereGe("created_at", rangeStart.Format(timeLayout)),
 		xdb.Field(
+			"provider",
 			"model",
 			"input_tokens",
 			"output_tokens",
@@ -156,7 +158,7 @@ func (ls *LogService) HeatmapStats(days int) ([]HeatmapStat, error) {
 			CacheCreateTokens: cacheCreate,
 			CacheReadTokens:   cacheRead,
 		}
-		cost := ls.calculateCost(record.GetString("model"), usage)
+		cost := ls.calculateCost(record.GetString("provider"), record.GetString("model"), usage)
 		bucket.TotalCost += cost.TotalCost
 	}
 	if len(hourBuckets) == 0 {
@@ -181,9 +183,10 @@ func (ls *LogService) StatsSince(platform string) (LogStats, error) {
 
 	stats := LogStats{
 		Series: make([]LogStatsSeries, 0, seriesHours),
+		Demo:   isDemoMode(),
 	}
 	now := time.Now()
-	model := xdb.New("request_log")
+	model := xdb.New(requestLogTable())
 	seriesStart := startOfDay(now)
 	seriesEnd := seriesStart.Add(seriesHours * time.Hour)
 	queryStart := seriesStart.Add(-24 * time.Hour)
@@ -191,6 +194,7 @@ func (ls *LogService) StatsSince(platform string) (LogStats, error) {
 	options := []xdb.Option{
 		xdb.WhereGte("created_at", queryStart.Format(timeLayout)),
 		xdb.Field(
+			"provider",
 			"model",
 			"input_tokens",
 			"output_tokens",
@@ -258,7 +262,7 @@ func (ls *LogService) StatsSince(platform string) (LogStats, error) {
 			CacheCreateTokens: cacheCreate,
 			CacheReadTokens:   cacheRead,
 		}
-		cost := ls.calculateCost(record.GetString("model"), usage)
+		cost := ls.calculateCost(record.GetString("provider"), record.GetString("model"), usage)
 
 		bucket.TotalRequests++
 		bucket.InputTokens += int64(input)
@@ -295,14 +299,41 @@ func (ls *LogService) StatsSince(platform string) (LogStats, error) {
 		}
 	}
 
+	stats.applyCurrency()
 	return stats, nil
 }
 
+// applyCurrency 把美元费用换算为当前展示币种
+func (stats *LogStats) applyCurrency() {
+	convert := func(amount float64) float64 {
+		converted, _ := displayCost(amount)
+		return converted
+	}
+	_, stats.Currency = displayCost(0)
+	stats.CostTotal = convert(stats.CostTotal)
+	stats.CostInput = convert(stats.CostInput)
+	stats.CostOutput = convert(stats.CostOutput)
+	stats.CostCacheCreate = convert(stats.CostCacheCreate)
+	stats.CostCacheRead = convert(stats.CostCacheRead)
+	for i := range stats.Series {
+		stats.Series[i].TotalCost = convert(stats.Series[i].TotalCost)
+	}
+}
+
+// TrayUsageLabel 返回托盘上展示的今日费用，如 "今日 ¥12.34"
+func (ls *LogService) TrayUsageLabel() string {
+	stats, err := ls.StatsSince("")
+	if err != nil {
+		return ""
+	}
+	return "今日 " + formatCost(stats.CostTotal, stats.Currency)
+}
+
 func (ls *LogService) ProviderDailyStats(platform string) ([]ProviderDailyStat, error) {
 	start := startOfDay(time.Now())
 	end := start.Add(24 * time.Hour)
 	queryStart := start.Add(-24 * time.Hour)
-	model := xdb.New("request_log")
+	model := xdb.New(requestLogTable())
 	options := []xdb.Option{
 		xdb.WhereGte("created_at", queryStart.Format(timeLayout)),
 		xdb.Field(
@@ -361,7 +392,7 @@ func (ls *LogService) ProviderDailyStats(platform string) ([]ProviderDailyStat,
 			CacheCreateTokens: cacheCreate,
 			CacheReadTokens:   cacheRead,
 		}
-		cost := ls.calculateCost(record.GetString("model"), usage)
+		cost := ls.calculateCost(record.GetString("provider"), record.GetString("model"), usage)
 		stat.TotalRequests++
 		// 只有 HTTP 200-299 才算成功，其他（包括 0）都算失败
 		if httpCode >= 200 && httpCode < 300 {
@@ -393,7 +424,7 @@ func (ls *LogService) ProviderDailyStats(platform string) ([]ProviderDailyStat,
 }
 
 func (ls *LogService) decorateCost(logEntry *ReqeustLog) {
-	if ls == nil || ls.pricing == nil || logEntry == nil {
+	if ls == nil || logEntry == nil {
 		return
 	}
 	usage := modelpricing.UsageSnapshot{
@@ -402,7 +433,7 @@ func (ls *LogService) decorateCost(logEntry *ReqeustLog) {
 		CacheCreateTokens: logEntry.CacheCreateTokens,
 		CacheReadTokens:   logEntry.CacheReadTokens,
 	}
-	cost := ls.pricing.CalculateCost(logEntry.Model, usage)
+	cost := ls.calculateCost(logEntry.Provider, logEntry.Model, usage)
 	logEntry.HasPricing = cost.HasPricing
 	logEntry.InputCost = cost.InputCost
 	logEntry.OutputCost = cost.OutputCost
@@ -411,13 +442,22 @@ func (ls *LogService) decorateCost(logEntry *ReqeustLog) {
 	logEntry.Ephemeral5mCost = cost.Ephemeral5mCost
 	logEntry.Ephemeral1hCost = cost.Ephemeral1hCost
 	logEntry.TotalCost = cost.TotalCost
+	logEntry.CacheSavings = ls.cacheSavings(logEntry.Provider, logEntry.Model, usage)
 }
 
-func (ls *LogService) calculateCost(model string, usage modelpricing.UsageSnapshot) modelpricing.CostBreakdown {
-	if ls == nil || ls.pricing == nil {
+// calculateCost 优先使用价格表（provider 专属价格 > 通用价格），未配置的模型使用内置价格
+func (ls *LogService) calculateCost(provider string, model string, usage modelpricing.UsageSnapshot) modelpricing.CostBreakdown {
+	if price, ok := lookupModelPrice(provider, model); ok {
+		return price.cost(usage)
+	}
+	if ls == nil {
+		return modelpricing.CostBreakdown{}
+	}
+	pricing := ls.pricing.Load()
+	if pricing == nil {
 		return modelpricing.CostBreakdown{}
 	}
-	return ls.pricing.CalculateCost(model, usage)
+	return pricing.CalculateCost(model, usage)
 }
 
 func parseCreatedAt(record xdb.Record) (time.Time, bool) {
@@ -518,6 +558,9 @@ type LogStats struct {
 	CostCacheCreate   float64          `json:"cost_cache_create"`
 	CostCacheRead     float64          `json:"cost_cache_read"`
 	Series            []LogStatsSeries `json:"series"`
+	Demo              bool             `json:"demo"`
+	// 费用字段所用币种
+	Currency string `json:"currency"`
 }
 
 type ProviderDailyStat struct {
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/daodao97/xgo/xdb"
+)
+
+const (
+	logStreamFlushInterval = 500 * time.Millisecond
+	logStreamBatchSize     = 100
+	// 前端处理不过来时最多积压的条数，超出后丢弃最早的，前端可通过 FollowRequestLogs 补齐
+	logStreamQueueLimit = 1000
+)
+
+// LogStreamBatch 推送给日志窗口的一批新日志
+type LogStreamBatch struct {
+	Items []ReqeustLog `json:"items"`
+	// 因积压被丢弃的条数，大于 0 时前端应以已收到的最后一个 id 调用 FollowRequestLogs 补齐
+	Dropped int `json:"dropped"`
+	// 本批最后一条日志的 id
+	Cursor int64 `json:"cursor"`
+}
+
+// logStreamQueue relay 写入日志后放入队列，由 LogService 批量推送
+var logStreamQueue = struct {
+	sync.Mutex
+	items   []ReqeustLog
+	dropped int
+	notify  chan struct{}
+}{notify: make(chan struct{}, 1)}
+
+// publishRequestLog 把新写入的日志放入推送队列
+func publishRequestLog(entry ReqeustLog) {
+	logStreamQueue.Lock()
+	if len(logStreamQueue.items) >= logStreamQueueLimit {
+		logStreamQueue.items = logStreamQueue.items[1:]
+		logStreamQueue.dropped++
+	}
+	logStreamQueue.items = append(logStreamQueue.items, entry)
+	full := len(logStreamQueue.items) >= logStreamBatchSize
+	logStreamQueue.Unlock()
+	if full {
+		select {
+		case logStreamQueue.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// SetStreamHandler 设置新日志的推送回调（由 main 转为前端事件）
+func (ls *LogService) SetStreamHandler(handler func(LogStreamBatch)) {
+	ls.streamMu.Lock()
+	defer ls.streamMu.Unlock()
+	ls.streamHandler = handler
+}
+
+// Start 启动日志批量推送，每 500ms 或积满一批时推送一次
+func (ls *LogService) Start() error {
+	ls.streamMu.Lock()
+	defer ls.streamMu.Unlock()
+	if ls.stopCh != nil {
+		return nil
+	}
+	stopCh := make(chan struct{})
+	ls.stopCh = stopCh
+	go func() {
+		ticker := time.NewTicker(logStreamFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ls.flushStream()
+			case <-logStreamQueue.notify:
+				ls.flushStream()
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (ls *LogService) Stop() error {
+	ls.streamMu.Lock()
+	defer ls.streamMu.Unlock()
+	if ls.stopCh != nil {
+		close(ls.stopCh)
+		ls.stopCh = nil
+	}
+	return nil
+}
+
+func (ls *LogService) flushStream() {
+	ls.streamMu.Lock()
+	handler := ls.streamHandler
+	ls.streamMu.Unlock()
+	for {
+		logStreamQueue.Lock()
+		count := min(len(logStreamQueue.items), logStreamBatchSize)
+		items := append([]ReqeustLog(nil), logStreamQueue.items[:count]...)
+		logStreamQueue.items = logStreamQueue.items[count:]
+		dropped := logStreamQueue.dropped
+		logStreamQueue.dropped = 0
+		logStreamQueue.Unlock()
+		if len(items) == 0 && dropped == 0 {
+			return
+		}
+		// 没有订阅者时直接丢弃，前端打开日志窗口后通过 FollowRequestLogs 拉取
+		if handler == nil {
+			continue
+		}
+		batch := LogStreamBatch{Items: items, Dropped: dropped}
+		for i := range batch.Items {
+			ls.decorateCost(&batch.Items[i])
+		}
+		if len(items) > 0 {
+			batch.Cursor = items[len(items)-1].ID
+		}
+		handler(batch)
+	}
+}
+
+// FollowRequestLogs 返回 id 大于 cursor 的日志（按 id 升序），用于日志窗口断线或丢弃后从游标处续读
+func (ls *LogService) FollowRequestLogs(platform string, cursor int64, limit int) (LogPage, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	if limit > 500 {
+		limit = 500
+	}
+	page := LogPage{Items: []ReqeustLog{}, NextCursor: cursor}
+	options := []xdb.Option{
+		xdb.WhereGt("id", cursor),
+		xdb.OrderByAsc("id"),
+		xdb.Limit(limit + 1),
+	}
+	if platform != "" {
+		options = append(options, xdb.WhereEq("platform", platform))
+	}
+	records, err := xdb.New(requestLogTable()).Selects(options...)
+	if err != nil {
+		if errors.Is(err, xdb.ErrNotFound) || isNoSuchTableErr(err) {
+			return page, nil
+		}
+		return page, err
+	}
+	if len(records) > limit {
+		page.HasMore = true
+		records = records[:limit]
+	}
+	for _, record := range records {
+		logEntry := requestLogFromRecord(record)
+		ls.decorateCost(&logEntry)
+		page.Items = append(page.Items, logEntry)
+	}
+	if len(page.Items) > 0 {
+		page.NextCursor = page.Items[len(page.Items)-1].ID
+	}
+	return page, nil
+}
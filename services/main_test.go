@@ -0,0 +1,57 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/daodao97/xgo/xdb"
+)
+
+// TestMain 把数据目录指向临时目录，避免测试读写真实的 provider 配置与数据库
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "code-switch-test-")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	os.Setenv(dataDirEnv, dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// useTestDB 为当前测试注册一个独立的内存数据库作为 default 连接，并创建全部表；
+// provider 配置文件同时清空，避免与上一个测试留下的配置和变更历史对不上
+func useTestDB(t *testing.T) {
+	t.Helper()
+	removeTestProviders(t)
+	t.Cleanup(func() { removeTestProviders(t) })
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	if err := xdb.Inits([]xdb.Config{{
+		Name:        "default",
+		Driver:      "sqlite",
+		DSN:         "file:" + name + "?mode=memory&cache=shared&_busy_timeout=5000",
+		MaxOpenConn: 1,
+		MaxIdleConn: 1,
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ensureDatabaseTables(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func removeTestProviders(t *testing.T) {
+	t.Helper()
+	for _, kind := range []string{"claude", "codex", "gemini"} {
+		path, err := providerFilePath(kind)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+	}
+}
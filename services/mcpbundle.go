@@ -0,0 +1,306 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	mcpBundleFormat  = "code-switch-mcp"
+	mcpBundleVersion = 1
+	// mcpBundleMaxSize 导入的配置包上限
+	mcpBundleMaxSize = 4 << 20
+)
+
+// 未标记为密钥但名称像凭据的环境变量导出时同样替换为占位符，避免误把明文分享出去
+var mcpSensitiveEnvPattern = regexp.MustCompile(`(?i)(token|secret|password|passwd|api_?key|access_?key|private_?key|credential)`)
+
+// mcpBundleFile 导出的 MCP 配置包，密钥只保留键名，值替换为 {KEY} 占位符
+type mcpBundleFile struct {
+	Format    string            `json:"format"`
+	Version   int               `json:"version"`
+	CreatedAt time.Time         `json:"created_at"`
+	Servers   []mcpBundleServer `json:"servers"`
+	Policies  []MCPToolPolicy   `json:"policies,omitempty"`
+}
+
+type mcpBundleServer struct {
+	Name           string            `json:"name"`
+	Type           string            `json:"type"`
+	Command        string            `json:"command,omitempty"`
+	Args           []string          `json:"args,omitempty"`
+	Env            map[string]string `json:"env,omitempty"`
+	URL            string            `json:"url,omitempty"`
+	Website        string            `json:"website,omitempty"`
+	Tips           string            `json:"tips,omitempty"`
+	EnablePlatform []string          `json:"enable_platform,omitempty"`
+	Managed        bool              `json:"managed,omitempty"`
+	// Secrets 导入时需要填写的环境变量，AuthToken 为 true 时还需要填写 HTTP 访问令牌
+	Secrets   []string `json:"secrets,omitempty"`
+	AuthToken bool     `json:"auth_token,omitempty"`
+}
+
+// MCPBundleExport 导出结果
+type MCPBundleExport struct {
+	Path  string `json:"path"`
+	Count int    `json:"count"`
+}
+
+// MCPBundleCandidate 配置包中的一个服务，Secrets 为导入前需要填写的密钥
+type MCPBundleCandidate struct {
+	Server    MCPServer `json:"server"`
+	Secrets   []string  `json:"secrets"`
+	AuthToken bool      `json:"auth_token"`
+	Exists    bool      `json:"exists"`
+}
+
+// ExportMCPBundle 把指定服务（为空时全部）导出为可分享的 JSON，写入 outputDir（为空时写入数据目录下的 exports）。
+// 项目分配与本机路径相关，不导出
+func (ms *MCPService) ExportMCPBundle(names []string, outputDir string) (MCPBundleExport, error) {
+	servers, err := ms.ListServers()
+	if err != nil {
+		return MCPBundleExport{}, err
+	}
+	bundle := mcpBundleFile{Format: mcpBundleFormat, Version: mcpBundleVersion, CreatedAt: time.Now()}
+	exported := make([]string, 0, len(servers))
+	for _, server := range servers {
+		if len(names) > 0 && !containsPlatform(names, server.Name) {
+			continue
+		}
+		bundle.Servers = append(bundle.Servers, newMCPBundleServer(server))
+		exported = append(exported, server.Name)
+	}
+	if len(bundle.Servers) == 0 {
+		return MCPBundleExport{}, errors.New("没有可导出的 MCP 服务")
+	}
+	if policies, err := ms.ListMCPToolPolicies(); err == nil {
+		for _, policy := range policies {
+			if containsPlatform(exported, policy.Server) {
+				bundle.Policies = append(bundle.Policies, policy)
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return MCPBundleExport{}, err
+	}
+	dir, err := exportDir(outputDir)
+	if err != nil {
+		return MCPBundleExport{}, err
+	}
+	target := filepath.Join(dir, fmt.Sprintf("mcp-servers-%s.json", time.Now().Format("20060102-150405")))
+	if err := os.WriteFile(target, data, 0o644); err != nil {
+		return MCPBundleExport{}, err
+	}
+	return MCPBundleExport{Path: target, Count: len(bundle.Servers)}, nil
+}
+
+func newMCPBundleServer(server MCPServer) mcpBundleServer {
+	entry := mcpBundleServer{
+		Name:           server.Name,
+		Type:           server.Type,
+		Command:        server.Command,
+		Args:           cloneArgs(server.Args),
+		URL:            server.URL,
+		Website:        server.Website,
+		Tips:           server.Tips,
+		EnablePlatform: server.EnablePlatform,
+		Managed:        server.Managed,
+		AuthToken:      server.AuthToken != "",
+	}
+	if len(server.Env) > 0 {
+		entry.Env = make(map[string]string, len(server.Env))
+	}
+	keys := make([]string, 0, len(server.Env))
+	for key := range server.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := server.Env[key]
+		secret := containsPlatform(server.SecretEnv, key) ||
+			(value != "" && mcpSensitiveEnvPattern.MatchString(key) && !placeholderPattern.MatchString(value))
+		if secret {
+			entry.Env[key] = "{" + key + "}"
+			entry.Secrets = append(entry.Secrets, key)
+			continue
+		}
+		entry.Env[key] = value
+	}
+	return entry
+}
+
+// PreviewMCPBundle 读取配置包，列出其中的服务与需要填写的密钥
+func (ms *MCPService) PreviewMCPBundle(path string) ([]MCPBundleCandidate, error) {
+	bundle, err := readMCPBundle(path)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := ms.ListServers()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(existing))
+	for i, server := range existing {
+		names[i] = server.Name
+	}
+	candidates := make([]MCPBundleCandidate, 0, len(bundle.Servers))
+	for _, entry := range bundle.Servers {
+		server := entry.server()
+		candidates = append(candidates, MCPBundleCandidate{
+			Server:    server,
+			Secrets:   entry.Secrets,
+			AuthToken: entry.AuthToken,
+			Exists:    containsPlatform(names, server.Name),
+		})
+	}
+	return candidates, nil
+}
+
+// ImportMCPBundle 导入配置包中选中的服务，secrets 按服务名提供密钥（HTTP 访问令牌的键名为 Authorization），
+// 密钥保存到系统钥匙串；同名服务会被覆盖
+func (ms *MCPService) ImportMCPBundle(path string, names []string, secrets map[string]map[string]string) ([]MCPServer, error) {
+	bundle, err := readMCPBundle(path)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := ms.ListServers()
+	if err != nil {
+		return nil, err
+	}
+	imported := make([]MCPServer, 0, len(names))
+	for _, entry := range bundle.Servers {
+		if !containsPlatform(names, entry.Name) {
+			continue
+		}
+		server, err := entry.withSecrets(secrets[entry.Name])
+		if err != nil {
+			return nil, err
+		}
+		imported = append(imported, server)
+	}
+	if len(imported) == 0 {
+		return nil, errors.New("请选择要导入的服务")
+	}
+
+	servers := make([]MCPServer, 0, len(existing)+len(imported))
+	for _, server := range existing {
+		replaced := false
+		for _, next := range imported {
+			if strings.EqualFold(next.Name, server.Name) {
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			servers = append(servers, server)
+		}
+	}
+	servers = append(servers, imported...)
+	if err := ms.SaveServers(servers); err != nil {
+		return nil, err
+	}
+
+	if len(bundle.Policies) > 0 {
+		policies, err := ms.ListMCPToolPolicies()
+		if err != nil {
+			return imported, err
+		}
+		for _, policy := range bundle.Policies {
+			for _, server := range imported {
+				if strings.EqualFold(policy.Server, server.Name) {
+					policies = append(policies, policy)
+				}
+			}
+		}
+		if _, err := ms.SaveMCPToolPolicies(policies); err != nil {
+			return imported, err
+		}
+	}
+	return imported, nil
+}
+
+func (entry mcpBundleServer) server() MCPServer {
+	env := make(map[string]string, len(entry.Env))
+	for key, value := range entry.Env {
+		env[key] = value
+	}
+	platforms := entry.EnablePlatform
+	if platforms == nil {
+		platforms = []string{}
+	}
+	return MCPServer{
+		Name:           strings.TrimSpace(entry.Name),
+		Type:           entry.Type,
+		Command:        entry.Command,
+		Args:           cloneArgs(entry.Args),
+		Env:            env,
+		URL:            entry.URL,
+		Website:        entry.Website,
+		Tips:           entry.Tips,
+		EnablePlatform: platforms,
+		Managed:        entry.Managed,
+		Scope:          MCPScopeGlobal,
+		Projects:       []string{},
+		SecretEnv:      []string{},
+	}
+}
+
+// withSecrets 填入密钥并标记为保存到钥匙串，缺少任一密钥时返回错误
+func (entry mcpBundleServer) withSecrets(values map[string]string) (MCPServer, error) {
+	server := entry.server()
+	for _, key := range entry.Secrets {
+		value := strings.TrimSpace(values[key])
+		if value == "" {
+			return MCPServer{}, fmt.Errorf("请填写 %s 的 %s", server.Name, key)
+		}
+		server.Env[key] = value
+		server.SecretEnv = append(server.SecretEnv, key)
+	}
+	if entry.AuthToken {
+		token := strings.TrimSpace(values[mcpAuthSecretKey])
+		if token == "" {
+			return MCPServer{}, fmt.Errorf("请填写 %s 的访问令牌", server.Name)
+		}
+		server.AuthToken = token
+	}
+	return server, nil
+}
+
+func readMCPBundle(path string) (mcpBundleFile, error) {
+	var bundle mcpBundleFile
+	info, err := os.Stat(path)
+	if err != nil {
+		return bundle, err
+	}
+	if info.Size() > mcpBundleMaxSize {
+		return bundle, fmt.Errorf("配置包超过 %d MB", mcpBundleMaxSize>>20)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return bundle, err
+	}
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return bundle, fmt.Errorf("配置包格式错误: %w", err)
+	}
+	if bundle.Format != mcpBundleFormat {
+		return bundle, errors.New("不是 code-switch 导出的 MCP 配置包")
+	}
+	if bundle.Version > mcpBundleVersion {
+		return bundle, fmt.Errorf("配置包版本 %d 过新，请升级 code-switch", bundle.Version)
+	}
+	for _, entry := range bundle.Servers {
+		if strings.TrimSpace(entry.Name) == "" {
+			return bundle, errors.New("配置包中存在未命名的服务")
+		}
+	}
+	return bundle, nil
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMCPBundleRoundTrip(t *testing.T) {
+	entry := newMCPBundleServer(MCPServer{
+		Name:      "github",
+		Type:      "stdio",
+		Command:   "npx",
+		Args:      []string{"-y", "@modelcontextprotocol/server-github"},
+		Env:       map[string]string{"GITHUB_TOKEN": MCPSecretMask, "OPENAI_API_KEY": "sk-plain", "REGION": "us", "DB_PASSWORD": "{DB_PASSWORD}"},
+		SecretEnv: []string{"GITHUB_TOKEN"},
+		Projects:  []string{"/home/me/repo"},
+	})
+	if !reflect.DeepEqual(entry.Secrets, []string{"GITHUB_TOKEN", "OPENAI_API_KEY"}) {
+		t.Fatalf("secrets = %v", entry.Secrets)
+	}
+	if entry.Env["OPENAI_API_KEY"] != "{OPENAI_API_KEY}" || entry.Env["REGION"] != "us" || entry.Env["DB_PASSWORD"] != "{DB_PASSWORD}" {
+		t.Fatalf("env = %v", entry.Env)
+	}
+
+	path := filepath.Join(t.TempDir(), "bundle.json")
+	data, _ := json.Marshal(mcpBundleFile{Format: mcpBundleFormat, Version: mcpBundleVersion, Servers: []mcpBundleServer{entry}})
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := readMCPBundle(path)
+	if err != nil || len(bundle.Servers) != 1 {
+		t.Fatalf("read: %v", err)
+	}
+	if strings.Contains(string(data), "sk-plain") {
+		t.Fatal("bundle leaked a secret value")
+	}
+
+	if _, err := bundle.Servers[0].withSecrets(map[string]string{"GITHUB_TOKEN": "ghp_1"}); err == nil {
+		t.Fatal("expected error for missing secret")
+	}
+	server, err := bundle.Servers[0].withSecrets(map[string]string{"GITHUB_TOKEN": "ghp_1", "OPENAI_API_KEY": "sk-2"})
+	if err != nil {
+		t.Fatalf("withSecrets: %v", err)
+	}
+	if server.Env["GITHUB_TOKEN"] != "ghp_1" || !reflect.DeepEqual(server.SecretEnv, []string{"GITHUB_TOKEN", "OPENAI_API_KEY"}) || len(server.Projects) != 0 {
+		t.Fatalf("server = %+v", server)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"format":"other","servers":[]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readMCPBundle(path); err == nil {
+		t.Fatal("expected error for foreign format")
+	}
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildServerFromCatalog(t *testing.T) {
+	entry := MCPCatalogEntry{
+		ID:      "search",
+		Type:    "stdio",
+		Command: "npx",
+		Args:    []string{"-y", "search-mcp", "--root", "{ROOT}"},
+		Env:     map[string]string{"API_KEY": "{API_KEY}", "REGION": "{REGION}", "MODE": "fast"},
+		Secrets: []MCPCatalogSecret{
+			{Key: "API_KEY", Label: "API Key", Required: true, Secret: true},
+			{Key: "ROOT", Label: "Root", Required: true},
+			{Key: "REGION", Label: "Region"},
+		},
+	}
+	if _, err := buildServerFromCatalog(entry, map[string]string{"ROOT": "/tmp"}, nil); err == nil {
+		t.Fatal("expected error for missing required secret")
+	}
+	server, err := buildServerFromCatalog(entry, map[string]string{"API_KEY": " sk-1 ", "ROOT": "/work"}, []string{"gemini-cli"})
+	if err != nil {
+		t.Fatalf("buildServerFromCatalog: %v", err)
+	}
+	if want := []string{"-y", "search-mcp", "--root", "/work"}; !reflect.DeepEqual(server.Args, want) {
+		t.Fatalf("args = %v", server.Args)
+	}
+	if want := map[string]string{"API_KEY": "sk-1", "MODE": "fast"}; !reflect.DeepEqual(server.Env, want) {
+		t.Fatalf("env = %v", server.Env)
+	}
+	if !reflect.DeepEqual(server.SecretEnv, []string{"API_KEY"}) {
+		t.Fatalf("secret env = %v", server.SecretEnv)
+	}
+	if !reflect.DeepEqual(server.EnablePlatform, []string{platGemini}) {
+		t.Fatalf("platforms = %v", server.EnablePlatform)
+	}
+}
@@ -0,0 +1,708 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	mcpGatewayFile = "mcp-gateway.json"
+	// 网关挂在 relay 上，relay 监听所有网卡，因此默认关闭且必须携带 token
+	mcpGatewayPathPrefix     = "/mcp/"
+	mcpGatewayRequestTimeout = 5 * time.Minute
+	mcpGatewayMaxBody        = 8 << 20
+)
+
+// MCPGatewayInfo 网关配置与每个可访问服务的地址。Streamable HTTP 客户端用 URL，旧版 SSE 客户端用 SSEURL
+type MCPGatewayInfo struct {
+	Enabled   bool                 `json:"enabled"`
+	Token     string               `json:"token"`
+	BaseURL   string               `json:"base_url"`
+	Endpoints []MCPGatewayEndpoint `json:"endpoints"`
+}
+
+type MCPGatewayEndpoint struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	SSEURL string `json:"sse_url"`
+}
+
+type mcpGatewayConfig struct {
+	Enabled bool   `json:"enabled"`
+	Token   string `json:"token"`
+}
+
+// mcpGateway 把托管的 stdio 进程桥接为多客户端共享的 HTTP 端点
+type mcpGateway struct {
+	mu      sync.Mutex
+	baseURL string
+	bridges map[string]*mcpBridge
+}
+
+// mcpBridge 一个托管进程上的多路复用：客户端的请求 ID 改写为进程内唯一的 ID，响应按 ID 改写回去；
+// initialize 只向进程发送一次，之后的客户端直接复用结果，进程重启后重新握手
+type mcpBridge struct {
+	name    string
+	resolve func() (*mcpProcess, error)
+	// onTraffic 记录经过网关的每一帧，为 nil 时不记录
+	onTraffic func(MCPTrafficEntry)
+
+	mu          sync.Mutex
+	proc        *mcpProcess
+	cancelSub   func()
+	done        chan struct{}
+	nextID      int64
+	pending     map[int64]chan json.RawMessage
+	sessions    map[string]*mcpGatewaySession
+	initPID     int
+	initResult  json.RawMessage
+	initParams  json.RawMessage
+	initRunning chan struct{}
+}
+
+type mcpGatewaySession struct {
+	id      string
+	stream  chan []byte
+	created time.Time
+}
+
+// SetGatewayBaseURL 由 relay 设置对外地址，用于展示各服务的网关 URL
+func (ms *MCPService) SetGatewayBaseURL(baseURL string) {
+	ms.gateway().mu.Lock()
+	defer ms.gateway().mu.Unlock()
+	ms.gateway().baseURL = strings.TrimRight(baseURL, "/")
+}
+
+// GetMCPGateway 返回网关配置与托管服务的访问地址
+func (ms *MCPService) GetMCPGateway() (MCPGatewayInfo, error) {
+	config, err := loadMCPGatewayConfig()
+	if err != nil {
+		return MCPGatewayInfo{}, err
+	}
+	gw := ms.gateway()
+	gw.mu.Lock()
+	base := gw.baseURL
+	gw.mu.Unlock()
+	info := MCPGatewayInfo{Enabled: config.Enabled, Token: config.Token, BaseURL: base, Endpoints: []MCPGatewayEndpoint{}}
+	servers, err := ms.ListServers()
+	if err != nil {
+		return info, err
+	}
+	for _, server := range servers {
+		if !server.Managed || server.Type != "stdio" {
+			continue
+		}
+		endpoint := base + mcpGatewayPathPrefix + url.PathEscape(server.Name)
+		info.Endpoints = append(info.Endpoints, MCPGatewayEndpoint{Name: server.Name, URL: endpoint, SSEURL: endpoint + "/sse"})
+	}
+	return info, nil
+}
+
+// SetMCPGatewayEnabled 开启或关闭网关，首次开启时生成 token
+func (ms *MCPService) SetMCPGatewayEnabled(enabled bool) (MCPGatewayInfo, error) {
+	config, err := loadMCPGatewayConfig()
+	if err != nil {
+		return MCPGatewayInfo{}, err
+	}
+	config.Enabled = enabled
+	if config.Token == "" {
+		config.Token = newMCPGatewayToken()
+	}
+	if err := saveMCPGatewayConfig(config); err != nil {
+		return MCPGatewayInfo{}, err
+	}
+	if !enabled {
+		ms.gateway().closeAll()
+	}
+	return ms.GetMCPGateway()
+}
+
+// RegenerateMCPGatewayToken 更换 token，已连接的客户端需要使用新 token 重新连接
+func (ms *MCPService) RegenerateMCPGatewayToken() (MCPGatewayInfo, error) {
+	config, err := loadMCPGatewayConfig()
+	if err != nil {
+		return MCPGatewayInfo{}, err
+	}
+	config.Token = newMCPGatewayToken()
+	if err := saveMCPGatewayConfig(config); err != nil {
+		return MCPGatewayInfo{}, err
+	}
+	ms.gateway().closeAll()
+	return ms.GetMCPGateway()
+}
+
+func (ms *MCPService) gateway() *mcpGateway {
+	ms.gatewayOnce.Do(func() {
+		ms.gw = &mcpGateway{bridges: make(map[string]*mcpBridge)}
+	})
+	return ms.gw
+}
+
+// ServeMCPGateway 处理 /mcp/<name>、/mcp/<name>/sse 与 /mcp/<name>/messages
+func (ms *MCPService) ServeMCPGateway(w http.ResponseWriter, r *http.Request) {
+	config, err := loadMCPGatewayConfig()
+	if err != nil || !config.Enabled || config.Token == "" {
+		http.Error(w, "mcp gateway disabled", http.StatusNotFound)
+		return
+	}
+	if !mcpGatewayAuthorized(r, config.Token) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="code-switch"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	rest := strings.TrimPrefix(r.URL.Path, mcpGatewayPathPrefix)
+	name, suffix, _ := strings.Cut(rest, "/")
+	name, err = url.PathUnescape(name)
+	if err != nil || name == "" {
+		http.NotFound(w, r)
+		return
+	}
+	bridge, err := ms.gateway().bridge(ms, name)
+	if err != nil {
+		writeMCPGatewayError(w, http.StatusBadGateway, err)
+		return
+	}
+	switch {
+	case suffix == "" && r.Method == http.MethodPost:
+		bridge.serveStreamablePost(w, r)
+	case suffix == "" && r.Method == http.MethodGet:
+		bridge.serveStream(w, r, r.Header.Get("Mcp-Session-Id"), false)
+	case suffix == "" && r.Method == http.MethodDelete:
+		bridge.closeSession(r.Header.Get("Mcp-Session-Id"))
+		w.WriteHeader(http.StatusOK)
+	case suffix == "sse" && r.Method == http.MethodGet:
+		bridge.serveStream(w, r, "", true)
+	case suffix == "messages" && r.Method == http.MethodPost:
+		bridge.serveLegacyPost(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func mcpGatewayAuthorized(r *http.Request, token string) bool {
+	provided := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	if provided == "" {
+		provided = r.URL.Query().Get("token")
+	}
+	return provided != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
+
+func (gw *mcpGateway) bridge(ms *MCPService, name string) (*mcpBridge, error) {
+	gw.mu.Lock()
+	bridge, ok := gw.bridges[name]
+	if !ok {
+		bridge = newMCPBridge(name, func() (*mcpProcess, error) { return ms.ensureMCPProcess(name) })
+		bridge.onTraffic = ms.recordMCPTraffic
+		gw.bridges[name] = bridge
+	}
+	gw.mu.Unlock()
+	if err := bridge.attach(); err != nil {
+		return nil, err
+	}
+	return bridge, nil
+}
+
+func (gw *mcpGateway) closeAll() {
+	gw.mu.Lock()
+	bridges := gw.bridges
+	gw.bridges = make(map[string]*mcpBridge)
+	gw.mu.Unlock()
+	for _, bridge := range bridges {
+		bridge.detach()
+	}
+}
+
+func newMCPBridge(name string, resolve func() (*mcpProcess, error)) *mcpBridge {
+	return &mcpBridge{name: name, resolve: resolve, pending: make(map[int64]chan json.RawMessage), sessions: make(map[string]*mcpGatewaySession)}
+}
+
+// attach 按需启动托管进程并订阅其输出；进程被停止后重新调用会得到新的进程
+func (b *mcpBridge) attach() error {
+	proc, err := b.resolve()
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.proc == proc {
+		return nil
+	}
+	b.unsubscribeLocked()
+	lines, cancel := proc.subscribe()
+	b.proc = proc
+	b.cancelSub = cancel
+	b.done = make(chan struct{})
+	b.initPID = 0
+	go b.dispatch(lines, b.done)
+	return nil
+}
+
+func (b *mcpBridge) unsubscribeLocked() {
+	if b.cancelSub != nil {
+		b.cancelSub()
+		close(b.done)
+		b.cancelSub = nil
+	}
+}
+
+func (b *mcpBridge) detach() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.unsubscribeLocked()
+	b.proc = nil
+	for id, session := range b.sessions {
+		close(session.stream)
+		delete(b.sessions, id)
+	}
+}
+
+// dispatch 把进程输出的响应交给等待中的请求，通知广播给所有会话
+func (b *mcpBridge) dispatch(lines <-chan []byte, done <-chan struct{}) {
+	for {
+		var line []byte
+		select {
+		case <-done:
+			return
+		case line = <-lines:
+		}
+		var msg map[string]json.RawMessage
+		if err := json.Unmarshal(line, &msg); err != nil {
+			continue
+		}
+		rawID, hasID := msg["id"]
+		_, hasMethod := msg["method"]
+		if hasID && !hasMethod {
+			var id int64
+			if json.Unmarshal(rawID, &id) != nil {
+				continue
+			}
+			b.mu.Lock()
+			ch, ok := b.pending[id]
+			delete(b.pending, id)
+			b.mu.Unlock()
+			if ok {
+				ch <- line
+			}
+			continue
+		}
+		if hasMethod && !hasID {
+			b.record(newMCPTrafficEntry(b.name, MCPTrafficServer, msg))
+			b.broadcast(line)
+		}
+		// 服务端发起的请求（sampling、roots 等）无法确定应由哪个客户端处理，忽略
+	}
+}
+
+func (b *mcpBridge) broadcast(line []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, session := range b.sessions {
+		select {
+		case session.stream <- line:
+		default:
+		}
+	}
+}
+
+func (b *mcpBridge) record(entry MCPTrafficEntry) {
+	if b.onTraffic != nil {
+		b.onTraffic(entry)
+	}
+}
+
+// call 把一条带 ID 的请求发给进程并等待响应，返回的响应中 ID 已改写回客户端的 ID
+func (b *mcpBridge) call(ctx context.Context, msg map[string]json.RawMessage) (resp []byte, err error) {
+	if b.onTraffic != nil {
+		entry := newMCPTrafficEntry(b.name, MCPTrafficRequest, msg)
+		started := time.Now()
+		defer func() {
+			finishMCPTrafficEntry(&entry, started, resp, err)
+			b.onTraffic(entry)
+		}()
+	}
+	if err := b.attach(); err != nil {
+		return nil, err
+	}
+	clientID := msg["id"]
+	b.mu.Lock()
+	proc := b.proc
+	b.nextID++
+	id := b.nextID
+	ch := make(chan json.RawMessage, 1)
+	b.pending[id] = ch
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		delete(b.pending, id)
+		b.mu.Unlock()
+	}()
+
+	forwarded := make(map[string]json.RawMessage, len(msg))
+	for key, value := range msg {
+		forwarded[key] = value
+	}
+	forwarded["id"] = json.RawMessage(fmt.Sprint(id))
+	data, err := json.Marshal(forwarded)
+	if err != nil {
+		return nil, err
+	}
+	if proc == nil {
+		return nil, errors.New("进程未运行")
+	}
+	if err := proc.send(data); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, mcpGatewayRequestTimeout)
+	defer cancel()
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case line := <-ch:
+		return rewriteMCPMessageID(line, clientID)
+	}
+}
+
+// initialize 进程只握手一次，之后的客户端复用缓存的结果
+func (b *mcpBridge) initialize(ctx context.Context, msg map[string]json.RawMessage) ([]byte, error) {
+	for {
+		if err := b.attach(); err != nil {
+			return nil, err
+		}
+		b.mu.Lock()
+		if b.proc == nil {
+			b.mu.Unlock()
+			return nil, errors.New("进程未运行")
+		}
+		pid := b.proc.snapshot().PID
+		if b.initResult != nil && b.initPID == pid {
+			result := b.initResult
+			b.mu.Unlock()
+			return json.Marshal(map[string]json.RawMessage{"jsonrpc": json.RawMessage(`"2.0"`), "id": msg["id"], "result": result})
+		}
+		if waiting := b.initRunning; waiting != nil {
+			b.mu.Unlock()
+			select {
+			case <-waiting:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		b.initRunning = make(chan struct{})
+		b.mu.Unlock()
+
+		resp, err := b.call(ctx, msg)
+		var parsed mcpRPCResponse
+		if err == nil && json.Unmarshal(resp, &parsed) == nil && parsed.Error == nil && parsed.Result != nil {
+			notify, _ := mcpRequestBody(0, "notifications/initialized", nil)
+			b.mu.Lock()
+			if b.proc != nil {
+				_ = b.proc.send(notify)
+			}
+			b.initPID = pid
+			b.initResult = parsed.Result
+			b.initParams = msg["params"]
+			b.mu.Unlock()
+		}
+		b.mu.Lock()
+		close(b.initRunning)
+		b.initRunning = nil
+		b.mu.Unlock()
+		return resp, err
+	}
+}
+
+// ensureInitialized 客户端复用会话而进程已重启时，用上次的参数重新握手
+func (b *mcpBridge) ensureInitialized(ctx context.Context) error {
+	b.mu.Lock()
+	params := b.initParams
+	stale := b.proc == nil || b.initResult == nil || b.initPID != b.proc.snapshot().PID
+	b.mu.Unlock()
+	if !stale {
+		return nil
+	}
+	if params == nil {
+		params = json.RawMessage(fmt.Sprintf(`{"protocolVersion":%q,"capabilities":{},"clientInfo":{"name":"code-switch-gateway","version":%q}}`, mcpProbeProtocolVersion, mcpProbeClientVersion))
+	}
+	_, err := b.initialize(ctx, map[string]json.RawMessage{"jsonrpc": json.RawMessage(`"2.0"`), "id": json.RawMessage(`0`), "method": json.RawMessage(`"initialize"`), "params": params})
+	return err
+}
+
+// handle 处理一条客户端消息，返回需要回给客户端的响应（通知与客户端响应返回 nil）
+func (b *mcpBridge) handle(ctx context.Context, msg map[string]json.RawMessage) ([]byte, error) {
+	var method string
+	_ = json.Unmarshal(msg["method"], &method)
+	_, hasID := msg["id"]
+	switch {
+	case method == "initialize" && hasID:
+		return b.initialize(ctx, msg)
+	case method == "notifications/initialized":
+		return nil, nil
+	case method == "":
+		// 客户端对服务端请求的响应，服务端请求已被忽略
+		return nil, nil
+	case !hasID:
+		if err := b.attach(); err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return nil, err
+		}
+		b.mu.Lock()
+		proc := b.proc
+		b.mu.Unlock()
+		if proc == nil {
+			return nil, errors.New("进程未运行")
+		}
+		entry := newMCPTrafficEntry(b.name, MCPTrafficNotification, msg)
+		if err := proc.send(data); err != nil {
+			entry.Error = err.Error()
+			b.record(entry)
+			return nil, err
+		}
+		b.record(entry)
+		return nil, nil
+	default:
+		if err := b.ensureInitialized(ctx); err != nil {
+			return nil, err
+		}
+		return b.call(ctx, msg)
+	}
+}
+
+func (b *mcpBridge) serveStreamablePost(w http.ResponseWriter, r *http.Request) {
+	msg, err := readMCPGatewayMessage(r)
+	if err != nil {
+		writeMCPGatewayError(w, http.StatusBadRequest, err)
+		return
+	}
+	resp, err := b.handle(r.Context(), msg)
+	if err != nil {
+		writeMCPGatewayRPCError(w, msg["id"], err)
+		return
+	}
+	var method string
+	_ = json.Unmarshal(msg["method"], &method)
+	if method == "initialize" {
+		session := b.openSession()
+		w.Header().Set("Mcp-Session-Id", session.id)
+	}
+	if resp == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(resp)
+}
+
+// serveLegacyPost 旧版 HTTP+SSE 传输：POST 立即返回 202，响应通过会话的 SSE 流发回
+func (b *mcpBridge) serveLegacyPost(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	session, ok := b.sessions[r.URL.Query().Get("sessionId")]
+	b.mu.Unlock()
+	if !ok {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	msg, err := readMCPGatewayMessage(r)
+	if err != nil {
+		writeMCPGatewayError(w, http.StatusBadRequest, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	go func() {
+		resp, err := b.handle(context.Background(), msg)
+		if err != nil {
+			resp = mcpGatewayRPCError(msg["id"], err)
+		}
+		if resp == nil {
+			return
+		}
+		defer func() { _ = recover() }() // 会话已关闭时 stream 已被 close
+		select {
+		case session.stream <- resp:
+		case <-time.After(mcpGatewayRequestTimeout):
+		}
+	}()
+}
+
+// serveStream 输出 SSE：legacy 模式先发送 endpoint 事件并新建会话，否则复用 initialize 时创建的会话
+func (b *mcpBridge) serveStream(w http.ResponseWriter, r *http.Request, sessionID string, legacy bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	var session *mcpGatewaySession
+	if legacy {
+		session = b.openSession()
+		defer b.closeSession(session.id)
+	} else {
+		b.mu.Lock()
+		session = b.sessions[sessionID]
+		b.mu.Unlock()
+		if session == nil {
+			http.Error(w, "session not found", http.StatusNotFound)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	if legacy {
+		endpoint := mcpGatewayPathPrefix + url.PathEscape(b.name) + "/messages?sessionId=" + session.id
+		if token := r.URL.Query().Get("token"); token != "" {
+			endpoint += "&token=" + url.QueryEscape(token)
+		}
+		fmt.Fprintf(w, "event: endpoint\ndata: %s\n\n", endpoint)
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(25 * time.Second)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case line, ok := <-session.stream:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", line)
+			flusher.Flush()
+		}
+	}
+}
+
+func (b *mcpBridge) openSession() *mcpGatewaySession {
+	session := &mcpGatewaySession{id: newMCPGatewayToken(), stream: make(chan []byte, 64), created: time.Now()}
+	b.mu.Lock()
+	b.sessions[session.id] = session
+	b.mu.Unlock()
+	return session
+}
+
+func (b *mcpBridge) closeSession(id string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if session, ok := b.sessions[id]; ok {
+		close(session.stream)
+		delete(b.sessions, id)
+	}
+}
+
+func readMCPGatewayMessage(r *http.Request) (map[string]json.RawMessage, error) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, mcpGatewayMaxBody))
+	if err != nil {
+		return nil, err
+	}
+	var msg map[string]json.RawMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, fmt.Errorf("仅支持单条 JSON-RPC 消息: %w", err)
+	}
+	return msg, nil
+}
+
+func rewriteMCPMessageID(line []byte, id json.RawMessage) ([]byte, error) {
+	var msg map[string]json.RawMessage
+	if err := json.Unmarshal(line, &msg); err != nil {
+		return nil, err
+	}
+	msg["id"] = id
+	return json.Marshal(msg)
+}
+
+func mcpGatewayRPCError(id json.RawMessage, err error) []byte {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	data, _ := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"error":   map[string]any{"code": -32603, "message": err.Error()},
+	})
+	return data
+}
+
+func writeMCPGatewayRPCError(w http.ResponseWriter, id json.RawMessage, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(mcpGatewayRPCError(id, err))
+}
+
+func writeMCPGatewayError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+func newMCPGatewayToken() string {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
+
+func mcpGatewayConfigPath() (string, error) {
+	dir, err := ensureDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, mcpGatewayFile), nil
+}
+
+func loadMCPGatewayConfig() (mcpGatewayConfig, error) {
+	var config mcpGatewayConfig
+	path, err := mcpGatewayConfigPath()
+	if err != nil {
+		return config, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config, nil
+		}
+		return config, err
+	}
+	if len(data) == 0 {
+		return config, nil
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+func saveMCPGatewayConfig(config mcpGatewayConfig) error {
+	path, err := mcpGatewayConfigPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
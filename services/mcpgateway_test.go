@@ -0,0 +1,71 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMCPGatewayBridge(t *testing.T) {
+	proc := newTestMCPProcess("1", func(MCPProcessStatus) {})
+	defer proc.stop()
+	bridge := newMCPBridge("helper", func() (*mcpProcess, error) {
+		return proc, proc.start()
+	})
+	defer bridge.detach()
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/mcp/helper", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		bridge.serveStreamablePost(rec, req)
+		return rec
+	}
+
+	rec := post(`{"jsonrpc":"2.0","id":"init-a","method":"initialize","params":{}}`)
+	if rec.Code != http.StatusOK || rec.Header().Get("Mcp-Session-Id") == "" || !strings.Contains(rec.Body.String(), `"id":"init-a"`) {
+		t.Fatalf("initialize: %d %s", rec.Code, rec.Body.String())
+	}
+	// 第二个客户端复用缓存的握手结果
+	pid := proc.snapshot().PID
+	rec = post(`{"jsonrpc":"2.0","id":9,"method":"initialize","params":{}}`)
+	if !strings.Contains(rec.Body.String(), `"id":9`) || !strings.Contains(rec.Body.String(), `"serverInfo"`) || proc.snapshot().PID != pid {
+		t.Fatalf("second initialize: %s", rec.Body.String())
+	}
+	if rec = post(`{"jsonrpc":"2.0","method":"notifications/initialized"}`); rec.Code != http.StatusAccepted {
+		t.Fatalf("notification status = %d", rec.Code)
+	}
+
+	rec = post(`{"jsonrpc":"2.0","id":"list-1","method":"tools/list"}`)
+	var resp struct {
+		ID     string `json:"id"`
+		Result struct {
+			Tools []struct {
+				Name string `json:"name"`
+			} `json:"tools"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.ID != "list-1" || len(resp.Result.Tools) != 2 {
+		t.Fatalf("tools/list: %s", rec.Body.String())
+	}
+}
+
+func TestMCPGatewayAuthorized(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/mcp/a/sse?token=secret", nil)
+	if !mcpGatewayAuthorized(req, "secret") {
+		t.Fatal("query token rejected")
+	}
+	req = httptest.NewRequest(http.MethodPost, "/mcp/a", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	if !mcpGatewayAuthorized(req, "secret") {
+		t.Fatal("bearer token rejected")
+	}
+	req.Header.Set("Authorization", "Bearer other")
+	if mcpGatewayAuthorized(req, "secret") {
+		t.Fatal("wrong token accepted")
+	}
+	if mcpGatewayAuthorized(httptest.NewRequest(http.MethodPost, "/mcp/a", nil), "secret") {
+		t.Fatal("missing token accepted")
+	}
+}
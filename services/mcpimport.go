@@ -0,0 +1,277 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+const clineMCPSettingsFile = "cline_mcp_settings.json"
+
+// MCPImportCandidate 从其他工具配置中发现的 MCP 服务。同名服务合并为一条，定义不一致时以第一个来源为准并标记 Conflict
+type MCPImportCandidate struct {
+	Server   MCPServer `json:"server"`
+	Sources  []string  `json:"sources"`
+	Exists   bool      `json:"exists"`
+	Conflict bool      `json:"conflict"`
+}
+
+// mcpImportSource 一个待扫描的配置文件。Platform 非空时导入后自动在对应 CLI 中启用；
+// Project 非空时读取 ~/.claude.json 中该项目的 mcpServers（项目路径可能含 "."，无法用 ServersKey 表达）
+type mcpImportSource struct {
+	Label    string
+	Target   MCPSyncTarget
+	Platform string
+	Project  string
+}
+
+// ScanMCPImports 扫描 Claude Code（含各项目的 .mcp.json）、Codex、Gemini、Cursor、Cline 以及自定义同步目标中的 MCP 服务
+func (is *ImportService) ScanMCPImports() ([]MCPImportCandidate, error) {
+	existing, err := is.mcpService.ListServers()
+	if err != nil {
+		return nil, err
+	}
+	custom, err := loadCustomMCPTargets()
+	if err != nil {
+		return nil, err
+	}
+	candidates := scanMCPImportSources(mcpImportSources(custom))
+	existingNames := make(map[string]struct{}, len(existing))
+	for _, server := range existing {
+		existingNames[normalizeName(server.Name)] = struct{}{}
+	}
+	for i := range candidates {
+		_, candidates[i].Exists = existingNames[normalizeName(candidates[i].Server.Name)]
+	}
+	return candidates, nil
+}
+
+// ImportMCPServersFromConfigs 导入选中的服务，names 为空时导入全部尚未存在的服务；已存在的同名服务不会被覆盖
+func (is *ImportService) ImportMCPServersFromConfigs(names []string) (int, error) {
+	candidates, err := is.ScanMCPImports()
+	if err != nil {
+		return 0, err
+	}
+	selected := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		selected[normalizeName(name)] = struct{}{}
+	}
+	servers := make([]MCPServer, 0, len(candidates))
+	for _, candidate := range candidates {
+		if candidate.Exists {
+			continue
+		}
+		if _, ok := selected[normalizeName(candidate.Server.Name)]; len(selected) > 0 && !ok {
+			continue
+		}
+		servers = append(servers, candidate.Server)
+	}
+	return is.importMCPServers(servers)
+}
+
+func mcpImportSources(custom []MCPSyncTarget) []mcpImportSource {
+	var sources []mcpImportSource
+	for _, target := range append(builtInMCPTargets(), custom...) {
+		sources = append(sources, mcpImportSource{Label: target.Name, Target: target, Platform: target.ID})
+	}
+
+	// Claude Code 的项目级服务：~/.claude.json 中 projects.<path>.mcpServers 与项目根目录的 .mcp.json
+	home := userHomeDir()
+	claudePath := filepath.Join(home, claudeMcpFile)
+	for _, project := range claudeKnownProjects(claudePath) {
+		sources = append(sources,
+			mcpImportSource{
+				Label:   "Claude Code · " + filepath.Base(project),
+				Target:  MCPSyncTarget{ConfigPath: claudePath, Format: mcpFormatJSON},
+				Project: project,
+			},
+			mcpImportSource{
+				Label:  filepath.Join(project, ".mcp.json"),
+				Target: MCPSyncTarget{ConfigPath: filepath.Join(project, ".mcp.json"), Format: mcpFormatJSON, ServersKey: "mcpServers"},
+			},
+		)
+	}
+
+	sources = append(sources, mcpImportSource{
+		Label:  "Cursor",
+		Target: MCPSyncTarget{ConfigPath: filepath.Join(home, ".cursor", "mcp.json"), Format: mcpFormatJSON, ServersKey: "mcpServers"},
+	})
+	// Cline 扩展装在各个 VS Code 系编辑器中，配置位于编辑器的 globalStorage
+	for _, editor := range []string{"Code", "Code - Insiders", "Cursor", "VSCodium", "Windsurf"} {
+		path := filepath.Join(editorConfigDir(), editor, "User", "globalStorage", "saoudrizwan.claude-dev", "settings", clineMCPSettingsFile)
+		sources = append(sources, mcpImportSource{
+			Label:  "Cline · " + editor,
+			Target: MCPSyncTarget{ConfigPath: path, Format: mcpFormatJSON, ServersKey: "mcpServers"},
+		})
+	}
+	return sources
+}
+
+// claudeKnownProjects 返回 ~/.claude.json 中记录过的项目目录
+func claudeKnownProjects(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var payload struct {
+		Projects map[string]json.RawMessage `json:"projects"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil
+	}
+	projects := make([]string, 0, len(payload.Projects))
+	for project := range payload.Projects {
+		if filepath.IsAbs(project) {
+			projects = append(projects, project)
+		}
+	}
+	sort.Strings(projects)
+	return projects
+}
+
+// editorConfigDir VS Code 系编辑器存放用户配置的目录
+func editorConfigDir() string {
+	home := userHomeDir()
+	switch runtime.GOOS {
+	case "darwin":
+		return filepath.Join(home, "Library", "Application Support")
+	case "windows":
+		if dir := os.Getenv("APPDATA"); dir != "" {
+			return dir
+		}
+		return filepath.Join(home, "AppData", "Roaming")
+	default:
+		return filepath.Join(home, ".config")
+	}
+}
+
+// scanMCPImportSources 读取各来源并按名称去重，读取失败的来源跳过
+func scanMCPImportSources(sources []mcpImportSource) []MCPImportCandidate {
+	var candidates []MCPImportCandidate
+	index := make(map[string]int)
+	for _, source := range sources {
+		section, err := readMCPImportSection(source)
+		if err != nil {
+			fmt.Printf("[WARN] 读取 %s 失败: %v\n", source.Target.ConfigPath, err)
+			continue
+		}
+		names := make([]string, 0, len(section))
+		for name := range section {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			entry, ok := section[name].(map[string]any)
+			if !ok {
+				continue
+			}
+			server, ok := parseMCPImportEntry(name, entry)
+			if !ok {
+				continue
+			}
+			enabled := source.Platform != "" && !mcpImportBool(entry["disabled"])
+			key := normalizeName(server.Name)
+			if i, seen := index[key]; seen {
+				candidate := &candidates[i]
+				if !containsPlatform(candidate.Sources, source.Label) {
+					candidate.Sources = append(candidate.Sources, source.Label)
+				}
+				if !sameMCPDefinition(candidate.Server, server) {
+					candidate.Conflict = true
+					continue
+				}
+				if enabled && !containsPlatform(candidate.Server.EnablePlatform, source.Platform) {
+					candidate.Server.EnablePlatform = append(candidate.Server.EnablePlatform, source.Platform)
+				}
+				continue
+			}
+			if enabled {
+				server.EnablePlatform = []string{source.Platform}
+			}
+			index[key] = len(candidates)
+			candidates = append(candidates, MCPImportCandidate{Server: server, Sources: []string{source.Label}})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return strings.ToLower(candidates[i].Server.Name) < strings.ToLower(candidates[j].Server.Name)
+	})
+	return candidates
+}
+
+func readMCPImportSection(source mcpImportSource) (map[string]any, error) {
+	payload, _, err := readMCPTargetFile(source.Target)
+	if err != nil {
+		return nil, err
+	}
+	if source.Project != "" {
+		projects, _ := payload["projects"].(map[string]any)
+		entry, _ := projects[source.Project].(map[string]any)
+		servers, _ := entry["mcpServers"].(map[string]any)
+		return servers, nil
+	}
+	return mcpTargetSection(payload, source.Target.ServersKey, false), nil
+}
+
+// parseMCPImportEntry 兼容各工具的字段：Gemini 的 httpUrl、Cline 的 url（sse）与 Windsurf 的 serverUrl
+func parseMCPImportEntry(name string, entry map[string]any) (MCPServer, bool) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return MCPServer{}, false
+	}
+	command, _ := entry["command"].(string)
+	url := ""
+	for _, key := range []string{"url", "httpUrl", "serverUrl"} {
+		if value, ok := entry[key].(string); ok && strings.TrimSpace(value) != "" {
+			url = value
+			break
+		}
+	}
+	var args []string
+	if list, ok := entry["args"].([]any); ok {
+		for _, item := range list {
+			if value, ok := item.(string); ok {
+				args = append(args, value)
+			}
+		}
+	}
+	env := map[string]string{}
+	if values, ok := entry["env"].(map[string]any); ok {
+		for key, item := range values {
+			if value, ok := item.(string); ok {
+				env[key] = value
+			}
+		}
+	}
+	server := MCPServer{
+		Name:           name,
+		Command:        strings.TrimSpace(command),
+		Args:           cleanArgs(args),
+		Env:            cleanEnv(env),
+		URL:            strings.TrimSpace(url),
+		EnablePlatform: []string{},
+	}
+	switch {
+	case server.URL != "":
+		server.Type = "http"
+		server.Command = ""
+		server.Args = nil
+	case server.Command != "":
+		server.Type = "stdio"
+	default:
+		return MCPServer{}, false
+	}
+	return server, true
+}
+
+func sameMCPDefinition(a, b MCPServer) bool {
+	return a.Type == b.Type && a.Command == b.Command && a.URL == b.URL && strings.Join(a.Args, "\x00") == strings.Join(b.Args, "\x00")
+}
+
+func mcpImportBool(value any) bool {
+	flag, _ := value.(bool)
+	return flag
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestScanMCPImportSources(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	project := filepath.Join(home, "work", "app.v2")
+	writeSkillFiles(t, home, map[string]string{
+		".claude.json": `{"mcpServers":{"fetch":{"command":"uvx","args":["mcp-server-fetch"]}},` +
+			`"projects":{"` + project + `":{"mcpServers":{"db":{"command":"npx","args":["db-mcp"],"env":{"DB_URL":"pg://x"}}}}}}`,
+		".codex/config.toml":    "[mcp_servers.fetch]\ncommand = \"uvx\"\nargs = [\"mcp-server-fetch\"]\n",
+		".gemini/settings.json": `{"mcpServers":{"sentry":{"httpUrl":"https://mcp.sentry.dev/mcp"}}}`,
+		".cursor/mcp.json":      `{"mcpServers":{"fetch":{"command":"node","args":["fetch.js"]},"broken":{}}}`,
+		"work/app.v2/.mcp.json": `{"mcpServers":{"docs":{"type":"sse","url":"http://localhost:9000/sse"}}}`,
+	})
+
+	candidates := scanMCPImportSources(mcpImportSources(nil))
+	byName := make(map[string]MCPImportCandidate, len(candidates))
+	for _, candidate := range candidates {
+		byName[candidate.Server.Name] = candidate
+	}
+	if len(candidates) != 4 {
+		t.Fatalf("candidates = %+v", candidates)
+	}
+
+	fetch := byName["fetch"]
+	if !reflect.DeepEqual(fetch.Server.EnablePlatform, []string{platClaudeCode, platCodex}) || !fetch.Conflict {
+		t.Fatalf("fetch = %+v", fetch)
+	}
+	if !reflect.DeepEqual(fetch.Sources, []string{"Claude Code", "Codex", "Cursor"}) {
+		t.Fatalf("fetch sources = %v", fetch.Sources)
+	}
+	if db := byName["db"]; db.Server.Env["DB_URL"] != "pg://x" || len(db.Server.EnablePlatform) != 0 {
+		t.Fatalf("db = %+v", db)
+	}
+	if sentry := byName["sentry"]; sentry.Server.Type != "http" || !reflect.DeepEqual(sentry.Server.EnablePlatform, []string{platGemini}) {
+		t.Fatalf("sentry = %+v", sentry)
+	}
+	if docs := byName["docs"]; docs.Server.URL != "http://localhost:9000/sse" || docs.Sources[0] != filepath.Join(project, ".mcp.json") {
+		t.Fatalf("docs = %+v", docs)
+	}
+}
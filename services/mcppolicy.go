@@ -0,0 +1,298 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	MCPPolicyAllow = "allow"
+	MCPPolicyDeny  = "deny"
+
+	mcpPoliciesFile = "mcp-policies.json"
+	// mcpPolicyAllTools 表示整个服务的所有工具
+	mcpPolicyAllTools = "*"
+)
+
+// Claude Code 生成工具名时把服务名中的其他字符替换为下划线
+var claudeMCPNamePattern = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// MCPToolPolicy 某个 MCP 服务的工具自动允许或拒绝。Tool 为 "*" 时作用于整个服务
+type MCPToolPolicy struct {
+	Server string `json:"server"`
+	Tool   string `json:"tool"`
+	Action string `json:"action"`
+}
+
+// mcpPolicyStore 除策略本身外还记录上次写入 CLI 配置的内容，修改策略时只清理 code-switch 写入的规则
+type mcpPolicyStore struct {
+	Policies []MCPToolPolicy  `json:"policies"`
+	Applied  mcpPolicyApplied `json:"applied"`
+}
+
+type mcpPolicyApplied struct {
+	ClaudeAllow  []string `json:"claude_allow,omitempty"`
+	ClaudeDeny   []string `json:"claude_deny,omitempty"`
+	CodexServers []string `json:"codex_servers,omitempty"`
+}
+
+// ListMCPToolPolicies 返回所有工具策略
+func (ms *MCPService) ListMCPToolPolicies() ([]MCPToolPolicy, error) {
+	store, err := loadMCPPolicyStore()
+	if err != nil {
+		return nil, err
+	}
+	return store.Policies, nil
+}
+
+// SaveMCPToolPolicies 整体替换工具策略并写入 Claude Code 与 Codex 的配置
+func (ms *MCPService) SaveMCPToolPolicies(policies []MCPToolPolicy) ([]MCPToolPolicy, error) {
+	normalized, err := normalizeMCPToolPolicies(policies)
+	if err != nil {
+		return nil, err
+	}
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	store, err := loadMCPPolicyStore()
+	if err != nil {
+		return nil, err
+	}
+	store.Policies = normalized
+	if err := saveMCPPolicyStore(store); err != nil {
+		return nil, err
+	}
+	return normalized, applyMCPToolPolicies()
+}
+
+func normalizeMCPToolPolicies(policies []MCPToolPolicy) ([]MCPToolPolicy, error) {
+	index := make(map[string]int, len(policies))
+	result := make([]MCPToolPolicy, 0, len(policies))
+	for _, policy := range policies {
+		policy.Server = strings.TrimSpace(policy.Server)
+		policy.Tool = strings.TrimSpace(policy.Tool)
+		policy.Action = strings.ToLower(strings.TrimSpace(policy.Action))
+		if policy.Server == "" {
+			return nil, errors.New("服务名不能为空")
+		}
+		if policy.Tool == "" {
+			policy.Tool = mcpPolicyAllTools
+		}
+		if strings.ContainsAny(policy.Tool, " \t") {
+			return nil, fmt.Errorf("工具名无效: %q", policy.Tool)
+		}
+		if policy.Action != MCPPolicyAllow && policy.Action != MCPPolicyDeny {
+			return nil, fmt.Errorf("策略无效: %q", policy.Action)
+		}
+		// 同一服务同一工具只保留最后一条
+		key := strings.ToLower(policy.Server) + "\x00" + policy.Tool
+		if i, ok := index[key]; ok {
+			result[i] = policy
+			continue
+		}
+		index[key] = len(result)
+		result = append(result, policy)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if !strings.EqualFold(result[i].Server, result[j].Server) {
+			return strings.ToLower(result[i].Server) < strings.ToLower(result[j].Server)
+		}
+		return result[i].Tool < result[j].Tool
+	})
+	return result, nil
+}
+
+// applyMCPToolPolicies 把策略写入 ~/.claude/settings.json 与 ~/.codex/config.toml。
+// 同步服务会整体重写 Codex 的服务条目，因此 SaveServers 之后也需要调用
+func applyMCPToolPolicies() error {
+	store, err := loadMCPPolicyStore()
+	if err != nil {
+		return err
+	}
+	if len(store.Policies) == 0 && len(store.Applied.ClaudeAllow) == 0 && len(store.Applied.ClaudeDeny) == 0 && len(store.Applied.CodexServers) == 0 {
+		return nil
+	}
+	home := userHomeDir()
+	var errs []error
+
+	claude := MCPSyncTarget{Name: "Claude Code", ConfigPath: filepath.Join(home, claudeSettingsDir, claudeSettingsFileName), Format: mcpFormatJSON}
+	if payload, mode, err := readMCPTargetFile(claude); err != nil {
+		errs = append(errs, fmt.Errorf("写入 Claude Code 权限失败: %w", err))
+	} else {
+		allow, deny := applyClaudeMCPPermissions(payload, store.Policies, store.Applied)
+		if err := writeMCPTargetFile(claude, payload, mode); err != nil {
+			errs = append(errs, fmt.Errorf("写入 Claude Code 权限失败: %w", err))
+		} else {
+			store.Applied.ClaudeAllow, store.Applied.ClaudeDeny = allow, deny
+		}
+	}
+
+	codex := builtInMCPTargets()[1]
+	if payload, mode, err := readMCPTargetFile(codex); err != nil {
+		errs = append(errs, fmt.Errorf("写入 Codex 工具策略失败: %w", err))
+	} else if section := mcpTargetSection(payload, codex.ServersKey, false); section != nil || len(store.Applied.CodexServers) > 0 {
+		servers := applyCodexMCPPolicies(section, store.Policies, store.Applied.CodexServers)
+		if err := writeMCPTargetFile(codex, payload, mode); err != nil {
+			errs = append(errs, fmt.Errorf("写入 Codex 工具策略失败: %w", err))
+		} else {
+			store.Applied.CodexServers = servers
+		}
+	}
+
+	if err := saveMCPPolicyStore(store); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func claudeMCPPermissionRule(policy MCPToolPolicy) string {
+	rule := "mcp__" + claudeMCPNamePattern.ReplaceAllString(policy.Server, "_")
+	if policy.Tool != mcpPolicyAllTools {
+		rule += "__" + policy.Tool
+	}
+	return rule
+}
+
+// applyClaudeMCPPermissions 更新 permissions.allow / permissions.deny，保留用户自己写的规则，返回本次写入的规则
+func applyClaudeMCPPermissions(payload map[string]any, policies []MCPToolPolicy, previous mcpPolicyApplied) (allow, deny []string) {
+	for _, policy := range policies {
+		if policy.Action == MCPPolicyAllow {
+			allow = append(allow, claudeMCPPermissionRule(policy))
+		} else {
+			deny = append(deny, claudeMCPPermissionRule(policy))
+		}
+	}
+	permissions, _ := payload["permissions"].(map[string]any)
+	if permissions == nil {
+		permissions = make(map[string]any)
+	}
+	mergeClaudePermissionList(permissions, "allow", previous.ClaudeAllow, allow)
+	mergeClaudePermissionList(permissions, "deny", previous.ClaudeDeny, deny)
+	if len(permissions) == 0 {
+		delete(payload, "permissions")
+	} else {
+		payload["permissions"] = permissions
+	}
+	return allow, deny
+}
+
+func mergeClaudePermissionList(permissions map[string]any, key string, previous, rules []string) {
+	stale := make(map[string]struct{}, len(previous))
+	for _, rule := range previous {
+		stale[rule] = struct{}{}
+	}
+	existing, _ := permissions[key].([]any)
+	seen := make(map[string]struct{}, len(existing)+len(rules))
+	list := make([]any, 0, len(existing)+len(rules))
+	for _, item := range existing {
+		rule, _ := item.(string)
+		if _, managed := stale[rule]; managed {
+			continue
+		}
+		seen[rule] = struct{}{}
+		list = append(list, item)
+	}
+	for _, rule := range rules {
+		if _, dup := seen[rule]; dup {
+			continue
+		}
+		seen[rule] = struct{}{}
+		list = append(list, rule)
+	}
+	if len(list) == 0 {
+		delete(permissions, key)
+		return
+	}
+	permissions[key] = list
+}
+
+// applyCodexMCPPolicies Codex 没有按工具自动批准的配置，拒绝的工具写入 disabled_tools，
+// 拒绝整个服务时设置 enabled = false；返回本次修改过的服务
+func applyCodexMCPPolicies(section map[string]any, policies []MCPToolPolicy, previous []string) []string {
+	for _, name := range previous {
+		if entry, ok := section[name].(map[string]any); ok {
+			delete(entry, "disabled_tools")
+			delete(entry, "enabled")
+		}
+	}
+	disabled := make(map[string][]string)
+	for _, policy := range policies {
+		if policy.Action != MCPPolicyDeny {
+			continue
+		}
+		for name := range section {
+			if strings.EqualFold(name, policy.Server) {
+				disabled[name] = append(disabled[name], policy.Tool)
+			}
+		}
+	}
+	applied := make([]string, 0, len(disabled))
+	for name, tools := range disabled {
+		entry, ok := section[name].(map[string]any)
+		if !ok {
+			continue
+		}
+		if containsPlatform(tools, mcpPolicyAllTools) {
+			entry["enabled"] = false
+		} else {
+			entry["disabled_tools"] = tools
+		}
+		applied = append(applied, name)
+	}
+	sort.Strings(applied)
+	return applied
+}
+
+func mcpPolicyStorePath() (string, error) {
+	dir, err := ensureDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, mcpPoliciesFile), nil
+}
+
+func loadMCPPolicyStore() (mcpPolicyStore, error) {
+	store := mcpPolicyStore{Policies: []MCPToolPolicy{}}
+	path, err := mcpPolicyStorePath()
+	if err != nil {
+		return store, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return store, nil
+		}
+		return store, err
+	}
+	if len(data) == 0 {
+		return store, nil
+	}
+	if err := json.Unmarshal(data, &store); err != nil {
+		return store, err
+	}
+	if store.Policies == nil {
+		store.Policies = []MCPToolPolicy{}
+	}
+	return store, nil
+}
+
+func saveMCPPolicyStore(store mcpPolicyStore) error {
+	path, err := mcpPolicyStorePath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(store, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyClaudeMCPPermissions(t *testing.T) {
+	policies, err := normalizeMCPToolPolicies([]MCPToolPolicy{
+		{Server: "github", Tool: "create_issue", Action: "allow"},
+		{Server: "my.server", Action: "deny"},
+		{Server: "github", Tool: "create_issue", Action: "deny"},
+	})
+	if err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+	payload := map[string]any{
+		"permissions": map[string]any{
+			"allow": []any{"Bash(ls:*)", "mcp__old__tool"},
+			"deny":  []any{"Read(.env)"},
+		},
+	}
+	previous := mcpPolicyApplied{ClaudeAllow: []string{"mcp__old__tool"}}
+	allow, deny := applyClaudeMCPPermissions(payload, policies, previous)
+	if len(allow) != 0 || !reflect.DeepEqual(deny, []string{"mcp__github__create_issue", "mcp__my_server"}) {
+		t.Fatalf("allow = %v, deny = %v", allow, deny)
+	}
+	permissions := payload["permissions"].(map[string]any)
+	if !reflect.DeepEqual(permissions["allow"], []any{"Bash(ls:*)"}) {
+		t.Fatalf("allow list = %v", permissions["allow"])
+	}
+	if !reflect.DeepEqual(permissions["deny"], []any{"Read(.env)", "mcp__github__create_issue", "mcp__my_server"}) {
+		t.Fatalf("deny list = %v", permissions["deny"])
+	}
+
+	if _, err := normalizeMCPToolPolicies([]MCPToolPolicy{{Server: "a", Action: "ask"}}); err == nil {
+		t.Fatal("expected error for unknown action")
+	}
+}
+
+func TestApplyCodexMCPPolicies(t *testing.T) {
+	section := map[string]any{
+		"github": map[string]any{"command": "npx", "disabled_tools": []any{"old"}},
+		"fetch":  map[string]any{"command": "uvx", "enabled": false},
+		"mine":   map[string]any{"command": "mine", "enabled": false},
+	}
+	policies := []MCPToolPolicy{
+		{Server: "GitHub", Tool: "delete_repo", Action: MCPPolicyDeny},
+		{Server: "github", Tool: "list_issues", Action: MCPPolicyAllow},
+	}
+	applied := applyCodexMCPPolicies(section, policies, []string{"github", "fetch"})
+	if !reflect.DeepEqual(applied, []string{"github"}) {
+		t.Fatalf("applied = %v", applied)
+	}
+	if got := section["github"].(map[string]any)["disabled_tools"]; !reflect.DeepEqual(got, []string{"delete_repo"}) {
+		t.Fatalf("github disabled_tools = %v", got)
+	}
+	if _, ok := section["fetch"].(map[string]any)["enabled"]; ok {
+		t.Fatal("fetch should be re-enabled after its policy was removed")
+	}
+	if section["mine"].(map[string]any)["enabled"] != false {
+		t.Fatal("unmanaged server changed")
+	}
+}
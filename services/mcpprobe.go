@@ -0,0 +1,490 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	mcpProbeTimeout         = 20 * time.Second
+	mcpProbeConcurrency     = 4
+	mcpProbeProtocolVersion = "2025-06-18"
+	mcpProbeClientVersion   = "1.0.0"
+
+	mcpHealthOK      = "ok"
+	mcpHealthError   = "error"
+	mcpHealthSkipped = "skipped"
+)
+
+// MCPServerHealth 一次探测的结果：启动（或连接）服务，完成 initialize 握手并列出工具
+type MCPServerHealth struct {
+	Name            string    `json:"name"`
+	Status          string    `json:"status"`
+	ServerName      string    `json:"server_name,omitempty"`
+	ServerVersion   string    `json:"server_version,omitempty"`
+	ProtocolVersion string    `json:"protocol_version,omitempty"`
+	ToolCount       int       `json:"tool_count"`
+	Tools           []string  `json:"tools,omitempty"`
+	Error           string    `json:"error,omitempty"`
+	LatencyMs       int64     `json:"latency_ms"`
+	CheckedAt       time.Time `json:"checked_at"`
+}
+
+type mcpRPCResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type mcpInitializeResult struct {
+	ProtocolVersion string `json:"protocolVersion"`
+	ServerInfo      struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"serverInfo"`
+}
+
+type mcpToolsResult struct {
+	Tools []struct {
+		Name string `json:"name"`
+	} `json:"tools"`
+	NextCursor string `json:"nextCursor"`
+}
+
+// mcpSession 一条 JSON-RPC 通道，stdio 与 HTTP 各自实现
+type mcpSession interface {
+	request(ctx context.Context, id int, method string, params any) (json.RawMessage, error)
+	notify(ctx context.Context, method string, params any) error
+	close()
+}
+
+// CheckMCPServers 并发探测所有服务
+func (ms *MCPService) CheckMCPServers() ([]MCPServerHealth, error) {
+	servers, err := ms.ListServers()
+	if err != nil {
+		return nil, err
+	}
+	results := make([]MCPServerHealth, len(servers))
+	sem := make(chan struct{}, mcpProbeConcurrency)
+	var wg sync.WaitGroup
+	for i := range servers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = probeMCPServer(context.Background(), servers[i])
+		}(i)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// CheckMCPServer 探测单个服务
+func (ms *MCPService) CheckMCPServer(name string) (MCPServerHealth, error) {
+	servers, err := ms.ListServers()
+	if err != nil {
+		return MCPServerHealth{}, err
+	}
+	for _, server := range servers {
+		if strings.EqualFold(server.Name, strings.TrimSpace(name)) {
+			return probeMCPServer(context.Background(), server), nil
+		}
+	}
+	return MCPServerHealth{}, fmt.Errorf("MCP 服务不存在: %s", name)
+}
+
+func probeMCPServer(parent context.Context, server MCPServer) MCPServerHealth {
+	health := MCPServerHealth{Name: server.Name, CheckedAt: time.Now()}
+	if missing := detectPlaceholders(server.URL, server.Args); len(missing) > 0 {
+		health.Status = mcpHealthSkipped
+		health.Error = fmt.Sprintf("请先替换占位符: %s", strings.Join(missing, ", "))
+		return health
+	}
+	server, err := resolveMCPSecrets(server)
+	if err != nil {
+		health.Status = mcpHealthError
+		health.Error = err.Error()
+		return health
+	}
+	ctx, cancel := context.WithTimeout(parent, mcpProbeTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err = runMCPProbe(ctx, server, &health)
+	health.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		health.Status = mcpHealthError
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			health.Error = fmt.Sprintf("%s 内未完成握手: %v", mcpProbeTimeout, err)
+		} else {
+			health.Error = err.Error()
+		}
+		return health
+	}
+	health.Status = mcpHealthOK
+	return health
+}
+
+func runMCPProbe(ctx context.Context, server MCPServer, health *MCPServerHealth) error {
+	var (
+		session mcpSession
+		err     error
+	)
+	if server.Type == "http" {
+		session = newHTTPMCPSession(server.URL, mcpAuthHeaders(server))
+	} else {
+		session, err = startStdioMCPSession(ctx, server)
+		if err != nil {
+			return err
+		}
+	}
+	defer session.close()
+
+	raw, err := session.request(ctx, 1, "initialize", map[string]any{
+		"protocolVersion": mcpProbeProtocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]string{"name": "code-switch", "version": mcpProbeClientVersion},
+	})
+	if err != nil {
+		return fmt.Errorf("initialize 失败: %w", err)
+	}
+	var initResult mcpInitializeResult
+	if err := json.Unmarshal(raw, &initResult); err != nil {
+		return fmt.Errorf("initialize 响应无效: %w", err)
+	}
+	health.ProtocolVersion = initResult.ProtocolVersion
+	health.ServerName = initResult.ServerInfo.Name
+	health.ServerVersion = initResult.ServerInfo.Version
+	if err := session.notify(ctx, "notifications/initialized", nil); err != nil {
+		return fmt.Errorf("initialized 通知失败: %w", err)
+	}
+
+	cursor := ""
+	for id := 2; ; id++ {
+		params := map[string]any{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		raw, err := session.request(ctx, id, "tools/list", params)
+		if err != nil {
+			return fmt.Errorf("tools/list 失败: %w", err)
+		}
+		var tools mcpToolsResult
+		if err := json.Unmarshal(raw, &tools); err != nil {
+			return fmt.Errorf("tools/list 响应无效: %w", err)
+		}
+		for _, tool := range tools.Tools {
+			health.Tools = append(health.Tools, tool.Name)
+		}
+		if tools.NextCursor == "" || tools.NextCursor == cursor {
+			break
+		}
+		cursor = tools.NextCursor
+	}
+	sort.Strings(health.Tools)
+	health.ToolCount = len(health.Tools)
+	return nil
+}
+
+func mcpRequestBody(id int, method string, params any) ([]byte, error) {
+	body := map[string]any{"jsonrpc": "2.0", "method": method}
+	if id > 0 {
+		body["id"] = id
+	}
+	if params != nil {
+		body["params"] = params
+	}
+	return json.Marshal(body)
+}
+
+// decodeMCPResponse 解析与 id 对应的响应，其他消息（通知、服务端请求）返回 nil
+func decodeMCPResponse(data []byte, id int) (json.RawMessage, bool, error) {
+	var resp mcpRPCResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, false, nil
+	}
+	if strings.TrimSpace(string(resp.ID)) != fmt.Sprint(id) {
+		return nil, false, nil
+	}
+	if resp.Error != nil {
+		return nil, true, fmt.Errorf("%d %s", resp.Error.Code, resp.Error.Message)
+	}
+	return resp.Result, true, nil
+}
+
+type stdioMCPSession struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	lines  chan []byte
+	stderr *limitedWriter
+	done   chan struct{}
+}
+
+func startStdioMCPSession(ctx context.Context, server MCPServer) (*stdioMCPSession, error) {
+	cmd := exec.CommandContext(ctx, server.Command, server.Args...)
+	cmd.Env = os.Environ()
+	for key, value := range server.Env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr := &limitedWriter{limit: 4096}
+	cmd.Stderr = stderr
+	// npx 等启动器会派生子进程，子进程仍持有 stdout 时不能让 Wait 一直阻塞
+	cmd.WaitDelay = 2 * time.Second
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("启动 %s 失败: %w", server.Command, err)
+	}
+	session := &stdioMCPSession{cmd: cmd, stdin: stdin, lines: make(chan []byte, 16), stderr: stderr, done: make(chan struct{})}
+	go func() {
+		defer close(session.lines)
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), 8*1024*1024)
+		for scanner.Scan() {
+			line := append([]byte(nil), scanner.Bytes()...)
+			select {
+			case session.lines <- line:
+			case <-session.done:
+				return
+			}
+		}
+	}()
+	return session, nil
+}
+
+func (s *stdioMCPSession) request(ctx context.Context, id int, method string, params any) (json.RawMessage, error) {
+	body, err := mcpRequestBody(id, method, params)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.stdin.Write(append(body, '\n')); err != nil {
+		return nil, s.exitError(err)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case line, ok := <-s.lines:
+			if !ok {
+				return nil, s.exitError(errors.New("进程已退出"))
+			}
+			result, matched, err := decodeMCPResponse(line, id)
+			if matched {
+				return result, err
+			}
+		}
+	}
+}
+
+func (s *stdioMCPSession) notify(_ context.Context, method string, params any) error {
+	body, err := mcpRequestBody(0, method, params)
+	if err != nil {
+		return err
+	}
+	_, err = s.stdin.Write(append(body, '\n'))
+	return err
+}
+
+func (s *stdioMCPSession) close() {
+	close(s.done)
+	_ = s.stdin.Close()
+	if s.cmd.Process != nil {
+		_ = s.cmd.Process.Kill()
+	}
+	_ = s.cmd.Wait()
+}
+
+// exitError 附带 stderr 的最后几行，便于定位启动失败的原因
+func (s *stdioMCPSession) exitError(err error) error {
+	stderr := strings.TrimSpace(s.stderr.String())
+	if stderr == "" {
+		return err
+	}
+	lines := strings.Split(stderr, "\n")
+	if len(lines) > 5 {
+		lines = lines[len(lines)-5:]
+	}
+	return fmt.Errorf("%w: %s", err, strings.Join(lines, "\n"))
+}
+
+// limitedWriter 只保留前 limit 字节的 stderr
+type limitedWriter struct {
+	mu    sync.Mutex
+	buf   bytes.Buffer
+	limit int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if remaining := w.limit - w.buf.Len(); remaining > 0 {
+		if len(p) > remaining {
+			w.buf.Write(p[:remaining])
+		} else {
+			w.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+func (w *limitedWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+// httpMCPSession Streamable HTTP 传输，响应可能是 JSON 也可能是 SSE
+type httpMCPSession struct {
+	url       string
+	headers   map[string]string
+	client    *http.Client
+	sessionID string
+}
+
+func newHTTPMCPSession(url string, headers map[string]string) *httpMCPSession {
+	return &httpMCPSession{url: url, headers: headers, client: &http.Client{}}
+}
+
+func (s *httpMCPSession) post(ctx context.Context, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	req.Header.Set("MCP-Protocol-Version", mcpProbeProtocolVersion)
+	for key, value := range s.headers {
+		req.Header.Set(key, value)
+	}
+	if s.sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", s.sessionID)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if id := resp.Header.Get("Mcp-Session-Id"); id != "" {
+		s.sessionID = id
+	}
+	if resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusUnauthorized {
+			return nil, fmt.Errorf("需要授权 (%s)", resp.Status)
+		}
+		return nil, fmt.Errorf("%s %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	return resp, nil
+}
+
+func (s *httpMCPSession) request(ctx context.Context, id int, method string, params any) (json.RawMessage, error) {
+	body, err := mcpRequestBody(id, method, params)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.post(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		result, matched, err := decodeMCPResponse(data, id)
+		if !matched && err == nil {
+			return nil, fmt.Errorf("响应无效: %s", truncateMCPBody(data))
+		}
+		return result, err
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 8*1024*1024)
+	var event strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "data:") {
+			event.WriteString(strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+			continue
+		}
+		if line != "" || event.Len() == 0 {
+			continue
+		}
+		result, matched, err := decodeMCPResponse([]byte(event.String()), id)
+		if matched {
+			return result, err
+		}
+		event.Reset()
+	}
+	if event.Len() > 0 {
+		if result, matched, err := decodeMCPResponse([]byte(event.String()), id); matched {
+			return result, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("事件流结束但没有收到响应")
+}
+
+func (s *httpMCPSession) notify(ctx context.Context, method string, params any) error {
+	body, err := mcpRequestBody(0, method, params)
+	if err != nil {
+		return err
+	}
+	resp, err := s.post(ctx, body)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *httpMCPSession) close() {
+	if s.sessionID == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.url, nil)
+	if err != nil {
+		return
+	}
+	for key, value := range s.headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Mcp-Session-Id", s.sessionID)
+	if resp, err := s.client.Do(req); err == nil {
+		resp.Body.Close()
+	}
+}
+
+func truncateMCPBody(data []byte) string {
+	text := strings.TrimSpace(string(data))
+	if len(text) > 200 {
+		return text[:200] + "..."
+	}
+	return text
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestMCPHelperProcess 作为 stdio MCP 服务被探测测试启动，正常运行测试时直接返回
+func TestMCPHelperProcess(t *testing.T) {
+	switch os.Getenv("CODE_SWITCH_MCP_HELPER") {
+	case "1":
+	case "crash":
+		fmt.Fprintln(os.Stderr, "boom")
+		os.Exit(3)
+	default:
+		return
+	}
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var msg struct {
+			ID     *int   `json:"id"`
+			Method string `json:"method"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &msg) != nil || msg.ID == nil {
+			continue
+		}
+		// 先输出一条日志通知，探测时应忽略
+		fmt.Println(`{"jsonrpc":"2.0","method":"notifications/message","params":{}}`)
+		switch msg.Method {
+		case "initialize":
+			fmt.Printf(`{"jsonrpc":"2.0","id":%d,"result":{"protocolVersion":"2025-06-18","serverInfo":{"name":"helper","version":"0.1.0"}}}`+"\n", *msg.ID)
+		case "tools/list":
+			fmt.Printf(`{"jsonrpc":"2.0","id":%d,"result":{"tools":[{"name":"b"},{"name":"a"}]}}`+"\n", *msg.ID)
+		case "tools/call":
+			fmt.Printf(`{"jsonrpc":"2.0","id":%d,"result":{"content":[{"type":"text","text":"bad input"}],"isError":true}}`+"\n", *msg.ID)
+		default:
+			fmt.Printf(`{"jsonrpc":"2.0","id":%d,"error":{"code":-32601,"message":"Method not found"}}`+"\n", *msg.ID)
+		}
+	}
+	os.Exit(0)
+}
+
+func TestProbeStdioMCPServer(t *testing.T) {
+	server := MCPServer{
+		Name:    "helper",
+		Type:    "stdio",
+		Command: os.Args[0],
+		Args:    []string{"-test.run=TestMCPHelperProcess"},
+		Env:     map[string]string{"CODE_SWITCH_MCP_HELPER": "1"},
+	}
+	health := probeMCPServer(context.Background(), server)
+	if health.Status != mcpHealthOK || health.ServerName != "helper" || health.ServerVersion != "0.1.0" || strings.Join(health.Tools, ",") != "a,b" || health.ToolCount != 2 {
+		t.Fatalf("health = %+v", health)
+	}
+
+	broken := probeMCPServer(context.Background(), MCPServer{Name: "broken", Type: "stdio", Command: "code-switch-missing-mcp-binary"})
+	if broken.Status != mcpHealthError || broken.Error == "" {
+		t.Fatalf("broken = %+v", broken)
+	}
+	skipped := probeMCPServer(context.Background(), MCPServer{Name: "ref", Type: "http", URL: "https://example.com/mcp?key={apiKey}"})
+	if skipped.Status != mcpHealthSkipped {
+		t.Fatalf("skipped = %+v", skipped)
+	}
+}
+
+func TestProbeHTTPMCPServer(t *testing.T) {
+	var sawSession bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			return
+		}
+		var msg struct {
+			ID     *int   `json:"id"`
+			Method string `json:"method"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&msg)
+		switch msg.Method {
+		case "initialize":
+			w.Header().Set("Mcp-Session-Id", "s1")
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%d,"result":{"protocolVersion":"2025-03-26","serverInfo":{"name":"remote","version":"2.0"}}}`, *msg.ID)
+		case "notifications/initialized":
+			w.WriteHeader(http.StatusAccepted)
+		case "tools/list":
+			sawSession = r.Header.Get("Mcp-Session-Id") == "s1"
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n")
+			fmt.Fprintf(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"id\":%d,\"result\":{\"tools\":[{\"name\":\"search\"}]}}\n\n", *msg.ID)
+		}
+	}))
+	defer srv.Close()
+
+	health := probeMCPServer(context.Background(), MCPServer{Name: "remote", Type: "http", URL: srv.URL})
+	if health.Status != mcpHealthOK || health.ProtocolVersion != "2025-03-26" || health.ToolCount != 1 || !sawSession {
+		t.Fatalf("health = %+v, session = %v", health, sawSession)
+	}
+
+	unauthorized := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer unauthorized.Close()
+	if health := probeMCPServer(context.Background(), MCPServer{Name: "auth", Type: "http", URL: unauthorized.URL}); health.Status != mcpHealthError || !strings.Contains(health.Error, "授权") {
+		t.Fatalf("health = %+v", health)
+	}
+}
@@ -0,0 +1,522 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daodao97/xgo/xdb"
+)
+
+const (
+	mcpLogTable = "mcp_log"
+
+	MCPProcessStopped  = "stopped"
+	MCPProcessRunning  = "running"
+	MCPProcessBackoff  = "backoff"
+	MCPProcessFailed   = "failed"
+	mcpLogStreamStderr = "stderr"
+	mcpLogStreamEvent  = "event"
+
+	mcpRestartBaseDelay = time.Second
+	mcpRestartMaxDelay  = time.Minute
+	// 运行超过该时长后退出视为偶发崩溃，退避时间重新计算
+	mcpRestartResetAfter = time.Minute
+	// 连续快速崩溃的次数上限，超过后停止重启
+	mcpRestartMaxAttempts = 8
+	mcpLogLineLimit       = 2000
+	mcpStopTimeout        = 5 * time.Second
+)
+
+// MCPProcessStatus 托管 stdio MCP 进程的运行状态
+type MCPProcessStatus struct {
+	Name      string    `json:"name"`
+	State     string    `json:"state"`
+	PID       int       `json:"pid,omitempty"`
+	StartedAt time.Time `json:"started_at,omitempty"`
+	Restarts  int       `json:"restarts"`
+	NextRetry time.Time `json:"next_retry,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// MCPLogEntry 托管进程的 stderr 输出与启动、退出事件
+type MCPLogEntry struct {
+	ID        int64     `json:"id"`
+	Server    string    `json:"server"`
+	Stream    string    `json:"stream"`
+	Line      string    `json:"line"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// mcpProcess 由 code-switch 启动并负责重启的 MCP 服务进程。stdout 按行广播给订阅者（例如 MCP 网关），
+// 写入 stdin 的内容原样转发
+type mcpProcess struct {
+	name   string
+	server MCPServer
+
+	mu          sync.Mutex
+	cmd         *exec.Cmd
+	stdin       io.WriteCloser
+	status      MCPProcessStatus
+	stopping    bool
+	attempts    int
+	retry       *time.Timer
+	subscribers map[int]chan []byte
+	nextSubID   int
+	onChange    func(MCPProcessStatus)
+}
+
+// SetMCPProcessHandler 托管进程状态变化时回调
+func (ms *MCPService) SetMCPProcessHandler(handler func(MCPProcessStatus)) {
+	ms.procMu.Lock()
+	defer ms.procMu.Unlock()
+	ms.processHandler = handler
+}
+
+// ListMCPProcesses 返回所有开启托管的 stdio 服务及其进程状态
+func (ms *MCPService) ListMCPProcesses() ([]MCPProcessStatus, error) {
+	servers, err := ms.ListServers()
+	if err != nil {
+		return nil, err
+	}
+	ms.procMu.Lock()
+	defer ms.procMu.Unlock()
+	statuses := make([]MCPProcessStatus, 0, len(servers))
+	for _, server := range servers {
+		if !server.Managed || server.Type != "stdio" {
+			continue
+		}
+		if proc, ok := ms.processes[server.Name]; ok {
+			statuses = append(statuses, proc.snapshot())
+		} else {
+			statuses = append(statuses, MCPProcessStatus{Name: server.Name, State: MCPProcessStopped})
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses, nil
+}
+
+// StartMCPProcess 启动托管进程，已在运行时直接返回当前状态
+func (ms *MCPService) StartMCPProcess(name string) (MCPProcessStatus, error) {
+	proc, err := ms.ensureMCPProcess(name)
+	if err != nil {
+		return MCPProcessStatus{}, err
+	}
+	return proc.snapshot(), nil
+}
+
+// StopMCPProcess 停止托管进程，不会触发自动重启
+func (ms *MCPService) StopMCPProcess(name string) (MCPProcessStatus, error) {
+	ms.procMu.Lock()
+	proc, ok := ms.processes[strings.TrimSpace(name)]
+	if ok {
+		delete(ms.processes, proc.name)
+	}
+	ms.procMu.Unlock()
+	if !ok {
+		return MCPProcessStatus{Name: name, State: MCPProcessStopped}, nil
+	}
+	proc.stop()
+	return proc.snapshot(), nil
+}
+
+// RestartMCPProcess 停止后重新启动，并清零重启计数
+func (ms *MCPService) RestartMCPProcess(name string) (MCPProcessStatus, error) {
+	if _, err := ms.StopMCPProcess(name); err != nil {
+		return MCPProcessStatus{}, err
+	}
+	return ms.StartMCPProcess(name)
+}
+
+// Stop 退出应用时停止版本检查与全部托管进程
+func (ms *MCPService) Stop() error {
+	ms.stopVersionChecks()
+	ms.procMu.Lock()
+	processes := ms.processes
+	ms.processes = nil
+	ms.procMu.Unlock()
+	var wg sync.WaitGroup
+	for _, proc := range processes {
+		wg.Add(1)
+		go func(proc *mcpProcess) {
+			defer wg.Done()
+			proc.stop()
+		}(proc)
+	}
+	wg.Wait()
+	return nil
+}
+
+// ensureMCPProcess 按需启动托管进程，供 API 与网关共用
+func (ms *MCPService) ensureMCPProcess(name string) (*mcpProcess, error) {
+	name = strings.TrimSpace(name)
+	servers, err := ms.ListServers()
+	if err != nil {
+		return nil, err
+	}
+	var server *MCPServer
+	for i := range servers {
+		if servers[i].Name == name {
+			server = &servers[i]
+			break
+		}
+	}
+	if server == nil {
+		return nil, fmt.Errorf("MCP 服务不存在: %s", name)
+	}
+	if server.Type != "stdio" {
+		return nil, fmt.Errorf("%s 不是 stdio 服务，无需托管进程", name)
+	}
+	if !server.Managed {
+		return nil, fmt.Errorf("%s 未开启进程托管", name)
+	}
+	if missing := detectPlaceholders(server.URL, server.Args); len(missing) > 0 {
+		return nil, fmt.Errorf("请先替换占位符: %s", strings.Join(missing, ", "))
+	}
+	resolved, err := resolveMCPSecrets(*server)
+	if err != nil {
+		return nil, err
+	}
+
+	ms.procMu.Lock()
+	if ms.processes == nil {
+		ms.processes = make(map[string]*mcpProcess)
+	}
+	proc, ok := ms.processes[name]
+	if !ok {
+		proc = &mcpProcess{name: name, server: resolved, subscribers: make(map[int]chan []byte), onChange: ms.notifyMCPProcess}
+		proc.status = MCPProcessStatus{Name: name, State: MCPProcessStopped}
+		ms.processes[name] = proc
+	}
+	ms.procMu.Unlock()
+
+	if err := proc.start(); err != nil {
+		return nil, err
+	}
+	return proc, nil
+}
+
+// stopUnmanagedProcesses 服务被删除或关闭托管后停止对应进程
+func (ms *MCPService) stopUnmanagedProcesses(servers []MCPServer) {
+	keep := make(map[string]struct{}, len(servers))
+	for _, server := range servers {
+		if server.Managed && server.Type == "stdio" {
+			keep[server.Name] = struct{}{}
+		}
+	}
+	ms.procMu.Lock()
+	var stale []*mcpProcess
+	for name, proc := range ms.processes {
+		if _, ok := keep[name]; !ok {
+			stale = append(stale, proc)
+			delete(ms.processes, name)
+		}
+	}
+	ms.procMu.Unlock()
+	for _, proc := range stale {
+		go proc.stop()
+	}
+}
+
+func (ms *MCPService) notifyMCPProcess(status MCPProcessStatus) {
+	ms.procMu.Lock()
+	handler := ms.processHandler
+	ms.procMu.Unlock()
+	if handler != nil {
+		handler(status)
+	}
+}
+
+func (p *mcpProcess) snapshot() MCPProcessStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.status
+}
+
+func (p *mcpProcess) start() error {
+	p.mu.Lock()
+	if p.cmd != nil {
+		p.mu.Unlock()
+		return nil
+	}
+	if p.retry != nil {
+		p.retry.Stop()
+		p.retry = nil
+	}
+	p.stopping = false
+	err := p.launchLocked()
+	status := p.status
+	p.mu.Unlock()
+	p.onChange(status)
+	return err
+}
+
+func (p *mcpProcess) launchLocked() error {
+	cmd := exec.Command(p.server.Command, p.server.Args...)
+	cmd.Env = os.Environ()
+	for key, value := range p.server.Env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		p.status.State = MCPProcessFailed
+		p.status.LastError = err.Error()
+		recordMCPLog(p.name, mcpLogStreamEvent, fmt.Sprintf("启动失败: %v", err))
+		return fmt.Errorf("启动 %s 失败: %w", p.name, err)
+	}
+	p.cmd = cmd
+	p.stdin = stdin
+	p.status.State = MCPProcessRunning
+	p.status.PID = cmd.Process.Pid
+	p.status.StartedAt = time.Now()
+	p.status.NextRetry = time.Time{}
+	recordMCPLog(p.name, mcpLogStreamEvent, fmt.Sprintf("已启动 (pid %d)", cmd.Process.Pid))
+
+	var readers sync.WaitGroup
+	readers.Add(2)
+	go func() {
+		defer readers.Done()
+		p.pumpStdout(stdout)
+	}()
+	go func() {
+		defer readers.Done()
+		scanner := bufio.NewScanner(stderr)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			recordMCPLog(p.name, mcpLogStreamStderr, scanner.Text())
+		}
+	}()
+	go func() {
+		readers.Wait()
+		p.handleExit(cmd, cmd.Wait())
+	}()
+	return nil
+}
+
+func (p *mcpProcess) pumpStdout(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 8*1024*1024)
+	for scanner.Scan() {
+		line := append([]byte(nil), scanner.Bytes()...)
+		p.mu.Lock()
+		for _, ch := range p.subscribers {
+			select {
+			case ch <- line:
+			default:
+				// 订阅者处理不过来时丢弃，避免阻塞进程输出
+			}
+		}
+		p.mu.Unlock()
+	}
+}
+
+func (p *mcpProcess) handleExit(cmd *exec.Cmd, waitErr error) {
+	p.mu.Lock()
+	if p.cmd != cmd {
+		p.mu.Unlock()
+		return
+	}
+	p.cmd = nil
+	p.stdin = nil
+	p.status.PID = 0
+	ranFor := time.Since(p.status.StartedAt)
+	exitMsg := "进程已退出"
+	if waitErr != nil {
+		exitMsg = fmt.Sprintf("进程已退出: %v", waitErr)
+	}
+	if p.stopping {
+		p.status.State = MCPProcessStopped
+		p.status.LastError = ""
+		status := p.status
+		p.mu.Unlock()
+		recordMCPLog(p.name, mcpLogStreamEvent, "已停止")
+		p.onChange(status)
+		return
+	}
+
+	p.status.LastError = exitMsg
+	if ranFor >= mcpRestartResetAfter {
+		p.attempts = 0
+	}
+	p.attempts++
+	if p.attempts > mcpRestartMaxAttempts {
+		p.status.State = MCPProcessFailed
+		status := p.status
+		p.mu.Unlock()
+		recordMCPLog(p.name, mcpLogStreamEvent, fmt.Sprintf("%s，连续崩溃 %d 次，不再重启", exitMsg, mcpRestartMaxAttempts))
+		p.onChange(status)
+		return
+	}
+	delay := mcpRestartBaseDelay << (p.attempts - 1)
+	if delay > mcpRestartMaxDelay {
+		delay = mcpRestartMaxDelay
+	}
+	p.status.State = MCPProcessBackoff
+	p.status.NextRetry = time.Now().Add(delay)
+	p.retry = time.AfterFunc(delay, p.restartAfterBackoff)
+	status := p.status
+	p.mu.Unlock()
+	recordMCPLog(p.name, mcpLogStreamEvent, fmt.Sprintf("%s，%s 后重启", exitMsg, delay))
+	p.onChange(status)
+}
+
+func (p *mcpProcess) restartAfterBackoff() {
+	p.mu.Lock()
+	if p.stopping || p.cmd != nil {
+		p.mu.Unlock()
+		return
+	}
+	p.retry = nil
+	p.status.Restarts++
+	_ = p.launchLocked()
+	status := p.status
+	p.mu.Unlock()
+	p.onChange(status)
+}
+
+func (p *mcpProcess) stop() {
+	p.mu.Lock()
+	p.stopping = true
+	if p.retry != nil {
+		p.retry.Stop()
+		p.retry = nil
+	}
+	cmd := p.cmd
+	stdin := p.stdin
+	if cmd == nil {
+		p.status.State = MCPProcessStopped
+		p.status.NextRetry = time.Time{}
+		status := p.status
+		p.mu.Unlock()
+		p.onChange(status)
+		return
+	}
+	p.mu.Unlock()
+
+	// 先关闭 stdin 让服务自行退出，超时后强制结束
+	_ = stdin.Close()
+	deadline := time.Now().Add(mcpStopTimeout)
+	for time.Now().Before(deadline) {
+		if p.snapshot().State == MCPProcessStopped {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	_ = cmd.Process.Kill()
+}
+
+// send 把一行 JSON-RPC 消息写入进程 stdin
+func (p *mcpProcess) send(line []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stdin == nil {
+		return errors.New("进程未运行")
+	}
+	_, err := p.stdin.Write(append(line, '\n'))
+	return err
+}
+
+// subscribe 订阅 stdout 的每一行，返回的函数用于取消订阅
+func (p *mcpProcess) subscribe() (<-chan []byte, func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	id := p.nextSubID
+	p.nextSubID++
+	ch := make(chan []byte, 64)
+	p.subscribers[id] = ch
+	return ch, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		delete(p.subscribers, id)
+	}
+}
+
+func recordMCPLog(server, stream, line string) {
+	if len(line) > mcpLogLineLimit {
+		line = line[:mcpLogLineLimit] + "..."
+	}
+	if _, err := xdb.New(mcpLogTable).Insert(xdb.Record{
+		"server": server,
+		"stream": stream,
+		"line":   line,
+	}); err != nil && !isNoSuchTableErr(err) {
+		fmt.Printf("[WARN] 写入 mcp_log 失败: %v\n", err)
+	}
+}
+
+// ListMCPLogs 查询托管 MCP 进程的日志，server 为空时返回全部
+func (ls *LogService) ListMCPLogs(server string, limit int) ([]MCPLogEntry, error) {
+	if limit <= 0 {
+		limit = 200
+	}
+	if limit > 2000 {
+		limit = 2000
+	}
+	options := []xdb.Option{
+		xdb.OrderByDesc("id"),
+		xdb.Limit(limit),
+	}
+	if server = strings.TrimSpace(server); server != "" {
+		options = append(options, xdb.WhereEq("server", server))
+	}
+	records, err := xdb.New(mcpLogTable).Selects(options...)
+	if err != nil {
+		if errors.Is(err, xdb.ErrNotFound) || isNoSuchTableErr(err) {
+			return []MCPLogEntry{}, nil
+		}
+		return nil, err
+	}
+	entries := make([]MCPLogEntry, 0, len(records))
+	for _, record := range records {
+		createdAt, _ := parseCreatedAt(record)
+		entries = append(entries, MCPLogEntry{
+			ID:        record.GetInt64("id"),
+			Server:    record.GetString("server"),
+			Stream:    record.GetString("stream"),
+			Line:      record.GetString("line"),
+			CreatedAt: createdAt,
+		})
+	}
+	return entries, nil
+}
+
+func ensureMCPLogTable() error {
+	db, err := xdb.DB("default")
+	if err != nil {
+		return err
+	}
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS mcp_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			server TEXT,
+			stream TEXT,
+			line TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_mcp_log_server ON mcp_log (server, id)`,
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
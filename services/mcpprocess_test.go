@@ -0,0 +1,91 @@
+package services
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestMCPProcess(mode string, onChange func(MCPProcessStatus)) *mcpProcess {
+	return &mcpProcess{
+		name: "helper",
+		server: MCPServer{
+			Name:    "helper",
+			Type:    "stdio",
+			Command: os.Args[0],
+			Args:    []string{"-test.run=TestMCPHelperProcess"},
+			Env:     map[string]string{"CODE_SWITCH_MCP_HELPER": mode},
+		},
+		status:      MCPProcessStatus{Name: "helper", State: MCPProcessStopped},
+		subscribers: make(map[int]chan []byte),
+		onChange:    onChange,
+	}
+}
+
+func TestMCPProcessLifecycle(t *testing.T) {
+	proc := newTestMCPProcess("1", func(MCPProcessStatus) {})
+	if err := proc.start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if status := proc.snapshot(); status.State != MCPProcessRunning || status.PID == 0 {
+		t.Fatalf("status = %+v", status)
+	}
+	lines, cancel := proc.subscribe()
+	defer cancel()
+	if err := proc.send([]byte(`{"jsonrpc":"2.0","id":7,"method":"tools/list"}`)); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	timeout := time.After(5 * time.Second)
+	for found := false; !found; {
+		select {
+		case line := <-lines:
+			found = strings.Contains(string(line), `"id":7`)
+		case <-timeout:
+			t.Fatal("no response from managed process")
+		}
+	}
+	proc.stop()
+	if status := proc.snapshot(); status.State != MCPProcessStopped || status.PID != 0 {
+		t.Fatalf("status after stop = %+v", status)
+	}
+	if err := proc.send([]byte(`{}`)); err == nil {
+		t.Fatal("send should fail after stop")
+	}
+}
+
+func TestMCPProcessRestartsWithBackoff(t *testing.T) {
+	var mu sync.Mutex
+	var states []string
+	proc := newTestMCPProcess("crash", func(status MCPProcessStatus) {
+		mu.Lock()
+		defer mu.Unlock()
+		states = append(states, status.State)
+	})
+	if err := proc.start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for proc.snapshot().State != MCPProcessBackoff {
+		if time.Now().After(deadline) {
+			t.Fatalf("status = %+v", proc.snapshot())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	status := proc.snapshot()
+	if status.LastError == "" || status.NextRetry.IsZero() || time.Until(status.NextRetry) > mcpRestartBaseDelay {
+		t.Fatalf("status = %+v", status)
+	}
+	// 退避期间停止后不应再重启
+	proc.stop()
+	time.Sleep(mcpRestartBaseDelay + 200*time.Millisecond)
+	if status := proc.snapshot(); status.State != MCPProcessStopped || status.Restarts != 0 {
+		t.Fatalf("status after stop = %+v", status)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(states, ",") != "running,backoff,stopped" {
+		t.Fatalf("states = %v", states)
+	}
+}
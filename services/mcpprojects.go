@@ -0,0 +1,252 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	MCPScopeGlobal  = "global"
+	MCPScopeProject = "project"
+
+	mcpProjectsFile    = "mcp-projects.json"
+	mcpProjectFileName = ".mcp.json"
+)
+
+// mcpProjectRecord 记录写入过某个项目 .mcp.json 的服务，删除或取消分配时只清理这些条目；
+// Created 表示文件由 code-switch 创建，清空后会一并删除
+type mcpProjectRecord struct {
+	Servers []string `json:"servers"`
+	Created bool     `json:"created,omitempty"`
+}
+
+// MCPProject 可分配 MCP 服务的项目目录
+type MCPProject struct {
+	Path    string   `json:"path"`
+	Name    string   `json:"name"`
+	Exists  bool     `json:"exists"`
+	Servers []string `json:"servers"`
+}
+
+// ListMCPProjects 返回 Claude Code 记录过的项目与已分配服务的项目，供选择项目根目录
+func (ms *MCPService) ListMCPProjects() ([]MCPProject, error) {
+	records, err := loadMCPProjectRecords()
+	if err != nil {
+		return nil, err
+	}
+	paths := make(map[string]struct{})
+	for _, path := range claudeKnownProjects(filepath.Join(userHomeDir(), claudeMcpFile)) {
+		paths[filepath.Clean(path)] = struct{}{}
+	}
+	for path := range records {
+		paths[path] = struct{}{}
+	}
+	projects := make([]MCPProject, 0, len(paths))
+	for path := range paths {
+		info, err := os.Stat(path)
+		servers := records[path].Servers
+		if servers == nil {
+			servers = []string{}
+		}
+		projects = append(projects, MCPProject{
+			Path:    path,
+			Name:    filepath.Base(path),
+			Exists:  err == nil && info.IsDir(),
+			Servers: servers,
+		})
+	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].Path < projects[j].Path })
+	return projects, nil
+}
+
+func normalizeMCPScope(value string) string {
+	if strings.EqualFold(strings.TrimSpace(value), MCPScopeProject) {
+		return MCPScopeProject
+	}
+	return MCPScopeGlobal
+}
+
+func normalizeMCPProjects(values []string) ([]string, error) {
+	result := make([]string, 0, len(values))
+	seen := make(map[string]struct{}, len(values))
+	for _, value := range values {
+		path := strings.TrimSpace(value)
+		if path == "" {
+			continue
+		}
+		if path == "~" || strings.HasPrefix(path, "~/") {
+			path = filepath.Join(userHomeDir(), strings.TrimPrefix(path, "~"))
+		}
+		if !filepath.IsAbs(path) {
+			return nil, fmt.Errorf("项目路径需要是绝对路径: %s", value)
+		}
+		path = filepath.Clean(path)
+		if _, dup := seen[path]; dup {
+			continue
+		}
+		seen[path] = struct{}{}
+		result = append(result, path)
+	}
+	return result, nil
+}
+
+// globalMCPServers 项目级服务不写入 CLI 的全局配置，清空启用平台后交给 syncTargets 从全局配置中移除
+func globalMCPServers(servers []MCPServer) []MCPServer {
+	result := make([]MCPServer, len(servers))
+	for i, server := range servers {
+		if server.Scope == MCPScopeProject {
+			server.EnablePlatform = []string{}
+		}
+		result[i] = server
+	}
+	return result
+}
+
+// syncMCPProjects 按分配写入各项目根目录的 .mcp.json，并移除已取消分配的服务
+func syncMCPProjects(servers []MCPServer) error {
+	records, err := loadMCPProjectRecords()
+	if err != nil {
+		return err
+	}
+	desired := make(map[string][]MCPServer)
+	for _, server := range servers {
+		if server.Scope != MCPScopeProject || len(server.MissingPlaceholders) > 0 {
+			continue
+		}
+		for _, project := range server.Projects {
+			desired[project] = append(desired[project], server)
+		}
+	}
+	roots := make(map[string]struct{}, len(records)+len(desired))
+	for root := range records {
+		roots[root] = struct{}{}
+	}
+	for root := range desired {
+		roots[root] = struct{}{}
+	}
+
+	var errs []error
+	next := make(map[string]mcpProjectRecord, len(roots))
+	for root := range roots {
+		record, err := writeMCPProjectFile(root, desired[root], records[root])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("同步 MCP 到项目 %s 失败: %w", root, err))
+			// 写入失败时保留原记录，下次同步时仍能清理
+			record = records[root]
+		}
+		if len(record.Servers) > 0 {
+			next[root] = record
+		}
+	}
+	if err := saveMCPProjectRecords(next); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func writeMCPProjectFile(root string, servers []MCPServer, record mcpProjectRecord) (mcpProjectRecord, error) {
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		if len(servers) == 0 {
+			// 项目目录已不存在，无需清理
+			return mcpProjectRecord{}, nil
+		}
+		return record, fmt.Errorf("项目目录不存在: %s", root)
+	}
+	target := MCPSyncTarget{ConfigPath: filepath.Join(root, mcpProjectFileName), Format: mcpFormatJSON, ServersKey: "mcpServers"}
+	created := record.Created || !fileExists(target.ConfigPath)
+	payload, mode, err := readMCPTargetFile(target)
+	if err != nil {
+		return record, err
+	}
+	section := mcpTargetSection(payload, target.ServersKey, true)
+
+	wanted := make(map[string]struct{}, len(servers))
+	for _, server := range servers {
+		wanted[strings.ToLower(server.Name)] = struct{}{}
+	}
+	for _, name := range record.Servers {
+		if _, keep := wanted[strings.ToLower(name)]; keep {
+			continue
+		}
+		for key := range section {
+			if strings.EqualFold(key, name) {
+				delete(section, key)
+			}
+		}
+	}
+	next := mcpProjectRecord{Servers: make([]string, 0, len(servers)), Created: created}
+	for _, server := range servers {
+		section[server.Name] = buildClaudeDesktopEntry(server)
+		next.Servers = append(next.Servers, server.Name)
+	}
+	sort.Strings(next.Servers)
+
+	if len(section) == 0 && len(payload) == 1 && created {
+		if err := os.Remove(target.ConfigPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return record, err
+		}
+		return mcpProjectRecord{}, nil
+	}
+	if len(servers) == 0 && len(record.Servers) == 0 {
+		return next, nil
+	}
+	data, err := json.MarshalIndent(payload, "", "  ")
+	if err != nil {
+		return record, err
+	}
+	if err := os.WriteFile(target.ConfigPath, data, mode); err != nil {
+		return record, err
+	}
+	return next, nil
+}
+
+func mcpProjectsRecordPath() (string, error) {
+	dir, err := ensureDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, mcpProjectsFile), nil
+}
+
+func loadMCPProjectRecords() (map[string]mcpProjectRecord, error) {
+	records := map[string]mcpProjectRecord{}
+	path, err := mcpProjectsRecordPath()
+	if err != nil {
+		return records, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return records, nil
+		}
+		return records, err
+	}
+	if len(data) == 0 {
+		return records, nil
+	}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func saveMCPProjectRecords(records map[string]mcpProjectRecord) error {
+	path, err := mcpProjectsRecordPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
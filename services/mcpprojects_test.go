@@ -0,0 +1,73 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWriteMCPProjectFile(t *testing.T) {
+	server := MCPServer{Name: "db", Type: "stdio", Command: "npx", Args: []string{"db-mcp"}, Scope: MCPScopeProject}
+
+	// 已有 .mcp.json 的项目：只增删自己写入的条目
+	shared := t.TempDir()
+	writeSkillFiles(t, shared, map[string]string{".mcp.json": `{"mcpServers":{"mine":{"command":"mine"}}}`})
+	record, err := writeMCPProjectFile(shared, []MCPServer{server}, mcpProjectRecord{})
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if record.Created || !reflect.DeepEqual(record.Servers, []string{"db"}) {
+		t.Fatalf("record = %+v", record)
+	}
+	if names := readProjectServerNames(t, shared); !reflect.DeepEqual(names, map[string]bool{"mine": true, "db": true}) {
+		t.Fatalf("servers = %v", names)
+	}
+	record, err = writeMCPProjectFile(shared, nil, record)
+	if err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if len(record.Servers) != 0 {
+		t.Fatalf("record after removal = %+v", record)
+	}
+	if names := readProjectServerNames(t, shared); !reflect.DeepEqual(names, map[string]bool{"mine": true}) {
+		t.Fatalf("servers after removal = %v", names)
+	}
+
+	// 由 code-switch 创建的 .mcp.json 清空后删除
+	fresh := t.TempDir()
+	record, err = writeMCPProjectFile(fresh, []MCPServer{server}, mcpProjectRecord{})
+	if err != nil || !record.Created {
+		t.Fatalf("record = %+v, err = %v", record, err)
+	}
+	if _, err := writeMCPProjectFile(fresh, nil, record); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if fileExists(filepath.Join(fresh, mcpProjectFileName)) {
+		t.Fatal("created .mcp.json should be removed")
+	}
+
+	if _, err := writeMCPProjectFile(filepath.Join(fresh, "missing"), []MCPServer{server}, mcpProjectRecord{}); err == nil {
+		t.Fatal("expected error for missing project directory")
+	}
+}
+
+func readProjectServerNames(t *testing.T, root string) map[string]bool {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(root, mcpProjectFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payload struct {
+		Servers map[string]json.RawMessage `json:"mcpServers"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]bool, len(payload.Servers))
+	for name := range payload.Servers {
+		names[name] = true
+	}
+	return names
+}
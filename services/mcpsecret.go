@@ -0,0 +1,265 @@
+package services
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const (
+	// MCPSecretMask 列表中代替密钥明文返回给前端，保存时原样传回表示不修改
+	MCPSecretMask = "********"
+
+	mcpSecretService = "code-switch-mcp"
+	mcpSecretDirName = "mcp-secrets"
+	// mcpAuthSecretKey HTTP 服务的访问令牌（API Key 或 OAuth access token）在钥匙串中的键名
+	mcpAuthSecretKey = "Authorization"
+)
+
+// secretStore 保存 MCP 服务的密钥，默认使用系统钥匙串
+type secretStore interface {
+	Get(account string) (string, error)
+	Set(account, secret string) error
+	Delete(account string) error
+}
+
+var mcpSecrets secretStore = osSecretStore{}
+
+func mcpSecretAccount(server, key string) string {
+	return server + "/" + key
+}
+
+// resolveMCPSecrets 从钥匙串取出密钥填入 env 与访问令牌，返回用于写入 CLI 配置或启动进程的副本
+func resolveMCPSecrets(server MCPServer) (MCPServer, error) {
+	if len(server.SecretEnv) == 0 && server.AuthToken == "" {
+		return server, nil
+	}
+	env := make(map[string]string, len(server.Env))
+	for key, value := range server.Env {
+		env[key] = value
+	}
+	var errs []error
+	for _, key := range server.SecretEnv {
+		secret, err := mcpSecrets.Get(mcpSecretAccount(server.Name, key))
+		if err != nil {
+			delete(env, key)
+			errs = append(errs, fmt.Errorf("读取 %s 的密钥 %s 失败: %w", server.Name, key, err))
+			continue
+		}
+		env[key] = secret
+	}
+	server.Env = env
+	if server.AuthToken != "" {
+		token, err := mcpSecrets.Get(mcpSecretAccount(server.Name, mcpAuthSecretKey))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("读取 %s 的访问令牌失败: %w", server.Name, err))
+			token = ""
+		}
+		server.AuthToken = token
+	}
+	return server, errors.Join(errs...)
+}
+
+// resolveMCPServersSecrets 批量解析，读取失败的密钥不写入并打印警告，不阻塞其他服务的同步
+func resolveMCPServersSecrets(servers []MCPServer) []MCPServer {
+	result := make([]MCPServer, len(servers))
+	for i, server := range servers {
+		resolved, err := resolveMCPSecrets(server)
+		if err != nil {
+			fmt.Printf("[WARN] %v\n", err)
+		}
+		result[i] = resolved
+	}
+	return result
+}
+
+// storeMCPSecrets 把新填写的密钥写入钥匙串，env 中对应的值替换为掩码；值为空时不再作为密钥
+func storeMCPSecrets(server *MCPServer) error {
+	keys := make([]string, 0, len(server.SecretEnv))
+	for _, key := range cleanArgs(server.SecretEnv) {
+		value, ok := server.Env[key]
+		if !ok || value == "" || containsPlatform(keys, key) {
+			continue
+		}
+		if value != MCPSecretMask {
+			if err := mcpSecrets.Set(mcpSecretAccount(server.Name, key), value); err != nil {
+				return fmt.Errorf("保存 %s 到钥匙串失败: %w", key, err)
+			}
+		} else if _, err := mcpSecrets.Get(mcpSecretAccount(server.Name, key)); err != nil {
+			// 改名或钥匙串被清理后掩码已无对应的值
+			return fmt.Errorf("请重新填写 %s", key)
+		}
+		server.Env[key] = MCPSecretMask
+		keys = append(keys, key)
+	}
+	server.SecretEnv = keys
+
+	switch {
+	case server.Type != "http" || server.AuthToken == "":
+		server.AuthToken = ""
+	case server.AuthToken != MCPSecretMask:
+		if err := mcpSecrets.Set(mcpSecretAccount(server.Name, mcpAuthSecretKey), server.AuthToken); err != nil {
+			return fmt.Errorf("保存访问令牌到钥匙串失败: %w", err)
+		}
+		server.AuthToken = MCPSecretMask
+	default:
+		if _, err := mcpSecrets.Get(mcpSecretAccount(server.Name, mcpAuthSecretKey)); err != nil {
+			return errors.New("请重新填写访问令牌")
+		}
+	}
+	return nil
+}
+
+// removeStaleMCPSecrets 删除服务被删除、改名或取消密钥后留在钥匙串中的条目
+func removeStaleMCPSecrets(previous map[string]rawMCPServer, servers []MCPServer) {
+	kept := make(map[string]struct{})
+	for _, server := range servers {
+		for _, key := range server.SecretEnv {
+			kept[mcpSecretAccount(server.Name, key)] = struct{}{}
+		}
+		if server.AuthToken != "" {
+			kept[mcpSecretAccount(server.Name, mcpAuthSecretKey)] = struct{}{}
+		}
+	}
+	for name, entry := range previous {
+		accounts := make([]string, 0, len(entry.SecretEnv)+1)
+		for _, key := range entry.SecretEnv {
+			accounts = append(accounts, mcpSecretAccount(name, key))
+		}
+		if entry.AuthToken {
+			accounts = append(accounts, mcpSecretAccount(name, mcpAuthSecretKey))
+		}
+		for _, account := range accounts {
+			if _, ok := kept[account]; ok {
+				continue
+			}
+			if err := mcpSecrets.Delete(account); err != nil {
+				fmt.Printf("[WARN] 删除钥匙串条目 %s 失败: %v\n", account, err)
+			}
+		}
+	}
+}
+
+// mcpAuthHeaders HTTP 服务写入 CLI 配置与探测时携带的请求头
+func mcpAuthHeaders(server MCPServer) map[string]string {
+	token := strings.TrimSpace(server.AuthToken)
+	if token == "" || token == MCPSecretMask {
+		return nil
+	}
+	if !strings.Contains(token, " ") {
+		token = "Bearer " + token
+	}
+	return map[string]string{"Authorization": token}
+}
+
+// osSecretStore macOS 使用 security 命令，Linux 使用 libsecret 的 secret-tool，
+// Windows 没有可读取通用凭据的内置命令，使用 DPAPI 加密后保存在数据目录
+type osSecretStore struct{}
+
+func (osSecretStore) Get(account string) (string, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		out, err := runSecretCommand(nil, "security", "find-generic-password", "-s", mcpSecretService, "-a", account, "-w")
+		return strings.TrimSuffix(out, "\n"), err
+	case "windows":
+		path, err := windowsSecretPath(account)
+		if err != nil {
+			return "", err
+		}
+		blob, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		out, err := runSecretCommand(blob, "powershell", "-NoProfile", "-NonInteractive", "-Command",
+			"$s = ConvertTo-SecureString ([Console]::In.ReadToEnd().Trim()); "+
+				"[Console]::Out.Write([Runtime.InteropServices.Marshal]::PtrToStringBSTR([Runtime.InteropServices.Marshal]::SecureStringToBSTR($s)))")
+		return out, err
+	default:
+		out, err := runSecretCommand(nil, "secret-tool", "lookup", "service", mcpSecretService, "account", account)
+		if err == nil && out == "" {
+			return "", errors.New("钥匙串中没有该条目")
+		}
+		return out, err
+	}
+}
+
+func (osSecretStore) Set(account, secret string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		// security 只能通过参数传入密码
+		_, err := runSecretCommand(nil, "security", "add-generic-password", "-U", "-s", mcpSecretService, "-a", account, "-l", "Code Switch MCP "+account, "-w", secret)
+		return err
+	case "windows":
+		path, err := windowsSecretPath(account)
+		if err != nil {
+			return err
+		}
+		out, err := runSecretCommand([]byte(secret), "powershell", "-NoProfile", "-NonInteractive", "-Command",
+			"ConvertTo-SecureString ([Console]::In.ReadToEnd()) -AsPlainText -Force | ConvertFrom-SecureString")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(path, []byte(strings.TrimSpace(out)), 0o600)
+	default:
+		_, err := runSecretCommand([]byte(secret), "secret-tool", "store", "--label", "Code Switch MCP "+account, "service", mcpSecretService, "account", account)
+		return err
+	}
+}
+
+func (osSecretStore) Delete(account string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		_, err := runSecretCommand(nil, "security", "delete-generic-password", "-s", mcpSecretService, "-a", account)
+		return err
+	case "windows":
+		path, err := windowsSecretPath(account)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	default:
+		_, err := runSecretCommand(nil, "secret-tool", "clear", "service", mcpSecretService, "account", account)
+		return err
+	}
+}
+
+func windowsSecretPath(account string) (string, error) {
+	dir, err := ensureDataDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, mcpSecretDirName)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, hex.EncodeToString([]byte(account))), nil
+}
+
+func runSecretCommand(stdin []byte, name string, args ...string) (string, error) {
+	if _, err := exec.LookPath(name); err != nil {
+		return "", fmt.Errorf("未找到 %s，无法访问系统钥匙串", name)
+	}
+	cmd := exec.Command(name, args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %s", name, msg)
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type memorySecretStore map[string]string
+
+func (m memorySecretStore) Get(account string) (string, error) {
+	if value, ok := m[account]; ok {
+		return value, nil
+	}
+	return "", errors.New("not found")
+}
+
+func (m memorySecretStore) Set(account, secret string) error {
+	m[account] = secret
+	return nil
+}
+
+func (m memorySecretStore) Delete(account string) error {
+	delete(m, account)
+	return nil
+}
+
+func TestMCPSecretsRoundTrip(t *testing.T) {
+	store := memorySecretStore{}
+	original := mcpSecrets
+	mcpSecrets = store
+	defer func() { mcpSecrets = original }()
+
+	server := MCPServer{
+		Name:      "github",
+		Type:      "stdio",
+		Command:   "npx",
+		Env:       map[string]string{"GITHUB_TOKEN": "ghp_123", "MODE": "fast"},
+		SecretEnv: []string{"GITHUB_TOKEN", "MISSING"},
+	}
+	if err := storeMCPSecrets(&server); err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	if server.Env["GITHUB_TOKEN"] != MCPSecretMask || !reflect.DeepEqual(server.SecretEnv, []string{"GITHUB_TOKEN"}) {
+		t.Fatalf("server = %+v", server)
+	}
+	if store["github/GITHUB_TOKEN"] != "ghp_123" {
+		t.Fatalf("store = %v", store)
+	}
+	// 再次保存时掩码表示沿用钥匙串中的值
+	if err := storeMCPSecrets(&server); err != nil || store["github/GITHUB_TOKEN"] != "ghp_123" {
+		t.Fatalf("resave: %v, store = %v", err, store)
+	}
+	resolved, err := resolveMCPSecrets(server)
+	if err != nil || resolved.Env["GITHUB_TOKEN"] != "ghp_123" || resolved.Env["MODE"] != "fast" {
+		t.Fatalf("resolved = %+v, err = %v", resolved, err)
+	}
+	if server.Env["GITHUB_TOKEN"] != MCPSecretMask {
+		t.Fatal("resolve must not modify the original env")
+	}
+
+	renamed := server
+	renamed.Name = "gh"
+	if err := storeMCPSecrets(&renamed); err == nil {
+		t.Fatal("expected error when mask has no stored secret")
+	}
+
+	remote := MCPServer{Name: "sentry", Type: "http", URL: "https://mcp.sentry.dev/mcp", AuthToken: "tok"}
+	if err := storeMCPSecrets(&remote); err != nil || remote.AuthToken != MCPSecretMask {
+		t.Fatalf("remote = %+v, err = %v", remote, err)
+	}
+	resolvedRemote, _ := resolveMCPSecrets(remote)
+	if headers := mcpAuthHeaders(resolvedRemote); headers["Authorization"] != "Bearer tok" {
+		t.Fatalf("headers = %v", headers)
+	}
+	if mcpAuthHeaders(remote) != nil {
+		t.Fatal("masked token must not be written")
+	}
+
+	previous := map[string]rawMCPServer{
+		"github": {SecretEnv: []string{"GITHUB_TOKEN"}},
+		"sentry": {AuthToken: true},
+	}
+	removeStaleMCPSecrets(previous, []MCPServer{server})
+	if _, ok := store["sentry/Authorization"]; ok {
+		t.Fatal("secret of removed server kept")
+	}
+	if _, ok := store["github/GITHUB_TOKEN"]; !ok {
+		t.Fatal("secret of kept server removed")
+	}
+}
@@ -16,13 +16,14 @@ import (
 )
 
 const (
-	mcpStoreDir     = ".code-switch"
 	mcpStoreFile    = "mcp.json"
 	claudeMcpFile   = ".claude.json"
 	codexDirName    = ".codex"
 	codexConfigFile = "config.toml"
 	platClaudeCode  = "claude-code"
 	platCodex       = "codex"
+	platGemini      = "gemini"
+	geminiDirName   = ".gemini"
 )
 
 var builtInServers = map[string]rawMCPServer{
@@ -44,6 +45,16 @@ var placeholderPattern = regexp.MustCompile(`\{([a-zA-Z0-9_]+)\}`)
 
 type MCPService struct {
 	mu sync.Mutex
+
+	procMu         sync.Mutex
+	processes      map[string]*mcpProcess
+	processHandler func(MCPProcessStatus)
+	trafficHandler func(MCPTrafficEntry)
+	versionHandler func([]MCPVersionInfo)
+	versionStop    chan struct{}
+
+	gatewayOnce sync.Once
+	gw          *mcpGateway
 }
 
 func NewMCPService() *MCPService {
@@ -60,8 +71,15 @@ type MCPServer struct {
 	Website             string            `json:"website,omitempty"`
 	Tips                string            `json:"tips,omitempty"`
 	EnablePlatform      []string          `json:"enable_platform"`
+	Managed             bool              `json:"managed"`
+	Scope               string            `json:"scope"`
+	Projects            []string          `json:"projects"`
+	SecretEnv           []string          `json:"secret_env"`
+	AuthToken           string            `json:"auth_token,omitempty"`
 	EnabledInClaude     bool              `json:"enabled_in_claude"`
 	EnabledInCodex      bool              `json:"enabled_in_codex"`
+	EnabledInGemini     bool              `json:"enabled_in_gemini"`
+	EnabledIn           map[string]bool   `json:"enabled_in"`
 	MissingPlaceholders []string          `json:"missing_placeholders"`
 }
 
@@ -74,6 +92,14 @@ type rawMCPServer struct {
 	Website        string            `json:"website,omitempty"`
 	Tips           string            `json:"tips,omitempty"`
 	EnablePlatform []string          `json:"enable_platform"`
+	// Managed 为 true 时 stdio 服务的进程由 code-switch 按需启动并在崩溃后重启
+	Managed bool `json:"managed,omitempty"`
+	// Scope 为 project 时只写入 Projects 中各项目根目录的 .mcp.json，不写入 CLI 的全局配置
+	Scope    string   `json:"scope,omitempty"`
+	Projects []string `json:"projects,omitempty"`
+	// SecretEnv 中的环境变量与 HTTP 访问令牌保存在系统钥匙串，这里只记录键名
+	SecretEnv []string `json:"secret_env,omitempty"`
+	AuthToken bool     `json:"auth_token,omitempty"`
 }
 
 type claudeMcpFilePayload struct {
@@ -90,6 +116,7 @@ type claudeDesktopServer struct {
 	Args    []string          `json:"args,omitempty"`
 	Env     map[string]string `json:"env,omitempty"`
 	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
 }
 
 func (ms *MCPService) ListServers() ([]MCPServer, error) {
@@ -101,8 +128,14 @@ func (ms *MCPService) ListServers() ([]MCPServer, error) {
 		return nil, err
 	}
 
-	claudeEnabled := loadClaudeEnabledServers()
-	codexEnabled := loadCodexEnabledServers()
+	targets := builtInMCPTargets()
+	if custom, err := loadCustomMCPTargets(); err == nil {
+		targets = append(targets, custom...)
+	}
+	enabled := make(map[string]map[string]struct{}, len(targets))
+	for _, target := range targets {
+		enabled[target.ID] = loadMCPTargetServerNames(target)
+	}
 
 	names := make([]string, 0, len(config))
 	for name := range config {
@@ -125,8 +158,23 @@ func (ms *MCPService) ListServers() ([]MCPServer, error) {
 			Website:         strings.TrimSpace(entry.Website),
 			Tips:            strings.TrimSpace(entry.Tips),
 			EnablePlatform:  platforms,
-			EnabledInClaude: containsNormalized(claudeEnabled, name),
-			EnabledInCodex:  containsNormalized(codexEnabled, name),
+			Managed:         entry.Managed,
+			Scope:           normalizeMCPScope(entry.Scope),
+			Projects:        cloneArgs(entry.Projects),
+			SecretEnv:       cloneArgs(entry.SecretEnv),
+			EnabledInClaude: containsNormalized(enabled[platClaudeCode], name),
+			EnabledInCodex:  containsNormalized(enabled[platCodex], name),
+			EnabledInGemini: containsNormalized(enabled[platGemini], name),
+			EnabledIn:       make(map[string]bool, len(targets)),
+		}
+		for _, target := range targets {
+			server.EnabledIn[target.ID] = containsNormalized(enabled[target.ID], name)
+		}
+		for _, key := range entry.SecretEnv {
+			server.Env[key] = MCPSecretMask
+		}
+		if entry.AuthToken {
+			server.AuthToken = MCPSecretMask
 		}
 		server.MissingPlaceholders = detectPlaceholders(server.URL, server.Args)
 		servers = append(servers, server)
@@ -159,6 +207,11 @@ func (ms *MCPService) SaveServers(servers []MCPServer) error {
 		if typ == "http" && url == "" {
 			return fmt.Errorf("%s 需要提供 url", name)
 		}
+		scope := normalizeMCPScope(server.Scope)
+		projects, err := normalizeMCPProjects(server.Projects)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
 		normalized[i] = MCPServer{
 			Name:            name,
 			Type:            typ,
@@ -169,18 +222,42 @@ func (ms *MCPService) SaveServers(servers []MCPServer) error {
 			Website:         strings.TrimSpace(server.Website),
 			Tips:            strings.TrimSpace(server.Tips),
 			EnablePlatform:  platforms,
+			Managed:         server.Managed && typ == "stdio",
+			Scope:           scope,
+			Projects:        projects,
+			SecretEnv:       server.SecretEnv,
+			AuthToken:       server.AuthToken,
 			EnabledInClaude: server.EnabledInClaude,
 			EnabledInCodex:  server.EnabledInCodex,
+			EnabledInGemini: server.EnabledInGemini,
+		}
+		if err := storeMCPSecrets(&normalized[i]); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		plainEnv := make(map[string]string, len(env))
+		for key, value := range env {
+			if !containsPlatform(normalized[i].SecretEnv, key) {
+				plainEnv[key] = value
+			}
 		}
 		raw[name] = rawMCPServer{
 			Type:           typ,
 			Command:        command,
 			Args:           args,
-			Env:            env,
+			Env:            plainEnv,
 			URL:            url,
 			Website:        normalized[i].Website,
 			Tips:           normalized[i].Tips,
 			EnablePlatform: platforms,
+			Managed:        normalized[i].Managed,
+			Projects:       projects,
+			SecretEnv:      normalized[i].SecretEnv,
+			AuthToken:      normalized[i].AuthToken != "",
+		}
+		if scope == MCPScopeProject {
+			rawEntry := raw[name]
+			rawEntry.Scope = scope
+			raw[name] = rawEntry
 		}
 		placeholders := detectPlaceholders(url, args)
 		normalized[i].MissingPlaceholders = placeholders
@@ -192,27 +269,24 @@ func (ms *MCPService) SaveServers(servers []MCPServer) error {
 		}
 	}
 
+	previous := loadManagedMCPServerNames()
+	previousRaw, _ := ms.loadConfig()
 	if err := ms.saveConfig(raw); err != nil {
 		return err
 	}
-	if err := ms.syncClaudeServers(normalized); err != nil {
-		return err
-	}
-	if err := ms.syncCodexServers(normalized); err != nil {
-		return err
-	}
-	return nil
+	removeStaleMCPSecrets(previousRaw, normalized)
+	ms.stopUnmanagedProcesses(normalized)
+	resolved := resolveMCPServersSecrets(normalized)
+	syncErr := ms.syncTargets(globalMCPServers(resolved), previous)
+	projectErr := syncMCPProjects(resolved)
+	return errors.Join(syncErr, projectErr, applyMCPToolPolicies())
 }
 
 func (ms *MCPService) configPath() (string, error) {
-	home, err := os.UserHomeDir()
+	dir, err := ensureDataDir()
 	if err != nil {
 		return "", err
 	}
-	dir := filepath.Join(home, mcpStoreDir)
-	if err := os.MkdirAll(dir, 0o755); err != nil {
-		return "", err
-	}
 	return filepath.Join(dir, mcpStoreFile), nil
 }
 
@@ -362,7 +436,13 @@ func normalizePlatform(value string) (string, bool) {
 		return "claude-code", true
 	case "codex":
 		return "codex", true
+	case "gemini", "gemini-cli", "gemini_cli":
+		return platGemini, true
 	default:
+		// 自定义同步目标的 ID
+		if id := strings.ToLower(strings.TrimSpace(value)); mcpTargetIDPattern.MatchString(id) {
+			return id, true
+		}
 		return "", false
 	}
 }
@@ -544,62 +624,6 @@ func ensureBuiltInServers(target map[string]rawMCPServer) bool {
 	return changed
 }
 
-func (ms *MCPService) syncClaudeServers(servers []MCPServer) error {
-	path, err := claudeConfigPath()
-	if err != nil {
-		return err
-	}
-	desired := make(map[string]claudeDesktopServer)
-	for _, server := range servers {
-		if !platformContains(server.EnablePlatform, platClaudeCode) {
-			continue
-		}
-		desired[server.Name] = buildClaudeDesktopEntry(server)
-	}
-	payload := make(map[string]any)
-	if data, err := os.ReadFile(path); err == nil && len(data) > 0 {
-		if err := json.Unmarshal(data, &payload); err != nil {
-			payload = make(map[string]any)
-		}
-	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
-		return err
-	}
-	payload["mcpServers"] = desired
-	data, err := json.MarshalIndent(payload, "", "  ")
-	if err != nil {
-		return err
-	}
-	return os.WriteFile(path, data, 0o600)
-}
-
-func (ms *MCPService) syncCodexServers(servers []MCPServer) error {
-	path, err := codexConfigPath()
-	if err != nil {
-		return err
-	}
-	desired := make(map[string]map[string]any)
-	for _, server := range servers {
-		if !platformContains(server.EnablePlatform, platCodex) {
-			continue
-		}
-		desired[server.Name] = buildCodexEntry(server)
-	}
-	payload := make(map[string]any)
-	if data, err := os.ReadFile(path); err == nil && len(data) > 0 {
-		if err := toml.Unmarshal(data, &payload); err != nil {
-			payload = make(map[string]any)
-		}
-	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
-		return err
-	}
-	payload["mcp_servers"] = desired
-	data, err := toml.Marshal(payload)
-	if err != nil {
-		return err
-	}
-	return os.WriteFile(path, data, 0o644)
-}
-
 func platformContains(platforms []string, target string) bool {
 	for _, value := range platforms {
 		if value == target {
@@ -613,6 +637,7 @@ func buildClaudeDesktopEntry(server MCPServer) claudeDesktopServer {
 	entry := claudeDesktopServer{Type: server.Type}
 	if server.Type == "http" {
 		entry.URL = server.URL
+		entry.Headers = mcpAuthHeaders(server)
 	} else {
 		entry.Command = server.Command
 		if len(server.Args) > 0 {
@@ -630,6 +655,9 @@ func buildCodexEntry(server MCPServer) map[string]any {
 	entry["type"] = server.Type
 	if server.Type == "http" {
 		entry["url"] = server.URL
+		if headers := mcpAuthHeaders(server); headers != nil {
+			entry["http_headers"] = headers
+		}
 	} else {
 		entry["command"] = server.Command
 		if len(server.Args) > 0 {
@@ -642,24 +670,32 @@ func buildCodexEntry(server MCPServer) map[string]any {
 	return entry
 }
 
-func claudeConfigPath() (string, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return "", err
+// buildGeminiEntry Gemini CLI 用 httpUrl 表示 streamable HTTP 服务
+func buildGeminiEntry(server MCPServer) map[string]any {
+	entry := make(map[string]any)
+	if server.Type == "http" {
+		entry["httpUrl"] = server.URL
+		if headers := mcpAuthHeaders(server); headers != nil {
+			entry["headers"] = headers
+		}
+		return entry
 	}
-	return filepath.Join(home, claudeMcpFile), nil
+	entry["command"] = server.Command
+	if len(server.Args) > 0 {
+		entry["args"] = server.Args
+	}
+	if len(server.Env) > 0 {
+		entry["env"] = server.Env
+	}
+	return entry
 }
 
-func codexConfigPath() (string, error) {
+func claudeConfigPath() (string, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", err
 	}
-	dir := filepath.Join(home, codexDirName)
-	if err := os.MkdirAll(dir, 0o755); err != nil {
-		return "", err
-	}
-	return filepath.Join(dir, codexConfigFile), nil
+	return filepath.Join(home, claudeMcpFile), nil
 }
 
 func detectPlaceholders(url string, args []string) []string {
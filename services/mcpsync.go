@@ -0,0 +1,407 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+const (
+	mcpTargetsFile = "mcp-targets.json"
+
+	mcpFormatJSON = "json"
+	mcpFormatTOML = "toml"
+
+	// 条目格式：claude 为 {type, command, args, env, url}，codex 同 claude 但写入 TOML，gemini 用 httpUrl 表示 HTTP 服务
+	mcpStyleClaude = "claude"
+	mcpStyleCodex  = "codex"
+	mcpStyleGemini = "gemini"
+)
+
+var (
+	mcpTargetIDPattern   = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+	mcpTargetSlugPattern = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// MCPSyncTarget 一个需要写入 MCP 配置的 CLI。内置 Claude Code、Codex 与 Gemini CLI，
+// 其他 CLI 只要配置文件是 JSON 或 TOML，指定文件路径、服务所在的键与条目格式即可同步
+type MCPSyncTarget struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	ConfigPath string `json:"config_path"`
+	Format     string `json:"format"`
+	// ServersKey 服务表所在的键，用 . 分隔嵌套，例如 mcpServers 或 mcp.servers
+	ServersKey string `json:"servers_key"`
+	Style      string `json:"style"`
+	BuiltIn    bool   `json:"built_in"`
+}
+
+func builtInMCPTargets() []MCPSyncTarget {
+	home := userHomeDir()
+	return []MCPSyncTarget{
+		{ID: platClaudeCode, Name: "Claude Code", ConfigPath: filepath.Join(home, claudeMcpFile), Format: mcpFormatJSON, ServersKey: "mcpServers", Style: mcpStyleClaude, BuiltIn: true},
+		{ID: platCodex, Name: "Codex", ConfigPath: filepath.Join(home, codexDirName, codexConfigFile), Format: mcpFormatTOML, ServersKey: "mcp_servers", Style: mcpStyleCodex, BuiltIn: true},
+		{ID: platGemini, Name: "Gemini CLI", ConfigPath: filepath.Join(home, geminiDirName, geminiSettingsFileName), Format: mcpFormatJSON, ServersKey: "mcpServers", Style: mcpStyleGemini, BuiltIn: true},
+	}
+}
+
+// ListMCPSyncTargets 返回内置与自定义的同步目标
+func (ms *MCPService) ListMCPSyncTargets() ([]MCPSyncTarget, error) {
+	custom, err := loadCustomMCPTargets()
+	if err != nil {
+		return nil, err
+	}
+	return append(builtInMCPTargets(), custom...), nil
+}
+
+// SaveMCPSyncTarget 新增或更新自定义同步目标，ID 为空时根据名称生成
+func (ms *MCPService) SaveMCPSyncTarget(target MCPSyncTarget) (MCPSyncTarget, error) {
+	target, err := normalizeMCPTarget(target)
+	if err != nil {
+		return MCPSyncTarget{}, err
+	}
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	custom, err := loadCustomMCPTargets()
+	if err != nil {
+		return MCPSyncTarget{}, err
+	}
+	replaced := false
+	for i := range custom {
+		if custom[i].ID == target.ID {
+			custom[i] = target
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		custom = append(custom, target)
+	}
+	if err := saveCustomMCPTargets(custom); err != nil {
+		return MCPSyncTarget{}, err
+	}
+	return target, nil
+}
+
+// DeleteMCPSyncTarget 删除自定义同步目标，并从各服务的启用平台中移除；已写入该 CLI 配置文件的内容保持不变
+func (ms *MCPService) DeleteMCPSyncTarget(id string) error {
+	id = strings.ToLower(strings.TrimSpace(id))
+	if _, ok := builtInMCPTargetIDs()[id]; ok {
+		return fmt.Errorf("内置目标 %s 不能删除", id)
+	}
+	ms.mu.Lock()
+	custom, err := loadCustomMCPTargets()
+	if err != nil {
+		ms.mu.Unlock()
+		return err
+	}
+	kept := make([]MCPSyncTarget, 0, len(custom))
+	for _, target := range custom {
+		if target.ID != id {
+			kept = append(kept, target)
+		}
+	}
+	if len(kept) == len(custom) {
+		ms.mu.Unlock()
+		return fmt.Errorf("同步目标不存在: %s", id)
+	}
+	if err := saveCustomMCPTargets(kept); err != nil {
+		ms.mu.Unlock()
+		return err
+	}
+	ms.mu.Unlock()
+
+	servers, err := ms.ListServers()
+	if err != nil {
+		return err
+	}
+	changed := false
+	for i := range servers {
+		platforms := make([]string, 0, len(servers[i].EnablePlatform))
+		for _, platform := range servers[i].EnablePlatform {
+			if platform != id {
+				platforms = append(platforms, platform)
+			}
+		}
+		if len(platforms) != len(servers[i].EnablePlatform) {
+			servers[i].EnablePlatform = platforms
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return ms.SaveServers(servers)
+}
+
+// SyncMCPServers 按 mcp.json 重新写入所有目标，用于手动修改过 CLI 配置或新增目标之后
+func (ms *MCPService) SyncMCPServers() error {
+	servers, err := ms.ListServers()
+	if err != nil {
+		return err
+	}
+	return ms.SaveServers(servers)
+}
+
+// syncTargets 把服务写入每个目标；previous 为保存前 code-switch 管理的服务名，
+// 只有这些服务会在目标中被删除，用户直接写在 CLI 配置里的服务保持不变
+func (ms *MCPService) syncTargets(servers []MCPServer, previous map[string]struct{}) error {
+	targets := builtInMCPTargets()
+	if custom, err := loadCustomMCPTargets(); err == nil {
+		targets = append(targets, custom...)
+	} else {
+		fmt.Printf("[WARN] 读取自定义 MCP 同步目标失败: %v\n", err)
+	}
+	var errs []error
+	for _, target := range targets {
+		if err := writeMCPTarget(target, servers, previous); err != nil {
+			errs = append(errs, fmt.Errorf("同步 MCP 到 %s 失败: %w", target.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func writeMCPTarget(target MCPSyncTarget, servers []MCPServer, previous map[string]struct{}) error {
+	payload, mode, err := readMCPTargetFile(target)
+	if err != nil {
+		return err
+	}
+	section := mcpTargetSection(payload, target.ServersKey, true)
+
+	current := make(map[string]struct{}, len(servers))
+	for _, server := range servers {
+		current[strings.ToLower(server.Name)] = struct{}{}
+	}
+	changed := false
+	for name := range section {
+		key := strings.ToLower(strings.TrimSpace(name))
+		if _, managed := previous[key]; !managed {
+			continue
+		}
+		if _, kept := current[key]; !kept {
+			delete(section, name)
+			changed = true
+		}
+	}
+	for _, server := range servers {
+		if platformContains(server.EnablePlatform, target.ID) {
+			section[server.Name] = buildMCPTargetEntry(target.Style, server)
+			changed = true
+		} else if _, ok := section[server.Name]; ok {
+			delete(section, server.Name)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return writeMCPTargetFile(target, payload, mode)
+}
+
+func writeMCPTargetFile(target MCPSyncTarget, payload map[string]any, mode os.FileMode) error {
+	original, err := os.ReadFile(target.ConfigPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	var data []byte
+	if target.Format == mcpFormatTOML {
+		data, err = encodeTOMLPreserving(original, payload)
+	} else {
+		data, err = encodeJSONPreserving(original, payload)
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target.ConfigPath), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(target.ConfigPath, data, mode)
+}
+
+// readMCPTargetFile 读取目标配置文件；文件无法解析时返回错误而不是覆盖，避免丢失用户的其他配置
+func readMCPTargetFile(target MCPSyncTarget) (map[string]any, os.FileMode, error) {
+	payload := make(map[string]any)
+	mode := os.FileMode(0o600)
+	if target.Format == mcpFormatTOML {
+		mode = 0o644
+	}
+	data, err := os.ReadFile(target.ConfigPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return payload, mode, nil
+		}
+		return nil, 0, err
+	}
+	if info, err := os.Stat(target.ConfigPath); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return payload, mode, nil
+	}
+	if target.Format == mcpFormatTOML {
+		err = toml.Unmarshal(data, &payload)
+	} else {
+		payload, err = parseJSONC(data)
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("解析 %s 失败: %w", target.ConfigPath, err)
+	}
+	return payload, mode, nil
+}
+
+// mcpTargetSection 按 . 分隔的键找到服务表，create 为 true 时逐级创建
+func mcpTargetSection(payload map[string]any, key string, create bool) map[string]any {
+	current := payload
+	for _, part := range strings.Split(key, ".") {
+		next, ok := current[part].(map[string]any)
+		if !ok {
+			if !create {
+				return nil
+			}
+			next = make(map[string]any)
+			current[part] = next
+		}
+		current = next
+	}
+	return current
+}
+
+func buildMCPTargetEntry(style string, server MCPServer) any {
+	switch style {
+	case mcpStyleCodex:
+		return buildCodexEntry(server)
+	case mcpStyleGemini:
+		return buildGeminiEntry(server)
+	default:
+		return buildClaudeDesktopEntry(server)
+	}
+}
+
+// loadMCPTargetServerNames 读取目标配置中已存在的服务名
+func loadMCPTargetServerNames(target MCPSyncTarget) map[string]struct{} {
+	result := map[string]struct{}{}
+	payload, _, err := readMCPTargetFile(target)
+	if err != nil {
+		return result
+	}
+	for name := range mcpTargetSection(payload, target.ServersKey, false) {
+		result[strings.ToLower(strings.TrimSpace(name))] = struct{}{}
+	}
+	return result
+}
+
+func normalizeMCPTarget(target MCPSyncTarget) (MCPSyncTarget, error) {
+	target.Name = strings.TrimSpace(target.Name)
+	if target.Name == "" {
+		return target, errors.New("名称不能为空")
+	}
+	target.ID = strings.ToLower(strings.TrimSpace(target.ID))
+	if target.ID == "" {
+		target.ID = strings.Trim(mcpTargetSlugPattern.ReplaceAllString(strings.ToLower(target.Name), "-"), "-")
+	}
+	if !mcpTargetIDPattern.MatchString(target.ID) {
+		return target, fmt.Errorf("ID 无效: %q", target.ID)
+	}
+	if _, ok := builtInMCPTargetIDs()[target.ID]; ok {
+		return target, fmt.Errorf("%s 是内置目标", target.ID)
+	}
+
+	path := strings.TrimSpace(target.ConfigPath)
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		path = filepath.Join(userHomeDir(), strings.TrimPrefix(path, "~"))
+	}
+	if path == "" || !filepath.IsAbs(path) {
+		return target, errors.New("配置文件路径需要是绝对路径")
+	}
+	target.ConfigPath = filepath.Clean(path)
+
+	target.Format = strings.ToLower(strings.TrimSpace(target.Format))
+	if target.Format == "" {
+		target.Format = mcpFormatJSON
+		if strings.EqualFold(filepath.Ext(target.ConfigPath), ".toml") {
+			target.Format = mcpFormatTOML
+		}
+	}
+	if target.Format != mcpFormatJSON && target.Format != mcpFormatTOML {
+		return target, fmt.Errorf("不支持的格式: %s", target.Format)
+	}
+	target.ServersKey = strings.Trim(strings.TrimSpace(target.ServersKey), ".")
+	if target.ServersKey == "" {
+		target.ServersKey = "mcpServers"
+		if target.Format == mcpFormatTOML {
+			target.ServersKey = "mcp_servers"
+		}
+	}
+	target.Style = strings.ToLower(strings.TrimSpace(target.Style))
+	switch target.Style {
+	case mcpStyleClaude, mcpStyleCodex, mcpStyleGemini:
+	case "":
+		target.Style = mcpStyleClaude
+		if target.Format == mcpFormatTOML {
+			target.Style = mcpStyleCodex
+		}
+	default:
+		return target, fmt.Errorf("不支持的条目格式: %s", target.Style)
+	}
+	target.BuiltIn = false
+	return target, nil
+}
+
+func builtInMCPTargetIDs() map[string]struct{} {
+	return map[string]struct{}{platClaudeCode: {}, platCodex: {}, platGemini: {}}
+}
+
+func mcpTargetsPath() (string, error) {
+	dir, err := ensureDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, mcpTargetsFile), nil
+}
+
+func loadCustomMCPTargets() ([]MCPSyncTarget, error) {
+	path, err := mcpTargetsPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []MCPSyncTarget{}, nil
+		}
+		return nil, err
+	}
+	var targets []MCPSyncTarget
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &targets); err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(targets, func(i, j int) bool {
+		return strings.ToLower(targets[i].Name) < strings.ToLower(targets[j].Name)
+	})
+	return targets, nil
+}
+
+func saveCustomMCPTargets(targets []MCPSyncTarget) error {
+	path, err := mcpTargetsPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(targets, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
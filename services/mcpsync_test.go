@@ -0,0 +1,101 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+func TestWriteMCPTargetKeepsUnmanagedEntries(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	path := filepath.Join(home, ".gemini", "settings.json")
+	writeSkillFiles(t, filepath.Dir(path), map[string]string{
+		"settings.json": `{"theme":"dark","mcpServers":{"mine":{"command":"mine"},"old":{"command":"old"},"fetch":{"command":"stale"}}}`,
+	})
+
+	servers := []MCPServer{
+		{Name: "fetch", Type: "stdio", Command: "uvx", Args: []string{"mcp-server-fetch"}, EnablePlatform: []string{platGemini}},
+		{Name: "sentry", Type: "http", URL: "https://mcp.sentry.dev/mcp", EnablePlatform: []string{platGemini}},
+		{Name: "memory", Type: "stdio", Command: "npx", EnablePlatform: []string{platClaudeCode}},
+	}
+	previous := map[string]struct{}{"old": {}, "fetch": {}}
+	if err := writeMCPTarget(builtInMCPTargets()[2], servers, previous); err != nil {
+		t.Fatalf("writeMCPTarget: %v", err)
+	}
+
+	var payload struct {
+		Theme   string                    `json:"theme"`
+		Servers map[string]map[string]any `json:"mcpServers"`
+	}
+	data, _ := os.ReadFile(path)
+	if err := json.Unmarshal(data, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Theme != "dark" {
+		t.Fatalf("theme lost: %s", data)
+	}
+	if _, ok := payload.Servers["mine"]; !ok {
+		t.Fatalf("unmanaged server removed: %s", data)
+	}
+	if _, ok := payload.Servers["old"]; ok {
+		t.Fatalf("deleted server kept: %s", data)
+	}
+	if _, ok := payload.Servers["memory"]; ok {
+		t.Fatalf("server not enabled for gemini written: %s", data)
+	}
+	if payload.Servers["fetch"]["command"] != "uvx" || payload.Servers["sentry"]["httpUrl"] != "https://mcp.sentry.dev/mcp" {
+		t.Fatalf("servers = %v", payload.Servers)
+	}
+}
+
+func TestWriteCustomMCPTarget(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	target, err := normalizeMCPTarget(MCPSyncTarget{Name: "My CLI", ConfigPath: "~/.mycli/config.toml", ServersKey: "tools.mcp"})
+	if err != nil {
+		t.Fatalf("normalizeMCPTarget: %v", err)
+	}
+	if target.ID != "my-cli" || target.Format != mcpFormatTOML || target.Style != mcpStyleCodex || target.ConfigPath != filepath.Join(home, ".mycli", "config.toml") {
+		t.Fatalf("target = %+v", target)
+	}
+	if _, err := normalizeMCPTarget(MCPSyncTarget{Name: "codex", ConfigPath: "/tmp/x.json"}); err == nil {
+		t.Fatal("expected error for built-in id")
+	}
+	writeSkillFiles(t, filepath.Dir(target.ConfigPath), map[string]string{
+		"config.toml": "model = \"x\"\n",
+	})
+
+	servers := []MCPServer{{Name: "fetch", Type: "stdio", Command: "uvx", Args: []string{"mcp-server-fetch"}, EnablePlatform: []string{"my-cli"}}}
+	if err := writeMCPTarget(target, servers, nil); err != nil {
+		t.Fatalf("writeMCPTarget: %v", err)
+	}
+	data, _ := os.ReadFile(target.ConfigPath)
+	var payload struct {
+		Model string `toml:"model"`
+		Tools struct {
+			MCP map[string]map[string]any `toml:"mcp"`
+		} `toml:"tools"`
+	}
+	if err := toml.Unmarshal(data, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Model != "x" || payload.Tools.MCP["fetch"]["command"] != "uvx" {
+		t.Fatalf("config = %s", data)
+	}
+	if names := loadMCPTargetServerNames(target); !containsNormalized(names, "fetch") {
+		t.Fatalf("names = %v", names)
+	}
+
+	// 无法解析的配置文件不能被覆盖
+	broken := MCPSyncTarget{ID: "broken", Name: "Broken", ConfigPath: filepath.Join(home, "broken.json"), Format: mcpFormatJSON, ServersKey: "mcpServers", Style: mcpStyleClaude}
+	_ = os.WriteFile(broken.ConfigPath, []byte("{not json"), 0o644)
+	servers[0].EnablePlatform = []string{"broken"}
+	if err := writeMCPTarget(broken, servers, nil); err == nil || !strings.Contains(err.Error(), "解析") {
+		t.Fatalf("expected parse error, got %v", err)
+	}
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/daodao97/xgo/xdb"
+)
+
+const (
+	mcpTrafficTable = "mcp_traffic"
+
+	MCPTrafficRequest      = "request"
+	MCPTrafficNotification = "notification"
+	MCPTrafficServer       = "server"
+
+	// 单条 params / result 的保存上限，超出部分截断
+	mcpTrafficBodyLimit = 16 * 1024
+	// 表中最多保留的记录数，每写入 mcpTrafficPruneEvery 条清理一次
+	mcpTrafficKeep       = 5000
+	mcpTrafficPruneEvery = 200
+)
+
+var mcpTrafficWrites atomic.Int64
+
+// MCPTrafficEntry 经过网关的一帧 JSON-RPC：客户端请求（含响应与耗时）、客户端通知或服务端通知
+type MCPTrafficEntry struct {
+	ID         int64     `json:"id"`
+	Server     string    `json:"server"`
+	Direction  string    `json:"direction"`
+	Method     string    `json:"method"`
+	Tool       string    `json:"tool,omitempty"`
+	Params     string    `json:"params,omitempty"`
+	Result     string    `json:"result,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// SetMCPTrafficHandler 每记录一帧回调一次，用于实时刷新流量视图
+func (ms *MCPService) SetMCPTrafficHandler(handler func(MCPTrafficEntry)) {
+	ms.procMu.Lock()
+	defer ms.procMu.Unlock()
+	ms.trafficHandler = handler
+}
+
+func (ms *MCPService) recordMCPTraffic(entry MCPTrafficEntry) {
+	recordMCPTraffic(entry)
+	ms.procMu.Lock()
+	handler := ms.trafficHandler
+	ms.procMu.Unlock()
+	if handler != nil {
+		entry.CreatedAt = time.Now()
+		handler(entry)
+	}
+}
+
+// newMCPTrafficEntry 从客户端消息中提取方法、工具名与参数
+func newMCPTrafficEntry(server, direction string, msg map[string]json.RawMessage) MCPTrafficEntry {
+	entry := MCPTrafficEntry{Server: server, Direction: direction, Params: truncateMCPTraffic(msg["params"])}
+	_ = json.Unmarshal(msg["method"], &entry.Method)
+	if entry.Method == "tools/call" {
+		var params struct {
+			Name string `json:"name"`
+		}
+		_ = json.Unmarshal(msg["params"], &params)
+		entry.Tool = params.Name
+	}
+	return entry
+}
+
+// finishMCPTrafficEntry 填入响应结果或错误
+func finishMCPTrafficEntry(entry *MCPTrafficEntry, started time.Time, resp []byte, err error) {
+	entry.DurationMs = time.Since(started).Milliseconds()
+	if err != nil {
+		entry.Error = err.Error()
+		return
+	}
+	var parsed mcpRPCResponse
+	if json.Unmarshal(resp, &parsed) != nil {
+		return
+	}
+	if parsed.Error != nil {
+		entry.Error = fmt.Sprintf("%d %s", parsed.Error.Code, parsed.Error.Message)
+		return
+	}
+	entry.Result = truncateMCPTraffic(parsed.Result)
+	// 工具调用失败通过 result.isError 返回
+	var result struct {
+		IsError bool `json:"isError"`
+	}
+	if entry.Method == "tools/call" && json.Unmarshal(parsed.Result, &result) == nil && result.IsError {
+		entry.Error = "tool returned isError"
+	}
+}
+
+func truncateMCPTraffic(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	if len(raw) > mcpTrafficBodyLimit {
+		return string(raw[:mcpTrafficBodyLimit]) + "..."
+	}
+	return string(raw)
+}
+
+func recordMCPTraffic(entry MCPTrafficEntry) {
+	if _, err := xdb.New(mcpTrafficTable).Insert(xdb.Record{
+		"server":      entry.Server,
+		"direction":   entry.Direction,
+		"method":      entry.Method,
+		"tool":        entry.Tool,
+		"params":      entry.Params,
+		"result":      entry.Result,
+		"error":       entry.Error,
+		"duration_ms": entry.DurationMs,
+	}); err != nil {
+		if !isNoSuchTableErr(err) {
+			fmt.Printf("[WARN] 写入 mcp_traffic 失败: %v\n", err)
+		}
+		return
+	}
+	if mcpTrafficWrites.Add(1)%mcpTrafficPruneEvery == 0 {
+		pruneMCPTraffic()
+	}
+}
+
+func pruneMCPTraffic() {
+	db, err := xdb.DB("default")
+	if err != nil {
+		return
+	}
+	if _, err := db.Exec(`DELETE FROM mcp_traffic WHERE id <= (SELECT MAX(id) FROM mcp_traffic) - ?`, mcpTrafficKeep); err != nil {
+		fmt.Printf("[WARN] 清理 mcp_traffic 失败: %v\n", err)
+	}
+}
+
+// ListMCPTraffic 查询网关记录的 MCP 流量，server 为空时返回全部，errorsOnly 只返回失败的调用
+func (ls *LogService) ListMCPTraffic(server string, errorsOnly bool, limit int) ([]MCPTrafficEntry, error) {
+	if limit <= 0 {
+		limit = 200
+	}
+	if limit > 2000 {
+		limit = 2000
+	}
+	options := []xdb.Option{
+		xdb.OrderByDesc("id"),
+		xdb.Limit(limit),
+	}
+	if server = strings.TrimSpace(server); server != "" {
+		options = append(options, xdb.WhereEq("server", server))
+	}
+	if errorsOnly {
+		options = append(options, xdb.WhereNotEq("error", ""))
+	}
+	records, err := xdb.New(mcpTrafficTable).Selects(options...)
+	if err != nil {
+		if errors.Is(err, xdb.ErrNotFound) || isNoSuchTableErr(err) {
+			return []MCPTrafficEntry{}, nil
+		}
+		return nil, err
+	}
+	entries := make([]MCPTrafficEntry, 0, len(records))
+	for _, record := range records {
+		createdAt, _ := parseCreatedAt(record)
+		entries = append(entries, MCPTrafficEntry{
+			ID:         record.GetInt64("id"),
+			Server:     record.GetString("server"),
+			Direction:  record.GetString("direction"),
+			Method:     record.GetString("method"),
+			Tool:       record.GetString("tool"),
+			Params:     record.GetString("params"),
+			Result:     record.GetString("result"),
+			Error:      record.GetString("error"),
+			DurationMs: record.GetInt64("duration_ms"),
+			CreatedAt:  createdAt,
+		})
+	}
+	return entries, nil
+}
+
+// ClearMCPTraffic 清空流量记录，server 为空时清空全部
+func (ls *LogService) ClearMCPTraffic(server string) error {
+	db, err := xdb.DB("default")
+	if err != nil {
+		return err
+	}
+	if server = strings.TrimSpace(server); server != "" {
+		_, err = db.Exec(`DELETE FROM mcp_traffic WHERE server = ?`, server)
+	} else {
+		_, err = db.Exec(`DELETE FROM mcp_traffic`)
+	}
+	if err != nil && isNoSuchTableErr(err) {
+		return nil
+	}
+	return err
+}
+
+func ensureMCPTrafficTable() error {
+	db, err := xdb.DB("default")
+	if err != nil {
+		return err
+	}
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS mcp_traffic (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			server TEXT,
+			direction TEXT,
+			method TEXT,
+			tool TEXT,
+			params TEXT,
+			result TEXT,
+			error TEXT DEFAULT '',
+			duration_ms INTEGER DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_mcp_traffic_server ON mcp_traffic (server, id)`,
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
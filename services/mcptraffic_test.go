@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMCPBridgeRecordsTraffic(t *testing.T) {
+	proc := newTestMCPProcess("1", func(MCPProcessStatus) {})
+	defer proc.stop()
+	bridge := newMCPBridge("helper", func() (*mcpProcess, error) {
+		return proc, proc.start()
+	})
+	defer bridge.detach()
+	var mu sync.Mutex
+	var entries []MCPTrafficEntry
+	bridge.onTraffic = func(entry MCPTrafficEntry) {
+		mu.Lock()
+		entries = append(entries, entry)
+		mu.Unlock()
+	}
+
+	send := func(body string) {
+		var msg map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(body), &msg); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if _, err := bridge.handle(context.Background(), msg); err != nil {
+			t.Fatalf("handle %s: %v", body, err)
+		}
+	}
+	send(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	send(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"search","arguments":{"q":"x"}}}`)
+	send(`{"jsonrpc":"2.0","id":3,"method":"resources/list"}`)
+	send(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":2}}`)
+
+	mu.Lock()
+	defer mu.Unlock()
+	requests := make(map[string]MCPTrafficEntry)
+	for _, entry := range entries {
+		if entry.Server != "helper" {
+			t.Fatalf("server = %q", entry.Server)
+		}
+		if entry.Direction == MCPTrafficRequest {
+			requests[entry.Method] = entry
+		}
+	}
+	if init := requests["initialize"]; init.Error != "" || !strings.Contains(init.Result, `"serverInfo"`) {
+		t.Fatalf("initialize entry = %+v", init)
+	}
+	if call := requests["tools/call"]; call.Tool != "search" || call.Error == "" || !strings.Contains(call.Params, `"q":"x"`) {
+		t.Fatalf("tools/call entry = %+v", call)
+	}
+	if list := requests["resources/list"]; !strings.Contains(list.Error, "-32601") {
+		t.Fatalf("resources/list entry = %+v", list)
+	}
+	last := entries[len(entries)-1]
+	if last.Direction != MCPTrafficNotification || last.Method != "notifications/cancelled" {
+		t.Fatalf("last entry = %+v", last)
+	}
+}
+
+func TestTruncateMCPTraffic(t *testing.T) {
+	if got := truncateMCPTraffic(json.RawMessage("null")); got != "" {
+		t.Fatalf("null = %q", got)
+	}
+	long := json.RawMessage(`"` + strings.Repeat("a", mcpTrafficBodyLimit) + `"`)
+	if got := truncateMCPTraffic(long); len(got) != mcpTrafficBodyLimit+3 || !strings.HasSuffix(got, "...") {
+		t.Fatalf("truncated length = %d", len(got))
+	}
+}
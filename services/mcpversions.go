@@ -0,0 +1,462 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	MCPRegistryNPM  = "npm"
+	MCPRegistryPyPI = "pypi"
+
+	mcpVersionsFile = "mcp-versions.json"
+	// 后台每小时检查一次是否到期，距上次检查超过 mcpVersionCheckInterval 才访问注册表
+	mcpVersionTick          = time.Hour
+	mcpVersionCheckInterval = 24 * time.Hour
+	mcpVersionLaunchDelay   = 2 * time.Minute
+	mcpVersionConcurrency   = 4
+)
+
+var (
+	mcpNPMRegistry  = "https://registry.npmjs.org"
+	mcpPyPIRegistry = "https://pypi.org/pypi"
+
+	// 只有精确版本号才视为已锁定，范围与 dist-tag 视为未锁定
+	mcpExactVersionPattern = regexp.MustCompile(`^v?\d+(\.\d+)*([-+.][0-9A-Za-z.-]+)?$`)
+)
+
+// MCPVersionInfo 某个 npm / PyPI 包形式的 MCP 服务的版本信息
+type MCPVersionInfo struct {
+	Server          string    `json:"server"`
+	Registry        string    `json:"registry"`
+	Package         string    `json:"package"`
+	Current         string    `json:"current,omitempty"`
+	Pinned          bool      `json:"pinned"`
+	Latest          string    `json:"latest,omitempty"`
+	UpdateAvailable bool      `json:"update_available"`
+	Error           string    `json:"error,omitempty"`
+	CheckedAt       time.Time `json:"checked_at"`
+}
+
+type mcpVersionCache struct {
+	CheckedAt time.Time        `json:"checked_at"`
+	Versions  []MCPVersionInfo `json:"versions"`
+}
+
+// mcpPackageSpec 命令参数中的包名与版本。Index 为所在参数的位置，
+// Prefix 为 --from= 这类内联参数前缀，Separator 为版本分隔符（@ 或 ==）
+type mcpPackageSpec struct {
+	Registry  string
+	Package   string
+	Version   string
+	Index     int
+	Prefix    string
+	Separator string
+}
+
+// SetMCPVersionHandler 后台检查发现新版本时回调
+func (ms *MCPService) SetMCPVersionHandler(handler func([]MCPVersionInfo)) {
+	ms.procMu.Lock()
+	defer ms.procMu.Unlock()
+	ms.versionHandler = handler
+}
+
+// Start 启动后台版本检查
+func (ms *MCPService) Start() error {
+	ms.procMu.Lock()
+	defer ms.procMu.Unlock()
+	if ms.versionStop != nil {
+		return nil
+	}
+	stopCh := make(chan struct{})
+	ms.versionStop = stopCh
+	go func() {
+		launch := time.NewTimer(mcpVersionLaunchDelay)
+		defer launch.Stop()
+		ticker := time.NewTicker(mcpVersionTick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-launch.C:
+				ms.checkMCPVersionsIfDue(time.Now())
+			case <-ticker.C:
+				ms.checkMCPVersionsIfDue(time.Now())
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (ms *MCPService) stopVersionChecks() {
+	ms.procMu.Lock()
+	defer ms.procMu.Unlock()
+	if ms.versionStop != nil {
+		close(ms.versionStop)
+		ms.versionStop = nil
+	}
+}
+
+func (ms *MCPService) checkMCPVersionsIfDue(now time.Time) {
+	cache, err := loadMCPVersionCache()
+	if err != nil || now.Sub(cache.CheckedAt) < mcpVersionCheckInterval {
+		return
+	}
+	versions, err := ms.CheckMCPServerVersions()
+	if err != nil {
+		fmt.Printf("[WARN] 检查 MCP 服务版本失败: %v\n", err)
+		return
+	}
+	var updates []MCPVersionInfo
+	for _, info := range versions {
+		if info.UpdateAvailable {
+			updates = append(updates, info)
+		}
+	}
+	ms.procMu.Lock()
+	handler := ms.versionHandler
+	ms.procMu.Unlock()
+	if handler != nil && len(updates) > 0 {
+		handler(updates)
+	}
+}
+
+// ListMCPServerVersions 返回上次检查的结果，不访问网络
+func (ms *MCPService) ListMCPServerVersions() ([]MCPVersionInfo, error) {
+	cache, err := loadMCPVersionCache()
+	if err != nil {
+		return nil, err
+	}
+	return cache.Versions, nil
+}
+
+// CheckMCPServerVersions 查询 npm / PyPI 上各服务包的最新版本
+func (ms *MCPService) CheckMCPServerVersions() ([]MCPVersionInfo, error) {
+	servers, err := ms.ListServers()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	versions := make([]MCPVersionInfo, 0, len(servers))
+	for _, server := range servers {
+		spec, ok := parseMCPPackageSpec(server)
+		if !ok {
+			continue
+		}
+		versions = append(versions, MCPVersionInfo{
+			Server:    server.Name,
+			Registry:  spec.Registry,
+			Package:   spec.Package,
+			Current:   spec.Version,
+			Pinned:    mcpExactVersionPattern.MatchString(spec.Version),
+			CheckedAt: now,
+		})
+	}
+
+	// 多个服务使用同一个包时只查询一次
+	type lookup struct {
+		latest string
+		err    error
+	}
+	results := make(map[string]*lookup)
+	for _, info := range versions {
+		results[info.Registry+"\x00"+info.Package] = &lookup{}
+	}
+	client := &http.Client{Timeout: 15 * time.Second}
+	sem := make(chan struct{}, mcpVersionConcurrency)
+	var wg sync.WaitGroup
+	for key, result := range results {
+		wg.Add(1)
+		go func(key string, result *lookup) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			registry, pkg, _ := strings.Cut(key, "\x00")
+			result.latest, result.err = fetchMCPLatestVersion(client, registry, pkg)
+		}(key, result)
+	}
+	wg.Wait()
+
+	for i := range versions {
+		info := &versions[i]
+		result := results[info.Registry+"\x00"+info.Package]
+		if result.err != nil {
+			info.Error = result.err.Error()
+			continue
+		}
+		info.Latest = result.latest
+		info.UpdateAvailable = info.Pinned && compareVersions(info.Latest, info.Current) > 0
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Server < versions[j].Server })
+	if err := saveMCPVersionCache(mcpVersionCache{CheckedAt: now, Versions: versions}); err != nil {
+		return versions, err
+	}
+	return versions, nil
+}
+
+// UpdateMCPServerVersions 把指定服务的包版本锁定为最新版本，保存后同步到所有 CLI 配置，
+// 正在运行的托管进程会重启以加载新版本
+func (ms *MCPService) UpdateMCPServerVersions(names []string) ([]MCPVersionInfo, error) {
+	cache, err := loadMCPVersionCache()
+	if err != nil {
+		return nil, err
+	}
+	latest := make(map[string]string, len(cache.Versions))
+	for _, info := range cache.Versions {
+		if info.Latest != "" {
+			latest[info.Server] = info.Latest
+		}
+	}
+	servers, err := ms.ListServers()
+	if err != nil {
+		return nil, err
+	}
+	var updated []string
+	for i := range servers {
+		if !containsPlatform(names, servers[i].Name) {
+			continue
+		}
+		version, ok := latest[servers[i].Name]
+		if !ok {
+			return nil, fmt.Errorf("%s 尚未检查到最新版本，请先检查更新", servers[i].Name)
+		}
+		spec, ok := parseMCPPackageSpec(servers[i])
+		if !ok {
+			return nil, fmt.Errorf("%s 不是 npm 或 PyPI 包形式的服务", servers[i].Name)
+		}
+		if spec.Version == version {
+			continue
+		}
+		servers[i].Args = cloneArgs(servers[i].Args)
+		servers[i].Args[spec.Index] = spec.withVersion(version)
+		updated = append(updated, servers[i].Name)
+	}
+	if len(updated) > 0 {
+		if err := ms.SaveServers(servers); err != nil {
+			return nil, err
+		}
+		for _, name := range updated {
+			ms.restartRunningMCPProcess(name)
+		}
+	}
+
+	for i := range cache.Versions {
+		info := &cache.Versions[i]
+		if containsPlatform(updated, info.Server) {
+			info.Current = info.Latest
+			info.Pinned = true
+			info.UpdateAvailable = false
+		}
+	}
+	if err := saveMCPVersionCache(cache); err != nil {
+		return nil, err
+	}
+	return cache.Versions, nil
+}
+
+func (ms *MCPService) restartRunningMCPProcess(name string) {
+	ms.procMu.Lock()
+	_, running := ms.processes[name]
+	ms.procMu.Unlock()
+	if !running {
+		return
+	}
+	if _, err := ms.RestartMCPProcess(name); err != nil {
+		fmt.Printf("[WARN] 重启 MCP 进程 %s 失败: %v\n", name, err)
+	}
+}
+
+// parseMCPPackageSpec 从 npx / bunx / pnpx / uvx / pipx run 的参数中找出包名与版本
+func parseMCPPackageSpec(server MCPServer) (mcpPackageSpec, bool) {
+	if server.Type != "stdio" {
+		return mcpPackageSpec{}, false
+	}
+	command := strings.ToLower(filepath.Base(strings.TrimSpace(server.Command)))
+	command = strings.TrimSuffix(strings.TrimSuffix(command, ".cmd"), ".exe")
+	args := server.Args
+	var registry string
+	var valueFlags, packageFlags []string
+	switch command {
+	case "npx", "bunx", "pnpx":
+		registry = MCPRegistryNPM
+		valueFlags = []string{"-c", "--call", "--cache", "--registry", "--userconfig"}
+		packageFlags = []string{"-p", "--package"}
+	case "uvx", "pipx":
+		registry = MCPRegistryPyPI
+		valueFlags = []string{"--python", "--with", "--index", "--index-url", "--extra-index-url", "--pip-args"}
+		packageFlags = []string{"--from"}
+		if command == "pipx" {
+			if len(args) == 0 || args[0] != "run" {
+				return mcpPackageSpec{}, false
+			}
+			packageFlags = append(packageFlags, "--spec")
+		}
+	default:
+		return mcpPackageSpec{}, false
+	}
+
+	start := 0
+	if command == "pipx" {
+		start = 1
+	}
+	for i := start; i < len(args); i++ {
+		arg := strings.TrimSpace(args[i])
+		flag, value, inline := strings.Cut(arg, "=")
+		switch {
+		case containsPlatform(packageFlags, flag):
+			if inline {
+				return finishMCPPackageSpec(registry, value, i, flag+"=")
+			}
+			if i+1 < len(args) {
+				return finishMCPPackageSpec(registry, args[i+1], i+1, "")
+			}
+			return mcpPackageSpec{}, false
+		case containsPlatform(valueFlags, flag):
+			if !inline {
+				i++
+			}
+		case strings.HasPrefix(arg, "-"):
+		default:
+			return finishMCPPackageSpec(registry, arg, i, "")
+		}
+	}
+	return mcpPackageSpec{}, false
+}
+
+// finishMCPPackageSpec 拆分 name@version / name==version；本地路径、git 地址与占位符不处理
+func finishMCPPackageSpec(registry, arg string, index int, prefix string) (mcpPackageSpec, bool) {
+	spec := mcpPackageSpec{Registry: registry, Index: index, Prefix: prefix, Separator: "@"}
+	if arg == "" || strings.ContainsAny(arg, ":\\{") || strings.HasPrefix(arg, ".") {
+		return spec, false
+	}
+	if registry == MCPRegistryNPM {
+		at := strings.LastIndex(arg, "@")
+		if at > 0 {
+			spec.Package, spec.Version = arg[:at], arg[at+1:]
+		} else {
+			spec.Package = arg
+		}
+		// 非 scope 包名中不应出现 /，否则是 GitHub 简写
+		if strings.Contains(spec.Package, "/") && !strings.HasPrefix(spec.Package, "@") {
+			return spec, false
+		}
+		return spec, spec.Package != ""
+	}
+	name := arg
+	spec.Separator = "=="
+	if i := strings.Index(arg, "=="); i >= 0 {
+		name, spec.Version = arg[:i], arg[i+2:]
+	} else if i := strings.Index(arg, "@"); i >= 0 {
+		name, spec.Version, spec.Separator = arg[:i], arg[i+1:], "@"
+	}
+	if strings.ContainsAny(name, "/<>~!") {
+		return spec, false
+	}
+	// 保留 extras 写回参数，查询注册表时去掉
+	spec.Package = name
+	return spec, name != ""
+}
+
+// withVersion 生成锁定到指定版本的参数，保留原有的写法
+func (spec mcpPackageSpec) withVersion(version string) string {
+	return spec.Prefix + spec.Package + spec.Separator + version
+}
+
+func fetchMCPLatestVersion(client *http.Client, registry, pkg string) (string, error) {
+	var url string
+	if registry == MCPRegistryNPM {
+		url = mcpNPMRegistry + "/-/package/" + pkg + "/dist-tags"
+	} else {
+		if i := strings.Index(pkg, "["); i >= 0 {
+			pkg = pkg[:i]
+		}
+		url = mcpPyPIRegistry + "/" + pkg + "/json"
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("注册表中不存在 %s", pkg)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("查询 %s 失败: HTTP %d", pkg, resp.StatusCode)
+	}
+	var payload struct {
+		Latest string `json:"latest"`
+		Info   struct {
+			Version string `json:"version"`
+		} `json:"info"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return "", err
+	}
+	version := payload.Latest
+	if registry == MCPRegistryPyPI {
+		version = payload.Info.Version
+	}
+	if version == "" {
+		return "", fmt.Errorf("未获取到 %s 的最新版本", pkg)
+	}
+	return version, nil
+}
+
+func mcpVersionCachePath() (string, error) {
+	dir, err := ensureDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, mcpVersionsFile), nil
+}
+
+func loadMCPVersionCache() (mcpVersionCache, error) {
+	cache := mcpVersionCache{Versions: []MCPVersionInfo{}}
+	path, err := mcpVersionCachePath()
+	if err != nil {
+		return cache, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cache, nil
+		}
+		return cache, err
+	}
+	if len(data) == 0 {
+		return cache, nil
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return cache, err
+	}
+	if cache.Versions == nil {
+		cache.Versions = []MCPVersionInfo{}
+	}
+	return cache, nil
+}
+
+func saveMCPVersionCache(cache mcpVersionCache) error {
+	path, err := mcpVersionCachePath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
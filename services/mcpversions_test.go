@@ -0,0 +1,73 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseMCPPackageSpec(t *testing.T) {
+	cases := []struct {
+		command  string
+		args     []string
+		ok       bool
+		pkg      string
+		version  string
+		index    int
+		upgraded string
+	}{
+		{"npx", []string{"-y", "@modelcontextprotocol/server-github@2025.4.8"}, true, "@modelcontextprotocol/server-github", "2025.4.8", 1, "@modelcontextprotocol/server-github@2025.9.1"},
+		{"npx.cmd", []string{"--yes", "mcp-remote", "https://example.com/mcp"}, true, "mcp-remote", "", 1, "mcp-remote@2025.9.1"},
+		{"npx", []string{"-p", "tool@1.0.0", "tool-cli"}, true, "tool", "1.0.0", 1, "tool@2025.9.1"},
+		{"uvx", []string{"mcp-server-fetch==0.6.2"}, true, "mcp-server-fetch", "0.6.2", 0, "mcp-server-fetch==2025.9.1"},
+		{"uvx", []string{"--python", "3.12", "--from=mcp-server-git@1.0", "mcp-server-git"}, true, "mcp-server-git", "1.0", 2, "--from=mcp-server-git@2025.9.1"},
+		{"pipx", []string{"run", "mcp-server-time"}, true, "mcp-server-time", "", 1, "mcp-server-time==2025.9.1"},
+		{"npx", []string{"-y", "github:owner/repo"}, false, "", "", 0, ""},
+		{"npx", []string{"owner/repo"}, false, "", "", 0, ""},
+		{"uvx", []string{"git+https://github.com/owner/repo"}, false, "", "", 0, ""},
+		{"node", []string{"server.js"}, false, "", "", 0, ""},
+		{"pipx", []string{"install", "x"}, false, "", "", 0, ""},
+	}
+	for _, tc := range cases {
+		spec, ok := parseMCPPackageSpec(MCPServer{Name: "s", Type: "stdio", Command: tc.command, Args: tc.args})
+		if ok != tc.ok {
+			t.Fatalf("%s %v: ok = %v", tc.command, tc.args, ok)
+		}
+		if !ok {
+			continue
+		}
+		if spec.Package != tc.pkg || spec.Version != tc.version || spec.Index != tc.index {
+			t.Fatalf("%s %v: spec = %+v", tc.command, tc.args, spec)
+		}
+		if got := spec.withVersion("2025.9.1"); got != tc.upgraded {
+			t.Fatalf("%s %v: upgraded = %q", tc.command, tc.args, got)
+		}
+	}
+}
+
+func TestFetchMCPLatestVersion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/npm/-/package/@scope/tool/dist-tags":
+			_, _ = w.Write([]byte(`{"latest":"1.4.0","next":"2.0.0-rc.1"}`))
+		case "/pypi/mcp-server-fetch/json":
+			_, _ = w.Write([]byte(`{"info":{"version":"0.7.0"}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	npm, pypi := mcpNPMRegistry, mcpPyPIRegistry
+	mcpNPMRegistry, mcpPyPIRegistry = server.URL+"/npm", server.URL+"/pypi"
+	defer func() { mcpNPMRegistry, mcpPyPIRegistry = npm, pypi }()
+
+	if version, err := fetchMCPLatestVersion(server.Client(), MCPRegistryNPM, "@scope/tool"); err != nil || version != "1.4.0" {
+		t.Fatalf("npm = %q, %v", version, err)
+	}
+	if version, err := fetchMCPLatestVersion(server.Client(), MCPRegistryPyPI, "mcp-server-fetch[cli]"); err != nil || version != "0.7.0" {
+		t.Fatalf("pypi = %q, %v", version, err)
+	}
+	if _, err := fetchMCPLatestVersion(server.Client(), MCPRegistryNPM, "missing"); err == nil {
+		t.Fatal("expected error for missing package")
+	}
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"fmt"
+	"time"
+)
+
+// 通知严重程度，由低到高
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+var severityRanks = map[string]int{
+	SeverityInfo:     0,
+	SeverityWarning:  1,
+	SeverityCritical: 2,
+}
+
+// QuietHours 免打扰时段（本地时间，HH:MM），Start 晚于 End 时跨午夜
+type QuietHours struct {
+	Enabled bool   `json:"enabled"`
+	Start   string `json:"start"`
+	End     string `json:"end"`
+}
+
+// EventPreference 单个事件类型的通知偏好
+type EventPreference struct {
+	Enabled bool `json:"enabled"`
+	// 低于该严重程度的通知不发送
+	MinSeverity string `json:"min_severity"`
+	// 免打扰时段内仍然发送
+	IgnoreQuietHours bool `json:"ignore_quiet_hours"`
+}
+
+// NotificationPreferences 免打扰时段与按事件类型的通知偏好，未配置的事件类型使用默认值
+type NotificationPreferences struct {
+	QuietHours QuietHours                 `json:"quiet_hours"`
+	Events     map[string]EventPreference `json:"events"`
+}
+
+func defaultNotificationPreferences() NotificationPreferences {
+	return NotificationPreferences{
+		QuietHours: QuietHours{Start: "23:00", End: "08:00"},
+		Events: map[string]EventPreference{
+			NotificationProviderSwitch: {Enabled: true, MinSeverity: SeverityInfo},
+			NotificationBlacklist:      {Enabled: true, MinSeverity: SeverityInfo},
+			NotificationRecovery:       {Enabled: true, MinSeverity: SeverityInfo},
+			NotificationBudget:         {Enabled: true, MinSeverity: SeverityInfo, IgnoreQuietHours: true},
+			NotificationOutage:         {Enabled: true, MinSeverity: SeverityInfo, IgnoreQuietHours: true},
+			NotificationUpdate:         {Enabled: true, MinSeverity: SeverityInfo},
+		},
+	}
+}
+
+func normalizeNotificationPreferences(prefs NotificationPreferences) NotificationPreferences {
+	defaults := defaultNotificationPreferences()
+	if _, ok := parseClock(prefs.QuietHours.Start); !ok {
+		prefs.QuietHours.Start = defaults.QuietHours.Start
+	}
+	if _, ok := parseClock(prefs.QuietHours.End); !ok {
+		prefs.QuietHours.End = defaults.QuietHours.End
+	}
+	events := make(map[string]EventPreference, len(defaults.Events))
+	for event, pref := range defaults.Events {
+		events[event] = pref
+	}
+	for event, pref := range prefs.Events {
+		if _, ok := severityRanks[pref.MinSeverity]; !ok {
+			pref.MinSeverity = SeverityInfo
+		}
+		events[event] = pref
+	}
+	prefs.Events = events
+	return prefs
+}
+
+// shouldDispatch 按事件偏好、最低严重程度与免打扰时段判断是否发送
+func shouldDispatch(prefs NotificationPreferences, notification Notification, now time.Time) bool {
+	pref, ok := prefs.Events[notification.Event]
+	if !ok {
+		return true
+	}
+	if !pref.Enabled {
+		return false
+	}
+	if severityRanks[notification.Severity] < severityRanks[pref.MinSeverity] {
+		return false
+	}
+	if pref.IgnoreQuietHours || !prefs.QuietHours.Enabled {
+		return true
+	}
+	return !inQuietHours(prefs.QuietHours, now)
+}
+
+func inQuietHours(quiet QuietHours, now time.Time) bool {
+	start, ok := parseClock(quiet.Start)
+	if !ok {
+		return false
+	}
+	end, ok := parseClock(quiet.End)
+	if !ok || start == end {
+		return false
+	}
+	minute := now.Hour()*60 + now.Minute()
+	if start < end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// parseClock 解析 HH:MM，返回当天的分钟数
+func parseClock(value string) (int, bool) {
+	var hour, minute int
+	if _, err := fmt.Sscanf(value, "%d:%d", &hour, &minute); err != nil {
+		return 0, false
+	}
+	if hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return 0, false
+	}
+	return hour*60 + minute, true
+}
+
+// allowed 读取当前偏好判断通知是否应发送
+func (ns *NotificationService) allowed(notification Notification) bool {
+	settings, err := ns.GetSettings()
+	if err != nil {
+		return true
+	}
+	at := notification.CreatedAt
+	if at.IsZero() {
+		at = time.Now()
+	}
+	return shouldDispatch(settings.Preferences, notification, at)
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShouldDispatch(t *testing.T) {
+	prefs := normalizeNotificationPreferences(NotificationPreferences{
+		QuietHours: QuietHours{Enabled: true, Start: "22:00", End: "07:30"},
+		Events: map[string]EventPreference{
+			NotificationBlacklist: {Enabled: true, MinSeverity: SeverityWarning},
+			NotificationUpdate:    {Enabled: false},
+		},
+	})
+	night := time.Date(2026, 3, 1, 23, 15, 0, 0, time.Local)
+	morning := time.Date(2026, 3, 1, 7, 29, 0, 0, time.Local)
+	day := time.Date(2026, 3, 1, 14, 0, 0, 0, time.Local)
+
+	cases := []struct {
+		name         string
+		notification Notification
+		now          time.Time
+		want         bool
+	}{
+		{"switch during day", Notification{Event: NotificationProviderSwitch, Severity: SeverityInfo}, day, true},
+		{"switch at night", Notification{Event: NotificationProviderSwitch, Severity: SeverityInfo}, night, false},
+		{"switch before quiet hours end", Notification{Event: NotificationProviderSwitch, Severity: SeverityInfo}, morning, false},
+		{"budget ignores quiet hours", Notification{Event: NotificationBudget, Severity: SeverityWarning}, night, true},
+		{"blacklist below min severity", Notification{Event: NotificationBlacklist, Severity: SeverityInfo}, day, false},
+		{"blacklist at min severity", Notification{Event: NotificationBlacklist, Severity: SeverityWarning}, day, true},
+		{"disabled event", Notification{Event: NotificationUpdate, Severity: SeverityCritical}, day, false},
+		{"unknown event", Notification{Event: "custom"}, night, true},
+	}
+	for _, tc := range cases {
+		if got := shouldDispatch(prefs, tc.notification, tc.now); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
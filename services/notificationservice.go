@@ -0,0 +1,481 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+)
+
+// 通知事件类型
+const (
+	NotificationProviderSwitch = "provider_switch"
+	NotificationBlacklist      = "blacklist"
+	NotificationRecovery       = "recovery"
+	NotificationOutage         = "outage"
+	NotificationBudget         = "budget"
+	NotificationUpdate         = "update"
+)
+
+// 通知渠道类型
+const (
+	ChannelSlack    = "slack"
+	ChannelDiscord  = "discord"
+	ChannelTelegram = "telegram"
+	ChannelFeishu   = "feishu"
+	ChannelDingTalk = "dingtalk"
+	ChannelWebhook  = "webhook"
+)
+
+const (
+	notificationTimeout         = 10 * time.Second
+	notificationCheckInterval   = time.Minute
+	defaultNotificationTemplate = "【Code Switch】{{.Title}}\n{{.Message}}"
+)
+
+// Notification 一条待发送的通知
+type Notification struct {
+	Event     string    `json:"event"`
+	Title     string    `json:"title"`
+	Message   string    `json:"message"`
+	Severity  string    `json:"severity"`
+	Platform  string    `json:"platform,omitempty"`
+	Provider  string    `json:"provider,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NotificationChannel 一个 webhook/IM 通知渠道
+type NotificationChannel struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Enabled bool   `json:"enabled"`
+	// webhook 地址；Telegram 填 bot token 或完整的 sendMessage 地址
+	URL string `json:"url"`
+	// Telegram 的 chat_id
+	ChatID string `json:"chat_id,omitempty"`
+	// 飞书、钉钉机器人的签名密钥，为空时不签名
+	Secret string `json:"secret,omitempty"`
+	// text/template 消息模板，可用 .Event .Title .Message .Platform .Provider .Time，为空时使用默认模板
+	Template string `json:"template,omitempty"`
+	// 接收的事件类型，为空表示全部
+	Events []string `json:"events"`
+}
+
+// NotificationSettings 通知渠道与通知偏好配置
+type NotificationSettings struct {
+	Channels    []NotificationChannel   `json:"channels"`
+	Preferences NotificationPreferences `json:"preferences"`
+}
+
+// activeNotifier 由 NotificationService 注册，供 provider 事件等内部路径直接发送通知
+var activeNotifier atomic.Pointer[NotificationService]
+
+type NotificationService struct {
+	appSettings      *AppSettingsService
+	blacklistService *BlacklistService
+	logService       *LogService
+	httpClient       *http.Client
+	mu               sync.Mutex
+	stopCh           chan struct{}
+	// provider 持续拉黑的起始时间与已发送过不可用邮件的 provider，key 为 blacklistKey
+	downSince    map[string]time.Time
+	downNotified map[string]struct{}
+	// 最近一次已通知的新版本，避免重复检查时重复通知
+	notifiedVersion string
+}
+
+func NewNotificationService(appSettings *AppSettingsService, blacklistService *BlacklistService, logService *LogService) *NotificationService {
+	ns := &NotificationService{
+		appSettings:      appSettings,
+		blacklistService: blacklistService,
+		logService:       logService,
+		httpClient:       &http.Client{Timeout: notificationTimeout},
+		downSince:        make(map[string]time.Time),
+		downNotified:     make(map[string]struct{}),
+	}
+	activeNotifier.Store(ns)
+	return ns
+}
+
+// Start 启动定时检查：provider 持续不可用与每日用量摘要
+func (ns *NotificationService) Start() error {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	if ns.stopCh != nil {
+		return nil
+	}
+	stopCh := make(chan struct{})
+	ns.stopCh = stopCh
+	go func() {
+		ticker := time.NewTicker(notificationCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				now := time.Now()
+				ns.checkProvidersDown(now)
+				ns.sendDailyDigestIfDue(now)
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (ns *NotificationService) Stop() error {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	if ns.stopCh != nil {
+		close(ns.stopCh)
+		ns.stopCh = nil
+	}
+	return nil
+}
+
+func normalizeNotificationSettings(settings NotificationSettings) NotificationSettings {
+	channels := make([]NotificationChannel, 0, len(settings.Channels))
+	for i, channel := range settings.Channels {
+		channel.Type = strings.ToLower(strings.TrimSpace(channel.Type))
+		channel.URL = strings.TrimSpace(channel.URL)
+		channel.ChatID = strings.TrimSpace(channel.ChatID)
+		channel.Name = strings.TrimSpace(channel.Name)
+		if channel.ID == "" {
+			channel.ID = strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + strconv.Itoa(i)
+		}
+		if channel.Name == "" {
+			channel.Name = channel.Type
+		}
+		if channel.Events == nil {
+			channel.Events = []string{}
+		}
+		channels = append(channels, channel)
+	}
+	settings.Channels = channels
+	settings.Preferences = normalizeNotificationPreferences(settings.Preferences)
+	return settings
+}
+
+func validateNotificationChannel(channel NotificationChannel) error {
+	switch channel.Type {
+	case ChannelSlack, ChannelDiscord, ChannelFeishu, ChannelDingTalk, ChannelWebhook:
+		if !strings.HasPrefix(channel.URL, "http://") && !strings.HasPrefix(channel.URL, "https://") {
+			return fmt.Errorf("通知渠道 %s 的 webhook 地址无效", channel.Name)
+		}
+	case ChannelTelegram:
+		if channel.URL == "" || channel.ChatID == "" {
+			return fmt.Errorf("通知渠道 %s 需要填写 bot token 与 chat_id", channel.Name)
+		}
+	default:
+		return fmt.Errorf("不支持的通知渠道类型: %s", channel.Type)
+	}
+	if channel.Template != "" {
+		if _, err := template.New("notification").Parse(channel.Template); err != nil {
+			return fmt.Errorf("通知渠道 %s 的消息模板无效: %w", channel.Name, err)
+		}
+	}
+	return nil
+}
+
+// GetSettings 返回通知渠道配置
+func (ns *NotificationService) GetSettings() (NotificationSettings, error) {
+	settings, err := ns.appSettings.GetAppSettings()
+	if err != nil {
+		return NotificationSettings{}, err
+	}
+	return normalizeNotificationSettings(settings.Notifications), nil
+}
+
+// SaveSettings 校验并保存通知渠道配置
+func (ns *NotificationService) SaveSettings(notifications NotificationSettings) (NotificationSettings, error) {
+	notifications = normalizeNotificationSettings(notifications)
+	for _, channel := range notifications.Channels {
+		if err := validateNotificationChannel(channel); err != nil {
+			return notifications, err
+		}
+	}
+	if _, err := ns.appSettings.update(func(settings *AppSettings) {
+		settings.Notifications = notifications
+	}); err != nil {
+		return notifications, err
+	}
+	return notifications, nil
+}
+
+// TestChannel 向指定渠道发送一条测试消息（渠道无需先保存）
+func (ns *NotificationService) TestChannel(channel NotificationChannel) error {
+	channel = normalizeNotificationSettings(NotificationSettings{Channels: []NotificationChannel{channel}}).Channels[0]
+	if err := validateNotificationChannel(channel); err != nil {
+		return err
+	}
+	return ns.send(channel, Notification{
+		Event:     "test",
+		Title:     "测试通知",
+		Message:   "通知渠道配置成功",
+		CreatedAt: time.Now(),
+	})
+}
+
+// Notify 按通知偏好过滤后，按事件类型路由到已启用的渠道，异步发送
+func (ns *NotificationService) Notify(notification Notification) {
+	if notification.CreatedAt.IsZero() {
+		notification.CreatedAt = time.Now()
+	}
+	if notification.Severity == "" {
+		notification.Severity = SeverityInfo
+	}
+	settings, err := ns.GetSettings()
+	if err != nil || !shouldDispatch(settings.Preferences, notification, notification.CreatedAt) {
+		return
+	}
+	for _, channel := range settings.Channels {
+		if !channel.Enabled || !channelAcceptsEvent(channel, notification.Event) {
+			continue
+		}
+		go func(channel NotificationChannel) {
+			if err := ns.send(channel, notification); err != nil {
+				fmt.Printf("[WARN] 通知渠道 %s 发送失败: %v\n", channel.Name, err)
+			}
+		}(channel)
+	}
+}
+
+// NotifyBudgetAlert 预算达到告警阈值时发送通知
+func (ns *NotificationService) NotifyBudgetAlert(alert BudgetAlert) {
+	scope := "总预算"
+	if alert.Platform != "" {
+		scope = alert.Platform + " 预算"
+	}
+	message := fmt.Sprintf("%s%s已使用 %.0f%%（%s / %s）", budgetPeriodLabel(alert.Period), scope, alert.Percent,
+		formatCost(alert.Spent, alert.Currency), formatCost(alert.Limit, alert.Currency))
+	if alert.Blocked {
+		message += "，已暂停转发请求"
+	}
+	notification := Notification{
+		Event:    NotificationBudget,
+		Title:    fmt.Sprintf("预算达到 %d%%", alert.Threshold),
+		Message:  message,
+		Severity: SeverityWarning,
+		Platform: alert.Platform,
+	}
+	if alert.Exceeded {
+		notification.Severity = SeverityCritical
+	}
+	ns.Notify(notification)
+	if alert.Exceeded {
+		go func() {
+			if email, err := ns.GetEmailSettings(); err == nil && email.BudgetExceeded && ns.allowed(notification) {
+				ns.sendEmailNotification(notification.Title, notification.Message)
+			}
+		}()
+	}
+}
+
+// NotifyRecovery 被拉黑的 provider 恢复可用时发送通知
+func (ns *NotificationService) NotifyRecovery(recovery BlacklistRecovery) {
+	ns.Notify(Notification{
+		Event:     NotificationRecovery,
+		Title:     fmt.Sprintf("[%s] %s 已恢复", recovery.Platform, recovery.Provider),
+		Message:   recovery.Reason,
+		Severity:  SeverityInfo,
+		Platform:  recovery.Platform,
+		Provider:  recovery.Provider,
+		CreatedAt: recovery.RecoveredAt,
+	})
+}
+
+// NotifyOutage 平台全部 provider 不可用或恢复时发送通知，中断通知为最高优先级
+func (ns *NotificationService) NotifyOutage(outage PlatformOutage) {
+	notification := Notification{
+		Event:    NotificationOutage,
+		Platform: outage.Platform,
+	}
+	if outage.Down {
+		notification.Title = fmt.Sprintf("[%s] 全部 provider 不可用", outage.Platform)
+		notification.Message = fmt.Sprintf("%s（%s）", outage.Reason, strings.Join(outage.Providers, "、"))
+		notification.Severity = SeverityCritical
+		notification.CreatedAt = outage.Since
+	} else {
+		notification.Title = fmt.Sprintf("[%s] 服务已恢复", outage.Platform)
+		notification.Message = fmt.Sprintf("中断 %s", outage.ResolvedAt.Sub(outage.Since).Round(time.Second))
+		notification.Severity = SeverityInfo
+		notification.CreatedAt = outage.ResolvedAt
+	}
+	ns.Notify(notification)
+	if outage.Down {
+		go func() {
+			if ns.allowed(notification) {
+				ns.sendEmailNotification(notification.Title, notification.Message)
+			}
+		}()
+	}
+}
+
+// NotifyUpdate 发现新版本时发送通知，同一版本只通知一次
+func (ns *NotificationService) NotifyUpdate(state UpdateState) {
+	ns.mu.Lock()
+	if state.LatestVersion == "" || ns.notifiedVersion == state.LatestVersion {
+		ns.mu.Unlock()
+		return
+	}
+	ns.notifiedVersion = state.LatestVersion
+	ns.mu.Unlock()
+	message := fmt.Sprintf("当前版本 %s", state.CurrentVersion)
+	if state.Install.Managed {
+		message += fmt.Sprintf("，请使用 %s 升级", state.Install.Command())
+	}
+	ns.Notify(Notification{
+		Event:    NotificationUpdate,
+		Title:    fmt.Sprintf("发现新版本 %s", state.LatestVersion),
+		Message:  message,
+		Severity: SeverityInfo,
+	})
+}
+
+// notifyProviderEvent 把拉黑与故障切换事件转为通知；平台整体中断期间由中断通知代替，不再逐条发送
+func notifyProviderEvent(event ProviderEvent) {
+	ns := activeNotifier.Load()
+	if ns == nil || isPlatformDown(event.Platform) {
+		return
+	}
+	notification := Notification{
+		Platform: event.Platform,
+		Provider: event.Provider,
+		Message:  event.Reason,
+	}
+	switch event.EventType {
+	case ProviderEventFailover:
+		notification.Event = NotificationProviderSwitch
+		notification.Severity = SeverityInfo
+		notification.Title = fmt.Sprintf("[%s] %s → %s", event.Platform, event.Provider, event.TargetProvider)
+	case ProviderEventBlacklist:
+		notification.Event = NotificationBlacklist
+		notification.Severity = SeverityWarning
+		notification.Title = fmt.Sprintf("[%s] %s 已被拉黑", event.Platform, event.Provider)
+	default:
+		return
+	}
+	// 调用方可能持有拉黑状态锁，读取配置与发送都放到后台
+	go ns.Notify(notification)
+}
+
+func channelAcceptsEvent(channel NotificationChannel, event string) bool {
+	if len(channel.Events) == 0 {
+		return true
+	}
+	for _, accepted := range channel.Events {
+		if accepted == event {
+			return true
+		}
+	}
+	return false
+}
+
+func (ns *NotificationService) send(channel NotificationChannel, notification Notification) error {
+	text, err := renderNotification(channel.Template, notification)
+	if err != nil {
+		return err
+	}
+	endpoint, payload, err := notificationRequest(channel, notification, text, time.Now())
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := ns.httpClient.Post(endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return nil
+}
+
+func renderNotification(text string, notification Notification) (string, error) {
+	if strings.TrimSpace(text) == "" {
+		text = defaultNotificationTemplate
+	}
+	tmpl, err := template.New("notification").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, struct {
+		Notification
+		Time string
+	}{notification, notification.CreatedAt.Format("2006-01-02 15:04:05")}); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// notificationRequest 按渠道类型构造请求地址与请求体
+func notificationRequest(channel NotificationChannel, notification Notification, text string, now time.Time) (string, any, error) {
+	switch channel.Type {
+	case ChannelSlack:
+		return channel.URL, map[string]any{"text": text}, nil
+	case ChannelDiscord:
+		return channel.URL, map[string]any{"content": text}, nil
+	case ChannelTelegram:
+		endpoint := channel.URL
+		if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+			endpoint = "https://api.telegram.org/bot" + endpoint + "/sendMessage"
+		}
+		return endpoint, map[string]any{"chat_id": channel.ChatID, "text": text}, nil
+	case ChannelFeishu:
+		payload := map[string]any{
+			"msg_type": "text",
+			"content":  map[string]string{"text": text},
+		}
+		if channel.Secret != "" {
+			timestamp := strconv.FormatInt(now.Unix(), 10)
+			// 飞书以 "timestamp\nsecret" 为密钥对空串签名
+			mac := hmac.New(sha256.New, []byte(timestamp+"\n"+channel.Secret))
+			payload["timestamp"] = timestamp
+			payload["sign"] = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+		}
+		return channel.URL, payload, nil
+	case ChannelDingTalk:
+		endpoint := channel.URL
+		if channel.Secret != "" {
+			timestamp := strconv.FormatInt(now.UnixMilli(), 10)
+			mac := hmac.New(sha256.New, []byte(channel.Secret))
+			mac.Write([]byte(timestamp + "\n" + channel.Secret))
+			separator := "?"
+			if strings.Contains(endpoint, "?") {
+				separator = "&"
+			}
+			endpoint += separator + "timestamp=" + timestamp + "&sign=" +
+				url.QueryEscape(base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+		}
+		return endpoint, map[string]any{
+			"msgtype": "text",
+			"text":    map[string]string{"content": text},
+		}, nil
+	case ChannelWebhook:
+		return channel.URL, struct {
+			Notification
+			Text string `json:"text"`
+		}{notification, text}, nil
+	default:
+		return "", nil, errors.New("不支持的通知渠道类型: " + channel.Type)
+	}
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderNotification(t *testing.T) {
+	notification := Notification{
+		Event:     NotificationBlacklist,
+		Title:     "[claude] a 已被拉黑",
+		Message:   "连续失败 3 次",
+		Provider:  "a",
+		CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local),
+	}
+	text, err := renderNotification("", notification)
+	if err != nil {
+		t.Fatalf("render default template: %v", err)
+	}
+	if text != "【Code Switch】[claude] a 已被拉黑\n连续失败 3 次" {
+		t.Fatalf("unexpected default text: %q", text)
+	}
+	text, err = renderNotification("{{.Provider}} {{.Event}} {{.Time}}", notification)
+	if err != nil {
+		t.Fatalf("render custom template: %v", err)
+	}
+	if text != "a blacklist 2026-01-02 03:04:05" {
+		t.Fatalf("unexpected custom text: %q", text)
+	}
+}
+
+func TestNotificationRequest(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	endpoint, payload, err := notificationRequest(NotificationChannel{
+		Type:   ChannelDingTalk,
+		URL:    "https://oapi.dingtalk.com/robot/send?access_token=x",
+		Secret: "secret",
+	}, Notification{}, "hi", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(endpoint, "&timestamp=1700000000000&sign=") {
+		t.Fatalf("dingtalk endpoint not signed: %s", endpoint)
+	}
+	if payload.(map[string]any)["msgtype"] != "text" {
+		t.Fatalf("unexpected dingtalk payload: %#v", payload)
+	}
+
+	endpoint, _, err = notificationRequest(NotificationChannel{Type: ChannelTelegram, URL: "123:abc", ChatID: "42"}, Notification{}, "hi", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if endpoint != "https://api.telegram.org/bot123:abc/sendMessage" {
+		t.Fatalf("unexpected telegram endpoint: %s", endpoint)
+	}
+
+	if _, _, err := notificationRequest(NotificationChannel{Type: "pager"}, Notification{}, "hi", now); err == nil {
+		t.Fatal("expected error for unknown channel type")
+	}
+}
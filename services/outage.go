@@ -0,0 +1,108 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// PlatformOutage 某个平台的全部 provider 均被拉黑或不可用
+type PlatformOutage struct {
+	Platform   string    `json:"platform"`
+	Down       bool      `json:"down"`
+	Since      time.Time `json:"since"`
+	Providers  []string  `json:"providers"`
+	Reason     string    `json:"reason"`
+	ResolvedAt time.Time `json:"resolved_at,omitempty"`
+}
+
+// platformOutages 由 relay 维护：全部 provider 不可用时标记平台中断，任意请求成功后解除
+var platformOutages = struct {
+	sync.RWMutex
+	outages map[string]*PlatformOutage
+	handler func(PlatformOutage)
+}{outages: make(map[string]*PlatformOutage)}
+
+// SetOutageHandler 设置平台中断与恢复时的回调（由 main 转为前端事件、通知与托盘状态）
+func (prs *ProviderRelayService) SetOutageHandler(handler func(PlatformOutage)) {
+	platformOutages.Lock()
+	defer platformOutages.Unlock()
+	platformOutages.handler = handler
+}
+
+// ListOutages 返回当前处于中断状态的平台
+func (prs *ProviderRelayService) ListOutages() []PlatformOutage {
+	platformOutages.RLock()
+	defer platformOutages.RUnlock()
+	outages := make([]PlatformOutage, 0, len(platformOutages.outages))
+	for _, outage := range platformOutages.outages {
+		outages = append(outages, *outage)
+	}
+	sort.Slice(outages, func(i, j int) bool { return outages[i].Platform < outages[j].Platform })
+	return outages
+}
+
+// markPlatformDown 标记平台中断，只在状态变化时回调一次
+func markPlatformDown(platform string, providers []Provider, reason string) {
+	platform = normalizePresetKind(platform)
+	platformOutages.Lock()
+	if _, ok := platformOutages.outages[platform]; ok {
+		platformOutages.Unlock()
+		return
+	}
+	names := make([]string, 0, len(providers))
+	for _, provider := range providers {
+		names = append(names, provider.Name)
+	}
+	outage := &PlatformOutage{
+		Platform:  platform,
+		Down:      true,
+		Since:     time.Now(),
+		Providers: names,
+		Reason:    reason,
+	}
+	platformOutages.outages[platform] = outage
+	handler := platformOutages.handler
+	platformOutages.Unlock()
+
+	fmt.Printf("[ERROR] %s 平台的 %d 个 provider 均不可用: %s\n", platform, len(names), reason)
+	if handler != nil {
+		handler(*outage)
+	}
+}
+
+// markPlatformUp 平台有请求成功时解除中断
+func markPlatformUp(platform string) {
+	platform = normalizePresetKind(platform)
+	platformOutages.RLock()
+	_, down := platformOutages.outages[platform]
+	platformOutages.RUnlock()
+	if !down {
+		return
+	}
+	platformOutages.Lock()
+	outage, ok := platformOutages.outages[platform]
+	if !ok {
+		platformOutages.Unlock()
+		return
+	}
+	delete(platformOutages.outages, platform)
+	resolved := *outage
+	resolved.Down = false
+	resolved.ResolvedAt = time.Now()
+	handler := platformOutages.handler
+	platformOutages.Unlock()
+
+	fmt.Printf("[INFO] %s 平台已恢复，中断 %s\n", platform, resolved.ResolvedAt.Sub(resolved.Since).Round(time.Second))
+	if handler != nil {
+		handler(resolved)
+	}
+}
+
+func isPlatformDown(platform string) bool {
+	platformOutages.RLock()
+	defer platformOutages.RUnlock()
+	_, ok := platformOutages.outages[normalizePresetKind(platform)]
+	return ok
+}
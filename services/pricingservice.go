@@ -0,0 +1,398 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	modelpricing "codeswitch/resources/model-pricing"
+
+	"github.com/daodao97/xgo/xdb"
+)
+
+const (
+	modelPricingTable = "model_pricing"
+	// defaultPricingURL LiteLLM 维护的模型价格表，与内置价格文件同源
+	defaultPricingURL   = "https://raw.githubusercontent.com/BerriAI/litellm/main/model_prices_and_context_window.json"
+	defaultPricingFile  = "model-pricing.json"
+	pricingFetchTimeout = 30 * time.Second
+
+	PricingSourceDefault = "default"
+	PricingSourceCustom  = "custom"
+
+	// CacheBillingInput 缓存读写均按输入单价计费
+	CacheBillingInput = "input"
+)
+
+// ModelPrice 价格表中的一行，单价为每百万 token；Provider 为空表示对所有 provider 生效
+type ModelPrice struct {
+	ID                 int64   `json:"id"`
+	Model              string  `json:"model"`
+	Provider           string  `json:"provider"`
+	InputPerMTok       float64 `json:"input_per_mtok"`
+	OutputPerMTok      float64 `json:"output_per_mtok"`
+	CacheCreatePerMTok float64 `json:"cache_create_per_mtok"`
+	CacheReadPerMTok   float64 `json:"cache_read_per_mtok"`
+	Currency           string  `json:"currency"`
+	// 缓存计费方式：空为按缓存单价计费；input 表示该 provider 不提供缓存折扣，缓存读写均按输入单价计费
+	CacheBilling string `json:"cache_billing,omitempty"`
+	// default 为导入的官方价格，重新导入时会被更新；custom 为手动维护，导入不会覆盖
+	Source    string `json:"source"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// pricingTable 价格表的内存缓存，计算费用时按 provider+model 查找
+var pricingTable = struct {
+	sync.RWMutex
+	loaded  bool
+	entries map[string]ModelPrice
+}{}
+
+type PricingService struct {
+	logService *LogService
+	httpClient *http.Client
+}
+
+func NewPricingService(logService *LogService) *PricingService {
+	return &PricingService{
+		logService: logService,
+		httpClient: &http.Client{Timeout: pricingFetchTimeout},
+	}
+}
+
+// ListPricing 返回价格表，provider 覆盖项排在对应模型的通用价格之后
+func (ps *PricingService) ListPricing() ([]ModelPrice, error) {
+	records, err := xdb.New(modelPricingTable).Selects(xdb.OrderByAsc("model"))
+	if err != nil {
+		if errors.Is(err, xdb.ErrNotFound) || isNoSuchTableErr(err) {
+			return []ModelPrice{}, nil
+		}
+		return nil, err
+	}
+	prices := make([]ModelPrice, 0, len(records))
+	for _, record := range records {
+		prices = append(prices, modelPriceFromRecord(record))
+	}
+	sort.SliceStable(prices, func(i, j int) bool {
+		if prices[i].Model != prices[j].Model {
+			return prices[i].Model < prices[j].Model
+		}
+		return prices[i].Provider < prices[j].Provider
+	})
+	return prices, nil
+}
+
+// SavePricing 新增或修改一行价格，同一 provider+model 只保留一行
+func (ps *PricingService) SavePricing(price ModelPrice) (ModelPrice, error) {
+	price.Model = strings.TrimSpace(price.Model)
+	price.Provider = strings.TrimSpace(price.Provider)
+	price.Currency = strings.ToUpper(strings.TrimSpace(price.Currency))
+	if price.Model == "" {
+		return price, errors.New("模型名不能为空")
+	}
+	if price.InputPerMTok < 0 || price.OutputPerMTok < 0 || price.CacheCreatePerMTok < 0 || price.CacheReadPerMTok < 0 {
+		return price, errors.New("单价不能为负数")
+	}
+	if price.Currency == "" {
+		price.Currency = "USD"
+	}
+	price.CacheBilling = strings.ToLower(strings.TrimSpace(price.CacheBilling))
+	if price.CacheBilling != "" && price.CacheBilling != CacheBillingInput {
+		return price, fmt.Errorf("不支持的缓存计费方式: %s", price.CacheBilling)
+	}
+	price.Source = PricingSourceCustom
+	record := price.record()
+	model := xdb.New(modelPricingTable)
+	existing, err := model.First(xdb.WhereEq("model", price.Model), xdb.WhereEq("provider", price.Provider))
+	switch {
+	case err == nil:
+		price.ID = existing.GetInt64("id")
+		if _, err := model.Update(record, xdb.WhereEq("id", price.ID)); err != nil {
+			return price, err
+		}
+	case errors.Is(err, xdb.ErrNotFound):
+		id, err := model.Insert(record)
+		if err != nil {
+			return price, err
+		}
+		price.ID = id
+	default:
+		return price, err
+	}
+	invalidatePricingTable()
+	return price, nil
+}
+
+// DeletePricing 删除一行价格，删除后该模型回落到内置价格
+func (ps *PricingService) DeletePricing(id int64) error {
+	if _, err := xdb.New(modelPricingTable).Delete(xdb.WhereEq("id", id)); err != nil {
+		return err
+	}
+	invalidatePricingTable()
+	return nil
+}
+
+// ImportDefaultPricing 下载最新的官方价格，更新价格表中 Claude / OpenAI 模型的默认价格（手动维护的行不受影响），
+// 下载失败时使用内置价格；返回导入的行数
+func (ps *PricingService) ImportDefaultPricing() (int, error) {
+	source, err := ps.fetchDefaultPricing()
+	if err != nil {
+		fmt.Printf("[WARN] 下载最新价格失败，使用内置价格: %v\n", err)
+		source, err = modelpricing.DefaultService()
+		if err != nil {
+			return 0, err
+		}
+	} else if ps.logService != nil {
+		ps.logService.setPricing(source)
+	}
+
+	existing, err := ps.ListPricing()
+	if err != nil {
+		return 0, err
+	}
+	custom := make(map[string]struct{})
+	defaults := make(map[string]int64)
+	for _, price := range existing {
+		if price.Provider != "" {
+			continue
+		}
+		if price.Source == PricingSourceCustom {
+			custom[price.Model] = struct{}{}
+		} else {
+			defaults[price.Model] = price.ID
+		}
+	}
+
+	model := xdb.New(modelPricingTable)
+	imported := 0
+	for name, entry := range source.Entries() {
+		if !isImportablePricingModel(name) || (entry.InputCostPerToken == 0 && entry.OutputCostPerToken == 0) {
+			continue
+		}
+		if _, ok := custom[name]; ok {
+			continue
+		}
+		price := ModelPrice{
+			Model:              name,
+			InputPerMTok:       entry.InputCostPerToken * 1e6,
+			OutputPerMTok:      entry.OutputCostPerToken * 1e6,
+			CacheCreatePerMTok: entry.CacheCreationInputTokenCost * 1e6,
+			CacheReadPerMTok:   entry.CacheReadInputTokenCost * 1e6,
+			Currency:           "USD",
+			Source:             PricingSourceDefault,
+		}
+		if id, ok := defaults[name]; ok {
+			_, err = model.Update(price.record(), xdb.WhereEq("id", id))
+		} else {
+			_, err = model.Insert(price.record())
+		}
+		if err != nil {
+			return imported, err
+		}
+		imported++
+	}
+	invalidatePricingTable()
+	return imported, nil
+}
+
+// fetchDefaultPricing 下载最新价格文件并保存到本地，下次启动时优先使用
+func (ps *PricingService) fetchDefaultPricing() (*modelpricing.Service, error) {
+	resp, err := ps.httpClient.Get(defaultPricingURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 32*1024*1024))
+	if err != nil {
+		return nil, err
+	}
+	svc, err := modelpricing.NewServiceFromJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	path, err := defaultPricingPath()
+	if err != nil {
+		return svc, nil
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err == nil {
+		_ = os.Rename(tmp, path)
+	}
+	return svc, nil
+}
+
+// isImportablePricingModel 只导入 Claude / OpenAI 的直连模型名，跳过云厂商前缀的变体
+func isImportablePricingModel(name string) bool {
+	if strings.Contains(name, "/") {
+		return false
+	}
+	lower := strings.ToLower(name)
+	for _, prefix := range []string{"claude-", "gpt-", "o1", "o3", "o4", "codex-"} {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func defaultPricingPath() (string, error) {
+	dir, err := ensureDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, defaultPricingFile), nil
+}
+
+// loadLocalPricing 优先使用导入过的最新价格文件，不存在时使用内置价格
+func loadLocalPricing() (*modelpricing.Service, error) {
+	if path, err := defaultPricingPath(); err == nil {
+		if data, err := os.ReadFile(path); err == nil {
+			if svc, err := modelpricing.NewServiceFromJSON(data); err == nil {
+				return svc, nil
+			}
+		}
+	}
+	return modelpricing.DefaultService()
+}
+
+// lookupModelPrice 先找 provider 专属价格，再找通用价格
+func lookupModelPrice(provider string, model string) (ModelPrice, bool) {
+	if model == "" {
+		return ModelPrice{}, false
+	}
+	ensurePricingTableLoaded()
+	pricingTable.RLock()
+	defer pricingTable.RUnlock()
+	if provider != "" {
+		if price, ok := pricingTable.entries[pricingKey(provider, model)]; ok {
+			return price, true
+		}
+	}
+	price, ok := pricingTable.entries[pricingKey("", model)]
+	return price, ok
+}
+
+// cost 按价格表计算费用，非美元单价先按汇率换算为美元
+func (p ModelPrice) cost(usage modelpricing.UsageSnapshot) modelpricing.CostBreakdown {
+	perToken := func(perMTok float64) float64 {
+		return toUSD(perMTok, p.Currency) / 1e6
+	}
+	breakdown := modelpricing.CostBreakdown{HasPricing: true}
+	breakdown.InputCost = float64(usage.InputTokens) * perToken(p.InputPerMTok)
+	breakdown.OutputCost = float64(usage.OutputTokens) * perToken(p.OutputPerMTok)
+	cacheCreateRate, cacheReadRate := p.CacheCreatePerMTok, p.CacheReadPerMTok
+	if p.CacheBilling == CacheBillingInput {
+		cacheCreateRate, cacheReadRate = p.InputPerMTok, p.InputPerMTok
+	}
+	breakdown.CacheCreateCost = float64(usage.CacheCreateTokens) * perToken(cacheCreateRate)
+	breakdown.Ephemeral5mCost = breakdown.CacheCreateCost
+	breakdown.CacheReadCost = float64(usage.CacheReadTokens) * perToken(cacheReadRate)
+	breakdown.TotalCost = breakdown.InputCost + breakdown.OutputCost + breakdown.CacheCreateCost + breakdown.CacheReadCost
+	return breakdown
+}
+
+func (p ModelPrice) record() xdb.Record {
+	return xdb.Record{
+		"model":                 p.Model,
+		"provider":              p.Provider,
+		"input_per_mtok":        p.InputPerMTok,
+		"output_per_mtok":       p.OutputPerMTok,
+		"cache_create_per_mtok": p.CacheCreatePerMTok,
+		"cache_read_per_mtok":   p.CacheReadPerMTok,
+		"currency":              p.Currency,
+		"cache_billing":         p.CacheBilling,
+		"source":                p.Source,
+		"updated_at":            time.Now().UTC().Format(timeLayout),
+	}
+}
+
+func modelPriceFromRecord(record xdb.Record) ModelPrice {
+	return ModelPrice{
+		ID:                 record.GetInt64("id"),
+		Model:              record.GetString("model"),
+		Provider:           record.GetString("provider"),
+		InputPerMTok:       record.GetFloat64("input_per_mtok"),
+		OutputPerMTok:      record.GetFloat64("output_per_mtok"),
+		CacheCreatePerMTok: record.GetFloat64("cache_create_per_mtok"),
+		CacheReadPerMTok:   record.GetFloat64("cache_read_per_mtok"),
+		Currency:           record.GetString("currency"),
+		CacheBilling:       record.GetString("cache_billing"),
+		Source:             record.GetString("source"),
+		UpdatedAt:          record.GetString("updated_at"),
+	}
+}
+
+func pricingKey(provider string, model string) string {
+	return strings.ToLower(provider) + "|" + strings.ToLower(model)
+}
+
+func ensurePricingTableLoaded() {
+	pricingTable.RLock()
+	loaded := pricingTable.loaded
+	pricingTable.RUnlock()
+	if loaded {
+		return
+	}
+	entries := make(map[string]ModelPrice)
+	records, err := xdb.New(modelPricingTable).Selects()
+	// 读取失败时按空表处理并标记已加载，避免每次计算费用都重试
+	if err != nil && !errors.Is(err, xdb.ErrNotFound) && !isNoSuchTableErr(err) {
+		fmt.Printf("读取价格表失败: %v\n", err)
+	}
+	for _, record := range records {
+		price := modelPriceFromRecord(record)
+		entries[pricingKey(price.Provider, price.Model)] = price
+	}
+	pricingTable.Lock()
+	pricingTable.entries = entries
+	pricingTable.loaded = true
+	pricingTable.Unlock()
+}
+
+func invalidatePricingTable() {
+	pricingTable.Lock()
+	pricingTable.loaded = false
+	pricingTable.Unlock()
+}
+
+func ensureModelPricingTable() error {
+	db, err := xdb.DB("default")
+	if err != nil {
+		return err
+	}
+	return ensureModelPricingTableWithDB(db)
+}
+
+func ensureModelPricingTableWithDB(db *sql.DB) error {
+	const createTableSQL = `CREATE TABLE IF NOT EXISTS model_pricing (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		model TEXT NOT NULL,
+		provider TEXT NOT NULL DEFAULT '',
+		input_per_mtok REAL DEFAULT 0,
+		output_per_mtok REAL DEFAULT 0,
+		cache_create_per_mtok REAL DEFAULT 0,
+		cache_read_per_mtok REAL DEFAULT 0,
+		currency TEXT DEFAULT 'USD',
+		source TEXT DEFAULT 'custom',
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return err
+	}
+	if err := ensureRequestLogColumn(db, modelPricingTable, "cache_billing", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+	_, err := db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_model_pricing_key ON model_pricing (model, provider)`)
+	return err
+}
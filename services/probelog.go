@@ -0,0 +1,176 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	modelpricing "codeswitch/resources/model-pricing"
+
+	"github.com/daodao97/xgo/xdb"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	// probeLogTable 健康检查、连通性与能力探测的用量单独记录，不计入真实请求统计
+	probeLogTable = "probe_log"
+
+	relayProbeKey = "relay_probe"
+)
+
+// ProbeUsageStat 某一天的探测用量与费用
+type ProbeUsageStat struct {
+	Day          string  `json:"day"`
+	Provider     string  `json:"provider"`
+	Requests     int64   `json:"requests"`
+	InputTokens  int64   `json:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	TotalCost    float64 `json:"total_cost"`
+}
+
+// recordProbeUsage 写入一次探测的用量
+func recordProbeUsage(entry *ReqeustLog) {
+	if _, err := xdb.New(probeLogTable).Insert(xdb.Record{
+		"request_id":          entry.RequestID,
+		"platform":            entry.Platform,
+		"model":               entry.Model,
+		"requested_model":     entry.RequestedModel,
+		"provider":            entry.Provider,
+		"http_code":           entry.HttpCode,
+		"input_tokens":        entry.InputTokens,
+		"output_tokens":       entry.OutputTokens,
+		"cache_create_tokens": entry.CacheCreateTokens,
+		"cache_read_tokens":   entry.CacheReadTokens,
+		"reasoning_tokens":    entry.ReasoningTokens,
+		"is_stream":           boolToInt(entry.IsStream),
+		"duration_sec":        entry.DurationSec,
+		"first_token_sec":     entry.FirstTokenSec,
+		"error_message":       entry.ErrorMessage,
+	}); err != nil {
+		fmt.Printf("写入 probe_log 失败: %v\n", err)
+	}
+}
+
+// parseProbeUsage 从探测响应中解析 token 用量，兼容流式与非流式响应
+func parseProbeUsage(platform string, body []byte, entry *ReqeustLog) {
+	if entry.IsStream {
+		parser := ClaudeCodeParseTokenUsageFromResponse
+		if platform == "codex" {
+			parser = CodexParseTokenUsageFromResponse
+		}
+		parseEventPayload(string(body), parser, entry)
+		return
+	}
+	usage := gjson.GetBytes(body, "usage")
+	entry.InputTokens += int(usage.Get("input_tokens").Int())
+	entry.OutputTokens += int(usage.Get("output_tokens").Int())
+	entry.CacheCreateTokens += int(usage.Get("cache_creation_input_tokens").Int())
+	entry.CacheReadTokens += int(usage.Get("cache_read_input_tokens").Int())
+	entry.CacheReadTokens += int(usage.Get("input_tokens_details.cached_tokens").Int())
+	entry.ReasoningTokens += int(usage.Get("output_tokens_details.reasoning_tokens").Int())
+}
+
+// ProbeStats 按天、按 provider 汇总最近 days 天的探测用量与费用
+func (ls *LogService) ProbeStats(platform string, days int) ([]ProbeUsageStat, error) {
+	if days <= 0 {
+		days = 7
+	}
+	since := startOfDay(time.Now()).AddDate(0, 0, -(days - 1))
+	records, err := ls.probeRecordsSince(platform, since)
+	if err != nil {
+		return nil, err
+	}
+	buckets := make(map[string]*ProbeUsageStat)
+	for _, record := range records {
+		createdAt, ok := parseCreatedAt(record)
+		if !ok || createdAt.Before(since) {
+			continue
+		}
+		day := createdAt.Format("2006-01-02")
+		provider := record.GetString("provider")
+		key := day + "|" + provider
+		bucket, ok := buckets[key]
+		if !ok {
+			bucket = &ProbeUsageStat{Day: day, Provider: provider}
+			buckets[key] = bucket
+		}
+		bucket.Requests++
+		bucket.InputTokens += int64(record.GetInt("input_tokens"))
+		bucket.OutputTokens += int64(record.GetInt("output_tokens"))
+		bucket.TotalCost += ls.recordCost(record)
+	}
+	stats := make([]ProbeUsageStat, 0, len(buckets))
+	for _, bucket := range buckets {
+		stats = append(stats, *bucket)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Day != stats[j].Day {
+			return stats[i].Day > stats[j].Day
+		}
+		return strings.ToLower(stats[i].Provider) < strings.ToLower(stats[j].Provider)
+	})
+	return stats, nil
+}
+
+// ProbeCostToday 返回今天探测已花费的金额（美元）
+func (ls *LogService) ProbeCostToday() (float64, error) {
+	since := startOfDay(time.Now())
+	records, err := ls.probeRecordsSince("", since)
+	if err != nil {
+		return 0, err
+	}
+	total := 0.0
+	for _, record := range records {
+		if createdAt, ok := parseCreatedAt(record); ok && createdAt.Before(since) {
+			continue
+		}
+		total += ls.recordCost(record)
+	}
+	return total, nil
+}
+
+func (ls *LogService) probeRecordsSince(platform string, since time.Time) ([]xdb.Record, error) {
+	// created_at 以 UTC 存储，多查一天再按本地时间过滤
+	options := []xdb.Option{
+		xdb.WhereGte("created_at", since.Add(-24*time.Hour).UTC().Format(timeLayout)),
+		xdb.Field(
+			"provider",
+			"model",
+			"input_tokens",
+			"output_tokens",
+			"cache_create_tokens",
+			"cache_read_tokens",
+			"created_at",
+		),
+	}
+	if platform != "" {
+		options = append(options, xdb.WhereEq("platform", normalizePresetKind(platform)))
+	}
+	records, err := xdb.New(probeLogTable).Selects(options...)
+	if err != nil {
+		if errors.Is(err, xdb.ErrNotFound) || isNoSuchTableErr(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return records, nil
+}
+
+func (ls *LogService) recordCost(record xdb.Record) float64 {
+	return ls.calculateCost(record.GetString("provider"), record.GetString("model"), modelpricing.UsageSnapshot{
+		InputTokens:       record.GetInt("input_tokens"),
+		OutputTokens:      record.GetInt("output_tokens"),
+		CacheCreateTokens: record.GetInt("cache_create_tokens"),
+		CacheReadTokens:   record.GetInt("cache_read_tokens"),
+	}).TotalCost
+}
+
+func ensureProbeLogTable() error {
+	db, err := xdb.DB("default")
+	if err != nil {
+		return err
+	}
+	return ensureLogTableSchema(db, probeLogTable)
+}
@@ -0,0 +1,419 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daodao97/xgo/xdb"
+)
+
+const (
+	defaultProfileID = "default"
+	profilesFile     = "profiles.json"
+	profilesDir      = "profiles"
+)
+
+// profileIDPattern profile ID 会出现在目录名与日志表名中，只允许小写字母、数字与下划线
+var profileIDPattern = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// Profile 一套独立的 provider、预算与路由计划（如 work / personal）
+type Profile struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ProfileState 全部 profile 与当前激活的 profile
+type ProfileState struct {
+	Active   string    `json:"active"`
+	Profiles []Profile `json:"profiles"`
+}
+
+// activeProfile 当前 profile，provider 文件、路由计划、预算与 request_log 都按它取用
+var activeProfile = struct {
+	sync.RWMutex
+	loaded bool
+	id     string
+}{}
+
+func currentProfileID() string {
+	activeProfile.RLock()
+	if activeProfile.loaded {
+		id := activeProfile.id
+		activeProfile.RUnlock()
+		return id
+	}
+	activeProfile.RUnlock()
+
+	activeProfile.Lock()
+	defer activeProfile.Unlock()
+	if !activeProfile.loaded {
+		activeProfile.id = defaultProfileID
+		if state, err := loadProfileState(); err == nil && state.Active != "" {
+			activeProfile.id = state.Active
+		}
+		activeProfile.loaded = true
+	}
+	return activeProfile.id
+}
+
+func setCurrentProfileID(id string) {
+	activeProfile.Lock()
+	defer activeProfile.Unlock()
+	activeProfile.id = id
+	activeProfile.loaded = true
+}
+
+// profileDir 当前 profile 的数据目录；默认 profile 直接使用数据目录，兼容已有文件
+func profileDir() (string, error) {
+	return ensureProfileDir(currentProfileID())
+}
+
+func ensureProfileDir(id string) (string, error) {
+	dir, err := ensureDataDir()
+	if err != nil || id == defaultProfileID {
+		return dir, err
+	}
+	dir = filepath.Join(dir, profilesDir, id)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// profileRequestLogTable 每个 profile 的请求日志单独一张表，统计天然按 profile 隔离
+func profileRequestLogTable(id string) string {
+	if id == defaultProfileID {
+		return "request_log"
+	}
+	return "profile_" + id + "_request_log"
+}
+
+// activeRequestLogTable relay 写入的日志表，不受演示模式影响
+func activeRequestLogTable() string {
+	return profileRequestLogTable(currentProfileID())
+}
+
+func profileStatePath() string {
+	return filepath.Join(dataDir(), profilesFile)
+}
+
+func loadProfileState() (ProfileState, error) {
+	state := ProfileState{Active: defaultProfileID}
+	data, err := os.ReadFile(profileStatePath())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return state, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &state); err != nil {
+			return state, err
+		}
+	}
+	return normalizeProfileState(state), nil
+}
+
+func normalizeProfileState(state ProfileState) ProfileState {
+	profiles := []Profile{{ID: defaultProfileID, Name: "Default"}}
+	seen := map[string]bool{defaultProfileID: true}
+	for _, profile := range state.Profiles {
+		if profile.ID == defaultProfileID {
+			if name := strings.TrimSpace(profile.Name); name != "" {
+				profiles[0].Name = name
+			}
+			continue
+		}
+		if !profileIDPattern.MatchString(profile.ID) || seen[profile.ID] {
+			continue
+		}
+		seen[profile.ID] = true
+		profiles = append(profiles, profile)
+	}
+	state.Profiles = profiles
+	if !seen[state.Active] {
+		state.Active = defaultProfileID
+	}
+	return state
+}
+
+func saveProfileState(state ProfileState) error {
+	dir, err := ensureDataDir()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, profilesFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// profileID 由名称生成 ID，非 ASCII 名称使用时间戳
+func profileID(name string, existing map[string]bool) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == ' ' || r == '-' || r == '_':
+			if !strings.HasSuffix(b.String(), "_") {
+				b.WriteRune('_')
+			}
+		}
+	}
+	id := strings.Trim(b.String(), "_")
+	if len(id) > 24 {
+		id = id[:24]
+	}
+	if id == "" {
+		id = fmt.Sprintf("p%d", time.Now().Unix())
+	}
+	base := id
+	for i := 2; existing[id]; i++ {
+		id = fmt.Sprintf("%s_%d", base, i)
+	}
+	return id
+}
+
+type ProfileService struct {
+	providerService *ProviderService
+	claudeSettings  *ClaudeSettingsService
+	codexSettings   *CodexSettingsService
+	mu              sync.Mutex
+	switchHandler   func(Profile)
+}
+
+func NewProfileService(providerService *ProviderService, claudeSettings *ClaudeSettingsService, codexSettings *CodexSettingsService) *ProfileService {
+	return &ProfileService{
+		providerService: providerService,
+		claudeSettings:  claudeSettings,
+		codexSettings:   codexSettings,
+	}
+}
+
+// SetSwitchHandler 设置切换 profile 后的回调（由 main 重新加载预算并通知前端）
+func (ps *ProfileService) SetSwitchHandler(handler func(Profile)) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.switchHandler = handler
+}
+
+// ListProfiles 返回全部 profile 与当前激活的 profile
+func (ps *ProfileService) ListProfiles() (ProfileState, error) {
+	state, err := loadProfileState()
+	if err != nil {
+		return state, err
+	}
+	state.Active = currentProfileID()
+	return state, nil
+}
+
+// CreateProfile 新建 profile，copyFrom 不为空时复制该 profile 的 provider 与路由计划
+func (ps *ProfileService) CreateProfile(name, copyFrom string) (Profile, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Profile{}, errors.New("profile 名称不能为空")
+	}
+	state, err := loadProfileState()
+	if err != nil {
+		return Profile{}, err
+	}
+	existing := make(map[string]bool, len(state.Profiles))
+	for _, profile := range state.Profiles {
+		if strings.EqualFold(profile.Name, name) {
+			return Profile{}, fmt.Errorf("profile %s 已存在", name)
+		}
+		existing[profile.ID] = true
+	}
+	if copyFrom != "" && !existing[copyFrom] {
+		return Profile{}, fmt.Errorf("profile %s 不存在", copyFrom)
+	}
+	profile := Profile{ID: profileID(name, existing), Name: name, CreatedAt: time.Now()}
+	dir, err := ensureProfileDir(profile.ID)
+	if err != nil {
+		return Profile{}, err
+	}
+	if copyFrom != "" {
+		source, err := ensureProfileDir(copyFrom)
+		if err != nil {
+			return Profile{}, err
+		}
+		for _, file := range []string{"claude-code.json", "codex.json", routingScheduleFile} {
+			if err := copyPath(filepath.Join(source, file), filepath.Join(dir, file)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return Profile{}, fmt.Errorf("复制 %s 失败: %w", file, err)
+			}
+		}
+	}
+	state.Profiles = append(state.Profiles, profile)
+	if err := saveProfileState(state); err != nil {
+		return Profile{}, err
+	}
+	return profile, nil
+}
+
+// RenameProfile 修改 profile 名称
+func (ps *ProfileService) RenameProfile(id, name string) (Profile, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Profile{}, errors.New("profile 名称不能为空")
+	}
+	state, err := loadProfileState()
+	if err != nil {
+		return Profile{}, err
+	}
+	for i := range state.Profiles {
+		if state.Profiles[i].ID == id {
+			state.Profiles[i].Name = name
+			return state.Profiles[i], saveProfileState(state)
+		}
+	}
+	return Profile{}, fmt.Errorf("profile %s 不存在", id)
+}
+
+// DeleteProfile 删除 profile 及其 provider、路由计划、预算与请求日志；默认与当前 profile 不能删除
+func (ps *ProfileService) DeleteProfile(id string) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if id == defaultProfileID {
+		return errors.New("默认 profile 不能删除")
+	}
+	if id == currentProfileID() {
+		return errors.New("不能删除当前使用中的 profile，请先切换")
+	}
+	state, err := loadProfileState()
+	if err != nil {
+		return err
+	}
+	profiles := state.Profiles[:0]
+	found := false
+	for _, profile := range state.Profiles {
+		if profile.ID == id {
+			found = true
+			continue
+		}
+		profiles = append(profiles, profile)
+	}
+	if !found {
+		return fmt.Errorf("profile %s 不存在", id)
+	}
+	state.Profiles = profiles
+	if err := saveProfileState(state); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(filepath.Join(dataDir(), profilesDir, id)); err != nil {
+		return err
+	}
+	if db, err := xdb.DB("default"); err == nil {
+		// 归档表不再属于任何 profile，日志维护不会再处理，随日志表一并删除
+		archives, _ := logArchiveTables(db, profileRequestLogTable(id))
+		for _, table := range append(archives, profileRequestLogTable(id)) {
+			if _, err := db.Exec("DROP TABLE IF EXISTS " + table); err != nil {
+				fmt.Printf("[WARN] 删除 profile %s 的请求日志失败: %v\n", id, err)
+			}
+		}
+		if _, err := db.Exec("DELETE FROM "+providerHistoryTable+" WHERE profile = ?", id); err != nil && !isNoSuchTableErr(err) {
+			fmt.Printf("[WARN] 删除 profile %s 的 provider 变更历史失败: %v\n", id, err)
+		}
+	}
+	return nil
+}
+
+// SwitchProfile 运行时切换 profile：relay 与统计立即使用新 profile 的 provider 与日志表，
+// 直连写入 CLI 配置的 provider 换成新 profile 中的同名 provider，不存在时移除
+func (ps *ProfileService) SwitchProfile(id string) (ProfileState, error) {
+	ps.mu.Lock()
+	state, err := loadProfileState()
+	if err != nil {
+		ps.mu.Unlock()
+		return state, err
+	}
+	var target *Profile
+	for i := range state.Profiles {
+		if state.Profiles[i].ID == id {
+			target = &state.Profiles[i]
+		}
+	}
+	if target == nil {
+		ps.mu.Unlock()
+		return state, fmt.Errorf("profile %s 不存在", id)
+	}
+	if db, err := xdb.DB("default"); err == nil {
+		if err := ensureLogTableSchema(db, profileRequestLogTable(id)); err != nil {
+			ps.mu.Unlock()
+			return state, fmt.Errorf("初始化 profile 日志表失败: %w", err)
+		}
+	}
+	state.Active = id
+	if err := saveProfileState(state); err != nil {
+		ps.mu.Unlock()
+		return state, err
+	}
+	setCurrentProfileID(id)
+	profile := *target
+	handler := ps.switchHandler
+	ps.mu.Unlock()
+
+	ps.rescopeDirectApply()
+	fmt.Printf("[INFO] 已切换到 profile %s\n", profile.Name)
+	if handler != nil {
+		handler(profile)
+	}
+	return state, nil
+}
+
+// rescopeDirectApply 让直连模式写入 CLI 配置的 provider 跟随当前 profile
+func (ps *ProfileService) rescopeDirectApply() {
+	type directApplier interface {
+		DirectApplyStatus() (DirectApplyStatus, error)
+		ApplySingleProvider(Provider) error
+		RemoveSingleProvider() error
+	}
+	targets := []struct {
+		kind    string
+		applier directApplier
+	}{
+		{"claude", ps.claudeSettings},
+		{"codex", ps.codexSettings},
+	}
+	for _, target := range targets {
+		if target.applier == nil || ps.providerService == nil {
+			continue
+		}
+		status, err := target.applier.DirectApplyStatus()
+		if err != nil || !status.Applied {
+			continue
+		}
+		providers, err := ps.providerService.LoadProviders(target.kind)
+		if err != nil {
+			continue
+		}
+		err = target.applier.RemoveSingleProvider()
+		for _, provider := range providers {
+			if provider.Name == status.Provider {
+				err = target.applier.ApplySingleProvider(provider)
+				break
+			}
+		}
+		if err != nil {
+			fmt.Printf("[WARN] 切换 profile 后更新 %s 直连配置失败: %v\n", target.kind, err)
+		}
+	}
+}
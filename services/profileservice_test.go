@@ -0,0 +1,106 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daodao97/xgo/xdb"
+)
+
+func TestProfileID(t *testing.T) {
+	existing := map[string]bool{"default": true, "work": true}
+	cases := map[string]string{
+		"Personal":      "personal",
+		"Client - Acme": "client_acme",
+		"Work":          "work_2",
+	}
+	for name, want := range cases {
+		if got := profileID(name, existing); got != want {
+			t.Errorf("profileID(%q) = %q, want %q", name, got, want)
+		}
+	}
+	if got := profileID("工作", existing); !profileIDPattern.MatchString(got) {
+		t.Errorf("profileID for non-ASCII name = %q, want a valid ID", got)
+	}
+}
+
+func TestNormalizeProfileState(t *testing.T) {
+	state := normalizeProfileState(ProfileState{
+		Active: "missing",
+		Profiles: []Profile{
+			{ID: "work", Name: "Work"},
+			{ID: "work", Name: "Duplicate"},
+			{ID: "Bad-ID", Name: "Bad"},
+		},
+	})
+	if state.Active != defaultProfileID {
+		t.Fatalf("active = %q, want default", state.Active)
+	}
+	if len(state.Profiles) != 2 || state.Profiles[0].ID != defaultProfileID || state.Profiles[1].ID != "work" {
+		t.Fatalf("profiles = %+v", state.Profiles)
+	}
+	if got := profileRequestLogTable("work"); got != "profile_work_request_log" {
+		t.Fatalf("profileRequestLogTable = %q", got)
+	}
+}
+
+func TestProviderHistoryScopedToProfile(t *testing.T) {
+	useTestDB(t)
+	previous := currentProfileID()
+	t.Cleanup(func() { setCurrentProfileID(previous) })
+	ps := NewProviderService()
+
+	setCurrentProfileID(defaultProfileID)
+	if err := ps.SaveProviders("codex", []Provider{{ID: 1, Name: "home", APIURL: "https://home.example.com", APIKey: "k"}}); err != nil {
+		t.Fatal(err)
+	}
+	defaultHistory, err := ps.History("codex", 10)
+	if err != nil || len(defaultHistory) != 1 {
+		t.Fatalf("default 历史：%+v %v", defaultHistory, err)
+	}
+
+	setCurrentProfileID("work")
+	if err := ps.SaveProviders("codex", []Provider{{ID: 1, Name: "office", APIURL: "https://office.example.com", APIKey: "k"}}); err != nil {
+		t.Fatal(err)
+	}
+	history, err := ps.History("codex", 10)
+	if err != nil || len(history) != 1 || history[0].Changes[0] != "新增 office" {
+		t.Fatalf("work 只应看到自己的历史：%+v %v", history, err)
+	}
+	if err := ps.Rollback("codex", defaultHistory[0].ID); err == nil {
+		t.Fatal("不应回滚其他 profile 的历史记录")
+	}
+	providers, err := ps.LoadProviders("codex")
+	if err != nil || len(providers) != 1 || providers[0].Name != "office" {
+		t.Fatalf("work 的 provider 不应被改动：%+v %v", providers, err)
+	}
+}
+
+func TestArchiveProfileRequestLog(t *testing.T) {
+	useTestDB(t)
+	db, err := xdb.DB("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := profileRequestLogTable("work")
+	if err := ensureLogTableSchema(db, source); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	for _, createdAt := range []time.Time{old, time.Now()} {
+		if _, err := xdb.New(source).Insert(xdb.Record{"platform": "claude", "provider": "relay", "created_at": createdAt.UTC().Format(timeLayout)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	archived, err := archiveRequestLogs(db, source, time.Now().AddDate(0, 0, -30))
+	if err != nil || archived != 1 {
+		t.Fatalf("归档 %d 行：%v", archived, err)
+	}
+	tables, err := logArchiveTables(db, source)
+	if err != nil || len(tables) != 1 || tables[0] != source+"_202403" {
+		t.Fatalf("归档表：%v %v", tables, err)
+	}
+	if defaults, _ := logArchiveTables(db, profileRequestLogTable(defaultProfileID)); len(defaults) != 0 {
+		t.Fatalf("profile 的归档表不应出现在默认日志表下：%v", defaults)
+	}
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// promptDeployTargets 提示词可部署到的 CLI：Claude Code 斜杠命令、Codex 自定义 prompt 与 Gemini CLI 自定义命令
+var promptDeployTargets = []string{platClaudeCode, platCodex, platGemini}
+
+// SetPromptDeploy 修改提示词部署到的 CLI，并立即写入或删除对应的命令文件
+func (ps *PromptService) SetPromptDeploy(id string, targets []string) (Prompt, error) {
+	prompt, err := ps.GetPrompt(id)
+	if err != nil {
+		return Prompt{}, err
+	}
+	prompt.Deploy = targets
+	return ps.SavePrompt(prompt)
+}
+
+func normalizePromptDeploy(targets []string) []string {
+	result := []string{}
+	for _, target := range promptDeployTargets {
+		if containsPlatform(targets, target) {
+			result = append(result, target)
+		}
+	}
+	return result
+}
+
+func promptCommandPath(target, id string) string {
+	home := userHomeDir()
+	switch target {
+	case platClaudeCode:
+		return filepath.Join(home, claudeSettingsDir, "commands", id+".md")
+	case platCodex:
+		return filepath.Join(home, codexDirName, "prompts", id+".md")
+	default:
+		return filepath.Join(home, geminiDirName, "commands", id+".toml")
+	}
+}
+
+// deployPrompt 把提示词写入选中的 CLI，删除取消选中的 CLI 中由 code-switch 写入的文件，
+// 返回当前已部署的文件路径。已存在且不是 code-switch 写入的同名命令不会被覆盖
+func deployPrompt(prompt Prompt, previous map[string]string) (map[string]string, error) {
+	deployed := make(map[string]string)
+	var errs []error
+	for target, path := range previous {
+		if containsPlatform(prompt.Deploy, target) && path == promptCommandPath(target, prompt.ID) {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, fmt.Errorf("删除 %s 失败: %w", path, err))
+			deployed[target] = path
+		}
+	}
+	for _, target := range prompt.Deploy {
+		path := promptCommandPath(target, prompt.ID)
+		if previous[target] != path && fileExists(path) {
+			errs = append(errs, fmt.Errorf("%s 已存在同名命令，未覆盖: %s", target, path))
+			continue
+		}
+		data, err := buildPromptCommand(target, prompt)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			errs = append(errs, fmt.Errorf("写入 %s 失败: %w", path, err))
+			continue
+		}
+		deployed[target] = path
+	}
+	return deployed, errors.Join(errs...)
+}
+
+// removePromptDeployment 删除提示词时清理已部署的命令文件
+func removePromptDeployment(deployed map[string]string) error {
+	var errs []error
+	for _, path := range deployed {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, fmt.Errorf("删除 %s 失败: %w", path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// buildPromptCommand 变量先用默认值填充；没有默认值的变量改为 CLI 的命令参数：
+// Claude Code 与 Codex 按顺序映射为 $1、$2…，Gemini CLI 只有一个 {{args}}
+func buildPromptCommand(target string, prompt Prompt) ([]byte, error) {
+	render := renderPromptTemplate(prompt.Content, prompt.Variables, nil)
+	description := prompt.Description
+	if description == "" {
+		description = prompt.Name
+	}
+	if target == platGemini {
+		content := promptVariablePattern.ReplaceAllString(render.Content, "{{args}}")
+		return toml.Marshal(struct {
+			Description string `toml:"description"`
+			Prompt      string `toml:"prompt,multiline"`
+		}{description, content})
+	}
+
+	content := promptVariablePattern.ReplaceAllStringFunc(render.Content, func(match string) string {
+		name := promptVariablePattern.FindStringSubmatch(match)[1]
+		for i, missing := range render.Missing {
+			if missing == name {
+				return fmt.Sprintf("$%d", i+1)
+			}
+		}
+		return match
+	})
+	var buf bytes.Buffer
+	buf.WriteString("---\n")
+	fmt.Fprintf(&buf, "description: %s\n", yamlScalar(description))
+	if len(render.Missing) > 0 {
+		hints := make([]string, len(render.Missing))
+		for i, name := range render.Missing {
+			hints[i] = "[" + name + "]"
+		}
+		fmt.Fprintf(&buf, "argument-hint: %s\n", yamlScalar(strings.Join(hints, " ")))
+	}
+	buf.WriteString("---\n\n")
+	buf.WriteString(strings.TrimRight(content, "\n"))
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// yamlScalar 单行字符串统一加双引号，避免冒号、方括号等被解析为 YAML 结构
+func yamlScalar(value string) string {
+	value = strings.ReplaceAll(strings.TrimSpace(value), "\n", " ")
+	return fmt.Sprintf("%q", value)
+}
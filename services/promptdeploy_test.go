@@ -0,0 +1,69 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildPromptCommand(t *testing.T) {
+	prompt := Prompt{
+		ID:          "review",
+		Name:        "Review",
+		Description: "Review: a ticket",
+		Content:     "Review {{project|code-switch}} for {{ticket}} by {{owner}}, again {{ticket}}",
+	}
+	prompt.Variables = mergePromptVariables(prompt.Content, nil)
+
+	data, err := buildPromptCommand(platClaudeCode, prompt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "---\ndescription: \"Review: a ticket\"\nargument-hint: \"[ticket] [owner]\"\n---\n\nReview code-switch for $1 by $2, again $1\n"
+	if string(data) != want {
+		t.Fatalf("claude command = %q", data)
+	}
+
+	data, err = buildPromptCommand(platGemini, prompt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `description = 'Review: a ticket'`) && !strings.Contains(string(data), `description = "Review: a ticket"`) {
+		t.Fatalf("gemini command = %s", data)
+	}
+	if !strings.Contains(string(data), "for {{args}} by {{args}}") {
+		t.Fatalf("gemini command = %s", data)
+	}
+}
+
+func TestDeployPrompt(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	prompt := Prompt{ID: "fix", Name: "Fix", Content: "Fix it", Deploy: []string{platClaudeCode, platGemini}}
+
+	// 用户自己的同名命令不会被覆盖
+	userCommand := promptCommandPath(platGemini, "fix")
+	writeSkillFiles(t, filepath.Dir(userCommand), map[string]string{"fix.toml": "prompt = 'mine'"})
+	deployed, err := deployPrompt(prompt, nil)
+	if err == nil || len(deployed) != 1 {
+		t.Fatalf("deployed = %v, err = %v", deployed, err)
+	}
+	if data, _ := os.ReadFile(userCommand); string(data) != "prompt = 'mine'" {
+		t.Fatal("user command was overwritten")
+	}
+	claudePath := deployed[platClaudeCode]
+	if claudePath != filepath.Join(home, ".claude", "commands", "fix.md") || !fileExists(claudePath) {
+		t.Fatalf("claude path = %q", claudePath)
+	}
+
+	prompt.Deploy = []string{platCodex}
+	deployed, err = deployPrompt(prompt, deployed)
+	if err != nil || fileExists(claudePath) || !fileExists(deployed[platCodex]) || len(deployed) != 1 {
+		t.Fatalf("redeploy: %v, %v", deployed, err)
+	}
+	if err := removePromptDeployment(deployed); err != nil || fileExists(deployed[platCodex]) {
+		t.Fatalf("remove: %v", err)
+	}
+}
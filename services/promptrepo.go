@@ -0,0 +1,420 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	promptRepoCacheDirName = "prompt-repos"
+	// promptRepoCheckInterval 定时同步的检查周期，实际间隔由 IntervalHours 决定
+	promptRepoCheckInterval = 10 * time.Minute
+	promptRepoMaxFileSize   = 256 << 10
+)
+
+// PromptRepoConfig 共享提示词仓库，托管平台、令牌与代理的含义与 skill 仓库相同。
+// Directory 为仓库内存放提示词的子目录，IntervalHours 为 0 时只手动同步
+type PromptRepoConfig struct {
+	skillRepoConfig
+	Directory     string `json:"directory,omitempty"`
+	IntervalHours int    `json:"interval_hours"`
+}
+
+// PromptUpstream 仓库中的新内容与本地修改冲突时暂存的远端版本，等待用户选择
+type PromptUpstream struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Content     string `json:"content"`
+	Hash        string `json:"hash"`
+}
+
+// PromptSyncResult 一次仓库同步的结果
+type PromptSyncResult struct {
+	Branch    string    `json:"branch"`
+	Added     []string  `json:"added"`
+	Updated   []string  `json:"updated"`
+	Removed   []string  `json:"removed"`
+	Detached  []string  `json:"detached"`
+	Conflicts []string  `json:"conflicts"`
+	Skipped   []string  `json:"skipped"`
+	Error     string    `json:"error,omitempty"`
+	SyncedAt  time.Time `json:"synced_at"`
+}
+
+// promptRepoEntry 仓库中的一个 Markdown 提示词，front matter 可声明 id、name、description 与 variables
+type promptRepoEntry struct {
+	ID          string           `yaml:"id"`
+	Name        string           `yaml:"name"`
+	Description string           `yaml:"description"`
+	Variables   []PromptVariable `yaml:"variables"`
+	Content     string           `yaml:"-"`
+}
+
+// PromptRepoState 共享提示词仓库配置与上次同步结果，未配置时 Repo.Owner 为空
+type PromptRepoState struct {
+	Repo     PromptRepoConfig  `json:"repo"`
+	LastSync *PromptSyncResult `json:"last_sync"`
+}
+
+// GetPromptRepo 返回共享提示词仓库配置与上次同步结果
+func (ps *PromptService) GetPromptRepo() (PromptRepoState, error) {
+	store, err := loadPromptStore()
+	if err != nil {
+		return PromptRepoState{}, err
+	}
+	state := PromptRepoState{LastSync: store.LastSync}
+	if store.Repo != nil {
+		state.Repo = *store.Repo
+	}
+	return state, nil
+}
+
+// SetPromptRepo 保存共享提示词仓库配置，Owner 与 Name 都为空时取消配置；
+// 已同步的提示词保留在本地，不再跟随仓库更新
+func (ps *PromptService) SetPromptRepo(repo PromptRepoConfig) (PromptRepoConfig, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	store, err := loadPromptStore()
+	if err != nil {
+		return PromptRepoConfig{}, err
+	}
+	if strings.TrimSpace(repo.Owner) == "" && strings.TrimSpace(repo.Name) == "" {
+		store.Repo = nil
+		store.LastSync = nil
+		return PromptRepoConfig{}, savePromptStore(store)
+	}
+	repo.skillRepoConfig = normalizeRepoConfig(repo.skillRepoConfig)
+	if err := validateRepoConfig(repo.skillRepoConfig); err != nil {
+		return PromptRepoConfig{}, err
+	}
+	repo.Directory = strings.Trim(filepath.ToSlash(strings.TrimSpace(repo.Directory)), "/")
+	if repo.Directory != "" && !filepath.IsLocal(filepath.FromSlash(repo.Directory)) {
+		return PromptRepoConfig{}, fmt.Errorf("目录无效: %s", repo.Directory)
+	}
+	if repo.IntervalHours < 0 {
+		repo.IntervalHours = 0
+	}
+	if store.Repo != nil && !equalRepo(store.Repo.skillRepoConfig, repo.skillRepoConfig) {
+		store.LastSync = nil
+	}
+	store.Repo = &repo
+	if err := savePromptStore(store); err != nil {
+		return PromptRepoConfig{}, err
+	}
+	return repo, nil
+}
+
+// SyncPromptRepo 从共享仓库拉取提示词：未在本地修改过的直接更新，本地修改过且远端也有变化的
+// 标记为冲突；仓库中已删除的提示词，未修改过的随之删除，修改过的转为本地提示词
+func (ps *PromptService) SyncPromptRepo() (PromptSyncResult, error) {
+	ps.syncMu.Lock()
+	defer ps.syncMu.Unlock()
+	store, err := loadPromptStore()
+	if err != nil {
+		return PromptSyncResult{}, err
+	}
+	if store.Repo == nil {
+		return PromptSyncResult{}, errors.New("尚未配置共享提示词仓库")
+	}
+	repo := *store.Repo
+	result := PromptSyncResult{
+		Added: []string{}, Updated: []string{}, Removed: []string{},
+		Detached: []string{}, Conflicts: []string{}, Skipped: []string{},
+		SyncedAt: time.Now(),
+	}
+
+	repoDir, branch, cleanup, err := ps.fetcher.prepareRepoSnapshot(repo.skillRepoConfig)
+	if err == nil {
+		defer cleanup()
+		var entries []promptRepoEntry
+		entries, err = scanPromptRepo(filepath.Join(repoDir, filepath.FromSlash(repo.Directory)))
+		if err == nil {
+			result.Branch = branch
+			ps.applyPromptRepo(store, promptRepoSource(repo), entries, &result)
+		}
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	ps.saveLastSync(result)
+	if ps.syncHandler != nil {
+		ps.syncHandler(result)
+	}
+	return result, err
+}
+
+// ResolvePromptConflict 处理同步冲突：useRemote 为 true 时用仓库版本覆盖本地修改，
+// 否则保留本地内容，之后仓库再次变化时才会重新提示
+func (ps *PromptService) ResolvePromptConflict(id string, useRemote bool) (Prompt, error) {
+	prompt, err := ps.GetPrompt(id)
+	if err != nil {
+		return Prompt{}, err
+	}
+	if prompt.Upstream == nil {
+		return prompt, nil
+	}
+	upstream := *prompt.Upstream
+	if useRemote {
+		prompt.Name = upstream.Name
+		prompt.Description = upstream.Description
+		prompt.Content = upstream.Content
+	}
+	prompt.SourceHash = upstream.Hash
+	prompt.Upstream = nil
+	return ps.SavePrompt(prompt)
+}
+
+// applyPromptRepo 逐条保存变化，保存走 SavePrompt，同样会记录历史版本并更新已部署的命令
+func (ps *PromptService) applyPromptRepo(store promptStore, source string, entries []promptRepoEntry, result *PromptSyncResult) {
+	existing := make(map[string]Prompt, len(store.Prompts))
+	for _, prompt := range store.Prompts {
+		existing[prompt.ID] = prompt
+	}
+	save := func(prompt Prompt, list *[]string) {
+		saved, err := ps.SavePrompt(prompt)
+		if err != nil {
+			fmt.Printf("[WARN] 同步提示词 %s: %v\n", prompt.ID, err)
+		}
+		// 命令文件部署失败时提示词本身已保存
+		if saved.ID == "" {
+			result.Skipped = append(result.Skipped, prompt.ID)
+			return
+		}
+		*list = append(*list, prompt.ID)
+	}
+
+	seen := make(map[string]struct{}, len(entries))
+	for _, entry := range entries {
+		seen[entry.ID] = struct{}{}
+		hash := promptContentHash(entry.Name, entry.Description, entry.Content)
+		local, ok := existing[entry.ID]
+		switch {
+		case !ok:
+			save(Prompt{
+				ID:          entry.ID,
+				Name:        entry.Name,
+				Description: entry.Description,
+				Content:     entry.Content,
+				Variables:   entry.Variables,
+				Deploy:      []string{},
+				Source:      source,
+				SourceHash:  hash,
+			}, &result.Added)
+		case local.Source != source:
+			result.Skipped = append(result.Skipped, entry.ID)
+		case hash == local.SourceHash:
+			if local.Upstream != nil {
+				// 远端回到了上次同步的内容，冲突自然消除
+				local.Upstream = nil
+				save(local, &result.Updated)
+			}
+		case !local.editedSinceSync():
+			local.Name = entry.Name
+			local.Description = entry.Description
+			local.Content = entry.Content
+			local.Variables = mergePromptVariables(entry.Content, append(entry.Variables, local.Variables...))
+			local.SourceHash = hash
+			local.Upstream = nil
+			save(local, &result.Updated)
+		case local.Upstream == nil || local.Upstream.Hash != hash:
+			local.Upstream = &PromptUpstream{Name: entry.Name, Description: entry.Description, Content: entry.Content, Hash: hash}
+			save(local, &result.Conflicts)
+		default:
+			result.Conflicts = append(result.Conflicts, entry.ID)
+		}
+	}
+
+	for _, prompt := range store.Prompts {
+		if prompt.Source != source {
+			continue
+		}
+		if _, ok := seen[prompt.ID]; ok {
+			continue
+		}
+		if !prompt.editedSinceSync() {
+			if err := ps.DeletePrompt(prompt.ID); err != nil {
+				fmt.Printf("[WARN] 删除已移出仓库的提示词 %s: %v\n", prompt.ID, err)
+			}
+			result.Removed = append(result.Removed, prompt.ID)
+			continue
+		}
+		prompt.Source = ""
+		prompt.SourceHash = ""
+		prompt.Upstream = nil
+		save(prompt, &result.Detached)
+	}
+}
+
+func (prompt Prompt) editedSinceSync() bool {
+	return promptContentHash(prompt.Name, prompt.Description, prompt.Content) != prompt.SourceHash
+}
+
+func (ps *PromptService) saveLastSync(result PromptSyncResult) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	store, err := loadPromptStore()
+	if err != nil {
+		return
+	}
+	store.LastSync = &result
+	if err := savePromptStore(store); err != nil {
+		fmt.Printf("[WARN] 保存提示词同步结果失败: %v\n", err)
+	}
+}
+
+// scanPromptRepo 读取目录下的全部 .md 文件（README 除外），ID 优先取 front matter，其次取文件名
+func scanPromptRepo(root string) ([]promptRepoEntry, error) {
+	info, err := os.Stat(root)
+	if err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("仓库中未找到提示词目录: %s", filepath.Base(root))
+	}
+	byID := make(map[string]promptRepoEntry)
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && skipSkillScanDir(d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		name := d.Name()
+		if !strings.EqualFold(filepath.Ext(name), ".md") || strings.EqualFold(name, "README.md") {
+			return nil
+		}
+		if info, err := d.Info(); err != nil || info.Size() > promptRepoMaxFileSize {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		entry, err := parsePromptRepoEntry(strings.TrimSuffix(name, filepath.Ext(name)), string(data))
+		if err != nil {
+			rel, _ := filepath.Rel(root, path)
+			fmt.Printf("[WARN] 跳过提示词文件 %s: %v\n", filepath.ToSlash(rel), err)
+			return nil
+		}
+		byID[entry.ID] = entry
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]promptRepoEntry, 0, len(byID))
+	for _, entry := range byID {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries, nil
+}
+
+func parsePromptRepoEntry(fileName, data string) (promptRepoEntry, error) {
+	var entry promptRepoEntry
+	body := strings.TrimLeft(data, "\ufeff")
+	if strings.HasPrefix(body, "---") {
+		parts := strings.SplitN(body, "---", 3)
+		if len(parts) == 3 {
+			if err := yaml.Unmarshal([]byte(parts[1]), &entry); err != nil {
+				return entry, fmt.Errorf("front matter 格式错误: %w", err)
+			}
+			body = parts[2]
+		}
+	}
+	entry.Content = strings.TrimSpace(body)
+	if entry.Content == "" {
+		return entry, errors.New("内容为空")
+	}
+	entry.ID = strings.TrimSpace(entry.ID)
+	if entry.ID == "" {
+		entry.ID = slugifyPromptID(fileName)
+	}
+	if !promptIDPattern.MatchString(entry.ID) {
+		return entry, fmt.Errorf("ID 无效: %q", entry.ID)
+	}
+	entry.Name = strings.TrimSpace(entry.Name)
+	if entry.Name == "" {
+		entry.Name = fileName
+	}
+	entry.Description = strings.TrimSpace(entry.Description)
+	entry.Variables = mergePromptVariables(entry.Content, entry.Variables)
+	return entry, nil
+}
+
+func promptContentHash(name, description, content string) string {
+	sum := sha256.Sum256([]byte(name + "\x00" + description + "\x00" + content))
+	return hex.EncodeToString(sum[:])
+}
+
+func promptRepoSource(repo PromptRepoConfig) string {
+	return strings.ToLower(repo.Owner + "/" + repo.Name)
+}
+
+func newPromptRepoFetcher() *SkillService {
+	// 复用 skill 仓库的下载与快照缓存，缓存放在单独的目录
+	return &SkillService{
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+		cacheDir:   filepath.Join(dataDir(), promptRepoCacheDirName),
+	}
+}
+
+// SetPromptSyncHandler 注册定时同步完成后的回调
+func (ps *PromptService) SetPromptSyncHandler(handler func(PromptSyncResult)) {
+	ps.syncHandler = handler
+}
+
+// Start 按配置的间隔定时同步共享提示词仓库
+func (ps *PromptService) Start() error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if ps.syncStopCh != nil {
+		return nil
+	}
+	stopCh := make(chan struct{})
+	ps.syncStopCh = stopCh
+	go func() {
+		ticker := time.NewTicker(promptRepoCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if ps.promptRepoDue() {
+					_, _ = ps.SyncPromptRepo()
+				}
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (ps *PromptService) Stop() error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if ps.syncStopCh != nil {
+		close(ps.syncStopCh)
+		ps.syncStopCh = nil
+	}
+	return nil
+}
+
+func (ps *PromptService) promptRepoDue() bool {
+	state, err := ps.GetPromptRepo()
+	if err != nil || state.Repo.Owner == "" || state.Repo.IntervalHours <= 0 {
+		return false
+	}
+	return state.LastSync == nil || time.Since(state.LastSync.SyncedAt) >= time.Duration(state.Repo.IntervalHours)*time.Hour
+}
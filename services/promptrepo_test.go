@@ -0,0 +1,52 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestScanPromptRepo(t *testing.T) {
+	root := t.TempDir()
+	writeSkillFiles(t, root, map[string]string{
+		"README.md":             "# Shared prompts",
+		"review.md":             "---\nname: Code Review\ndescription: Review a diff\nvariables:\n  - name: focus\n    default: bugs\n---\n\nReview for {{focus}} in {{file}}\n",
+		"team/Release Notes.md": "Summarize {{version}}",
+		"team/broken.md":        "---\nid: Not Valid\n---\nbody",
+		"node_modules/skip.md":  "ignored",
+		"notes.txt":             "ignored",
+	})
+
+	entries, err := scanPromptRepo(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("entries = %+v", entries)
+	}
+	release, review := entries[0], entries[1]
+	if release.ID != "release-notes" || release.Name != "Release Notes" || release.Content != "Summarize {{version}}" {
+		t.Fatalf("release = %+v", release)
+	}
+	if review.ID != "review" || review.Name != "Code Review" || review.Description != "Review a diff" {
+		t.Fatalf("review = %+v", review)
+	}
+	if len(review.Variables) != 2 || review.Variables[0].Default != "bugs" || review.Variables[1].Name != "file" {
+		t.Fatalf("variables = %+v", review.Variables)
+	}
+
+	if _, err := scanPromptRepo(filepath.Join(root, "missing")); err == nil {
+		t.Fatal("expected error for missing directory")
+	}
+}
+
+func TestPromptEditedSinceSync(t *testing.T) {
+	prompt := Prompt{Name: "a", Content: "b"}
+	prompt.SourceHash = promptContentHash(prompt.Name, prompt.Description, prompt.Content)
+	if prompt.editedSinceSync() {
+		t.Fatal("unchanged prompt reported as edited")
+	}
+	prompt.Content = "c"
+	if !prompt.editedSinceSync() {
+		t.Fatal("edited prompt not detected")
+	}
+}
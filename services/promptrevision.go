@@ -0,0 +1,268 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/daodao97/xgo/xdb"
+)
+
+const (
+	promptRevisionTable = "prompt_revision"
+	// promptRevisionKeep 每条提示词保留的修订数
+	promptRevisionKeep = 100
+)
+
+// PromptRevision 提示词的一个历史版本，保存时内容有变化才会记录
+type PromptRevision struct {
+	ID          int64            `json:"id"`
+	PromptID    string           `json:"prompt_id"`
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Content     string           `json:"content"`
+	Variables   []PromptVariable `json:"variables"`
+	CreatedAt   time.Time        `json:"created_at"`
+}
+
+// PromptDiffLine 逐行差异，Type 为 equal、add 或 remove
+type PromptDiffLine struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// PromptDiff 两个版本之间的差异
+type PromptDiff struct {
+	Lines   []PromptDiffLine `json:"lines"`
+	Added   int              `json:"added"`
+	Removed int              `json:"removed"`
+}
+
+// ListPromptRevisions 按时间倒序返回提示词的历史版本
+func (ps *PromptService) ListPromptRevisions(id string, limit int) ([]PromptRevision, error) {
+	if limit <= 0 || limit > promptRevisionKeep {
+		limit = promptRevisionKeep
+	}
+	records, err := xdb.New(promptRevisionTable).Selects(
+		xdb.WhereEq("prompt_id", id),
+		xdb.OrderByDesc("id"),
+		xdb.Limit(limit),
+	)
+	if err != nil {
+		if errors.Is(err, xdb.ErrNotFound) || isNoSuchTableErr(err) {
+			return []PromptRevision{}, nil
+		}
+		return nil, err
+	}
+	revisions := make([]PromptRevision, 0, len(records))
+	for _, record := range records {
+		revisions = append(revisions, newPromptRevision(record))
+	}
+	return revisions, nil
+}
+
+// DiffPromptRevisions 比较两个版本的内容，toRevision 为 0 时与当前内容比较
+func (ps *PromptService) DiffPromptRevisions(id string, fromRevision, toRevision int64) (PromptDiff, error) {
+	from, err := loadPromptRevision(id, fromRevision)
+	if err != nil {
+		return PromptDiff{}, err
+	}
+	var to string
+	if toRevision == 0 {
+		current, err := ps.GetPrompt(id)
+		if err != nil {
+			return PromptDiff{}, err
+		}
+		to = current.Content
+	} else {
+		revision, err := loadPromptRevision(id, toRevision)
+		if err != nil {
+			return PromptDiff{}, err
+		}
+		to = revision.Content
+	}
+	return diffPromptLines(from.Content, to), nil
+}
+
+// RevertPrompt 恢复到指定版本的名称、说明、内容与变量，部署设置保持不变；恢复本身会记录为新版本
+func (ps *PromptService) RevertPrompt(id string, revisionID int64) (Prompt, error) {
+	revision, err := loadPromptRevision(id, revisionID)
+	if err != nil {
+		return Prompt{}, err
+	}
+	prompt, err := ps.GetPrompt(id)
+	if err != nil {
+		return Prompt{}, err
+	}
+	prompt.Name = revision.Name
+	prompt.Description = revision.Description
+	prompt.Content = revision.Content
+	prompt.Variables = revision.Variables
+	return ps.SavePrompt(prompt)
+}
+
+func loadPromptRevision(id string, revisionID int64) (PromptRevision, error) {
+	record, err := xdb.New(promptRevisionTable).First(
+		xdb.WhereEq("id", revisionID),
+		xdb.WhereEq("prompt_id", id),
+	)
+	if err != nil {
+		if errors.Is(err, xdb.ErrNotFound) {
+			return PromptRevision{}, fmt.Errorf("未找到历史版本 %d", revisionID)
+		}
+		return PromptRevision{}, err
+	}
+	return newPromptRevision(record), nil
+}
+
+func newPromptRevision(record xdb.Record) PromptRevision {
+	createdAt, _ := parseCreatedAt(record)
+	variables := []PromptVariable{}
+	if raw := record.GetString("variables"); raw != "" {
+		_ = json.Unmarshal([]byte(raw), &variables)
+	}
+	return PromptRevision{
+		ID:          record.GetInt64("id"),
+		PromptID:    record.GetString("prompt_id"),
+		Name:        record.GetString("name"),
+		Description: record.GetString("description"),
+		Content:     record.GetString("content"),
+		Variables:   variables,
+		CreatedAt:   createdAt,
+	}
+}
+
+// promptRevisionChanged 只比较会被记录的字段，仅修改部署设置不产生新版本
+func promptRevisionChanged(before, after Prompt) bool {
+	return before.Name != after.Name ||
+		before.Description != after.Description ||
+		before.Content != after.Content ||
+		!jsonEqual(before.Variables, after.Variables)
+}
+
+// recordPromptRevision 记录保存后的版本。功能上线前已存在的提示词第一次修改时，先补记修改前的版本
+func recordPromptRevision(before *Prompt, after Prompt) {
+	if before != nil {
+		if count, err := countPromptRevisions(after.ID); err == nil && count == 0 {
+			insertPromptRevision(*before)
+		}
+	}
+	insertPromptRevision(after)
+	prunePromptRevisions(after.ID)
+}
+
+func insertPromptRevision(prompt Prompt) {
+	variables, err := json.Marshal(prompt.Variables)
+	if err != nil {
+		return
+	}
+	if _, err := xdb.New(promptRevisionTable).Insert(xdb.Record{
+		"prompt_id":   prompt.ID,
+		"name":        prompt.Name,
+		"description": prompt.Description,
+		"content":     prompt.Content,
+		"variables":   string(variables),
+		"created_at":  prompt.UpdatedAt.UTC().Format(timeLayout),
+	}); err != nil {
+		fmt.Printf("[WARN] 记录提示词版本失败: %v\n", err)
+	}
+}
+
+func countPromptRevisions(id string) (int, error) {
+	db, err := xdb.DB("default")
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	err = db.QueryRow(`SELECT COUNT(*) FROM prompt_revision WHERE prompt_id = ?`, id).Scan(&count)
+	return count, err
+}
+
+func prunePromptRevisions(id string) {
+	db, err := xdb.DB("default")
+	if err != nil {
+		return
+	}
+	if _, err := db.Exec(`DELETE FROM prompt_revision WHERE prompt_id = ? AND id NOT IN (
+		SELECT id FROM prompt_revision WHERE prompt_id = ? ORDER BY id DESC LIMIT ?
+	)`, id, id, promptRevisionKeep); err != nil {
+		fmt.Printf("[WARN] 清理提示词版本失败: %v\n", err)
+	}
+}
+
+// deletePromptRevisions 删除提示词时一并删除历史，避免同名 ID 复用时串到旧版本
+func deletePromptRevisions(id string) {
+	db, err := xdb.DB("default")
+	if err != nil {
+		return
+	}
+	if _, err := db.Exec(`DELETE FROM prompt_revision WHERE prompt_id = ?`, id); err != nil && !isNoSuchTableErr(err) {
+		fmt.Printf("[WARN] 删除提示词版本失败: %v\n", err)
+	}
+}
+
+// diffPromptLines 基于最长公共子序列的逐行差异，删除行排在新增行之前
+func diffPromptLines(before, after string) PromptDiff {
+	a := strings.Split(before, "\n")
+	b := strings.Split(after, "\n")
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	diff := PromptDiff{Lines: []PromptDiffLine{}}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			diff.Lines = append(diff.Lines, PromptDiffLine{Type: "equal", Text: a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			diff.Lines = append(diff.Lines, PromptDiffLine{Type: "remove", Text: a[i]})
+			diff.Removed++
+			i++
+		default:
+			diff.Lines = append(diff.Lines, PromptDiffLine{Type: "add", Text: b[j]})
+			diff.Added++
+			j++
+		}
+	}
+	return diff
+}
+
+func ensurePromptRevisionTable() error {
+	db, err := xdb.DB("default")
+	if err != nil {
+		return err
+	}
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS prompt_revision (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			prompt_id TEXT,
+			name TEXT,
+			description TEXT,
+			content TEXT,
+			variables TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_prompt_revision_prompt ON prompt_revision (prompt_id, id)`,
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const promptsFile = "prompts.json"
+
+// promptIDPattern prompt ID 会作为斜杠命令的文件名，只允许小写字母、数字、中划线与下划线
+var promptIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// Prompt 提示词库中的一条提示词，Content 中可以使用 {{variable}} 占位符
+type Prompt struct {
+	ID          string           `json:"id"`
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Content     string           `json:"content"`
+	Variables   []PromptVariable `json:"variables"`
+	// Deploy 选中的 CLI，Deployed 为实际写入的命令文件（CLI → 路径），编辑后会重新写入
+	Deploy   []string          `json:"deploy"`
+	Deployed map[string]string `json:"deployed,omitempty"`
+	// Source 来自共享仓库时为 owner/name，SourceHash 为上次同步时的内容哈希，
+	// Upstream 为与本地修改冲突、等待处理的仓库版本
+	Source     string          `json:"source,omitempty"`
+	SourceHash string          `json:"source_hash,omitempty"`
+	Upstream   *PromptUpstream `json:"upstream,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+}
+
+type promptStore struct {
+	Prompts  []Prompt          `json:"prompts"`
+	Repo     *PromptRepoConfig `json:"repo,omitempty"`
+	LastSync *PromptSyncResult `json:"last_sync,omitempty"`
+}
+
+// PromptService 管理提示词库，数据保存在数据目录下的 prompts.json
+type PromptService struct {
+	mu sync.Mutex
+	// syncMu 保证同一时间只有一次仓库同步
+	syncMu      sync.Mutex
+	syncStopCh  chan struct{}
+	syncHandler func(PromptSyncResult)
+	fetcher     *SkillService
+}
+
+func NewPromptService() *PromptService {
+	return &PromptService{fetcher: newPromptRepoFetcher()}
+}
+
+// ListPrompts 按名称排序返回全部提示词
+func (ps *PromptService) ListPrompts() ([]Prompt, error) {
+	store, err := loadPromptStore()
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(store.Prompts, func(i, j int) bool {
+		return strings.ToLower(store.Prompts[i].Name) < strings.ToLower(store.Prompts[j].Name)
+	})
+	return store.Prompts, nil
+}
+
+// GetPrompt 按 ID 返回提示词
+func (ps *PromptService) GetPrompt(id string) (Prompt, error) {
+	store, err := loadPromptStore()
+	if err != nil {
+		return Prompt{}, err
+	}
+	if i := store.index(id); i >= 0 {
+		return store.Prompts[i], nil
+	}
+	return Prompt{}, fmt.Errorf("提示词不存在: %s", id)
+}
+
+// SavePrompt 新建或更新提示词并记录历史版本；ID 为空时由名称生成。变量列表按内容中的占位符重新整理，
+// 已声明的默认值与说明会保留；已部署到 CLI 的命令文件同步更新
+func (ps *PromptService) SavePrompt(prompt Prompt) (Prompt, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	prompt.Name = strings.TrimSpace(prompt.Name)
+	prompt.Description = strings.TrimSpace(prompt.Description)
+	if prompt.Name == "" {
+		return Prompt{}, errors.New("名称不能为空")
+	}
+	if strings.TrimSpace(prompt.Content) == "" {
+		return Prompt{}, errors.New("内容不能为空")
+	}
+	store, err := loadPromptStore()
+	if err != nil {
+		return Prompt{}, err
+	}
+	prompt.ID = strings.TrimSpace(prompt.ID)
+	if prompt.ID == "" {
+		prompt.ID = store.uniqueID(slugifyPromptID(prompt.Name))
+	}
+	if !promptIDPattern.MatchString(prompt.ID) {
+		return Prompt{}, fmt.Errorf("ID 只能包含小写字母、数字、中划线与下划线: %q", prompt.ID)
+	}
+	prompt.Variables = mergePromptVariables(prompt.Content, prompt.Variables)
+	prompt.Deploy = normalizePromptDeploy(prompt.Deploy)
+	now := time.Now()
+	prompt.UpdatedAt = now
+	i := store.index(prompt.ID)
+	var previous map[string]string
+	var before *Prompt
+	changed := true
+	if i >= 0 {
+		existing := store.Prompts[i]
+		prompt.CreatedAt = existing.CreatedAt
+		previous = existing.Deployed
+		before = &existing
+		changed = promptRevisionChanged(existing, prompt)
+	} else {
+		prompt.CreatedAt = now
+	}
+	deployed, deployErr := deployPrompt(prompt, previous)
+	prompt.Deployed = deployed
+	if i >= 0 {
+		store.Prompts[i] = prompt
+	} else {
+		store.Prompts = append(store.Prompts, prompt)
+	}
+	if err := savePromptStore(store); err != nil {
+		return Prompt{}, err
+	}
+	if changed {
+		recordPromptRevision(before, prompt)
+	}
+	return prompt, deployErr
+}
+
+// DeletePrompt 删除提示词
+func (ps *PromptService) DeletePrompt(id string) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	store, err := loadPromptStore()
+	if err != nil {
+		return err
+	}
+	i := store.index(id)
+	if i < 0 {
+		return nil
+	}
+	deployed := store.Prompts[i].Deployed
+	store.Prompts = append(store.Prompts[:i], store.Prompts[i+1:]...)
+	if err := savePromptStore(store); err != nil {
+		return err
+	}
+	deletePromptRevisions(id)
+	return removePromptDeployment(deployed)
+}
+
+func (store promptStore) index(id string) int {
+	for i, prompt := range store.Prompts {
+		if prompt.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (store promptStore) uniqueID(base string) string {
+	id := base
+	for n := 2; store.index(id) >= 0; n++ {
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+	return id
+}
+
+// slugifyPromptID 由名称生成 ID，名称中没有可用字符（如纯中文）时使用时间戳
+func slugifyPromptID(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+			dash = false
+		case b.Len() > 0 && !dash:
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	slug := strings.TrimRight(b.String(), "-")
+	if len(slug) > 48 {
+		slug = strings.TrimRight(slug[:48], "-")
+	}
+	if slug == "" {
+		slug = "prompt-" + time.Now().Format("20060102150405")
+	}
+	return slug
+}
+
+func promptStorePath() (string, error) {
+	dir, err := ensureDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, promptsFile), nil
+}
+
+func loadPromptStore() (promptStore, error) {
+	store := promptStore{Prompts: []Prompt{}}
+	path, err := promptStorePath()
+	if err != nil {
+		return store, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return store, nil
+		}
+		return store, err
+	}
+	if len(data) == 0 {
+		return store, nil
+	}
+	if err := json.Unmarshal(data, &store); err != nil {
+		return store, err
+	}
+	if store.Prompts == nil {
+		store.Prompts = []Prompt{}
+	}
+	for i := range store.Prompts {
+		if store.Prompts[i].Variables == nil {
+			store.Prompts[i].Variables = []PromptVariable{}
+		}
+		if store.Prompts[i].Deploy == nil {
+			store.Prompts[i].Deploy = []string{}
+		}
+	}
+	return store, nil
+}
+
+func savePromptStore(store promptStore) error {
+	path, err := promptStorePath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(store, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	defer invalidatePromptMatchers()
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+)
+
+// promptVariablePattern 匹配 {{name}} 与带内联默认值的 {{name|默认值}}
+var promptVariablePattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*(?:\|([^}]*))?\}\}`)
+
+// PromptVariable 提示词中的一个变量，Default 为未填写时使用的值
+type PromptVariable struct {
+	Name        string `json:"name"`
+	Default     string `json:"default,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// PromptRender 渲染结果，Missing 为没有值也没有默认值、原样保留在内容中的变量
+type PromptRender struct {
+	Content string   `json:"content"`
+	Missing []string `json:"missing"`
+}
+
+// RenderPrompt 用 values 填充提示词库中的提示词
+func (ps *PromptService) RenderPrompt(id string, values map[string]string) (PromptRender, error) {
+	prompt, err := ps.GetPrompt(id)
+	if err != nil {
+		return PromptRender{}, err
+	}
+	return renderPromptTemplate(prompt.Content, prompt.Variables, values), nil
+}
+
+// RenderPromptTemplate 渲染尚未保存的内容，用于编辑时预览
+func (ps *PromptService) RenderPromptTemplate(content string, variables []PromptVariable, values map[string]string) PromptRender {
+	return renderPromptTemplate(content, variables, values)
+}
+
+// ParsePromptVariables 按出现顺序返回内容中的变量，内联默认值填入 Default
+func (ps *PromptService) ParsePromptVariables(content string) []PromptVariable {
+	return mergePromptVariables(content, nil)
+}
+
+// renderPromptTemplate 取值优先级：填写的值 > 声明的默认值 > 内联默认值
+func renderPromptTemplate(content string, variables []PromptVariable, values map[string]string) PromptRender {
+	defaults := make(map[string]string, len(variables))
+	for _, variable := range variables {
+		if variable.Default != "" {
+			defaults[variable.Name] = variable.Default
+		}
+	}
+	missing := []string{}
+	rendered := promptVariablePattern.ReplaceAllStringFunc(content, func(match string) string {
+		parts := promptVariablePattern.FindStringSubmatch(match)
+		name, inline := parts[1], strings.TrimSpace(parts[2])
+		if value := values[name]; value != "" {
+			return value
+		}
+		if value, ok := defaults[name]; ok {
+			return value
+		}
+		if inline != "" {
+			return inline
+		}
+		if !containsPlatform(missing, name) {
+			missing = append(missing, name)
+		}
+		return match
+	})
+	return PromptRender{Content: rendered, Missing: missing}
+}
+
+// mergePromptVariables 以内容中出现的变量为准，保留已声明变量的默认值与说明
+func mergePromptVariables(content string, declared []PromptVariable) []PromptVariable {
+	existing := make(map[string]PromptVariable, len(declared))
+	for _, variable := range declared {
+		existing[strings.TrimSpace(variable.Name)] = variable
+	}
+	variables := []PromptVariable{}
+	seen := make(map[string]struct{})
+	for _, parts := range promptVariablePattern.FindAllStringSubmatch(content, -1) {
+		name := parts[1]
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		variable, ok := existing[name]
+		if !ok {
+			variable = PromptVariable{Default: strings.TrimSpace(parts[2])}
+		}
+		variable.Name = name
+		variable.Default = strings.TrimSpace(variable.Default)
+		variable.Description = strings.TrimSpace(variable.Description)
+		variables = append(variables, variable)
+	}
+	return variables
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRenderPromptTemplate(t *testing.T) {
+	content := "Review {{ project }} in {{language|Go}}.\nTicket: {{ticket}} / {{ticket}} by {{owner}}"
+	variables := mergePromptVariables(content, []PromptVariable{
+		{Name: "project", Default: "code-switch", Description: "仓库名"},
+		{Name: "removed", Default: "x"},
+	})
+	want := []PromptVariable{
+		{Name: "project", Default: "code-switch", Description: "仓库名"},
+		{Name: "language", Default: "Go"},
+		{Name: "ticket"},
+		{Name: "owner"},
+	}
+	if !reflect.DeepEqual(variables, want) {
+		t.Fatalf("variables = %+v", variables)
+	}
+
+	render := renderPromptTemplate(content, variables, map[string]string{"ticket": "CS-42", "language": ""})
+	if render.Content != "Review code-switch in Go.\nTicket: CS-42 / CS-42 by {{owner}}" {
+		t.Fatalf("content = %q", render.Content)
+	}
+	if !reflect.DeepEqual(render.Missing, []string{"owner"}) {
+		t.Fatalf("missing = %v", render.Missing)
+	}
+	if render = renderPromptTemplate(content, nil, map[string]string{"project": "x", "language": "Rust", "ticket": "1", "owner": "me"}); len(render.Missing) != 0 || render.Content != "Review x in Rust.\nTicket: 1 / 1 by me" {
+		t.Fatalf("render = %+v", render)
+	}
+}
+
+func TestSlugifyPromptID(t *testing.T) {
+	cases := map[string]string{
+		"Code Review!":   "code-review",
+		"  Fix -- bugs ": "fix-bugs",
+		"PR 描述 v2":       "pr-v2",
+	}
+	for name, want := range cases {
+		if got := slugifyPromptID(name); got != want {
+			t.Errorf("slugifyPromptID(%q) = %q, want %q", name, got, want)
+		}
+	}
+	if got := slugifyPromptID("提示词"); !promptIDPattern.MatchString(got) {
+		t.Errorf("fallback id %q is invalid", got)
+	}
+}
+
+func TestDiffPromptLines(t *testing.T) {
+	diff := diffPromptLines("a\nb\nc", "a\nc\nd")
+	var got []string
+	for _, line := range diff.Lines {
+		got = append(got, line.Type+":"+line.Text)
+	}
+	want := "equal:a remove:b equal:c add:d"
+	if strings.Join(got, " ") != want || diff.Added != 1 || diff.Removed != 1 {
+		t.Fatalf("diff = %v", got)
+	}
+}
@@ -0,0 +1,289 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daodao97/xgo/xdb"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	promptUsageTable = "prompt_usage"
+	// promptFingerprintMinLen 用于匹配的固定文本过短时容易误判，只依赖命令名匹配
+	promptFingerprintMinLen = 20
+)
+
+// promptArgumentPattern 内容中原本就写了的 CLI 参数占位符
+var promptArgumentPattern = regexp.MustCompile(`\$[0-9]+|\$ARGUMENTS|\{\{args\}\}`)
+
+// PromptUsageStat 某条提示词在统计周期内的使用次数与花费，Tokens 与花费取自展开该命令的那一次请求
+type PromptUsageStat struct {
+	PromptID          string    `json:"prompt_id"`
+	Name              string    `json:"name"`
+	Deployed          bool      `json:"deployed"`
+	Invocations       int       `json:"invocations"`
+	InputTokens       int       `json:"input_tokens"`
+	OutputTokens      int       `json:"output_tokens"`
+	CacheCreateTokens int       `json:"cache_create_tokens"`
+	CacheReadTokens   int       `json:"cache_read_tokens"`
+	TotalCost         float64   `json:"total_cost"`
+	LastUsed          time.Time `json:"last_used,omitempty"`
+}
+
+// promptMatcher 已部署提示词的识别规则：Claude Code 会在消息中带上 <command-name>/id</command-name>，
+// 其余情况按展开后内容中最长的一段固定文本匹配
+type promptMatcher struct {
+	id          string
+	commandTag  string
+	fingerprint string
+}
+
+var (
+	promptMatchersMu    sync.Mutex
+	promptMatchers      []promptMatcher
+	promptMatchersReady bool
+)
+
+// invalidatePromptMatchers 提示词库保存后重新生成识别规则
+func invalidatePromptMatchers() {
+	promptMatchersMu.Lock()
+	promptMatchersReady = false
+	promptMatchersMu.Unlock()
+}
+
+func currentPromptMatchers() []promptMatcher {
+	promptMatchersMu.Lock()
+	defer promptMatchersMu.Unlock()
+	if !promptMatchersReady {
+		store, err := loadPromptStore()
+		if err != nil {
+			return nil
+		}
+		promptMatchers = buildPromptMatchers(store.Prompts)
+		promptMatchersReady = true
+	}
+	return promptMatchers
+}
+
+func buildPromptMatchers(prompts []Prompt) []promptMatcher {
+	matchers := make([]promptMatcher, 0, len(prompts))
+	for _, prompt := range prompts {
+		if len(prompt.Deployed) == 0 {
+			continue
+		}
+		matcher := promptMatcher{id: prompt.ID, commandTag: "<command-name>/" + prompt.ID + "</command-name>"}
+		// 参数位置在不同 CLI 中写法不同，统一去掉后取最长的固定片段
+		rendered := renderPromptTemplate(prompt.Content, prompt.Variables, nil).Content
+		rendered = promptVariablePattern.ReplaceAllLiteralString(rendered, "\x00")
+		rendered = promptArgumentPattern.ReplaceAllLiteralString(rendered, "\x00")
+		for _, segment := range strings.Split(rendered, "\x00") {
+			segment = strings.TrimSpace(segment)
+			if len([]rune(segment)) > len([]rune(matcher.fingerprint)) {
+				matcher.fingerprint = segment
+			}
+		}
+		if len([]rune(matcher.fingerprint)) < promptFingerprintMinLen {
+			matcher.fingerprint = ""
+		}
+		matchers = append(matchers, matcher)
+	}
+	// 固定文本更长的优先，避免一条提示词的内容包含另一条时重复计数
+	sort.SliceStable(matchers, func(i, j int) bool {
+		return len(matchers[i].fingerprint) > len(matchers[j].fingerprint)
+	})
+	return matchers
+}
+
+// detectPromptInvocations 在最后一条 user 消息中查找展开的命令。工具调用结果同样以 user 消息发送，
+// 只看纯文本消息，这样每次命令只在用户发出它的那个请求里计一次
+func detectPromptInvocations(kind string, body []byte) []string {
+	matchers := currentPromptMatchers()
+	if len(matchers) == 0 {
+		return nil
+	}
+	text := lastUserText(kind, body)
+	if text == "" {
+		return nil
+	}
+	var ids []string
+	for _, matcher := range matchers {
+		if strings.Contains(text, matcher.commandTag) ||
+			(matcher.fingerprint != "" && strings.Contains(text, matcher.fingerprint)) {
+			ids = append(ids, matcher.id)
+			if matcher.fingerprint != "" {
+				text = strings.Replace(text, matcher.fingerprint, "", 1)
+			}
+		}
+	}
+	return ids
+}
+
+// lastUserText 返回最后一条 user 消息的文本：Claude 的 messages，Codex 的 responses input
+func lastUserText(kind string, body []byte) string {
+	var last gjson.Result
+	switch normalizePresetKind(kind) {
+	case "claude":
+		messages := gjson.GetBytes(body, "messages").Array()
+		if len(messages) == 0 {
+			return ""
+		}
+		last = messages[len(messages)-1]
+	case "codex":
+		input := gjson.GetBytes(body, "input")
+		if input.Type == gjson.String {
+			return input.String()
+		}
+		items := input.Array()
+		if len(items) == 0 {
+			return ""
+		}
+		last = items[len(items)-1]
+	default:
+		return ""
+	}
+	if last.Get("role").String() != "user" {
+		return ""
+	}
+	content := last.Get("content")
+	if content.Type == gjson.String {
+		return content.String()
+	}
+	var parts []string
+	for _, block := range content.Array() {
+		switch block.Get("type").String() {
+		case "text", "input_text":
+			parts = append(parts, block.Get("text").String())
+		case "tool_result":
+			return ""
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// recordPromptUsage 为请求中展开的每条提示词写入一条记录
+func recordPromptUsage(logID int64, entry *ReqeustLog, prompts []string) {
+	for _, id := range prompts {
+		if _, err := xdb.New(promptUsageTable).Insert(xdb.Record{
+			"log_id":              logID,
+			"request_id":          entry.RequestID,
+			"platform":            entry.Platform,
+			"prompt_id":           id,
+			"provider":            entry.Provider,
+			"model":               entry.Model,
+			"input_tokens":        entry.InputTokens,
+			"output_tokens":       entry.OutputTokens,
+			"cache_create_tokens": entry.CacheCreateTokens,
+			"cache_read_tokens":   entry.CacheReadTokens,
+		}); err != nil {
+			fmt.Printf("写入 prompt_usage 失败: %v\n", err)
+		}
+	}
+}
+
+// GetPromptUsageStats 统计最近 days 天各提示词的使用次数与花费，按使用次数降序；
+// 已部署但从未使用的提示词以 0 次列在最后。Gemini CLI 不经过中转，使用情况无法统计
+func (ls *LogService) GetPromptUsageStats(days int) ([]PromptUsageStat, error) {
+	if days <= 0 {
+		days = 30
+	}
+	since := startOfDay(time.Now()).AddDate(0, 0, -(days - 1))
+	records, err := xdb.New(promptUsageTable).Selects(
+		xdb.WhereGte("created_at", since.UTC().Format(timeLayout)),
+		xdb.Field(
+			"prompt_id",
+			"provider",
+			"model",
+			"input_tokens",
+			"output_tokens",
+			"cache_create_tokens",
+			"cache_read_tokens",
+			"created_at",
+		),
+	)
+	if err != nil && !errors.Is(err, xdb.ErrNotFound) && !isNoSuchTableErr(err) {
+		return nil, err
+	}
+	store, err := loadPromptStore()
+	if err != nil {
+		return nil, err
+	}
+
+	statMap := make(map[string]*PromptUsageStat)
+	for _, record := range records {
+		id := record.GetString("prompt_id")
+		stat, ok := statMap[id]
+		if !ok {
+			stat = &PromptUsageStat{PromptID: id, Name: id}
+			statMap[id] = stat
+		}
+		stat.Invocations++
+		stat.InputTokens += record.GetInt("input_tokens")
+		stat.OutputTokens += record.GetInt("output_tokens")
+		stat.CacheCreateTokens += record.GetInt("cache_create_tokens")
+		stat.CacheReadTokens += record.GetInt("cache_read_tokens")
+		stat.TotalCost += ls.recordCost(record)
+		if createdAt, ok := parseCreatedAt(record); ok && createdAt.After(stat.LastUsed) {
+			stat.LastUsed = createdAt
+		}
+	}
+	for _, prompt := range store.Prompts {
+		stat, ok := statMap[prompt.ID]
+		if !ok {
+			if len(prompt.Deployed) == 0 {
+				continue
+			}
+			stat = &PromptUsageStat{PromptID: prompt.ID}
+			statMap[prompt.ID] = stat
+		}
+		stat.Name = prompt.Name
+		stat.Deployed = len(prompt.Deployed) > 0
+	}
+
+	stats := make([]PromptUsageStat, 0, len(statMap))
+	for _, stat := range statMap {
+		stats = append(stats, *stat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Invocations != stats[j].Invocations {
+			return stats[i].Invocations > stats[j].Invocations
+		}
+		return stats[i].PromptID < stats[j].PromptID
+	})
+	return stats, nil
+}
+
+func ensurePromptUsageTable() error {
+	db, err := xdb.DB("default")
+	if err != nil {
+		return err
+	}
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS prompt_usage (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			log_id INTEGER,
+			request_id TEXT,
+			platform TEXT,
+			prompt_id TEXT,
+			provider TEXT,
+			model TEXT,
+			input_tokens INTEGER DEFAULT 0,
+			output_tokens INTEGER DEFAULT 0,
+			cache_create_tokens INTEGER DEFAULT 0,
+			cache_read_tokens INTEGER DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_prompt_usage_created_at ON prompt_usage (created_at)`,
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
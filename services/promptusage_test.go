@@ -0,0 +1,41 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetectPromptInvocations(t *testing.T) {
+	prompts := []Prompt{
+		{ID: "review", Content: "Review the staged changes carefully and list bugs for {{ticket}}", Deployed: map[string]string{platClaudeCode: "x"}},
+		{ID: "short", Content: "Fix it", Deployed: map[string]string{platCodex: "x"}},
+		{ID: "draft", Content: "Review the staged changes carefully and list bugs", Deployed: nil},
+	}
+	for i := range prompts {
+		prompts[i].Variables = mergePromptVariables(prompts[i].Content, nil)
+	}
+	promptMatchersMu.Lock()
+	promptMatchers = buildPromptMatchers(prompts)
+	promptMatchersReady = true
+	promptMatchersMu.Unlock()
+	defer invalidatePromptMatchers()
+
+	cases := []struct {
+		name string
+		kind string
+		body string
+		want []string
+	}{
+		{"claude expanded content", "claude", `{"messages":[{"role":"user","content":[{"type":"text","text":"<command-message>review is running</command-message>"},{"type":"text","text":"Review the staged changes carefully and list bugs for ABC-1"}]}]}`, []string{"review"}},
+		{"claude command tag", "claude", `{"messages":[{"role":"user","content":"<command-name>/short</command-name>"}]}`, []string{"short"}},
+		{"tool result turn", "claude", `{"messages":[{"role":"user","content":[{"type":"tool_result","content":"Review the staged changes carefully and list bugs for ABC-1"}]}]}`, nil},
+		{"codex responses input", "codex", `{"input":[{"type":"message","role":"user","content":[{"type":"input_text","text":"Review the staged changes carefully and list bugs for X"}]}]}`, []string{"review"}},
+		{"short content not fingerprinted", "codex", `{"input":[{"role":"user","content":"Fix it"}]}`, nil},
+		{"assistant last", "claude", `{"messages":[{"role":"assistant","content":"Review the staged changes carefully and list bugs for"}]}`, nil},
+	}
+	for _, tc := range cases {
+		if got := detectPromptInvocations(tc.kind, []byte(tc.body)); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const archivedProvidersFile = "archived-providers.json"
+
+// ArchivedProvider 已归档的 provider：不参与路由、不出现在列表中，但名称保留，历史日志与统计仍可归属
+type ArchivedProvider struct {
+	Provider
+	ArchivedAt time.Time `json:"archivedAt"`
+}
+
+// ArchiveProviders 归档选中的 provider，返回归档数量
+func (ps *ProviderService) ArchiveProviders(kind string, ids []int) (int, error) {
+	platform := normalizePresetKind(kind)
+	if platform == "" {
+		return 0, fmt.Errorf("unknown provider type: %s", kind)
+	}
+	if len(ids) == 0 {
+		return 0, errors.New("未选择 provider")
+	}
+	selected := idSet(ids)
+	providers, err := ps.LoadProviders(platform)
+	if err != nil {
+		return 0, err
+	}
+	archive, err := loadArchivedProviders()
+	if err != nil {
+		return 0, err
+	}
+	kept := make([]Provider, 0, len(providers))
+	now := time.Now()
+	archived := 0
+	for _, p := range providers {
+		if _, ok := selected[p.ID]; !ok {
+			kept = append(kept, p)
+			continue
+		}
+		p.Enabled = false
+		archive[platform] = append(archive[platform], ArchivedProvider{Provider: p, ArchivedAt: now})
+		archived++
+	}
+	if archived == 0 {
+		return 0, nil
+	}
+	// 先写归档再更新列表，保证任何一步失败都不会丢失 provider
+	if err := saveArchivedProviders(archive); err != nil {
+		return 0, err
+	}
+	if err := ps.saveProviders(platform, kept, "archive"); err != nil {
+		return 0, err
+	}
+	return archived, nil
+}
+
+// ListArchivedProviders 返回已归档的 provider
+func (ps *ProviderService) ListArchivedProviders(kind string) ([]ArchivedProvider, error) {
+	platform := normalizePresetKind(kind)
+	if platform == "" {
+		return nil, fmt.Errorf("unknown provider type: %s", kind)
+	}
+	archive, err := loadArchivedProviders()
+	if err != nil {
+		return nil, err
+	}
+	if archive[platform] == nil {
+		return []ArchivedProvider{}, nil
+	}
+	return archive[platform], nil
+}
+
+// RestoreProvider 恢复已归档的 provider（恢复后默认禁用），ID 冲突时重新分配
+func (ps *ProviderService) RestoreProvider(kind string, id int) (Provider, error) {
+	platform := normalizePresetKind(kind)
+	if platform == "" {
+		return Provider{}, fmt.Errorf("unknown provider type: %s", kind)
+	}
+	archive, err := loadArchivedProviders()
+	if err != nil {
+		return Provider{}, err
+	}
+	index := -1
+	for i, item := range archive[platform] {
+		if item.ID == id {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return Provider{}, fmt.Errorf("未找到已归档的 provider id %d", id)
+	}
+	restored := archive[platform][index].Provider
+	providers, err := ps.LoadProviders(platform)
+	if err != nil {
+		return Provider{}, err
+	}
+	for _, p := range providers {
+		if p.ID == restored.ID {
+			restored.ID = nextProviderID(providers)
+			break
+		}
+	}
+
+	remaining := append([]ArchivedProvider{}, archive[platform][:index]...)
+	archive[platform] = append(remaining, archive[platform][index+1:]...)
+	if err := saveArchivedProviders(archive); err != nil {
+		return Provider{}, err
+	}
+	if err := ps.saveProviders(platform, append(providers, restored), "restore"); err != nil {
+		// 回写归档，避免 provider 丢失
+		archive[platform] = append(archive[platform], ArchivedProvider{Provider: restored, ArchivedAt: time.Now()})
+		_ = saveArchivedProviders(archive)
+		return Provider{}, err
+	}
+	return restored, nil
+}
+
+// archivedNameSet 返回已归档 provider 的名称（小写），新 provider 不能与之重名，否则历史统计会混在一起
+func archivedNameSet(kind string) map[string]struct{} {
+	names := make(map[string]struct{})
+	archive, err := loadArchivedProviders()
+	if err != nil {
+		return names
+	}
+	for _, item := range archive[normalizePresetKind(kind)] {
+		names[strings.ToLower(item.Name)] = struct{}{}
+	}
+	return names
+}
+
+func archivedProvidersPath() (string, error) {
+	dir, err := profileDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, archivedProvidersFile), nil
+}
+
+func loadArchivedProviders() (map[string][]ArchivedProvider, error) {
+	archive := make(map[string][]ArchivedProvider)
+	path, err := archivedProvidersPath()
+	if err != nil {
+		return archive, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return archive, nil
+		}
+		return archive, err
+	}
+	if len(data) == 0 {
+		return archive, nil
+	}
+	if err := json.Unmarshal(data, &archive); err != nil {
+		return archive, err
+	}
+	return archive, nil
+}
+
+func saveArchivedProviders(archive map[string][]ArchivedProvider) error {
+	path, err := archivedProvidersPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(archive, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
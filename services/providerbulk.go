@@ -0,0 +1,130 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+)
+
+// CloneProvider 复制指定 provider，新副本默认禁用，名称自动追加 " copy"
+func (ps *ProviderService) CloneProvider(kind string, id int) (Provider, error) {
+	providers, err := ps.LoadProviders(kind)
+	if err != nil {
+		return Provider{}, err
+	}
+	var source *Provider
+	for i := range providers {
+		if providers[i].ID == id {
+			source = &providers[i]
+			break
+		}
+	}
+	if source == nil {
+		return Provider{}, fmt.Errorf("未找到 provider id %d", id)
+	}
+
+	clone := *source
+	clone.ID = nextProviderID(providers)
+	clone.Name = uniqueProviderName(providers, source.Name+" copy", archivedNameSet(kind))
+	clone.Enabled = false
+	clone.SupportedModels = cloneBoolMap(source.SupportedModels)
+	clone.ModelMapping = cloneStringMap(source.ModelMapping)
+	clone.Env = cloneStringMap(source.Env)
+	if source.Balance != nil {
+		balance := *source.Balance
+		balance.Headers = cloneStringMap(source.Balance.Headers)
+		clone.Balance = &balance
+	}
+	clone.configErrors = nil
+
+	if err := ps.SaveProviders(kind, append(providers, clone)); err != nil {
+		return Provider{}, err
+	}
+	return clone, nil
+}
+
+// BulkSetEnabled 批量启用/禁用 provider，返回实际变更的数量
+func (ps *ProviderService) BulkSetEnabled(kind string, ids []int, enabled bool) (int, error) {
+	return ps.bulkUpdate(kind, ids, func(p *Provider) bool {
+		if p.Enabled == enabled {
+			return false
+		}
+		p.Enabled = enabled
+		return true
+	})
+}
+
+// BulkSetLevel 批量修改优先级分组（1-10）
+func (ps *ProviderService) BulkSetLevel(kind string, ids []int, level int) (int, error) {
+	if level < 1 || level > 10 {
+		return 0, fmt.Errorf("优先级必须在 1-10 之间: %d", level)
+	}
+	return ps.bulkUpdate(kind, ids, func(p *Provider) bool {
+		if p.Level == level {
+			return false
+		}
+		p.Level = level
+		return true
+	})
+}
+
+// BulkDelete 批量删除 provider，返回删除的数量
+func (ps *ProviderService) BulkDelete(kind string, ids []int) (int, error) {
+	if len(ids) == 0 {
+		return 0, errors.New("未选择 provider")
+	}
+	selected := idSet(ids)
+	providers, err := ps.LoadProviders(kind)
+	if err != nil {
+		return 0, err
+	}
+	kept := make([]Provider, 0, len(providers))
+	for _, p := range providers {
+		if _, ok := selected[p.ID]; ok {
+			continue
+		}
+		kept = append(kept, p)
+	}
+	removed := len(providers) - len(kept)
+	if removed == 0 {
+		return 0, nil
+	}
+	if err := ps.SaveProviders(kind, kept); err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+func (ps *ProviderService) bulkUpdate(kind string, ids []int, mutate func(p *Provider) bool) (int, error) {
+	if len(ids) == 0 {
+		return 0, errors.New("未选择 provider")
+	}
+	selected := idSet(ids)
+	providers, err := ps.LoadProviders(kind)
+	if err != nil {
+		return 0, err
+	}
+	changed := 0
+	for i := range providers {
+		if _, ok := selected[providers[i].ID]; !ok {
+			continue
+		}
+		if mutate(&providers[i]) {
+			changed++
+		}
+	}
+	if changed == 0 {
+		return 0, nil
+	}
+	if err := ps.SaveProviders(kind, providers); err != nil {
+		return 0, err
+	}
+	return changed, nil
+}
+
+func idSet(ids []int) map[int]struct{} {
+	set := make(map[int]struct{}, len(ids))
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+	return set
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/daodao97/xgo/xdb"
+)
+
+// ProviderComparison 单个 provider 在时间范围内的成本、延迟与可靠性
+type ProviderComparison struct {
+	Provider string `json:"provider"`
+	Requests int64  `json:"requests"`
+	Errors   int64  `json:"errors"`
+	// 错误率与故障切换率均为百分比
+	ErrorRate float64 `json:"error_rate"`
+	// 以该 provider 为起点的故障切换次数
+	Failovers    int64   `json:"failovers"`
+	FailoverRate float64 `json:"failover_rate"`
+	Tokens       int64   `json:"tokens"`
+	TotalCost    float64 `json:"total_cost"`
+	// 每 1K token 的费用（展示币种）
+	CostPer1K float64 `json:"cost_per_1k"`
+	P50       float64 `json:"p50"`
+	P95       float64 `json:"p95"`
+	TTFTP95   float64 `json:"ttft_p95"`
+}
+
+// ProviderComparisonReport provider 横向对比报告，Best* 为各指标最优的 provider（样本不足时为空）
+type ProviderComparisonReport struct {
+	Platform     string               `json:"platform"`
+	Since        string               `json:"since"`
+	Until        string               `json:"until"`
+	Currency     string               `json:"currency"`
+	Providers    []ProviderComparison `json:"providers"`
+	Cheapest     string               `json:"cheapest,omitempty"`
+	Fastest      string               `json:"fastest,omitempty"`
+	MostReliable string               `json:"most_reliable,omitempty"`
+}
+
+// 参与评选最优 provider 的最少请求数
+const comparisonMinRequests = 10
+
+// CompareProviders 对比 [since, until) 内各 provider 的每 1K token 成本、p95 延迟、错误率与故障切换频率；
+// 时间格式同 LogQuery，since 为空时默认最近 7 天
+func (ls *LogService) CompareProviders(platform, since, until string) (ProviderComparisonReport, error) {
+	end := time.Now()
+	if until != "" {
+		parsed, err := parseLocalTime(until)
+		if err != nil {
+			return ProviderComparisonReport{}, err
+		}
+		end = parsed
+	}
+	start := end.AddDate(0, 0, -7)
+	if since != "" {
+		parsed, err := parseLocalTime(since)
+		if err != nil {
+			return ProviderComparisonReport{}, err
+		}
+		start = parsed
+	}
+	report := ProviderComparisonReport{
+		Platform:  platform,
+		Since:     start.Format(timeLayout),
+		Until:     end.Format(timeLayout),
+		Providers: []ProviderComparison{},
+	}
+	_, report.Currency = displayCost(0)
+
+	rangeOptions := func(extra ...xdb.Option) []xdb.Option {
+		options := []xdb.Option{
+			xdb.WhereGte("created_at", start.UTC().Format(timeLayout)),
+			xdb.WhereLt("created_at", end.UTC().Format(timeLayout)),
+		}
+		if platform != "" {
+			options = append(options, xdb.WhereEq("platform", platform))
+		}
+		return append(options, extra...)
+	}
+	records, err := xdb.New(requestLogTable()).Selects(rangeOptions(xdb.Field(
+		"provider",
+		"model",
+		"http_code",
+		"input_tokens",
+		"output_tokens",
+		"cache_create_tokens",
+		"cache_read_tokens",
+		"duration_sec",
+		"first_token_sec",
+	))...)
+	if err != nil && !errors.Is(err, xdb.ErrNotFound) && !isNoSuchTableErr(err) {
+		return report, err
+	}
+
+	type accumulator struct {
+		stat      *ProviderComparison
+		durations []float64
+		ttfts     []float64
+	}
+	providers := make(map[string]*accumulator)
+	for _, record := range records {
+		name := record.GetString("provider")
+		acc := providers[name]
+		if acc == nil {
+			acc = &accumulator{stat: &ProviderComparison{Provider: name}}
+			providers[name] = acc
+		}
+		stat := acc.stat
+		stat.Requests++
+		code := record.GetInt("http_code")
+		if code < http.StatusOK || code >= http.StatusMultipleChoices {
+			stat.Errors++
+			continue
+		}
+		stat.Tokens += int64(record.GetInt("input_tokens") + record.GetInt("output_tokens") +
+			record.GetInt("cache_create_tokens") + record.GetInt("cache_read_tokens"))
+		stat.TotalCost += ls.recordCost(record)
+		if duration := record.GetFloat64("duration_sec"); duration > 0 {
+			acc.durations = append(acc.durations, duration)
+		}
+		if ttft := record.GetFloat64("first_token_sec"); ttft > 0 {
+			acc.ttfts = append(acc.ttfts, ttft)
+		}
+	}
+
+	events, err := xdb.New(providerEventTable).Selects(rangeOptions(
+		xdb.WhereEq("event_type", ProviderEventFailover),
+		xdb.Field("provider"),
+	)...)
+	if err != nil && !errors.Is(err, xdb.ErrNotFound) && !isNoSuchTableErr(err) {
+		return report, err
+	}
+	for _, event := range events {
+		if acc := providers[event.GetString("provider")]; acc != nil {
+			acc.stat.Failovers++
+		}
+	}
+
+	for _, acc := range providers {
+		stat := acc.stat
+		stat.ErrorRate = float64(stat.Errors) / float64(stat.Requests) * 100
+		stat.FailoverRate = float64(stat.Failovers) / float64(stat.Requests) * 100
+		stat.TotalCost, _ = displayCost(stat.TotalCost)
+		if stat.Tokens > 0 {
+			stat.CostPer1K = stat.TotalCost / float64(stat.Tokens) * 1000
+		}
+		sort.Float64s(acc.durations)
+		sort.Float64s(acc.ttfts)
+		stat.P50 = percentile(acc.durations, 50)
+		stat.P95 = percentile(acc.durations, 95)
+		stat.TTFTP95 = percentile(acc.ttfts, 95)
+		report.Providers = append(report.Providers, *stat)
+	}
+	sort.Slice(report.Providers, func(i, j int) bool {
+		if report.Providers[i].Requests != report.Providers[j].Requests {
+			return report.Providers[i].Requests > report.Providers[j].Requests
+		}
+		return report.Providers[i].Provider < report.Providers[j].Provider
+	})
+
+	report.Cheapest = bestProvider(report.Providers, func(stat ProviderComparison) (float64, bool) {
+		return stat.CostPer1K, stat.Tokens > 0 && stat.CostPer1K > 0
+	})
+	report.Fastest = bestProvider(report.Providers, func(stat ProviderComparison) (float64, bool) {
+		return stat.P95, stat.P95 > 0
+	})
+	report.MostReliable = bestProvider(report.Providers, func(stat ProviderComparison) (float64, bool) {
+		return stat.ErrorRate + stat.FailoverRate, true
+	})
+	return report, nil
+}
+
+// bestProvider 返回请求数达标的 provider 中指标最小的一个
+func bestProvider(stats []ProviderComparison, metric func(ProviderComparison) (float64, bool)) string {
+	best := ""
+	bestValue := 0.0
+	for _, stat := range stats {
+		if stat.Requests < comparisonMinRequests {
+			continue
+		}
+		value, ok := metric(stat)
+		if !ok {
+			continue
+		}
+		if best == "" || value < bestValue {
+			best = stat.Provider
+			bestValue = value
+		}
+	}
+	return best
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/daodao97/xgo/xdb"
+)
+
+const (
+	providerEventTable = "provider_event"
+
+	ProviderEventBlacklist   = "blacklist"
+	ProviderEventUnblacklist = "unblacklist"
+	ProviderEventRecover     = "recover"
+	ProviderEventFailover    = "failover"
+	ProviderEventIncident    = "incident"
+
+	relayRequestIDKey = "relay_request_id"
+)
+
+// ProviderEvent 拉黑、恢复与故障切换事件，用于事后追溯请求为何被切到了另一个 provider
+type ProviderEvent struct {
+	ID             int64     `json:"id"`
+	Platform       string    `json:"platform"`
+	EventType      string    `json:"event_type"`
+	Provider       string    `json:"provider"`
+	TargetProvider string    `json:"target_provider,omitempty"`
+	Reason         string    `json:"reason"`
+	RequestID      string    `json:"request_id,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+var relayRequestSeq atomic.Uint64
+
+// newRelayRequestID 生成 relay 请求 ID，写入 request_log 与事件表以便关联
+func newRelayRequestID() string {
+	return strconv.FormatInt(time.Now().UnixMilli(), 36) + "-" + strconv.FormatUint(relayRequestSeq.Add(1), 36)
+}
+
+func recordProviderEvent(event ProviderEvent) {
+	if _, err := xdb.New(providerEventTable).Insert(xdb.Record{
+		"platform":        normalizeRelayScope(event.Platform),
+		"event_type":      event.EventType,
+		"provider":        event.Provider,
+		"target_provider": event.TargetProvider,
+		"reason":          event.Reason,
+		"request_id":      event.RequestID,
+	}); err != nil {
+		fmt.Printf("写入 provider_event 失败: %v\n", err)
+	}
+	notifyProviderEvent(event)
+}
+
+// ListProviderEvents 查询拉黑/恢复/切换事件，eventType、provider 为空表示不过滤
+func (ls *LogService) ListProviderEvents(platform string, eventType string, provider string, limit int) ([]ProviderEvent, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+	options := []xdb.Option{
+		xdb.OrderByDesc("id"),
+		xdb.Limit(limit),
+	}
+	if platform != "" {
+		options = append(options, xdb.WhereEq("platform", normalizeRelayScope(platform)))
+	}
+	if eventType != "" {
+		options = append(options, xdb.WhereEq("event_type", eventType))
+	}
+	if provider != "" {
+		options = append(options, xdb.WhereEq("provider", provider))
+	}
+	return queryProviderEvents(options...)
+}
+
+// ListRequestEvents 查询某个请求触发的全部事件
+func (ls *LogService) ListRequestEvents(requestID string) ([]ProviderEvent, error) {
+	return queryProviderEvents(
+		xdb.WhereEq("request_id", requestID),
+		xdb.OrderByDesc("id"),
+	)
+}
+
+func queryProviderEvents(options ...xdb.Option) ([]ProviderEvent, error) {
+	records, err := xdb.New(providerEventTable).Selects(options...)
+	if err != nil {
+		if isNoSuchTableErr(err) {
+			return []ProviderEvent{}, nil
+		}
+		return nil, err
+	}
+	events := make([]ProviderEvent, 0, len(records))
+	for _, record := range records {
+		createdAt, _ := parseCreatedAt(record)
+		events = append(events, ProviderEvent{
+			ID:             record.GetInt64("id"),
+			Platform:       record.GetString("platform"),
+			EventType:      record.GetString("event_type"),
+			Provider:       record.GetString("provider"),
+			TargetProvider: record.GetString("target_provider"),
+			Reason:         record.GetString("reason"),
+			RequestID:      record.GetString("request_id"),
+			CreatedAt:      createdAt,
+		})
+	}
+	return events, nil
+}
+
+func ensureProviderEventTable() error {
+	db, err := xdb.DB("default")
+	if err != nil {
+		return err
+	}
+	return ensureProviderEventTableWithDB(db)
+}
+
+func ensureProviderEventTableWithDB(db *sql.DB) error {
+	const createTableSQL = `CREATE TABLE IF NOT EXISTS provider_event (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		platform TEXT,
+		event_type TEXT,
+		provider TEXT,
+		target_provider TEXT,
+		reason TEXT,
+		request_id TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return err
+	}
+	_, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_provider_event_request ON provider_event (request_id)`)
+	return err
+}
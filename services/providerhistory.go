@@ -0,0 +1,259 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/daodao97/xgo/xdb"
+)
+
+const providerHistoryTable = "provider_history"
+
+// ProviderHistoryEntry 一次 provider 配置变更记录
+type ProviderHistoryEntry struct {
+	ID        int64     `json:"id"`
+	Platform  string    `json:"platform"`
+	Actor     string    `json:"actor"`
+	Action    string    `json:"action"`
+	Summary   string    `json:"summary"`
+	Changes   []string  `json:"changes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// History 返回当前 profile 下某个平台最近的配置变更记录，按时间倒序
+func (ps *ProviderService) History(kind string, limit int) ([]ProviderHistoryEntry, error) {
+	platform := normalizePresetKind(kind)
+	if platform == "" {
+		return nil, fmt.Errorf("unknown provider type: %s", kind)
+	}
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	records, err := xdb.New(providerHistoryTable).Selects(
+		xdb.WhereEq("platform", platform),
+		xdb.WhereEq("profile", currentProfileID()),
+		xdb.OrderByDesc("id"),
+		xdb.Limit(limit),
+	)
+	if err != nil {
+		if isNoSuchTableErr(err) {
+			return []ProviderHistoryEntry{}, nil
+		}
+		return nil, err
+	}
+	entries := make([]ProviderHistoryEntry, 0, len(records))
+	for _, record := range records {
+		changes := splitHistoryChanges(record.GetString("changes"))
+		createdAt, _ := parseCreatedAt(record)
+		entries = append(entries, ProviderHistoryEntry{
+			ID:        record.GetInt64("id"),
+			Platform:  record.GetString("platform"),
+			Actor:     record.GetString("actor"),
+			Action:    record.GetString("action"),
+			Summary:   historySummary(changes),
+			Changes:   changes,
+			CreatedAt: createdAt,
+		})
+	}
+	return entries, nil
+}
+
+// Rollback 将配置恢复到指定变更发生之前的状态，只能回滚当前 profile 的记录，回滚本身也会记录一条历史
+func (ps *ProviderService) Rollback(kind string, historyID int64) error {
+	platform := normalizePresetKind(kind)
+	if platform == "" {
+		return fmt.Errorf("unknown provider type: %s", kind)
+	}
+	record, err := xdb.New(providerHistoryTable).First(
+		xdb.WhereEq("id", historyID),
+		xdb.WhereEq("platform", platform),
+		xdb.WhereEq("profile", currentProfileID()),
+	)
+	if err != nil {
+		if errors.Is(err, xdb.ErrNotFound) {
+			return fmt.Errorf("未找到历史记录 %d", historyID)
+		}
+		return err
+	}
+	var before []Provider
+	if snapshot := record.GetString("before_snapshot"); snapshot != "" {
+		if err := json.Unmarshal([]byte(snapshot), &before); err != nil {
+			return fmt.Errorf("历史快照解析失败: %w", err)
+		}
+	}
+	return ps.saveProviders(kind, before, fmt.Sprintf("rollback#%d", historyID))
+}
+
+// recordProviderHistory 记录变更前后的完整快照与可读差异，无变化时不记录
+func recordProviderHistory(kind, action string, before, after []Provider) {
+	changes := diffProviders(before, after)
+	if len(changes) == 0 {
+		return
+	}
+	beforeData, err := json.Marshal(before)
+	if err != nil {
+		return
+	}
+	afterData, err := json.Marshal(after)
+	if err != nil {
+		return
+	}
+	if _, err := xdb.New(providerHistoryTable).Insert(xdb.Record{
+		"platform":        normalizePresetKind(kind),
+		"profile":         currentProfileID(),
+		"actor":           currentActor(),
+		"action":          action,
+		"changes":         strings.Join(changes, "\n"),
+		"before_snapshot": string(beforeData),
+		"after_snapshot":  string(afterData),
+	}); err != nil {
+		fmt.Printf("记录 provider 变更历史失败: %v\n", err)
+	}
+}
+
+// diffProviders 按 provider ID 比较前后两份配置，API Key 只提示变更不输出明文
+func diffProviders(before, after []Provider) []string {
+	oldByID := make(map[int]Provider, len(before))
+	for _, p := range before {
+		oldByID[p.ID] = p
+	}
+	changes := make([]string, 0)
+	seen := make(map[int]struct{}, len(after))
+	for _, p := range after {
+		seen[p.ID] = struct{}{}
+		old, ok := oldByID[p.ID]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("新增 %s", p.Name))
+			continue
+		}
+		if old.Enabled != p.Enabled {
+			changes = append(changes, fmt.Sprintf("%s: enabled %v -> %v", p.Name, old.Enabled, p.Enabled))
+		}
+		if old.Observer != p.Observer {
+			changes = append(changes, fmt.Sprintf("%s: observer %v -> %v", p.Name, old.Observer, p.Observer))
+		}
+		if old.Level != p.Level {
+			changes = append(changes, fmt.Sprintf("%s: level %d -> %d", p.Name, old.Level, p.Level))
+		}
+		if old.APIURL != p.APIURL {
+			changes = append(changes, fmt.Sprintf("%s: apiUrl %s -> %s", p.Name, old.APIURL, p.APIURL))
+		}
+		if old.APIKey != p.APIKey {
+			changes = append(changes, fmt.Sprintf("%s: apiKey 已修改", p.Name))
+		}
+		if old.AuthStyle != p.AuthStyle {
+			changes = append(changes, fmt.Sprintf("%s: authStyle %s -> %s", p.Name, old.AuthStyle, p.AuthStyle))
+		}
+		if old.ForceModel != p.ForceModel {
+			changes = append(changes, fmt.Sprintf("%s: forceModel %s -> %s", p.Name, old.ForceModel, p.ForceModel))
+		}
+		if old.StatusPageURL != p.StatusPageURL {
+			changes = append(changes, fmt.Sprintf("%s: statusPageUrl %s -> %s", p.Name, old.StatusPageURL, p.StatusPageURL))
+		}
+		if old.Subscription != p.Subscription {
+			changes = append(changes, fmt.Sprintf("%s: subscription %s -> %s", p.Name, old.Subscription, p.Subscription))
+		}
+		if !jsonEqual(old.Balance, p.Balance) {
+			changes = append(changes, fmt.Sprintf("%s: 余额查询配置已修改", p.Name))
+		}
+		if !jsonEqual(old.Env, p.Env) {
+			changes = append(changes, fmt.Sprintf("%s: 环境变量已修改", p.Name))
+		}
+		if !jsonEqual(old.SupportedModels, p.SupportedModels) || !jsonEqual(old.ModelMapping, p.ModelMapping) {
+			changes = append(changes, fmt.Sprintf("%s: 模型配置已修改", p.Name))
+		}
+		if old.Site != p.Site || old.Icon != p.Icon || old.Tint != p.Tint || old.Accent != p.Accent {
+			changes = append(changes, fmt.Sprintf("%s: 展示信息已修改", p.Name))
+		}
+	}
+	removed := make([]string, 0)
+	for _, p := range before {
+		if _, ok := seen[p.ID]; !ok {
+			removed = append(removed, fmt.Sprintf("删除 %s", p.Name))
+		}
+	}
+	sort.Strings(removed)
+	changes = append(changes, removed...)
+	if len(changes) == 0 && !sameProviderOrder(before, after) {
+		changes = append(changes, "调整顺序")
+	}
+	return changes
+}
+
+func sameProviderOrder(before, after []Provider) bool {
+	if len(before) != len(after) {
+		return false
+	}
+	for i := range before {
+		if before[i].ID != after[i].ID {
+			return false
+		}
+	}
+	return true
+}
+
+func jsonEqual(a, b interface{}) bool {
+	left, _ := json.Marshal(a)
+	right, _ := json.Marshal(b)
+	return string(left) == string(right)
+}
+
+func historySummary(changes []string) string {
+	switch len(changes) {
+	case 0:
+		return ""
+	case 1:
+		return changes[0]
+	default:
+		return fmt.Sprintf("%s 等 %d 项变更", changes[0], len(changes))
+	}
+}
+
+func splitHistoryChanges(value string) []string {
+	if strings.TrimSpace(value) == "" {
+		return []string{}
+	}
+	return strings.Split(value, "\n")
+}
+
+// currentActor 返回当前系统用户名，用于记录“谁”修改了配置
+func currentActor() string {
+	for _, key := range []string{"USER", "USERNAME"} {
+		if name := strings.TrimSpace(os.Getenv(key)); name != "" {
+			return name
+		}
+	}
+	return "local"
+}
+
+func ensureProviderHistoryTable() error {
+	db, err := xdb.DB("default")
+	if err != nil {
+		return err
+	}
+	return ensureProviderHistoryTableWithDB(db)
+}
+
+func ensureProviderHistoryTableWithDB(db *sql.DB) error {
+	const createTableSQL = `CREATE TABLE IF NOT EXISTS provider_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		platform TEXT,
+		actor TEXT,
+		action TEXT,
+		changes TEXT,
+		before_snapshot TEXT,
+		after_snapshot TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return err
+	}
+	// profile 之前的记录都属于默认 profile
+	return ensureRequestLogColumn(db, providerHistoryTable, "profile", "TEXT NOT NULL DEFAULT '"+defaultProfileID+"'")
+}
@@ -0,0 +1,394 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	providerPresetsFile      = "provider-presets.json"
+	defaultProviderPresetURL = "https://raw.githubusercontent.com/daodao97/code-switch/main/resources/provider-presets.json"
+
+	authStyleBearer  = "bearer"
+	authStyleAPIKey  = "x-api-key"
+	authStyleBothKey = "both"
+	authStyleGoogKey = "x-goog-api-key"
+)
+
+// ProviderPreset 描述一个常见 provider 的预设，添加时只需选择预设并填写 API Key
+type ProviderPreset struct {
+	ID              string            `json:"id"`
+	Kind            string            `json:"kind"`
+	Name            string            `json:"name"`
+	APIURL          string            `json:"apiUrl"`
+	Site            string            `json:"officialSite"`
+	Icon            string            `json:"icon"`
+	Tint            string            `json:"tint,omitempty"`
+	Accent          string            `json:"accent,omitempty"`
+	AuthStyle       string            `json:"authStyle,omitempty"`
+	SupportedModels map[string]bool   `json:"supportedModels,omitempty"`
+	ModelMapping    map[string]string `json:"modelMapping,omitempty"`
+	Notes           string            `json:"notes,omitempty"`
+}
+
+type providerPresetCache struct {
+	Source    string           `json:"source"`
+	UpdatedAt time.Time        `json:"updated_at"`
+	Presets   []ProviderPreset `json:"presets"`
+}
+
+var builtInProviderPresets = []ProviderPreset{
+	{
+		ID:        "anthropic",
+		Kind:      "claude",
+		Name:      "Anthropic",
+		APIURL:    "https://api.anthropic.com",
+		Site:      "https://console.anthropic.com",
+		Icon:      "claude",
+		AuthStyle: authStyleAPIKey,
+		Notes:     "官方 API，使用 x-api-key 鉴权",
+	},
+	{
+		ID:        "deepseek",
+		Kind:      "claude",
+		Name:      "Deepseek",
+		APIURL:    "https://api.deepseek.com/anthropic",
+		Site:      "https://platform.deepseek.com",
+		Icon:      "deepseek",
+		AuthStyle: authStyleBearer,
+		SupportedModels: map[string]bool{
+			"deepseek-chat":     true,
+			"deepseek-reasoner": true,
+		},
+		ModelMapping: map[string]string{
+			"claude-*": "deepseek-chat",
+		},
+	},
+	{
+		ID:        "kimi",
+		Kind:      "claude",
+		Name:      "Kimi",
+		APIURL:    "https://api.moonshot.cn/anthropic",
+		Site:      "https://platform.moonshot.cn",
+		Icon:      "kimi",
+		AuthStyle: authStyleBearer,
+		SupportedModels: map[string]bool{
+			"kimi-k2-turbo-preview": true,
+			"kimi-k2-0905-preview":  true,
+		},
+		ModelMapping: map[string]string{
+			"claude-*": "kimi-k2-turbo-preview",
+		},
+	},
+	{
+		ID:        "glm",
+		Kind:      "claude",
+		Name:      "GLM",
+		APIURL:    "https://open.bigmodel.cn/api/anthropic",
+		Site:      "https://open.bigmodel.cn",
+		Icon:      "zhipu",
+		AuthStyle: authStyleBearer,
+		SupportedModels: map[string]bool{
+			"glm-4.6":     true,
+			"glm-4.5-air": true,
+		},
+		ModelMapping: map[string]string{
+			"claude-*": "glm-4.6",
+		},
+	},
+	{
+		ID:        "qwen",
+		Kind:      "claude",
+		Name:      "Qwen",
+		APIURL:    "https://dashscope.aliyuncs.com/apps/anthropic",
+		Site:      "https://bailian.console.aliyun.com",
+		Icon:      "qwen",
+		AuthStyle: authStyleBearer,
+		SupportedModels: map[string]bool{
+			"qwen3-coder-plus": true,
+		},
+		ModelMapping: map[string]string{
+			"claude-*": "qwen3-coder-plus",
+		},
+	},
+	{
+		ID:        "siliconflow",
+		Kind:      "claude",
+		Name:      "SiliconFlow",
+		APIURL:    "https://api.siliconflow.cn",
+		Site:      "https://cloud.siliconflow.cn",
+		Icon:      "siliconcloud",
+		AuthStyle: authStyleBearer,
+		SupportedModels: map[string]bool{
+			"moonshotai/Kimi-K2-Instruct-0905": true,
+			"deepseek-ai/DeepSeek-V3.1":        true,
+		},
+		ModelMapping: map[string]string{
+			"claude-*": "moonshotai/Kimi-K2-Instruct-0905",
+		},
+	},
+	{
+		ID:        "openrouter",
+		Kind:      "claude",
+		Name:      "OpenRouter",
+		APIURL:    "https://openrouter.ai/api",
+		Site:      "https://openrouter.ai",
+		Icon:      "openrouter",
+		AuthStyle: authStyleBearer,
+		SupportedModels: map[string]bool{
+			"anthropic/claude-*": true,
+		},
+		ModelMapping: map[string]string{
+			"claude-*": "anthropic/claude-*",
+		},
+	},
+	{
+		ID:        "openai",
+		Kind:      "codex",
+		Name:      "OpenAI",
+		APIURL:    "https://api.openai.com/v1",
+		Site:      "https://platform.openai.com",
+		Icon:      "openai",
+		AuthStyle: authStyleBearer,
+		Notes:     "官方 API",
+	},
+	{
+		ID:        "openrouter",
+		Kind:      "codex",
+		Name:      "OpenRouter",
+		APIURL:    "https://openrouter.ai/api/v1",
+		Site:      "https://openrouter.ai",
+		Icon:      "openrouter",
+		AuthStyle: authStyleBearer,
+		SupportedModels: map[string]bool{
+			"openai/gpt-5":       true,
+			"openai/gpt-5-codex": true,
+		},
+		ModelMapping: map[string]string{
+			"gpt-5*": "openai/gpt-5*",
+		},
+	},
+}
+
+// ListPresets 返回内置预设与远程更新的预设（同 ID 时以远程为准）
+func (ps *ProviderService) ListPresets(kind string) ([]ProviderPreset, error) {
+	kind = normalizePresetKind(kind)
+	merged := make(map[string]ProviderPreset)
+	for _, preset := range builtInProviderPresets {
+		merged[presetKey(preset)] = preset
+	}
+	cache, err := loadProviderPresetCache()
+	if err != nil {
+		return nil, err
+	}
+	for _, preset := range cache.Presets {
+		merged[presetKey(preset)] = preset
+	}
+	presets := make([]ProviderPreset, 0, len(merged))
+	for _, preset := range merged {
+		if kind != "" && preset.Kind != kind {
+			continue
+		}
+		presets = append(presets, preset)
+	}
+	sort.SliceStable(presets, func(i, j int) bool {
+		if presets[i].Kind != presets[j].Kind {
+			return presets[i].Kind < presets[j].Kind
+		}
+		return strings.ToLower(presets[i].Name) < strings.ToLower(presets[j].Name)
+	})
+	return presets, nil
+}
+
+// RefreshPresets 从远程地址拉取最新的预设目录并缓存到本地
+func (ps *ProviderService) RefreshPresets(sourceURL string) (int, error) {
+	sourceURL = strings.TrimSpace(sourceURL)
+	if sourceURL == "" {
+		sourceURL = defaultProviderPresetURL
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(sourceURL)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("拉取预设失败: %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	var remote []ProviderPreset
+	if err := json.Unmarshal(data, &remote); err != nil {
+		return 0, fmt.Errorf("预设格式错误: %w", err)
+	}
+	valid := make([]ProviderPreset, 0, len(remote))
+	for _, preset := range remote {
+		preset.Kind = normalizePresetKind(preset.Kind)
+		preset.ID = strings.TrimSpace(preset.ID)
+		if preset.ID == "" || preset.Kind == "" || strings.TrimSpace(preset.APIURL) == "" {
+			continue
+		}
+		valid = append(valid, preset)
+	}
+	cache := providerPresetCache{Source: sourceURL, UpdatedAt: time.Now(), Presets: valid}
+	if err := saveProviderPresetCache(cache); err != nil {
+		return 0, err
+	}
+	return len(valid), nil
+}
+
+// AddProviderFromPreset 根据预设创建 provider，只需提供 API Key
+func (ps *ProviderService) AddProviderFromPreset(kind string, presetID string, apiKey string) (Provider, error) {
+	kind = normalizePresetKind(kind)
+	apiKey = strings.TrimSpace(apiKey)
+	if apiKey == "" {
+		return Provider{}, errors.New("API Key 不能为空")
+	}
+	presets, err := ps.ListPresets(kind)
+	if err != nil {
+		return Provider{}, err
+	}
+	var preset *ProviderPreset
+	for i := range presets {
+		if strings.EqualFold(presets[i].ID, strings.TrimSpace(presetID)) {
+			preset = &presets[i]
+			break
+		}
+	}
+	if preset == nil {
+		return Provider{}, fmt.Errorf("未找到预设 %s", presetID)
+	}
+
+	existing, err := ps.LoadProviders(kind)
+	if err != nil {
+		return Provider{}, err
+	}
+	accent, tint := defaultVisual(kind)
+	if preset.Accent != "" {
+		accent = preset.Accent
+	}
+	if preset.Tint != "" {
+		tint = preset.Tint
+	}
+	provider := Provider{
+		ID:              nextProviderID(existing),
+		Name:            uniqueProviderName(existing, preset.Name, archivedNameSet(kind)),
+		APIURL:          preset.APIURL,
+		APIKey:          apiKey,
+		Site:            preset.Site,
+		Icon:            preset.Icon,
+		Tint:            tint,
+		Accent:          accent,
+		Enabled:         true,
+		AuthStyle:       preset.AuthStyle,
+		SupportedModels: cloneBoolMap(preset.SupportedModels),
+		ModelMapping:    cloneStringMap(preset.ModelMapping),
+	}
+	if err := ps.SaveProviders(kind, append(existing, provider)); err != nil {
+		return Provider{}, err
+	}
+	return provider, nil
+}
+
+func normalizePresetKind(kind string) string {
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case "claude", "claude-code", "claude_code":
+		return "claude"
+	case "codex":
+		return "codex"
+	case "gemini":
+		return "gemini"
+	default:
+		return ""
+	}
+}
+
+func presetKey(preset ProviderPreset) string {
+	return preset.Kind + ":" + strings.ToLower(preset.ID)
+}
+
+func uniqueProviderName(existing []Provider, name string, reserved map[string]struct{}) string {
+	taken := make(map[string]struct{}, len(existing)+len(reserved))
+	for _, p := range existing {
+		taken[strings.ToLower(p.Name)] = struct{}{}
+	}
+	for key := range reserved {
+		taken[key] = struct{}{}
+	}
+	if _, ok := taken[strings.ToLower(name)]; !ok {
+		return name
+	}
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s %d", name, i)
+		if _, ok := taken[strings.ToLower(candidate)]; !ok {
+			return candidate
+		}
+	}
+}
+
+func cloneBoolMap(values map[string]bool) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+	out := make(map[string]bool, len(values))
+	for key, value := range values {
+		out[key] = value
+	}
+	return out
+}
+
+func providerPresetCachePath() (string, error) {
+	dir, err := ensureDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, providerPresetsFile), nil
+}
+
+func loadProviderPresetCache() (providerPresetCache, error) {
+	var cache providerPresetCache
+	path, err := providerPresetCachePath()
+	if err != nil {
+		return cache, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cache, nil
+		}
+		return cache, err
+	}
+	if len(data) == 0 {
+		return cache, nil
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return cache, err
+	}
+	return cache, nil
+}
+
+func saveProviderPresetCache(cache providerPresetCache) error {
+	path, err := providerPresetCachePath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
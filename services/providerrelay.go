@@ -4,10 +4,10 @@ import (
 	"bytes"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strings"
 	"time"
@@ -21,37 +21,50 @@ import (
 	_ "modernc.org/sqlite"
 )
 
+// relayTargetHeader 指定本次请求只使用某个 provider（按名称匹配）
+const relayTargetHeader = "X-Code-Switch-Provider"
+
+const (
+	// relayScopeKey 拉黑、故障切换事件与请求日志使用的平台名，未设置时与 provider 平台相同
+	relayScopeKey = "relay_scope"
+	// relayPoolKey 本次请求可用的 provider 名称，按故障切换顺序排列
+	relayPoolKey = "relay_pool"
+)
+
 type ProviderRelayService struct {
-	providerService *ProviderService
-	server          *http.Server
-	addr            string
+	providerService  *ProviderService
+	blacklistService *BlacklistService
+	server           *http.Server
+	addr             string
+	mcpService       *MCPService
+	customCliService *CustomCliService
 }
 
-func NewProviderRelayService(providerService *ProviderService, addr string) *ProviderRelayService {
+func NewProviderRelayService(providerService *ProviderService, blacklistService *BlacklistService, addr string) *ProviderRelayService {
 	if addr == "" {
 		addr = ":18100"
 	}
 
-	home, _ := os.UserHomeDir()
 	const sqliteOptions = "?cache=shared&mode=rwc&_busy_timeout=5000&_journal_mode=WAL"
 
 	if err := xdb.Inits([]xdb.Config{
 		{
 			Name:        "default",
 			Driver:      "sqlite",
-			DSN:         filepath.Join(home, ".code-switch", "app.db"+sqliteOptions),
+			DSN:         filepath.Join(dataDir(), "app.db"+sqliteOptions),
 			MaxOpenConn: 1,
 			MaxIdleConn: 1,
 		},
 	}); err != nil {
 		fmt.Printf("初始化数据库失败: %v\n", err)
-	} else if err := ensureRequestLogTable(); err != nil {
-		fmt.Printf("初始化 request_log 表失败: %v\n", err)
+	} else if err := ensureDatabaseTables(); err != nil {
+		fmt.Println(err)
 	}
 
 	return &ProviderRelayService{
-		providerService: providerService,
-		addr:            addr,
+		providerService:  providerService,
+		blacklistService: blacklistService,
+		addr:             addr,
 	}
 }
 
@@ -135,17 +148,119 @@ func (prs *ProviderRelayService) Stop() error {
 	return prs.server.Shutdown(ctx)
 }
 
+// relayBaseURL 把监听地址（如 ":18100"）转换为客户端可访问的 URL
+func relayBaseURL(addr string) string {
+	addr = strings.TrimSpace(addr)
+	if addr == "" {
+		addr = ":18100"
+	}
+	if strings.HasPrefix(addr, "http://") || strings.HasPrefix(addr, "https://") {
+		return addr
+	}
+	host := addr
+	if strings.HasPrefix(host, ":") {
+		host = "127.0.0.1" + host
+	}
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	return host
+}
+
+func (prs *ProviderRelayService) blacklistEntry(kind string, provider string) (BlacklistEntry, bool) {
+	if prs.blacklistService == nil {
+		return BlacklistEntry{}, false
+	}
+	return prs.blacklistService.blacklistEntry(kind, provider)
+}
+
 func (prs *ProviderRelayService) Addr() string {
 	return prs.addr
 }
 
+// SetMCPService 在 relay 上挂载 MCP 网关，需在 Start 之前调用
+func (prs *ProviderRelayService) SetMCPService(ms *MCPService) {
+	prs.mcpService = ms
+	if ms != nil {
+		ms.SetGatewayBaseURL(relayBaseURL(prs.addr))
+	}
+}
+
+// SetCustomCliService 在 relay 上挂载自定义工具的 /custom/<id> 路由，需在 Start 之前调用
+func (prs *ProviderRelayService) SetCustomCliService(cs *CustomCliService) {
+	prs.customCliService = cs
+}
+
 func (prs *ProviderRelayService) registerRoutes(router gin.IRouter) {
 	router.POST("/v1/messages", prs.proxyHandler("claude", "/v1/messages"))
 	router.POST("/responses", prs.proxyHandler("codex", "/responses"))
+	router.POST("/v1beta/*path", prs.geminiHandler)
+	if prs.mcpService != nil {
+		router.Any("/mcp/*path", gin.WrapF(prs.mcpService.ServeMCPGateway))
+	}
+	if prs.customCliService != nil {
+		router.POST(customCliRoutePrefix+":toolId/*path", prs.customCliHandler)
+	}
+}
+
+// customCliHandler 按工具的协议交给 Claude 或 Codex 的 provider，工具请求的路径原样转发。
+// 拉黑、故障切换事件与请求日志都记在 custom/<id> 下，与 CLI 本身互不影响
+func (prs *ProviderRelayService) customCliHandler(c *gin.Context) {
+	tool, err := findCustomCliTool(c.Param("toolId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.Set(relayScopeKey, customCliScope(tool.ID))
+	if len(tool.Providers) > 0 {
+		c.Set(relayPoolKey, tool.Providers)
+	}
+	prs.proxyHandler(customCliProviderKind(tool), c.Param("path"))(c)
+}
+
+// geminiHandler Gemini 的模型名在路径中（models/<model>:generateContent），路径原样转发给 gemini provider
+func (prs *ProviderRelayService) geminiHandler(c *gin.Context) {
+	prs.proxyHandler("gemini", "/v1beta"+c.Param("path"))(c)
+}
+
+// geminiPathModel 从 /models/<model>:<method> 中取出模型名与方法，流式请求的方法为 streamGenerateContent
+func geminiPathModel(endpoint string) (model string, method string) {
+	_, rest, ok := strings.Cut(endpoint, "/models/")
+	if !ok {
+		return "", ""
+	}
+	model, method, _ = strings.Cut(rest, ":")
+	return model, method
+}
+
+// replaceGeminiPathModel 替换路径中的模型名，用于 Gemini 的模型映射
+func replaceGeminiPathModel(endpoint string, model string) string {
+	prefix, rest, ok := strings.Cut(endpoint, "/models/")
+	if !ok {
+		return endpoint
+	}
+	_, method, _ := strings.Cut(rest, ":")
+	return prefix + "/models/" + model + ":" + method
+}
+
+// pickPoolProviders 只保留 pool 中的 provider，并按 pool 的顺序排列
+func pickPoolProviders(providers []Provider, pool []string) []Provider {
+	byName := make(map[string]Provider, len(providers))
+	for _, provider := range providers {
+		byName[strings.ToLower(provider.Name)] = provider
+	}
+	picked := make([]Provider, 0, len(pool))
+	for _, name := range pool {
+		if provider, ok := byName[strings.ToLower(strings.TrimSpace(name))]; ok {
+			picked = append(picked, provider)
+		}
+	}
+	return picked
 }
 
 func (prs *ProviderRelayService) proxyHandler(kind string, endpoint string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		markRelayActivity()
 		var bodyBytes []byte
 		if c.Request.Body != nil {
 			data, err := io.ReadAll(c.Request.Body)
@@ -157,8 +272,25 @@ func (prs *ProviderRelayService) proxyHandler(kind string, endpoint string) gin.
 			c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
 		}
 
+		// 自定义工具使用独立的拉黑与统计范围
+		scope := c.GetString(relayScopeKey)
+		if scope == "" {
+			scope = kind
+		}
+		// 超出预算且开启硬上限时直接拒绝，避免继续产生费用；自定义工具按 custom/<id> 计算预算
+		if reason := budgetBlocked(scope); reason != "" {
+			c.JSON(http.StatusPaymentRequired, gin.H{"error": reason})
+			return
+		}
+
 		isStream := gjson.GetBytes(bodyBytes, "stream").Bool()
 		requestedModel := gjson.GetBytes(bodyBytes, "model").String()
+		if kind == "gemini" {
+			// Gemini 的模型与是否流式都由路径决定，请求体中没有 model / stream 字段
+			var method string
+			requestedModel, method = geminiPathModel(endpoint)
+			isStream = method == "streamGenerateContent"
+		}
 
 		// 如果未指定模型，记录警告但不拦截
 		if requestedModel == "" {
@@ -170,12 +302,46 @@ func (prs *ProviderRelayService) proxyHandler(kind string, endpoint string) gin.
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load providers"})
 			return
 		}
+		// 配置了 provider 列表时只按该顺序故障切换
+		pool := c.GetStringSlice(relayPoolKey)
+		if len(pool) > 0 {
+			providers = pickPoolProviders(providers, pool)
+		}
 
 		active := make([]Provider, 0, len(providers))
 		skippedCount := 0
+		required := requiredCapabilities(kind, bodyBytes)
+		benched := make([]Provider, 0)
+		// 指定 provider 的请求（经由 relay 的端到端健康检查）只发往该 provider，不做故障切换；
+		// 未携带本次运行探测密钥的请求忽略该请求头，按正常流量路由与计费
+		target := strings.TrimSpace(c.GetHeader(relayTargetHeader))
+		if target != "" && !validRelayProbeToken(c.GetHeader(relayProbeTokenHeader)) {
+			fmt.Printf("[WARN] 请求携带 %s 但探测密钥无效，已忽略\n", relayTargetHeader)
+			target = ""
+		}
+		if target != "" {
+			c.Set(relayProbeKey, true)
+		}
+
 		for _, provider := range providers {
+			if target != "" {
+				if !strings.EqualFold(provider.Name, target) {
+					continue
+				}
+				if provider.APIURL == "" || (provider.APIKey == "" && provider.Subscription == "") {
+					continue
+				}
+				active = append(active, provider)
+				continue
+			}
+
 			// 基础过滤：enabled、URL、APIKey
-			if !provider.Enabled || provider.APIURL == "" || provider.APIKey == "" {
+			if !provider.Enabled || provider.APIURL == "" || (provider.APIKey == "" && provider.Subscription == "") {
+				continue
+			}
+
+			// 观察模式的 provider 只做健康检查，不接收真实流量
+			if provider.Observer {
 				continue
 			}
 
@@ -193,9 +359,31 @@ func (prs *ProviderRelayService) proxyHandler(kind string, endpoint string) gin.
 				continue
 			}
 
+			// 能力矩阵：跳过已探测为不支持本次请求所需特性的 provider
+			if missing := unsupportedCapability(kind, provider, required); missing != "" {
+				fmt.Printf("[INFO] Provider %s 不支持 %s，已跳过\n", provider.Name, missing)
+				skippedCount++
+				continue
+			}
+
+			// 拉黑期内的 provider 暂不参与路由
+			if entry, ok := prs.blacklistEntry(scope, provider.Name); ok {
+				fmt.Printf("[INFO] Provider %s 处于拉黑期（%s），已跳过\n", provider.Name, entry.Reason)
+				benched = append(benched, provider)
+				continue
+			}
+
 			active = append(active, provider)
 		}
 
+		// 全部 provider 都被拉黑时，仍按原顺序尝试，避免请求直接失败
+		if len(active) == 0 && len(benched) > 0 {
+			active = benched
+			if scope == kind {
+				markPlatformDown(kind, benched, "全部 provider 均处于拉黑期")
+			}
+		}
+
 		if len(active) == 0 {
 			if requestedModel != "" {
 				c.JSON(http.StatusNotFound, gin.H{
@@ -207,6 +395,19 @@ func (prs *ProviderRelayService) proxyHandler(kind string, endpoint string) gin.
 			return
 		}
 
+		// 分时段路由计划：按当前时间段调整优先级
+		if len(pool) == 0 {
+			active = applyRoutingSchedule(kind, active, time.Now())
+		}
+		// 余额不足且开启自动降级的 provider 放到最后
+		active = deprioritizeLowBalance(kind, active)
+		// 官方订阅临近限额时提前切换到 API Key 类 provider
+		active = deprioritizeNearLimitSubscriptions(active)
+		// 延迟或错误率超标的降级 provider 仅作兜底
+		active = deprioritizeDegraded(kind, active)
+		// 状态页声明事故的 provider 提前让位
+		active = deprioritizeIncidents(kind, active)
+
 		fmt.Printf("[INFO] 找到 %d 个可用的 provider（已过滤 %d 个）：", len(active), skippedCount)
 		for _, p := range active {
 			fmt.Printf("%s ", p.Name)
@@ -215,8 +416,20 @@ func (prs *ProviderRelayService) proxyHandler(kind string, endpoint string) gin.
 
 		query := flattenQuery(c.Request.URL.Query())
 		clientHeaders := cloneHeaders(c.Request.Header)
+		delete(clientHeaders, relayTargetHeader)
+		delete(clientHeaders, relayProbeTokenHeader)
+		requestID := newRelayRequestID()
+		c.Set(relayRequestIDKey, requestID)
+		c.Set(relaySessionIDKey, relaySessionID(kind, clientHeaders, bodyBytes))
 
 		var lastErr error
+		trace := startRelayTrace(kind, endpoint, requestID, c.GetString(relaySessionIDKey), c.GetHeader("traceparent"))
+		if trace != nil {
+			c.Set(relayTraceKey, trace)
+			defer func() {
+				trace.finish(c.Writer.Status(), lastErr)
+			}()
+		}
 		attemptCount := 0
 		for i, provider := range active {
 			attemptCount++
@@ -224,7 +437,11 @@ func (prs *ProviderRelayService) proxyHandler(kind string, endpoint string) gin.
 			effectiveModel := provider.GetEffectiveModel(requestedModel)
 
 			currentBodyBytes := bodyBytes
-			if effectiveModel != requestedModel && requestedModel != "" {
+			currentEndpoint := endpoint
+			if effectiveModel != requestedModel && requestedModel != "" && kind == "gemini" {
+				fmt.Printf("[INFO]   Provider %s 映射模型: %s -> %s\n", provider.Name, requestedModel, effectiveModel)
+				currentEndpoint = replaceGeminiPathModel(endpoint, effectiveModel)
+			} else if effectiveModel != requestedModel && requestedModel != "" {
 				fmt.Printf("[INFO]   Provider %s 映射模型: %s -> %s\n", provider.Name, requestedModel, effectiveModel)
 
 				modifiedBody, err := ReplaceModelInRequestBody(bodyBytes, effectiveModel)
@@ -236,17 +453,28 @@ func (prs *ProviderRelayService) proxyHandler(kind string, endpoint string) gin.
 				currentBodyBytes = modifiedBody
 			}
 
+			currentBodyBytes = adaptBodyForProvider(kind, provider, currentBodyBytes)
+
 			fmt.Printf("[INFO]   [%d/%d] Provider: %s | Model: %s\n",
 				i+1, len(active), provider.Name, effectiveModel)
 
 			startTime := time.Now()
-			ok, err := prs.forwardRequest(c, kind, provider, endpoint, query, clientHeaders, currentBodyBytes, isStream, effectiveModel)
+			ok, err := prs.forwardRequest(c, kind, provider, currentEndpoint, query, clientHeaders, currentBodyBytes, isStream, effectiveModel, requestedModel)
 			duration := time.Since(startTime)
 
 			if ok {
 				fmt.Printf("[INFO]   ✓ 成功: %s | 耗时: %.2fs\n", provider.Name, duration.Seconds())
+				if prs.blacklistService != nil {
+					prs.blacklistService.RecordSuccess(scope, provider.Name, requestID)
+				}
+				if target == "" && scope == kind {
+					markPlatformUp(kind)
+				}
 				return
 			}
+			if prs.blacklistService != nil && isProviderFault(err) {
+				prs.blacklistService.RecordFailure(scope, provider.Name, requestID)
+			}
 
 			errorMsg := "未知错误"
 			if err != nil {
@@ -255,12 +483,26 @@ func (prs *ProviderRelayService) proxyHandler(kind string, endpoint string) gin.
 			fmt.Printf("[WARN]   ✗ 失败: %s | 错误: %s | 耗时: %.2fs\n",
 				provider.Name, errorMsg, duration.Seconds())
 			lastErr = err
+
+			if i+1 < len(active) {
+				recordProviderEvent(ProviderEvent{
+					Platform:       scope,
+					EventType:      ProviderEventFailover,
+					Provider:       provider.Name,
+					TargetProvider: active[i+1].Name,
+					Reason:         errorMsg,
+					RequestID:      requestID,
+				})
+			}
 		}
 
 		message := fmt.Sprintf("所有 %d 个 provider 均失败（共尝试 %d 次）", len(active), attemptCount)
 		if lastErr != nil {
 			message = fmt.Sprintf("%s: %s", message, lastErr.Error())
 		}
+		if target == "" && scope == kind && isProviderFault(lastErr) {
+			markPlatformDown(kind, active, message)
+		}
 		xlog.Error("all is error")
 		c.JSON(http.StatusBadRequest, gin.H{"error": message})
 	}
@@ -276,26 +518,57 @@ func (prs *ProviderRelayService) forwardRequest(
 	bodyBytes []byte,
 	isStream bool,
 	model string,
-) (bool, error) {
+	requestedModel string,
+) (ok bool, err error) {
 	targetURL := joinURL(provider.APIURL, endpoint)
 	headers := cloneMap(clientHeaders)
-	headers["Authorization"] = fmt.Sprintf("Bearer %s", provider.APIKey)
+	provider, err = applySubscriptionCredential(provider, headers)
+	if err != nil {
+		return false, err
+	}
+	applyAuthHeaders(headers, provider)
 	if _, ok := headers["Accept"]; !ok {
 		headers["Accept"] = "application/json"
 	}
 
+	platform := c.GetString(relayScopeKey)
+	if platform == "" {
+		platform = kind
+	}
 	requestLog := &ReqeustLog{
-		Platform: kind,
-		Provider: provider.Name,
-		Model:    model,
-		IsStream: isStream,
+		RequestID: c.GetString(relayRequestIDKey),
+		SessionID: c.GetString(relaySessionIDKey),
+		Platform:  platform,
+		Provider:  provider.Name,
+		Model:     model,
+		IsStream:  isStream,
+	}
+	if requestedModel != model {
+		requestLog.RequestedModel = requestedModel
 	}
+	capture := newBodyCapture(kind, bodyBytes)
+	skills := detectSkillInvocations(kind, bodyBytes)
+	prompts := detectPromptInvocations(kind, bodyBytes)
 	start := time.Now()
 	defer func() {
 		requestLog.DurationSec = time.Since(start).Seconds()
-		if _, err := xdb.New("request_log").Insert(xdb.Record{
+		if err != nil {
+			requestLog.ErrorMessage = truncateProbeMessage(err.Error())
+		}
+		if trace, exists := c.Get(relayTraceKey); exists {
+			trace.(*relayTrace).recordAttempt(requestLog, start, err)
+		}
+		// 指定 provider 的探测请求单独记录，不计入真实用量统计，但仍计入预算
+		if c.GetBool(relayProbeKey) {
+			recordProbeUsage(requestLog)
+			return
+		}
+		logID, insertErr := xdb.New(activeRequestLogTable()).Insert(xdb.Record{
+			"request_id":          requestLog.RequestID,
+			"session_id":          requestLog.SessionID,
 			"platform":            requestLog.Platform,
 			"model":               requestLog.Model,
+			"requested_model":     requestLog.RequestedModel,
 			"provider":            requestLog.Provider,
 			"http_code":           requestLog.HttpCode,
 			"input_tokens":        requestLog.InputTokens,
@@ -305,8 +578,23 @@ func (prs *ProviderRelayService) forwardRequest(
 			"reasoning_tokens":    requestLog.ReasoningTokens,
 			"is_stream":           boolToInt(requestLog.IsStream),
 			"duration_sec":        requestLog.DurationSec,
-		}); err != nil {
-			fmt.Printf("写入 request_log 失败: %v\n", err)
+			"first_token_sec":     requestLog.FirstTokenSec,
+			"error_message":       requestLog.ErrorMessage,
+		})
+		if insertErr != nil {
+			fmt.Printf("写入 request_log 失败: %v\n", insertErr)
+			return
+		}
+		requestLog.ID = logID
+		publishRequestLog(*requestLog)
+		if err == nil && len(skills) > 0 {
+			recordSkillUsage(logID, requestLog, skills)
+		}
+		if err == nil && len(prompts) > 0 {
+			recordPromptUsage(logID, requestLog, prompts)
+		}
+		if capture != nil {
+			capture.save(logID, requestLog, provider.APIKey, strings.TrimPrefix(headers["Authorization"], "Bearer "), headers["X-Api-Key"])
 		}
 	}()
 
@@ -326,19 +614,82 @@ func (prs *ProviderRelayService) forwardRequest(
 		return false, fmt.Errorf("empty response")
 	}
 
-	if resp.Error() != nil {
-		return false, resp.Error()
-	}
-
 	status := resp.StatusCode()
 	requestLog.HttpCode = status
+	if status < http.StatusOK || status >= http.StatusMultipleChoices {
+		body := resp.Bytes()
+		if capture != nil {
+			capture.appendResponse(body)
+		}
+		message := gjson.GetBytes(body, "error.message").String()
+		if message == "" {
+			message = strings.TrimSpace(string(body))
+		}
+		return false, &upstreamStatusError{status: status, message: message}
+	}
 
-	if status >= http.StatusOK && status < http.StatusMultipleChoices {
-		_, copyErr := resp.ToHttpResponseWriter(c.Writer, ReqeustLogHook(c, kind, requestLog))
-		return copyErr == nil, copyErr
+	hook := ReqeustLogHook(c, kind, requestLog)
+	if kind == "gemini" {
+		hook = geminiUsageHook(requestLog)
 	}
+	_, copyErr := resp.ToHttpResponseWriter(c.Writer, func(data []byte) (bool, []byte) {
+		// 首个响应分片到达的时间即 TTFT
+		if requestLog.FirstTokenSec == 0 {
+			requestLog.FirstTokenSec = time.Since(start).Seconds()
+		}
+		if capture != nil {
+			capture.appendResponse(data)
+		}
+		return hook(data)
+	})
+	return copyErr == nil, copyErr
+}
 
-	return false, fmt.Errorf("upstream status %d", status)
+// upstreamStatusError 上游返回非 2xx 状态码
+type upstreamStatusError struct {
+	status  int
+	message string
+}
+
+func (e *upstreamStatusError) Error() string {
+	if e.message == "" {
+		return fmt.Sprintf("upstream status %d", e.status)
+	}
+	return fmt.Sprintf("upstream status %d: %s", e.status, truncateProbeMessage(e.message))
+}
+
+// isProviderFault 判断失败是否应计入 provider 的健康状态：请求本身有误（4xx 参数类错误）不算 provider 的问题
+func isProviderFault(err error) bool {
+	var statusErr *upstreamStatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.status {
+		case http.StatusBadRequest, http.StatusNotFound, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+			return false
+		}
+	}
+	return true
+}
+
+// applyAuthHeaders 按 provider 的鉴权方式写入上游鉴权头。
+// 客户端带来的鉴权头（如 Claude Code、Gemini CLI 的占位 Key）不论大小写一律移除，避免与真实 Key 一起发给上游
+func applyAuthHeaders(headers map[string]string, provider Provider) {
+	for key := range headers {
+		switch http.CanonicalHeaderKey(key) {
+		case "Authorization", "X-Api-Key", "X-Goog-Api-Key":
+			delete(headers, key)
+		}
+	}
+	switch strings.ToLower(strings.TrimSpace(provider.AuthStyle)) {
+	case authStyleAPIKey:
+		headers["X-Api-Key"] = provider.APIKey
+	case authStyleGoogKey:
+		headers["X-Goog-Api-Key"] = provider.APIKey
+	case authStyleBothKey:
+		headers["Authorization"] = fmt.Sprintf("Bearer %s", provider.APIKey)
+		headers["X-Api-Key"] = provider.APIKey
+	default:
+		headers["Authorization"] = fmt.Sprintf("Bearer %s", provider.APIKey)
+	}
 }
 
 func cloneHeaders(header http.Header) map[string]string {
@@ -382,14 +733,14 @@ func boolToInt(b bool) int {
 	return 0
 }
 
-func ensureRequestLogColumn(db *sql.DB, column string, definition string) error {
-	query := fmt.Sprintf("SELECT COUNT(*) FROM pragma_table_info('request_log') WHERE name = '%s'", column)
+func ensureRequestLogColumn(db *sql.DB, table string, column string, definition string) error {
+	query := fmt.Sprintf("SELECT COUNT(*) FROM pragma_table_info('%s') WHERE name = '%s'", table, column)
 	var count int
 	if err := db.QueryRow(query).Scan(&count); err != nil {
 		return err
 	}
 	if count == 0 {
-		alter := fmt.Sprintf("ALTER TABLE request_log ADD COLUMN %s %s", column, definition)
+		alter := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)
 		if _, err := db.Exec(alter); err != nil {
 			return err
 		}
@@ -397,6 +748,34 @@ func ensureRequestLogColumn(db *sql.DB, column string, definition string) error
 	return nil
 }
 
+// ensureDatabaseTables 创建或迁移 default 连接上的全部表
+func ensureDatabaseTables() error {
+	tables := []struct {
+		name   string
+		ensure func() error
+	}{
+		{"request_log", ensureRequestLogTable},
+		{"speed_test_result", ensureSpeedTestTable},
+		{"provider_history", ensureProviderHistoryTable},
+		{"provider_event", ensureProviderEventTable},
+		{"probe_log", ensureProbeLogTable},
+		{"request_body", ensureRequestBodyTable},
+		{"model_pricing", ensureModelPricingTable},
+		{"skill_index", ensureSkillIndexTable},
+		{"skill_usage", ensureSkillUsageTable},
+		{"mcp_log", ensureMCPLogTable},
+		{"mcp_traffic", ensureMCPTrafficTable},
+		{"prompt_revision", ensurePromptRevisionTable},
+		{"prompt_usage", ensurePromptUsageTable},
+	}
+	for _, table := range tables {
+		if err := table.ensure(); err != nil {
+			return fmt.Errorf("初始化 %s 表失败: %w", table.name, err)
+		}
+	}
+	return nil
+}
+
 func ensureRequestLogTable() error {
 	db, err := xdb.DB("default")
 	if err != nil {
@@ -412,8 +791,20 @@ func ensureRequestLogTableWithDB(db *sql.DB) error {
 	if _, err := db.Exec("PRAGMA journal_mode=WAL"); err != nil {
 		return err
 	}
+	if err := ensureLogTableSchema(db, "request_log"); err != nil {
+		return err
+	}
+	if table := activeRequestLogTable(); table != "request_log" {
+		if err := ensureLogTableSchema(db, table); err != nil {
+			return err
+		}
+	}
+	return migrateLogData(db)
+}
 
-	const createTableSQL = `CREATE TABLE IF NOT EXISTS request_log (
+// ensureLogTableSchema 创建/迁移日志表结构，request_log 与演示模式的 demo_request_log 共用
+func ensureLogTableSchema(db *sql.DB, table string) error {
+	createTableSQL := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		platform TEXT,
 		model TEXT,
@@ -427,21 +818,42 @@ func ensureRequestLogTableWithDB(db *sql.DB) error {
 		is_stream INTEGER DEFAULT 0,
 		duration_sec REAL DEFAULT 0,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	)`
+	)`, table)
 
 	if _, err := db.Exec(createTableSQL); err != nil {
 		return err
 	}
 
-	if err := ensureRequestLogColumn(db, "created_at", "DATETIME DEFAULT CURRENT_TIMESTAMP"); err != nil {
+	if err := ensureRequestLogColumn(db, table, "created_at", "DATETIME DEFAULT CURRENT_TIMESTAMP"); err != nil {
+		return err
+	}
+	if err := ensureRequestLogColumn(db, table, "is_stream", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := ensureRequestLogColumn(db, table, "duration_sec", "REAL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := ensureRequestLogColumn(db, table, "requested_model", "TEXT"); err != nil {
+		return err
+	}
+	if err := ensureRequestLogColumn(db, table, "request_id", "TEXT"); err != nil {
 		return err
 	}
-	if err := ensureRequestLogColumn(db, "is_stream", "INTEGER DEFAULT 0"); err != nil {
+	if err := ensureRequestLogColumn(db, table, "first_token_sec", "REAL DEFAULT 0"); err != nil {
 		return err
 	}
-	if err := ensureRequestLogColumn(db, "duration_sec", "REAL DEFAULT 0"); err != nil {
+	if err := ensureRequestLogColumn(db, table, "session_id", "TEXT"); err != nil {
 		return err
 	}
+	if err := ensureRequestLogColumn(db, table, "error_message", "TEXT"); err != nil {
+		return err
+	}
+	for _, column := range []string{"created_at", "session_id"} {
+		indexSQL := fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_%s ON %s (%s)", table, column, table, column)
+		if _, err := db.Exec(indexSQL); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
@@ -460,6 +872,36 @@ func ReqeustLogHook(c *gin.Context, kind string, usage *ReqeustLog) func(data []
 	}
 }
 
+// geminiUsageHook Gemini 的 usageMetadata 是累计值，后出现的覆盖先出现的：
+// SSE 流（alt=sse）逐行解析；普通响应与不带 alt=sse 的流式响应（JSON 数组）在响应体完整后解析
+func geminiUsageHook(usage *ReqeustLog) func(data []byte) (bool, []byte) {
+	var body []byte
+	sse := false
+	return func(data []byte) (bool, []byte) {
+		if len(body) == 0 && strings.HasPrefix(strings.TrimSpace(string(data)), "data:") {
+			sse = true
+		}
+		if sse {
+			parseEventPayload(strings.TrimSpace(string(data)), GeminiParseTokenUsageFromResponse, usage)
+			return true, data
+		}
+		body = append(body, data...)
+		if !gjson.ValidBytes(body) {
+			return true, data
+		}
+		result := gjson.ParseBytes(body)
+		if result.IsArray() {
+			result.ForEach(func(_, chunk gjson.Result) bool {
+				GeminiParseTokenUsageFromResponse(chunk.Raw, usage)
+				return true
+			})
+		} else {
+			GeminiParseTokenUsageFromResponse(result.Raw, usage)
+		}
+		return true, data
+	}
+}
+
 func parseEventPayload(payload string, parser func(string, *ReqeustLog), usage *ReqeustLog) {
 	lines := strings.Split(payload, "\n")
 	for _, line := range lines {
@@ -472,9 +914,12 @@ func parseEventPayload(payload string, parser func(string, *ReqeustLog), usage *
 
 type ReqeustLog struct {
 	ID                int64   `json:"id"`
+	RequestID         string  `json:"request_id,omitempty"`
+	SessionID         string  `json:"session_id,omitempty"`
 	Platform          string  `json:"platform"` // claude code or codex
 	Model             string  `json:"model"`
-	Provider          string  `json:"provider"` // provider name
+	RequestedModel    string  `json:"requested_model,omitempty"` // 客户端原始请求的模型（被映射或固定时）
+	Provider          string  `json:"provider"`                  // provider name
 	HttpCode          int     `json:"http_code"`
 	InputTokens       int     `json:"input_tokens"`
 	OutputTokens      int     `json:"output_tokens"`
@@ -483,6 +928,8 @@ type ReqeustLog struct {
 	ReasoningTokens   int     `json:"reasoning_tokens"`
 	IsStream          bool    `json:"is_stream"`
 	DurationSec       float64 `json:"duration_sec"`
+	FirstTokenSec     float64 `json:"first_token_sec"` // 首个响应分片到达耗时（TTFT）
+	ErrorMessage      string  `json:"error_message,omitempty"`
 	CreatedAt         string  `json:"created_at"`
 	InputCost         float64 `json:"input_cost"`
 	OutputCost        float64 `json:"output_cost"`
@@ -492,6 +939,8 @@ type ReqeustLog struct {
 	Ephemeral1hCost   float64 `json:"ephemeral_1h_cost"`
 	TotalCost         float64 `json:"total_cost"`
 	HasPricing        bool    `json:"has_pricing"`
+	// 提示词缓存节省的费用
+	CacheSavings float64 `json:"cache_savings"`
 }
 
 // claude code usage parser
@@ -507,13 +956,37 @@ func ClaudeCodeParseTokenUsageFromResponse(data string, usage *ReqeustLog) {
 
 // codex usage parser
 func CodexParseTokenUsageFromResponse(data string, usage *ReqeustLog) {
-	usage.InputTokens += int(gjson.Get(data, "response.usage.input_tokens").Int())
+	// Codex 的 input_tokens 包含缓存命中的部分，这里只记录未命中缓存的输入，避免缓存 token 被重复计费
+	inputTokens := int(gjson.Get(data, "response.usage.input_tokens").Int())
+	cachedTokens := int(gjson.Get(data, "response.usage.input_tokens_details.cached_tokens").Int())
+	if cachedTokens > inputTokens {
+		cachedTokens = inputTokens
+	}
+	usage.InputTokens += inputTokens - cachedTokens
 	usage.OutputTokens += int(gjson.Get(data, "response.usage.output_tokens").Int())
-	usage.CacheReadTokens += int(gjson.Get(data, "response.usage.input_tokens_details.cached_tokens").Int())
+	usage.CacheReadTokens += cachedTokens
 	usage.ReasoningTokens += int(gjson.Get(data, "response.usage.output_tokens_details.reasoning_tokens").Int())
 	fmt.Println("data ---->", data, fmt.Sprintf("%v", usage))
 }
 
+// gemini usage parser：usageMetadata 为累计值，直接覆盖；promptTokenCount 包含缓存命中的部分，思考 token 按输出计费
+func GeminiParseTokenUsageFromResponse(data string, usage *ReqeustLog) {
+	metadata := gjson.Get(data, "usageMetadata")
+	if !metadata.Exists() {
+		return
+	}
+	promptTokens := int(metadata.Get("promptTokenCount").Int())
+	cachedTokens := int(metadata.Get("cachedContentTokenCount").Int())
+	if cachedTokens > promptTokens {
+		cachedTokens = promptTokens
+	}
+	thoughtsTokens := int(metadata.Get("thoughtsTokenCount").Int())
+	usage.InputTokens = promptTokens - cachedTokens
+	usage.CacheReadTokens = cachedTokens
+	usage.OutputTokens = int(metadata.Get("candidatesTokenCount").Int()) + thoughtsTokens
+	usage.ReasoningTokens = thoughtsTokens
+}
+
 // ReplaceModelInRequestBody 替换请求体中的模型名
 // 使用 gjson + sjson 实现高性能 JSON 操作，避免完整反序列化
 func ReplaceModelInRequestBody(bodyBytes []byte, newModel string) ([]byte, error) {
@@ -2,8 +2,14 @@ package services
 
 import (
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/daodao97/xgo/xdb"
+	"github.com/gin-gonic/gin"
 	"github.com/tidwall/gjson"
 )
 
@@ -302,3 +308,173 @@ func BenchmarkReplaceModelInRequestBody(b *testing.B) {
 		_, _ = ReplaceModelInRequestBody(bodyBytes, "anthropic/claude-sonnet-4")
 	}
 }
+
+// ==================== 探测请求头测试 ====================
+
+func TestRelayTargetHeaderRequiresProbeToken(t *testing.T) {
+	useTestDB(t)
+	gin.SetMode(gin.TestMode)
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	newUpstream := func(name string) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get(relayProbeTokenHeader) != "" || r.Header.Get(relayTargetHeader) != "" {
+				t.Errorf("探测请求头不应转发给上游：%v", r.Header)
+			}
+			mu.Lock()
+			hits[name]++
+			mu.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"usage":{"input_tokens":1,"output_tokens":1}}`))
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+	primary, secondary := newUpstream("primary"), newUpstream("secondary")
+	ps := NewProviderService()
+	if err := ps.SaveProviders("claude", []Provider{
+		{ID: 1, Name: "primary", APIURL: primary.URL, APIKey: "k1", Enabled: true},
+		{ID: 2, Name: "secondary", APIURL: secondary.URL, APIKey: "k2", Enabled: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	router := gin.New()
+	(&ProviderRelayService{providerService: ps}).registerRoutes(router)
+	send := func(token string) {
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"claude-haiku-4-5-20251001","max_tokens":1}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(relayTargetHeader, "secondary")
+		if token != "" {
+			req.Header.Set(relayProbeTokenHeader, token)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("relay 返回 %d：%s", rec.Code, rec.Body.String())
+		}
+	}
+	countRows := func(table string) int64 {
+		count, err := xdb.New(table).Count()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return count
+	}
+
+	// 伪造或缺少密钥时忽略指定 provider，按正常流量路由并记入请求日志
+	send("forged")
+	send("")
+	if hits["primary"] != 2 || hits["secondary"] != 0 || countRows("request_log") != 2 || countRows(probeLogTable) != 0 {
+		t.Fatalf("无效密钥应按正常流量处理：hits=%v", hits)
+	}
+
+	send(relayProbeToken)
+	if hits["secondary"] != 1 || countRows(probeLogTable) != 1 || countRows("request_log") != 2 {
+		t.Fatalf("有效密钥应只发往指定 provider 并单独记录：hits=%v", hits)
+	}
+}
+
+func TestRelayReplacesClientAPIKey(t *testing.T) {
+	useTestDB(t)
+	gin.SetMode(gin.TestMode)
+	received := make(chan http.Header, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"usage":{"input_tokens":1,"output_tokens":1}}`))
+	}))
+	t.Cleanup(upstream.Close)
+	ps := NewProviderService()
+	if err := ps.SaveProviders("claude", []Provider{
+		{ID: 1, Name: "anthropic", APIURL: upstream.URL, APIKey: "sk-real", Enabled: true, AuthStyle: authStyleAPIKey},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	router := gin.New()
+	(&ProviderRelayService{providerService: ps}).registerRoutes(router)
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"claude-haiku-4-5-20251001","max_tokens":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Api-Key", "code-switch")
+	req.Header.Set("Authorization", "Bearer code-switch")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("relay 返回 %d：%s", rec.Code, rec.Body.String())
+	}
+	header := <-received
+	if got := header.Values("X-Api-Key"); len(got) != 1 || got[0] != "sk-real" {
+		t.Fatalf("上游应只收到 provider 的 Key：%v", got)
+	}
+	if got := header.Values("Authorization"); len(got) != 0 {
+		t.Fatalf("x-api-key 鉴权不应转发客户端的 Authorization：%v", got)
+	}
+}
+
+func TestGeminiRelayRecordsUsage(t *testing.T) {
+	useTestDB(t)
+	gin.SetMode(gin.TestMode)
+	paths := make(chan string, 2)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths <- r.URL.Path
+		if strings.HasSuffix(r.URL.Path, ":streamGenerateContent") {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("data: {\"candidates\":[],\"usageMetadata\":{\"promptTokenCount\":120,\"candidatesTokenCount\":5}}\n\n"))
+			w.(http.Flusher).Flush()
+			w.Write([]byte("data: {\"candidates\":[],\"usageMetadata\":{\"promptTokenCount\":120,\"candidatesTokenCount\":40,\"thoughtsTokenCount\":10}}\n\n"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"candidates":[{"content":{"parts":[{"text":"pong"}]}}],"usageMetadata":{"promptTokenCount":1000,"cachedContentTokenCount":200,"candidatesTokenCount":50,"totalTokenCount":1050}}`))
+	}))
+	t.Cleanup(upstream.Close)
+	ps := NewProviderService()
+	if err := ps.SaveProviders("gemini", []Provider{{
+		ID: 1, Name: "google", APIURL: upstream.URL, APIKey: "k", Enabled: true, AuthStyle: authStyleGoogKey,
+		SupportedModels: map[string]bool{"gemini-2.5-pro": true, "gemini-2.5-flash": true},
+		ModelMapping:    map[string]string{"gemini-pro-latest": "gemini-2.5-pro"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	router := gin.New()
+	(&ProviderRelayService{providerService: ps}).registerRoutes(router)
+	send := func(path string) {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"contents":[{"parts":[{"text":"ping"}]}]}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("relay 返回 %d：%s", rec.Code, rec.Body.String())
+		}
+	}
+
+	send("/v1beta/models/gemini-pro-latest:generateContent")
+	send("/v1beta/models/gemini-2.5-flash:streamGenerateContent?alt=sse")
+	if got := <-paths; got != "/v1beta/models/gemini-2.5-pro:generateContent" {
+		t.Fatalf("模型映射应改写路径：%s", got)
+	}
+	<-paths
+
+	records, err := xdb.New("request_log").Selects(xdb.OrderByAsc("id"))
+	if err != nil || len(records) != 2 {
+		t.Fatalf("请求日志：%v %v", records, err)
+	}
+	first := requestLogFromRecord(records[0])
+	if first.Platform != "gemini" || first.Model != "gemini-2.5-pro" || first.RequestedModel != "gemini-pro-latest" || first.IsStream {
+		t.Fatalf("非流式请求日志：%+v", first)
+	}
+	if first.InputTokens != 800 || first.CacheReadTokens != 200 || first.OutputTokens != 50 {
+		t.Fatalf("非流式用量：%+v", first)
+	}
+	second := requestLogFromRecord(records[1])
+	if second.Model != "gemini-2.5-flash" || !second.IsStream {
+		t.Fatalf("流式请求日志：%+v", second)
+	}
+	if second.InputTokens != 120 || second.OutputTokens != 50 || second.ReasoningTokens != 10 {
+		t.Fatalf("流式用量应取最后一次累计值：%+v", second)
+	}
+	ls := NewLogService()
+	ls.decorateCost(&first)
+	if first.TotalCost <= 0 {
+		t.Fatalf("Gemini 请求应有费用：%+v", first)
+	}
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -32,6 +33,27 @@ type Provider struct {
 	// 使用 omitempty 确保零值不序列化，向后兼容
 	Level int `json:"level,omitempty"`
 
+	// 鉴权方式 - bearer（默认）/ x-api-key / both，兼容不同上游的鉴权要求
+	AuthStyle string `json:"authStyle,omitempty"`
+
+	// 自定义环境变量 - 直连应用时合并写入 settings.json / config.toml，移除时一并清理
+	Env map[string]string `json:"env,omitempty"`
+
+	// 余额查询配置 - 聚合类上游通常提供余额接口，配置后由 BalanceService 定时轮询
+	Balance *BalanceQuery `json:"balance,omitempty"`
+
+	// 官方订阅 - claude / chatgpt，未填写 API Key 时使用本地 OAuth 登录凭据，临近限额时自动让位
+	Subscription string `json:"subscription,omitempty"`
+
+	// 固定模型 - 忽略客户端请求的模型，始终使用该模型（适用于单模型的第三方端点）
+	ForceModel string `json:"forceModel,omitempty"`
+
+	// 观察模式 - 只参与测速/健康检查，不接收真实流量，用于评估新供应商
+	Observer bool `json:"observer,omitempty"`
+
+	// 状态页 - Statuspage JSON 或 RSS/Atom 地址，声明事故时提前降低优先级；官方端点留空即使用内置状态页
+	StatusPageURL string `json:"statusPageUrl,omitempty"`
+
 	// 内部字段：配置验证错误（不持久化）
 	configErrors []string `json:"-"`
 }
@@ -52,20 +74,18 @@ func (ps *ProviderService) Start() error { return nil }
 func (ps *ProviderService) Stop() error  { return nil }
 
 func providerFilePath(kind string) (string, error) {
-	home, err := os.UserHomeDir()
+	dir, err := profileDir()
 	if err != nil {
 		return "", err
 	}
-	dir := filepath.Join(home, ".code-switch")
-	if err := os.MkdirAll(dir, 0o755); err != nil {
-		return "", err
-	}
 	var filename string
 	switch strings.ToLower(kind) {
 	case "claude", "claude-code", "claude_code":
 		filename = "claude-code.json"
 	case "codex":
 		filename = "codex.json"
+	case "gemini":
+		filename = "gemini-cli.json"
 	default:
 		return "", fmt.Errorf("unknown provider type: %s", kind)
 	}
@@ -73,6 +93,22 @@ func providerFilePath(kind string) (string, error) {
 }
 
 func (ps *ProviderService) SaveProviders(kind string, providers []Provider) error {
+	return ps.saveProviders(kind, providers, "save")
+}
+
+// ListProviders 界面展示用的 provider 列表，演示模式下返回演示 provider；relay 等服务始终使用 LoadProviders
+func (ps *ProviderService) ListProviders(kind string) ([]Provider, error) {
+	if isDemoMode() {
+		return demoProviders(kind), nil
+	}
+	return ps.LoadProviders(kind)
+}
+
+func (ps *ProviderService) saveProviders(kind string, providers []Provider, action string) error {
+	// 演示模式下界面展示的是演示 provider，保存会覆盖真实配置
+	if isDemoMode() {
+		return errors.New("演示模式下不能修改 provider，请先关闭演示模式")
+	}
 	ps.mu.Lock()
 	defer ps.mu.Unlock()
 
@@ -89,6 +125,7 @@ func (ps *ProviderService) SaveProviders(kind string, providers []Provider) erro
 	for _, p := range existingProviders {
 		nameByID[p.ID] = p.Name
 	}
+	archivedNames := archivedNameSet(kind)
 
 	// 验证每个 provider 的配置
 	validationErrors := make([]string, 0)
@@ -98,6 +135,13 @@ func (ps *ProviderService) SaveProviders(kind string, providers []Provider) erro
 			return fmt.Errorf("provider id %d 的 name 不可修改", p.ID)
 		}
 
+		// 规则 1.1：新 provider 不能与已归档的 provider 重名，否则历史统计无法区分
+		if _, ok := nameByID[p.ID]; !ok {
+			if _, archived := archivedNames[strings.ToLower(p.Name)]; archived {
+				return fmt.Errorf("provider %s 与已归档的 provider 重名，请先恢复或更换名称", p.Name)
+			}
+		}
+
 		// 规则 2：验证模型配置
 		if errs := p.ValidateConfiguration(); len(errs) > 0 {
 			for _, errMsg := range errs {
@@ -120,7 +164,11 @@ func (ps *ProviderService) SaveProviders(kind string, providers []Provider) erro
 	if err := os.WriteFile(tmp, data, 0o644); err != nil {
 		return err
 	}
-	return os.Rename(tmp, path)
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	recordProviderHistory(kind, action, existingProviders, providers)
+	return nil
 }
 
 func (ps *ProviderService) LoadProviders(kind string) ([]Provider, error) {
@@ -152,6 +200,11 @@ func (ps *ProviderService) LoadProviders(kind string) ([]Provider, error) {
 // 支持条件：1) 模型在 SupportedModels 中（精确或通配符匹配）
 //          2) 模型在 ModelMapping 的 key 中（精确或通配符匹配）
 func (p *Provider) IsModelSupported(modelName string) bool {
+	// 配置了固定模型时，任何请求都会被改写为该模型
+	if strings.TrimSpace(p.ForceModel) != "" {
+		return true
+	}
+
 	// 向后兼容：如果未配置白名单和映射，假设支持所有模型
 	if (p.SupportedModels == nil || len(p.SupportedModels) == 0) &&
 		(p.ModelMapping == nil || len(p.ModelMapping) == 0) {
@@ -193,6 +246,9 @@ func (p *Provider) IsModelSupported(modelName string) bool {
 // GetEffectiveModel 获取实际应该使用的模型名
 // 如果存在映射（精确或通配符），返回映射后的模型名；否则返回原模型名
 func (p *Provider) GetEffectiveModel(requestedModel string) string {
+	if forced := strings.TrimSpace(p.ForceModel); forced != "" {
+		return forced
+	}
 	if p.ModelMapping == nil || len(p.ModelMapping) == 0 {
 		return requestedModel
 	}
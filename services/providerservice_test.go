@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"sort"
 	"testing"
+
+	"github.com/daodao97/xgo/xdb"
 )
 
 // ==================== 通配符匹配测试 ====================
@@ -737,3 +739,88 @@ func findSubstring(s, substr string) bool {
 	}
 	return false
 }
+
+func TestGeminiProviderHistoryRollback(t *testing.T) {
+	useTestDB(t)
+	ps := NewProviderService()
+	original := []Provider{{ID: 1, Name: "relay", APIURL: "https://a.example.com", APIKey: "k1", Enabled: true}}
+	if err := ps.SaveProviders("gemini", original); err != nil {
+		t.Fatal(err)
+	}
+	edited := []Provider{{ID: 1, Name: "relay", APIURL: "https://b.example.com", APIKey: "k2", Enabled: false}}
+	if err := ps.SaveProviders("gemini", edited); err != nil {
+		t.Fatal(err)
+	}
+	history, err := ps.History("gemini", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].Platform != "gemini" {
+		t.Fatalf("gemini 历史记录：%+v", history)
+	}
+	if err := ps.Rollback("gemini", history[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := ps.LoadProviders("gemini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := json.Marshal(restored)
+	want, _ := json.Marshal(original)
+	if string(got) != string(want) {
+		t.Fatalf("回滚后配置：%s，期望 %s", got, want)
+	}
+	if claude, err := ps.History("claude", 10); err != nil || len(claude) != 0 {
+		t.Fatalf("gemini 的变更不应出现在 claude 历史中：%+v %v", claude, err)
+	}
+}
+
+func TestProviderRollbackRestoresSnapshot(t *testing.T) {
+	useTestDB(t)
+	ps := NewProviderService()
+	snapshot := []Provider{
+		{ID: 1, Name: "relay", APIURL: "https://a.example.com", APIKey: "k1", Enabled: true, Level: 1,
+			SupportedModels: map[string]bool{"anthropic/claude-*": true},
+			ModelMapping:    map[string]string{"claude-*": "anthropic/claude-*"}},
+		{ID: 2, Name: "backup", APIURL: "https://backup.example.com", APIKey: "k2", Enabled: true, Level: 2},
+	}
+	if err := ps.SaveProviders("claude", snapshot); err != nil {
+		t.Fatal(err)
+	}
+	edited := []Provider{
+		{ID: 1, Name: "relay", APIURL: "https://b.example.com", APIKey: "k3", Enabled: false, Level: 3,
+			SupportedModels: map[string]bool{"other/claude-*": true},
+			ModelMapping:    map[string]string{"claude-*": "other/claude-*"}},
+	}
+	if err := ps.SaveProviders("claude", edited); err != nil {
+		t.Fatal(err)
+	}
+	history, err := ps.History("claude", 10)
+	if err != nil || len(history) != 2 {
+		t.Fatalf("历史记录：%+v %v", history, err)
+	}
+	if err := ps.Rollback("claude", history[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := ps.LoadProviders("claude")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := json.Marshal(restored)
+	want, _ := json.Marshal(snapshot)
+	if string(got) != string(want) {
+		t.Fatalf("回滚后配置：%s，期望 %s", got, want)
+	}
+	record, err := xdb.New(providerHistoryTable).First(xdb.WhereEq("id", history[0].ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored := record.GetString("before_snapshot"); stored != string(got) {
+		t.Fatalf("回滚结果应与历史快照一致：%s，快照 %s", got, stored)
+	}
+	after, err := ps.History("claude", 10)
+	if err != nil || len(after) != 3 || after[0].Actor == "" {
+		t.Fatalf("回滚本身应记录一条历史：%+v %v", after, err)
+	}
+}
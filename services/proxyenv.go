@@ -0,0 +1,228 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// 环境变量模式：不改动任何 CLI 的配置文件，通过 shell 环境把 CLI 指向本地中转。
+// 配置文件中写有同名变量时（如 Claude settings.json 的 env）以配置文件为准
+const (
+	ProxyEnvFormatShell      = "sh"
+	ProxyEnvFormatFish       = "fish"
+	ProxyEnvFormatPowerShell = "powershell"
+	ProxyEnvFormatLaunchd    = "launchd"
+
+	proxyEnvLaunchdLabel = "com.codeswitch.proxy-env"
+	proxyEnvMarker       = "code-switch proxy"
+)
+
+// ProxyEnvVar 一个需要导出的环境变量
+type ProxyEnvVar struct {
+	Platform string `json:"platform"`
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+}
+
+// ProxyEnvSnippet 按格式生成的片段。Target 为建议放置的位置，Hint 为生效方式
+type ProxyEnvSnippet struct {
+	Format  string        `json:"format"`
+	Content string        `json:"content"`
+	Target  string        `json:"target"`
+	Hint    string        `json:"hint"`
+	Vars    []ProxyEnvVar `json:"vars"`
+}
+
+type ProxyEnvService struct {
+	relayAddr string
+}
+
+func NewProxyEnvService(relayAddr string) *ProxyEnvService {
+	return &ProxyEnvService{relayAddr: relayAddr}
+}
+
+// ProxyEnvVars 返回各平台经由中转所需的环境变量，platforms 为空时包含 Claude Code、Codex 与 Gemini CLI
+func (pes *ProxyEnvService) ProxyEnvVars(platforms []string) ([]ProxyEnvVar, error) {
+	if len(platforms) == 0 {
+		platforms = []string{"claude", "codex", "gemini"}
+	}
+	baseURL := relayBaseURL(pes.relayAddr)
+	vars := make([]ProxyEnvVar, 0, len(platforms)*2)
+	for _, platform := range platforms {
+		switch strings.ToLower(strings.TrimSpace(platform)) {
+		case "claude", "claude-code", "claude_code":
+			vars = append(vars,
+				ProxyEnvVar{Platform: "claude", Key: "ANTHROPIC_BASE_URL", Value: baseURL},
+				ProxyEnvVar{Platform: "claude", Key: "ANTHROPIC_AUTH_TOKEN", Value: claudeAuthTokenValue},
+			)
+		case "codex":
+			// 内置 openai provider 读取 OPENAI_BASE_URL，请求 <base>/responses 正好落在中转的 /responses 上
+			vars = append(vars,
+				ProxyEnvVar{Platform: "codex", Key: "OPENAI_BASE_URL", Value: baseURL},
+				ProxyEnvVar{Platform: "codex", Key: codexEnvKey, Value: codexTokenValue},
+			)
+		case "gemini":
+			vars = append(vars,
+				ProxyEnvVar{Platform: "gemini", Key: geminiBaseURLEnv, Value: baseURL},
+				ProxyEnvVar{Platform: "gemini", Key: geminiAPIKeyEnv, Value: geminiTokenValue},
+			)
+		default:
+			return nil, fmt.Errorf("不支持的平台: %s", platform)
+		}
+	}
+	return vars, nil
+}
+
+// ProxyEnvSnippet 生成 shell、fish、PowerShell 片段或 macOS 登录时执行 launchctl setenv 的 LaunchAgent
+func (pes *ProxyEnvService) ProxyEnvSnippet(format string, platforms []string) (ProxyEnvSnippet, error) {
+	vars, err := pes.ProxyEnvVars(platforms)
+	if err != nil {
+		return ProxyEnvSnippet{}, err
+	}
+	snippet := ProxyEnvSnippet{Format: format, Vars: vars}
+	var lines []string
+	switch format {
+	case ProxyEnvFormatShell:
+		lines = append(lines, "# "+proxyEnvMarker)
+		for _, v := range vars {
+			lines = append(lines, "export "+v.Key+"="+shellQuote(v.Value))
+		}
+		snippet.Target = "~/.zshrc / ~/.bashrc"
+		snippet.Hint = "追加到 shell 配置文件末尾，新开终端后生效；也可直接粘贴到当前终端只对本次会话生效"
+	case ProxyEnvFormatFish:
+		lines = append(lines, "# "+proxyEnvMarker)
+		for _, v := range vars {
+			lines = append(lines, "set -gx "+v.Key+" "+fishQuote(v.Value))
+		}
+		snippet.Target = "~/.config/fish/config.fish"
+		snippet.Hint = "追加到 config.fish 末尾，新开终端后生效"
+	case ProxyEnvFormatPowerShell:
+		lines = append(lines, "# "+proxyEnvMarker)
+		for _, v := range vars {
+			lines = append(lines, "$env:"+v.Key+" = "+powerShellQuote(v.Value))
+		}
+		snippet.Target = "$PROFILE"
+		snippet.Hint = "执行 notepad $PROFILE 打开配置文件并追加，新开 PowerShell 后生效"
+	case ProxyEnvFormatLaunchd:
+		commands := make([]string, 0, len(vars))
+		for _, v := range vars {
+			commands = append(commands, "launchctl setenv "+v.Key+" "+shellQuote(v.Value))
+		}
+		lines = append(lines,
+			`<?xml version="1.0" encoding="UTF-8"?>`,
+			`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">`,
+			`<plist version="1.0">`,
+			`<dict>`,
+			`  <key>Label</key>`,
+			`  <string>`+proxyEnvLaunchdLabel+`</string>`,
+			`  <key>ProgramArguments</key>`,
+			`  <array>`,
+			`    <string>/bin/sh</string>`,
+			`    <string>-c</string>`,
+			`    <string>`+html.EscapeString(strings.Join(commands, "; "))+`</string>`,
+			`  </array>`,
+			`  <key>RunAtLoad</key>`,
+			`  <true/>`,
+			`</dict>`,
+			`</plist>`,
+		)
+		snippet.Target = "~/Library/LaunchAgents/" + proxyEnvLaunchdLabel + ".plist"
+		snippet.Hint = "保存后执行 launchctl load -w " + snippet.Target + "，登录后从 Dock 或 Spotlight 启动的应用与终端都会带上这些变量"
+	default:
+		return ProxyEnvSnippet{}, fmt.Errorf("不支持的格式: %s", format)
+	}
+	snippet.Content = strings.Join(lines, "\n") + "\n"
+	return snippet, nil
+}
+
+// LaunchProxyTerminal 打开一个带代理环境变量的新终端，dir 为空时使用主目录。
+// 变量只存在于该终端及其子进程中，关闭终端即失效
+func (pes *ProxyEnvService) LaunchProxyTerminal(dir string, platforms []string) error {
+	vars, err := pes.ProxyEnvVars(platforms)
+	if err != nil {
+		return err
+	}
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		dir = userHomeDir()
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("目录不存在: %s", dir)
+	}
+	env := os.Environ()
+	for _, v := range vars {
+		env = append(env, v.Key+"="+v.Value)
+	}
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		// Terminal.app 由 launchd 启动，不继承本进程的环境，变量写进启动命令
+		parts := []string{"cd " + shellQuote(dir)}
+		for _, v := range vars {
+			parts = append(parts, "export "+v.Key+"="+shellQuote(v.Value))
+		}
+		script := strings.Join(parts, "; ")
+		cmd = exec.Command("osascript",
+			"-e", `tell application "Terminal" to activate`,
+			"-e", `tell application "Terminal" to do script "`+appleScriptEscape(script)+`"`,
+		)
+	case "windows":
+		cmd = exec.Command("cmd", "/c", "start", "", "powershell", "-NoExit")
+		cmd.Env, cmd.Dir = env, dir
+	default:
+		terminal, err := findTerminalEmulator()
+		if err != nil {
+			return err
+		}
+		cmd = exec.Command(terminal)
+		cmd.Env, cmd.Dir = env, dir
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+// findTerminalEmulator 优先使用 $TERMINAL，其次是常见的终端程序
+func findTerminalEmulator() (string, error) {
+	candidates := []string{os.Getenv("TERMINAL"), "x-terminal-emulator", "gnome-terminal", "konsole", "xfce4-terminal", "kitty", "alacritty", "wezterm", "xterm"}
+	for _, candidate := range candidates {
+		if strings.TrimSpace(candidate) == "" {
+			continue
+		}
+		if path, err := exec.LookPath(candidate); err == nil {
+			return path, nil
+		}
+	}
+	return "", errors.New("未找到可用的终端程序，请设置 TERMINAL 环境变量或复制 shell 片段手动执行")
+}
+
+// shellQuote 只含安全字符时原样返回，否则用单引号包裹
+func shellQuote(value string) string {
+	if value != "" && strings.IndexFunc(value, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:@%+=,", r))
+	}) < 0 {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// fishQuote fish 的单引号内只有 \' 与 \\ 需要转义
+func fishQuote(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+func powerShellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+func appleScriptEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value)
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProxyEnvSnippets(t *testing.T) {
+	pes := NewProxyEnvService(":18100")
+	snippet, err := pes.ProxyEnvSnippet(ProxyEnvFormatShell, []string{"claude", "codex"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"export ANTHROPIC_BASE_URL=http://127.0.0.1:18100\n", "export OPENAI_BASE_URL=http://127.0.0.1:18100\n"} {
+		if !strings.Contains(snippet.Content, want) {
+			t.Fatalf("missing %q in:\n%s", want, snippet.Content)
+		}
+	}
+	if strings.Contains(snippet.Content, "GEMINI") {
+		t.Fatal("gemini variables should only be included when requested")
+	}
+	snippet, err = pes.ProxyEnvSnippet(ProxyEnvFormatPowerShell, nil)
+	if err != nil || !strings.Contains(snippet.Content, "$env:GEMINI_API_KEY = 'code-switch'") {
+		t.Fatalf("unexpected powershell snippet %q %v", snippet.Content, err)
+	}
+	snippet, err = pes.ProxyEnvSnippet(ProxyEnvFormatLaunchd, []string{"claude"})
+	if err != nil || !strings.Contains(snippet.Content, "launchctl setenv ANTHROPIC_AUTH_TOKEN code-switch") {
+		t.Fatalf("unexpected plist %q %v", snippet.Content, err)
+	}
+	if _, err := pes.ProxyEnvSnippet("cmd", nil); err == nil {
+		t.Fatal("unknown format should fail")
+	}
+	if got := shellQuote("it's"); got != `'it'\''s'` {
+		t.Fatalf("shellQuote = %s", got)
+	}
+}
@@ -0,0 +1,461 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+const (
+	selfCheckBackupDir  = "backups"
+	selfCheckBackupKeep = 3
+	// 每天最多生成一份快照，避免频繁复制数据库
+	selfCheckBackupInterval = 24 * time.Hour
+	selfCheckTimeLayout     = "20060102-150405"
+	selfCheckDBName         = "app.db"
+)
+
+// 自检问题类型
+const (
+	SelfCheckSQLite       = "sqlite"
+	SelfCheckConfig       = "config"
+	SelfCheckOrphanBackup = "orphan_backup"
+)
+
+// SelfCheckIssue 自检发现的问题，Repaired 为 true 表示已自动修复
+type SelfCheckIssue struct {
+	ID       string `json:"id"`
+	Kind     string `json:"kind"`
+	Path     string `json:"path"`
+	Message  string `json:"message"`
+	Action   string `json:"action,omitempty"`
+	Repaired bool   `json:"repaired"`
+}
+
+// SelfCheckReport 一次自检的结果
+type SelfCheckReport struct {
+	CheckedAt time.Time        `json:"checked_at"`
+	Issues    []SelfCheckIssue `json:"issues"`
+	// 最近一份完整可用的快照
+	LatestBackup string `json:"latest_backup,omitempty"`
+}
+
+// selfCheckFile 纳入自检与快照的文件，Key 为快照中的相对路径
+type selfCheckFile struct {
+	Key  string
+	Path string
+	// CLI 的配置文件只报告问题，由用户确认后再恢复
+	External bool
+}
+
+var startupSelfCheck struct {
+	sync.Mutex
+	report SelfCheckReport
+}
+
+// RunStartupSelfCheck 在服务打开数据文件之前执行：校验数据库与配置文件，损坏的文件自动从最近的有效快照恢复，
+// 没有可用快照时把损坏文件改名保留，让应用以默认配置启动；全部正常时生成当天的快照
+func RunStartupSelfCheck() SelfCheckReport {
+	report := runSelfCheck(true)
+	healthy := true
+	for _, issue := range report.Issues {
+		if !issue.Repaired && issue.Kind != SelfCheckOrphanBackup {
+			healthy = false
+		}
+	}
+	if healthy {
+		if dir, err := snapshotSelfCheckBackup(time.Now()); err != nil {
+			fmt.Printf("[WARN] 生成配置快照失败: %v\n", err)
+		} else if dir != "" {
+			report.LatestBackup = dir
+		}
+	}
+	for _, issue := range report.Issues {
+		fmt.Printf("[WARN] 启动自检: %s %s（%s）\n", issue.Path, issue.Message, issue.Action)
+	}
+	startupSelfCheck.Lock()
+	startupSelfCheck.report = report
+	startupSelfCheck.Unlock()
+	return report
+}
+
+func runSelfCheck(repair bool) SelfCheckReport {
+	report := SelfCheckReport{CheckedAt: time.Now(), Issues: []SelfCheckIssue{}}
+	backups := selfCheckBackups()
+	if len(backups) > 0 {
+		report.LatestBackup = backups[0]
+	}
+
+	dbPath := filepath.Join(dataDir(), selfCheckDBName)
+	if err := quickCheckSQLite(dbPath); err != nil {
+		issue := SelfCheckIssue{ID: SelfCheckSQLite + ":" + selfCheckDBName, Kind: SelfCheckSQLite, Path: dbPath, Message: err.Error()}
+		if repair {
+			issue.Action, issue.Repaired = repairSQLite(dbPath, backups)
+		} else {
+			issue.Action = "重启应用后自动修复"
+		}
+		report.Issues = append(report.Issues, issue)
+	}
+
+	for _, file := range selfCheckFiles() {
+		if err := validateConfigFile(file.Path); err != nil {
+			issue := SelfCheckIssue{ID: SelfCheckConfig + ":" + file.Key, Kind: SelfCheckConfig, Path: file.Path, Message: err.Error()}
+			if repair && !file.External {
+				issue.Action, issue.Repaired = repairConfigFile(file, backups)
+			} else {
+				issue.Action = "可从最近的快照恢复"
+			}
+			report.Issues = append(report.Issues, issue)
+		}
+	}
+	return report
+}
+
+// quickCheckSQLite 以只读方式执行 PRAGMA quick_check，数据库不存在时视为正常
+func quickCheckSQLite(path string) error {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	db, err := sql.Open("sqlite", "file:"+filepath.ToSlash(path)+"?mode=ro&_busy_timeout=5000")
+	if err != nil {
+		return fmt.Errorf("无法打开数据库: %w", err)
+	}
+	defer db.Close()
+	rows, err := db.Query("PRAGMA quick_check")
+	if err != nil {
+		return fmt.Errorf("数据库校验失败: %w", err)
+	}
+	defer rows.Close()
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return fmt.Errorf("数据库校验失败: %w", err)
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("数据库校验失败: %w", err)
+	}
+	if len(problems) > 0 {
+		if len(problems) > 3 {
+			problems = append(problems[:3], fmt.Sprintf("等 %d 处问题", len(problems)))
+		}
+		return fmt.Errorf("数据库已损坏: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func validateConfigFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("无法读取: %w", err)
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return nil
+	}
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		var raw map[string]any
+		if err := toml.Unmarshal(data, &raw); err != nil {
+			return fmt.Errorf("TOML 格式错误: %w", err)
+		}
+		return nil
+	}
+	if !json.Valid(data) {
+		return errors.New("JSON 格式错误")
+	}
+	return nil
+}
+
+// selfCheckFiles 数据目录（含各 profile）下的 JSON 文件、应用设置与 CLI 配置
+func selfCheckFiles() []selfCheckFile {
+	root := dataDir()
+	files := []selfCheckFile{{Key: appSettingsFile, Path: appSettingsPath()}}
+	dirs := []string{root}
+	if entries, err := os.ReadDir(filepath.Join(root, profilesDir)); err == nil {
+		for _, entry := range entries {
+			if entry.IsDir() {
+				dirs = append(dirs, filepath.Join(root, profilesDir, entry.Name()))
+			}
+		}
+	}
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ".json") {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if path == files[0].Path {
+				continue
+			}
+			rel, _ := filepath.Rel(root, path)
+			files = append(files, selfCheckFile{Key: filepath.ToSlash(filepath.Join("data", rel)), Path: path})
+		}
+	}
+	home := userHomeDir()
+	files = append(files,
+		selfCheckFile{Key: "cli/claude/" + claudeSettingsFileName, Path: filepath.Join(home, claudeSettingsDir, claudeSettingsFileName), External: true},
+		selfCheckFile{Key: "cli/codex/" + codexConfigFileName, Path: filepath.Join(home, codexSettingsDir, codexConfigFileName), External: true},
+		selfCheckFile{Key: "cli/codex/" + codexAuthFileName, Path: filepath.Join(home, codexSettingsDir, codexAuthFileName), External: true},
+	)
+	return files
+}
+
+// selfCheckBackups 返回快照目录，最新的在前
+func selfCheckBackups() []string {
+	root := filepath.Join(dataDir(), selfCheckBackupDir)
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil
+	}
+	backups := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if _, err := time.Parse(selfCheckTimeLayout, entry.Name()); err == nil && entry.IsDir() {
+			backups = append(backups, filepath.Join(root, entry.Name()))
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	return backups
+}
+
+// repairConfigFile 从最近一份该文件有效的快照恢复，没有时把损坏文件改名保留
+func repairConfigFile(file selfCheckFile, backups []string) (string, bool) {
+	for _, backup := range backups {
+		source := filepath.Join(backup, filepath.FromSlash(file.Key))
+		if _, err := os.Stat(source); err != nil || validateConfigFile(source) != nil {
+			continue
+		}
+		corrupt, err := quarantineFile(file.Path)
+		if err != nil {
+			return fmt.Sprintf("修复失败: %v", err), false
+		}
+		if err := copyPath(source, file.Path); err != nil {
+			return fmt.Sprintf("从快照恢复失败: %v", err), false
+		}
+		return fmt.Sprintf("已从快照 %s 恢复，损坏的文件保存为 %s", filepath.Base(backup), filepath.Base(corrupt)), true
+	}
+	corrupt, err := quarantineFile(file.Path)
+	if err != nil {
+		return fmt.Sprintf("修复失败: %v", err), false
+	}
+	return fmt.Sprintf("没有可用的快照，已改名为 %s 并使用默认配置", filepath.Base(corrupt)), true
+}
+
+// repairSQLite 数据库损坏时改名保留，并从最近的快照恢复；没有快照时启动后会新建数据库
+func repairSQLite(path string, backups []string) (string, bool) {
+	corrupt, err := quarantineFile(path)
+	if err != nil {
+		return fmt.Sprintf("修复失败: %v", err), false
+	}
+	for _, suffix := range []string{"-wal", "-shm"} {
+		_ = os.Rename(path+suffix, corrupt+suffix)
+	}
+	for _, backup := range backups {
+		source := filepath.Join(backup, selfCheckDBName)
+		if _, err := os.Stat(source); err != nil || quickCheckSQLite(source) != nil {
+			continue
+		}
+		if err := copyPath(source, path); err != nil {
+			return fmt.Sprintf("从快照恢复数据库失败: %v", err), false
+		}
+		return fmt.Sprintf("已从快照 %s 恢复数据库，损坏的数据库保存为 %s", filepath.Base(backup), filepath.Base(corrupt)), true
+	}
+	return fmt.Sprintf("没有可用的快照，已新建数据库，损坏的数据库保存为 %s", filepath.Base(corrupt)), true
+}
+
+func quarantineFile(path string) (string, error) {
+	corrupt := fmt.Sprintf("%s.corrupt-%s", path, time.Now().Format(selfCheckTimeLayout))
+	if err := os.Rename(path, corrupt); err != nil {
+		return "", err
+	}
+	return corrupt, nil
+}
+
+// snapshotSelfCheckBackup 复制配置文件，并用 VACUUM INTO 导出一致的数据库副本，只保留最近几份
+func snapshotSelfCheckBackup(now time.Time) (string, error) {
+	backups := selfCheckBackups()
+	if len(backups) > 0 {
+		if last, err := time.ParseInLocation(selfCheckTimeLayout, filepath.Base(backups[0]), time.Local); err == nil && now.Sub(last) < selfCheckBackupInterval {
+			return backups[0], nil
+		}
+	}
+	dir := filepath.Join(dataDir(), selfCheckBackupDir, now.Format(selfCheckTimeLayout))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	for _, file := range selfCheckFiles() {
+		if _, err := os.Stat(file.Path); err != nil {
+			continue
+		}
+		target := filepath.Join(dir, filepath.FromSlash(file.Key))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return "", err
+		}
+		if err := copyPath(file.Path, target); err != nil {
+			_ = os.RemoveAll(dir)
+			return "", err
+		}
+	}
+	if dbPath := filepath.Join(dataDir(), selfCheckDBName); fileExists(dbPath) {
+		db, err := sql.Open("sqlite", dbPath)
+		if err == nil {
+			_, err = db.Exec("VACUUM INTO ?", filepath.Join(dir, selfCheckDBName))
+			db.Close()
+		}
+		if err != nil {
+			_ = os.RemoveAll(dir)
+			return "", fmt.Errorf("备份数据库失败: %w", err)
+		}
+	}
+	for _, old := range append([]string{dir}, backups...)[min(len(backups)+1, selfCheckBackupKeep):] {
+		_ = os.RemoveAll(old)
+	}
+	return dir, nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+type SelfCheckService struct {
+	claudeSettings *ClaudeSettingsService
+	codexSettings  *CodexSettingsService
+}
+
+func NewSelfCheckService(claudeSettings *ClaudeSettingsService, codexSettings *CodexSettingsService) *SelfCheckService {
+	return &SelfCheckService{claudeSettings: claudeSettings, codexSettings: codexSettings}
+}
+
+// GetStartupReport 返回启动自检的结果（含自动修复记录）与当前的遗留备份
+func (scs *SelfCheckService) GetStartupReport() SelfCheckReport {
+	startupSelfCheck.Lock()
+	report := startupSelfCheck.report
+	startupSelfCheck.Unlock()
+	report.Issues = append(append([]SelfCheckIssue{}, report.Issues...), scs.orphanBackupIssues()...)
+	return report
+}
+
+// RunSelfCheck 立即重新自检，只报告问题不自动修复
+func (scs *SelfCheckService) RunSelfCheck() SelfCheckReport {
+	report := runSelfCheck(false)
+	report.Issues = append(report.Issues, scs.orphanBackupIssues()...)
+	return report
+}
+
+// RepairIssue 修复指定问题：配置文件从最近的有效快照恢复，遗留备份按情况还原或归档
+func (scs *SelfCheckService) RepairIssue(id string) (SelfCheckReport, error) {
+	kind, key, _ := strings.Cut(id, ":")
+	switch kind {
+	case SelfCheckSQLite:
+		return scs.RunSelfCheck(), errors.New("数据库正在使用，重启应用后会自动修复")
+	case SelfCheckConfig:
+		for _, file := range selfCheckFiles() {
+			if file.Key != key {
+				continue
+			}
+			if validateConfigFile(file.Path) == nil {
+				return scs.RunSelfCheck(), nil
+			}
+			action, ok := repairConfigFile(file, selfCheckBackups())
+			if !ok {
+				return scs.RunSelfCheck(), errors.New(action)
+			}
+			fmt.Printf("[INFO] %s: %s\n", file.Path, action)
+			return scs.RunSelfCheck(), nil
+		}
+	case SelfCheckOrphanBackup:
+		for _, orphan := range scs.orphanBackups() {
+			if orphan.key == key {
+				return scs.RunSelfCheck(), resolveOrphanBackup(orphan)
+			}
+		}
+	}
+	return scs.RunSelfCheck(), fmt.Errorf("未找到问题 %s", id)
+}
+
+// orphanBackup 代理已关闭但仍残留的 CLI 配置备份（通常是启用代理后应用崩溃或被卸载）
+type orphanBackup struct {
+	key    string
+	backup string
+	target string
+}
+
+func (scs *SelfCheckService) orphanBackups() []orphanBackup {
+	var orphans []orphanBackup
+	if scs.claudeSettings != nil {
+		if status, err := scs.claudeSettings.ProxyStatus(); err == nil && !status.Enabled {
+			if settingsPath, backupPath, err := scs.claudeSettings.paths(); err == nil && fileExists(backupPath) {
+				orphans = append(orphans, orphanBackup{key: "claude", backup: backupPath, target: settingsPath})
+			}
+		}
+	}
+	if scs.codexSettings != nil {
+		if status, err := scs.codexSettings.ProxyStatus(); err == nil && !status.Enabled {
+			if configPath, backupPath, err := scs.codexSettings.paths(); err == nil && fileExists(backupPath) {
+				orphans = append(orphans, orphanBackup{key: "codex", backup: backupPath, target: configPath})
+			}
+			if authPath, backupPath, err := scs.codexSettings.authPaths(); err == nil && fileExists(backupPath) {
+				orphans = append(orphans, orphanBackup{key: "codex-auth", backup: backupPath, target: authPath})
+			}
+		}
+	}
+	return orphans
+}
+
+func (scs *SelfCheckService) orphanBackupIssues() []SelfCheckIssue {
+	orphans := scs.orphanBackups()
+	issues := make([]SelfCheckIssue, 0, len(orphans))
+	for _, orphan := range orphans {
+		action := "归档该备份"
+		if !fileExists(orphan.target) || validateConfigFile(orphan.target) != nil {
+			action = "用该备份还原配置"
+		}
+		issues = append(issues, SelfCheckIssue{
+			ID:      SelfCheckOrphanBackup + ":" + orphan.key,
+			Kind:    SelfCheckOrphanBackup,
+			Path:    orphan.backup,
+			Message: "代理未启用，但仍残留启用代理时的配置备份",
+			Action:  action,
+		})
+	}
+	return issues
+}
+
+// resolveOrphanBackup 当前配置缺失或损坏时用备份还原，否则把备份移入快照目录，不删除任何内容
+func resolveOrphanBackup(orphan orphanBackup) error {
+	if !fileExists(orphan.target) || validateConfigFile(orphan.target) != nil {
+		if err := validateConfigFile(orphan.backup); err != nil {
+			return fmt.Errorf("备份文件也已损坏: %w", err)
+		}
+		if fileExists(orphan.target) {
+			if _, err := quarantineFile(orphan.target); err != nil {
+				return err
+			}
+		}
+		return os.Rename(orphan.backup, orphan.target)
+	}
+	dir := filepath.Join(dataDir(), selfCheckBackupDir, "orphans")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return movePath(orphan.backup, filepath.Join(dir, time.Now().Format(selfCheckTimeLayout)+"-"+filepath.Base(orphan.backup)))
+}
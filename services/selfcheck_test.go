@@ -0,0 +1,65 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	cases := []struct {
+		path    string
+		wantErr bool
+	}{
+		{write("ok.json", `{"providers": []}`), false},
+		{write("empty.json", "  \n"), false},
+		{write("broken.json", `{"providers": [`), true},
+		{write("ok.toml", "model_provider = \"code-switch\"\n"), false},
+		{write("broken.toml", "model_provider = \n"), true},
+		{filepath.Join(dir, "missing.json"), false},
+	}
+	for _, tc := range cases {
+		if err := validateConfigFile(tc.path); (err != nil) != tc.wantErr {
+			t.Errorf("validateConfigFile(%s) error = %v, wantErr %v", filepath.Base(tc.path), err, tc.wantErr)
+		}
+	}
+}
+
+func TestRepairConfigFileFromBackup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "codex.json")
+	if err := os.WriteFile(path, []byte(`{"providers": [`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	newer := filepath.Join(dir, "20260102-090000")
+	older := filepath.Join(dir, "20260101-090000")
+	for backup, content := range map[string]string{newer: `{"providers": [`, older: `{"providers": []}`} {
+		if err := os.MkdirAll(filepath.Join(backup, "data"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(backup, "data", "codex.json"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	action, ok := repairConfigFile(selfCheckFile{Key: "data/codex.json", Path: path}, []string{newer, older})
+	if !ok {
+		t.Fatalf("repair failed: %s", action)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != `{"providers": []}` {
+		t.Fatalf("restored content = %q, err = %v; want the newest valid backup", data, err)
+	}
+	matches, _ := filepath.Glob(path + ".corrupt-*")
+	if len(matches) != 1 {
+		t.Fatalf("corrupt file should be kept, got %v", matches)
+	}
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"time"
+
+	modelpricing "codeswitch/resources/model-pricing"
+
+	"github.com/daodao97/xgo/xdb"
+)
+
+// SessionSummary 一次 Claude Code / Codex 会话的用量汇总
+type SessionSummary struct {
+	SessionID         string  `json:"session_id"`
+	Platform          string  `json:"platform"`
+	Requests          int64   `json:"requests"`
+	Failures          int64   `json:"failures"`
+	InputTokens       int64   `json:"input_tokens"`
+	OutputTokens      int64   `json:"output_tokens"`
+	CacheCreateTokens int64   `json:"cache_create_tokens"`
+	CacheReadTokens   int64   `json:"cache_read_tokens"`
+	TotalCost         float64 `json:"total_cost"`
+	// 提示词缓存节省的费用
+	CacheSavings float64  `json:"cache_savings"`
+	Providers    []string `json:"providers"`
+	// 相邻两次成功请求使用了不同 provider 的次数
+	ProviderSwitches int     `json:"provider_switches"`
+	StartedAt        string  `json:"started_at"`
+	EndedAt          string  `json:"ended_at"`
+	DurationSec      float64 `json:"duration_sec"`
+}
+
+type SessionDetail struct {
+	Summary SessionSummary `json:"summary"`
+	Logs    []ReqeustLog   `json:"logs"`
+}
+
+type sessionAccumulator struct {
+	summary      SessionSummary
+	providers    map[string]struct{}
+	lastProvider string
+	started      time.Time
+	ended        time.Time
+}
+
+// ListSessions 汇总最近 days 天内的会话，按最近活动时间倒序
+func (ls *LogService) ListSessions(platform string, days int, limit int) ([]SessionSummary, error) {
+	if days <= 0 {
+		days = 7
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+	since := startOfDay(time.Now()).AddDate(0, 0, -(days - 1))
+	options := []xdb.Option{
+		xdb.WhereGte("created_at", since.UTC().Format(timeLayout)),
+		xdb.WhereNotEq("session_id", ""),
+		xdb.OrderByAsc("id"),
+	}
+	if platform != "" {
+		options = append(options, xdb.WhereEq("platform", platform))
+	}
+	records, err := xdb.New(requestLogTable()).Selects(options...)
+	if err != nil {
+		if errors.Is(err, xdb.ErrNotFound) || isNoSuchTableErr(err) {
+			return []SessionSummary{}, nil
+		}
+		return nil, err
+	}
+
+	sessions := make(map[string]*sessionAccumulator)
+	order := make([]*sessionAccumulator, 0)
+	for _, record := range records {
+		entry := requestLogFromRecord(record)
+		key := entry.Platform + ":" + entry.SessionID
+		acc, ok := sessions[key]
+		if !ok {
+			acc = &sessionAccumulator{
+				summary:   SessionSummary{SessionID: entry.SessionID, Platform: entry.Platform},
+				providers: make(map[string]struct{}),
+			}
+			sessions[key] = acc
+			order = append(order, acc)
+		}
+		createdAt, _ := parseCreatedAt(record)
+		acc.add(ls, entry, createdAt)
+	}
+
+	result := make([]SessionSummary, 0, len(order))
+	for _, acc := range order {
+		result = append(result, acc.finish())
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].EndedAt > result[j].EndedAt })
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+// GetSession 返回某个会话的汇总与全部请求
+func (ls *LogService) GetSession(platform string, sessionID string) (SessionDetail, error) {
+	detail := SessionDetail{
+		Summary: SessionSummary{SessionID: sessionID, Platform: platform, Providers: []string{}},
+		Logs:    []ReqeustLog{},
+	}
+	options := []xdb.Option{
+		xdb.WhereEq("session_id", sessionID),
+		xdb.OrderByAsc("id"),
+	}
+	if platform != "" {
+		options = append(options, xdb.WhereEq("platform", platform))
+	}
+	records, err := xdb.New(requestLogTable()).Selects(options...)
+	if err != nil {
+		if errors.Is(err, xdb.ErrNotFound) || isNoSuchTableErr(err) {
+			return detail, nil
+		}
+		return detail, err
+	}
+	acc := &sessionAccumulator{summary: detail.Summary, providers: make(map[string]struct{})}
+	for _, record := range records {
+		entry := requestLogFromRecord(record)
+		ls.decorateCost(&entry)
+		createdAt, _ := parseCreatedAt(record)
+		acc.add(ls, entry, createdAt)
+		detail.Logs = append(detail.Logs, entry)
+	}
+	detail.Summary = acc.finish()
+	return detail, nil
+}
+
+func (acc *sessionAccumulator) add(ls *LogService, entry ReqeustLog, createdAt time.Time) {
+	summary := &acc.summary
+	summary.Requests++
+	if entry.HttpCode < 200 || entry.HttpCode >= 300 {
+		summary.Failures++
+	} else {
+		if acc.lastProvider != "" && acc.lastProvider != entry.Provider {
+			summary.ProviderSwitches++
+		}
+		acc.lastProvider = entry.Provider
+	}
+	summary.InputTokens += int64(entry.InputTokens)
+	summary.OutputTokens += int64(entry.OutputTokens)
+	summary.CacheCreateTokens += int64(entry.CacheCreateTokens)
+	summary.CacheReadTokens += int64(entry.CacheReadTokens)
+	actual, uncached := ls.cacheCosts(entry.Provider, entry.Model, modelpricing.UsageSnapshot{
+		InputTokens:       entry.InputTokens,
+		OutputTokens:      entry.OutputTokens,
+		CacheCreateTokens: entry.CacheCreateTokens,
+		CacheReadTokens:   entry.CacheReadTokens,
+	})
+	summary.TotalCost += actual
+	summary.CacheSavings += uncached - actual
+	if _, ok := acc.providers[entry.Provider]; !ok {
+		acc.providers[entry.Provider] = struct{}{}
+		summary.Providers = append(summary.Providers, entry.Provider)
+	}
+	if createdAt.IsZero() {
+		return
+	}
+	if acc.started.IsZero() || createdAt.Before(acc.started) {
+		acc.started = createdAt
+	}
+	end := createdAt.Add(time.Duration(entry.DurationSec * float64(time.Second)))
+	if end.After(acc.ended) {
+		acc.ended = end
+	}
+}
+
+func (acc *sessionAccumulator) finish() SessionSummary {
+	summary := acc.summary
+	if summary.Providers == nil {
+		summary.Providers = []string{}
+	}
+	if !acc.started.IsZero() {
+		summary.StartedAt = acc.started.Format(timeLayout)
+		summary.EndedAt = acc.ended.Format(timeLayout)
+		summary.DurationSec = acc.ended.Sub(acc.started).Seconds()
+	}
+	return summary
+}
+
+// systemPromptSessionID 客户端未携带会话标识时，以系统提示词的哈希近似区分会话
+func systemPromptSessionID(system string) string {
+	if system == "" {
+		return ""
+	}
+	sum := sha1.Sum([]byte(system))
+	return "sys-" + hex.EncodeToString(sum[:])[:12]
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	skillBackupDirName  = "skill-backups"
+	skillBackupMetaFile = "backup.json"
+	skillBackupFilesDir = "files"
+	// 每个 skill 目录保留的备份数
+	skillBackupKeep       = 10
+	skillBackupTimeLayout = "20060102-150405.000"
+
+	skillBackupInstall   = "install"
+	skillBackupUninstall = "uninstall"
+	skillBackupSync      = "sync"
+	skillBackupRestore   = "restore"
+)
+
+// SkillBackup 安装、卸载或同步覆盖前保存的 skill 目录快照
+type SkillBackup struct {
+	ID        string    `json:"id"`
+	Directory string    `json:"directory"`
+	Source    string    `json:"source"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ListSkillBackups 返回备份列表，按时间倒序；directory 为空时返回全部
+func (ss *SkillService) ListSkillBackups(directory string) ([]SkillBackup, error) {
+	directory = strings.TrimSpace(directory)
+	backups, err := ss.loadSkillBackups()
+	if err != nil {
+		return nil, err
+	}
+	if directory == "" {
+		return backups, nil
+	}
+	filtered := make([]SkillBackup, 0, len(backups))
+	for _, backup := range backups {
+		if strings.EqualFold(backup.Directory, directory) {
+			filtered = append(filtered, backup)
+		}
+	}
+	return filtered, nil
+}
+
+// RestoreSkillBackup 把备份还原到原位置；还原前先备份当前内容，误操作时可以再还原回去
+func (ss *SkillService) RestoreSkillBackup(id string) (SkillBackup, error) {
+	backup, err := ss.readSkillBackup(id)
+	if err != nil {
+		return SkillBackup{}, err
+	}
+	if err := ss.backupSkillDir(backup.Source, skillBackupRestore); err != nil {
+		return SkillBackup{}, err
+	}
+	if err := restoreSkillFiles(filepath.Join(ss.backupDir, backup.ID, skillBackupFilesDir), backup.Source); err != nil {
+		return SkillBackup{}, fmt.Errorf("还原 skill 失败: %w", err)
+	}
+	if filepath.Dir(backup.Source) == filepath.Clean(ss.installDir) {
+		ss.mu.Lock()
+		defer ss.mu.Unlock()
+		store, err := ss.loadStoreLocked()
+		if err != nil {
+			return SkillBackup{}, err
+		}
+		if store.Skills == nil {
+			store.Skills = make(map[string]skillState)
+		}
+		store.Skills[backup.Directory] = skillState{Installed: true, InstalledAt: time.Now()}
+		if err := ss.saveStoreLocked(store); err != nil {
+			return SkillBackup{}, err
+		}
+	}
+	return backup, nil
+}
+
+// DeleteSkillBackup 删除单个备份
+func (ss *SkillService) DeleteSkillBackup(id string) error {
+	backup, err := ss.readSkillBackup(id)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(ss.backupDir, backup.ID))
+}
+
+// backupSkillDir 在修改 skill 目录前保存快照，目录不存在（首次安装）时跳过；
+// 未配置备份目录时不备份
+func (ss *SkillService) backupSkillDir(dir, reason string) error {
+	if ss.backupDir == "" {
+		return nil
+	}
+	dir = filepath.Clean(dir)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil
+	}
+	now := time.Now()
+	backup := SkillBackup{
+		ID:        now.Format(skillBackupTimeLayout) + "-" + filepath.Base(dir),
+		Directory: filepath.Base(dir),
+		Source:    dir,
+		Reason:    reason,
+		CreatedAt: now,
+	}
+	// 同一毫秒内多次备份时追加序号，避免覆盖
+	for i := 2; fileExists(filepath.Join(ss.backupDir, backup.ID)); i++ {
+		backup.ID = fmt.Sprintf("%s-%s-%d", now.Format(skillBackupTimeLayout), backup.Directory, i)
+	}
+	target := filepath.Join(ss.backupDir, backup.ID)
+	if err := copyPath(dir, filepath.Join(target, skillBackupFilesDir)); err != nil {
+		_ = os.RemoveAll(target)
+		return fmt.Errorf("备份 skill %s 失败: %w", backup.Directory, err)
+	}
+	data, err := json.MarshalIndent(backup, "", "  ")
+	if err == nil {
+		err = os.WriteFile(filepath.Join(target, skillBackupMetaFile), data, 0o644)
+	}
+	if err != nil {
+		_ = os.RemoveAll(target)
+		return fmt.Errorf("备份 skill %s 失败: %w", backup.Directory, err)
+	}
+	ss.pruneSkillBackups(dir)
+	return nil
+}
+
+// pruneSkillBackups 每个 skill 目录只保留最近 skillBackupKeep 个备份
+func (ss *SkillService) pruneSkillBackups(source string) {
+	backups, err := ss.loadSkillBackups()
+	if err != nil {
+		return
+	}
+	kept := 0
+	for _, backup := range backups {
+		if backup.Source != source {
+			continue
+		}
+		if kept++; kept > skillBackupKeep {
+			if err := os.RemoveAll(filepath.Join(ss.backupDir, backup.ID)); err != nil {
+				fmt.Printf("[WARN] 清理 skill 备份 %s 失败: %v\n", backup.ID, err)
+			}
+		}
+	}
+}
+
+// restoreSkillFiles 与 mirrorSkillDir 一样先复制到临时目录再替换，但保留文件权限，脚本还原后仍可执行
+func restoreSkillFiles(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dst), "."+filepath.Base(dst)+"-restore-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	staged := filepath.Join(tmp, filepath.Base(dst))
+	if err := copyPath(src, staged); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dst); err != nil {
+		return err
+	}
+	return os.Rename(staged, dst)
+}
+
+func (ss *SkillService) loadSkillBackups() ([]SkillBackup, error) {
+	if ss.backupDir == "" {
+		return []SkillBackup{}, nil
+	}
+	entries, err := os.ReadDir(ss.backupDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []SkillBackup{}, nil
+		}
+		return nil, err
+	}
+	backups := make([]SkillBackup, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		backup, err := ss.readSkillBackup(entry.Name())
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup)
+	}
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+func (ss *SkillService) readSkillBackup(id string) (SkillBackup, error) {
+	id = strings.TrimSpace(id)
+	if id == "" || !filepath.IsLocal(id) || strings.ContainsAny(id, `/\`) {
+		return SkillBackup{}, errors.New("备份 ID 无效")
+	}
+	data, err := os.ReadFile(filepath.Join(ss.backupDir, id, skillBackupMetaFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return SkillBackup{}, fmt.Errorf("备份不存在: %s", id)
+		}
+		return SkillBackup{}, err
+	}
+	var backup SkillBackup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return SkillBackup{}, fmt.Errorf("备份 %s 已损坏: %w", id, err)
+	}
+	if backup.ID != id || backup.Source == "" || !filepath.IsAbs(backup.Source) {
+		return SkillBackup{}, fmt.Errorf("备份 %s 已损坏", id)
+	}
+	return backup, nil
+}
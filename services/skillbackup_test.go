@@ -0,0 +1,89 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSkillBackupRestore(t *testing.T) {
+	root := t.TempDir()
+	ss := &SkillService{
+		storePath:  filepath.Join(root, "skills.json"),
+		installDir: filepath.Join(root, "claude", "skills"),
+		backupDir:  filepath.Join(root, "backups"),
+	}
+	skillDir := filepath.Join(ss.installDir, "pdf")
+	writeSkillFiles(t, skillDir, map[string]string{
+		"SKILL.md":       "---\nname: pdf\ndescription: v1\n---\nbody\n",
+		"scripts/run.sh": "echo v1\n",
+	})
+	if err := os.Chmod(filepath.Join(skillDir, "scripts", "run.sh"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// 升级到 v2 前备份 v1
+	upgrade := filepath.Join(root, "upgrade", "pdf")
+	writeSkillFiles(t, upgrade, map[string]string{"SKILL.md": "---\nname: pdf\ndescription: v2\n---\nbody\n"})
+	if err := ss.installFromPath("pdf", upgrade); err != nil {
+		t.Fatalf("installFromPath: %v", err)
+	}
+	if err := ss.UninstallSkill("pdf"); err != nil {
+		t.Fatalf("UninstallSkill: %v", err)
+	}
+	backups, err := ss.ListSkillBackups("pdf")
+	if err != nil {
+		t.Fatalf("ListSkillBackups: %v", err)
+	}
+	if len(backups) != 2 || backups[0].Reason != skillBackupUninstall || backups[1].Reason != skillBackupInstall {
+		t.Fatalf("backups = %+v", backups)
+	}
+
+	restored, err := ss.RestoreSkillBackup(backups[1].ID)
+	if err != nil {
+		t.Fatalf("RestoreSkillBackup: %v", err)
+	}
+	if restored.Source != skillDir {
+		t.Fatalf("restored to %s", restored.Source)
+	}
+	meta, err := readSkillMetadata(skillDir)
+	if err != nil || meta.Description != "v1" {
+		t.Fatalf("restored metadata = %+v, err = %v", meta, err)
+	}
+	if info, err := os.Stat(filepath.Join(skillDir, "scripts", "run.sh")); err != nil || info.Mode().Perm()&0o100 == 0 {
+		t.Fatalf("script not restored with exec bit: %v", err)
+	}
+	if !ss.isInstalled("pdf") {
+		t.Fatal("restored skill should be marked installed")
+	}
+	// 还原前的状态（已卸载）不存在目录，不产生新的备份
+	if backups, _ = ss.ListSkillBackups(""); len(backups) != 2 {
+		t.Fatalf("backups after restore = %+v", backups)
+	}
+
+	if _, err := ss.RestoreSkillBackup("../x"); err == nil {
+		t.Fatal("expected error for invalid id")
+	}
+	if err := ss.DeleteSkillBackup(backups[0].ID); err != nil {
+		t.Fatalf("DeleteSkillBackup: %v", err)
+	}
+	if backups, _ = ss.ListSkillBackups(""); len(backups) != 1 {
+		t.Fatalf("backups after delete = %+v", backups)
+	}
+}
+
+func TestPruneSkillBackups(t *testing.T) {
+	root := t.TempDir()
+	ss := &SkillService{backupDir: filepath.Join(root, "backups")}
+	dir := filepath.Join(root, "skills", "pdf")
+	writeSkillFiles(t, dir, map[string]string{"SKILL.md": "x"})
+	for i := 0; i < skillBackupKeep+3; i++ {
+		if err := ss.backupSkillDir(dir, skillBackupInstall); err != nil {
+			t.Fatalf("backupSkillDir: %v", err)
+		}
+	}
+	backups, _ := ss.ListSkillBackups("pdf")
+	if len(backups) != skillBackupKeep {
+		t.Fatalf("kept %d backups, want %d", len(backups), skillBackupKeep)
+	}
+}
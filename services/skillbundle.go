@@ -0,0 +1,361 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	skillBundleFormat   = "code-switch-skill"
+	skillBundleVersion  = 1
+	skillBundleManifest = "manifest.json"
+	// skillBundleMaxSize 导入的压缩包与解压后内容的上限
+	skillBundleMaxSize = 50 << 20
+	// skillLinkMaxSize 内嵌在链接中的压缩包上限，更大的 skill 请分享 zip 文件
+	skillLinkMaxSize = 48 << 10
+	skillLinkPrefix  = "codeswitch://skill/import"
+)
+
+// skillBundleManifestFile 压缩包根目录的 manifest.json，Files 为每个文件的 SHA256
+type skillBundleManifestFile struct {
+	Format      string            `json:"format"`
+	Version     int               `json:"version"`
+	Name        string            `json:"name"`
+	Directory   string            `json:"directory"`
+	Description string            `json:"description,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	Files       map[string]string `json:"files"`
+}
+
+// SkillBundle 导出的 skill 压缩包
+type SkillBundle struct {
+	Path      string `json:"path"`
+	Directory string `json:"directory"`
+	SHA256    string `json:"sha256"`
+	Size      int64  `json:"size"`
+}
+
+// ExportSkill 把已安装的 skill 打包为 zip，写入 outputDir（为空时写入数据目录下的 exports）
+func (ss *SkillService) ExportSkill(directory, outputDir string) (SkillBundle, error) {
+	data, directory, err := ss.buildSkillBundle(directory)
+	if err != nil {
+		return SkillBundle{}, err
+	}
+	dir, err := exportDir(outputDir)
+	if err != nil {
+		return SkillBundle{}, err
+	}
+	target := filepath.Join(dir, fmt.Sprintf("%s-%s.zip", directory, time.Now().Format("20060102-150405")))
+	if err := os.WriteFile(target, data, 0o644); err != nil {
+		return SkillBundle{}, err
+	}
+	sum := sha256.Sum256(data)
+	return SkillBundle{Path: target, Directory: directory, SHA256: hex.EncodeToString(sum[:]), Size: int64(len(data))}, nil
+}
+
+// ExportSkillLink 把小体积的 skill 编码为 codeswitch://skill/import?data=...&sha256=... 链接，便于直接粘贴分享
+func (ss *SkillService) ExportSkillLink(directory string) (string, error) {
+	data, _, err := ss.buildSkillBundle(directory)
+	if err != nil {
+		return "", err
+	}
+	if len(data) > skillLinkMaxSize {
+		return "", fmt.Errorf("skill 压缩后 %d KB，超过链接上限 %d KB，请导出为文件分享", len(data)>>10, skillLinkMaxSize>>10)
+	}
+	sum := sha256.Sum256(data)
+	query := url.Values{}
+	query.Set("data", base64.RawURLEncoding.EncodeToString(data))
+	query.Set("sha256", hex.EncodeToString(sum[:]))
+	return skillLinkPrefix + "?" + query.Encode(), nil
+}
+
+// ImportSkillFromFile 安装本地 zip 包；expectedSHA256 不为空时先校验整个压缩包
+func (ss *SkillService) ImportSkillFromFile(path, expectedSHA256 string) (Skill, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Skill{}, err
+	}
+	if info.Size() > skillBundleMaxSize {
+		return Skill{}, fmt.Errorf("压缩包超过 %d MB", skillBundleMaxSize>>20)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Skill{}, err
+	}
+	return ss.importSkillBundle(data, expectedSHA256)
+}
+
+// ImportSkillFromURL 下载并安装 zip 包，也接受 ExportSkillLink 生成的链接
+func (ss *SkillService) ImportSkillFromURL(rawURL, expectedSHA256 string) (Skill, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if strings.HasPrefix(rawURL, skillLinkPrefix) {
+		return ss.importSkillLink(rawURL)
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return Skill{}, fmt.Errorf("不支持的地址: %s", rawURL)
+	}
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return Skill{}, err
+	}
+	req.Header.Set("User-Agent", "ai-code-studio")
+	resp, err := ss.httpClient.Do(req)
+	if err != nil {
+		return Skill{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Skill{}, fmt.Errorf("下载失败: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, skillBundleMaxSize+1))
+	if err != nil {
+		return Skill{}, err
+	}
+	if len(data) > skillBundleMaxSize {
+		return Skill{}, fmt.Errorf("压缩包超过 %d MB", skillBundleMaxSize>>20)
+	}
+	return ss.importSkillBundle(data, expectedSHA256)
+}
+
+func (ss *SkillService) importSkillLink(link string) (Skill, error) {
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return Skill{}, err
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parsed.Query().Get("data"))
+	if err != nil || len(data) == 0 {
+		return Skill{}, errors.New("skill 链接内容无效")
+	}
+	sum := parsed.Query().Get("sha256")
+	if sum == "" {
+		return Skill{}, errors.New("skill 链接缺少 sha256")
+	}
+	return ss.importSkillBundle(data, sum)
+}
+
+// buildSkillBundle 打包已安装的 skill：manifest.json 与 <directory>/ 下的全部文件
+func (ss *SkillService) buildSkillBundle(directory string) ([]byte, string, error) {
+	directory = strings.TrimSpace(directory)
+	if directory == "" || !filepath.IsLocal(directory) || strings.ContainsAny(directory, `/\`) {
+		return nil, "", errors.New("skill directory 无效")
+	}
+	source := filepath.Join(ss.installDir, directory)
+	meta, err := readSkillMetadata(source)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s 不是有效的 skill: %w", directory, err)
+	}
+
+	var files []string
+	err = filepath.WalkDir(source, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != source && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(source, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	sort.Strings(files)
+
+	manifest := skillBundleManifestFile{
+		Format:      skillBundleFormat,
+		Version:     skillBundleVersion,
+		Name:        strings.TrimSpace(meta.Name),
+		Directory:   directory,
+		Description: strings.TrimSpace(meta.Description),
+		CreatedAt:   time.Now(),
+		Files:       make(map[string]string, len(files)),
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, rel := range files {
+		content, err := os.ReadFile(filepath.Join(source, filepath.FromSlash(rel)))
+		if err != nil {
+			return nil, "", err
+		}
+		sum := sha256.Sum256(content)
+		manifest.Files[rel] = hex.EncodeToString(sum[:])
+		w, err := zw.Create(path.Join(directory, rel))
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := w.Write(content); err != nil {
+			return nil, "", err
+		}
+	}
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, "", err
+	}
+	w, err := zw.Create(skillBundleManifest)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := w.Write(manifestData); err != nil {
+		return nil, "", err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), directory, nil
+}
+
+// importSkillBundle 校验压缩包与 manifest 中每个文件的 SHA256 后安装
+func (ss *SkillService) importSkillBundle(data []byte, expectedSHA256 string) (Skill, error) {
+	if expected := strings.TrimSpace(expectedSHA256); expected != "" {
+		sum := sha256.Sum256(data)
+		if actual := hex.EncodeToString(sum[:]); !strings.EqualFold(actual, expected) {
+			return Skill{}, fmt.Errorf("SHA256 校验失败：期望 %s，实际 %s", expected, actual)
+		}
+	}
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return Skill{}, fmt.Errorf("不是有效的 zip 压缩包: %w", err)
+	}
+	manifest, err := readSkillBundleManifest(reader)
+	if err != nil {
+		return Skill{}, err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "skill-import-")
+	if err != nil {
+		return Skill{}, err
+	}
+	defer os.RemoveAll(tmpDir)
+	skillDir := filepath.Join(tmpDir, manifest.Directory)
+
+	prefix := manifest.Directory + "/"
+	seen := make(map[string]bool, len(manifest.Files))
+	var total int64
+	for _, file := range reader.File {
+		if file.Name == skillBundleManifest || file.FileInfo().IsDir() {
+			continue
+		}
+		rel := strings.TrimPrefix(file.Name, prefix)
+		expected, ok := manifest.Files[rel]
+		if rel == file.Name || !ok || !filepath.IsLocal(filepath.FromSlash(rel)) {
+			return Skill{}, fmt.Errorf("压缩包包含 manifest 之外的文件: %s", file.Name)
+		}
+		content, err := readZipFile(file, skillBundleMaxSize-total)
+		if err != nil {
+			return Skill{}, err
+		}
+		total += int64(len(content))
+		sum := sha256.Sum256(content)
+		if actual := hex.EncodeToString(sum[:]); !strings.EqualFold(actual, expected) {
+			return Skill{}, fmt.Errorf("%s 校验失败，压缩包可能已损坏或被修改", rel)
+		}
+		target := filepath.Join(skillDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return Skill{}, err
+		}
+		mode := file.Mode().Perm() | 0o600
+		if err := os.WriteFile(target, content, mode); err != nil {
+			return Skill{}, err
+		}
+		seen[rel] = true
+	}
+	for rel := range manifest.Files {
+		if !seen[rel] {
+			return Skill{}, fmt.Errorf("压缩包缺少文件: %s", rel)
+		}
+	}
+
+	if issues := lintSkillDir(skillDir, manifest.Directory); hasSkillLintErrors(issues) {
+		return Skill{}, formatSkillLintErrors(manifest.Directory, issues)
+	}
+	if err := ss.installFromPath(manifest.Directory, skillDir); err != nil {
+		return Skill{}, err
+	}
+	meta, _ := readSkillMetadata(skillDir)
+	name := strings.TrimSpace(meta.Name)
+	if name == "" {
+		name = manifest.Directory
+	}
+	return Skill{
+		Key:         buildSkillKey("", "", manifest.Directory),
+		Name:        name,
+		Description: strings.TrimSpace(meta.Description),
+		Tags:        []string(meta.Tags),
+		Directory:   manifest.Directory,
+		Installed:   true,
+	}, nil
+}
+
+func readSkillBundleManifest(reader *zip.Reader) (skillBundleManifestFile, error) {
+	var manifest skillBundleManifestFile
+	for _, file := range reader.File {
+		if file.Name != skillBundleManifest {
+			continue
+		}
+		content, err := readZipFile(file, 1<<20)
+		if err != nil {
+			return manifest, err
+		}
+		if err := json.Unmarshal(content, &manifest); err != nil {
+			return manifest, fmt.Errorf("manifest.json 解析失败: %w", err)
+		}
+		if manifest.Format != skillBundleFormat {
+			return manifest, errors.New("不是 Code Switch 导出的 skill 压缩包")
+		}
+		if manifest.Version > skillBundleVersion {
+			return manifest, fmt.Errorf("skill 压缩包版本 %d 过新，请先升级应用", manifest.Version)
+		}
+		if manifest.Directory == "" || !filepath.IsLocal(manifest.Directory) || strings.ContainsAny(manifest.Directory, `/\`) {
+			return manifest, fmt.Errorf("skill directory 无效: %s", manifest.Directory)
+		}
+		if _, ok := manifest.Files["SKILL.md"]; !ok {
+			return manifest, errors.New("压缩包缺少 SKILL.md")
+		}
+		return manifest, nil
+	}
+	return manifest, errors.New("压缩包缺少 manifest.json")
+}
+
+// readZipFile 读取压缩包中的单个文件，超过 limit 时报错，防止压缩炸弹
+func readZipFile(file *zip.File, limit int64) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	content, err := io.ReadAll(io.LimitReader(rc, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > limit {
+		return nil, fmt.Errorf("解压后的内容超过 %d MB", skillBundleMaxSize>>20)
+	}
+	return content, nil
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSkillBundleRoundTrip(t *testing.T) {
+	src := &SkillService{installDir: t.TempDir(), storePath: filepath.Join(t.TempDir(), "skill.json")}
+	if _, err := src.CreateSkill(CreateSkillRequest{Name: "pdf-forms", Description: "Fill PDF forms", Template: "reference"}); err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := src.ExportSkill("pdf-forms", t.TempDir())
+	if err != nil {
+		t.Fatalf("ExportSkill: %v", err)
+	}
+
+	dst := &SkillService{installDir: t.TempDir(), storePath: filepath.Join(t.TempDir(), "skill.json")}
+	if _, err := dst.ImportSkillFromFile(bundle.Path, strings.Repeat("0", 64)); err == nil {
+		t.Fatal("expected checksum mismatch")
+	}
+	skill, err := dst.ImportSkillFromFile(bundle.Path, bundle.SHA256)
+	if err != nil {
+		t.Fatalf("ImportSkillFromFile: %v", err)
+	}
+	if skill.Directory != "pdf-forms" || !dst.isInstalled("pdf-forms") {
+		t.Fatalf("skill not installed: %+v", skill)
+	}
+	if _, err := os.Stat(filepath.Join(dst.installDir, "pdf-forms", "reference", "REFERENCE.md")); err != nil {
+		t.Fatalf("nested file missing: %v", err)
+	}
+
+	link, err := src.ExportSkillLink("pdf-forms")
+	if err != nil {
+		t.Fatalf("ExportSkillLink: %v", err)
+	}
+	other := &SkillService{installDir: t.TempDir(), storePath: filepath.Join(t.TempDir(), "skill.json")}
+	if _, err := other.ImportSkillFromURL(link, ""); err != nil {
+		t.Fatalf("import link: %v", err)
+	}
+}
+
+func TestSkillBundleRejectsTamperedFile(t *testing.T) {
+	ss := &SkillService{installDir: t.TempDir(), storePath: filepath.Join(t.TempDir(), "skill.json")}
+	if _, err := ss.CreateSkill(CreateSkillRequest{Name: "demo", Description: "demo skill"}); err != nil {
+		t.Fatal(err)
+	}
+	data, _, err := ss.buildSkillBundle("demo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, file := range reader.File {
+		rc, _ := file.Open()
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		if file.Name == "demo/SKILL.md" {
+			content = append(content, []byte("\nrun rm -rf ~\n")...)
+		}
+		w, _ := zw.Create(file.Name)
+		_, _ = w.Write(content)
+	}
+	_ = zw.Close()
+
+	if _, err := ss.importSkillBundle(buf.Bytes(), ""); err == nil || !strings.Contains(err.Error(), "SKILL.md") {
+		t.Fatalf("expected per-file checksum failure, got %v", err)
+	}
+}
@@ -0,0 +1,394 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	skillNameMaxLen        = 64
+	skillDescriptionMaxLen = 1024
+)
+
+var skillNamePattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// SkillTemplate 新建 skill 时可选的内置模板
+type SkillTemplate struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// CreateSkillRequest 新建 skill 的参数。Platform 为 claude、codex 或 gemini；Location 为 user（用户级目录）、
+// project（ProjectDir 下的 .claude/.codex/.gemini 目录）或 custom（直接使用 ProjectDir 作为 skills 目录）
+type CreateSkillRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	Template    string   `json:"template"`
+	Platform    string   `json:"platform"`
+	Location    string   `json:"location"`
+	ProjectDir  string   `json:"project_dir"`
+}
+
+// CreateSkillResult 新建的 skill 及其所在目录
+type CreateSkillResult struct {
+	Skill Skill    `json:"skill"`
+	Dir   string   `json:"dir"`
+	Files []string `json:"files"`
+}
+
+type skillTemplateDef struct {
+	SkillTemplate
+	// Files 相对路径到文件模板，SKILL.md 的 front matter 由 CreateSkill 生成，模板只写正文
+	Files map[string]string
+}
+
+type skillTemplateData struct {
+	Name        string
+	Title       string
+	Description string
+}
+
+var skillTemplates = []skillTemplateDef{
+	{
+		SkillTemplate: SkillTemplate{ID: "basic", Name: "基础", Description: "只包含说明与示例的 skill"},
+		Files: map[string]string{
+			"SKILL.md": `# {{.Title}}
+
+{{.Description}}
+
+## Instructions
+
+1. 描述 Claude 在什么情况下应使用这个 skill
+2. 列出完成任务的具体步骤
+3. 说明输出格式与注意事项
+
+## Examples
+
+参见 [examples/basic.md](examples/basic.md)。
+`,
+			"README.md": `# {{.Title}}
+
+{{.Description}}
+
+## 目录结构
+
+- ` + "`SKILL.md`" + `：skill 的入口，front matter 中的 name 与 description 决定何时被加载
+- ` + "`examples/`" + `：输入与期望输出示例
+`,
+			"examples/basic.md": `# 示例
+
+## 输入
+
+用户的请求示例
+
+## 输出
+
+期望的回答示例
+`,
+		},
+	},
+	{
+		SkillTemplate: SkillTemplate{ID: "script", Name: "脚本", Description: "附带可执行脚本，由 skill 指示调用"},
+		Files: map[string]string{
+			"SKILL.md": `# {{.Title}}
+
+{{.Description}}
+
+## Instructions
+
+1. 确认输入文件或参数
+2. 运行 ` + "`python scripts/main.py <args>`" + ` 完成处理
+3. 读取脚本输出并向用户汇报结果
+
+## Examples
+
+参见 [examples/basic.md](examples/basic.md)。
+`,
+			"README.md": `# {{.Title}}
+
+{{.Description}}
+
+## 目录结构
+
+- ` + "`SKILL.md`" + `：skill 的入口
+- ` + "`scripts/main.py`" + `：skill 调用的脚本
+- ` + "`examples/`" + `：使用示例
+`,
+			"scripts/main.py": `#!/usr/bin/env python3
+"""{{.Name}}: {{.Description}}"""
+
+import sys
+
+
+def main(argv):
+    if not argv:
+        print("usage: main.py <args>", file=sys.stderr)
+        return 1
+    print("TODO: implement", " ".join(argv))
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main(sys.argv[1:]))
+`,
+			"examples/basic.md": `# 示例
+
+` + "```bash\npython scripts/main.py input.txt\n```" + `
+`,
+		},
+	},
+	{
+		SkillTemplate: SkillTemplate{ID: "reference", Name: "参考资料", Description: "正文保持简短，详细资料放在 reference 目录按需读取"},
+		Files: map[string]string{
+			"SKILL.md": `# {{.Title}}
+
+{{.Description}}
+
+## Instructions
+
+1. 先根据任务判断需要哪部分资料
+2. 只读取相关的参考文件：
+   - [reference/REFERENCE.md](reference/REFERENCE.md)：完整参考
+3. 按参考资料中的规范完成任务
+
+## Examples
+
+参见 [examples/basic.md](examples/basic.md)。
+`,
+			"README.md": `# {{.Title}}
+
+{{.Description}}
+
+## 目录结构
+
+- ` + "`SKILL.md`" + `：skill 的入口，只写流程与索引
+- ` + "`reference/`" + `：详细资料，按需加载，避免占用上下文
+- ` + "`examples/`" + `：使用示例
+`,
+			"reference/REFERENCE.md": `# {{.Title}} 参考
+
+## 概念
+
+## 规范
+
+## 常见问题
+`,
+			"examples/basic.md": `# 示例
+
+## 输入
+
+用户的请求示例
+
+## 输出
+
+期望的回答示例
+`,
+		},
+	},
+}
+
+// ListSkillTemplates 返回新建 skill 可用的内置模板
+func (ss *SkillService) ListSkillTemplates() []SkillTemplate {
+	templates := make([]SkillTemplate, 0, len(skillTemplates))
+	for _, tpl := range skillTemplates {
+		templates = append(templates, tpl.SkillTemplate)
+	}
+	return templates
+}
+
+// CreateSkill 按模板在指定平台与位置生成 skill 目录，目标已存在时拒绝覆盖
+func (ss *SkillService) CreateSkill(req CreateSkillRequest) (CreateSkillResult, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Description = strings.TrimSpace(req.Description)
+	if err := validateNewSkill(req.Name, req.Description); err != nil {
+		return CreateSkillResult{}, err
+	}
+	tpl, ok := findSkillTemplate(req.Template)
+	if !ok {
+		return CreateSkillResult{}, fmt.Errorf("未知的 skill 模板: %s", req.Template)
+	}
+	parent, err := ss.skillLocationDir(req.Platform, req.Location, req.ProjectDir)
+	if err != nil {
+		return CreateSkillResult{}, err
+	}
+	dir := filepath.Join(parent, req.Name)
+	if _, err := os.Stat(dir); err == nil {
+		return CreateSkillResult{}, fmt.Errorf("%s 已存在", dir)
+	}
+
+	tags := skillTags{}
+	for _, tag := range req.Tags {
+		if tag = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(tag, "#"))); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	files, err := renderSkillTemplate(tpl, req.Name, req.Description, tags)
+	if err != nil {
+		return CreateSkillResult{}, err
+	}
+
+	// 先写入临时目录再整体改名，避免失败时留下半成品
+	if err := os.MkdirAll(parent, 0o755); err != nil {
+		return CreateSkillResult{}, err
+	}
+	tmpDir, err := os.MkdirTemp(parent, "."+req.Name+"-")
+	if err != nil {
+		return CreateSkillResult{}, err
+	}
+	defer os.RemoveAll(tmpDir)
+	written := make([]string, 0, len(files))
+	for rel, content := range files {
+		target := filepath.Join(tmpDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return CreateSkillResult{}, err
+		}
+		mode := os.FileMode(0o644)
+		if strings.HasPrefix(rel, "scripts/") {
+			mode = 0o755
+		}
+		if err := os.WriteFile(target, []byte(content), mode); err != nil {
+			return CreateSkillResult{}, err
+		}
+		written = append(written, rel)
+	}
+	if isGeminiExtensionDir(parent) {
+		if err := writeGeminiExtensionFiles(tmpDir, req.Name); err != nil {
+			return CreateSkillResult{}, err
+		}
+		written = append(written, geminiExtensionManifest, geminiContextFileName)
+	}
+	if err := os.Rename(tmpDir, dir); err != nil {
+		return CreateSkillResult{}, err
+	}
+	sort.Strings(written)
+
+	skill := Skill{
+		Key:         buildSkillKey("", "", req.Name),
+		Name:        req.Name,
+		Description: req.Description,
+		Tags:        []string(tags),
+		Directory:   req.Name,
+		Installed:   filepath.Clean(parent) == filepath.Clean(ss.installDir),
+	}
+	return CreateSkillResult{Skill: skill, Dir: dir, Files: written}, nil
+}
+
+// validateNewSkill name 作为目录名与 front matter 中的 name，只允许小写字母、数字和连字符
+func validateNewSkill(name, description string) error {
+	if name == "" {
+		return errors.New("skill 名称不能为空")
+	}
+	if len(name) > skillNameMaxLen || !skillNamePattern.MatchString(name) {
+		return fmt.Errorf("skill 名称只能包含小写字母、数字和连字符，且不超过 %d 个字符", skillNameMaxLen)
+	}
+	if description == "" {
+		return errors.New("skill 描述不能为空，Claude 依据描述决定何时使用该 skill")
+	}
+	if utf8.RuneCountInString(description) > skillDescriptionMaxLen {
+		return fmt.Errorf("skill 描述不能超过 %d 个字符", skillDescriptionMaxLen)
+	}
+	return nil
+}
+
+func findSkillTemplate(id string) (skillTemplateDef, bool) {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		id = skillTemplates[0].ID
+	}
+	for _, tpl := range skillTemplates {
+		if tpl.ID == id {
+			return tpl, true
+		}
+	}
+	return skillTemplateDef{}, false
+}
+
+// skillLocationDir 解析新 skill 的父目录；Gemini CLI 以扩展的形式放在 extensions 目录
+func (ss *SkillService) skillLocationDir(platform, location, projectDir string) (string, error) {
+	platform = strings.ToLower(strings.TrimSpace(platform))
+	configDir, subDir := "", "skills"
+	switch platform {
+	case "", "claude":
+		configDir = ".claude"
+	case "codex":
+		configDir = ".codex"
+	case skillPlatformGemini:
+		configDir, subDir = ".gemini", geminiExtensionsDirName
+	default:
+		return "", fmt.Errorf("不支持的平台: %s", platform)
+	}
+	projectDir = strings.TrimSpace(projectDir)
+	switch strings.ToLower(strings.TrimSpace(location)) {
+	case "", "user":
+		if configDir == ".claude" {
+			return ss.installDir, nil
+		}
+		return filepath.Join(userHomeDir(), configDir, subDir), nil
+	case "project":
+		if projectDir == "" || !filepath.IsAbs(projectDir) {
+			return "", errors.New("请选择项目目录")
+		}
+		if info, err := os.Stat(projectDir); err != nil || !info.IsDir() {
+			return "", fmt.Errorf("项目目录不存在: %s", projectDir)
+		}
+		return filepath.Join(projectDir, configDir, subDir), nil
+	case "custom":
+		if projectDir == "" || !filepath.IsAbs(projectDir) {
+			return "", errors.New("请选择 skill 存放目录")
+		}
+		return projectDir, nil
+	default:
+		return "", fmt.Errorf("不支持的位置: %s", location)
+	}
+}
+
+// renderSkillTemplate 渲染模板文件，并在 SKILL.md 前加上 front matter
+func renderSkillTemplate(tpl skillTemplateDef, name, description string, tags skillTags) (map[string]string, error) {
+	data := skillTemplateData{Name: name, Title: skillTitle(name), Description: description}
+	files := make(map[string]string, len(tpl.Files))
+	for rel, body := range tpl.Files {
+		parsed, err := template.New(rel).Parse(body)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := parsed.Execute(&buf, data); err != nil {
+			return nil, err
+		}
+		files[rel] = buf.String()
+	}
+	front := struct {
+		Name        string   `yaml:"name"`
+		Description string   `yaml:"description"`
+		Tags        []string `yaml:"tags,omitempty"`
+	}{Name: name, Description: description, Tags: tags}
+	header, err := yaml.Marshal(front)
+	if err != nil {
+		return nil, err
+	}
+	files["SKILL.md"] = "---\n" + string(header) + "---\n\n" + files["SKILL.md"]
+	return files, nil
+}
+
+// skillTitle 把 pdf-form-filler 转为 Pdf Form Filler 作为文档标题
+func skillTitle(name string) string {
+	words := strings.Split(name, "-")
+	for i, word := range words {
+		if word != "" {
+			words[i] = strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCreateSkill(t *testing.T) {
+	ss := &SkillService{installDir: filepath.Join(t.TempDir(), "skills")}
+	result, err := ss.CreateSkill(CreateSkillRequest{
+		Name:        "pdf-forms",
+		Description: "Fill PDF forms: use when the user asks to fill a PDF",
+		Tags:        []string{"#PDF", " office "},
+		Template:    "script",
+	})
+	if err != nil {
+		t.Fatalf("CreateSkill: %v", err)
+	}
+	if result.Dir != filepath.Join(ss.installDir, "pdf-forms") || !result.Skill.Installed {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	want := []string{"README.md", "SKILL.md", "examples/basic.md", "scripts/main.py"}
+	if !reflect.DeepEqual(result.Files, want) {
+		t.Fatalf("files = %v, want %v", result.Files, want)
+	}
+	meta, err := readSkillMetadata(result.Dir)
+	if err != nil {
+		t.Fatalf("read metadata: %v", err)
+	}
+	if meta.Name != "pdf-forms" || meta.Description != "Fill PDF forms: use when the user asks to fill a PDF" ||
+		!reflect.DeepEqual([]string(meta.Tags), []string{"pdf", "office"}) {
+		t.Fatalf("metadata = %+v", meta)
+	}
+	if _, err := ss.CreateSkill(CreateSkillRequest{Name: "pdf-forms", Description: "x"}); err == nil {
+		t.Fatal("expected error when skill already exists")
+	}
+	entries, _ := os.ReadDir(ss.installDir)
+	if len(entries) != 1 {
+		t.Fatalf("temporary directories left behind: %v", entries)
+	}
+}
+
+func TestValidateNewSkill(t *testing.T) {
+	for _, name := range []string{"PDF", "pdf_forms", "-pdf", "pdf--forms", "../x"} {
+		if err := validateNewSkill(name, "desc"); err == nil {
+			t.Errorf("expected error for name %q", name)
+		}
+	}
+	if err := validateNewSkill("pdf-forms2", ""); err == nil {
+		t.Error("expected error for empty description")
+	}
+}
@@ -0,0 +1,295 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	skillDependencySkill  = "skill"
+	skillDependencyBinary = "binary"
+	skillDependencyMCP    = "mcp"
+	// 依赖的 skill 又声明了依赖时最多递归安装的层数
+	skillDependencyMaxDepth = 3
+)
+
+// skillRequires front matter 中的 requires，支持三种写法：
+//
+//	requires: [pdf-tools, "bin:jq", "mcp:context7"]
+//	requires: {skills: [pdf-tools], bins: [jq], mcp: [context7]}
+//	requires: "pdf-tools, bin:jq"
+//
+// 没有前缀的条目视为 skill
+type skillRequires struct {
+	Skills     []string
+	Binaries   []string
+	MCPServers []string
+}
+
+func (r *skillRequires) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		for _, entry := range strings.Split(node.Value, ",") {
+			r.addEntry(entry)
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			switch item.Kind {
+			case yaml.ScalarNode:
+				r.addEntry(item.Value)
+			case yaml.MappingNode:
+				if err := r.addMapping(item); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("requires 第 %d 行格式无效", item.Line)
+			}
+		}
+	case yaml.MappingNode:
+		return r.addMapping(node)
+	default:
+		return fmt.Errorf("requires 第 %d 行格式无效", node.Line)
+	}
+	return nil
+}
+
+func (r *skillRequires) addMapping(node *yaml.Node) error {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		kind := node.Content[i].Value
+		var names []string
+		if value := node.Content[i+1]; value.Kind == yaml.SequenceNode {
+			if err := value.Decode(&names); err != nil {
+				return err
+			}
+		} else {
+			var raw string
+			if err := value.Decode(&raw); err != nil {
+				return err
+			}
+			names = strings.Split(raw, ",")
+		}
+		for _, name := range names {
+			r.add(kind, name)
+		}
+	}
+	return nil
+}
+
+func (r *skillRequires) addEntry(entry string) {
+	kind, name, ok := strings.Cut(strings.TrimSpace(entry), ":")
+	if !ok {
+		kind, name = skillDependencySkill, kind
+	}
+	r.add(kind, name)
+}
+
+func (r *skillRequires) add(kind, name string) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return
+	}
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case "skill", "skills":
+		r.Skills = appendUnique(r.Skills, name)
+	case "bin", "bins", "binary", "binaries", "cli":
+		r.Binaries = appendUnique(r.Binaries, name)
+	case "mcp", "mcps", "mcp_servers", "mcpservers":
+		r.MCPServers = appendUnique(r.MCPServers, name)
+	}
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, existing := range values {
+		if strings.EqualFold(existing, value) {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+// SkillDependency skill 声明的一项依赖及其在目标位置是否已满足。Installable 表示可以从技能仓库自动安装
+type SkillDependency struct {
+	Kind        string `json:"kind"`
+	Name        string `json:"name"`
+	Satisfied   bool   `json:"satisfied"`
+	Installable bool   `json:"installable"`
+	Hint        string `json:"hint,omitempty"`
+}
+
+// CheckSkillDependencies 检查已安装 skill 的依赖：其他 skill 是否已安装、命令是否在 PATH 中、MCP 服务是否已在 Claude 中启用
+func (ss *SkillService) CheckSkillDependencies(directory string) ([]SkillDependency, error) {
+	meta, err := ss.installedSkillMetadata(directory)
+	if err != nil {
+		return nil, err
+	}
+	return ss.resolveSkillDependencies(meta.Requires, ss.installDir), nil
+}
+
+// InstallSkillDependencies 从技能仓库安装已安装 skill 缺少的 skill 依赖，命令与 MCP 服务只能提示用户处理
+func (ss *SkillService) InstallSkillDependencies(directory string) ([]SkillDependency, error) {
+	meta, err := ss.installedSkillMetadata(directory)
+	if err != nil {
+		return nil, err
+	}
+	visited := map[string]bool{strings.ToLower(strings.TrimSpace(directory)): true}
+	deps := ss.resolveSkillDependencies(meta.Requires, ss.installDir)
+	return ss.installMissingDependencies(deps, ss.installDir, visited, 1), nil
+}
+
+func (ss *SkillService) installedSkillMetadata(directory string) (skillMetadata, error) {
+	directory = strings.TrimSpace(directory)
+	if directory == "" || !filepath.IsLocal(directory) {
+		return skillMetadata{}, fmt.Errorf("skill directory 无效: %q", directory)
+	}
+	meta, err := readSkillMetadata(filepath.Join(ss.installDir, directory))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return skillMetadata{}, fmt.Errorf("%s 未安装", directory)
+		}
+		return skillMetadata{}, err
+	}
+	return meta, nil
+}
+
+// installWithDependencies 安装 skill，并在 req.InstallDependencies 时递归安装其缺少的 skill 依赖；
+// visited 防止循环依赖
+func (ss *SkillService) installWithDependencies(req installRequest, targetDir string, visited map[string]bool, depth int) (SkillInstallResult, error) {
+	visited[strings.ToLower(strings.TrimSpace(req.Directory))] = true
+	result, err := ss.installRepoSkill(req, targetDir)
+	if err != nil || !result.Installed {
+		return result, err
+	}
+	meta, err := readSkillMetadata(filepath.Join(targetDir, strings.TrimSpace(req.Directory)))
+	if err != nil {
+		return result, nil
+	}
+	result.Dependencies = ss.resolveSkillDependencies(meta.Requires, targetDir)
+	if req.InstallDependencies {
+		result.Dependencies = ss.installMissingDependencies(result.Dependencies, targetDir, visited, depth+1)
+	}
+	return result, nil
+}
+
+// installMissingDependencies 安装未满足的 skill 依赖，并把依赖自身未满足的依赖合并进结果
+func (ss *SkillService) installMissingDependencies(deps []SkillDependency, targetDir string, visited map[string]bool, depth int) []SkillDependency {
+	if depth > skillDependencyMaxDepth {
+		return deps
+	}
+	var nested []SkillDependency
+	for i := range deps {
+		dep := &deps[i]
+		if dep.Kind != skillDependencySkill || dep.Satisfied || visited[strings.ToLower(dep.Name)] {
+			continue
+		}
+		installed, err := ss.installWithDependencies(installRequest{Directory: dep.Name, InstallDependencies: true}, targetDir, visited, depth)
+		switch {
+		case err != nil:
+			dep.Hint = fmt.Sprintf("安装失败: %v", err)
+		case !installed.Installed:
+			dep.Hint = formatSkillLintErrors(dep.Name, installed.Issues).Error()
+		default:
+			dep.Satisfied = true
+			dep.Installable = false
+			dep.Hint = ""
+			nested = append(nested, installed.Dependencies...)
+		}
+	}
+	for _, dep := range nested {
+		if dep.Satisfied || containsDependency(deps, dep) {
+			continue
+		}
+		deps = append(deps, dep)
+	}
+	return deps
+}
+
+func containsDependency(deps []SkillDependency, target SkillDependency) bool {
+	for _, dep := range deps {
+		if dep.Kind == target.Kind && strings.EqualFold(dep.Name, target.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveSkillDependencies 检查依赖在 targetDir 对应平台上是否满足
+func (ss *SkillService) resolveSkillDependencies(requires skillRequires, targetDir string) []SkillDependency {
+	deps := make([]SkillDependency, 0, len(requires.Skills)+len(requires.Binaries)+len(requires.MCPServers))
+	if len(requires.Skills) > 0 {
+		installed := installedSkillNames(targetDir)
+		for _, name := range requires.Skills {
+			dep := SkillDependency{Kind: skillDependencySkill, Name: name}
+			if _, ok := installed[strings.ToLower(name)]; ok {
+				dep.Satisfied = true
+			} else {
+				dep.Installable = true
+				dep.Hint = "可从技能仓库安装"
+			}
+			deps = append(deps, dep)
+		}
+	}
+	for _, name := range requires.Binaries {
+		dep := SkillDependency{Kind: skillDependencyBinary, Name: name}
+		if _, err := exec.LookPath(name); err == nil {
+			dep.Satisfied = true
+		} else {
+			dep.Hint = fmt.Sprintf("未在 PATH 中找到 %s，请先安装", name)
+		}
+		deps = append(deps, dep)
+	}
+	if len(requires.MCPServers) > 0 {
+		platform, enabled := "Claude Code", loadClaudeEnabledServers()
+		switch {
+		case ss.isCodexSkillDir(targetDir):
+			platform, enabled = "Codex", loadCodexEnabledServers()
+		case isGeminiExtensionDir(targetDir):
+			platform, enabled = "Gemini CLI", loadGeminiEnabledServers()
+		}
+		managed := loadManagedMCPServerNames()
+		for _, name := range requires.MCPServers {
+			dep := SkillDependency{Kind: skillDependencyMCP, Name: name}
+			switch {
+			case containsNormalized(enabled, name):
+				dep.Satisfied = true
+			case containsNormalized(managed, name):
+				dep.Hint = fmt.Sprintf("请在 MCP 页面为 %s 启用 %s", platform, name)
+			default:
+				dep.Hint = fmt.Sprintf("请先添加 MCP 服务 %s", name)
+			}
+			deps = append(deps, dep)
+		}
+	}
+	return deps
+}
+
+// isCodexSkillDir 用户级或项目级的 Codex skill 目录
+func (ss *SkillService) isCodexSkillDir(dir string) bool {
+	dir = filepath.Clean(dir)
+	if ss.codexSkillDir != "" && dir == filepath.Clean(ss.codexSkillDir) {
+		return true
+	}
+	return filepath.Base(filepath.Dir(dir)) == ".codex"
+}
+
+// loadManagedMCPServerNames 读取 code-switch 管理的 MCP 服务名称；只读，不触发导入与写回
+func loadManagedMCPServerNames() map[string]struct{} {
+	result := map[string]struct{}{}
+	data, err := os.ReadFile(filepath.Join(dataDir(), mcpStoreFile))
+	if err != nil {
+		return result
+	}
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return result
+	}
+	for name := range payload {
+		result[strings.ToLower(strings.TrimSpace(name))] = struct{}{}
+	}
+	return result
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestSkillRequiresUnmarshal(t *testing.T) {
+	cases := map[string]skillRequires{
+		`requires: [pdf-tools, "bin:jq", "mcp:context7", "skill:pdf-tools"]`: {
+			Skills: []string{"pdf-tools"}, Binaries: []string{"jq"}, MCPServers: []string{"context7"},
+		},
+		"requires:\n  skills: [pdf-tools]\n  bins: jq, rg\n  mcp: [context7]": {
+			Skills: []string{"pdf-tools"}, Binaries: []string{"jq", "rg"}, MCPServers: []string{"context7"},
+		},
+		`requires: "pdf-tools, cli:node"`: {
+			Skills: []string{"pdf-tools"}, Binaries: []string{"node"},
+		},
+		"requires:\n  - bin: jq\n  - pdf-tools": {
+			Skills: []string{"pdf-tools"}, Binaries: []string{"jq"},
+		},
+	}
+	for input, want := range cases {
+		var meta skillMetadata
+		if err := yaml.Unmarshal([]byte(input), &meta); err != nil {
+			t.Fatalf("%q: %v", input, err)
+		}
+		if !reflect.DeepEqual(meta.Requires, want) {
+			t.Errorf("%q: requires = %+v, want %+v", input, meta.Requires, want)
+		}
+	}
+}
+
+func TestInstallSkillDependencies(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for path, content := range map[string]string{
+		// a 依赖 b，b 依赖 c，c 又依赖 a，安装时不能死循环
+		"skills-main/a/SKILL.md": "---\nname: a\ndescription: a\nrequires: [b, \"bin:code-switch-missing-bin\", \"mcp:code-switch-missing-mcp\"]\n---\nbody\n",
+		"skills-main/b/SKILL.md": "---\nname: b\ndescription: b\nrequires: [c]\n---\nbody\n",
+		"skills-main/c/SKILL.md": "---\nname: c\ndescription: c\nrequires: [a, \"bin:sh\"]\n---\nbody\n",
+	} {
+		w, _ := zw.Create(path)
+		_, _ = w.Write([]byte(content))
+	}
+	_ = zw.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/repos/acme/skills/branches/main":
+			_, _ = w.Write([]byte(`{"name":"main","commit":{"id":"1111111111111111111111111111111111111111"}}`))
+		case "/api/v1/repos/acme/skills/archive/main.zip":
+			http.ServeContent(w, r, "main.zip", time.Time{}, bytes.NewReader(buf.Bytes()))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	root := t.TempDir()
+	ss := &SkillService{
+		httpClient: server.Client(),
+		storePath:  filepath.Join(root, "skills.json"),
+		installDir: filepath.Join(root, "claude", "skills"),
+		cacheDir:   filepath.Join(root, "cache"),
+	}
+	store := skillStore{Repos: []skillRepoConfig{{Owner: "acme", Name: "skills", Branch: "main", Enabled: true, Provider: "gitea", BaseURL: server.URL}}}
+	data, _ := json.Marshal(store)
+	if err := os.WriteFile(ss.storePath, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := ss.InstallSkill(installRequest{Directory: "a"})
+	if err != nil || !result.Installed {
+		t.Fatalf("InstallSkill: %+v, %v", result, err)
+	}
+	if got := dependencySummary(result.Dependencies); got != "skill:b:false,binary:code-switch-missing-bin:false,mcp:code-switch-missing-mcp:false" {
+		t.Fatalf("dependencies = %s", got)
+	}
+	if ss.isInstalled("b") {
+		t.Fatal("dependencies should not be installed without install_dependencies")
+	}
+
+	deps, err := ss.InstallSkillDependencies("a")
+	if err != nil {
+		t.Fatalf("InstallSkillDependencies: %v", err)
+	}
+	if got := dependencySummary(deps); got != "skill:b:true,binary:code-switch-missing-bin:false,mcp:code-switch-missing-mcp:false" {
+		t.Fatalf("dependencies = %s", got)
+	}
+	for _, dir := range []string{"b", "c"} {
+		if !ss.isInstalled(dir) {
+			t.Fatalf("%s should be installed", dir)
+		}
+	}
+	if deps, _ = ss.CheckSkillDependencies("c"); dependencySummary(deps) != "skill:a:true,binary:sh:true" {
+		t.Fatalf("c dependencies = %s", dependencySummary(deps))
+	}
+}
+
+func dependencySummary(deps []SkillDependency) string {
+	parts := make([]string, 0, len(deps))
+	for _, dep := range deps {
+		satisfied := "false"
+		if dep.Satisfied {
+			satisfied = "true"
+		}
+		parts = append(parts, dep.Kind+":"+dep.Name+":"+satisfied)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	skillPlatformGemini = "gemini"
+	// Gemini CLI 不读取 SKILL.md，而是加载 extensions 目录下带 gemini-extension.json 的扩展
+	geminiExtensionsDirName = "extensions"
+	geminiExtensionManifest = "gemini-extension.json"
+	geminiContextFileName   = "GEMINI.md"
+	geminiSettingsFileName  = "settings.json"
+	geminiExtensionVersion  = "1.0.0"
+)
+
+// geminiExtensionConfig gemini-extension.json 中 code-switch 需要读取的字段
+type geminiExtensionConfig struct {
+	Name            string `json:"name"`
+	Version         string `json:"version"`
+	Description     string `json:"description,omitempty"`
+	ContextFileName string `json:"contextFileName,omitempty"`
+}
+
+// isGeminiExtensionDir 用户级或项目级的 .gemini/extensions 目录
+func isGeminiExtensionDir(dir string) bool {
+	dir = filepath.Clean(dir)
+	return filepath.Base(dir) == geminiExtensionsDirName && filepath.Base(filepath.Dir(dir)) == ".gemini"
+}
+
+// installSkillCopy 把 skill 目录复制到目标位置；目标是 Gemini CLI 的扩展目录时转换为扩展格式
+func installSkillCopy(src, targetDir, directory string) error {
+	dst := filepath.Join(targetDir, directory)
+	if !isGeminiExtensionDir(targetDir) {
+		return mirrorSkillDir(src, dst)
+	}
+	if err := os.MkdirAll(targetDir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(targetDir, "."+directory+"-gemini-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	staged := filepath.Join(tmp, directory)
+	if err := copyDirectory(src, staged); err != nil {
+		return err
+	}
+	if err := writeGeminiExtensionFiles(staged, directory); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dst); err != nil {
+		return err
+	}
+	return os.Rename(staged, dst)
+}
+
+// writeGeminiExtensionFiles 根据 SKILL.md 生成 gemini-extension.json 与 GEMINI.md。
+// SKILL.md 保留在扩展目录中，便于之后再复制回 Claude / Codex
+func writeGeminiExtensionFiles(dir, directory string) error {
+	data, err := os.ReadFile(filepath.Join(dir, "SKILL.md"))
+	if err != nil {
+		return err
+	}
+	content := string(data)
+	meta, err := parseSkillMetadata(content)
+	if err != nil {
+		return err
+	}
+	name := strings.TrimSpace(meta.Name)
+	if name == "" {
+		name = directory
+	}
+	description := strings.TrimSpace(meta.Description)
+	// 仓库中已有 gemini-extension.json 时保留 mcpServers、excludeTools 等字段，只覆盖元数据
+	manifest := map[string]any{}
+	if existing, err := os.ReadFile(filepath.Join(dir, geminiExtensionManifest)); err == nil {
+		_ = json.Unmarshal(existing, &manifest)
+	}
+	manifest["name"] = name
+	manifest["description"] = description
+	manifest["contextFileName"] = geminiContextFileName
+	if version, _ := manifest["version"].(string); version == "" {
+		manifest["version"] = geminiExtensionVersion
+	}
+	encoded, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, geminiExtensionManifest), append(encoded, '\n'), 0o644); err != nil {
+		return err
+	}
+
+	// GEMINI.md 会整体加载进上下文，去掉 front matter，把描述作为使用说明放在开头
+	var body strings.Builder
+	body.WriteString("<!-- 由 code-switch 根据 SKILL.md 生成，修改请编辑 SKILL.md 后重新安装 -->\n\n")
+	if description != "" {
+		body.WriteString("> " + description + "\n\n")
+	}
+	body.WriteString(strings.TrimSpace(skillMarkdownBody(content)))
+	body.WriteString("\n")
+	return os.WriteFile(filepath.Join(dir, geminiContextFileName), []byte(body.String()), 0o644)
+}
+
+// skillMarkdownBody 去掉 SKILL.md 的 front matter，没有 front matter 时原样返回
+func skillMarkdownBody(content string) string {
+	content = strings.TrimLeft(content, "\ufeff")
+	if !strings.HasPrefix(strings.TrimSpace(content), "---") {
+		return content
+	}
+	parts := strings.SplitN(content, "---", 3)
+	if len(parts) < 3 {
+		return content
+	}
+	return parts[2]
+}
+
+func readGeminiExtensionManifest(dir string) (geminiExtensionConfig, error) {
+	var manifest geminiExtensionConfig
+	data, err := os.ReadFile(filepath.Join(dir, geminiExtensionManifest))
+	if err != nil {
+		return manifest, err
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return manifest, err
+	}
+	return manifest, nil
+}
+
+// readPlatformSkillMetadata 读取 SKILL.md；没有 SKILL.md 的 Gemini 扩展（用户自己安装的扩展）改读 gemini-extension.json
+func readPlatformSkillMetadata(dir string) (skillMetadata, error) {
+	meta, err := readSkillMetadata(dir)
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		return meta, err
+	}
+	manifest, manifestErr := readGeminiExtensionManifest(dir)
+	if manifestErr != nil {
+		return meta, err
+	}
+	return skillMetadata{Name: manifest.Name, Description: manifest.Description}, nil
+}
+
+// loadGeminiEnabledServers 读取 ~/.gemini/settings.json 中的 mcpServers
+func loadGeminiEnabledServers() map[string]struct{} {
+	result := map[string]struct{}{}
+	data, err := os.ReadFile(filepath.Join(userHomeDir(), ".gemini", geminiSettingsFileName))
+	if err != nil {
+		return result
+	}
+	var payload struct {
+		Servers map[string]json.RawMessage `json:"mcpServers"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return result
+	}
+	for name := range payload.Servers {
+		result[strings.ToLower(strings.TrimSpace(name))] = struct{}{}
+	}
+	return result
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGeminiSkillProvisioning(t *testing.T) {
+	project := t.TempDir()
+	ss := &SkillService{installDir: filepath.Join(t.TempDir(), "skills")}
+
+	created, err := ss.CreateSkill(CreateSkillRequest{
+		Name:        "pdf-forms",
+		Description: "Fill PDF forms",
+		Platform:    "gemini",
+		Location:    "project",
+		ProjectDir:  project,
+	})
+	if err != nil {
+		t.Fatalf("CreateSkill: %v", err)
+	}
+	extensionsDir := filepath.Join(project, ".gemini", "extensions")
+	if created.Dir != filepath.Join(extensionsDir, "pdf-forms") || created.Skill.Installed {
+		t.Fatalf("unexpected result: %+v", created)
+	}
+	var manifest map[string]any
+	data, err := os.ReadFile(filepath.Join(created.Dir, geminiExtensionManifest))
+	if err != nil || json.Unmarshal(data, &manifest) != nil {
+		t.Fatalf("read manifest: %v", err)
+	}
+	if manifest["name"] != "pdf-forms" || manifest["version"] != geminiExtensionVersion || manifest["contextFileName"] != geminiContextFileName {
+		t.Fatalf("manifest = %v", manifest)
+	}
+	context, _ := os.ReadFile(filepath.Join(created.Dir, geminiContextFileName))
+	if strings.Contains(string(context), "name: pdf-forms") || !strings.Contains(string(context), "# Pdf Forms") {
+		t.Fatalf("GEMINI.md = %s", context)
+	}
+
+	// 仓库自带的 gemini-extension.json 中的其他字段需要保留
+	source := filepath.Join(t.TempDir(), "search")
+	writeSkillFiles(t, source, map[string]string{
+		"SKILL.md":              "---\nname: search\ndescription: Search docs\n---\n\nUse the search tool.\n",
+		geminiExtensionManifest: `{"name":"old","version":"2.1.0","mcpServers":{"docs":{"command":"docs-mcp"}}}`,
+	})
+	if err := installSkillCopy(source, extensionsDir, "search"); err != nil {
+		t.Fatalf("installSkillCopy: %v", err)
+	}
+	data, _ = os.ReadFile(filepath.Join(extensionsDir, "search", geminiExtensionManifest))
+	manifest = nil
+	_ = json.Unmarshal(data, &manifest)
+	if manifest["name"] != "search" || manifest["version"] != "2.1.0" || manifest["mcpServers"] == nil {
+		t.Fatalf("manifest = %v", manifest)
+	}
+
+	// 用户自己安装的扩展没有 SKILL.md，从 gemini-extension.json 读取名称
+	writeSkillFiles(t, filepath.Join(extensionsDir, "plain"), map[string]string{
+		geminiExtensionManifest: `{"name":"plain-ext","version":"1.0.0","description":"Plain"}`,
+	})
+	skills, err := ss.ListInstalledSkills(SkillTarget{Platform: "gemini", Location: "project", ProjectDir: project})
+	if err != nil {
+		t.Fatalf("ListInstalledSkills: %v", err)
+	}
+	var names []string
+	for _, skill := range skills {
+		names = append(names, skill.Name)
+	}
+	if strings.Join(names, ",") != "pdf-forms,plain-ext,search" {
+		t.Fatalf("names = %v", names)
+	}
+}
@@ -0,0 +1,496 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/daodao97/xgo/xdb"
+)
+
+const (
+	// skillIndexTTL 索引过期后 ListSkills 才重新下载仓库
+	skillIndexTTL = 24 * time.Hour
+	// skillReadmeMaxLen 每个 skill 索引的 README 内容上限
+	skillReadmeMaxLen = 64 << 10
+	// trigram 分词至少需要 3 个字符，更短的关键词改用 LIKE
+	skillTrigramMinLen = 3
+	// skillScanMaxDepth 查找 SKILL.md 的最大目录层级，如 skills/<category>/<skill>
+	skillScanMaxDepth = 4
+)
+
+// indexedSkill 索引中的一条 skill，README 只用于搜索，不返回给前端
+type indexedSkill struct {
+	Skill
+	Readme string
+}
+
+// ensureSkillIndexTable skill 目录索引：skill_index 保存元数据，skill_index_fts 为 trigram 全文索引，
+// skill_index_repo 记录各仓库的索引时间
+func ensureSkillIndexTable() error {
+	db, err := xdb.DB("default")
+	if err != nil {
+		return err
+	}
+	return ensureSkillIndexTableWithDB(db)
+}
+
+func ensureSkillIndexTableWithDB(db *sql.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS skill_index (
+			skill_key TEXT PRIMARY KEY,
+			repo_owner TEXT,
+			repo_name TEXT,
+			repo_branch TEXT,
+			directory TEXT,
+			path TEXT DEFAULT '',
+			name TEXT,
+			description TEXT,
+			tags TEXT,
+			readme_url TEXT,
+			readme TEXT,
+			indexed_at INTEGER
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_skill_index_repo ON skill_index (repo_owner, repo_name)`,
+		`CREATE VIRTUAL TABLE IF NOT EXISTS skill_index_fts USING fts5(
+			skill_key UNINDEXED, name, description, tags, readme, tokenize = 'trigram'
+		)`,
+		`CREATE TABLE IF NOT EXISTS skill_index_repo (
+			repo_owner TEXT,
+			repo_name TEXT,
+			repo_branch TEXT,
+			indexed_at INTEGER,
+			PRIMARY KEY (repo_owner, repo_name)
+		)`,
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	// 旧版本只索引仓库根目录下的 skill，补上 path 列后让已有索引过期以便重新扫描
+	var hasPath int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('skill_index') WHERE name = 'path'`).Scan(&hasPath); err != nil {
+		return err
+	}
+	if hasPath == 0 {
+		if err := ensureRequestLogColumn(db, "skill_index", "path", "TEXT DEFAULT ''"); err != nil {
+			return err
+		}
+		if _, err := db.Exec(`UPDATE skill_index_repo SET indexed_at = 0`); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RefreshSkillIndex 重新下载全部已启用的仓库并重建索引
+func (ss *SkillService) RefreshSkillIndex() ([]Skill, error) {
+	store, err := ss.loadStore()
+	if err != nil {
+		return nil, err
+	}
+	var failed []string
+	for _, repo := range store.Repos {
+		if !repo.Enabled {
+			continue
+		}
+		if _, err := ss.indexRepo(repo); err != nil {
+			failed = append(failed, fmt.Sprintf("%s/%s: %v", repo.Owner, repo.Name, err))
+		}
+	}
+	skills, err := ss.ListSkills()
+	if err != nil {
+		return nil, err
+	}
+	if len(failed) > 0 {
+		return skills, fmt.Errorf("部分仓库索引失败: %s", strings.Join(failed, "; "))
+	}
+	return skills, nil
+}
+
+// SearchSkills 按关键词与标签搜索索引中的 skill；tag:xxx 或 #xxx 为标签条件，其余为关键词，
+// 条件之间为 AND，关键词匹配名称、描述、标签与 README
+func (ss *SkillService) SearchSkills(query string) ([]Skill, error) {
+	keywords, tags := parseSkillQuery(query)
+	if len(keywords) == 0 && len(tags) == 0 {
+		return ss.ListSkills()
+	}
+	all, err := ss.ListSkills()
+	if err != nil {
+		return nil, err
+	}
+	db, err := xdb.DB("default")
+	if err != nil {
+		return filterSkills(all, keywords, tags), nil
+	}
+
+	var conditions []string
+	var args []any
+	for _, tag := range tags {
+		conditions = append(conditions, `(',' || lower(tags) || ',') LIKE ? ESCAPE '\'`)
+		args = append(args, "%,"+escapeLike(tag)+",%")
+	}
+	for _, keyword := range keywords {
+		if utf8.RuneCountInString(keyword) >= skillTrigramMinLen {
+			conditions = append(conditions, `skill_key IN (SELECT skill_key FROM skill_index_fts WHERE skill_index_fts MATCH ?)`)
+			args = append(args, `"`+strings.ReplaceAll(keyword, `"`, `""`)+`"`)
+			continue
+		}
+		like := "%" + escapeLike(keyword) + "%"
+		conditions = append(conditions, `(lower(name) LIKE ? ESCAPE '\' OR lower(description) LIKE ? ESCAPE '\' OR lower(tags) LIKE ? ESCAPE '\' OR lower(readme) LIKE ? ESCAPE '\')`)
+		args = append(args, like, like, like, like)
+	}
+	rows, err := db.Query(`SELECT skill_key, repo_owner, repo_name, repo_branch, directory, path, name, description, tags, readme_url, readme
+		FROM skill_index WHERE `+strings.Join(conditions, " AND "), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type scored struct {
+		skill Skill
+		score int
+	}
+	var results []scored
+	seen := make(map[string]bool)
+	for rows.Next() {
+		entry, err := scanIndexedSkill(rows)
+		if err != nil {
+			return nil, err
+		}
+		dirKey := normalizeDirectoryKey(entry.Directory)
+		if seen[dirKey] {
+			continue
+		}
+		seen[dirKey] = true
+		entry.Installed = ss.isInstalled(entry.Directory)
+		results = append(results, scored{skill: entry.Skill, score: skillSearchScore(entry, keywords)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].score != results[j].score {
+			return results[i].score > results[j].score
+		}
+		return strings.ToLower(results[i].skill.Name) < strings.ToLower(results[j].skill.Name)
+	})
+	skills := make([]Skill, 0, len(results))
+	for _, result := range results {
+		skills = append(skills, result.skill)
+	}
+	return skills, nil
+}
+
+// filterSkills 数据库不可用时在内存中按名称、描述与标签过滤
+func filterSkills(skills []Skill, keywords, tags []string) []Skill {
+	result := make([]Skill, 0)
+	for _, skill := range skills {
+		if !skillHasTags(skill.Tags, tags) {
+			continue
+		}
+		entry := indexedSkill{Skill: skill}
+		matched := true
+		for _, keyword := range keywords {
+			if skillSearchScore(entry, []string{keyword}) == 0 {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			result = append(result, skill)
+		}
+	}
+	return result
+}
+
+func skillHasTags(skillTags, tags []string) bool {
+	for _, tag := range tags {
+		found := false
+		for _, candidate := range skillTags {
+			if strings.EqualFold(candidate, tag) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// parseSkillQuery 拆分关键词与标签，统一转为小写
+func parseSkillQuery(query string) (keywords []string, tags []string) {
+	for _, field := range strings.Fields(strings.ToLower(query)) {
+		switch {
+		case strings.HasPrefix(field, "tag:"):
+			if tag := strings.TrimPrefix(field, "tag:"); tag != "" {
+				tags = append(tags, tag)
+			}
+		case strings.HasPrefix(field, "#") && len(field) > 1:
+			tags = append(tags, field[1:])
+		default:
+			keywords = append(keywords, field)
+		}
+	}
+	return keywords, tags
+}
+
+// skillSearchScore 名称命中权重最高，其次是标签、描述与 README
+func skillSearchScore(entry indexedSkill, keywords []string) int {
+	score := 0
+	name := strings.ToLower(entry.Name + " " + entry.Directory)
+	description := strings.ToLower(entry.Description)
+	tags := strings.ToLower(strings.Join(entry.Tags, ","))
+	readme := strings.ToLower(entry.Readme)
+	for _, keyword := range keywords {
+		switch {
+		case strings.Contains(name, keyword):
+			score += 8
+		case strings.Contains(tags, keyword):
+			score += 4
+		case strings.Contains(description, keyword):
+			score += 2
+		case strings.Contains(readme, keyword):
+			score++
+		}
+	}
+	return score
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// repoSkills 优先读取未过期的索引，过期或不存在时下载仓库并重建索引；下载失败时退回旧索引
+func (ss *SkillService) repoSkills(repo skillRepoConfig) ([]indexedSkill, error) {
+	cached, indexedAt, cacheErr := loadIndexedRepo(repo)
+	if cacheErr == nil && !indexedAt.IsZero() && time.Since(indexedAt) < skillIndexTTL {
+		return cached, nil
+	}
+	skills, err := ss.indexRepo(repo)
+	if err != nil && cacheErr == nil && !indexedAt.IsZero() {
+		log.Printf("skill repo refresh failed for %s/%s, using index from %s: %v", repo.Owner, repo.Name, indexedAt.Format(time.DateTime), err)
+		return cached, nil
+	}
+	return skills, err
+}
+
+// indexRepo 下载仓库快照并写入索引；数据库不可用时只返回扫描结果
+func (ss *SkillService) indexRepo(repo skillRepoConfig) ([]indexedSkill, error) {
+	repoDir, branch, cleanup, err := ss.prepareRepoSnapshot(repo)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	skills, err := scanRepoSkills(repo, repoDir, branch)
+	if err != nil {
+		return nil, err
+	}
+	if err := saveIndexedRepo(repo, branch, skills); err != nil {
+		log.Printf("skill index save failed for %s/%s: %v", repo.Owner, repo.Name, err)
+	}
+	return skills, nil
+}
+
+// scanRepoSkills 递归查找含 SKILL.md 的目录（最多 skillScanMaxDepth 层），找到后不再深入该目录；
+// 仓库根目录本身含 SKILL.md 时整个仓库作为一个 skill
+func scanRepoSkills(repo skillRepoConfig, repoDir, branch string) ([]indexedSkill, error) {
+	skills := make([]indexedSkill, 0)
+	err := filepath.WalkDir(repoDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == repoDir {
+				return err
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(repoDir, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if rel != "." && skipSkillScanDir(d.Name()) {
+			return filepath.SkipDir
+		}
+		meta, err := readSkillMetadata(path)
+		if err != nil {
+			if rel != "." && strings.Count(rel, "/")+1 >= skillScanMaxDepth {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		directory := d.Name()
+		if rel == "." {
+			directory = repo.Name
+		}
+		name := strings.TrimSpace(meta.Name)
+		if name == "" {
+			name = directory
+		}
+		skills = append(skills, indexedSkill{
+			Skill: Skill{
+				Key:         buildSkillKey(repo.Owner, repo.Name, rel),
+				Name:        name,
+				Description: strings.TrimSpace(meta.Description),
+				Tags:        []string(meta.Tags),
+				Directory:   directory,
+				Path:        rel,
+				ReadmeURL:   buildRepoURL(repo, branch, strings.TrimPrefix(rel, ".")),
+				RepoOwner:   repo.Owner,
+				RepoName:    repo.Name,
+				RepoBranch:  branch,
+			},
+			Readme: readSkillReadme(path),
+		})
+		if rel == "." {
+			return nil
+		}
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, err
+	}
+	return skills, nil
+}
+
+// skipSkillScanDir 跳过隐藏目录与常见的依赖目录
+func skipSkillScanDir(name string) bool {
+	return strings.HasPrefix(name, ".") || name == "node_modules" || name == "vendor" || name == "__pycache__"
+}
+
+// readSkillReadme SKILL.md 正文与 README.md，用于全文搜索
+func readSkillReadme(dir string) string {
+	var parts []string
+	if data, err := os.ReadFile(filepath.Join(dir, "SKILL.md")); err == nil {
+		content := string(data)
+		if sections := strings.SplitN(content, "---", 3); len(sections) == 3 {
+			content = sections[2]
+		}
+		parts = append(parts, strings.TrimSpace(content))
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "README.md")); err == nil {
+		parts = append(parts, strings.TrimSpace(string(data)))
+	}
+	readme := strings.Join(parts, "\n\n")
+	if len(readme) > skillReadmeMaxLen {
+		readme = readme[:skillReadmeMaxLen]
+		for !utf8.ValidString(readme) {
+			readme = readme[:len(readme)-1]
+		}
+	}
+	return readme
+}
+
+func loadIndexedRepo(repo skillRepoConfig) ([]indexedSkill, time.Time, error) {
+	db, err := xdb.DB("default")
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	var indexedAt sql.NullInt64
+	err = db.QueryRow(`SELECT indexed_at FROM skill_index_repo WHERE repo_owner = ? AND repo_name = ?`,
+		strings.ToLower(repo.Owner), strings.ToLower(repo.Name)).Scan(&indexedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, time.Time{}, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	rows, err := db.Query(`SELECT skill_key, repo_owner, repo_name, repo_branch, directory, path, name, description, tags, readme_url, readme
+		FROM skill_index WHERE lower(repo_owner) = ? AND lower(repo_name) = ?`, strings.ToLower(repo.Owner), strings.ToLower(repo.Name))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer rows.Close()
+	skills := []indexedSkill{}
+	for rows.Next() {
+		entry, err := scanIndexedSkill(rows)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		skills = append(skills, entry)
+	}
+	return skills, time.Unix(indexedAt.Int64, 0), rows.Err()
+}
+
+// invalidateIndexedRepo 仓库配置变更后使索引过期，下次 ListSkills 重新下载；旧索引保留作为下载失败时的兜底
+func invalidateIndexedRepo(owner, name string) {
+	db, err := xdb.DB("default")
+	if err != nil {
+		return
+	}
+	_, _ = db.Exec(`UPDATE skill_index_repo SET indexed_at = 0 WHERE repo_owner = ? AND repo_name = ?`,
+		strings.ToLower(owner), strings.ToLower(name))
+}
+
+// saveIndexedRepo 在一个事务内替换该仓库的全部索引
+func saveIndexedRepo(repo skillRepoConfig, branch string, skills []indexedSkill) error {
+	db, err := xdb.DB("default")
+	if err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	owner, name := strings.ToLower(repo.Owner), strings.ToLower(repo.Name)
+	now := time.Now().Unix()
+	if _, err := tx.Exec(`DELETE FROM skill_index_fts WHERE skill_key IN (SELECT skill_key FROM skill_index WHERE lower(repo_owner) = ? AND lower(repo_name) = ?)`, owner, name); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM skill_index WHERE lower(repo_owner) = ? AND lower(repo_name) = ?`, owner, name); err != nil {
+		return err
+	}
+	for _, skill := range skills {
+		tags := strings.Join(skill.Tags, ",")
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO skill_index (skill_key, repo_owner, repo_name, repo_branch, directory, path, name, description, tags, readme_url, readme, indexed_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			skill.Key, skill.RepoOwner, skill.RepoName, skill.RepoBranch, skill.Directory, skill.Path, skill.Name, skill.Description, tags, skill.ReadmeURL, skill.Readme, now); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT INTO skill_index_fts (skill_key, name, description, tags, readme) VALUES (?, ?, ?, ?, ?)`,
+			skill.Key, skill.Name+" "+skill.Directory, skill.Description, tags, skill.Readme); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO skill_index_repo (repo_owner, repo_name, repo_branch, indexed_at) VALUES (?, ?, ?, ?)`,
+		owner, name, branch, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func scanIndexedSkill(rows *sql.Rows) (indexedSkill, error) {
+	var entry indexedSkill
+	var owner, name, branch, directory, path, skillName, description, tags, readmeURL, readme sql.NullString
+	if err := rows.Scan(&entry.Key, &owner, &name, &branch, &directory, &path, &skillName, &description, &tags, &readmeURL, &readme); err != nil {
+		return entry, err
+	}
+	entry.RepoOwner = owner.String
+	entry.RepoName = name.String
+	entry.RepoBranch = branch.String
+	entry.Directory = directory.String
+	entry.Path = path.String
+	entry.Name = skillName.String
+	entry.Description = description.String
+	entry.ReadmeURL = readmeURL.String
+	entry.Readme = readme.String
+	if tags.String != "" {
+		entry.Tags = strings.Split(tags.String, ",")
+	}
+	return entry, nil
+}
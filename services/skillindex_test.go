@@ -0,0 +1,91 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseSkillQuery(t *testing.T) {
+	keywords, tags := parseSkillQuery("  PDF tag:Docs #office  ex ")
+	if !reflect.DeepEqual(keywords, []string{"pdf", "ex"}) {
+		t.Fatalf("keywords = %v", keywords)
+	}
+	if !reflect.DeepEqual(tags, []string{"docs", "office"}) {
+		t.Fatalf("tags = %v", tags)
+	}
+}
+
+func TestParseSkillMetadataTags(t *testing.T) {
+	cases := map[string][]string{
+		"---\nname: a\ntags: [PDF, docs, pdf]\n---\n": {"pdf", "docs"},
+		"---\nname: a\ntags: \"#pdf, office\"\n---\n": {"pdf", "office"},
+	}
+	for content, want := range cases {
+		meta, err := parseSkillMetadata(content)
+		if err != nil {
+			t.Fatalf("parse %q: %v", content, err)
+		}
+		if !reflect.DeepEqual([]string(meta.Tags), want) {
+			t.Fatalf("tags = %v, want %v", meta.Tags, want)
+		}
+	}
+}
+
+func TestSkillSearchRanking(t *testing.T) {
+	skills := []Skill{
+		{Name: "xlsx", Description: "Spreadsheet editing", Tags: []string{"office"}},
+		{Name: "pdf", Description: "Fill PDF forms", Tags: []string{"office", "docs"}},
+		{Name: "canvas", Description: "Design posters"},
+	}
+	got := filterSkills(skills, []string{"pdf"}, []string{"office"})
+	if len(got) != 1 || got[0].Name != "pdf" {
+		t.Fatalf("filterSkills = %v", got)
+	}
+	byName := skillSearchScore(indexedSkill{Skill: skills[1]}, []string{"pdf"})
+	byReadme := skillSearchScore(indexedSkill{Skill: skills[2], Readme: "export to pdf"}, []string{"pdf"})
+	if byName <= byReadme || byReadme == 0 {
+		t.Fatalf("name score %d should exceed readme score %d", byName, byReadme)
+	}
+}
+
+func TestScanRepoSkillsNested(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel, content string) {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("pdf/SKILL.md", "---\nname: pdf\n---\n")
+	write("pdf/examples/SKILL.md", "---\nname: inner\n---\n")
+	write("skills/office/xlsx/SKILL.md", "---\nname: xlsx\n---\n")
+	write("a/b/c/d/e/SKILL.md", "---\nname: too-deep\n---\n")
+	write(".github/demo/SKILL.md", "---\nname: hidden\n---\n")
+
+	repo := skillRepoConfig{Owner: "acme", Name: "skills", Provider: skillProviderGitHub}
+	skills, err := scanRepoSkills(repo, root, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := map[string]string{}
+	for _, skill := range skills {
+		paths[skill.Path] = skill.Directory
+	}
+	want := map[string]string{"pdf": "pdf", "skills/office/xlsx": "xlsx"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Fatalf("paths = %v, want %v", paths, want)
+	}
+
+	dir, err := locateRepoSkill(root, installRequest{Directory: "xlsx"})
+	if err != nil || dir != filepath.Join(root, "skills", "office", "xlsx") {
+		t.Fatalf("locate = %s, %v", dir, err)
+	}
+	if _, err := locateRepoSkill(root, installRequest{Directory: "x", Path: "../x"}); err == nil {
+		t.Fatal("expected error for path outside repo")
+	}
+}
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	skillLintError   = "error"
+	skillLintWarning = "warning"
+	// SKILL.md 超过该行数时建议拆分到引用文件，避免一次加载占用过多上下文
+	skillBodyMaxLines = 500
+	// 只扫描不超过该大小的文本文件
+	skillLintMaxFileSize = 1 << 20
+)
+
+// SkillLintIssue 检查 skill 时发现的问题。Level 为 error 时 CLI 无法正常加载该 skill，默认阻止安装；
+// warning 只提示，不影响安装
+type SkillLintIssue struct {
+	Level   string `json:"level"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+}
+
+// SkillInstallResult 安装结果。存在 error 级别的问题且未强制安装时 Installed 为 false，Issues 中给出原因；
+// Dependencies 为 front matter requires 中声明的依赖及其是否满足
+type SkillInstallResult struct {
+	Installed    bool              `json:"installed"`
+	Issues       []SkillLintIssue  `json:"issues"`
+	Dependencies []SkillDependency `json:"dependencies,omitempty"`
+}
+
+type dangerousSnippet struct {
+	code    string
+	message string
+	pattern *regexp.Regexp
+}
+
+var dangerousSnippets = []dangerousSnippet{
+	{"rm-root", "删除根目录或用户主目录", regexp.MustCompile(`(?i)\brm\s+(-[a-z]+\s+)*-[a-z]*[rf][a-z]*\s+(-[a-z]+\s+)*["']?(/|~|\$home|\$\{home\})["']?(\s|\*|;|&|$)`)},
+	{"pipe-to-shell", "下载内容直接交给 shell 执行", regexp.MustCompile(`(?i)\b(curl|wget)\b[^\n|]*\|\s*(sudo\s+)?(ba|z|da|k)?sh\b`)},
+	{"eval-remote", "eval 执行远程下载的内容", regexp.MustCompile(`(?i)\beval\s+["']?\$\(\s*(curl|wget)\b`)},
+	{"base64-to-shell", "解码后的内容直接交给 shell 执行", regexp.MustCompile(`(?i)\bbase64\s+(-d|-D|--decode)\b[^\n|]*\|\s*(sudo\s+)?(ba|z|da)?sh\b`)},
+	{"fork-bomb", "fork 炸弹", regexp.MustCompile(`:\(\)\s*\{\s*:\s*\|\s*:\s*&\s*\}\s*;\s*:`)},
+	{"disk-write", "格式化或直接写入磁盘设备", regexp.MustCompile(`(?i)\bmkfs(\.\w+)?\s|\bdd\s+[^\n]*\bof=/dev/(sd|hd|nvme|disk|mmcblk)|>\s*/dev/(sd|hd|nvme|disk)[a-z0-9]*\b`)},
+	{"chmod-777", "递归放开系统目录权限", regexp.MustCompile(`(?i)\bchmod\s+(-R\s+)?(0?777|a\+rwx)\s+/(\s|$)`)},
+	{"credential-access", "读取 SSH 或云服务凭证", regexp.MustCompile(`(?i)(~|\$home|\$\{home\})/\.(ssh/id_|aws/credentials|config/gcloud|kube/config)`)},
+}
+
+var skillLinkPattern = regexp.MustCompile(`\[[^\]]*\]\(([^)\s]+)\)`)
+
+// skillLintTextExts 会被扫描危险片段的文件类型，没有扩展名的文件（通常是脚本）也会扫描
+var skillLintTextExts = map[string]bool{
+	".md": true, ".txt": true, ".sh": true, ".bash": true, ".zsh": true, ".fish": true,
+	".py": true, ".js": true, ".mjs": true, ".cjs": true, ".ts": true, ".rb": true,
+	".pl": true, ".ps1": true, ".bat": true, ".cmd": true, ".yaml": true, ".yml": true,
+}
+
+// LintSkill 在安装前检查仓库中的 skill，不写入任何文件
+func (ss *SkillService) LintSkill(req installRequest) ([]SkillLintIssue, error) {
+	var issues []SkillLintIssue
+	err := ss.withRepoSkill(req, func(skillPath string) error {
+		issues = lintSkillDir(skillPath, req.Directory)
+		return nil
+	})
+	return issues, err
+}
+
+// LintLocalSkill 检查本地目录中的 skill，例如已安装或正在编写的 skill
+func (ss *SkillService) LintLocalSkill(dir string) ([]SkillLintIssue, error) {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		return nil, fmt.Errorf("skill 目录不能为空")
+	}
+	if !filepath.IsAbs(dir) {
+		if !filepath.IsLocal(dir) {
+			return nil, fmt.Errorf("skill 目录无效: %s", dir)
+		}
+		dir = filepath.Join(ss.installDir, dir)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("skill 目录不存在: %s", dir)
+	}
+	return lintSkillDir(dir, filepath.Base(dir)), nil
+}
+
+// lintSkillDir 检查 SKILL.md 的 front matter、正文与引用文件，并扫描目录中的危险 shell 片段
+func lintSkillDir(dir, directory string) []SkillLintIssue {
+	issues := []SkillLintIssue{}
+	data, err := os.ReadFile(filepath.Join(dir, "SKILL.md"))
+	if err != nil {
+		return append(issues, SkillLintIssue{Level: skillLintError, Code: "missing-skill-md", Message: "缺少 SKILL.md"})
+	}
+	issues = append(issues, lintSkillManifest(dir, directory, string(data))...)
+	issues = append(issues, scanDangerousSnippets(dir)...)
+	return issues
+}
+
+func lintSkillManifest(dir, directory, content string) []SkillLintIssue {
+	var issues []SkillLintIssue
+	add := func(level, code string, line int, format string, args ...any) {
+		issues = append(issues, SkillLintIssue{Level: level, Code: code, Message: fmt.Sprintf(format, args...), File: "SKILL.md", Line: line})
+	}
+
+	content = strings.ReplaceAll(strings.TrimPrefix(content, "\ufeff"), "\r\n", "\n")
+	lines := strings.Split(content, "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		add(skillLintError, "front-matter-missing", 1, "SKILL.md 必须以 --- 开头的 YAML front matter 开始")
+		return issues
+	}
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "---" {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		add(skillLintError, "front-matter-unclosed", 1, "front matter 缺少结束的 ---")
+		return issues
+	}
+
+	var meta skillMetadata
+	if err := yaml.Unmarshal([]byte(strings.Join(lines[1:end], "\n")), &meta); err != nil {
+		add(skillLintError, "front-matter-invalid", 1, "front matter 不是有效的 YAML: %v", err)
+		return issues
+	}
+
+	name := strings.TrimSpace(meta.Name)
+	nameLine := frontMatterKeyLine(lines[1:end], "name")
+	switch {
+	case name == "":
+		add(skillLintError, "name-missing", 1, "front matter 缺少 name")
+	case len(name) > skillNameMaxLen || !skillNamePattern.MatchString(name):
+		add(skillLintError, "name-invalid", nameLine, "name %q 只能包含小写字母、数字和连字符，且不超过 %d 个字符", name, skillNameMaxLen)
+	default:
+		if strings.Contains(name, "anthropic") || strings.Contains(name, "claude") {
+			add(skillLintWarning, "name-reserved", nameLine, "name %q 包含保留词 anthropic 或 claude", name)
+		}
+		if directory != "" && name != directory {
+			add(skillLintWarning, "name-mismatch", nameLine, "name %q 与目录名 %q 不一致", name, directory)
+		}
+	}
+
+	description := strings.TrimSpace(meta.Description)
+	descriptionLine := frontMatterKeyLine(lines[1:end], "description")
+	switch {
+	case description == "":
+		add(skillLintError, "description-missing", 1, "front matter 缺少 description，Claude 依据描述决定何时使用该 skill")
+	case utf8.RuneCountInString(description) > skillDescriptionMaxLen:
+		add(skillLintError, "description-too-long", descriptionLine, "description 超过 %d 个字符", skillDescriptionMaxLen)
+	case strings.ContainsAny(description, "<>"):
+		add(skillLintWarning, "description-xml", descriptionLine, "description 不应包含 XML 标签")
+	}
+
+	body := strings.TrimSpace(strings.Join(lines[end+1:], "\n"))
+	if body == "" {
+		add(skillLintWarning, "body-empty", end+1, "SKILL.md 正文为空")
+	}
+	if len(lines) > skillBodyMaxLines {
+		add(skillLintWarning, "body-too-long", 0, "SKILL.md 超过 %d 行，建议把详细内容拆分到引用文件中", skillBodyMaxLines)
+	}
+
+	for i := end + 1; i < len(lines); i++ {
+		for _, match := range skillLinkPattern.FindAllStringSubmatch(lines[i], -1) {
+			target := strings.SplitN(match[1], "#", 2)[0]
+			if target == "" || strings.Contains(target, "://") || strings.HasPrefix(target, "mailto:") {
+				continue
+			}
+			rel := filepath.FromSlash(target)
+			if !filepath.IsLocal(rel) {
+				add(skillLintWarning, "reference-outside", i+1, "引用了 skill 目录之外的文件: %s", target)
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(dir, rel)); err != nil {
+				add(skillLintWarning, "reference-missing", i+1, "引用的文件不存在: %s", target)
+			}
+		}
+	}
+	return issues
+}
+
+// frontMatterKeyLine 返回顶层 key 在 SKILL.md 中的行号，找不到时返回 1
+func frontMatterKeyLine(lines []string, key string) int {
+	for i, line := range lines {
+		if strings.HasPrefix(line, key+":") {
+			return i + 2
+		}
+	}
+	return 1
+}
+
+// scanDangerousSnippets 逐行扫描文本文件，跳过隐藏目录与过大的文件；指向目录外的符号链接同样提示
+func scanDangerousSnippets(dir string) []SkillLintIssue {
+	var issues []SkillLintIssue
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, path)
+		rel = filepath.ToSlash(rel)
+		if d.Type()&fs.ModeSymlink != 0 {
+			target, err := filepath.EvalSymlinks(path)
+			root, rootErr := filepath.EvalSymlinks(dir)
+			if err != nil || rootErr != nil || !strings.HasPrefix(target, root+string(filepath.Separator)) {
+				issues = append(issues, SkillLintIssue{Level: skillLintWarning, Code: "symlink-outside", Message: "符号链接指向 skill 目录之外", File: rel})
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if ext := strings.ToLower(filepath.Ext(d.Name())); ext != "" && !skillLintTextExts[ext] {
+			return nil
+		}
+		if info, err := d.Info(); err != nil || info.Size() > skillLintMaxFileSize {
+			return nil
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return nil
+		}
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 0, 64*1024), skillLintMaxFileSize)
+		for line := 1; scanner.Scan(); line++ {
+			text := scanner.Text()
+			for _, snippet := range dangerousSnippets {
+				if snippet.pattern.MatchString(text) {
+					issues = append(issues, SkillLintIssue{
+						Level:   skillLintWarning,
+						Code:    snippet.code,
+						Message: "包含危险命令：" + snippet.message,
+						File:    rel,
+						Line:    line,
+					})
+				}
+			}
+		}
+		return nil
+	})
+	return issues
+}
+
+func hasSkillLintErrors(issues []SkillLintIssue) bool {
+	for _, issue := range issues {
+		if issue.Level == skillLintError {
+			return true
+		}
+	}
+	return false
+}
+
+// formatSkillLintErrors 把 error 级别的问题拼成一条错误信息，用于没有结构化返回值的调用方
+func formatSkillLintErrors(directory string, issues []SkillLintIssue) error {
+	var messages []string
+	for _, issue := range issues {
+		if issue.Level == skillLintError {
+			messages = append(messages, issue.Message)
+		}
+	}
+	return fmt.Errorf("%s 未通过检查: %s", directory, strings.Join(messages, "；"))
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	skillBulkInstall   = "install"
+	skillBulkUninstall = "uninstall"
+
+	skillBulkInstalled = "installed"
+	skillBulkRemoved   = "removed"
+	skillBulkBlocked   = "blocked"
+	skillBulkFailed    = "failed"
+	skillBulkRunning   = "running"
+)
+
+// SkillProfile 命名的 skill 组合，例如 frontend、data，可以整体安装到某个平台与位置
+type SkillProfile struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Skills      []installRequest `json:"skills"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// SkillTarget 批量操作的目标位置，含义与 CreateSkillRequest 的 Platform / Location / ProjectDir 相同
+type SkillTarget struct {
+	Platform   string `json:"platform"`
+	Location   string `json:"location"`
+	ProjectDir string `json:"project_dir"`
+}
+
+// SkillBulkItem 批量操作中单个 skill 的结果
+type SkillBulkItem struct {
+	Directory string           `json:"directory"`
+	Status    string           `json:"status"`
+	Error     string           `json:"error,omitempty"`
+	Issues    []SkillLintIssue `json:"issues,omitempty"`
+}
+
+// SkillBulkResult 批量操作的汇总结果，单个 skill 失败不会中断其余 skill
+type SkillBulkResult struct {
+	Operation string          `json:"operation"`
+	Profile   string          `json:"profile,omitempty"`
+	Succeeded int             `json:"succeeded"`
+	Failed    int             `json:"failed"`
+	Items     []SkillBulkItem `json:"items"`
+}
+
+// SkillBulkProgress 批量操作进度，每处理一个 skill 前后各推送一次
+type SkillBulkProgress struct {
+	Operation string `json:"operation"`
+	Profile   string `json:"profile,omitempty"`
+	Directory string `json:"directory"`
+	Index     int    `json:"index"`
+	Total     int    `json:"total"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+}
+
+// SetBulkProgressHandler 设置批量安装 / 卸载的进度回调（由 main 转发给前端）
+func (ss *SkillService) SetBulkProgressHandler(handler func(SkillBulkProgress)) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.bulkProgressHandler = handler
+}
+
+// ListSkillProfiles 返回全部 skill 组合，按名称排序
+func (ss *SkillService) ListSkillProfiles() ([]SkillProfile, error) {
+	store, err := ss.loadStore()
+	if err != nil {
+		return nil, err
+	}
+	profiles := append([]SkillProfile{}, store.Profiles...)
+	sort.SliceStable(profiles, func(i, j int) bool {
+		return strings.ToLower(profiles[i].Name) < strings.ToLower(profiles[j].Name)
+	})
+	return profiles, nil
+}
+
+// SaveSkillProfile 新建或覆盖同名 skill 组合
+func (ss *SkillService) SaveSkillProfile(profile SkillProfile) ([]SkillProfile, error) {
+	profile.Name = strings.TrimSpace(profile.Name)
+	profile.Description = strings.TrimSpace(profile.Description)
+	if profile.Name == "" {
+		return nil, errors.New("组合名称不能为空")
+	}
+	seen := make(map[string]bool)
+	skills := make([]installRequest, 0, len(profile.Skills))
+	for _, skill := range profile.Skills {
+		skill.Directory = strings.TrimSpace(skill.Directory)
+		if skill.Directory == "" || !filepath.IsLocal(skill.Directory) {
+			return nil, fmt.Errorf("skill directory 无效: %q", skill.Directory)
+		}
+		skill.Force = false
+		key := strings.ToLower(skill.Directory)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		skills = append(skills, skill)
+	}
+	if len(skills) == 0 {
+		return nil, errors.New("组合中至少需要一个 skill")
+	}
+	profile.Skills = skills
+	profile.UpdatedAt = time.Now()
+
+	ss.mu.Lock()
+	store, err := ss.loadStoreLocked()
+	if err != nil {
+		ss.mu.Unlock()
+		return nil, err
+	}
+	replaced := false
+	for i := range store.Profiles {
+		if strings.EqualFold(store.Profiles[i].Name, profile.Name) {
+			store.Profiles[i] = profile
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		store.Profiles = append(store.Profiles, profile)
+	}
+	err = ss.saveStoreLocked(store)
+	ss.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return ss.ListSkillProfiles()
+}
+
+// DeleteSkillProfile 删除组合定义，已安装的 skill 不受影响
+func (ss *SkillService) DeleteSkillProfile(name string) ([]SkillProfile, error) {
+	name = strings.TrimSpace(name)
+	ss.mu.Lock()
+	store, err := ss.loadStoreLocked()
+	if err != nil {
+		ss.mu.Unlock()
+		return nil, err
+	}
+	filtered := store.Profiles[:0]
+	for _, profile := range store.Profiles {
+		if !strings.EqualFold(profile.Name, name) {
+			filtered = append(filtered, profile)
+		}
+	}
+	store.Profiles = filtered
+	err = ss.saveStoreLocked(store)
+	ss.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return ss.ListSkillProfiles()
+}
+
+// ApplySkillProfile 把组合中的 skill 全部安装到目标位置；force 为 true 时忽略安装前检查的 error
+func (ss *SkillService) ApplySkillProfile(name string, target SkillTarget, force bool) (SkillBulkResult, error) {
+	profile, err := ss.findSkillProfile(name)
+	if err != nil {
+		return SkillBulkResult{}, err
+	}
+	reqs := make([]installRequest, 0, len(profile.Skills))
+	for _, skill := range profile.Skills {
+		skill.Force = force
+		reqs = append(reqs, skill)
+	}
+	return ss.bulkInstall(profile.Name, reqs, target)
+}
+
+// UnapplySkillProfile 从目标位置卸载组合中的全部 skill
+func (ss *SkillService) UnapplySkillProfile(name string, target SkillTarget) (SkillBulkResult, error) {
+	profile, err := ss.findSkillProfile(name)
+	if err != nil {
+		return SkillBulkResult{}, err
+	}
+	directories := make([]string, 0, len(profile.Skills))
+	for _, skill := range profile.Skills {
+		directories = append(directories, skill.Directory)
+	}
+	return ss.bulkUninstall(profile.Name, directories, target)
+}
+
+// BulkInstallSkills 一次安装多个 skill 到目标位置
+func (ss *SkillService) BulkInstallSkills(reqs []installRequest, target SkillTarget) (SkillBulkResult, error) {
+	return ss.bulkInstall("", reqs, target)
+}
+
+// BulkUninstallSkills 一次从目标位置卸载多个 skill
+func (ss *SkillService) BulkUninstallSkills(directories []string, target SkillTarget) (SkillBulkResult, error) {
+	return ss.bulkUninstall("", directories, target)
+}
+
+func (ss *SkillService) bulkInstall(profile string, reqs []installRequest, target SkillTarget) (SkillBulkResult, error) {
+	dir, err := ss.skillLocationDir(target.Platform, target.Location, target.ProjectDir)
+	if err != nil {
+		return SkillBulkResult{}, err
+	}
+	result := SkillBulkResult{Operation: skillBulkInstall, Profile: profile, Items: make([]SkillBulkItem, 0, len(reqs))}
+	for i, req := range reqs {
+		req.Directory = strings.TrimSpace(req.Directory)
+		ss.emitBulkProgress(SkillBulkProgress{Operation: skillBulkInstall, Profile: profile, Directory: req.Directory, Index: i + 1, Total: len(reqs), Status: skillBulkRunning})
+		item := SkillBulkItem{Directory: req.Directory}
+		installed, err := ss.installFromRepos(req, dir)
+		item.Issues = installed.Issues
+		switch {
+		case err != nil:
+			item.Status = skillBulkFailed
+			item.Error = err.Error()
+		case !installed.Installed:
+			item.Status = skillBulkBlocked
+			item.Error = formatSkillLintErrors(req.Directory, installed.Issues).Error()
+		default:
+			item.Status = skillBulkInstalled
+		}
+		result.add(item)
+		ss.emitBulkProgress(SkillBulkProgress{Operation: skillBulkInstall, Profile: profile, Directory: req.Directory, Index: i + 1, Total: len(reqs), Status: item.Status, Error: item.Error})
+	}
+	return result, nil
+}
+
+func (ss *SkillService) bulkUninstall(profile string, directories []string, target SkillTarget) (SkillBulkResult, error) {
+	dir, err := ss.skillLocationDir(target.Platform, target.Location, target.ProjectDir)
+	if err != nil {
+		return SkillBulkResult{}, err
+	}
+	result := SkillBulkResult{Operation: skillBulkUninstall, Profile: profile, Items: make([]SkillBulkItem, 0, len(directories))}
+	for i, directory := range directories {
+		directory = strings.TrimSpace(directory)
+		ss.emitBulkProgress(SkillBulkProgress{Operation: skillBulkUninstall, Profile: profile, Directory: directory, Index: i + 1, Total: len(directories), Status: skillBulkRunning})
+		item := SkillBulkItem{Directory: directory, Status: skillBulkRemoved}
+		var err error
+		switch {
+		case directory == "" || !filepath.IsLocal(directory):
+			err = fmt.Errorf("skill directory 无效: %q", directory)
+		case dir == ss.installDir:
+			err = ss.UninstallSkill(directory)
+		default:
+			if err = ss.backupSkillDir(filepath.Join(dir, directory), skillBackupUninstall); err == nil {
+				err = os.RemoveAll(filepath.Join(dir, directory))
+			}
+		}
+		if err != nil {
+			item.Status = skillBulkFailed
+			item.Error = err.Error()
+		}
+		result.add(item)
+		ss.emitBulkProgress(SkillBulkProgress{Operation: skillBulkUninstall, Profile: profile, Directory: directory, Index: i + 1, Total: len(directories), Status: item.Status, Error: item.Error})
+	}
+	return result, nil
+}
+
+func (r *SkillBulkResult) add(item SkillBulkItem) {
+	if item.Status == skillBulkInstalled || item.Status == skillBulkRemoved {
+		r.Succeeded++
+	} else {
+		r.Failed++
+	}
+	r.Items = append(r.Items, item)
+}
+
+func (ss *SkillService) findSkillProfile(name string) (SkillProfile, error) {
+	name = strings.TrimSpace(name)
+	store, err := ss.loadStore()
+	if err != nil {
+		return SkillProfile{}, err
+	}
+	for _, profile := range store.Profiles {
+		if strings.EqualFold(profile.Name, name) {
+			return profile, nil
+		}
+	}
+	return SkillProfile{}, fmt.Errorf("skill 组合不存在: %s", name)
+}
+
+func (ss *SkillService) emitBulkProgress(progress SkillBulkProgress) {
+	ss.mu.Lock()
+	handler := ss.bulkProgressHandler
+	ss.mu.Unlock()
+	if handler != nil {
+		handler(progress)
+	}
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSkillProfiles(t *testing.T) {
+	root := t.TempDir()
+	ss := &SkillService{
+		storePath:  filepath.Join(root, "skills.json"),
+		installDir: filepath.Join(root, "claude", "skills"),
+	}
+	if _, err := ss.SaveSkillProfile(SkillProfile{Name: "frontend"}); err == nil {
+		t.Fatal("expected error for empty profile")
+	}
+	profiles, err := ss.SaveSkillProfile(SkillProfile{
+		Name:   " frontend ",
+		Skills: []installRequest{{Directory: "react"}, {Directory: "css", Force: true}, {Directory: "React"}},
+	})
+	if err != nil {
+		t.Fatalf("SaveSkillProfile: %v", err)
+	}
+	if len(profiles) != 1 || profiles[0].Name != "frontend" || len(profiles[0].Skills) != 2 || profiles[0].Skills[1].Force {
+		t.Fatalf("profiles = %+v", profiles)
+	}
+	if profiles, _ = ss.SaveSkillProfile(SkillProfile{Name: "Frontend", Skills: []installRequest{{Directory: "vue"}}}); len(profiles) != 1 || profiles[0].Skills[0].Directory != "vue" {
+		t.Fatalf("profile not replaced: %+v", profiles)
+	}
+
+	for _, dir := range []string{"vue", "keep"} {
+		writeSkillFiles(t, filepath.Join(ss.installDir, dir), map[string]string{"SKILL.md": "---\nname: " + dir + "\ndescription: d\n---\nbody\n"})
+	}
+	var progress []SkillBulkProgress
+	ss.SetBulkProgressHandler(func(p SkillBulkProgress) { progress = append(progress, p) })
+	result, err := ss.UnapplySkillProfile("frontend", SkillTarget{})
+	if err != nil {
+		t.Fatalf("UnapplySkillProfile: %v", err)
+	}
+	if result.Succeeded != 1 || result.Failed != 0 || result.Profile != "Frontend" {
+		t.Fatalf("result = %+v", result)
+	}
+	if _, err := os.Stat(filepath.Join(ss.installDir, "vue")); !os.IsNotExist(err) {
+		t.Fatal("vue should be removed")
+	}
+	if _, err := os.Stat(filepath.Join(ss.installDir, "keep")); err != nil {
+		t.Fatal("keep should not be touched")
+	}
+	if len(progress) != 2 || progress[0].Status != skillBulkRunning || progress[1].Status != skillBulkRemoved || progress[1].Total != 1 {
+		t.Fatalf("progress = %+v", progress)
+	}
+
+	// 指定的仓库不存在时逐个失败，不中断批量操作
+	missing := []installRequest{{Directory: "a", RepoOwner: "x", RepoName: "y"}, {Directory: "b", RepoOwner: "x", RepoName: "y"}}
+	result, err = ss.BulkInstallSkills(missing, SkillTarget{})
+	if err != nil || result.Failed != 2 || len(result.Items) != 2 || result.Items[1].Status != skillBulkFailed {
+		t.Fatalf("result = %+v, err = %v", result, err)
+	}
+
+	if profiles, _ = ss.DeleteSkillProfile("FRONTEND"); len(profiles) != 0 {
+		t.Fatalf("profile not deleted: %+v", profiles)
+	}
+	if _, err := ss.ApplySkillProfile("frontend", SkillTarget{}, false); err == nil {
+		t.Fatal("expected error for missing profile")
+	}
+}
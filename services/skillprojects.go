@@ -0,0 +1,352 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	skillProjectManual     = "manual"
+	skillProjectDiscovered = "discovered"
+	// 每个会话文件只读取开头若干行查找 cwd
+	sessionCwdScanLines = 50
+	// 会话记录中最多发现的项目数，按最近使用排序
+	maxDiscoveredProjects = 50
+)
+
+// SkillProject 可以管理项目级 skill 的项目根目录
+type SkillProject struct {
+	Path     string    `json:"path"`
+	Name     string    `json:"name"`
+	Source   string    `json:"source"`
+	Exists   bool      `json:"exists"`
+	LastUsed time.Time `json:"last_used,omitempty"`
+}
+
+type skillProjectConfig struct {
+	Path    string    `json:"path"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+// ListSkillProjects 返回手动添加的项目与从 Claude Code 会话记录中发现的项目，已忽略的项目不返回
+func (ss *SkillService) ListSkillProjects() ([]SkillProject, error) {
+	store, err := ss.loadStore()
+	if err != nil {
+		return nil, err
+	}
+	ignored := make(map[string]bool, len(store.IgnoredProjects))
+	for _, path := range store.IgnoredProjects {
+		ignored[path] = true
+	}
+	seen := make(map[string]bool)
+	projects := make([]SkillProject, 0, len(store.Projects))
+	for _, project := range store.Projects {
+		seen[project.Path] = true
+		projects = append(projects, newSkillProject(project.Path, skillProjectManual, project.AddedAt))
+	}
+	for _, discovered := range discoverClaudeProjects(userHomeDir()) {
+		if seen[discovered.Path] || ignored[discovered.Path] {
+			continue
+		}
+		seen[discovered.Path] = true
+		projects = append(projects, discovered)
+	}
+	sort.SliceStable(projects, func(i, j int) bool {
+		if projects[i].Source != projects[j].Source {
+			return projects[i].Source == skillProjectManual
+		}
+		return projects[i].LastUsed.After(projects[j].LastUsed)
+	})
+	return projects, nil
+}
+
+// AddSkillProject 手动登记项目根目录
+func (ss *SkillService) AddSkillProject(path string) ([]SkillProject, error) {
+	path, err := normalizeProjectPath(path)
+	if err != nil {
+		return nil, err
+	}
+	ss.mu.Lock()
+	store, err := ss.loadStoreLocked()
+	if err != nil {
+		ss.mu.Unlock()
+		return nil, err
+	}
+	exists := false
+	for _, project := range store.Projects {
+		if project.Path == path {
+			exists = true
+			break
+		}
+	}
+	if !exists {
+		store.Projects = append(store.Projects, skillProjectConfig{Path: path, AddedAt: time.Now()})
+	}
+	store.IgnoredProjects = slices.DeleteFunc(store.IgnoredProjects, func(ignored string) bool { return ignored == path })
+	err = ss.saveStoreLocked(store)
+	ss.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return ss.ListSkillProjects()
+}
+
+// RemoveSkillProject 移除项目；自动发现的项目加入忽略列表，之后不再显示。项目中的 skill 文件不受影响
+func (ss *SkillService) RemoveSkillProject(path string) ([]SkillProject, error) {
+	path = filepath.Clean(strings.TrimSpace(path))
+	ss.mu.Lock()
+	store, err := ss.loadStoreLocked()
+	if err != nil {
+		ss.mu.Unlock()
+		return nil, err
+	}
+	filtered := store.Projects[:0]
+	for _, project := range store.Projects {
+		if project.Path != path {
+			filtered = append(filtered, project)
+		}
+	}
+	store.Projects = filtered
+	if !slices.Contains(store.IgnoredProjects, path) {
+		store.IgnoredProjects = append(store.IgnoredProjects, path)
+	}
+	err = ss.saveStoreLocked(store)
+	ss.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return ss.ListSkillProjects()
+}
+
+// ListProjectSkills 列出项目 .claude/skills（或 .codex/skills、.gemini/extensions）下的 skill
+func (ss *SkillService) ListProjectSkills(projectPath, platform string) ([]Skill, error) {
+	return ss.ListInstalledSkills(SkillTarget{Platform: platform, Location: "project", ProjectDir: projectPath})
+}
+
+// ListInstalledSkills 列出任一平台与位置下已安装的 skill，Gemini CLI 的扩展同样列出
+func (ss *SkillService) ListInstalledSkills(target SkillTarget) ([]Skill, error) {
+	dir, err := ss.skillLocationDir(target.Platform, target.Location, target.ProjectDir)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Skill{}, nil
+		}
+		return nil, err
+	}
+	skills := make([]Skill, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		meta, err := readPlatformSkillMetadata(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		name := strings.TrimSpace(meta.Name)
+		if name == "" {
+			name = entry.Name()
+		}
+		skills = append(skills, Skill{
+			Key:         "project:" + filepath.ToSlash(dir) + ":" + strings.ToLower(entry.Name()),
+			Name:        name,
+			Description: strings.TrimSpace(meta.Description),
+			Tags:        []string(meta.Tags),
+			Directory:   entry.Name(),
+			Installed:   true,
+		})
+	}
+	sort.SliceStable(skills, func(i, j int) bool {
+		return strings.ToLower(skills[i].Name) < strings.ToLower(skills[j].Name)
+	})
+	return skills, nil
+}
+
+// InstallProjectSkill 从已配置的仓库安装 skill 到项目目录
+func (ss *SkillService) InstallProjectSkill(projectPath, platform string, req installRequest) (SkillInstallResult, error) {
+	dir, err := ss.projectSkillDir(projectPath, platform)
+	if err != nil {
+		return SkillInstallResult{}, err
+	}
+	return ss.installFromRepos(req, dir)
+}
+
+// CopySkillToProject 把用户级已安装的 skill 复制到项目目录
+func (ss *SkillService) CopySkillToProject(directory, projectPath, platform string) error {
+	directory = strings.TrimSpace(directory)
+	if directory == "" || !filepath.IsLocal(directory) {
+		return errors.New("skill directory 无效")
+	}
+	dir, err := ss.projectSkillDir(projectPath, platform)
+	if err != nil {
+		return err
+	}
+	source := filepath.Join(ss.installDir, directory)
+	if _, err := os.Stat(filepath.Join(source, "SKILL.md")); err != nil {
+		return fmt.Errorf("%s 未安装", directory)
+	}
+	if err := ss.backupSkillDir(filepath.Join(dir, directory), skillBackupInstall); err != nil {
+		return err
+	}
+	return installSkillCopy(source, dir, directory)
+}
+
+// UninstallProjectSkill 删除项目中的 skill
+func (ss *SkillService) UninstallProjectSkill(projectPath, platform, directory string) error {
+	directory = strings.TrimSpace(directory)
+	if directory == "" || !filepath.IsLocal(directory) {
+		return errors.New("skill directory 无效")
+	}
+	dir, err := ss.projectSkillDir(projectPath, platform)
+	if err != nil {
+		return err
+	}
+	if err := ss.backupSkillDir(filepath.Join(dir, directory), skillBackupUninstall); err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(dir, directory))
+}
+
+// projectSkillDir 项目中指定平台的 skill 目录
+func (ss *SkillService) projectSkillDir(projectPath, platform string) (string, error) {
+	return ss.skillLocationDir(platform, "project", projectPath)
+}
+
+func newSkillProject(path, source string, lastUsed time.Time) SkillProject {
+	info, err := os.Stat(path)
+	return SkillProject{
+		Path:     path,
+		Name:     filepath.Base(path),
+		Source:   source,
+		Exists:   err == nil && info.IsDir(),
+		LastUsed: lastUsed,
+	}
+}
+
+func normalizeProjectPath(path string) (string, error) {
+	path = strings.TrimSpace(path)
+	if path == "" || !filepath.IsAbs(path) {
+		return "", errors.New("请选择项目目录")
+	}
+	path = filepath.Clean(path)
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		return "", fmt.Errorf("项目目录不存在: %s", path)
+	}
+	if home := userHomeDir(); path == filepath.Clean(home) {
+		return "", errors.New("不能把用户主目录作为项目，用户级 skill 请直接安装")
+	}
+	return path, nil
+}
+
+// discoverClaudeProjects 从 ~/.claude.json 的 projects 与 ~/.claude/projects 下的会话记录中收集项目目录；
+// 会话目录名对路径做了有损编码，因此从会话内容的 cwd 字段读取真实路径
+func discoverClaudeProjects(home string) []SkillProject {
+	lastUsed := make(map[string]time.Time)
+	if data, err := os.ReadFile(filepath.Join(home, ".claude.json")); err == nil {
+		var config struct {
+			Projects map[string]json.RawMessage `json:"projects"`
+		}
+		if json.Unmarshal(data, &config) == nil {
+			for path := range config.Projects {
+				if filepath.IsAbs(path) {
+					lastUsed[filepath.Clean(path)] = time.Time{}
+				}
+			}
+		}
+	}
+
+	sessionsRoot := filepath.Join(home, ".claude", "projects")
+	dirs, _ := os.ReadDir(sessionsRoot)
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		session, modTime := latestSessionFile(filepath.Join(sessionsRoot, dir.Name()))
+		if session == "" {
+			continue
+		}
+		cwd := sessionCwd(session)
+		if cwd == "" || !filepath.IsAbs(cwd) {
+			continue
+		}
+		cwd = filepath.Clean(cwd)
+		if modTime.After(lastUsed[cwd]) {
+			lastUsed[cwd] = modTime
+		}
+	}
+
+	projects := make([]SkillProject, 0, len(lastUsed))
+	for path, used := range lastUsed {
+		if path == filepath.Clean(home) {
+			continue
+		}
+		project := newSkillProject(path, skillProjectDiscovered, used)
+		if project.Exists {
+			projects = append(projects, project)
+		}
+	}
+	sort.SliceStable(projects, func(i, j int) bool {
+		if !projects[i].LastUsed.Equal(projects[j].LastUsed) {
+			return projects[i].LastUsed.After(projects[j].LastUsed)
+		}
+		return projects[i].Path < projects[j].Path
+	})
+	if len(projects) > maxDiscoveredProjects {
+		projects = projects[:maxDiscoveredProjects]
+	}
+	return projects
+}
+
+func latestSessionFile(dir string) (string, time.Time) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", time.Time{}
+	}
+	var latest string
+	var latestTime time.Time
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".jsonl") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if info.ModTime().After(latestTime) {
+			latest = filepath.Join(dir, entry.Name())
+			latestTime = info.ModTime()
+		}
+	}
+	return latest, latestTime
+}
+
+// sessionCwd 读取会话记录开头几行中的 cwd 字段
+func sessionCwd(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4<<20)
+	for i := 0; i < sessionCwdScanLines && scanner.Scan(); i++ {
+		var line struct {
+			Cwd string `json:"cwd"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &line) == nil && line.Cwd != "" {
+			return line.Cwd
+		}
+	}
+	return ""
+}
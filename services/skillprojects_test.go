@@ -0,0 +1,70 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiscoverClaudeProjects(t *testing.T) {
+	home := t.TempDir()
+	fromConfig := filepath.Join(home, "work", "api")
+	fromSession := filepath.Join(home, "work", "web.app")
+	for _, dir := range []string{fromConfig, fromSession} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config := `{"projects":{"` + filepath.ToSlash(fromConfig) + `":{},"` + filepath.ToSlash(filepath.Join(home, "gone")) + `":{}}}`
+	if err := os.WriteFile(filepath.Join(home, ".claude.json"), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sessionDir := filepath.Join(home, ".claude", "projects", "-work-web-app")
+	if err := os.MkdirAll(sessionDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	session := "{\"type\":\"summary\"}\n{\"type\":\"user\",\"cwd\":\"" + filepath.ToSlash(fromSession) + "\"}\n"
+	if err := os.WriteFile(filepath.Join(sessionDir, "s1.jsonl"), []byte(session), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	projects := discoverClaudeProjects(home)
+	if len(projects) != 2 {
+		t.Fatalf("projects = %+v", projects)
+	}
+	// 有会话记录的项目排在前面，不存在的目录被忽略
+	if projects[0].Path != fromSession || projects[1].Path != fromConfig {
+		t.Fatalf("unexpected order: %+v", projects)
+	}
+}
+
+func TestProjectSkills(t *testing.T) {
+	root := t.TempDir()
+	project := filepath.Join(root, "project")
+	if err := os.MkdirAll(project, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	ss := &SkillService{installDir: filepath.Join(root, "skills"), storePath: filepath.Join(root, "skill.json")}
+	if _, err := ss.CreateSkill(CreateSkillRequest{Name: "demo", Description: "demo skill"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ss.CopySkillToProject("demo", project, "codex"); err != nil {
+		t.Fatalf("CopySkillToProject: %v", err)
+	}
+	skills, err := ss.ListProjectSkills(project, "codex")
+	if err != nil || len(skills) != 1 || skills[0].Directory != "demo" {
+		t.Fatalf("ListProjectSkills = %+v, %v", skills, err)
+	}
+	if _, err := os.Stat(filepath.Join(project, ".codex", "skills", "demo", "SKILL.md")); err != nil {
+		t.Fatalf("skill not copied: %v", err)
+	}
+	if err := ss.UninstallProjectSkill(project, "codex", "demo"); err != nil {
+		t.Fatal(err)
+	}
+	if skills, _ := ss.ListProjectSkills(project, "codex"); len(skills) != 0 {
+		t.Fatalf("skill not removed: %+v", skills)
+	}
+	if _, err := ss.ListProjectSkills("relative/path", "claude"); err == nil {
+		t.Fatal("expected error for relative project path")
+	}
+}
@@ -20,7 +20,6 @@ import (
 )
 
 const (
-	skillStoreDir  = ".code-switch"
 	skillStoreFile = "skill.json"
 )
 
@@ -33,25 +32,68 @@ var (
 )
 
 type Skill struct {
-	Key         string `json:"key"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Directory   string `json:"directory"`
-	ReadmeURL   string `json:"readme_url"`
-	Installed   bool   `json:"installed"`
-	RepoOwner   string `json:"repo_owner,omitempty"`
-	RepoName    string `json:"repo_name,omitempty"`
-	RepoBranch  string `json:"repo_branch,omitempty"`
+	Key         string   `json:"key"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags,omitempty"`
+	Directory   string   `json:"directory"`
+	// Path skill 在仓库内的相对路径（如 skills/office/pdf），仓库本身即 skill 时为 "."
+	Path       string `json:"path,omitempty"`
+	ReadmeURL  string `json:"readme_url"`
+	Installed  bool   `json:"installed"`
+	RepoOwner  string `json:"repo_owner,omitempty"`
+	RepoName   string `json:"repo_name,omitempty"`
+	RepoBranch string `json:"repo_branch,omitempty"`
 }
 
 type skillMetadata struct {
-	Name        string `yaml:"name"`
-	Description string `yaml:"description"`
+	Name        string    `yaml:"name"`
+	Description string    `yaml:"description"`
+	Tags        skillTags `yaml:"tags"`
+	// Requires 依赖的其他 skill、命令与 MCP 服务
+	Requires skillRequires `yaml:"requires"`
+}
+
+// skillTags front matter 中的 tags 可以是列表，也可以是逗号分隔的字符串
+type skillTags []string
+
+func (t *skillTags) UnmarshalYAML(node *yaml.Node) error {
+	var raw []string
+	if node.Kind == yaml.SequenceNode {
+		if err := node.Decode(&raw); err != nil {
+			return err
+		}
+	} else {
+		var value string
+		if err := node.Decode(&value); err != nil {
+			return err
+		}
+		raw = strings.Split(value, ",")
+	}
+	tags := make([]string, 0, len(raw))
+	seen := make(map[string]bool)
+	for _, tag := range raw {
+		tag = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(tag), "#")))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	*t = tags
+	return nil
 }
 
 type skillStore struct {
 	Skills map[string]skillState `json:"skills"`
 	Repos  []skillRepoConfig     `json:"repos"`
+	// Synced 在 Claude 与 Codex 之间同步的 skill
+	Synced map[string]skillSyncState `json:"synced,omitempty"`
+	// Projects 手动登记的项目；IgnoredProjects 为不再显示的自动发现项目
+	Projects        []skillProjectConfig `json:"projects,omitempty"`
+	IgnoredProjects []string             `json:"ignored_projects,omitempty"`
+	// Profiles 命名的 skill 组合，用于批量安装
+	Profiles []SkillProfile `json:"profiles,omitempty"`
 }
 
 type skillState struct {
@@ -64,20 +106,43 @@ type skillRepoConfig struct {
 	Name    string `json:"name"`
 	Branch  string `json:"branch"`
 	Enabled bool   `json:"enabled"`
+	// Provider 托管平台：github（默认）、gitlab、gitea
+	Provider string `json:"provider,omitempty"`
+	// BaseURL 自建实例地址（GitHub Enterprise / 自建 GitLab / Gitea），为空时使用公共平台
+	BaseURL string `json:"base_url,omitempty"`
+	// Token 私有仓库的访问令牌（PAT）
+	Token string `json:"token,omitempty"`
+	// Proxy 访问该仓库使用的代理，为空时遵循系统代理环境变量
+	Proxy string `json:"proxy,omitempty"`
 }
 
 type installRequest struct {
 	Directory string `json:"directory"`
+	Path      string `json:"path"`
 	RepoOwner string `json:"repo_owner"`
 	RepoName  string `json:"repo_name"`
 	Branch    string `json:"repo_branch"`
+	// Force 为 true 时忽略检查出的 error 级别问题继续安装
+	Force bool `json:"force"`
+	// InstallDependencies 为 true 时一并从仓库安装 requires 中缺少的 skill
+	InstallDependencies bool `json:"install_dependencies"`
 }
 
 type SkillService struct {
 	httpClient *http.Client
 	storePath  string
 	installDir string
-	mu         sync.Mutex
+	cacheDir   string
+	// backupDir 安装、卸载前的 skill 目录快照
+	backupDir string
+	// codexSkillDir Codex 的 skill 目录，用于与 installDir 同步
+	codexSkillDir string
+	mu            sync.Mutex
+	snapshotMu    sync.Mutex
+	syncMu        sync.Mutex
+	syncStopCh    chan struct{}
+	// bulkProgressHandler 批量安装 / 卸载的进度回调
+	bulkProgressHandler func(SkillBulkProgress)
 }
 
 func NewSkillService() *SkillService {
@@ -86,9 +151,12 @@ func NewSkillService() *SkillService {
 		home = "."
 	}
 	return &SkillService{
-		httpClient: &http.Client{Timeout: 60 * time.Second},
-		storePath:  filepath.Join(home, skillStoreDir, skillStoreFile),
-		installDir: filepath.Join(home, ".claude", "skills"),
+		httpClient:    &http.Client{Timeout: 60 * time.Second},
+		storePath:     filepath.Join(dataDir(), skillStoreFile),
+		installDir:    filepath.Join(home, ".claude", "skills"),
+		cacheDir:      filepath.Join(dataDir(), skillCacheDirName),
+		backupDir:     filepath.Join(dataDir(), skillBackupDirName),
+		codexSkillDir: filepath.Join(home, ".codex", "skills"),
 	}
 }
 
@@ -104,48 +172,20 @@ func (ss *SkillService) ListSkills() ([]Skill, error) {
 		if !repo.Enabled {
 			continue
 		}
-		repoDir, branch, cleanup, err := ss.prepareRepoSnapshot(repo)
+		repoSkills, err := ss.repoSkills(repo)
 		if err != nil {
 			log.Printf("skill repo fetch failed for %s/%s: %v", repo.Owner, repo.Name, err)
 			continue
 		}
-		entries, err := os.ReadDir(repoDir)
-		if err != nil {
-			cleanup()
-			log.Printf("skill repo read failed for %s/%s: %v", repo.Owner, repo.Name, err)
-			continue
-		}
-		for _, entry := range entries {
-			if !entry.IsDir() {
-				continue
-			}
-			dirKey := normalizeDirectoryKey(entry.Name())
+		for _, entry := range repoSkills {
+			dirKey := normalizeDirectoryKey(entry.Directory)
 			if _, exists := skillMap[dirKey]; exists {
 				continue
 			}
-			skillPath := filepath.Join(repoDir, entry.Name())
-			meta, err := readSkillMetadata(skillPath)
-			if err != nil {
-				continue
-			}
-			name := strings.TrimSpace(meta.Name)
-			if name == "" {
-				name = entry.Name()
-			}
-			key := buildSkillKey(repo.Owner, repo.Name, entry.Name())
-			skillMap[dirKey] = Skill{
-				Key:         key,
-				Name:        name,
-				Description: strings.TrimSpace(meta.Description),
-				Directory:   entry.Name(),
-				ReadmeURL:   buildRepoURL(repo, branch, entry.Name()),
-				Installed:   ss.isInstalled(entry.Name()),
-				RepoOwner:   repo.Owner,
-				RepoName:    repo.Name,
-				RepoBranch:  branch,
-			}
+			skill := entry.Skill
+			skill.Installed = ss.isInstalled(skill.Directory)
+			skillMap[dirKey] = skill
 		}
-		cleanup()
 	}
 
 	ss.mergeLocalSkills(skillMap)
@@ -165,7 +205,46 @@ func (ss *SkillService) ListSkills() ([]Skill, error) {
 }
 
 // InstallSkill installs a skill directory from the configured repositories.
-func (ss *SkillService) InstallSkill(req installRequest) error {
+// 安装前会检查 skill，存在 error 级别的问题时不安装（除非 req.Force），问题通过返回值交给前端展示
+func (ss *SkillService) InstallSkill(req installRequest) (SkillInstallResult, error) {
+	return ss.installFromRepos(req, ss.installDir)
+}
+
+// installFromRepos 从仓库安装 skill 到 targetDir，并检查其声明的依赖
+func (ss *SkillService) installFromRepos(req installRequest, targetDir string) (SkillInstallResult, error) {
+	return ss.installWithDependencies(req, targetDir, map[string]bool{}, 1)
+}
+
+// installRepoSkill 从仓库安装单个 skill；安装到用户级目录时记录安装状态，安装到项目目录时只复制文件
+func (ss *SkillService) installRepoSkill(req installRequest, targetDir string) (SkillInstallResult, error) {
+	var result SkillInstallResult
+	err := ss.withRepoSkill(req, func(skillPath string) error {
+		result.Issues = lintSkillDir(skillPath, req.Directory)
+		if hasSkillLintErrors(result.Issues) && !req.Force {
+			return nil
+		}
+		var err error
+		if targetDir == ss.installDir {
+			err = ss.installFromPath(req.Directory, skillPath)
+		} else if _, err = os.Stat(filepath.Join(skillPath, "SKILL.md")); err != nil {
+			err = fmt.Errorf("%s 缺少 SKILL.md", req.Directory)
+		} else if err = ss.backupSkillDir(filepath.Join(targetDir, req.Directory), skillBackupInstall); err == nil {
+			err = installSkillCopy(skillPath, targetDir, req.Directory)
+		}
+		if err != nil {
+			return err
+		}
+		result.Installed = true
+		return nil
+	})
+	if err != nil {
+		return SkillInstallResult{}, err
+	}
+	return result, nil
+}
+
+// withRepoSkill 依次在可用仓库的快照中定位 skill 并调用 fn，fn 成功即返回；快照在 fn 返回后清理
+func (ss *SkillService) withRepoSkill(req installRequest, fn func(skillPath string) error) error {
 	req.Directory = strings.TrimSpace(req.Directory)
 	if req.Directory == "" {
 		return errors.New("skill directory 不能为空")
@@ -186,19 +265,24 @@ func (ss *SkillService) InstallSkill(req installRequest) error {
 			lastErr = err
 			continue
 		}
-		skillPath := filepath.Join(repoDir, req.Directory)
+		skillPath, err := locateRepoSkill(repoDir, req)
+		if err != nil {
+			cleanup()
+			lastErr = err
+			continue
+		}
 		info, err := os.Stat(skillPath)
 		if err != nil || !info.IsDir() {
 			cleanup()
 			lastErr = fmt.Errorf("仓库 %s/%s 中未找到 %s", repo.Owner, repo.Name, req.Directory)
 			continue
 		}
-		if err := ss.installFromPath(req.Directory, skillPath); err != nil {
-			cleanup()
+		err = fn(skillPath)
+		cleanup()
+		if err != nil {
 			lastErr = err
 			continue
 		}
-		cleanup()
 		return nil
 	}
 	if lastErr == nil {
@@ -207,6 +291,31 @@ func (ss *SkillService) InstallSkill(req installRequest) error {
 	return lastErr
 }
 
+// locateRepoSkill 按相对路径定位 skill；旧版前端只传目录名时，先找根目录，再在嵌套目录中查找同名 skill
+func locateRepoSkill(repoDir string, req installRequest) (string, error) {
+	if rel := strings.TrimSpace(req.Path); rel != "" {
+		rel = filepath.FromSlash(rel)
+		if !filepath.IsLocal(rel) && rel != "." {
+			return "", fmt.Errorf("skill 路径无效: %s", req.Path)
+		}
+		return filepath.Join(repoDir, rel), nil
+	}
+	direct := filepath.Join(repoDir, req.Directory)
+	if info, err := os.Stat(direct); err == nil && info.IsDir() {
+		return direct, nil
+	}
+	skills, err := scanRepoSkills(skillRepoConfig{}, repoDir, "")
+	if err != nil {
+		return "", err
+	}
+	for _, skill := range skills {
+		if strings.EqualFold(skill.Directory, req.Directory) {
+			return filepath.Join(repoDir, filepath.FromSlash(skill.Path)), nil
+		}
+	}
+	return direct, nil
+}
+
 func (ss *SkillService) installFromPath(directory, source string) error {
 	if _, err := os.Stat(filepath.Join(source, "SKILL.md")); err != nil {
 		return fmt.Errorf("%s 缺少 SKILL.md", directory)
@@ -215,6 +324,9 @@ func (ss *SkillService) installFromPath(directory, source string) error {
 		return err
 	}
 	target := filepath.Join(ss.installDir, directory)
+	if err := ss.backupSkillDir(target, skillBackupInstall); err != nil {
+		return err
+	}
 	if err := os.RemoveAll(target); err != nil && !os.IsNotExist(err) {
 		return err
 	}
@@ -240,6 +352,9 @@ func (ss *SkillService) UninstallSkill(directory string) error {
 		return errors.New("skill directory 不能为空")
 	}
 	target := filepath.Join(ss.installDir, directory)
+	if err := ss.backupSkillDir(target, skillBackupUninstall); err != nil {
+		return err
+	}
 	if err := os.RemoveAll(target); err != nil && !os.IsNotExist(err) {
 		return err
 	}
@@ -291,6 +406,7 @@ func (ss *SkillService) AddRepo(repo skillRepoConfig) ([]skillRepoConfig, error)
 	if err := ss.saveStoreLocked(store); err != nil {
 		return nil, err
 	}
+	invalidateIndexedRepo(repo.Owner, repo.Name)
 	return cloneRepoConfigs(store.Repos), nil
 }
 
@@ -309,6 +425,7 @@ func (ss *SkillService) RemoveRepo(owner, name string) ([]skillRepoConfig, error
 	filtered := make([]skillRepoConfig, 0, len(store.Repos))
 	for _, repo := range store.Repos {
 		if strings.EqualFold(repo.Owner, owner) && strings.EqualFold(repo.Name, name) {
+			_ = os.RemoveAll(ss.repoCacheDir(repo))
 			continue
 		}
 		filtered = append(filtered, repo)
@@ -388,14 +505,14 @@ func normalizeRepoConfig(repo skillRepoConfig) skillRepoConfig {
 	if !repo.Enabled {
 		repo.Enabled = true
 	}
-	return repo
+	return normalizeSkillProvider(repo)
 }
 
 func validateRepoConfig(repo skillRepoConfig) error {
 	if repo.Owner == "" || repo.Name == "" {
 		return errors.New("owner/name 不能为空")
 	}
-	return nil
+	return validateSkillProvider(repo)
 }
 
 func equalRepo(a, b skillRepoConfig) bool {
@@ -418,37 +535,6 @@ func (ss *SkillService) saveStoreLocked(store skillStore) error {
 	return os.Rename(tmp, ss.storePath)
 }
 
-func (ss *SkillService) prepareRepoSnapshot(repo skillRepoConfig) (string, string, func(), error) {
-	tmpDir, err := os.MkdirTemp("", "skill-repo-")
-	if err != nil {
-		return "", "", nil, err
-	}
-	cleanup := func() {
-		_ = os.RemoveAll(tmpDir)
-	}
-	archivePath := filepath.Join(tmpDir, "repo.zip")
-	branches := buildBranchCandidates(repo.Branch)
-	var lastErr error
-	for _, branch := range branches {
-		archiveURL := fmt.Sprintf("https://github.com/%s/%s/archive/refs/heads/%s.zip", repo.Owner, repo.Name, branch)
-		if err := ss.downloadFile(archiveURL, archivePath); err != nil {
-			lastErr = err
-			continue
-		}
-		rootDir, err := unzipArchive(archivePath, tmpDir)
-		if err != nil {
-			lastErr = err
-			continue
-		}
-		return rootDir, branch, cleanup, nil
-	}
-	cleanup()
-	if lastErr == nil {
-		lastErr = fmt.Errorf("无法下载仓库 %s/%s", repo.Owner, repo.Name)
-	}
-	return "", "", nil, lastErr
-}
-
 func buildBranchCandidates(preferred string) []string {
 	set := make(map[string]struct{})
 	ordered := make([]string, 0, len(defaultRepoBranches)+1)
@@ -467,19 +553,24 @@ func buildBranchCandidates(preferred string) []string {
 	return ordered
 }
 
-func (ss *SkillService) downloadFile(rawURL, dest string) error {
-	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+func (ss *SkillService) downloadArchive(repo skillRepoConfig, branch, dest string) error {
+	client, err := ss.repoHTTPClient(repo)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodGet, repo.archiveURL(branch), nil)
 	if err != nil {
 		return err
 	}
 	req.Header.Set("User-Agent", "ai-code-studio")
-	resp, err := ss.httpClient.Do(req)
+	repo.authorize(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("下载失败: %s", resp.Status)
+		return describeSkillDownloadError(repo, resp.StatusCode, resp.Status)
 	}
 	out, err := os.OpenFile(dest, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
 	if err != nil {
@@ -597,11 +688,7 @@ func (ss *SkillService) resolveReposForInstall(req installRequest, repos []skill
 }
 
 func buildRepoURL(repo skillRepoConfig, branch, directory string) string {
-	dir := strings.Trim(directory, "/")
-	if dir == "" {
-		return fmt.Sprintf("https://github.com/%s/%s", repo.Owner, repo.Name)
-	}
-	return fmt.Sprintf("https://github.com/%s/%s/tree/%s/%s", repo.Owner, repo.Name, branch, dir)
+	return repo.treeURL(branch, directory)
 }
 
 func buildSkillKey(owner, name, directory string) string {
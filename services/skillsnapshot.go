@@ -0,0 +1,230 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	skillCacheDirName     = "skill-cache"
+	skillSnapshotMetaFile = "snapshot.json"
+)
+
+var (
+	unsafeCacheNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+	// errSkillBranchNotFound 分支不存在时继续尝试下一个候选分支
+	errSkillBranchNotFound = errors.New("分支不存在")
+)
+
+// skillSnapshotMeta 仓库快照的元数据；SHA 为空表示无法获取提交号时下载的快照
+type skillSnapshotMeta struct {
+	SHA       string    `json:"sha"`
+	Branch    string    `json:"branch"`
+	Dir       string    `json:"dir"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// prepareRepoSnapshot 返回仓库快照目录。快照按提交 SHA 缓存在数据目录下，远端分支没有新提交时
+// 不会重新下载；无法获取提交号（离线、API 限流）时沿用已有快照
+func (ss *SkillService) prepareRepoSnapshot(repo skillRepoConfig) (string, string, func(), error) {
+	ss.snapshotMu.Lock()
+	defer ss.snapshotMu.Unlock()
+
+	noop := func() {}
+	cacheDir := ss.repoCacheDir(repo)
+	cached, hasCache := loadSkillSnapshotMeta(cacheDir)
+
+	var lastErr error
+	for _, branch := range buildBranchCandidates(repo.Branch) {
+		sha, err := ss.resolveRepoCommit(repo, branch)
+		if err != nil {
+			lastErr = err
+			if errors.Is(err, errSkillBranchNotFound) {
+				continue
+			}
+			break
+		}
+		if hasCache && cached.SHA == sha && cached.Branch == branch {
+			return filepath.Join(cacheDir, cached.Dir), branch, noop, nil
+		}
+		dir, err := ss.downloadSnapshot(repo, branch, sha, cacheDir, cached)
+		if err != nil {
+			return "", "", nil, err
+		}
+		return dir, branch, noop, nil
+	}
+
+	if hasCache {
+		log.Printf("skill repo %s/%s: resolve commit failed, using snapshot from %s: %v", repo.Owner, repo.Name, cached.FetchedAt.Format(time.DateTime), lastErr)
+		return filepath.Join(cacheDir, cached.Dir), cached.Branch, noop, nil
+	}
+	// 没有缓存时仍按分支直接下载一次
+	for _, branch := range buildBranchCandidates(repo.Branch) {
+		dir, err := ss.downloadSnapshot(repo, branch, "", cacheDir, cached)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return dir, branch, noop, nil
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("无法下载仓库 %s/%s", repo.Owner, repo.Name)
+	}
+	return "", "", nil, lastErr
+}
+
+// repoCacheDir 每个仓库独立的缓存目录：skill-cache/<host>/<owner>/<name>
+func (ss *SkillService) repoCacheDir(repo skillRepoConfig) string {
+	host := repo.Provider
+	if parsed, err := url.Parse(repo.webBaseURL()); err == nil && parsed.Host != "" {
+		host = parsed.Host
+	}
+	return filepath.Join(ss.cacheDir, cacheName(host), cacheName(repo.Owner), cacheName(repo.Name))
+}
+
+func cacheName(value string) string {
+	name := strings.Trim(unsafeCacheNameChars.ReplaceAllString(strings.ToLower(value), "_"), "._")
+	if name == "" {
+		return "_"
+	}
+	return name
+}
+
+// downloadSnapshot 下载分支压缩包并解压到缓存目录，只保留新快照和上一份快照
+// （上一份可能正被安装流程读取）
+func (ss *SkillService) downloadSnapshot(repo skillRepoConfig, branch, sha, cacheDir string, previous skillSnapshotMeta) (string, error) {
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		return "", err
+	}
+	tmpDir, err := os.MkdirTemp(cacheDir, ".download-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	archivePath := filepath.Join(tmpDir, "repo.zip")
+	if err := ss.downloadArchive(repo, branch, archivePath); err != nil {
+		return "", err
+	}
+	rootDir, err := unzipArchive(archivePath, filepath.Join(tmpDir, "extract"))
+	if err != nil {
+		return "", err
+	}
+
+	name := sha
+	if name == "" {
+		name = fmt.Sprintf("head-%d", time.Now().Unix())
+	}
+	name = cacheName(name)
+	target := filepath.Join(cacheDir, name)
+	if err := os.RemoveAll(target); err != nil {
+		return "", err
+	}
+	if err := os.Rename(rootDir, target); err != nil {
+		return "", err
+	}
+	meta := skillSnapshotMeta{SHA: sha, Branch: branch, Dir: name, FetchedAt: time.Now()}
+	if err := saveSkillSnapshotMeta(cacheDir, meta); err != nil {
+		return "", err
+	}
+
+	entries, _ := os.ReadDir(cacheDir)
+	for _, entry := range entries {
+		switch entry.Name() {
+		case name, previous.Dir, skillSnapshotMetaFile, filepath.Base(tmpDir):
+			continue
+		}
+		_ = os.RemoveAll(filepath.Join(cacheDir, entry.Name()))
+	}
+	return target, nil
+}
+
+// resolveRepoCommit 通过平台 API 查询分支最新提交号，只请求元数据，不下载仓库内容
+func (ss *SkillService) resolveRepoCommit(repo skillRepoConfig, branch string) (string, error) {
+	client, err := ss.repoHTTPClient(repo)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(http.MethodGet, repo.branchURL(branch), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "ai-code-studio")
+	repo.authorize(req)
+	if repo.Provider == skillProviderGitHub {
+		req.Header.Set("Accept", "application/vnd.github.sha")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusUnprocessableEntity {
+		return "", fmt.Errorf("%s: %w", branch, errSkillBranchNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", describeSkillDownloadError(repo, resp.StatusCode, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	return parseBranchCommit(repo.Provider, body)
+}
+
+// parseBranchCommit GitHub 以 sha 媒体类型返回纯文本提交号，GitLab/Gitea 返回分支 JSON
+func parseBranchCommit(provider string, body []byte) (string, error) {
+	var sha string
+	if provider == skillProviderGitHub {
+		sha = strings.TrimSpace(string(body))
+	} else {
+		var branch struct {
+			Commit struct {
+				ID string `json:"id"`
+			} `json:"commit"`
+		}
+		if err := json.Unmarshal(body, &branch); err != nil {
+			return "", err
+		}
+		sha = branch.Commit.ID
+	}
+	if len(sha) < 7 || strings.ContainsAny(sha, " \n/\\") {
+		return "", fmt.Errorf("无法解析提交号: %.64s", sha)
+	}
+	return sha, nil
+}
+
+func loadSkillSnapshotMeta(cacheDir string) (skillSnapshotMeta, bool) {
+	var meta skillSnapshotMeta
+	data, err := os.ReadFile(filepath.Join(cacheDir, skillSnapshotMetaFile))
+	if err != nil || json.Unmarshal(data, &meta) != nil || meta.Dir == "" {
+		return skillSnapshotMeta{}, false
+	}
+	info, err := os.Stat(filepath.Join(cacheDir, meta.Dir))
+	if err != nil || !info.IsDir() {
+		return skillSnapshotMeta{}, false
+	}
+	return meta, true
+}
+
+func saveSkillSnapshotMeta(cacheDir string, meta skillSnapshotMeta) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(cacheDir, skillSnapshotMetaFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(cacheDir, skillSnapshotMetaFile))
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPrepareRepoSnapshotCachesBySHA(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create("skills-main/pdf/SKILL.md")
+	_, _ = w.Write([]byte("---\nname: pdf\n---\n"))
+	_ = zw.Close()
+
+	var sha atomic.Value
+	sha.Store("1111111111111111111111111111111111111111")
+	var downloads atomic.Int32
+	var offline atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if offline.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/repos/acme/skills/branches/main":
+			_, _ = w.Write([]byte(`{"name":"main","commit":{"id":"` + sha.Load().(string) + `"}}`))
+		case "/api/v1/repos/acme/skills/archive/main.zip":
+			downloads.Add(1)
+			http.ServeContent(w, r, "main.zip", time.Time{}, bytes.NewReader(buf.Bytes()))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	ss := &SkillService{httpClient: server.Client(), cacheDir: t.TempDir()}
+	repo := normalizeRepoConfig(skillRepoConfig{Owner: "acme", Name: "skills", Provider: "gitea", BaseURL: server.URL})
+
+	first, branch, _, err := ss.prepareRepoSnapshot(repo)
+	if err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	if branch != "main" {
+		t.Fatalf("branch = %s", branch)
+	}
+	if _, err := os.Stat(filepath.Join(first, "pdf", "SKILL.md")); err != nil {
+		t.Fatalf("snapshot missing skill: %v", err)
+	}
+	if again, _, _, err := ss.prepareRepoSnapshot(repo); err != nil || again != first || downloads.Load() != 1 {
+		t.Fatalf("unchanged commit should reuse snapshot: dir=%s err=%v downloads=%d", again, err, downloads.Load())
+	}
+
+	sha.Store("2222222222222222222222222222222222222222")
+	second, _, _, err := ss.prepareRepoSnapshot(repo)
+	if err != nil || second == first || downloads.Load() != 2 {
+		t.Fatalf("new commit should download: dir=%s err=%v downloads=%d", second, err, downloads.Load())
+	}
+	if _, err := os.Stat(first); err != nil {
+		t.Fatalf("previous snapshot should be kept: %v", err)
+	}
+
+	offline.Store(true)
+	if cached, _, _, err := ss.prepareRepoSnapshot(repo); err != nil || cached != second {
+		t.Fatalf("offline should use cached snapshot: dir=%s err=%v", cached, err)
+	}
+}
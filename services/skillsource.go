@@ -0,0 +1,174 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// 技能仓库托管平台
+const (
+	skillProviderGitHub = "github"
+	skillProviderGitLab = "gitlab"
+	skillProviderGitea  = "gitea"
+)
+
+var defaultSkillProviderURLs = map[string]string{
+	skillProviderGitHub: "https://github.com",
+	skillProviderGitLab: "https://gitlab.com",
+}
+
+// webBaseURL 仓库所在平台的网页地址，自建实例使用配置的 BaseURL
+func (repo skillRepoConfig) webBaseURL() string {
+	if repo.BaseURL != "" {
+		return repo.BaseURL
+	}
+	return defaultSkillProviderURLs[repo.Provider]
+}
+
+// archiveURL 指定分支的 zip 下载地址。GitHub 公开仓库走 archive 链接，
+// 配置了 Token 时改用 API 的 zipball（私有仓库只能通过 API 下载）
+func (repo skillRepoConfig) archiveURL(branch string) string {
+	base := repo.webBaseURL()
+	switch repo.Provider {
+	case skillProviderGitLab:
+		project := url.PathEscape(repo.Owner + "/" + repo.Name)
+		return fmt.Sprintf("%s/api/v4/projects/%s/repository/archive.zip?sha=%s", base, project, url.QueryEscape(branch))
+	case skillProviderGitea:
+		return fmt.Sprintf("%s/api/v1/repos/%s/%s/archive/%s.zip", base, repo.Owner, repo.Name, url.PathEscape(branch))
+	default:
+		if repo.Token == "" {
+			return fmt.Sprintf("%s/%s/%s/archive/refs/heads/%s.zip", base, repo.Owner, repo.Name, branch)
+		}
+		return fmt.Sprintf("%s/repos/%s/%s/zipball/%s", repo.githubAPIBase(), repo.Owner, repo.Name, url.PathEscape(branch))
+	}
+}
+
+// branchURL 查询分支最新提交的 API 地址
+func (repo skillRepoConfig) branchURL(branch string) string {
+	base := repo.webBaseURL()
+	switch repo.Provider {
+	case skillProviderGitLab:
+		project := url.PathEscape(repo.Owner + "/" + repo.Name)
+		return fmt.Sprintf("%s/api/v4/projects/%s/repository/branches/%s", base, project, url.PathEscape(branch))
+	case skillProviderGitea:
+		return fmt.Sprintf("%s/api/v1/repos/%s/%s/branches/%s", base, repo.Owner, repo.Name, url.PathEscape(branch))
+	default:
+		return fmt.Sprintf("%s/repos/%s/%s/commits/%s", repo.githubAPIBase(), repo.Owner, repo.Name, url.PathEscape(branch))
+	}
+}
+
+// githubAPIBase github.com 使用 api.github.com，GitHub Enterprise Server 使用 <host>/api/v3
+func (repo skillRepoConfig) githubAPIBase() string {
+	if base := repo.webBaseURL(); base != defaultSkillProviderURLs[skillProviderGitHub] {
+		return base + "/api/v3"
+	}
+	return "https://api.github.com"
+}
+
+// treeURL 仓库内某个目录的网页地址
+func (repo skillRepoConfig) treeURL(branch, directory string) string {
+	base := fmt.Sprintf("%s/%s/%s", repo.webBaseURL(), repo.Owner, repo.Name)
+	dir := strings.Trim(directory, "/")
+	if dir == "" {
+		return base
+	}
+	switch repo.Provider {
+	case skillProviderGitLab:
+		return fmt.Sprintf("%s/-/tree/%s/%s", base, branch, dir)
+	case skillProviderGitea:
+		return fmt.Sprintf("%s/src/branch/%s/%s", base, branch, dir)
+	default:
+		return fmt.Sprintf("%s/tree/%s/%s", base, branch, dir)
+	}
+}
+
+// authorize 按平台设置访问令牌请求头
+func (repo skillRepoConfig) authorize(req *http.Request) {
+	if repo.Token == "" {
+		return
+	}
+	switch repo.Provider {
+	case skillProviderGitLab:
+		req.Header.Set("PRIVATE-TOKEN", repo.Token)
+	case skillProviderGitea:
+		req.Header.Set("Authorization", "token "+repo.Token)
+	default:
+		req.Header.Set("Authorization", "Bearer "+repo.Token)
+		req.Header.Set("Accept", "application/vnd.github+json")
+	}
+}
+
+// repoHTTPClient 仓库配置了代理时使用独立的 Transport，否则沿用默认客户端（遵循系统代理环境变量）
+func (ss *SkillService) repoHTTPClient(repo skillRepoConfig) (*http.Client, error) {
+	if repo.Proxy == "" {
+		return ss.httpClient, nil
+	}
+	proxyURL, err := url.Parse(repo.Proxy)
+	if err != nil || proxyURL.Host == "" {
+		return nil, fmt.Errorf("代理地址无效: %s", repo.Proxy)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(proxyURL)
+	return &http.Client{Transport: transport, Timeout: ss.httpClient.Timeout}, nil
+}
+
+// normalizeSkillProvider 规范化平台与自建实例地址
+func normalizeSkillProvider(repo skillRepoConfig) skillRepoConfig {
+	repo.Provider = strings.ToLower(strings.TrimSpace(repo.Provider))
+	if repo.Provider == "" {
+		repo.Provider = skillProviderGitHub
+	}
+	repo.BaseURL = strings.TrimRight(strings.TrimSpace(repo.BaseURL), "/")
+	if repo.BaseURL == defaultSkillProviderURLs[repo.Provider] {
+		repo.BaseURL = ""
+	}
+	repo.Token = strings.TrimSpace(repo.Token)
+	repo.Proxy = strings.TrimSpace(repo.Proxy)
+	return repo
+}
+
+func validateSkillProvider(repo skillRepoConfig) error {
+	switch repo.Provider {
+	case skillProviderGitHub, skillProviderGitLab:
+	case skillProviderGitea:
+		if repo.BaseURL == "" {
+			return errors.New("Gitea 仓库需要填写实例地址")
+		}
+	default:
+		return fmt.Errorf("不支持的仓库平台: %s", repo.Provider)
+	}
+	if repo.BaseURL != "" {
+		parsed, err := url.Parse(repo.BaseURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("实例地址无效: %s", repo.BaseURL)
+		}
+	}
+	if repo.Provider != skillProviderGitLab && strings.Contains(repo.Owner, "/") {
+		return errors.New("owner 不能包含 /")
+	}
+	if repo.Proxy != "" {
+		if parsed, err := url.Parse(repo.Proxy); err != nil || parsed.Host == "" {
+			return fmt.Errorf("代理地址无效: %s", repo.Proxy)
+		}
+	}
+	return nil
+}
+
+// describeSkillDownloadError 私有仓库常见的鉴权失败给出提示
+func describeSkillDownloadError(repo skillRepoConfig, status int, statusText string) error {
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		if repo.Token == "" {
+			return fmt.Errorf("下载失败: %s（私有仓库需要配置访问令牌）", statusText)
+		}
+		return fmt.Errorf("下载失败: %s（访问令牌无效或权限不足）", statusText)
+	case http.StatusNotFound:
+		if repo.Token == "" {
+			return fmt.Errorf("下载失败: %s（仓库或分支不存在，私有仓库需要配置访问令牌）", statusText)
+		}
+	}
+	return fmt.Errorf("下载失败: %s", statusText)
+}
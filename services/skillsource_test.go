@@ -0,0 +1,63 @@
+package services
+
+import "testing"
+
+func TestSkillRepoURLs(t *testing.T) {
+	cases := []struct {
+		repo    skillRepoConfig
+		archive string
+		tree    string
+	}{
+		{
+			repo:    skillRepoConfig{Owner: "anthropics", Name: "skills"},
+			archive: "https://github.com/anthropics/skills/archive/refs/heads/main.zip",
+			tree:    "https://github.com/anthropics/skills/tree/main/pdf",
+		},
+		{
+			repo:    skillRepoConfig{Owner: "acme", Name: "skills", Token: "t"},
+			archive: "https://api.github.com/repos/acme/skills/zipball/main",
+			tree:    "https://github.com/acme/skills/tree/main/pdf",
+		},
+		{
+			repo:    skillRepoConfig{Owner: "acme", Name: "skills", Provider: "github", BaseURL: "https://ghe.acme.com/", Token: "t"},
+			archive: "https://ghe.acme.com/api/v3/repos/acme/skills/zipball/main",
+			tree:    "https://ghe.acme.com/acme/skills/tree/main/pdf",
+		},
+		{
+			repo:    skillRepoConfig{Owner: "team/ai", Name: "skills", Provider: "GitLab", BaseURL: "https://git.acme.com"},
+			archive: "https://git.acme.com/api/v4/projects/team%2Fai%2Fskills/repository/archive.zip?sha=main",
+			tree:    "https://git.acme.com/team/ai/skills/-/tree/main/pdf",
+		},
+		{
+			repo:    skillRepoConfig{Owner: "acme", Name: "skills", Provider: "gitea", BaseURL: "https://gitea.acme.com"},
+			archive: "https://gitea.acme.com/api/v1/repos/acme/skills/archive/main.zip",
+			tree:    "https://gitea.acme.com/acme/skills/src/branch/main/pdf",
+		},
+	}
+	for _, tc := range cases {
+		repo := normalizeRepoConfig(tc.repo)
+		if err := validateRepoConfig(repo); err != nil {
+			t.Fatalf("validate %+v: %v", repo, err)
+		}
+		if got := repo.archiveURL("main"); got != tc.archive {
+			t.Errorf("archiveURL = %s, want %s", got, tc.archive)
+		}
+		if got := buildRepoURL(repo, "main", "pdf"); got != tc.tree {
+			t.Errorf("treeURL = %s, want %s", got, tc.tree)
+		}
+	}
+}
+
+func TestValidateSkillProvider(t *testing.T) {
+	invalid := []skillRepoConfig{
+		{Owner: "a", Name: "b", Provider: "gitea"},
+		{Owner: "a", Name: "b", Provider: "bitbucket"},
+		{Owner: "a/b", Name: "c"},
+		{Owner: "a", Name: "b", Proxy: "not a url"},
+	}
+	for _, repo := range invalid {
+		if err := validateRepoConfig(normalizeRepoConfig(repo)); err == nil {
+			t.Errorf("expected error for %+v", repo)
+		}
+	}
+}
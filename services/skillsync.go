@@ -0,0 +1,311 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	skillSyncInterval = 10 * time.Second
+	skillSideClaude   = "claude"
+	skillSideCodex    = "codex"
+)
+
+// skillSyncState 同步中的 skill 上次同步后的内容哈希，用于判断哪一侧被修改过
+type skillSyncState struct {
+	Hash     string    `json:"hash"`
+	SyncedAt time.Time `json:"synced_at"`
+	Conflict bool      `json:"conflict,omitempty"`
+}
+
+// SkillSyncStatus 某个 skill 在 Claude 与 Codex 两侧的同步状态
+type SkillSyncStatus struct {
+	Directory string    `json:"directory"`
+	Claude    bool      `json:"claude"`
+	Codex     bool      `json:"codex"`
+	InSync    bool      `json:"in_sync"`
+	Conflict  bool      `json:"conflict"`
+	SyncedAt  time.Time `json:"synced_at"`
+}
+
+// SyncSkill 在 ~/.claude/skills 与 ~/.codex/skills 之间镜像 skill，并在之后持续同步两侧的修改。
+// from 指定以哪一侧为准（claude / codex），为空时使用唯一存在的一侧；两侧都存在且内容不同时必须指定
+func (ss *SkillService) SyncSkill(directory, from string) (SkillSyncStatus, error) {
+	directory = strings.TrimSpace(directory)
+	if directory == "" || !filepath.IsLocal(directory) || strings.ContainsAny(directory, `/\`) {
+		return SkillSyncStatus{}, errors.New("skill directory 无效")
+	}
+	ss.syncMu.Lock()
+	defer ss.syncMu.Unlock()
+
+	claudeDir, codexDir := ss.skillSidePaths(directory)
+	claudeHash, claudeErr := hashSkillDir(claudeDir)
+	codexHash, codexErr := hashSkillDir(codexDir)
+	from = strings.ToLower(strings.TrimSpace(from))
+	if from == "" {
+		switch {
+		case claudeErr == nil && codexErr == nil:
+			if claudeHash != codexHash {
+				return SkillSyncStatus{}, errors.New("两侧的 skill 内容不同，请选择以哪一侧为准")
+			}
+			from = skillSideClaude
+		case claudeErr == nil:
+			from = skillSideClaude
+		case codexErr == nil:
+			from = skillSideCodex
+		default:
+			return SkillSyncStatus{}, fmt.Errorf("%s 在 Claude 与 Codex 中都不存在", directory)
+		}
+	}
+
+	var hash string
+	switch from {
+	case skillSideClaude:
+		if claudeErr != nil {
+			return SkillSyncStatus{}, fmt.Errorf("Claude 中不存在 %s", directory)
+		}
+		hash = claudeHash
+		if codexErr != nil || codexHash != claudeHash {
+			if err := ss.backupSkillDir(codexDir, skillBackupSync); err != nil {
+				return SkillSyncStatus{}, err
+			}
+			if err := mirrorSkillDir(claudeDir, codexDir); err != nil {
+				return SkillSyncStatus{}, err
+			}
+		}
+	case skillSideCodex:
+		if codexErr != nil {
+			return SkillSyncStatus{}, fmt.Errorf("Codex 中不存在 %s", directory)
+		}
+		hash = codexHash
+		if claudeErr != nil || claudeHash != codexHash {
+			if err := ss.backupSkillDir(claudeDir, skillBackupSync); err != nil {
+				return SkillSyncStatus{}, err
+			}
+			if err := mirrorSkillDir(codexDir, claudeDir); err != nil {
+				return SkillSyncStatus{}, err
+			}
+		}
+	default:
+		return SkillSyncStatus{}, fmt.Errorf("不支持的平台: %s", from)
+	}
+
+	state := skillSyncState{Hash: hash, SyncedAt: time.Now()}
+	if err := ss.saveSkillSyncState(directory, &state); err != nil {
+		return SkillSyncStatus{}, err
+	}
+	return SkillSyncStatus{Directory: directory, Claude: true, Codex: true, InSync: true, SyncedAt: state.SyncedAt}, nil
+}
+
+// UnsyncSkill 停止同步，两侧已有的文件保留
+func (ss *SkillService) UnsyncSkill(directory string) error {
+	ss.syncMu.Lock()
+	defer ss.syncMu.Unlock()
+	return ss.saveSkillSyncState(strings.TrimSpace(directory), nil)
+}
+
+// ListSkillSync 返回所有同步中的 skill 及其状态
+func (ss *SkillService) ListSkillSync() ([]SkillSyncStatus, error) {
+	store, err := ss.loadStore()
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]SkillSyncStatus, 0, len(store.Synced))
+	for directory, state := range store.Synced {
+		claudeDir, codexDir := ss.skillSidePaths(directory)
+		claudeHash, claudeErr := hashSkillDir(claudeDir)
+		codexHash, codexErr := hashSkillDir(codexDir)
+		statuses = append(statuses, SkillSyncStatus{
+			Directory: directory,
+			Claude:    claudeErr == nil,
+			Codex:     codexErr == nil,
+			InSync:    claudeErr == nil && codexErr == nil && claudeHash == codexHash,
+			Conflict:  state.Conflict,
+			SyncedAt:  state.SyncedAt,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Directory < statuses[j].Directory
+	})
+	return statuses, nil
+}
+
+// Start 定时检查同步中的 skill，把一侧的修改复制到另一侧
+func (ss *SkillService) Start() error {
+	ss.syncMu.Lock()
+	defer ss.syncMu.Unlock()
+	if ss.syncStopCh != nil {
+		return nil
+	}
+	stopCh := make(chan struct{})
+	ss.syncStopCh = stopCh
+	go func() {
+		ticker := time.NewTicker(skillSyncInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ss.syncSkills()
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (ss *SkillService) Stop() error {
+	ss.syncMu.Lock()
+	defer ss.syncMu.Unlock()
+	if ss.syncStopCh != nil {
+		close(ss.syncStopCh)
+		ss.syncStopCh = nil
+	}
+	return nil
+}
+
+// syncSkills 只有一侧相对上次同步发生变化时才复制；两侧都改过则标记冲突，等待用户选择；
+// 任一侧被删除时停止同步
+func (ss *SkillService) syncSkills() {
+	ss.syncMu.Lock()
+	defer ss.syncMu.Unlock()
+	store, err := ss.loadStore()
+	if err != nil {
+		return
+	}
+	for directory, state := range store.Synced {
+		claudeDir, codexDir := ss.skillSidePaths(directory)
+		claudeHash, claudeErr := hashSkillDir(claudeDir)
+		codexHash, codexErr := hashSkillDir(codexDir)
+		if claudeErr != nil || codexErr != nil {
+			fmt.Printf("[WARN] skill %s 已从一侧删除，停止同步\n", directory)
+			_ = ss.saveSkillSyncState(directory, nil)
+			continue
+		}
+
+		next := state
+		switch {
+		case claudeHash == codexHash:
+			next.Hash = claudeHash
+			next.Conflict = false
+		case state.Conflict:
+			continue
+		case codexHash == state.Hash:
+			if err := mirrorSkillDir(claudeDir, codexDir); err != nil {
+				fmt.Printf("[WARN] 同步 skill %s 到 Codex 失败: %v\n", directory, err)
+				continue
+			}
+			next.Hash = claudeHash
+		case claudeHash == state.Hash:
+			if err := mirrorSkillDir(codexDir, claudeDir); err != nil {
+				fmt.Printf("[WARN] 同步 skill %s 到 Claude 失败: %v\n", directory, err)
+				continue
+			}
+			next.Hash = codexHash
+		default:
+			fmt.Printf("[WARN] skill %s 在 Claude 与 Codex 中都被修改，等待手动选择\n", directory)
+			next.Conflict = true
+		}
+		if next == state {
+			continue
+		}
+		if next.Hash != state.Hash {
+			next.SyncedAt = time.Now()
+		}
+		if err := ss.saveSkillSyncState(directory, &next); err != nil {
+			fmt.Printf("[WARN] 保存 skill 同步状态失败: %v\n", err)
+		}
+	}
+}
+
+func (ss *SkillService) skillSidePaths(directory string) (string, string) {
+	return filepath.Join(ss.installDir, directory), filepath.Join(ss.codexSkillDir, directory)
+}
+
+// saveSkillSyncState 更新单个 skill 的同步状态，state 为 nil 时移除
+func (ss *SkillService) saveSkillSyncState(directory string, state *skillSyncState) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	store, err := ss.loadStoreLocked()
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		if _, ok := store.Synced[directory]; !ok {
+			return nil
+		}
+		delete(store.Synced, directory)
+	} else {
+		if store.Synced == nil {
+			store.Synced = make(map[string]skillSyncState)
+		}
+		store.Synced[directory] = *state
+	}
+	return ss.saveStoreLocked(store)
+}
+
+// hashSkillDir 按相对路径与文件内容计算目录哈希，忽略隐藏文件与目录
+func hashSkillDir(dir string) (string, error) {
+	if _, err := os.Stat(filepath.Join(dir, "SKILL.md")); err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		fmt.Fprintf(hash, "%s\x00", filepath.ToSlash(rel))
+		_, err = io.Copy(hash, file)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// mirrorSkillDir 先复制到临时目录再替换，避免另一侧的 CLI 读到一半的内容
+func mirrorSkillDir(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dst), "."+filepath.Base(dst)+"-sync-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	if err := copyDirectory(src, tmp); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dst); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSkillSync(t *testing.T) {
+	root := t.TempDir()
+	ss := &SkillService{
+		installDir:    filepath.Join(root, "claude"),
+		codexSkillDir: filepath.Join(root, "codex"),
+		storePath:     filepath.Join(root, "skill.json"),
+	}
+	if _, err := ss.CreateSkill(CreateSkillRequest{Name: "demo", Description: "demo skill"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ss.SyncSkill("demo", ""); err != nil {
+		t.Fatalf("SyncSkill: %v", err)
+	}
+	claudeFile := filepath.Join(ss.installDir, "demo", "README.md")
+	codexFile := filepath.Join(ss.codexSkillDir, "demo", "README.md")
+	if _, err := os.Stat(codexFile); err != nil {
+		t.Fatalf("skill not mirrored to codex: %v", err)
+	}
+
+	// Codex 一侧修改后同步回 Claude
+	if err := os.WriteFile(codexFile, []byte("edited in codex"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ss.syncSkills()
+	if data, _ := os.ReadFile(claudeFile); string(data) != "edited in codex" {
+		t.Fatalf("claude side not updated: %q", data)
+	}
+
+	// 两侧都修改时标记冲突，不覆盖任何一侧
+	_ = os.WriteFile(claudeFile, []byte("claude"), 0o644)
+	_ = os.WriteFile(codexFile, []byte("codex"), 0o644)
+	ss.syncSkills()
+	statuses, err := ss.ListSkillSync()
+	if err != nil || len(statuses) != 1 || !statuses[0].Conflict {
+		t.Fatalf("expected conflict, got %+v (%v)", statuses, err)
+	}
+	if data, _ := os.ReadFile(codexFile); string(data) != "codex" {
+		t.Fatalf("conflict should not overwrite: %q", data)
+	}
+
+	if _, err := ss.SyncSkill("demo", "claude"); err != nil {
+		t.Fatalf("resolve conflict: %v", err)
+	}
+	statuses, _ = ss.ListSkillSync()
+	if len(statuses) != 1 || statuses[0].Conflict || !statuses[0].InSync {
+		t.Fatalf("expected in sync after resolve, got %+v", statuses)
+	}
+
+	// 一侧删除后停止同步
+	_ = os.RemoveAll(filepath.Join(ss.codexSkillDir, "demo"))
+	ss.syncSkills()
+	if statuses, _ = ss.ListSkillSync(); len(statuses) != 0 {
+		t.Fatalf("expected sync removed, got %+v", statuses)
+	}
+}
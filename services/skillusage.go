@@ -0,0 +1,207 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/daodao97/xgo/xdb"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	skillUsageTable = "skill_usage"
+	// skillToolName Claude Code 加载 skill 时调用的工具名
+	skillToolName = "Skill"
+)
+
+// SkillUsageStat 某个 skill 在统计周期内的调用次数与花费。Tokens 与花费取自携带该 skill 调用结果的请求，
+// 即 skill 内容被加载进上下文的那一次请求
+type SkillUsageStat struct {
+	Skill             string    `json:"skill"`
+	Installed         bool      `json:"installed"`
+	Invocations       int       `json:"invocations"`
+	InputTokens       int       `json:"input_tokens"`
+	OutputTokens      int       `json:"output_tokens"`
+	CacheCreateTokens int       `json:"cache_create_tokens"`
+	CacheReadTokens   int       `json:"cache_read_tokens"`
+	TotalCost         float64   `json:"total_cost"`
+	LastUsed          time.Time `json:"last_used,omitempty"`
+}
+
+func ensureSkillUsageTable() error {
+	db, err := xdb.DB("default")
+	if err != nil {
+		return err
+	}
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS skill_usage (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			log_id INTEGER,
+			request_id TEXT,
+			platform TEXT,
+			skill TEXT,
+			provider TEXT,
+			model TEXT,
+			input_tokens INTEGER DEFAULT 0,
+			output_tokens INTEGER DEFAULT 0,
+			cache_create_tokens INTEGER DEFAULT 0,
+			cache_read_tokens INTEGER DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_skill_usage_created_at ON skill_usage (created_at)`,
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// detectSkillInvocations 找出请求中最新一轮 assistant 消息里的 Skill 工具调用。
+// 每次请求都会携带完整历史，只看倒数第二条（紧跟在最后一条 user 消息前的 assistant 消息），
+// 这样每次调用只在携带其 tool_result 的那个请求里计一次
+func detectSkillInvocations(kind string, body []byte) []string {
+	if normalizePresetKind(kind) != "claude" {
+		return nil
+	}
+	messages := gjson.GetBytes(body, "messages").Array()
+	if len(messages) < 2 {
+		return nil
+	}
+	last, previous := messages[len(messages)-1], messages[len(messages)-2]
+	if last.Get("role").String() != "user" || previous.Get("role").String() != "assistant" {
+		return nil
+	}
+	var skills []string
+	for _, block := range previous.Get("content").Array() {
+		if block.Get("type").String() != "tool_use" || block.Get("name").String() != skillToolName {
+			continue
+		}
+		name := strings.TrimSpace(block.Get("input.skill").String())
+		if name == "" {
+			name = strings.TrimSpace(block.Get("input.command").String())
+		}
+		if name = strings.TrimPrefix(name, "/"); name != "" {
+			skills = append(skills, name)
+		}
+	}
+	return skills
+}
+
+// recordSkillUsage 为请求中的每次 skill 调用写入一条记录
+func recordSkillUsage(logID int64, entry *ReqeustLog, skills []string) {
+	for _, skill := range skills {
+		if _, err := xdb.New(skillUsageTable).Insert(xdb.Record{
+			"log_id":              logID,
+			"request_id":          entry.RequestID,
+			"platform":            entry.Platform,
+			"skill":               skill,
+			"provider":            entry.Provider,
+			"model":               entry.Model,
+			"input_tokens":        entry.InputTokens,
+			"output_tokens":       entry.OutputTokens,
+			"cache_create_tokens": entry.CacheCreateTokens,
+			"cache_read_tokens":   entry.CacheReadTokens,
+		}); err != nil {
+			fmt.Printf("写入 skill_usage 失败: %v\n", err)
+		}
+	}
+}
+
+// GetSkillUsageStats 统计最近 days 天各 skill 的调用次数与花费，按调用次数降序；
+// 已安装但从未调用的 skill 以 0 次列在最后，便于清理
+func (ls *LogService) GetSkillUsageStats(days int) ([]SkillUsageStat, error) {
+	if days <= 0 {
+		days = 30
+	}
+	since := startOfDay(time.Now()).AddDate(0, 0, -(days - 1))
+	records, err := xdb.New(skillUsageTable).Selects(
+		xdb.WhereGte("created_at", since.UTC().Format(timeLayout)),
+		xdb.Field(
+			"skill",
+			"provider",
+			"model",
+			"input_tokens",
+			"output_tokens",
+			"cache_create_tokens",
+			"cache_read_tokens",
+			"created_at",
+		),
+	)
+	if err != nil && !errors.Is(err, xdb.ErrNotFound) && !isNoSuchTableErr(err) {
+		return nil, err
+	}
+
+	installed := installedSkillNames(filepath.Join(userHomeDir(), ".claude", "skills"))
+	statMap := make(map[string]*SkillUsageStat)
+	for _, record := range records {
+		name := record.GetString("skill")
+		key := strings.ToLower(name)
+		if dir, ok := installed[key]; ok {
+			// 调用时使用 front matter 中的名称，统一按目录名汇总
+			key = strings.ToLower(dir)
+			name = dir
+		}
+		stat, ok := statMap[key]
+		if !ok {
+			stat = &SkillUsageStat{Skill: name}
+			statMap[key] = stat
+		}
+		stat.Invocations++
+		stat.InputTokens += record.GetInt("input_tokens")
+		stat.OutputTokens += record.GetInt("output_tokens")
+		stat.CacheCreateTokens += record.GetInt("cache_create_tokens")
+		stat.CacheReadTokens += record.GetInt("cache_read_tokens")
+		stat.TotalCost += ls.recordCost(record)
+		if createdAt, ok := parseCreatedAt(record); ok && createdAt.After(stat.LastUsed) {
+			stat.LastUsed = createdAt
+		}
+	}
+	for _, dir := range installed {
+		key := strings.ToLower(dir)
+		if stat, ok := statMap[key]; ok {
+			stat.Installed = true
+			continue
+		}
+		statMap[key] = &SkillUsageStat{Skill: dir, Installed: true}
+	}
+
+	stats := make([]SkillUsageStat, 0, len(statMap))
+	for _, stat := range statMap {
+		stats = append(stats, *stat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Invocations != stats[j].Invocations {
+			return stats[i].Invocations > stats[j].Invocations
+		}
+		return strings.ToLower(stats[i].Skill) < strings.ToLower(stats[j].Skill)
+	})
+	return stats, nil
+}
+
+// installedSkillNames 已安装 skill 的名称（front matter name 与目录名，小写）到目录名的映射
+func installedSkillNames(dir string) map[string]string {
+	names := make(map[string]string)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return names
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		names[strings.ToLower(entry.Name())] = entry.Name()
+		if meta, err := readSkillMetadata(filepath.Join(dir, entry.Name())); err == nil {
+			if name := strings.TrimSpace(meta.Name); name != "" {
+				names[strings.ToLower(name)] = entry.Name()
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,32 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetectSkillInvocations(t *testing.T) {
+	body := []byte(`{"model":"claude-sonnet-4","messages":[
+		{"role":"user","content":"fill the form"},
+		{"role":"assistant","content":[{"type":"tool_use","id":"t0","name":"Skill","input":{"skill":"xlsx"}}]},
+		{"role":"user","content":[{"type":"tool_result","tool_use_id":"t0","content":"ok"}]},
+		{"role":"assistant","content":[
+			{"type":"text","text":"loading"},
+			{"type":"tool_use","id":"t1","name":"Skill","input":{"skill":"pdf"}},
+			{"type":"tool_use","id":"t2","name":"Skill","input":{"command":"/docx"}},
+			{"type":"tool_use","id":"t3","name":"Read","input":{"file_path":"a"}}
+		]},
+		{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"ok"}]}
+	]}`)
+	// 历史中的 xlsx 已在之前的请求中计过，不重复统计
+	if got := detectSkillInvocations("claude", body); !reflect.DeepEqual(got, []string{"pdf", "docx"}) {
+		t.Fatalf("skills = %v", got)
+	}
+	if got := detectSkillInvocations("codex", body); got != nil {
+		t.Fatalf("codex requests should be ignored, got %v", got)
+	}
+	noToolResult := []byte(`{"messages":[{"role":"user","content":"hi"}]}`)
+	if got := detectSkillInvocations("claude", noToolResult); got != nil {
+		t.Fatalf("skills = %v", got)
+	}
+}
@@ -0,0 +1,550 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daodao97/xgo/xdb"
+)
+
+const (
+	speedTestResultTable = "speed_test_result"
+	routingScheduleFile  = "routing-schedule.json"
+	speedTestInterval    = 30 * time.Minute
+	speedTestTimeout     = 10 * time.Second
+	defaultSuggestDays   = 7
+	speedTestMinSamples  = 1
+	speedTestFailLatency = 1e9
+)
+
+// routingBlocks 一天按 6 小时划分的时间段，建议与路由计划都基于这些时间段
+var routingBlocks = []RoutingBlock{
+	{Name: "night", StartHour: 0, EndHour: 6},
+	{Name: "morning", StartHour: 6, EndHour: 12},
+	{Name: "afternoon", StartHour: 12, EndHour: 18},
+	{Name: "evening", StartHour: 18, EndHour: 24},
+}
+
+// routingScheduleCache relay 每次请求都要读取路由计划，缓存解析结果；
+// 保存时直接更新，文件被外部修改（mtime 或大小变化）或切换 profile（路径变化）时重新读取
+var routingScheduleCache = struct {
+	sync.RWMutex
+	path      string
+	modTime   time.Time
+	size      int64
+	schedules map[string]RoutingSchedule
+}{}
+
+type RoutingBlock struct {
+	Name      string   `json:"name"`
+	StartHour int      `json:"start_hour"`
+	EndHour   int      `json:"end_hour"`
+	Order     []string `json:"order"`
+}
+
+type RoutingSchedule struct {
+	Enabled   bool           `json:"enabled"`
+	Blocks    []RoutingBlock `json:"blocks"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+type SpeedTestResult struct {
+	Platform  string  `json:"platform"`
+	Provider  string  `json:"provider"`
+	LatencyMs float64 `json:"latency_ms"`
+	HttpCode  int     `json:"http_code"`
+	Success   bool    `json:"success"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type ProviderBlockScore struct {
+	Provider     string  `json:"provider"`
+	Samples      int     `json:"samples"`
+	SuccessRate  float64 `json:"success_rate"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+}
+
+// AvailabilityPoint 某一天的测速汇总，用于查看 provider（尤其是观察模式）的可用性历史
+type AvailabilityPoint struct {
+	Day          string  `json:"day"`
+	Samples      int     `json:"samples"`
+	SuccessRate  float64 `json:"success_rate"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+}
+
+type RoutingBlockSuggestion struct {
+	RoutingBlock
+	UsageRequests int64                `json:"usage_requests"`
+	UsageShare    float64              `json:"usage_share"`
+	Scores        []ProviderBlockScore `json:"scores"`
+}
+
+type RoutingSuggestion struct {
+	Platform string                   `json:"platform"`
+	Days     int                      `json:"days"`
+	Blocks   []RoutingBlockSuggestion `json:"blocks"`
+}
+
+type SpeedTestService struct {
+	providerService *ProviderService
+	httpClient      *http.Client
+	mu              sync.Mutex
+	stopCh          chan struct{}
+}
+
+func NewSpeedTestService(providerService *ProviderService) *SpeedTestService {
+	return &SpeedTestService{
+		providerService: providerService,
+		httpClient:      &http.Client{Timeout: speedTestTimeout},
+	}
+}
+
+// Start 启动定时测速，测速结果用于生成分时段路由建议
+func (sts *SpeedTestService) Start() error {
+	sts.mu.Lock()
+	defer sts.mu.Unlock()
+	if sts.stopCh != nil {
+		return nil
+	}
+	stopCh := make(chan struct{})
+	sts.stopCh = stopCh
+	go func() {
+		ticker := time.NewTicker(speedTestInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				for _, kind := range []string{"claude", "codex"} {
+					if _, err := sts.RunSpeedTest(kind); err != nil {
+						fmt.Printf("[WARN] 定时测速失败 [%s]: %v\n", kind, err)
+					}
+				}
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (sts *SpeedTestService) Stop() error {
+	sts.mu.Lock()
+	defer sts.mu.Unlock()
+	if sts.stopCh != nil {
+		close(sts.stopCh)
+		sts.stopCh = nil
+	}
+	return nil
+}
+
+// RunSpeedTest 对指定平台所有启用或处于观察模式的 provider 测速并记录结果
+func (sts *SpeedTestService) RunSpeedTest(platform string) ([]SpeedTestResult, error) {
+	providers, err := sts.providerService.LoadProviders(platform)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]SpeedTestResult, 0, len(providers))
+	for _, provider := range providers {
+		if (!provider.Enabled && !provider.Observer) || provider.APIURL == "" {
+			continue
+		}
+		result := sts.measure(platform, provider)
+		results = append(results, result)
+		// 演示模式下写入演示表，与历史、建议读取的表保持一致
+		if _, err := xdb.New(speedTestTable()).Insert(xdb.Record{
+			"platform":   result.Platform,
+			"provider":   result.Provider,
+			"latency_ms": result.LatencyMs,
+			"http_code":  result.HttpCode,
+			"success":    boolToInt(result.Success),
+		}); err != nil {
+			return results, fmt.Errorf("写入测速结果失败: %w", err)
+		}
+	}
+	return results, nil
+}
+
+func (sts *SpeedTestService) measure(platform string, provider Provider) SpeedTestResult {
+	result := SpeedTestResult{Platform: platform, Provider: provider.Name}
+	req, err := http.NewRequest(http.MethodGet, provider.APIURL, nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	start := time.Now()
+	resp, err := sts.httpClient.Do(req)
+	result.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	resp.Body.Close()
+	// 只衡量网络可达性与往返耗时，任何 HTTP 响应都视为可达
+	result.HttpCode = resp.StatusCode
+	result.Success = true
+	return result
+}
+
+// AvailabilityHistory 按天汇总某个 provider 最近 N 天的测速结果
+func (sts *SpeedTestService) AvailabilityHistory(platform string, provider string, days int) ([]AvailabilityPoint, error) {
+	if days <= 0 {
+		days = defaultSuggestDays
+	}
+	start := startOfDay(time.Now()).AddDate(0, 0, -(days - 1))
+	records, err := xdb.New(speedTestTable()).Selects(
+		xdb.WhereEq("platform", platform),
+		xdb.WhereEq("provider", provider),
+		xdb.WhereGte("created_at", start.UTC().Format(timeLayout)),
+		xdb.Field("latency_ms", "success", "created_at"),
+	)
+	if err != nil && !errors.Is(err, xdb.ErrNotFound) && !isNoSuchTableErr(err) {
+		return nil, err
+	}
+	type dayAgg struct {
+		samples   int
+		successes int
+		latency   float64
+	}
+	aggs := make(map[string]*dayAgg, days)
+	for _, record := range records {
+		createdAt, ok := parseCreatedAt(record)
+		if !ok {
+			continue
+		}
+		key := createdAt.Format("2006-01-02")
+		agg := aggs[key]
+		if agg == nil {
+			agg = &dayAgg{}
+			aggs[key] = agg
+		}
+		agg.samples++
+		if record.GetBool("success") {
+			agg.successes++
+			agg.latency += record.GetFloat64("latency_ms")
+		}
+	}
+	points := make([]AvailabilityPoint, 0, days)
+	for i := 0; i < days; i++ {
+		key := start.AddDate(0, 0, i).Format("2006-01-02")
+		point := AvailabilityPoint{Day: key}
+		if agg := aggs[key]; agg != nil && agg.samples > 0 {
+			point.Samples = agg.samples
+			point.SuccessRate = float64(agg.successes) / float64(agg.samples)
+			if agg.successes > 0 {
+				point.AvgLatencyMs = agg.latency / float64(agg.successes)
+			}
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
+
+// SuggestRouting 结合最近 N 天的测速结果与实际使用时段，给出每个时间段的推荐优先级
+func (sts *SpeedTestService) SuggestRouting(platform string, days int) (RoutingSuggestion, error) {
+	if days <= 0 {
+		days = defaultSuggestDays
+	}
+	suggestion := RoutingSuggestion{Platform: platform, Days: days}
+	since := time.Now().Add(-time.Duration(days) * 24 * time.Hour).Format(timeLayout)
+
+	samples, err := xdb.New(speedTestTable()).Selects(
+		xdb.WhereEq("platform", platform),
+		xdb.WhereGte("created_at", since),
+		xdb.Field("provider", "latency_ms", "success", "created_at"),
+	)
+	if err != nil && !errors.Is(err, xdb.ErrNotFound) && !isNoSuchTableErr(err) {
+		return suggestion, err
+	}
+	usage, err := xdb.New(activeRequestLogTable()).Selects(
+		xdb.WhereEq("platform", platform),
+		xdb.WhereGte("created_at", since),
+		xdb.Field("created_at"),
+	)
+	if err != nil && !errors.Is(err, xdb.ErrNotFound) && !isNoSuchTableErr(err) {
+		return suggestion, err
+	}
+
+	usageByBlock := make([]int64, len(routingBlocks))
+	var usageTotal int64
+	for _, record := range usage {
+		createdAt, hasTime := parseCreatedAt(record)
+		if !hasTime {
+			continue
+		}
+		usageByBlock[routingBlockIndex(createdAt.Hour())]++
+		usageTotal++
+	}
+
+	type accumulator struct {
+		samples   int
+		successes int
+		latency   float64
+	}
+	blockStats := make([]map[string]*accumulator, len(routingBlocks))
+	for i := range blockStats {
+		blockStats[i] = map[string]*accumulator{}
+	}
+	for _, record := range samples {
+		createdAt, hasTime := parseCreatedAt(record)
+		if !hasTime {
+			continue
+		}
+		name := record.GetString("provider")
+		stats := blockStats[routingBlockIndex(createdAt.Hour())]
+		acc := stats[name]
+		if acc == nil {
+			acc = &accumulator{}
+			stats[name] = acc
+		}
+		acc.samples++
+		if record.GetBool("success") {
+			acc.successes++
+			acc.latency += record.GetFloat64("latency_ms")
+		}
+	}
+
+	for i, block := range routingBlocks {
+		item := RoutingBlockSuggestion{RoutingBlock: block, UsageRequests: usageByBlock[i]}
+		if usageTotal > 0 {
+			item.UsageShare = float64(usageByBlock[i]) / float64(usageTotal)
+		}
+		for name, acc := range blockStats[i] {
+			if acc.samples < speedTestMinSamples {
+				continue
+			}
+			score := ProviderBlockScore{
+				Provider:     name,
+				Samples:      acc.samples,
+				SuccessRate:  float64(acc.successes) / float64(acc.samples),
+				AvgLatencyMs: speedTestFailLatency,
+			}
+			if acc.successes > 0 {
+				score.AvgLatencyMs = acc.latency / float64(acc.successes)
+			}
+			item.Scores = append(item.Scores, score)
+		}
+		sortBlockScores(item.Scores)
+		item.Order = make([]string, 0, len(item.Scores))
+		for _, score := range item.Scores {
+			item.Order = append(item.Order, score.Provider)
+		}
+		suggestion.Blocks = append(suggestion.Blocks, item)
+	}
+	return suggestion, nil
+}
+
+// ApplySuggestion 将建议保存为分时段路由计划并启用
+func (sts *SpeedTestService) ApplySuggestion(platform string, days int) (RoutingSchedule, error) {
+	suggestion, err := sts.SuggestRouting(platform, days)
+	if err != nil {
+		return RoutingSchedule{}, err
+	}
+	schedule := RoutingSchedule{Enabled: true}
+	for _, block := range suggestion.Blocks {
+		schedule.Blocks = append(schedule.Blocks, block.RoutingBlock)
+	}
+	if err := sts.SaveRoutingSchedule(platform, schedule); err != nil {
+		return RoutingSchedule{}, err
+	}
+	return schedule, nil
+}
+
+func (sts *SpeedTestService) GetRoutingSchedule(platform string) (RoutingSchedule, error) {
+	schedules, err := loadRoutingSchedules()
+	if err != nil {
+		return RoutingSchedule{}, err
+	}
+	return schedules[strings.ToLower(platform)], nil
+}
+
+func (sts *SpeedTestService) SaveRoutingSchedule(platform string, schedule RoutingSchedule) error {
+	sts.mu.Lock()
+	defer sts.mu.Unlock()
+	schedules, err := loadRoutingSchedules()
+	if err != nil {
+		return err
+	}
+	schedule.UpdatedAt = time.Now()
+	schedules[strings.ToLower(platform)] = schedule
+	path, err := routingSchedulePath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(schedules, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	if info, err := os.Stat(path); err == nil {
+		storeRoutingSchedules(path, info, schedules)
+	}
+	return nil
+}
+
+func routingSchedulePath() (string, error) {
+	dir, err := profileDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, routingScheduleFile), nil
+}
+
+func loadRoutingSchedules() (map[string]RoutingSchedule, error) {
+	schedules := map[string]RoutingSchedule{}
+	path, err := routingSchedulePath()
+	if err != nil {
+		return schedules, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return schedules, nil
+		}
+		return schedules, err
+	}
+	if len(data) == 0 {
+		return schedules, nil
+	}
+	if err := json.Unmarshal(data, &schedules); err != nil {
+		return schedules, err
+	}
+	return schedules, nil
+}
+
+// cachedRoutingSchedules 返回缓存的路由计划，文件未变化时不重新读取；返回的 map 只读
+func cachedRoutingSchedules() (map[string]RoutingSchedule, error) {
+	path, err := routingSchedulePath()
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]RoutingSchedule{}, nil
+		}
+		return nil, err
+	}
+	routingScheduleCache.RLock()
+	if routingScheduleCache.schedules != nil && routingScheduleCache.path == path &&
+		routingScheduleCache.modTime.Equal(info.ModTime()) && routingScheduleCache.size == info.Size() {
+		schedules := routingScheduleCache.schedules
+		routingScheduleCache.RUnlock()
+		return schedules, nil
+	}
+	routingScheduleCache.RUnlock()
+	schedules, err := loadRoutingSchedules()
+	if err != nil {
+		return nil, err
+	}
+	storeRoutingSchedules(path, info, schedules)
+	return schedules, nil
+}
+
+func storeRoutingSchedules(path string, info os.FileInfo, schedules map[string]RoutingSchedule) {
+	routingScheduleCache.Lock()
+	defer routingScheduleCache.Unlock()
+	routingScheduleCache.path = path
+	routingScheduleCache.modTime = info.ModTime()
+	routingScheduleCache.size = info.Size()
+	routingScheduleCache.schedules = schedules
+}
+
+// applyRoutingSchedule 按当前时间段的计划调整 provider 顺序
+// 计划中未出现的 provider 保持原有相对顺序并排在后面
+func applyRoutingSchedule(kind string, providers []Provider, now time.Time) []Provider {
+	schedules, err := cachedRoutingSchedules()
+	if err != nil {
+		return providers
+	}
+	schedule, ok := schedules[strings.ToLower(kind)]
+	if !ok || !schedule.Enabled {
+		return providers
+	}
+	return orderProvidersBySchedule(providers, schedule, now.Hour())
+}
+
+func orderProvidersBySchedule(providers []Provider, schedule RoutingSchedule, hour int) []Provider {
+	var order []string
+	for _, block := range schedule.Blocks {
+		if hour >= block.StartHour && hour < block.EndHour {
+			order = block.Order
+			break
+		}
+	}
+	if len(order) == 0 {
+		return providers
+	}
+	rank := make(map[string]int, len(order))
+	for i, name := range order {
+		rank[strings.ToLower(name)] = i
+	}
+	ordered := make([]Provider, len(providers))
+	copy(ordered, providers)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		ri, iok := rank[strings.ToLower(ordered[i].Name)]
+		rj, jok := rank[strings.ToLower(ordered[j].Name)]
+		if iok && jok {
+			return ri < rj
+		}
+		return iok && !jok
+	})
+	return ordered
+}
+
+func routingBlockIndex(hour int) int {
+	for i, block := range routingBlocks {
+		if hour >= block.StartHour && hour < block.EndHour {
+			return i
+		}
+	}
+	return 0
+}
+
+func sortBlockScores(scores []ProviderBlockScore) {
+	sort.SliceStable(scores, func(i, j int) bool {
+		if scores[i].SuccessRate != scores[j].SuccessRate {
+			return scores[i].SuccessRate > scores[j].SuccessRate
+		}
+		if scores[i].AvgLatencyMs != scores[j].AvgLatencyMs {
+			return scores[i].AvgLatencyMs < scores[j].AvgLatencyMs
+		}
+		return scores[i].Provider < scores[j].Provider
+	})
+}
+
+func ensureSpeedTestTable() error {
+	db, err := xdb.DB("default")
+	if err != nil {
+		return err
+	}
+	return ensureSpeedTestTableWithDB(db, speedTestResultTable)
+}
+
+func ensureSpeedTestTableWithDB(db *sql.DB, table string) error {
+	createTableSQL := `CREATE TABLE IF NOT EXISTS ` + table + ` (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		platform TEXT,
+		provider TEXT,
+		latency_ms REAL DEFAULT 0,
+		http_code INTEGER DEFAULT 0,
+		success INTEGER DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`
+	_, err := db.Exec(createTableSQL)
+	return err
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/daodao97/xgo/xdb"
+)
+
+func TestOrderProvidersBySchedule(t *testing.T) {
+	providers := []Provider{
+		{ID: 1, Name: "A"},
+		{ID: 2, Name: "B"},
+		{ID: 3, Name: "C"},
+		{ID: 4, Name: "D"},
+	}
+	schedule := RoutingSchedule{
+		Enabled: true,
+		Blocks: []RoutingBlock{
+			{Name: "morning", StartHour: 6, EndHour: 12, Order: []string{"c", "A"}},
+			{Name: "evening", StartHour: 18, EndHour: 24, Order: []string{"D"}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		hour     int
+		expected []string
+	}{
+		{name: "上午按计划排序，未列出的保持原顺序", hour: 9, expected: []string{"C", "A", "B", "D"}},
+		{name: "晚上只提升 D", hour: 20, expected: []string{"D", "A", "B", "C"}},
+		{name: "无匹配时间段保持原顺序", hour: 3, expected: []string{"A", "B", "C", "D"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ordered := orderProvidersBySchedule(providers, schedule, tt.hour)
+			for i, name := range tt.expected {
+				if ordered[i].Name != name {
+					t.Fatalf("位置 %d：实际 %q，期望 %q", i, ordered[i].Name, name)
+				}
+			}
+		})
+	}
+}
+
+func TestSortBlockScores(t *testing.T) {
+	scores := []ProviderBlockScore{
+		{Provider: "slow", SuccessRate: 1, AvgLatencyMs: 800},
+		{Provider: "flaky", SuccessRate: 0.5, AvgLatencyMs: 100},
+		{Provider: "fast", SuccessRate: 1, AvgLatencyMs: 200},
+	}
+	sortBlockScores(scores)
+	expected := []string{"fast", "slow", "flaky"}
+	for i, name := range expected {
+		if scores[i].Provider != name {
+			t.Fatalf("位置 %d：实际 %q，期望 %q", i, scores[i].Provider, name)
+		}
+	}
+}
+
+func TestRoutingScheduleCacheReloadsOnChange(t *testing.T) {
+	path, err := routingSchedulePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Remove(path) })
+	providers := []Provider{{ID: 1, Name: "A"}, {ID: 2, Name: "B"}}
+	at := time.Date(2024, 5, 1, 8, 0, 0, 0, time.Local)
+	firstName := func() string {
+		return applyRoutingSchedule("claude", providers, at)[0].Name
+	}
+
+	sts := &SpeedTestService{}
+	if err := sts.SaveRoutingSchedule("claude", RoutingSchedule{Enabled: true, Blocks: []RoutingBlock{
+		{Name: "morning", StartHour: 6, EndHour: 12, Order: []string{"B", "A"}},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := firstName(); got != "B" {
+		t.Fatalf("保存后应立即生效，首位为 %s", got)
+	}
+
+	// 内容改变但 mtime 与大小不变时沿用缓存，说明请求路径上不会重复读取文件
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	swapped := bytes.Replace(data, []byte(`"B",`), []byte(`"X",`), 1)
+	swapped = bytes.Replace(swapped, []byte(`"A"`), []byte(`"B"`), 1)
+	swapped = bytes.Replace(swapped, []byte(`"X",`), []byte(`"A",`), 1)
+	if len(swapped) != len(data) || bytes.Equal(swapped, data) {
+		t.Fatalf("改写后的计划：%s", swapped)
+	}
+	if err := os.WriteFile(path, swapped, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	if got := firstName(); got != "B" {
+		t.Fatalf("文件未变化时应使用缓存，首位为 %s", got)
+	}
+
+	// 外部修改文件（mtime 变化）后重新读取
+	later := info.ModTime().Add(time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if got := firstName(); got != "A" {
+		t.Fatalf("mtime 变化后应重新读取，首位为 %s", got)
+	}
+}
+
+func TestSpeedTestUsesDemoTableInDemoMode(t *testing.T) {
+	useTestDB(t)
+	db, err := xdb.DB("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ensureSpeedTestTableWithDB(db, demoSpeedTestTable); err != nil {
+		t.Fatal(err)
+	}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(upstream.Close)
+	ps := NewProviderService()
+	if err := ps.SaveProviders("claude", []Provider{{ID: 1, Name: "relay", APIURL: upstream.URL, APIKey: "k", Enabled: true}}); err != nil {
+		t.Fatal(err)
+	}
+	demoMode.Store(true)
+	t.Cleanup(func() { demoMode.Store(false) })
+
+	sts := NewSpeedTestService(ps)
+	if _, err := sts.RunSpeedTest("claude"); err != nil {
+		t.Fatal(err)
+	}
+	for table, want := range map[string]int64{speedTestResultTable: 0, demoSpeedTestTable: 1} {
+		count, err := xdb.New(table).Count()
+		if err != nil || count != want {
+			t.Fatalf("%s 应有 %d 条测速结果，实际 %d：%v", table, want, count, err)
+		}
+	}
+	suggestion, err := sts.SuggestRouting("claude", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := 0
+	for _, block := range suggestion.Blocks {
+		for _, score := range block.Scores {
+			samples += score.Samples
+		}
+	}
+	if samples != 1 {
+		t.Fatalf("演示模式下的建议应基于演示表的样本：%+v", suggestion)
+	}
+}
@@ -0,0 +1,381 @@
+package services
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+const (
+	statusPagePollInterval = 5 * time.Minute
+	statusPageTimeout      = 15 * time.Second
+	// RSS/Atom 中最近多久内未标记 resolved 的条目视为进行中的事故
+	statusFeedWindow = 24 * time.Hour
+
+	anthropicStatusPage = "https://status.anthropic.com/api/v2/summary.json"
+	openAIStatusPage    = "https://status.openai.com/api/v2/summary.json"
+)
+
+// StatusIncident 状态页上声明的进行中事故
+type StatusIncident struct {
+	Platform   string    `json:"platform"`
+	Provider   string    `json:"provider"`
+	Source     string    `json:"source"`
+	Title      string    `json:"title"`
+	Impact     string    `json:"impact,omitempty"`
+	Link       string    `json:"link,omitempty"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// incidentProviders 由 StatusPageService 维护、relay 读取：状态页声明事故的 provider
+var incidentProviders = struct {
+	sync.RWMutex
+	names map[string]map[string]struct{}
+}{names: make(map[string]map[string]struct{})}
+
+type StatusPageService struct {
+	providerService *ProviderService
+	httpClient      *http.Client
+	mu              sync.Mutex
+	incidents       map[string]StatusIncident
+	incidentHandler func(StatusIncident)
+	stopCh          chan struct{}
+}
+
+func NewStatusPageService(providerService *ProviderService) *StatusPageService {
+	return &StatusPageService{
+		providerService: providerService,
+		httpClient:      &http.Client{Timeout: statusPageTimeout},
+		incidents:       make(map[string]StatusIncident),
+	}
+}
+
+// SetIncidentHandler 设置新发现事故时的回调（由 main 转为前端事件/系统通知）
+func (sps *StatusPageService) SetIncidentHandler(handler func(StatusIncident)) {
+	sps.mu.Lock()
+	defer sps.mu.Unlock()
+	sps.incidentHandler = handler
+}
+
+// Start 启动定时轮询状态页
+func (sps *StatusPageService) Start() error {
+	sps.mu.Lock()
+	defer sps.mu.Unlock()
+	if sps.stopCh != nil {
+		return nil
+	}
+	stopCh := make(chan struct{})
+	sps.stopCh = stopCh
+	go func() {
+		sps.refreshAll()
+		ticker := time.NewTicker(statusPagePollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				sps.refreshAll()
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (sps *StatusPageService) Stop() error {
+	sps.mu.Lock()
+	defer sps.mu.Unlock()
+	if sps.stopCh != nil {
+		close(sps.stopCh)
+		sps.stopCh = nil
+	}
+	return nil
+}
+
+// ListIncidents 返回当前进行中的事故
+func (sps *StatusPageService) ListIncidents(platform string) []StatusIncident {
+	platform = normalizePresetKind(platform)
+	sps.mu.Lock()
+	defer sps.mu.Unlock()
+	result := make([]StatusIncident, 0, len(sps.incidents))
+	for _, incident := range sps.incidents {
+		if platform != "" && incident.Platform != platform {
+			continue
+		}
+		result = append(result, incident)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Platform != result[j].Platform {
+			return result[i].Platform < result[j].Platform
+		}
+		return result[i].Provider < result[j].Provider
+	})
+	return result
+}
+
+// RefreshStatus 立即轮询所有状态页
+func (sps *StatusPageService) RefreshStatus() []StatusIncident {
+	sps.refreshAll()
+	return sps.ListIncidents("")
+}
+
+func (sps *StatusPageService) refreshAll() {
+	now := time.Now()
+	// 同一状态页只请求一次
+	pages := make(map[string]*StatusIncident)
+	failed := make(map[string]bool)
+	current := make(map[string]StatusIncident)
+	for _, platform := range []string{"claude", "codex"} {
+		providers, err := sps.providerService.LoadProviders(platform)
+		if err != nil {
+			continue
+		}
+		for _, provider := range providers {
+			if !provider.Enabled {
+				continue
+			}
+			source := statusPageURL(provider)
+			if source == "" {
+				continue
+			}
+			incident, checked := pages[source]
+			if !checked && !failed[source] {
+				incident, err = sps.fetchIncident(source, now)
+				if err != nil {
+					fmt.Printf("[WARN] 读取状态页 %s 失败: %v\n", source, err)
+					failed[source] = true
+				} else {
+					pages[source] = incident
+				}
+			}
+			key := blacklistKey(platform, provider.Name)
+			if failed[source] {
+				// 状态页暂时不可用时沿用上一次的结论
+				sps.mu.Lock()
+				if previous, ok := sps.incidents[key]; ok {
+					current[key] = previous
+				}
+				sps.mu.Unlock()
+				continue
+			}
+			if incident == nil {
+				continue
+			}
+			item := *incident
+			item.Platform = platform
+			item.Provider = provider.Name
+			current[key] = item
+		}
+	}
+
+	names := make(map[string]map[string]struct{})
+	started := make([]StatusIncident, 0)
+	sps.mu.Lock()
+	for key, item := range current {
+		if previous, ok := sps.incidents[key]; ok {
+			item.DetectedAt = previous.DetectedAt
+			current[key] = item
+		} else {
+			started = append(started, item)
+		}
+		if names[item.Platform] == nil {
+			names[item.Platform] = make(map[string]struct{})
+		}
+		names[item.Platform][strings.ToLower(item.Provider)] = struct{}{}
+	}
+	sps.incidents = current
+	handler := sps.incidentHandler
+	sps.mu.Unlock()
+
+	incidentProviders.Lock()
+	incidentProviders.names = names
+	incidentProviders.Unlock()
+
+	for _, item := range started {
+		recordProviderEvent(ProviderEvent{
+			Platform:  item.Platform,
+			EventType: ProviderEventIncident,
+			Provider:  item.Provider,
+			Reason:    fmt.Sprintf("状态页声明事故：%s，已降低优先级", item.Title),
+		})
+		if handler != nil {
+			handler(item)
+		}
+	}
+}
+
+// fetchIncident 读取状态页，返回进行中的事故；没有事故时返回 nil
+func (sps *StatusPageService) fetchIncident(source string, now time.Time) (*StatusIncident, error) {
+	req, err := http.NewRequest(http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json, application/rss+xml, application/atom+xml, */*")
+	resp, err := sps.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 2*1024*1024))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	trimmed := strings.TrimSpace(string(body))
+	if strings.HasPrefix(trimmed, "{") {
+		return parseStatuspageIncident(source, []byte(trimmed), now), nil
+	}
+	return parseFeedIncident(source, []byte(trimmed), now)
+}
+
+// parseStatuspageIncident 解析 Atlassian Statuspage 的 summary.json / incidents.json
+func parseStatuspageIncident(source string, body []byte, now time.Time) *StatusIncident {
+	var incident *StatusIncident
+	gjson.GetBytes(body, "incidents").ForEach(func(_, item gjson.Result) bool {
+		status := item.Get("status").String()
+		impact := item.Get("impact").String()
+		if status == "resolved" || status == "postmortem" || impact == "none" {
+			return true
+		}
+		incident = &StatusIncident{
+			Source:     source,
+			Title:      item.Get("name").String(),
+			Impact:     impact,
+			Link:       item.Get("shortlink").String(),
+			DetectedAt: now,
+		}
+		return false
+	})
+	if incident != nil {
+		return incident
+	}
+	switch indicator := gjson.GetBytes(body, "status.indicator").String(); indicator {
+	case "major", "critical":
+		return &StatusIncident{
+			Source:     source,
+			Title:      gjson.GetBytes(body, "status.description").String(),
+			Impact:     indicator,
+			DetectedAt: now,
+		}
+	}
+	return nil
+}
+
+type statusFeed struct {
+	Items   []statusFeedEntry `xml:"channel>item"`
+	Entries []statusFeedEntry `xml:"entry"`
+}
+
+type statusFeedEntry struct {
+	Title       string `xml:"title"`
+	Description string `xml:"description"`
+	Content     string `xml:"content"`
+	Summary     string `xml:"summary"`
+	PubDate     string `xml:"pubDate"`
+	Updated     string `xml:"updated"`
+	Link        struct {
+		Href string `xml:"href,attr"`
+		Text string `xml:",chardata"`
+	} `xml:"link"`
+}
+
+// parseFeedIncident 解析 RSS/Atom：最近的条目在窗口内且未标记 resolved 时视为进行中的事故
+func parseFeedIncident(source string, body []byte, now time.Time) (*StatusIncident, error) {
+	var feed statusFeed
+	if err := xml.Unmarshal(body, &feed); err != nil {
+		return nil, fmt.Errorf("无法解析状态页内容: %w", err)
+	}
+	entries := append(feed.Items, feed.Entries...)
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	latest := entries[0]
+	published, ok := parseFeedTime(latest.PubDate)
+	if !ok {
+		published, ok = parseFeedTime(latest.Updated)
+	}
+	if !ok || now.Sub(published) > statusFeedWindow {
+		return nil, nil
+	}
+	text := strings.ToLower(latest.Title + " " + latest.Description + " " + latest.Content + " " + latest.Summary)
+	if strings.Contains(text, "resolved") || strings.Contains(text, "completed") {
+		return nil, nil
+	}
+	link := latest.Link.Href
+	if link == "" {
+		link = strings.TrimSpace(latest.Link.Text)
+	}
+	return &StatusIncident{
+		Source:     source,
+		Title:      strings.TrimSpace(latest.Title),
+		Link:       link,
+		DetectedAt: now,
+	}, nil
+}
+
+func parseFeedTime(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range []string{time.RFC1123Z, time.RFC1123, time.RFC3339} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// statusPageURL 返回 provider 对应的状态页：优先使用自定义地址，官方端点与官方订阅使用内置状态页
+func statusPageURL(provider Provider) string {
+	if custom := strings.TrimSpace(provider.StatusPageURL); custom != "" {
+		return custom
+	}
+	switch provider.Subscription {
+	case "claude":
+		return anthropicStatusPage
+	case "chatgpt":
+		return openAIStatusPage
+	}
+	parsed, err := url.Parse(provider.APIURL)
+	if err != nil {
+		return ""
+	}
+	switch strings.ToLower(parsed.Hostname()) {
+	case "api.anthropic.com":
+		return anthropicStatusPage
+	case "api.openai.com", "chatgpt.com":
+		return openAIStatusPage
+	}
+	return ""
+}
+
+// deprioritizeIncidents 把状态页声明事故的 provider 移到队尾
+func deprioritizeIncidents(kind string, providers []Provider) []Provider {
+	incidentProviders.RLock()
+	affected := incidentProviders.names[normalizePresetKind(kind)]
+	incidentProviders.RUnlock()
+	if len(affected) == 0 {
+		return providers
+	}
+	ordered := make([]Provider, 0, len(providers))
+	tail := make([]Provider, 0)
+	for _, provider := range providers {
+		if _, ok := affected[strings.ToLower(provider.Name)]; ok {
+			tail = append(tail, provider)
+			continue
+		}
+		ordered = append(ordered, provider)
+	}
+	return append(ordered, tail...)
+}
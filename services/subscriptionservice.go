@@ -0,0 +1,366 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	subscriptionClaude  = "claude"
+	subscriptionChatGPT = "chatgpt"
+
+	subscriptionPollInterval = 5 * time.Minute
+	subscriptionQueryTimeout = 15 * time.Second
+	// 任一限额窗口用量达到该百分比时，relay 提前切换到 API Key 类 provider
+	subscriptionSwitchPercent = 90.0
+
+	claudeOAuthUsageURL  = "https://api.anthropic.com/api/oauth/usage"
+	claudeOAuthBetaFlag  = "oauth-2025-04-20"
+	chatGPTUsageURL      = "https://chatgpt.com/backend-api/wham/usage"
+	claudeCredentialFile = ".credentials.json"
+)
+
+// SubscriptionWindow 官方订阅的一个限额窗口（5 小时窗口、每周上限等）
+type SubscriptionWindow struct {
+	Name        string     `json:"name"`
+	UsedPercent float64    `json:"used_percent"`
+	ResetsAt    *time.Time `json:"resets_at,omitempty"`
+}
+
+type SubscriptionUsage struct {
+	Subscription   string               `json:"subscription"`
+	Plan           string               `json:"plan,omitempty"`
+	Windows        []SubscriptionWindow `json:"windows"`
+	MaxUsedPercent float64              `json:"max_used_percent"`
+	NearLimit      bool                 `json:"near_limit"`
+	Error          string               `json:"error,omitempty"`
+	CheckedAt      time.Time            `json:"checked_at"`
+}
+
+// subscriptionCredential 从 Claude Code / Codex 的本地登录信息中读取的 OAuth 凭据
+type subscriptionCredential struct {
+	AccessToken string
+	AccountID   string
+	Plan        string
+}
+
+// nearLimitSubscriptions 由 SubscriptionService 维护、relay 读取：即将触达限额的订阅
+var nearLimitSubscriptions = struct {
+	sync.RWMutex
+	names map[string]bool
+}{names: make(map[string]bool)}
+
+type SubscriptionService struct {
+	httpClient *http.Client
+	mu         sync.Mutex
+	usage      map[string]SubscriptionUsage
+	stopCh     chan struct{}
+}
+
+func NewSubscriptionService() *SubscriptionService {
+	return &SubscriptionService{
+		httpClient: &http.Client{Timeout: subscriptionQueryTimeout},
+		usage:      make(map[string]SubscriptionUsage),
+	}
+}
+
+// Start 定时查询官方订阅的用量窗口
+func (ss *SubscriptionService) Start() error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.stopCh != nil {
+		return nil
+	}
+	stopCh := make(chan struct{})
+	ss.stopCh = stopCh
+	go func() {
+		ss.refreshAll()
+		ticker := time.NewTicker(subscriptionPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ss.refreshAll()
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (ss *SubscriptionService) Stop() error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.stopCh != nil {
+		close(ss.stopCh)
+		ss.stopCh = nil
+	}
+	return nil
+}
+
+// GetUsage 返回最近一次查询的订阅用量
+func (ss *SubscriptionService) GetUsage() []SubscriptionUsage {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	result := make([]SubscriptionUsage, 0, len(ss.usage))
+	for _, name := range []string{subscriptionClaude, subscriptionChatGPT} {
+		if usage, ok := ss.usage[name]; ok {
+			result = append(result, usage)
+		}
+	}
+	return result
+}
+
+// RefreshUsage 立即查询指定订阅（claude / chatgpt）的用量
+func (ss *SubscriptionService) RefreshUsage(subscription string) (SubscriptionUsage, error) {
+	subscription = strings.ToLower(strings.TrimSpace(subscription))
+	usage := SubscriptionUsage{Subscription: subscription, Windows: []SubscriptionWindow{}, CheckedAt: time.Now()}
+	var err error
+	switch subscription {
+	case subscriptionClaude:
+		err = ss.fetchClaudeUsage(&usage)
+	case subscriptionChatGPT:
+		err = ss.fetchChatGPTUsage(&usage)
+	default:
+		return usage, fmt.Errorf("unknown subscription: %s", subscription)
+	}
+	if err != nil {
+		usage.Error = err.Error()
+	}
+	for _, window := range usage.Windows {
+		if window.UsedPercent > usage.MaxUsedPercent {
+			usage.MaxUsedPercent = window.UsedPercent
+		}
+	}
+	usage.NearLimit = usage.MaxUsedPercent >= subscriptionSwitchPercent
+
+	ss.mu.Lock()
+	ss.usage[subscription] = usage
+	ss.mu.Unlock()
+	nearLimitSubscriptions.Lock()
+	nearLimitSubscriptions.names[subscription] = usage.NearLimit
+	nearLimitSubscriptions.Unlock()
+	return usage, err
+}
+
+func (ss *SubscriptionService) refreshAll() {
+	for _, name := range []string{subscriptionClaude, subscriptionChatGPT} {
+		if _, err := loadSubscriptionCredential(name); err != nil {
+			continue
+		}
+		if _, err := ss.RefreshUsage(name); err != nil {
+			fmt.Printf("[WARN] 订阅用量查询失败 [%s]: %v\n", name, err)
+		}
+	}
+}
+
+func (ss *SubscriptionService) fetchClaudeUsage(usage *SubscriptionUsage) error {
+	cred, err := loadSubscriptionCredential(subscriptionClaude)
+	if err != nil {
+		return err
+	}
+	usage.Plan = cred.Plan
+	var payload map[string]struct {
+		Utilization *float64 `json:"utilization"`
+		ResetsAt    string   `json:"resets_at"`
+	}
+	headers := map[string]string{
+		"Authorization":  "Bearer " + cred.AccessToken,
+		"anthropic-beta": claudeOAuthBetaFlag,
+	}
+	if err := ss.getJSON(claudeOAuthUsageURL, headers, &payload); err != nil {
+		return err
+	}
+	for _, name := range []string{"five_hour", "seven_day", "seven_day_opus"} {
+		window, ok := payload[name]
+		if !ok || window.Utilization == nil {
+			continue
+		}
+		item := SubscriptionWindow{Name: name, UsedPercent: *window.Utilization}
+		if resetsAt, err := time.Parse(time.RFC3339, window.ResetsAt); err == nil {
+			item.ResetsAt = &resetsAt
+		}
+		usage.Windows = append(usage.Windows, item)
+	}
+	return nil
+}
+
+func (ss *SubscriptionService) fetchChatGPTUsage(usage *SubscriptionUsage) error {
+	cred, err := loadSubscriptionCredential(subscriptionChatGPT)
+	if err != nil {
+		return err
+	}
+	type rateWindow struct {
+		UsedPercent       float64 `json:"used_percent"`
+		ResetAfterSeconds int64   `json:"reset_after_seconds"`
+	}
+	var payload struct {
+		PlanType  string `json:"plan_type"`
+		RateLimit struct {
+			PrimaryWindow   *rateWindow `json:"primary_window"`
+			SecondaryWindow *rateWindow `json:"secondary_window"`
+		} `json:"rate_limit"`
+	}
+	headers := map[string]string{"Authorization": "Bearer " + cred.AccessToken}
+	if cred.AccountID != "" {
+		headers["ChatGPT-Account-Id"] = cred.AccountID
+	}
+	if err := ss.getJSON(chatGPTUsageURL, headers, &payload); err != nil {
+		return err
+	}
+	usage.Plan = payload.PlanType
+	windows := []struct {
+		name   string
+		window *rateWindow
+	}{
+		{"five_hour", payload.RateLimit.PrimaryWindow},
+		{"weekly", payload.RateLimit.SecondaryWindow},
+	}
+	for _, item := range windows {
+		if item.window == nil {
+			continue
+		}
+		window := SubscriptionWindow{Name: item.name, UsedPercent: item.window.UsedPercent}
+		if item.window.ResetAfterSeconds > 0 {
+			resetsAt := usage.CheckedAt.Add(time.Duration(item.window.ResetAfterSeconds) * time.Second)
+			window.ResetsAt = &resetsAt
+		}
+		usage.Windows = append(usage.Windows, window)
+	}
+	return nil
+}
+
+func (ss *SubscriptionService) getJSON(url string, headers map[string]string, out any) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := ss.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("用量接口返回 %s", resp.Status)
+	}
+	return json.Unmarshal(body, out)
+}
+
+// loadSubscriptionCredential 读取本地 OAuth 凭据；Codex 开启代理后原 auth.json 会被备份，因此同时尝试备份文件
+func loadSubscriptionCredential(subscription string) (subscriptionCredential, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return subscriptionCredential{}, err
+	}
+	switch subscription {
+	case subscriptionClaude:
+		data, err := os.ReadFile(filepath.Join(home, claudeSettingsDir, claudeCredentialFile))
+		if err != nil {
+			return subscriptionCredential{}, errors.New("未找到 Claude 订阅登录凭据")
+		}
+		var payload struct {
+			ClaudeAiOauth struct {
+				AccessToken      string `json:"accessToken"`
+				SubscriptionType string `json:"subscriptionType"`
+			} `json:"claudeAiOauth"`
+		}
+		if err := json.Unmarshal(data, &payload); err != nil || payload.ClaudeAiOauth.AccessToken == "" {
+			return subscriptionCredential{}, errors.New("Claude 订阅登录凭据无效")
+		}
+		return subscriptionCredential{
+			AccessToken: payload.ClaudeAiOauth.AccessToken,
+			Plan:        payload.ClaudeAiOauth.SubscriptionType,
+		}, nil
+	case subscriptionChatGPT:
+		dir := filepath.Join(home, codexSettingsDir)
+		for _, name := range []string{codexAuthFileName, codexBackupAuthName} {
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				continue
+			}
+			var payload struct {
+				Tokens struct {
+					AccessToken string `json:"access_token"`
+					AccountID   string `json:"account_id"`
+				} `json:"tokens"`
+			}
+			if err := json.Unmarshal(data, &payload); err != nil || payload.Tokens.AccessToken == "" {
+				continue
+			}
+			return subscriptionCredential{
+				AccessToken: payload.Tokens.AccessToken,
+				AccountID:   payload.Tokens.AccountID,
+			}, nil
+		}
+		return subscriptionCredential{}, errors.New("未找到 ChatGPT 订阅登录凭据")
+	default:
+		return subscriptionCredential{}, fmt.Errorf("unknown subscription: %s", subscription)
+	}
+}
+
+// applySubscriptionCredential 订阅类 provider 未填写 API Key 时使用本地 OAuth 凭据
+func applySubscriptionCredential(provider Provider, headers map[string]string) (Provider, error) {
+	subscription := strings.ToLower(strings.TrimSpace(provider.Subscription))
+	if subscription == "" {
+		return provider, nil
+	}
+	cred, err := loadSubscriptionCredential(subscription)
+	if err != nil {
+		if provider.APIKey != "" {
+			return provider, nil
+		}
+		return provider, err
+	}
+	if provider.APIKey == "" {
+		provider.APIKey = cred.AccessToken
+		provider.AuthStyle = authStyleBearer
+	}
+	switch subscription {
+	case subscriptionClaude:
+		beta := headers["Anthropic-Beta"]
+		if !strings.Contains(beta, claudeOAuthBetaFlag) {
+			if beta != "" {
+				beta += ","
+			}
+			headers["Anthropic-Beta"] = beta + claudeOAuthBetaFlag
+		}
+	case subscriptionChatGPT:
+		if cred.AccountID != "" {
+			headers["ChatGPT-Account-Id"] = cred.AccountID
+		}
+	}
+	return provider, nil
+}
+
+// deprioritizeNearLimitSubscriptions 订阅即将触达限额时，把对应的订阅类 provider 移到队尾
+func deprioritizeNearLimitSubscriptions(providers []Provider) []Provider {
+	nearLimitSubscriptions.RLock()
+	defer nearLimitSubscriptions.RUnlock()
+	ordered := make([]Provider, 0, len(providers))
+	tail := make([]Provider, 0)
+	for _, provider := range providers {
+		if nearLimitSubscriptions.names[strings.ToLower(provider.Subscription)] {
+			tail = append(tail, provider)
+			continue
+		}
+		ordered = append(ordered, provider)
+	}
+	return append(ordered, tail...)
+}
@@ -0,0 +1,615 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+var tomlBareKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// tomlEntry 文档中的一个表头或键值行。start/end 为整行范围（含换行），
+// valueStart/valueEnd 为键值的取值范围；数组表（[[...]]）内的键路径不唯一，不做定点修改
+type tomlEntry struct {
+	path       []string
+	header     bool
+	arrayTable bool
+	inArray    bool
+	start      int
+	end        int
+	valueStart int
+	valueEnd   int
+}
+
+// patchTOMLDocument 把 original 按 payload 定点修改，结果重新解析后必须与 payload 一致
+func patchTOMLDocument(original []byte, payload map[string]any) ([]byte, error) {
+	current := make(map[string]any)
+	if err := toml.Unmarshal(original, &current); err != nil {
+		return nil, err
+	}
+	data := append([]byte(nil), original...)
+	var err error
+	for _, change := range diffConfigPayload(current, payload, nil) {
+		if data, err = applyTOMLChange(data, payload, change); err != nil {
+			return nil, err
+		}
+	}
+	if data, err = dropStaleTOMLHeaders(data, payload); err != nil {
+		return nil, err
+	}
+
+	patched := make(map[string]any)
+	if err := toml.Unmarshal(data, &patched); err != nil {
+		return nil, err
+	}
+	encoded, err := toml.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	expected := make(map[string]any)
+	if err := toml.Unmarshal(encoded, &expected); err != nil {
+		return nil, err
+	}
+	if !reflect.DeepEqual(patched, expected) {
+		return nil, errConfigPatchMismatch
+	}
+	return data, nil
+}
+
+func applyTOMLChange(data []byte, payload map[string]any, change configChange) ([]byte, error) {
+	entries, err := scanTOMLEntries(data)
+	if err != nil {
+		return nil, err
+	}
+	// 上级是内联表或点分键写成的值时，整体改写该值
+	for n := 1; n < len(change.path); n++ {
+		entry := findTOMLAssignment(entries, change.path[:n])
+		if entry == nil {
+			continue
+		}
+		value, ok := configValueAt(payload, change.path[:n])
+		if !ok {
+			return spliceConfigText(data, entry.start, entry.end, ""), nil
+		}
+		return replaceTOMLValue(data, entry, value)
+	}
+	if change.delete {
+		return deleteTOMLPath(data, entries, change.path), nil
+	}
+	value, _ := configValueAt(payload, change.path)
+	if entry := findTOMLAssignment(entries, change.path); entry != nil {
+		return replaceTOMLValue(data, entry, value)
+	}
+	// 原来是表而现在是标量，或新增的键：先删除原有内容，再写到所属的表中
+	data = deleteTOMLPath(data, entries, change.path)
+	if entries, err = scanTOMLEntries(data); err != nil {
+		return nil, err
+	}
+	return insertTOMLValue(data, entries, change.path, value)
+}
+
+func findTOMLAssignment(entries []tomlEntry, path []string) *tomlEntry {
+	for i := range entries {
+		if !entries[i].header && !entries[i].inArray && configPathEqual(entries[i].path, path) {
+			return &entries[i]
+		}
+	}
+	return nil
+}
+
+func replaceTOMLValue(data []byte, entry *tomlEntry, value any) ([]byte, error) {
+	text, err := encodeTOMLValue(value)
+	if err != nil {
+		return nil, err
+	}
+	return spliceConfigText(data, entry.valueStart, entry.valueEnd, text), nil
+}
+
+// deleteTOMLPath 删除路径下的所有键值行，以及表头位于路径下的整个表
+func deleteTOMLPath(data []byte, entries []tomlEntry, path []string) []byte {
+	type span struct{ start, end int }
+	var spans []span
+	for i, entry := range entries {
+		if !configPathHasPrefix(entry.path, path) {
+			continue
+		}
+		if !entry.header {
+			spans = append(spans, span{entry.start, entry.end})
+			continue
+		}
+		spans = append(spans, span{tomlSectionStart(data, entry), tomlSectionEnd(data, entries, i)})
+	}
+	// 表的范围包含其中的键值行，合并后从后往前删除，避免偏移量失效
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	merged := make([]span, 0, len(spans))
+	for _, s := range spans {
+		if n := len(merged); n > 0 && s.start <= merged[n-1].end {
+			merged[n-1].end = max(merged[n-1].end, s.end)
+			continue
+		}
+		merged = append(merged, s)
+	}
+	for i := len(merged) - 1; i >= 0; i-- {
+		data = spliceConfigText(data, merged[i].start, merged[i].end, "")
+	}
+	return data
+}
+
+// insertTOMLValue 标量写到父表最后一个键之后；新表追加在同一父级的最后一个表之后
+func insertTOMLValue(data []byte, entries []tomlEntry, path []string, value any) ([]byte, error) {
+	parent, key := path[:len(path)-1], path[len(path)-1]
+	if table, ok := normalizeConfigValue(value).(map[string]any); ok {
+		text, err := encodeTOMLTable(path, table)
+		if err != nil {
+			return nil, err
+		}
+		return insertTOMLTable(data, entries, parent, text), nil
+	}
+	encoded, err := encodeTOMLValue(value)
+	if err != nil {
+		return nil, err
+	}
+	line := formatTOMLKey([]string{key}) + " = " + encoded + "\n"
+
+	headerIndex := -1
+	for i, entry := range entries {
+		if entry.header && !entry.arrayTable && configPathEqual(entry.path, parent) {
+			headerIndex = i
+		}
+	}
+	if len(parent) > 0 && headerIndex < 0 {
+		text, err := encodeTOMLTable(parent, map[string]any{key: value})
+		if err != nil {
+			return nil, err
+		}
+		return insertTOMLTable(data, entries, parent[:len(parent)-1], text), nil
+	}
+	pos := 0
+	if headerIndex >= 0 {
+		pos = entries[headerIndex].end
+	}
+	for _, entry := range entries[headerIndex+1:] {
+		if entry.header {
+			break
+		}
+		pos = entry.end
+	}
+	return insertTOMLLine(data, pos, line), nil
+}
+
+func insertTOMLTable(data []byte, entries []tomlEntry, parent []string, text string) []byte {
+	pos := len(data)
+	if len(parent) > 0 {
+		last := -1
+		for i, entry := range entries {
+			if entry.header && configPathHasPrefix(entry.path, parent) {
+				last = i
+			}
+		}
+		if last >= 0 {
+			if pos = tomlSectionEnd(data, entries, last); pos < len(data) {
+				text += "\n"
+			}
+		}
+	}
+	if pos > 0 && !bytes.HasSuffix(data[:pos], []byte("\n\n")) {
+		text = "\n" + text
+	}
+	return insertTOMLLine(data, pos, text)
+}
+
+// tomlSectionStart 表头之前紧挨着的注释视为表的说明，随表一起删除
+func tomlSectionStart(data []byte, header tomlEntry) int {
+	start := header.start
+	for start > 0 {
+		prev := lineStartOf(data, start-1)
+		if !bytes.HasPrefix(bytes.TrimLeft(data[prev:start], " \t"), []byte("#")) {
+			break
+		}
+		start = prev
+	}
+	return start
+}
+
+// tomlSectionEnd 返回第 i 个表头所在表的结束位置，紧挨下一个表头的注释属于下一个表
+func tomlSectionEnd(data []byte, entries []tomlEntry, i int) int {
+	end := len(data)
+	for _, next := range entries[i+1:] {
+		if next.header {
+			end = next.start
+			break
+		}
+	}
+	if end == len(data) {
+		return end
+	}
+	for end > entries[i].end {
+		start := lineStartOf(data, end-1)
+		if !bytes.HasPrefix(bytes.TrimLeft(data[start:end], " \t"), []byte("#")) {
+			break
+		}
+		end = start
+	}
+	return end
+}
+
+func insertTOMLLine(data []byte, pos int, text string) []byte {
+	if pos > 0 && data[pos-1] != '\n' {
+		text = "\n" + text
+	}
+	return spliceConfigText(data, pos, pos, text)
+}
+
+// dropStaleTOMLHeaders 删除键被清空后留下、payload 中已不存在的表头行
+func dropStaleTOMLHeaders(data []byte, payload map[string]any) ([]byte, error) {
+	entries, err := scanTOMLEntries(data)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if !entry.header || entry.arrayTable {
+			continue
+		}
+		if _, ok := configValueAt(payload, entry.path); !ok {
+			data = spliceConfigText(data, entry.start, entry.end, "")
+		}
+	}
+	return data, nil
+}
+
+func scanTOMLEntries(data []byte) ([]tomlEntry, error) {
+	var entries []tomlEntry
+	var table []string
+	inArray := false
+	i := 0
+	for i < len(data) {
+		lineStart := i
+		i = skipInlineSpace(data, i)
+		if i >= len(data) {
+			break
+		}
+		switch data[i] {
+		case '\n':
+			i++
+		case '#':
+			i = tomlLineEnd(data, i)
+		case '[':
+			array := i+1 < len(data) && data[i+1] == '['
+			closing := "]"
+			if array {
+				closing = "]]"
+			}
+			key, next, err := parseTOMLKey(data, i+len(closing))
+			if err != nil {
+				return nil, err
+			}
+			next = skipInlineSpace(data, next)
+			if !bytes.HasPrefix(data[next:], []byte(closing)) {
+				return nil, fmt.Errorf("第 %d 字节处的表头无效", lineStart)
+			}
+			i = tomlLineEnd(data, next+len(closing))
+			table, inArray = key, array
+			entries = append(entries, tomlEntry{path: key, header: true, arrayTable: array, start: lineStart, end: i})
+		default:
+			key, next, err := parseTOMLKey(data, i)
+			if err != nil {
+				return nil, err
+			}
+			next = skipInlineSpace(data, next)
+			if next >= len(data) || data[next] != '=' {
+				return nil, fmt.Errorf("第 %d 字节处缺少 =", next)
+			}
+			valueStart := skipInlineSpace(data, next+1)
+			valueEnd, err := scanTOMLValue(data, valueStart)
+			if err != nil {
+				return nil, err
+			}
+			i = tomlLineEnd(data, valueEnd)
+			entries = append(entries, tomlEntry{
+				path:       append(append([]string(nil), table...), key...),
+				inArray:    inArray,
+				start:      lineStart,
+				end:        i,
+				valueStart: valueStart,
+				valueEnd:   valueEnd,
+			})
+		}
+	}
+	return entries, nil
+}
+
+func tomlLineEnd(data []byte, pos int) int {
+	if idx := bytes.IndexByte(data[pos:], '\n'); idx >= 0 {
+		return pos + idx + 1
+	}
+	return len(data)
+}
+
+// parseTOMLKey 解析裸键、引号键以及由它们组成的点分键
+func parseTOMLKey(data []byte, pos int) ([]string, int, error) {
+	var segments []string
+	for {
+		pos = skipInlineSpace(data, pos)
+		if pos >= len(data) {
+			return nil, pos, errors.New("键不完整")
+		}
+		switch data[pos] {
+		case '"':
+			end, err := scanTOMLValue(data, pos)
+			if err != nil {
+				return nil, pos, err
+			}
+			segment, err := strconv.Unquote(string(data[pos:end]))
+			if err != nil {
+				return nil, pos, err
+			}
+			segments = append(segments, segment)
+			pos = end
+		case '\'':
+			end := bytes.IndexByte(data[pos+1:], '\'')
+			if end < 0 {
+				return nil, pos, errors.New("引号键未闭合")
+			}
+			segments = append(segments, string(data[pos+1:pos+1+end]))
+			pos += end + 2
+		default:
+			end := pos
+			for end < len(data) && isTOMLBareKeyChar(data[end]) {
+				end++
+			}
+			if end == pos {
+				return nil, pos, fmt.Errorf("第 %d 字节处的键无效", pos)
+			}
+			segments = append(segments, string(data[pos:end]))
+			pos = end
+		}
+		next := skipInlineSpace(data, pos)
+		if next < len(data) && data[next] == '.' {
+			pos = next + 1
+			continue
+		}
+		return segments, pos, nil
+	}
+}
+
+func isTOMLBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// scanTOMLValue 返回取值结束的位置：字符串按引号配对，数组与内联表按括号配对，其余取到行尾或注释前
+func scanTOMLValue(data []byte, pos int) (int, error) {
+	if pos >= len(data) {
+		return pos, errors.New("缺少取值")
+	}
+	switch {
+	case bytes.HasPrefix(data[pos:], []byte(`"""`)), bytes.HasPrefix(data[pos:], []byte(`'''`)):
+		delim := data[pos : pos+3]
+		for i := pos + 3; i+3 <= len(data); i++ {
+			if delim[0] == '"' && data[i] == '\\' {
+				i++
+				continue
+			}
+			if bytes.HasPrefix(data[i:], delim) {
+				end := i + 3
+				for end < len(data) && data[end] == delim[0] && end < i+5 {
+					end++
+				}
+				return end, nil
+			}
+		}
+		return pos, errors.New("多行字符串未闭合")
+	case data[pos] == '"':
+		for i := pos + 1; i < len(data) && data[i] != '\n'; i++ {
+			if data[i] == '\\' {
+				i++
+				continue
+			}
+			if data[i] == '"' {
+				return i + 1, nil
+			}
+		}
+		return pos, errors.New("字符串未闭合")
+	case data[pos] == '\'':
+		for i := pos + 1; i < len(data) && data[i] != '\n'; i++ {
+			if data[i] == '\'' {
+				return i + 1, nil
+			}
+		}
+		return pos, errors.New("字符串未闭合")
+	case data[pos] == '[' || data[pos] == '{':
+		depth := 0
+		for i := pos; i < len(data); {
+			switch data[i] {
+			case '"', '\'':
+				end, err := scanTOMLValue(data, i)
+				if err != nil {
+					return pos, err
+				}
+				i = end
+				continue
+			case '#':
+				i = tomlLineEnd(data, i)
+				continue
+			case '[', '{':
+				depth++
+			case ']', '}':
+				depth--
+				if depth == 0 {
+					return i + 1, nil
+				}
+			}
+			i++
+		}
+		return pos, errors.New("括号未闭合")
+	default:
+		end := pos
+		for end < len(data) && data[end] != '\n' && data[end] != '\r' && data[end] != '#' {
+			end++
+		}
+		for end > pos && (data[end-1] == ' ' || data[end-1] == '\t') {
+			end--
+		}
+		if end == pos {
+			return pos, errors.New("缺少取值")
+		}
+		return end, nil
+	}
+}
+
+func formatTOMLKey(path []string) string {
+	parts := make([]string, len(path))
+	for i, segment := range path {
+		if tomlBareKeyPattern.MatchString(segment) {
+			parts[i] = segment
+		} else {
+			parts[i] = quoteTOMLString(segment)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// encodeTOMLTable 生成 [path] 表：先写标量，再依次写子表
+func encodeTOMLTable(path []string, table map[string]any) (string, error) {
+	var scalars, tables []string
+	for key, value := range table {
+		if _, ok := normalizeConfigValue(value).(map[string]any); ok {
+			tables = append(tables, key)
+		} else {
+			scalars = append(scalars, key)
+		}
+	}
+	sort.Strings(scalars)
+	sort.Strings(tables)
+	var buf strings.Builder
+	buf.WriteString("[" + formatTOMLKey(path) + "]\n")
+	for _, key := range scalars {
+		encoded, err := encodeTOMLValue(table[key])
+		if err != nil {
+			return "", err
+		}
+		buf.WriteString(formatTOMLKey([]string{key}) + " = " + encoded + "\n")
+	}
+	for _, key := range tables {
+		sub, err := encodeTOMLTable(append(append([]string(nil), path...), key), normalizeConfigValue(table[key]).(map[string]any))
+		if err != nil {
+			return "", err
+		}
+		buf.WriteString("\n" + sub)
+	}
+	return buf.String(), nil
+}
+
+// encodeTOMLValue 把取值编码为单行 TOML，object 写作内联表
+func encodeTOMLValue(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", errors.New("TOML 不支持空值")
+	case string:
+		return quoteTOMLString(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return formatTOMLFloat(v), nil
+	case float32:
+		return formatTOMLFloat(float64(v)), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case toml.LocalDate, toml.LocalTime, toml.LocalDateTime:
+		return fmt.Sprint(v), nil
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fmt.Sprint(value), nil
+	case reflect.Slice, reflect.Array:
+		items := make([]string, rv.Len())
+		for i := range items {
+			encoded, err := encodeTOMLValue(rv.Index(i).Interface())
+			if err != nil {
+				return "", err
+			}
+			items[i] = encoded
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return "", fmt.Errorf("不支持的键类型 %s", rv.Type().Key())
+		}
+		keys := make([]string, 0, rv.Len())
+		for _, key := range rv.MapKeys() {
+			keys = append(keys, key.String())
+		}
+		sort.Strings(keys)
+		if len(keys) == 0 {
+			return "{}", nil
+		}
+		items := make([]string, len(keys))
+		for i, key := range keys {
+			encoded, err := encodeTOMLValue(rv.MapIndex(reflect.ValueOf(key).Convert(rv.Type().Key())).Interface())
+			if err != nil {
+				return "", err
+			}
+			items[i] = formatTOMLKey([]string{key}) + " = " + encoded
+		}
+		return "{ " + strings.Join(items, ", ") + " }", nil
+	}
+	return "", fmt.Errorf("不支持的取值类型 %T", value)
+}
+
+func formatTOMLFloat(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "nan"
+	case math.IsInf(v, 1):
+		return "inf"
+	case math.IsInf(v, -1):
+		return "-inf"
+	}
+	text := strconv.FormatFloat(v, 'g', -1, 64)
+	if !strings.ContainsAny(text, ".eEn") {
+		text += ".0"
+	}
+	return text
+}
+
+func quoteTOMLString(s string) string {
+	var buf strings.Builder
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '\\':
+			buf.WriteString(`\\`)
+		case '"':
+			buf.WriteString(`\"`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\t':
+			buf.WriteString(`\t`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\r':
+			buf.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&buf, `\u%04X`, r)
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
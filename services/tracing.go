@@ -0,0 +1,414 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	relayTraceKey        = "relay_trace"
+	traceFlushInterval   = 5 * time.Second
+	traceExportTimeout   = 10 * time.Second
+	traceQueueLimit      = 2048
+	traceExportBatchSize = 512
+)
+
+// OTLP span kind / status code
+const (
+	otlpSpanKindServer = 2
+	otlpSpanKindClient = 3
+	otlpStatusOK       = 1
+	otlpStatusError    = 2
+)
+
+// TracingPolicy OTLP 链路追踪导出（默认关闭）：每次转发请求为一个 span，每个 provider 尝试为子 span
+type TracingPolicy struct {
+	Enabled bool `json:"enabled"`
+	// OTLP/HTTP traces 端点，如 http://localhost:4318/v1/traces
+	Endpoint string `json:"endpoint"`
+	// 额外请求头，如鉴权 token
+	Headers     map[string]string `json:"headers"`
+	ServiceName string            `json:"service_name"`
+}
+
+// tracingPolicy 由 TracingService 维护、relay 读取
+var tracingPolicy atomic.Pointer[TracingPolicy]
+
+// traceQueue 待导出的 span，超出上限时丢弃最早的
+var traceQueue = struct {
+	sync.Mutex
+	spans   []traceSpan
+	dropped int
+}{}
+
+type traceSpan struct {
+	traceID      string
+	spanID       string
+	parentSpanID string
+	name         string
+	kind         int
+	start        time.Time
+	end          time.Time
+	attributes   map[string]any
+	failed       bool
+	message      string
+}
+
+type TracingService struct {
+	appSettings *AppSettingsService
+	httpClient  *http.Client
+	mu          sync.Mutex
+	stopCh      chan struct{}
+}
+
+func NewTracingService(appSettings *AppSettingsService) *TracingService {
+	ts := &TracingService{
+		appSettings: appSettings,
+		httpClient:  &http.Client{Timeout: traceExportTimeout},
+	}
+	policy := defaultTracingPolicy()
+	if appSettings != nil {
+		if settings, err := appSettings.GetAppSettings(); err == nil {
+			policy = normalizeTracingPolicy(settings.Tracing)
+		}
+	}
+	tracingPolicy.Store(&policy)
+	return ts
+}
+
+func defaultTracingPolicy() TracingPolicy {
+	return TracingPolicy{
+		Endpoint:    "http://localhost:4318/v1/traces",
+		ServiceName: "code-switch",
+	}
+}
+
+func normalizeTracingPolicy(policy TracingPolicy) TracingPolicy {
+	defaults := defaultTracingPolicy()
+	policy.Endpoint = strings.TrimSpace(policy.Endpoint)
+	if policy.Endpoint == "" {
+		policy.Endpoint = defaults.Endpoint
+	}
+	policy.ServiceName = strings.TrimSpace(policy.ServiceName)
+	if policy.ServiceName == "" {
+		policy.ServiceName = defaults.ServiceName
+	}
+	return policy
+}
+
+// GetPolicy 返回当前的链路追踪配置
+func (ts *TracingService) GetPolicy() TracingPolicy {
+	return currentTracingPolicy()
+}
+
+// SavePolicy 保存链路追踪配置并立即生效
+func (ts *TracingService) SavePolicy(policy TracingPolicy) (TracingPolicy, error) {
+	policy = normalizeTracingPolicy(policy)
+	if !strings.HasPrefix(policy.Endpoint, "http://") && !strings.HasPrefix(policy.Endpoint, "https://") {
+		return policy, fmt.Errorf("无效的 OTLP 端点: %s", policy.Endpoint)
+	}
+	if ts.appSettings != nil {
+		if _, err := ts.appSettings.update(func(settings *AppSettings) {
+			settings.Tracing = policy
+		}); err != nil {
+			return policy, err
+		}
+	}
+	tracingPolicy.Store(&policy)
+	return policy, nil
+}
+
+// Start 启动定时导出
+func (ts *TracingService) Start() error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.stopCh != nil {
+		return nil
+	}
+	stopCh := make(chan struct{})
+	ts.stopCh = stopCh
+	go func() {
+		ticker := time.NewTicker(traceFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ts.flush()
+			case <-stopCh:
+				ts.flush()
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (ts *TracingService) Stop() error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.stopCh != nil {
+		close(ts.stopCh)
+		ts.stopCh = nil
+	}
+	return nil
+}
+
+// flush 分批导出队列中的 span，导出失败的 span 直接丢弃，避免阻塞后续请求
+func (ts *TracingService) flush() {
+	traceQueue.Lock()
+	spans := traceQueue.spans
+	dropped := traceQueue.dropped
+	traceQueue.spans = nil
+	traceQueue.dropped = 0
+	traceQueue.Unlock()
+	if dropped > 0 {
+		fmt.Printf("[WARN] 链路追踪队列已满，丢弃 %d 个 span\n", dropped)
+	}
+	policy := currentTracingPolicy()
+	if len(spans) == 0 || !policy.Enabled {
+		return
+	}
+	for start := 0; start < len(spans); start += traceExportBatchSize {
+		end := min(start+traceExportBatchSize, len(spans))
+		if err := ts.export(policy, spans[start:end]); err != nil {
+			fmt.Printf("[WARN] 导出链路追踪失败: %v\n", err)
+			return
+		}
+	}
+}
+
+func (ts *TracingService) export(policy TracingPolicy, spans []traceSpan) error {
+	payload, err := json.Marshal(otlpPayload(policy.ServiceName, spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, policy.Endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range policy.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := ts.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func currentTracingPolicy() TracingPolicy {
+	if policy := tracingPolicy.Load(); policy != nil {
+		return *policy
+	}
+	return defaultTracingPolicy()
+}
+
+// relayTrace 一次转发请求的链路，未开启追踪时为 nil，方法均可在 nil 上调用
+type relayTrace struct {
+	mu       sync.Mutex
+	root     traceSpan
+	attempts int
+}
+
+// startRelayTrace 开始记录一次转发请求，客户端带有 traceparent 时沿用其 trace id
+func startRelayTrace(kind, endpoint, requestID, sessionID, traceparent string) *relayTrace {
+	if !currentTracingPolicy().Enabled {
+		return nil
+	}
+	root := traceSpan{
+		traceID: newTraceID(16),
+		spanID:  newTraceID(8),
+		name:    "relay " + endpoint,
+		kind:    otlpSpanKindServer,
+		start:   time.Now(),
+		attributes: map[string]any{
+			"codeswitch.platform":   kind,
+			"codeswitch.request_id": requestID,
+			"http.route":            endpoint,
+		},
+	}
+	if sessionID != "" {
+		root.attributes["codeswitch.session_id"] = sessionID
+	}
+	if traceID, parentID, ok := parseTraceparent(traceparent); ok {
+		root.traceID = traceID
+		root.parentSpanID = parentID
+	}
+	return &relayTrace{root: root}
+}
+
+// recordAttempt 记录一次 provider 尝试（含故障切换）为子 span
+func (rt *relayTrace) recordAttempt(entry *ReqeustLog, start time.Time, err error) {
+	if rt == nil || entry == nil {
+		return
+	}
+	rt.mu.Lock()
+	rt.attempts++
+	attempt := rt.attempts
+	rt.mu.Unlock()
+	span := traceSpan{
+		traceID:      rt.root.traceID,
+		spanID:       newTraceID(8),
+		parentSpanID: rt.root.spanID,
+		name:         "attempt " + entry.Provider,
+		kind:         otlpSpanKindClient,
+		start:        start,
+		end:          time.Now(),
+		attributes: map[string]any{
+			"codeswitch.provider":        entry.Provider,
+			"codeswitch.attempt":         attempt,
+			"gen_ai.request.model":       entry.Model,
+			"gen_ai.usage.input_tokens":  entry.InputTokens,
+			"gen_ai.usage.output_tokens": entry.OutputTokens,
+			"http.response.status_code":  entry.HttpCode,
+			"codeswitch.stream":          entry.IsStream,
+		},
+	}
+	if entry.FirstTokenSec > 0 {
+		span.attributes["codeswitch.first_token_sec"] = entry.FirstTokenSec
+	}
+	if entry.RequestedModel != "" {
+		span.attributes["codeswitch.requested_model"] = entry.RequestedModel
+	}
+	if err != nil {
+		span.failed = true
+		span.message = truncateProbeMessage(err.Error())
+	}
+	enqueueTraceSpan(span)
+}
+
+// finish 结束根 span 并加入导出队列
+func (rt *relayTrace) finish(status int, err error) {
+	if rt == nil {
+		return
+	}
+	rt.mu.Lock()
+	span := rt.root
+	span.attributes["codeswitch.attempts"] = rt.attempts
+	rt.mu.Unlock()
+	span.end = time.Now()
+	span.attributes["http.response.status_code"] = status
+	if status >= http.StatusBadRequest {
+		span.failed = true
+		if err != nil {
+			span.message = truncateProbeMessage(err.Error())
+		}
+	}
+	enqueueTraceSpan(span)
+}
+
+func enqueueTraceSpan(span traceSpan) {
+	traceQueue.Lock()
+	defer traceQueue.Unlock()
+	if len(traceQueue.spans) >= traceQueueLimit {
+		traceQueue.spans = traceQueue.spans[1:]
+		traceQueue.dropped++
+	}
+	traceQueue.spans = append(traceQueue.spans, span)
+}
+
+// parseTraceparent 解析 W3C traceparent：00-<trace-id>-<parent-id>-<flags>
+func parseTraceparent(value string) (string, string, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return "", "", false
+	}
+	if _, err := hex.DecodeString(parts[1] + parts[2]); err != nil {
+		return "", "", false
+	}
+	if strings.Trim(parts[1], "0") == "" || strings.Trim(parts[2], "0") == "" {
+		return "", "", false
+	}
+	return strings.ToLower(parts[1]), strings.ToLower(parts[2]), true
+}
+
+func newTraceID(size int) string {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return strings.Repeat("0", size*2-1) + "1"
+	}
+	return hex.EncodeToString(buf)
+}
+
+// otlpPayload 按 OTLP/HTTP JSON 编码组装 ExportTraceServiceRequest
+func otlpPayload(serviceName string, spans []traceSpan) map[string]any {
+	encoded := make([]map[string]any, 0, len(spans))
+	for _, span := range spans {
+		item := map[string]any{
+			"traceId":           span.traceID,
+			"spanId":            span.spanID,
+			"name":              span.name,
+			"kind":              span.kind,
+			"startTimeUnixNano": strconv.FormatInt(span.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(span.end.UnixNano(), 10),
+			"attributes":        otlpAttributes(span.attributes),
+		}
+		if span.parentSpanID != "" {
+			item["parentSpanId"] = span.parentSpanID
+		}
+		status := map[string]any{"code": otlpStatusOK}
+		if span.failed {
+			status = map[string]any{"code": otlpStatusError, "message": span.message}
+		}
+		item["status"] = status
+		encoded = append(encoded, item)
+	}
+	return map[string]any{
+		"resourceSpans": []any{
+			map[string]any{
+				"resource": map[string]any{
+					"attributes": otlpAttributes(map[string]any{"service.name": serviceName}),
+				},
+				"scopeSpans": []any{
+					map[string]any{
+						"scope": map[string]any{"name": "codeswitch/relay"},
+						"spans": encoded,
+					},
+				},
+			},
+		},
+	}
+}
+
+func otlpAttributes(attributes map[string]any) []map[string]any {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	encoded := make([]map[string]any, 0, len(keys))
+	for _, key := range keys {
+		var value map[string]any
+		switch v := attributes[key].(type) {
+		case bool:
+			value = map[string]any{"boolValue": v}
+		case int:
+			value = map[string]any{"intValue": strconv.Itoa(v)}
+		case int64:
+			value = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]any{"doubleValue": v}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprint(v)}
+		}
+		encoded = append(encoded, map[string]any{"key": key, "value": value})
+	}
+	return encoded
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	traceID, parentID, ok := parseTraceparent("00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01")
+	if !ok || traceID != "4bf92f3577b34da6a3ce929d0e0e4736" || parentID != "00f067aa0ba902b7" {
+		t.Fatalf("unexpected parse result: %s %s %v", traceID, parentID, ok)
+	}
+	for _, value := range []string{"", "00-abc-def-01", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", "00-zzf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"} {
+		if _, _, ok := parseTraceparent(value); ok {
+			t.Fatalf("expected %q to be rejected", value)
+		}
+	}
+}
+
+func TestOTLPPayloadEncodesSpans(t *testing.T) {
+	start := time.Unix(100, 0)
+	spans := []traceSpan{{
+		traceID:      "4bf92f3577b34da6a3ce929d0e0e4736",
+		spanID:       "00f067aa0ba902b7",
+		parentSpanID: "1111111111111111",
+		name:         "attempt demo",
+		kind:         otlpSpanKindClient,
+		start:        start,
+		end:          start.Add(time.Second),
+		attributes:   map[string]any{"codeswitch.attempt": 2, "codeswitch.stream": true, "codeswitch.provider": "demo"},
+		failed:       true,
+		message:      "HTTP 502",
+	}}
+	data, err := json.Marshal(otlpPayload("code-switch", spans))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := string(data)
+	for _, want := range []string{
+		`"service.name"`,
+		`"startTimeUnixNano":"100000000000"`,
+		`"parentSpanId":"1111111111111111"`,
+		`{"key":"codeswitch.attempt","value":{"intValue":"2"}}`,
+		`{"key":"codeswitch.stream","value":{"boolValue":true}}`,
+		`"status":{"code":2,"message":"HTTP 502"}`,
+	} {
+		if !strings.Contains(payload, want) {
+			t.Fatalf("payload missing %s: %s", want, payload)
+		}
+	}
+}
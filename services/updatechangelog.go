@@ -0,0 +1,205 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	updateReleasesURL = "https://api.github.com/repos/daodao97/code-switch/releases?per_page=50"
+	changelogCacheTTL = 6 * time.Hour
+	changelogFile     = "changelog.json"
+	// GitHub API 返回的 release 列表比 latest.json 大得多
+	changelogMaxLen = 8 << 20
+)
+
+// ChangelogSection 发布说明中的一个小节（如“新增功能”“问题修复”）
+type ChangelogSection struct {
+	Title string   `json:"title"`
+	Items []string `json:"items"`
+}
+
+// ChangelogEntry 某个版本的发布说明
+type ChangelogEntry struct {
+	Version     string             `json:"version"`
+	Title       string             `json:"title"`
+	PublishedAt time.Time          `json:"published_at"`
+	URL         string             `json:"url"`
+	Notes       string             `json:"notes"`
+	Sections    []ChangelogSection `json:"sections"`
+}
+
+type changelogCache struct {
+	FetchedAt time.Time        `json:"fetched_at"`
+	Entries   []ChangelogEntry `json:"entries"`
+}
+
+type githubRelease struct {
+	TagName     string    `json:"tag_name"`
+	Name        string    `json:"name"`
+	Body        string    `json:"body"`
+	HTMLURL     string    `json:"html_url"`
+	Draft       bool      `json:"draft"`
+	Prerelease  bool      `json:"prerelease"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// GetChangelog 返回当前版本之后、最新版本及之前的全部发布说明，新版本在前；
+// 发布列表缓存在本地，网络不可用时使用缓存
+func (us *UpdateService) GetChangelog() ([]ChangelogEntry, error) {
+	state := us.GetState()
+	entries, err := us.loadChangelog(false)
+	if err != nil {
+		return nil, err
+	}
+	// 缓存中还没有已知的最新版本时强制刷新
+	if state.LatestVersion != "" && !changelogHasVersion(entries, state.LatestVersion) {
+		if refreshed, err := us.loadChangelog(true); err == nil {
+			entries = refreshed
+		}
+	}
+	return changelogBetween(entries, state.CurrentVersion, state.LatestVersion), nil
+}
+
+func (us *UpdateService) loadChangelog(force bool) ([]ChangelogEntry, error) {
+	path := filepath.Join(us.dir, changelogFile)
+	var cache changelogCache
+	if data, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(data, &cache)
+	}
+	if !force && len(cache.Entries) > 0 && time.Since(cache.FetchedAt) < changelogCacheTTL {
+		return cache.Entries, nil
+	}
+	entries, err := us.fetchChangelog()
+	if err != nil {
+		if len(cache.Entries) > 0 {
+			fmt.Printf("[WARN] 获取发布说明失败，使用缓存: %v\n", err)
+			return cache.Entries, nil
+		}
+		return nil, err
+	}
+	cache = changelogCache{FetchedAt: time.Now(), Entries: entries}
+	if data, err := json.MarshalIndent(cache, "", "  "); err == nil {
+		if err := os.MkdirAll(us.dir, 0o755); err == nil {
+			_ = os.WriteFile(path, data, 0o644)
+		}
+	}
+	return entries, nil
+}
+
+func (us *UpdateService) fetchChangelog() ([]ChangelogEntry, error) {
+	settings, err := us.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	client, err := updateHTTPClient(settings, updateCheckTimeout)
+	if err != nil {
+		return nil, err
+	}
+	var lastErr error
+	for _, candidate := range updateCandidateURLs(settings, updateReleasesURL) {
+		resp, err := client.Get(candidate)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		var releases []githubRelease
+		if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("HTTP %d", resp.StatusCode)
+		} else {
+			err = json.NewDecoder(io.LimitReader(resp.Body, changelogMaxLen)).Decode(&releases)
+		}
+		resp.Body.Close()
+		if err != nil {
+			lastErr = err
+			fmt.Printf("[WARN] 获取发布说明失败 %s: %v\n", candidate, err)
+			continue
+		}
+		entries := make([]ChangelogEntry, 0, len(releases))
+		for _, release := range releases {
+			if release.Draft || release.Prerelease || release.TagName == "" {
+				continue
+			}
+			entries = append(entries, ChangelogEntry{
+				Version:     release.TagName,
+				Title:       release.Name,
+				PublishedAt: release.PublishedAt,
+				URL:         release.HTMLURL,
+				Notes:       release.Body,
+				Sections:    parseChangelogSections(release.Body),
+			})
+		}
+		return entries, nil
+	}
+	return nil, fmt.Errorf("获取发布说明失败: %w", lastErr)
+}
+
+func changelogHasVersion(entries []ChangelogEntry, version string) bool {
+	for _, entry := range entries {
+		if compareVersions(entry.Version, version) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// changelogBetween 筛选 (current, latest] 之间的版本，latest 为空时不设上限
+func changelogBetween(entries []ChangelogEntry, current, latest string) []ChangelogEntry {
+	result := make([]ChangelogEntry, 0)
+	for _, entry := range entries {
+		if compareVersions(entry.Version, current) <= 0 {
+			continue
+		}
+		if latest != "" && compareVersions(entry.Version, latest) > 0 {
+			continue
+		}
+		result = append(result, entry)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return compareVersions(result[i].Version, result[j].Version) > 0
+	})
+	return result
+}
+
+// parseChangelogSections 按 Markdown 标题拆分小节，列表项作为条目；标题前的列表项归入无标题小节
+func parseChangelogSections(body string) []ChangelogSection {
+	sections := make([]ChangelogSection, 0)
+	current := -1
+	for _, line := range strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "#"):
+			title := strings.TrimSpace(strings.TrimLeft(line, "#"))
+			if title == "" {
+				continue
+			}
+			sections = append(sections, ChangelogSection{Title: title, Items: []string{}})
+			current = len(sections) - 1
+		case strings.HasPrefix(line, "- "), strings.HasPrefix(line, "* "):
+			item := strings.TrimSpace(line[2:])
+			if item == "" {
+				continue
+			}
+			if current < 0 {
+				sections = append(sections, ChangelogSection{Items: []string{}})
+				current = 0
+			}
+			sections[current].Items = append(sections[current].Items, item)
+		}
+	}
+	// 去掉只有标题、没有条目的上级标题
+	result := sections[:0]
+	for _, section := range sections {
+		if len(section.Items) > 0 {
+			result = append(result, section)
+		}
+	}
+	return result
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const bsdiffMagic = "BSDIFF40"
+
+// UpdatePatch 从某个旧版本安装包生成新安装包的 bsdiff 补丁
+type UpdatePatch struct {
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size,omitempty"`
+}
+
+// errNoUpdatePatch 没有匹配当前版本的补丁或本地没有当前版本的安装包，直接完整下载
+var errNoUpdatePatch = errors.New("没有可用的增量补丁")
+
+func updateAssetName(asset UpdateAsset) string {
+	name := filepath.Base(asset.Name)
+	if name == "." || name == string(filepath.Separator) || name == "" {
+		name = filepath.Base(asset.URL)
+	}
+	return name
+}
+
+// versionDir 某个版本安装包的存放目录
+func (us *UpdateService) versionDir(version string) string {
+	return filepath.Join(us.dir, filepath.Base(strings.TrimSpace(version)))
+}
+
+// pruneUpdateDirs 只保留指定版本的安装包与发布说明缓存，其余版本的目录与旧布局下的文件一并删除
+func (us *UpdateService) pruneUpdateDirs(keep ...string) {
+	entries, err := os.ReadDir(us.dir)
+	if err != nil {
+		return
+	}
+	kept := map[string]bool{changelogFile: true}
+	for _, version := range keep {
+		kept[filepath.Base(us.versionDir(version))] = true
+	}
+	for _, entry := range entries {
+		if kept[entry.Name()] {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(us.dir, entry.Name())); err != nil {
+			fmt.Printf("[WARN] 清理旧安装包失败 %s: %v\n", entry.Name(), err)
+		}
+	}
+}
+
+// findUpdatePatch 按当前版本查找补丁，兼容版本号带或不带 v 前缀
+func findUpdatePatch(asset UpdateAsset, current string) (UpdatePatch, bool) {
+	bare := strings.TrimPrefix(strings.TrimSpace(current), "v")
+	for _, key := range []string{current, bare, "v" + bare} {
+		if patch, ok := asset.Patches[key]; ok && patch.URL != "" && patch.SHA256 != "" {
+			return patch, true
+		}
+	}
+	return UpdatePatch{}, false
+}
+
+// applyPatch 下载补丁并应用到当前版本的安装包，结果需与完整安装包的 SHA256 和签名一致，
+// 写入 partial 后由调用方放到最终路径
+func (us *UpdateService) applyPatch(ctx context.Context, client *http.Client, settings UpdateSettings, asset UpdateAsset, partial string) error {
+	current := us.GetState().CurrentVersion
+	patch, ok := findUpdatePatch(asset, current)
+	if !ok {
+		return errNoUpdatePatch
+	}
+	base, err := os.ReadFile(filepath.Join(us.versionDir(current), updateAssetName(asset)))
+	if err != nil {
+		return errNoUpdatePatch
+	}
+
+	patchPath := partial + ".patch"
+	signatures := updateCandidateURLs(settings, asset.URL+updateSignatureSuffix)
+	var lastErr error
+	for i, candidate := range updateCandidateURLs(settings, patch.URL) {
+		if lastErr = us.downloadTo(ctx, client, candidate, patchPath, patch.SHA256); lastErr != nil {
+			if errors.Is(lastErr, context.Canceled) {
+				return lastErr
+			}
+			fmt.Printf("[WARN] 下载增量补丁失败 %s: %v\n", candidate, lastErr)
+			continue
+		}
+		diff, err := os.ReadFile(patchPath)
+		_ = os.Remove(patchPath)
+		if err != nil {
+			return err
+		}
+		patched, err := bspatch(base, diff)
+		if err != nil {
+			return fmt.Errorf("应用补丁失败: %w", err)
+		}
+		sum := sha256.Sum256(patched)
+		if actual := hex.EncodeToString(sum[:]); !strings.EqualFold(actual, asset.SHA256) {
+			return fmt.Errorf("补丁结果 SHA256 校验失败：期望 %s，实际 %s", asset.SHA256, actual)
+		}
+		if err := os.WriteFile(partial, patched, 0o644); err != nil {
+			return err
+		}
+		return verifyUpdateFile(client, signatures[i], partial)
+	}
+	return fmt.Errorf("下载增量补丁失败: %w", lastErr)
+}
+
+// bspatch 应用 bsdiff 4.x（BSDIFF40）格式的补丁：32 字节头部后依次是 bzip2 压缩的
+// 控制块、差异块与新增块
+func bspatch(old, patch []byte) ([]byte, error) {
+	if len(patch) < 32 || string(patch[:8]) != bsdiffMagic {
+		return nil, errors.New("不是 BSDIFF40 格式的补丁")
+	}
+	ctrlLen := offtin(patch[8:16])
+	diffLen := offtin(patch[16:24])
+	newSize := offtin(patch[24:32])
+	if ctrlLen < 0 || diffLen < 0 || newSize < 0 || 32+ctrlLen+diffLen > int64(len(patch)) {
+		return nil, errors.New("补丁头部损坏")
+	}
+	ctrlReader := bzip2.NewReader(bytes.NewReader(patch[32 : 32+ctrlLen]))
+	diffReader := bzip2.NewReader(bytes.NewReader(patch[32+ctrlLen : 32+ctrlLen+diffLen]))
+	extraReader := bzip2.NewReader(bytes.NewReader(patch[32+ctrlLen+diffLen:]))
+
+	result := make([]byte, newSize)
+	var oldPos, newPos int64
+	ctrl := make([]byte, 24)
+	for newPos < newSize {
+		if _, err := io.ReadFull(ctrlReader, ctrl); err != nil {
+			return nil, fmt.Errorf("读取控制块失败: %w", err)
+		}
+		add, copyLen, seek := offtin(ctrl[0:8]), offtin(ctrl[8:16]), offtin(ctrl[16:24])
+
+		if add < 0 || newPos+add > newSize {
+			return nil, errors.New("补丁控制块损坏")
+		}
+		chunk := result[newPos : newPos+add]
+		if _, err := io.ReadFull(diffReader, chunk); err != nil {
+			return nil, fmt.Errorf("读取差异块失败: %w", err)
+		}
+		for i := range chunk {
+			if at := oldPos + int64(i); at >= 0 && at < int64(len(old)) {
+				chunk[i] += old[at]
+			}
+		}
+		newPos += add
+		oldPos += add
+
+		if copyLen < 0 || newPos+copyLen > newSize {
+			return nil, errors.New("补丁控制块损坏")
+		}
+		if _, err := io.ReadFull(extraReader, result[newPos:newPos+copyLen]); err != nil {
+			return nil, fmt.Errorf("读取新增块失败: %w", err)
+		}
+		newPos += copyLen
+		oldPos += seek
+	}
+	return result, nil
+}
+
+// offtin 解析 bsdiff 的 8 字节整数：小端序，最高位为符号位
+func offtin(buf []byte) int64 {
+	value := int64(binary.LittleEndian.Uint64(buf) &^ (1 << 63))
+	if buf[7]&0x80 != 0 {
+		return -value
+	}
+	return value
+}
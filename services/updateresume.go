@@ -0,0 +1,83 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// updateDownloadStateFile 记录未完成的下载，应用重启后恢复为暂停状态，继续下载时断点续传
+const updateDownloadStateFile = "download.json"
+
+type pendingDownload struct {
+	Version string      `json:"version"`
+	Notes   string      `json:"notes,omitempty"`
+	Asset   UpdateAsset `json:"asset"`
+}
+
+// PauseDownload 暂停正在进行的下载，已下载的部分保留在磁盘上
+func (us *UpdateService) PauseDownload() (UpdateState, error) {
+	us.mu.Lock()
+	cancel := us.cancelDownload
+	status := us.state.Status
+	us.mu.Unlock()
+	if cancel == nil || status != UpdateStatusDownloading {
+		return us.GetState(), errors.New("当前没有正在进行的下载")
+	}
+	cancel()
+	// 等下载协程退出并把状态置为 paused
+	us.downloadMu.Lock()
+	defer us.downloadMu.Unlock()
+	return us.GetState(), nil
+}
+
+// ResumeDownload 从断点继续下载（包括应用重启前暂停或中断的下载）
+func (us *UpdateService) ResumeDownload() (UpdateState, error) {
+	if state := us.GetState(); state.Status != UpdateStatusPaused && state.Status != UpdateStatusError {
+		return state, errors.New("没有可继续的下载")
+	}
+	return us.DownloadUpdate()
+}
+
+func (us *UpdateService) pendingDownloadPath() string {
+	return filepath.Join(us.dir, updateDownloadStateFile)
+}
+
+func (us *UpdateService) savePendingDownload(version, notes string, asset UpdateAsset) {
+	data, err := json.MarshalIndent(pendingDownload{Version: version, Notes: notes, Asset: asset}, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(us.dir, 0o755); err == nil {
+		_ = os.WriteFile(us.pendingDownloadPath(), data, 0o644)
+	}
+}
+
+func (us *UpdateService) clearPendingDownload() {
+	_ = os.Remove(us.pendingDownloadPath())
+}
+
+// restorePendingDownload 启动时恢复上次未完成的下载；版本已不比当前新或已下载部分丢失时丢弃
+func (us *UpdateService) restorePendingDownload() {
+	data, err := os.ReadFile(us.pendingDownloadPath())
+	if err != nil {
+		return
+	}
+	var pending pendingDownload
+	if err := json.Unmarshal(data, &pending); err != nil || pending.Asset.URL == "" ||
+		compareVersions(pending.Version, us.state.CurrentVersion) <= 0 {
+		us.clearPendingDownload()
+		return
+	}
+	partial := filepath.Join(us.versionDir(pending.Version), updateAssetName(pending.Asset)) + ".part"
+	if !fileExists(partial) && !fileExists(partial+".patch") {
+		us.clearPendingDownload()
+		return
+	}
+	asset := pending.Asset
+	us.state.Status = UpdateStatusPaused
+	us.state.LatestVersion = pending.Version
+	us.state.Notes = pending.Notes
+	us.state.Asset = &asset
+}
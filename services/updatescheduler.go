@@ -0,0 +1,113 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	updateSchedulerTick = 5 * time.Minute
+	// 启动后延迟一段时间再检查，避开启动时的网络与磁盘高峰
+	updateLaunchDelay = 30 * time.Second
+)
+
+// lastRelayActivity 最近一次 relay 请求的 Unix 时间，用于判断是否空闲
+var lastRelayActivity atomic.Int64
+
+func markRelayActivity() {
+	lastRelayActivity.Store(time.Now().Unix())
+}
+
+// relayIdleFor 距最近一次 relay 请求的时长，从未有请求时视为一直空闲
+func relayIdleFor(now time.Time) time.Duration {
+	last := lastRelayActivity.Load()
+	if last == 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return now.Sub(time.Unix(last, 0))
+}
+
+// Start 启动后台更新检查：按配置在启动时检查一次，之后按间隔检查，空闲时自动下载
+func (us *UpdateService) Start() error {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	if us.stopCh != nil {
+		return nil
+	}
+	stopCh := make(chan struct{})
+	us.stopCh = stopCh
+	go func() {
+		launch := time.NewTimer(updateLaunchDelay)
+		defer launch.Stop()
+		ticker := time.NewTicker(updateSchedulerTick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-launch.C:
+				if settings, err := us.GetSettings(); err == nil && settings.CheckOnLaunch {
+					us.check()
+				}
+				us.autoDownloadIfIdle(time.Now())
+			case <-ticker.C:
+				us.runDue(time.Now())
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (us *UpdateService) Stop() error {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	if us.stopCh != nil {
+		close(us.stopCh)
+		us.stopCh = nil
+	}
+	return nil
+}
+
+func (us *UpdateService) runDue(now time.Time) {
+	settings, err := us.GetSettings()
+	if err != nil {
+		return
+	}
+	interval := time.Duration(settings.CheckIntervalHours) * time.Hour
+	if settings.AutoCheck && now.Sub(settings.LastCheckedAt) >= interval {
+		us.check()
+	}
+	us.autoDownloadIfIdle(now)
+}
+
+// check 检查更新并记录检查时间，失败只打印日志（状态中已带错误信息）
+func (us *UpdateService) check() {
+	if _, err := us.CheckForUpdate(); err != nil {
+		fmt.Printf("[WARN] 后台检查更新失败: %v\n", err)
+	}
+	if _, err := us.appSettings.update(func(settings *AppSettings) {
+		settings.Update.LastCheckedAt = time.Now()
+	}); err != nil {
+		fmt.Printf("[WARN] 保存更新检查时间失败: %v\n", err)
+	}
+}
+
+// autoDownloadIfIdle 开启自动下载且 relay 空闲足够久时下载已发现的更新，重启后即可应用；
+// 包管理器安装的版本交由包管理器升级，不自动下载
+func (us *UpdateService) autoDownloadIfIdle(now time.Time) {
+	settings, err := us.GetSettings()
+	if err != nil || !settings.AutoDownload || us.GetInstallSource().Managed {
+		return
+	}
+	if us.GetState().Status != UpdateStatusAvailable {
+		return
+	}
+	if relayIdleFor(now) < time.Duration(settings.IdleMinutes)*time.Minute {
+		return
+	}
+	if _, err := us.DownloadUpdate(); err != nil {
+		fmt.Printf("[WARN] 自动下载更新失败: %v\n", err)
+	}
+}
@@ -0,0 +1,581 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	updateManifestURL    = "https://github.com/daodao97/code-switch/releases/latest/download/latest.json"
+	updateCheckTimeout   = 20 * time.Second
+	updateManifestMaxLen = 1 << 20
+	updateDownloadDir    = "updates"
+)
+
+// 更新状态
+const (
+	UpdateStatusIdle        = "idle"
+	UpdateStatusChecking    = "checking"
+	UpdateStatusAvailable   = "available"
+	UpdateStatusDownloading = "downloading"
+	UpdateStatusPaused      = "paused"
+	UpdateStatusReady       = "ready"
+	UpdateStatusError       = "error"
+)
+
+// UpdateSettings 更新检查与下载配置
+type UpdateSettings struct {
+	// 代理地址，支持 http/https/socks5；为空时使用系统环境变量中的代理
+	Proxy string `json:"proxy"`
+	// 镜像地址模板，{url} 会替换为原始地址；不含 {url} 时作为前缀拼接（ghproxy 风格）
+	Mirrors []string `json:"mirrors"`
+	// 优先使用镜像，失败后再直连
+	PreferMirror bool `json:"prefer_mirror"`
+
+	// 后台定时检查与检查间隔（小时）
+	AutoCheck          bool `json:"auto_check"`
+	CheckIntervalHours int  `json:"check_interval_hours"`
+	// 启动时检查一次
+	CheckOnLaunch bool `json:"check_on_launch"`
+	// 发现更新后自动下载，等 relay 空闲 IdleMinutes 分钟后再开始，避免占用代理带宽
+	AutoDownload  bool      `json:"auto_download"`
+	IdleMinutes   int       `json:"idle_minutes"`
+	LastCheckedAt time.Time `json:"last_checked_at,omitempty"`
+}
+
+// UpdateAsset latest.json 中某个平台的安装包
+type UpdateAsset struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+	// 从旧版本升级的 bsdiff 补丁，key 为旧版本号
+	Patches map[string]UpdatePatch `json:"patches,omitempty"`
+}
+
+// UpdateManifest 发布时生成的 latest.json，assets 的 key 为 GOOS-GOARCH
+type UpdateManifest struct {
+	Version string                 `json:"version"`
+	Notes   string                 `json:"notes,omitempty"`
+	PubDate string                 `json:"pub_date,omitempty"`
+	Assets  map[string]UpdateAsset `json:"assets"`
+}
+
+// UpdateState 当前的更新状态，每次变化都会通知前端
+type UpdateState struct {
+	Status         string       `json:"status"`
+	CurrentVersion string       `json:"current_version"`
+	LatestVersion  string       `json:"latest_version,omitempty"`
+	Notes          string       `json:"notes,omitempty"`
+	Asset          *UpdateAsset `json:"asset,omitempty"`
+	// 下载进度 0-100
+	Progress float64 `json:"progress"`
+	// 下载完成并校验通过的安装包路径
+	Path      string    `json:"path,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at,omitempty"`
+	// 安装方式，由包管理器安装时前端展示升级命令而不是下载按钮
+	Install InstallSource `json:"install"`
+}
+
+type UpdateService struct {
+	appSettings  *AppSettingsService
+	dir          string
+	mu           sync.Mutex
+	downloadMu   sync.Mutex
+	state        UpdateState
+	stateHandler func(UpdateState)
+	stopCh       chan struct{}
+	installOnce  sync.Once
+	install      InstallSource
+	// 取消正在进行的下载，由 PauseDownload 调用
+	cancelDownload context.CancelFunc
+}
+
+func NewUpdateService(appSettings *AppSettingsService, currentVersion string) *UpdateService {
+	us := &UpdateService{
+		appSettings: appSettings,
+		dir:         filepath.Join(dataDir(), updateDownloadDir),
+		state: UpdateState{
+			Status:         UpdateStatusIdle,
+			CurrentVersion: currentVersion,
+		},
+	}
+	us.restorePendingDownload()
+	return us
+}
+
+func defaultUpdateSettings() UpdateSettings {
+	return UpdateSettings{
+		Mirrors:            []string{},
+		AutoCheck:          true,
+		CheckIntervalHours: 24,
+		CheckOnLaunch:      true,
+		IdleMinutes:        10,
+	}
+}
+
+func normalizeUpdateSettings(settings UpdateSettings) UpdateSettings {
+	settings.Proxy = strings.TrimSpace(settings.Proxy)
+	mirrors := make([]string, 0, len(settings.Mirrors))
+	for _, mirror := range settings.Mirrors {
+		if mirror = strings.TrimSpace(mirror); mirror != "" {
+			mirrors = append(mirrors, mirror)
+		}
+	}
+	settings.Mirrors = mirrors
+	defaults := defaultUpdateSettings()
+	if settings.CheckIntervalHours <= 0 {
+		settings.CheckIntervalHours = defaults.CheckIntervalHours
+	}
+	if settings.IdleMinutes < 0 {
+		settings.IdleMinutes = defaults.IdleMinutes
+	}
+	return settings
+}
+
+// GetSettings 返回更新配置
+func (us *UpdateService) GetSettings() (UpdateSettings, error) {
+	settings, err := us.appSettings.GetAppSettings()
+	if err != nil {
+		return UpdateSettings{}, err
+	}
+	return normalizeUpdateSettings(settings.Update), nil
+}
+
+// SaveSettings 保存更新配置
+func (us *UpdateService) SaveSettings(update UpdateSettings) (UpdateSettings, error) {
+	update = normalizeUpdateSettings(update)
+	if update.Proxy != "" {
+		if _, err := url.Parse(update.Proxy); err != nil {
+			return update, fmt.Errorf("代理地址无效: %w", err)
+		}
+	}
+	if _, err := us.appSettings.update(func(settings *AppSettings) {
+		update.LastCheckedAt = settings.Update.LastCheckedAt
+		settings.Update = update
+	}); err != nil {
+		return update, err
+	}
+	return update, nil
+}
+
+// SetStateHandler 设置更新状态变化时的回调（由 main 转为 update:state 事件）
+func (us *UpdateService) SetStateHandler(handler func(UpdateState)) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	us.stateHandler = handler
+}
+
+// GetState 返回当前的更新状态
+func (us *UpdateService) GetState() UpdateState {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	return us.state
+}
+
+func (us *UpdateService) setState(mutate func(state *UpdateState)) UpdateState {
+	us.mu.Lock()
+	mutate(&us.state)
+	state := us.state
+	handler := us.stateHandler
+	us.mu.Unlock()
+	if handler != nil {
+		handler(state)
+	}
+	return state
+}
+
+// CheckForUpdate 获取 latest.json 并与当前版本比较
+func (us *UpdateService) CheckForUpdate() (UpdateState, error) {
+	settings, err := us.GetSettings()
+	if err != nil {
+		return us.GetState(), err
+	}
+	previous := us.GetState()
+	if previous.Status == UpdateStatusDownloading {
+		return previous, nil
+	}
+	us.setState(func(state *UpdateState) {
+		state.Status = UpdateStatusChecking
+		state.Error = ""
+	})
+
+	manifest, err := fetchUpdateManifest(settings)
+	if err != nil {
+		return us.failUpdate(err), err
+	}
+	current := us.GetState().CurrentVersion
+	install := us.GetInstallSource()
+	asset, hasAsset := manifest.Assets[runtime.GOOS+"-"+runtime.GOARCH]
+	available := compareVersions(manifest.Version, current) > 0 && hasAsset
+	// 同一版本已下载完成或暂停时保留原状态，等待用户重启应用或继续下载
+	if available && (previous.Status == UpdateStatusReady || previous.Status == UpdateStatusPaused) && previous.LatestVersion == manifest.Version {
+		return us.setState(func(state *UpdateState) {
+			state.Status = previous.Status
+			state.CheckedAt = time.Now()
+		}), nil
+	}
+	state := us.setState(func(state *UpdateState) {
+		state.LatestVersion = manifest.Version
+		state.Notes = manifest.Notes
+		state.CheckedAt = time.Now()
+		state.Progress = 0
+		state.Asset = nil
+		state.Path = ""
+		state.Status = UpdateStatusIdle
+		state.Install = install
+		if available {
+			state.Asset = &asset
+			state.Status = UpdateStatusAvailable
+		}
+	})
+	return state, nil
+}
+
+// DownloadUpdate 下载当前平台的安装包，SHA256 与签名都校验通过后状态变为 ready
+func (us *UpdateService) DownloadUpdate() (UpdateState, error) {
+	us.downloadMu.Lock()
+	defer us.downloadMu.Unlock()
+
+	state := us.GetState()
+	if state.Status == UpdateStatusReady {
+		return state, nil
+	}
+	if state.Asset == nil {
+		return state, errors.New("没有可下载的更新，请先检查更新")
+	}
+	if install := us.GetInstallSource(); install.Managed {
+		return state, fmt.Errorf("应用通过 %s 安装，请使用 %s 升级", install.Source, install.Command())
+	}
+	settings, err := us.GetSettings()
+	if err != nil {
+		return state, err
+	}
+	asset := *state.Asset
+	version := state.LatestVersion
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	us.setState(func(state *UpdateState) {
+		state.Status = UpdateStatusDownloading
+		state.Error = ""
+		us.cancelDownload = cancel
+	})
+	us.savePendingDownload(version, state.Notes, asset)
+	path, err := us.downloadAsset(ctx, settings, version, asset)
+	us.mu.Lock()
+	us.cancelDownload = nil
+	us.mu.Unlock()
+	if errors.Is(err, context.Canceled) {
+		return us.setState(func(state *UpdateState) { state.Status = UpdateStatusPaused }), nil
+	}
+	if err != nil {
+		return us.failUpdate(err), err
+	}
+	us.clearPendingDownload()
+	us.pruneUpdateDirs(state.CurrentVersion, version)
+	return us.setState(func(state *UpdateState) {
+		state.Status = UpdateStatusReady
+		state.Progress = 100
+		state.Path = path
+	}), nil
+}
+
+func (us *UpdateService) failUpdate(err error) UpdateState {
+	return us.setState(func(state *UpdateState) {
+		state.Status = UpdateStatusError
+		state.Error = err.Error()
+	})
+}
+
+// downloadAsset 优先用补丁从当前版本的安装包生成新安装包，不可用或失败时依次尝试各个下载地址，
+// 边下载边计算 SHA256，校验通过后才放到最终路径。安装包按版本存放，作为下次升级的补丁基准
+func (us *UpdateService) downloadAsset(ctx context.Context, settings UpdateSettings, version string, asset UpdateAsset) (string, error) {
+	if asset.SHA256 == "" {
+		return "", errors.New("latest.json 缺少安装包的 SHA256")
+	}
+	dir := us.versionDir(version)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	target := filepath.Join(dir, updateAssetName(asset))
+	partial := target + ".part"
+
+	client, err := updateHTTPClient(settings, 0)
+	if err != nil {
+		return "", err
+	}
+	if err := us.applyPatch(ctx, client, settings, asset, partial); err == nil {
+		if err := os.Rename(partial, target); err != nil {
+			return "", err
+		}
+		return target, nil
+	} else if errors.Is(err, context.Canceled) {
+		return "", err
+	} else if !errors.Is(err, errNoUpdatePatch) {
+		_ = os.Remove(partial)
+		fmt.Printf("[WARN] 增量更新失败，改为完整下载: %v\n", err)
+	}
+
+	signatures := updateCandidateURLs(settings, asset.URL+updateSignatureSuffix)
+	var lastErr error
+	for i, candidate := range updateCandidateURLs(settings, asset.URL) {
+		lastErr = us.downloadTo(ctx, client, candidate, partial, asset.SHA256)
+		if errors.Is(lastErr, context.Canceled) {
+			// 暂停时保留已下载的部分，继续时从断点续传
+			return "", lastErr
+		}
+		if lastErr == nil {
+			if lastErr = verifyUpdateFile(client, signatures[i], partial); lastErr != nil {
+				_ = os.Remove(partial)
+			}
+		}
+		if lastErr == nil {
+			if err := os.Rename(partial, target); err != nil {
+				return "", err
+			}
+			return target, nil
+		}
+		fmt.Printf("[WARN] 下载更新失败 %s: %v\n", candidate, lastErr)
+	}
+	return "", fmt.Errorf("下载更新失败: %w", lastErr)
+}
+
+// downloadTo 下载到 path；path 已有部分内容时用 Range 断点续传，服务端不支持时重新下载。
+// SHA256 不一致时删除文件，网络错误与暂停时保留已下载的部分
+func (us *UpdateService) downloadTo(ctx context.Context, client *http.Client, source, path, expected string) error {
+	var offset int64
+	if info, err := os.Stat(path); err == nil {
+		offset = info.Size()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case offset > 0 && resp.StatusCode == http.StatusPartialContent:
+		flags |= os.O_APPEND
+	case offset > 0 && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// 上次已经下载完整，只需校验
+		return checkFileSHA256(path, expected)
+	case resp.StatusCode == http.StatusOK:
+		offset = 0
+		flags |= os.O_TRUNC
+	default:
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	hash := sha256.New()
+	if offset > 0 {
+		existing, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(hash, io.LimitReader(existing, offset))
+		existing.Close()
+		if err != nil {
+			return err
+		}
+	}
+	file, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	total := int64(-1)
+	if resp.ContentLength >= 0 {
+		total = offset + resp.ContentLength
+	}
+	progress := &updateProgressWriter{total: total, written: offset, report: func(percent float64) {
+		us.setState(func(state *UpdateState) { state.Progress = percent })
+	}}
+	if _, err := io.Copy(io.MultiWriter(file, hash, progress), resp.Body); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(actual, expected) {
+		file.Close()
+		_ = os.Remove(path)
+		return fmt.Errorf("SHA256 校验失败：期望 %s，实际 %s", expected, actual)
+	}
+	return nil
+}
+
+func checkFileSHA256(path, expected string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	file.Close()
+	if err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(actual, expected) {
+		_ = os.Remove(path)
+		return fmt.Errorf("SHA256 校验失败：期望 %s，实际 %s", expected, actual)
+	}
+	return nil
+}
+
+// updateProgressWriter 按整数百分比上报下载进度，避免频繁推送
+type updateProgressWriter struct {
+	total    int64
+	written  int64
+	reported int
+	report   func(float64)
+}
+
+func (w *updateProgressWriter) Write(p []byte) (int, error) {
+	w.written += int64(len(p))
+	if w.total > 0 {
+		if percent := int(w.written * 100 / w.total); percent > w.reported && percent < 100 {
+			w.reported = percent
+			w.report(float64(percent))
+		}
+	}
+	return len(p), nil
+}
+
+func fetchUpdateManifest(settings UpdateSettings) (UpdateManifest, error) {
+	client, err := updateHTTPClient(settings, updateCheckTimeout)
+	if err != nil {
+		return UpdateManifest{}, err
+	}
+	candidates := updateCandidateURLs(settings, updateManifestURL)
+	signatures := updateCandidateURLs(settings, updateManifestURL+updateSignatureSuffix)
+	var lastErr error
+	for i, candidate := range candidates {
+		manifest, err := fetchManifestFrom(client, candidate, signatures[i])
+		if err == nil {
+			return manifest, nil
+		}
+		lastErr = err
+		fmt.Printf("[WARN] 获取 latest.json 失败 %s: %v\n", candidate, err)
+	}
+	return UpdateManifest{}, fmt.Errorf("检查更新失败: %w", lastErr)
+}
+
+// fetchManifestFrom 获取 latest.json 与其签名，签名校验通过后才解析
+func fetchManifestFrom(client *http.Client, source, signatureSource string) (UpdateManifest, error) {
+	data, err := fetchUpdateBytes(client, source)
+	if err != nil {
+		return UpdateManifest{}, err
+	}
+	signature, err := fetchUpdateBytes(client, signatureSource)
+	if err != nil {
+		return UpdateManifest{}, fmt.Errorf("获取 latest.json 签名失败: %w", err)
+	}
+	if err := verifyUpdateSignature(data, signature); err != nil {
+		return UpdateManifest{}, fmt.Errorf("latest.json %w", err)
+	}
+	var manifest UpdateManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return UpdateManifest{}, fmt.Errorf("latest.json 格式错误: %w", err)
+	}
+	if manifest.Version == "" {
+		return UpdateManifest{}, errors.New("latest.json 缺少版本号")
+	}
+	return manifest, nil
+}
+
+func fetchUpdateBytes(client *http.Client, source string) ([]byte, error) {
+	resp, err := client.Get(source)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, updateManifestMaxLen))
+}
+
+// updateHTTPClient 按配置使用代理，未配置时沿用系统环境变量中的代理
+func updateHTTPClient(settings UpdateSettings, timeout time.Duration) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if settings.Proxy != "" {
+		proxyURL, err := url.Parse(settings.Proxy)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("代理地址无效: %s", settings.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
+
+// updateCandidateURLs 返回直连地址与各镜像地址，顺序由 PreferMirror 决定
+func updateCandidateURLs(settings UpdateSettings, source string) []string {
+	mirrors := make([]string, 0, len(settings.Mirrors))
+	for _, mirror := range settings.Mirrors {
+		if strings.Contains(mirror, "{url}") {
+			mirrors = append(mirrors, strings.ReplaceAll(mirror, "{url}", source))
+			continue
+		}
+		mirrors = append(mirrors, strings.TrimRight(mirror, "/")+"/"+source)
+	}
+	if settings.PreferMirror {
+		return append(mirrors, source)
+	}
+	return append([]string{source}, mirrors...)
+}
+
+// compareVersions 比较 v1.2.3 形式的版本号，忽略前缀 v 与预发布后缀
+func compareVersions(a, b string) int {
+	parse := func(version string) []int {
+		version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+		if i := strings.IndexAny(version, "-+"); i >= 0 {
+			version = version[:i]
+		}
+		parts := strings.Split(version, ".")
+		numbers := make([]int, len(parts))
+		for i, part := range parts {
+			numbers[i], _ = strconv.Atoi(part)
+		}
+		return numbers
+	}
+	left, right := parse(a), parse(b)
+	for i := 0; i < len(left) || i < len(right); i++ {
+		var x, y int
+		if i < len(left) {
+			x = left[i]
+		}
+		if i < len(right) {
+			y = right[i]
+		}
+		if x != y {
+			if x > y {
+				return 1
+			}
+			return -1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCompareVersions(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"v0.1.9", "v0.1.8", 1},
+		{"v0.1.10", "v0.1.9", 1},
+		{"0.2", "v0.2.0", 0},
+		{"v1.0.0-beta", "v1.0.0", 0},
+		{"v0.1.8", "v1.0.0", -1},
+	}
+	for _, tc := range cases {
+		if got := compareVersions(tc.a, tc.b); got != tc.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestUpdateCandidateURLs(t *testing.T) {
+	source := "https://github.com/a/b/releases/latest/download/latest.json"
+	settings := UpdateSettings{Mirrors: []string{"https://ghproxy.example/", "https://mirror.example/get?u={url}"}}
+	want := []string{
+		source,
+		"https://ghproxy.example/" + source,
+		"https://mirror.example/get?u=" + source,
+	}
+	if got := updateCandidateURLs(settings, source); !reflect.DeepEqual(got, want) {
+		t.Fatalf("direct first: got %v", got)
+	}
+	settings.PreferMirror = true
+	if got := updateCandidateURLs(settings, source); got[len(got)-1] != source || len(got) != 3 {
+		t.Fatalf("mirror first: got %v", got)
+	}
+}
+
+func TestVerifyUpdateSignature(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := updatePublicKey
+	defer func() { updatePublicKey = original }()
+
+	data := []byte(`{"version":"v9.9.9"}`)
+	signature := ed25519.Sign(privateKey, data)
+
+	updatePublicKey = ""
+	if err := verifyUpdateSignature(data, signature); err == nil {
+		t.Fatal("未内置公钥时应拒绝")
+	}
+	updatePublicKey = base64.StdEncoding.EncodeToString(publicKey)
+	if err := verifyUpdateSignature(data, signature); err != nil {
+		t.Fatalf("原始签名校验失败: %v", err)
+	}
+	if err := verifyUpdateSignature(data, []byte(base64.StdEncoding.EncodeToString(signature)+"\n")); err != nil {
+		t.Fatalf("base64 签名校验失败: %v", err)
+	}
+	if err := verifyUpdateSignature([]byte(`{"version":"v9.9.8"}`), signature); err == nil {
+		t.Fatal("内容被篡改时应校验失败")
+	}
+}
+
+func TestBspatch(t *testing.T) {
+	// 由 "hello world" 生成 "Hello gopher!"：差异块改写前 6 字节，新增块追加 "gopher!"
+	patch, _ := hex.DecodeString("4253444946463430290000000000000029000000000000000d00000000000000425a683931415926535908925c2d00000840004b80200030cd00c1a18c931f17724538509008925c2d425a6839314159265359d889e335000002c0016000400020002126419890b8bb9229c28486c44f19a8425a6839314159265359a820f2b50000011180200002c0d0002000310c010d31a84e2878bb9229c28485410795a8")
+	got, err := bspatch([]byte("hello world"), patch)
+	if err != nil {
+		t.Fatalf("bspatch: %v", err)
+	}
+	if string(got) != "Hello gopher!" {
+		t.Fatalf("bspatch = %q", got)
+	}
+	if _, err := bspatch([]byte("hello world"), patch[:40]); err == nil {
+		t.Fatal("truncated patch should fail")
+	}
+	if _, err := bspatch(nil, []byte("not a patch")); err == nil {
+		t.Fatal("invalid magic should fail")
+	}
+}
+
+func TestInstallSourceFromPath(t *testing.T) {
+	cases := []struct {
+		goos, exe, source string
+	}{
+		{"darwin", "/opt/homebrew/Caskroom/code-switch/1.2.0/CodeSwitch.app/Contents/MacOS/CodeSwitch", InstallSourceHomebrew},
+		{"windows", `C:\Users\me\scoop\apps\code-switch\current\CodeSwitch.exe`, InstallSourceScoop},
+		{"windows", `C:\Users\me\AppData\Local\Microsoft\WinGet\Packages\daodao97.CodeSwitch\CodeSwitch.exe`, InstallSourceWinget},
+		{"windows", `C:\Program Files\CodeSwitch\CodeSwitch.exe`, InstallSourceDirect},
+		{"linux", "/usr/local/bin/scoop/apps/codeswitch", InstallSourceDirect},
+	}
+	for _, tc := range cases {
+		got := installSourceFromPath(tc.goos, tc.exe)
+		if got.Source != tc.source {
+			t.Errorf("installSourceFromPath(%q) = %q, want %q", tc.exe, got.Source, tc.source)
+		}
+		if got.Managed != (tc.source != InstallSourceDirect) || (got.Managed && len(got.UpgradeCommand) == 0) {
+			t.Errorf("installSourceFromPath(%q) = %+v", tc.exe, got)
+		}
+	}
+}
+
+func TestChangelogBetween(t *testing.T) {
+	entries := []ChangelogEntry{{Version: "v1.1.0"}, {Version: "v1.3.0"}, {Version: "v1.2.0"}, {Version: "v1.0.0"}}
+	got := changelogBetween(entries, "1.0.0", "v1.2.0")
+	if len(got) != 2 || got[0].Version != "v1.2.0" || got[1].Version != "v1.1.0" {
+		t.Fatalf("changelogBetween = %+v", got)
+	}
+	if got := changelogBetween(entries, "v1.0.0", ""); len(got) != 3 || got[0].Version != "v1.3.0" {
+		t.Fatalf("changelogBetween without latest = %+v", got)
+	}
+}
+
+func TestParseChangelogSections(t *testing.T) {
+	body := "- 顶部说明\n## 新增功能\n\n### 优先级分组\n- 新增 Level 字段\n* 支持降级\n## 问题修复\n- 修复统计\r\n"
+	want := []ChangelogSection{
+		{Items: []string{"顶部说明"}},
+		{Title: "优先级分组", Items: []string{"新增 Level 字段", "支持降级"}},
+		{Title: "问题修复", Items: []string{"修复统计"}},
+	}
+	if got := parseChangelogSections(body); !reflect.DeepEqual(got, want) {
+		t.Fatalf("parseChangelogSections = %+v, want %+v", got, want)
+	}
+}
+
+func TestDownloadToResumes(t *testing.T) {
+	content := bytes.Repeat([]byte("code-switch update "), 1024)
+	sum := sha256.Sum256(content)
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "update.zip", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "update.zip.part")
+	if err := os.WriteFile(path, content[:1000], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	us := &UpdateService{}
+	if err := us.downloadTo(context.Background(), server.Client(), server.URL, path, hex.EncodeToString(sum[:])); err != nil {
+		t.Fatalf("downloadTo: %v", err)
+	}
+	if len(ranges) != 1 || ranges[0] != "bytes=1000-" {
+		t.Fatalf("range headers = %v", ranges)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || !bytes.Equal(data, content) {
+		t.Fatalf("resumed file mismatch, err = %v", err)
+	}
+
+	// 已下载部分与服务端内容不一致时校验失败并删除，下次重新下载
+	if err := os.WriteFile(path, []byte("corrupted"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := us.downloadTo(context.Background(), server.Client(), server.URL, path, hex.EncodeToString(sum[:])); err == nil {
+		t.Fatal("corrupted partial download should fail verification")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("corrupted partial download should be removed, stat err = %v", err)
+	}
+}
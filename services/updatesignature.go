@@ -0,0 +1,78 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const updateSignatureSuffix = ".sig"
+
+// updatePublicKey 校验更新签名的 Ed25519 公钥（base64 的 32 字节原始公钥或 PEM），
+// 生产构建通过 -ldflags "-X codeswitch/services.updatePublicKey=..." 注入，未设置 UPDATE_PUBLIC_KEY 时构建失败；
+// 只有开发构建为空，此时拒绝所有更新
+var updatePublicKey = ""
+
+// verifyUpdateSignature 使用内置公钥校验 detached 签名，签名可以是 64 字节原始格式或 base64 文本
+func verifyUpdateSignature(data, signature []byte) error {
+	publicKey, err := parseUpdatePublicKey(updatePublicKey)
+	if err != nil {
+		return err
+	}
+	if len(signature) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+		if err != nil || len(decoded) != ed25519.SignatureSize {
+			return errors.New("签名格式无效")
+		}
+		signature = decoded
+	}
+	if !ed25519.Verify(publicKey, data, signature) {
+		return errors.New("签名校验失败")
+	}
+	return nil
+}
+
+// verifyUpdateFile 下载安装包的签名并校验已下载的文件
+func verifyUpdateFile(client *http.Client, signatureSource, path string) error {
+	signature, err := fetchUpdateBytes(client, signatureSource)
+	if err != nil {
+		return fmt.Errorf("获取安装包签名失败: %w", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := verifyUpdateSignature(data, signature); err != nil {
+		return fmt.Errorf("安装包%w", err)
+	}
+	return nil
+}
+
+func parseUpdatePublicKey(text string) (ed25519.PublicKey, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, errors.New("未内置更新签名公钥，无法校验更新")
+	}
+	if block, _ := pem.Decode([]byte(text)); block != nil {
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("更新签名公钥无效: %w", err)
+		}
+		publicKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, errors.New("更新签名公钥不是 Ed25519 公钥")
+		}
+		return publicKey, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(text)
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, errors.New("更新签名公钥无效")
+	}
+	return ed25519.PublicKey(raw), nil
+}
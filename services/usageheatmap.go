@@ -0,0 +1,124 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	modelpricing "codeswitch/resources/model-pricing"
+
+	"github.com/daodao97/xgo/xdb"
+)
+
+// UsageHeatmapCell 某个星期几、某个小时的用量，Weekday 0 为周日
+type UsageHeatmapCell struct {
+	Weekday           int     `json:"weekday"`
+	Hour              int     `json:"hour"`
+	Requests          int64   `json:"requests"`
+	InputTokens       int64   `json:"input_tokens"`
+	OutputTokens      int64   `json:"output_tokens"`
+	ReasoningTokens   int64   `json:"reasoning_tokens"`
+	CacheCreateTokens int64   `json:"cache_create_tokens"`
+	CacheReadTokens   int64   `json:"cache_read_tokens"`
+	TotalCost         float64 `json:"total_cost"`
+}
+
+// UsageHeatmap 返回 [since, until) 内按星期几 × 小时（本地时间）汇总的用量，固定 7×24 个格子。
+// 汇总在 SQL 中完成，只按 provider/模型分组取回聚合结果再计算费用；时间格式为 "2006-01-02" 或 "2006-01-02 15:04:05"，
+// 为空时默认最近 4 周
+func (ls *LogService) UsageHeatmap(platform, since, until string) ([]UsageHeatmapCell, error) {
+	end := time.Now()
+	if until != "" {
+		parsed, err := parseLocalTime(until)
+		if err != nil {
+			return nil, err
+		}
+		end = parsed
+	}
+	start := startOfDay(end).AddDate(0, 0, -27)
+	if since != "" {
+		parsed, err := parseLocalTime(since)
+		if err != nil {
+			return nil, err
+		}
+		start = parsed
+	}
+	if !start.Before(end) {
+		return nil, fmt.Errorf("开始时间需早于结束时间")
+	}
+
+	cells := make([]UsageHeatmapCell, 7*24)
+	for i := range cells {
+		cells[i] = UsageHeatmapCell{Weekday: i / 24, Hour: i % 24}
+	}
+
+	db, err := xdb.DB("default")
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT
+		CAST(strftime('%w', created_at, 'localtime') AS INTEGER) AS weekday,
+		CAST(strftime('%H', created_at, 'localtime') AS INTEGER) AS hour,
+		COALESCE(provider, ''),
+		COALESCE(model, ''),
+		COUNT(*),
+		COALESCE(SUM(input_tokens), 0),
+		COALESCE(SUM(output_tokens), 0),
+		COALESCE(SUM(reasoning_tokens), 0),
+		COALESCE(SUM(cache_create_tokens), 0),
+		COALESCE(SUM(cache_read_tokens), 0)
+	FROM ` + requestLogTable() + `
+	WHERE created_at >= ?`
+	args := []any{start.UTC().Format(timeLayout)}
+	if until != "" {
+		query += ` AND created_at < ?`
+		args = append(args, end.UTC().Format(timeLayout))
+	}
+	if platform != "" {
+		query += ` AND platform = ?`
+		args = append(args, platform)
+	}
+	query += ` GROUP BY weekday, hour, provider, model`
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		if isNoSuchTableErr(err) {
+			return cells, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			weekday, hour                                    int
+			provider, model                                  string
+			requests, input, output, reasoning, create, read int64
+		)
+		if err := rows.Scan(&weekday, &hour, &provider, &model, &requests, &input, &output, &reasoning, &create, &read); err != nil {
+			return nil, err
+		}
+		if weekday < 0 || weekday > 6 || hour < 0 || hour > 23 {
+			continue
+		}
+		cell := &cells[weekday*24+hour]
+		cell.Requests += requests
+		cell.InputTokens += input
+		cell.OutputTokens += output
+		cell.ReasoningTokens += reasoning
+		cell.CacheCreateTokens += create
+		cell.CacheReadTokens += read
+		// 计价按 token 数线性计算，按 provider/模型聚合后再计算与逐条计算结果一致
+		cell.TotalCost += ls.calculateCost(provider, model, modelpricing.UsageSnapshot{
+			InputTokens:       int(input),
+			OutputTokens:      int(output),
+			CacheCreateTokens: int(create),
+			CacheReadTokens:   int(read),
+		}).TotalCost
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range cells {
+		cells[i].TotalCost, _ = displayCost(cells[i].TotalCost)
+	}
+	return cells, nil
+}
@@ -0,0 +1,297 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daodao97/xgo/xdb"
+)
+
+const (
+	usageWebhookTimeout     = 15 * time.Second
+	usageWebhookCheckPeriod = time.Minute
+	// 单次上报的最长时间范围，长时间未上报时分多次补齐
+	usageWebhookMaxWindow = 24 * time.Hour
+
+	usageWebhookSignatureHeader = "X-Code-Switch-Signature"
+	usageWebhookTimestampHeader = "X-Code-Switch-Timestamp"
+)
+
+// UsageWebhookPolicy 定时把聚合后的用量与费用 POST 到指定地址，便于团队汇总每个人的花费
+type UsageWebhookPolicy struct {
+	Enabled bool   `json:"enabled"`
+	URL     string `json:"url"`
+	// HMAC-SHA256 签名密钥，为空时不签名
+	Secret          string `json:"secret"`
+	IntervalMinutes int    `json:"interval_minutes"`
+	// 上报中标识本机使用者，默认 用户名@主机名
+	Developer string `json:"developer"`
+	// 已上报到的时间点（UTC），之后的用量在下次上报
+	SyncedUntil time.Time `json:"synced_until,omitempty"`
+}
+
+// UsageReport webhook 请求体，费用单位为美元
+type UsageReport struct {
+	Developer   string       `json:"developer"`
+	Since       time.Time    `json:"since"`
+	Until       time.Time    `json:"until"`
+	Currency    string       `json:"currency"`
+	Requests    int64        `json:"requests"`
+	TotalCost   float64      `json:"total_cost"`
+	Usage       []ExportStat `json:"usage"`
+	GeneratedAt time.Time    `json:"generated_at"`
+}
+
+// UsageWebhookResult 一次上报的结果
+type UsageWebhookResult struct {
+	Since    time.Time `json:"since"`
+	Until    time.Time `json:"until"`
+	Requests int64     `json:"requests"`
+	Status   int       `json:"status"`
+}
+
+type UsageWebhookService struct {
+	appSettings *AppSettingsService
+	logService  *LogService
+	httpClient  *http.Client
+	mu          sync.Mutex
+	syncMu      sync.Mutex
+	stopCh      chan struct{}
+}
+
+func NewUsageWebhookService(appSettings *AppSettingsService, logService *LogService) *UsageWebhookService {
+	return &UsageWebhookService{
+		appSettings: appSettings,
+		logService:  logService,
+		httpClient:  &http.Client{Timeout: usageWebhookTimeout},
+	}
+}
+
+func defaultUsageWebhookPolicy() UsageWebhookPolicy {
+	return UsageWebhookPolicy{IntervalMinutes: 60}
+}
+
+func normalizeUsageWebhookPolicy(policy UsageWebhookPolicy) UsageWebhookPolicy {
+	policy.URL = strings.TrimSpace(policy.URL)
+	policy.Developer = strings.TrimSpace(policy.Developer)
+	if policy.IntervalMinutes <= 0 {
+		policy.IntervalMinutes = defaultUsageWebhookPolicy().IntervalMinutes
+	}
+	if policy.Developer == "" {
+		policy.Developer = defaultDeveloperName()
+	}
+	return policy
+}
+
+// GetPolicy 返回用量上报配置
+func (uws *UsageWebhookService) GetPolicy() (UsageWebhookPolicy, error) {
+	settings, err := uws.appSettings.GetAppSettings()
+	if err != nil {
+		return UsageWebhookPolicy{}, err
+	}
+	return normalizeUsageWebhookPolicy(settings.UsageWebhook), nil
+}
+
+// SavePolicy 保存用量上报配置，已上报的进度保持不变
+func (uws *UsageWebhookService) SavePolicy(policy UsageWebhookPolicy) (UsageWebhookPolicy, error) {
+	policy = normalizeUsageWebhookPolicy(policy)
+	if policy.Enabled && !strings.HasPrefix(policy.URL, "http://") && !strings.HasPrefix(policy.URL, "https://") {
+		return policy, errors.New("请填写有效的 webhook 地址")
+	}
+	settings, err := uws.appSettings.update(func(settings *AppSettings) {
+		policy.SyncedUntil = settings.UsageWebhook.SyncedUntil
+		settings.UsageWebhook = policy
+	})
+	if err != nil {
+		return policy, err
+	}
+	return normalizeUsageWebhookPolicy(settings.UsageWebhook), nil
+}
+
+// SyncNow 立即上报上次上报之后的用量
+func (uws *UsageWebhookService) SyncNow() (UsageWebhookResult, error) {
+	policy, err := uws.GetPolicy()
+	if err != nil {
+		return UsageWebhookResult{}, err
+	}
+	if policy.URL == "" {
+		return UsageWebhookResult{}, errors.New("未配置 webhook 地址")
+	}
+	return uws.sync(policy, time.Now().UTC())
+}
+
+// Start 启动定时上报
+func (uws *UsageWebhookService) Start() error {
+	uws.mu.Lock()
+	defer uws.mu.Unlock()
+	if uws.stopCh != nil {
+		return nil
+	}
+	stopCh := make(chan struct{})
+	uws.stopCh = stopCh
+	go func() {
+		ticker := time.NewTicker(usageWebhookCheckPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				uws.syncIfDue()
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (uws *UsageWebhookService) Stop() error {
+	uws.mu.Lock()
+	defer uws.mu.Unlock()
+	if uws.stopCh != nil {
+		close(uws.stopCh)
+		uws.stopCh = nil
+	}
+	return nil
+}
+
+func (uws *UsageWebhookService) syncIfDue() {
+	policy, err := uws.GetPolicy()
+	if err != nil || !policy.Enabled || policy.URL == "" || isDemoMode() {
+		return
+	}
+	now := time.Now().UTC()
+	interval := time.Duration(policy.IntervalMinutes) * time.Minute
+	if !policy.SyncedUntil.IsZero() && now.Sub(policy.SyncedUntil) < interval {
+		return
+	}
+	if _, err := uws.sync(policy, now); err != nil {
+		fmt.Printf("[WARN] 用量上报失败: %v\n", err)
+	}
+}
+
+// sync 上报 [SyncedUntil, now) 的用量，成功后推进进度；失败或处于演示模式时保留进度，下次重试
+func (uws *UsageWebhookService) sync(policy UsageWebhookPolicy, now time.Time) (UsageWebhookResult, error) {
+	uws.syncMu.Lock()
+	defer uws.syncMu.Unlock()
+	if isDemoMode() {
+		return UsageWebhookResult{}, errors.New("演示模式下不上报用量，请先关闭演示模式")
+	}
+
+	since := policy.SyncedUntil
+	if since.IsZero() {
+		// 首次上报只覆盖最近一个周期
+		since = now.Add(-time.Duration(policy.IntervalMinutes) * time.Minute)
+	}
+	until := now
+	if until.Sub(since) > usageWebhookMaxWindow {
+		until = since.Add(usageWebhookMaxWindow)
+	}
+	report, err := uws.logService.usageReport(since, until)
+	if err != nil {
+		return UsageWebhookResult{}, err
+	}
+	report.Developer = policy.Developer
+	result := UsageWebhookResult{Since: since, Until: until, Requests: report.Requests}
+
+	payload, err := json.Marshal(report)
+	if err != nil {
+		return result, err
+	}
+	req, err := http.NewRequest(http.MethodPost, policy.URL, bytes.NewReader(payload))
+	if err != nil {
+		return result, err
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(usageWebhookTimestampHeader, timestamp)
+	if policy.Secret != "" {
+		req.Header.Set(usageWebhookSignatureHeader, signUsageWebhook(policy.Secret, timestamp, payload))
+	}
+	resp, err := uws.httpClient.Do(req)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+	result.Status = resp.StatusCode
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return result, fmt.Errorf("webhook 返回 HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if _, err := uws.appSettings.update(func(settings *AppSettings) {
+		settings.UsageWebhook.SyncedUntil = until
+	}); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// usageReport 汇总 [since, until) 的用量，按日期、平台、provider、模型分组
+func (ls *LogService) usageReport(since, until time.Time) (UsageReport, error) {
+	report := UsageReport{
+		Since:       since,
+		Until:       until,
+		Currency:    "USD",
+		Usage:       []ExportStat{},
+		GeneratedAt: time.Now().UTC(),
+	}
+	// 上报真实用量：不读取演示数据
+	records, err := xdb.New(activeRequestLogTable()).Selects(
+		xdb.WhereGte("created_at", since.UTC().Format(timeLayout)),
+		xdb.WhereLt("created_at", until.UTC().Format(timeLayout)),
+	)
+	if err != nil {
+		if errors.Is(err, xdb.ErrNotFound) || isNoSuchTableErr(err) {
+			return report, nil
+		}
+		return report, err
+	}
+	aggregates := make(map[string]*ExportStat)
+	for _, record := range records {
+		entry := requestLogFromRecord(record)
+		ls.decorateCost(&entry)
+		accumulateExportStat(aggregates, entry)
+		report.Requests++
+		report.TotalCost += entry.TotalCost
+	}
+	for _, stat := range aggregates {
+		report.Usage = append(report.Usage, *stat)
+	}
+	sortExportStats(report.Usage)
+	return report, nil
+}
+
+// signUsageWebhook 签名内容为 "时间戳.请求体"，格式 sha256=<hex>
+func signUsageWebhook(secret string, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func defaultDeveloperName() string {
+	name := ""
+	if current, err := user.Current(); err == nil {
+		name = current.Username
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		if name == "" {
+			return host
+		}
+		return name + "@" + host
+	}
+	return name
+}
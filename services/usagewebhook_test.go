@@ -0,0 +1,124 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/daodao97/xgo/xdb"
+)
+
+func TestSignUsageWebhookKnownVector(t *testing.T) {
+	got := signUsageWebhook("team-secret", "1700000000", []byte(`{"requests":1}`))
+	want := "sha256=57524c3ee391b9a53003f9902e67c994ce7ebd89232fbffb9d08b254449d3379"
+	if got != want {
+		t.Fatalf("签名不符：%s", got)
+	}
+}
+
+type capturedWebhook struct {
+	header http.Header
+	body   []byte
+}
+
+func newUsageWebhookTestServer(t *testing.T) (*httptest.Server, chan capturedWebhook) {
+	t.Helper()
+	received := make(chan capturedWebhook, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- capturedWebhook{header: r.Header.Clone(), body: body}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+func TestUsageWebhookSignsWithSecret(t *testing.T) {
+	useTestDB(t)
+	server, received := newUsageWebhookTestServer(t)
+	uws := NewUsageWebhookService(&AppSettingsService{path: filepath.Join(t.TempDir(), "app.json")}, &LogService{})
+	now := time.Unix(1700000000, 0).UTC()
+	policy := normalizeUsageWebhookPolicy(UsageWebhookPolicy{Enabled: true, URL: server.URL, Secret: "team-secret"})
+	if _, err := uws.sync(policy, now); err != nil {
+		t.Fatal(err)
+	}
+	req := <-received
+	if got := req.header.Get(usageWebhookTimestampHeader); got != "1700000000" {
+		t.Fatalf("时间戳头不符：%q", got)
+	}
+	mac := hmac.New(sha256.New, []byte("team-secret"))
+	mac.Write([]byte("1700000000." + string(req.body)))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if got := req.header.Get(usageWebhookSignatureHeader); got != want {
+		t.Fatalf("签名头应为 %s，实际 %s", want, got)
+	}
+}
+
+func TestUsageWebhookUnsignedWithoutSecret(t *testing.T) {
+	useTestDB(t)
+	server, received := newUsageWebhookTestServer(t)
+	uws := NewUsageWebhookService(&AppSettingsService{path: filepath.Join(t.TempDir(), "app.json")}, &LogService{})
+	policy := normalizeUsageWebhookPolicy(UsageWebhookPolicy{Enabled: true, URL: server.URL})
+	if _, err := uws.sync(policy, time.Now().UTC()); err != nil {
+		t.Fatal(err)
+	}
+	req := <-received
+	if _, ok := req.header[usageWebhookSignatureHeader]; ok {
+		t.Fatalf("未配置密钥时不应带签名头：%v", req.header)
+	}
+	if req.header.Get(usageWebhookTimestampHeader) == "" {
+		t.Fatal("未签名时仍应带时间戳头")
+	}
+}
+
+func TestUsageWebhookSkipsDemoMode(t *testing.T) {
+	useTestDB(t)
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+	settings := &AppSettingsService{path: filepath.Join(t.TempDir(), "app.json")}
+	uws := NewUsageWebhookService(settings, &LogService{})
+	if _, err := uws.SavePolicy(UsageWebhookPolicy{Enabled: true, URL: server.URL}); err != nil {
+		t.Fatal(err)
+	}
+	demoMode.Store(true)
+	t.Cleanup(func() { demoMode.Store(false) })
+
+	if _, err := uws.SyncNow(); err == nil {
+		t.Fatal("演示模式下手动上报应返回错误")
+	}
+	uws.syncIfDue()
+	policy, err := uws.GetPolicy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 0 || !policy.SyncedUntil.IsZero() {
+		t.Fatalf("演示模式下不应上报也不应推进进度：requests=%d synced=%v", requests, policy.SyncedUntil)
+	}
+}
+
+func TestUsageReportIgnoresDemoLogs(t *testing.T) {
+	useTestDB(t)
+	now := time.Now().UTC()
+	if _, err := xdb.New("request_log").Insert(xdb.Record{"platform": "claude", "provider": "relay", "created_at": now.Add(-time.Minute).Format(timeLayout)}); err != nil {
+		t.Fatal(err)
+	}
+	demoMode.Store(true)
+	t.Cleanup(func() { demoMode.Store(false) })
+	report, err := (&LogService{}).usageReport(now.Add(-time.Hour), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Requests != 1 {
+		t.Fatalf("用量报告应读取真实日志表：%+v", report)
+	}
+}
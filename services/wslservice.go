@@ -0,0 +1,329 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// WSL 双环境：应用运行在 Windows 上，CLI 运行在 WSL 发行版内时，把代理配置写进发行版的 ~/.claude 与 ~/.codex，
+// 地址指向 Windows 主机上的中转。NAT 网络下主机地址为发行版的默认网关，镜像网络与 WSL 1 下为 127.0.0.1
+const (
+	wslNetworkingNAT      = "nat"
+	wslNetworkingMirrored = "mirrored"
+	wslCommandTimeout     = 15 * time.Second
+)
+
+// WSLDistro 一个 WSL 发行版及其中 Claude Code 与 Codex 的代理状态
+type WSLDistro struct {
+	Name       string            `json:"name"`
+	Default    bool              `json:"default"`
+	State      string            `json:"state"`
+	Version    int               `json:"version"`
+	Home       string            `json:"home"`
+	Networking string            `json:"networking"`
+	BaseURL    string            `json:"base_url"`
+	Claude     ClaudeProxyStatus `json:"claude"`
+	Codex      ClaudeProxyStatus `json:"codex"`
+	Error      string            `json:"error,omitempty"`
+}
+
+type WSLService struct {
+	relayAddr string
+	// run 执行 wsl.exe，测试中替换
+	run func(args ...string) ([]byte, error)
+}
+
+// wslTarget 已解析的发行版：Home 为 Windows 侧可访问的 UNC 路径，BaseURL 为发行版内访问中转的地址
+type wslTarget struct {
+	Distro     string
+	Home       string
+	Networking string
+	BaseURL    string
+}
+
+func NewWSLService(relayAddr string) *WSLService {
+	return &WSLService{relayAddr: relayAddr, run: runWSLCommand}
+}
+
+// ListWSLDistros 列出已安装的发行版；非 Windows 或未安装 WSL 时返回空列表。
+// 只查询运行中的发行版的代理状态，避免为查看状态而启动发行版
+func (ws *WSLService) ListWSLDistros() ([]WSLDistro, error) {
+	if runtime.GOOS != "windows" {
+		return []WSLDistro{}, nil
+	}
+	output, err := ws.run("--list", "--verbose")
+	if err != nil {
+		return []WSLDistro{}, nil
+	}
+	distros := parseWSLList(decodeWSLOutput(output))
+	for i := range distros {
+		distro := &distros[i]
+		if !strings.EqualFold(distro.State, "Running") {
+			continue
+		}
+		target, err := ws.resolve(distro.Name, distro.Version)
+		if err != nil {
+			distro.Error = err.Error()
+			continue
+		}
+		distro.Home, distro.Networking, distro.BaseURL = target.Home, target.Networking, target.BaseURL
+		if distro.Claude, err = target.claude().ProxyStatus(); err != nil {
+			distro.Error = err.Error()
+		}
+		if distro.Codex, err = target.codex().ProxyStatus(); err != nil {
+			distro.Error = err.Error()
+		}
+	}
+	return distros, nil
+}
+
+// EnableWSLProxy 在发行版内开启代理，platforms 为空时同时处理 Claude Code 与 Codex。
+// NAT 网络下网关地址会在 WSL 重启后变化，状态显示未开启时需重新执行
+func (ws *WSLService) EnableWSLProxy(distro string, platforms []string) error {
+	target, err := ws.resolveDistro(distro)
+	if err != nil {
+		return err
+	}
+	return target.apply(platforms, true)
+}
+
+// DisableWSLProxy 用开启时的备份还原发行版内的配置
+func (ws *WSLService) DisableWSLProxy(distro string, platforms []string) error {
+	target, err := ws.resolveDistro(distro)
+	if err != nil {
+		return err
+	}
+	return target.apply(platforms, false)
+}
+
+func (ws *WSLService) resolveDistro(distro string) (wslTarget, error) {
+	if runtime.GOOS != "windows" {
+		return wslTarget{}, errors.New("WSL 仅在 Windows 上可用")
+	}
+	distro = strings.TrimSpace(distro)
+	if distro == "" {
+		return wslTarget{}, errors.New("发行版名称不能为空")
+	}
+	output, err := ws.run("--list", "--verbose")
+	if err != nil {
+		return wslTarget{}, fmt.Errorf("读取 WSL 发行版失败: %w", err)
+	}
+	for _, item := range parseWSLList(decodeWSLOutput(output)) {
+		if strings.EqualFold(item.Name, distro) {
+			return ws.resolve(item.Name, item.Version)
+		}
+	}
+	return wslTarget{}, fmt.Errorf("未找到 WSL 发行版: %s", distro)
+}
+
+// resolve 在发行版内查询主目录与网络模式，会按需启动发行版
+func (ws *WSLService) resolve(distro string, version int) (wslTarget, error) {
+	target := wslTarget{Distro: distro, Networking: wslNetworkingNAT}
+	output, err := ws.run("-d", distro, "-e", "sh", "-c", `printf %s "$HOME"`)
+	if err != nil {
+		return target, fmt.Errorf("读取 %s 的主目录失败: %w", distro, err)
+	}
+	home := strings.TrimSpace(decodeWSLOutput(output))
+	if !strings.HasPrefix(home, "/") {
+		return target, fmt.Errorf("无法识别 %s 的主目录: %q", distro, home)
+	}
+	target.Home = wslUNCPath(distro, home)
+
+	host := "127.0.0.1"
+	switch {
+	case version == 1:
+		target.Networking = wslNetworkingMirrored
+	case ws.mirrored(distro):
+		target.Networking = wslNetworkingMirrored
+	default:
+		output, err := ws.run("-d", distro, "-e", "sh", "-c", "ip route show default")
+		if err != nil {
+			return target, fmt.Errorf("读取 %s 的网关失败: %w", distro, err)
+		}
+		if host = parseDefaultGateway(decodeWSLOutput(output)); host == "" {
+			return target, fmt.Errorf("无法识别 %s 的默认网关", distro)
+		}
+	}
+	base, err := wslRelayBaseURL(ws.relayAddr, host)
+	if err != nil {
+		return target, err
+	}
+	target.BaseURL = base
+	return target, nil
+}
+
+// mirrored 优先使用发行版内的 wslinfo，旧版本 WSL 没有该命令时读取 %USERPROFILE%\.wslconfig
+func (ws *WSLService) mirrored(distro string) bool {
+	if output, err := ws.run("-d", distro, "-e", "wslinfo", "--networking-mode"); err == nil {
+		return strings.EqualFold(strings.TrimSpace(decodeWSLOutput(output)), wslNetworkingMirrored)
+	}
+	data, err := os.ReadFile(filepath.Join(userHomeDir(), ".wslconfig"))
+	if err != nil {
+		return false
+	}
+	return wslConfigMirrored(string(data))
+}
+
+func (target wslTarget) apply(platforms []string, enable bool) error {
+	if len(platforms) == 0 {
+		platforms = []string{"claude", "codex"}
+	}
+	for _, platform := range platforms {
+		var err error
+		switch strings.ToLower(strings.TrimSpace(platform)) {
+		case "claude", "claude-code", "claude_code":
+			if enable {
+				err = target.claude().EnableProxy()
+			} else {
+				err = target.claude().DisableProxy()
+			}
+		case "codex":
+			if enable {
+				err = target.codex().EnableProxy()
+			} else {
+				err = target.codex().DisableProxy()
+			}
+		default:
+			return fmt.Errorf("不支持的平台: %s", platform)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", target.Distro, err)
+		}
+	}
+	return nil
+}
+
+func (target wslTarget) claude() *ClaudeSettingsService {
+	return &ClaudeSettingsService{relayAddr: target.BaseURL, home: target.Home}
+}
+
+func (target wslTarget) codex() *CodexSettingsService {
+	return &CodexSettingsService{relayAddr: target.BaseURL, home: target.Home}
+}
+
+func runWSLCommand(args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), wslCommandTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, "wsl.exe", args...).Output()
+	if err != nil {
+		if message := strings.TrimSpace(decodeWSLOutput(output)); message != "" {
+			return output, fmt.Errorf("%w: %s", err, message)
+		}
+		return output, err
+	}
+	return output, nil
+}
+
+// decodeWSLOutput wsl.exe 自身的输出为 UTF-16LE，在发行版内执行的命令输出为 UTF-8
+func decodeWSLOutput(data []byte) string {
+	data = bytes.TrimPrefix(data, []byte{0xff, 0xfe})
+	if len(data) >= 2 && len(data)%2 == 0 && bytes.Count(data, []byte{0}) >= len(data)/4 {
+		units := make([]uint16, 0, len(data)/2)
+		for i := 0; i+1 < len(data); i += 2 {
+			units = append(units, uint16(data[i])|uint16(data[i+1])<<8)
+		}
+		return strings.ReplaceAll(string(utf16.Decode(units)), "\r\n", "\n")
+	}
+	return strings.ReplaceAll(string(data), "\r\n", "\n")
+}
+
+// parseWSLList 解析 wsl --list --verbose，默认发行版以 * 开头；表头随系统语言变化，按列数识别
+func parseWSLList(output string) []WSLDistro {
+	distros := make([]WSLDistro, 0)
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for first := true; scanner.Scan(); first = false {
+		line := strings.TrimSpace(scanner.Text())
+		if first || line == "" {
+			continue
+		}
+		distro := WSLDistro{}
+		if strings.HasPrefix(line, "*") {
+			distro.Default = true
+			line = strings.TrimSpace(line[1:])
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		distro.Version = 2
+		if fields[len(fields)-1] == "1" {
+			distro.Version = 1
+		}
+		distro.State = fields[len(fields)-2]
+		distro.Name = strings.Join(fields[:len(fields)-2], " ")
+		distros = append(distros, distro)
+	}
+	return distros
+}
+
+// parseDefaultGateway 从 ip route show default 的输出中取网关地址
+func parseDefaultGateway(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		for i := 0; i+1 < len(fields); i++ {
+			if fields[i] == "via" && net.ParseIP(fields[i+1]) != nil {
+				return fields[i+1]
+			}
+		}
+	}
+	return ""
+}
+
+// wslConfigMirrored 判断 .wslconfig 的 [wsl2] 段是否设置了 networkingMode=mirrored
+func wslConfigMirrored(content string) bool {
+	section := ""
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.ToLower(strings.TrimSpace(line[1 : len(line)-1]))
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if ok && section == "wsl2" && strings.EqualFold(strings.TrimSpace(key), "networkingMode") {
+			return strings.EqualFold(strings.Trim(strings.TrimSpace(value), `"`), wslNetworkingMirrored)
+		}
+	}
+	return false
+}
+
+// wslUNCPath 把发行版内的路径转换为 Windows 侧的 \\wsl.localhost 路径，旧版本 Windows 只支持 \\wsl$
+func wslUNCPath(distro, linuxPath string) string {
+	suffix := strings.ReplaceAll(strings.TrimPrefix(linuxPath, "/"), "/", `\`)
+	path := `\\wsl.localhost\` + distro + `\` + suffix
+	if runtime.GOOS == "windows" {
+		if _, err := os.Stat(`\\wsl.localhost\` + distro); err != nil {
+			path = `\\wsl$\` + distro + `\` + suffix
+		}
+	}
+	return path
+}
+
+// wslRelayBaseURL 用中转的端口拼出发行版内的访问地址；中转只监听回环地址时 NAT 网络下无法访问
+func wslRelayBaseURL(relayAddr, host string) (string, error) {
+	relayAddr = strings.TrimSpace(relayAddr)
+	if relayAddr == "" {
+		relayAddr = ":18100"
+	}
+	listenHost, port, err := net.SplitHostPort(relayAddr)
+	if err != nil {
+		return "", fmt.Errorf("无法识别中转地址 %s: %w", relayAddr, err)
+	}
+	if ip := net.ParseIP(listenHost); host != "127.0.0.1" && (strings.EqualFold(listenHost, "localhost") || ip != nil && ip.IsLoopback()) {
+		return "", fmt.Errorf("中转只监听 %s，WSL 的 NAT 网络无法访问，请改为监听所有地址或开启镜像网络", listenHost)
+	}
+	return "http://" + net.JoinHostPort(host, port), nil
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestParseWSLOutput(t *testing.T) {
+	text := "  NAME            STATE           VERSION\r\n* Ubuntu-22.04    Running         2\r\n  Debian          Stopped         1\r\n"
+	units := utf16.Encode([]rune(text))
+	data := make([]byte, 0, len(units)*2)
+	for _, unit := range units {
+		data = append(data, byte(unit), byte(unit>>8))
+	}
+	distros := parseWSLList(decodeWSLOutput(data))
+	if len(distros) != 2 {
+		t.Fatalf("distros = %+v", distros)
+	}
+	if d := distros[0]; d.Name != "Ubuntu-22.04" || !d.Default || d.State != "Running" || d.Version != 2 {
+		t.Fatalf("unexpected default distro %+v", d)
+	}
+	if d := distros[1]; d.Name != "Debian" || d.Default || d.Version != 1 {
+		t.Fatalf("unexpected distro %+v", d)
+	}
+	if got := decodeWSLOutput([]byte("/home/dev")); got != "/home/dev" {
+		t.Fatalf("utf-8 output decoded as %q", got)
+	}
+
+	if got := parseDefaultGateway("default via 172.29.160.1 dev eth0 proto kernel\n"); got != "172.29.160.1" {
+		t.Fatalf("gateway = %q", got)
+	}
+	if !wslConfigMirrored("[wsl2]\nmemory=8GB\nnetworkingMode=mirrored\n") || wslConfigMirrored("[experimental]\nnetworkingMode=mirrored\n") {
+		t.Fatal("networkingMode should only be read from [wsl2]")
+	}
+	if got, err := wslRelayBaseURL(":18100", "172.29.160.1"); err != nil || got != "http://172.29.160.1:18100" {
+		t.Fatalf("base url = %q %v", got, err)
+	}
+	if _, err := wslRelayBaseURL("127.0.0.1:18100", "172.29.160.1"); err == nil {
+		t.Fatal("loopback-only relay should be rejected for NAT networking")
+	}
+}
+
+func TestWSLTargetApply(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	home := t.TempDir()
+	writeSkillFiles(t, home, map[string]string{
+		".claude/settings.json": `{"model":"opus"}`,
+	})
+	before, hadBefore := proxyIntent(configWatchTargetClaude)
+	target := wslTarget{Distro: "Ubuntu", Home: home, BaseURL: "http://172.29.160.1:18100"}
+	if err := target.apply(nil, true); err != nil {
+		t.Fatal(err)
+	}
+	var settings claudeSettingsFile
+	data, err := os.ReadFile(filepath.Join(home, ".claude", "settings.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &settings); err != nil || settings.Env["ANTHROPIC_BASE_URL"] != target.BaseURL {
+		t.Fatalf("unexpected claude settings %s", data)
+	}
+	config, err := os.ReadFile(filepath.Join(home, ".codex", "config.toml"))
+	if err != nil || !strings.Contains(string(config), target.BaseURL) {
+		t.Fatalf("unexpected codex config %s %v", config, err)
+	}
+	if after, ok := proxyIntent(configWatchTargetClaude); after != before || ok != hadBefore {
+		t.Fatal("WSL targets should not change the local proxy intent")
+	}
+	if status, err := target.codex().ProxyStatus(); err != nil || !status.Enabled {
+		t.Fatalf("codex status = %+v %v", status, err)
+	}
+
+	if err := target.apply([]string{"claude", "codex"}, false); err != nil {
+		t.Fatal(err)
+	}
+	data, err = os.ReadFile(filepath.Join(home, ".claude", "settings.json"))
+	if err != nil || string(data) != `{"model":"opus"}` {
+		t.Fatalf("claude settings not restored: %s %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".codex", "config.toml")); !os.IsNotExist(err) {
+		t.Fatalf("codex config should be removed, got %v", err)
+	}
+	if err := target.apply([]string{"gemini"}, true); err == nil {
+		t.Fatal("unsupported platform should fail")
+	}
+}